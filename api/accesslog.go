@@ -0,0 +1,136 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// requestIDHeader carries the ID of a request, taken from the client or a
+// proxy in front when it sends one, so a request can be followed from the
+// edge to the access log and back to the client in the response
+const requestIDHeader = "X-Request-ID"
+
+type requestIDKey struct{}
+
+// newRequestID returns a random 128-bit ID
+func newRequestID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// validRequestID reports whether a client-supplied ID is safe to log and
+// echo: 1 to 128 visible ASCII characters
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// requestID returns the ID of the request of ctx, empty outside of
+// requestIDMiddleware
+func requestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// requestIDMiddleware keeps the X-Request-ID of the request, or generates
+// one, and sets it on the response and in the request context
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+// statusRecorder records the status and size of a response. Unwrap lets
+// http.ResponseController reach the flushes and deadlines of the
+// underlying writer, which /events and the long polls rely on.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (w *statusRecorder) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusRecorder) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+func (w *statusRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// newAccessLogger returns the access logger of ACCESS_LOG: "json", the
+// default, or "text" to stderr, nil for "off". Access lines stay out of
+// /admin/logs, where they would push out the warnings.
+func newAccessLogger(format string, out io.Writer) (*slog.Logger, error) {
+	switch format {
+	case "", "json":
+		return slog.New(slog.NewJSONHandler(out, nil)), nil
+	case "text":
+		return slog.New(slog.NewTextHandler(out, nil)), nil
+	case "off":
+		return nil, nil
+	}
+	return nil, fmt.Errorf("invalid ACCESS_LOG %q: must be json, text or off", format)
+}
+
+// accessLogMiddleware logs one line per request once it is served. It
+// runs inside requestIDMiddleware and tracingMiddleware to log the request
+// and trace IDs.
+func accessLogMiddleware(logger *slog.Logger, next http.Handler) http.Handler {
+	if logger == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		attrs := []slog.Attr{
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", rec.status),
+			slog.Duration("latency", time.Since(start)),
+			slog.Int64("bytes", rec.bytes),
+			slog.String("clientIp", clientIP(r)),
+			slog.String("requestId", requestID(r.Context())),
+		}
+		if sc := trace.SpanContextFromContext(r.Context()); sc.IsValid() {
+			attrs = append(attrs, slog.String("traceId", sc.TraceID().String()))
+		}
+		logger.LogAttrs(r.Context(), slog.LevelInfo, "request", attrs...)
+	})
+}
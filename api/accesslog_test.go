@@ -0,0 +1,92 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestIDMiddleware(t *testing.T) {
+	var seen string
+	handler := requestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = requestID(r.Context())
+		writeError(w, r, http.StatusNotFound, "no such week")
+	}))
+
+	req := httptest.NewRequest("GET", "/games/2024/30", nil)
+	req.Header.Set(requestIDHeader, "edge-42")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if seen != "edge-42" || rec.Header().Get(requestIDHeader) != "edge-42" {
+		t.Errorf("expected the client ID to be kept, got %q and %q", seen, rec.Header().Get(requestIDHeader))
+	}
+	var p Problem
+	if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil || p.RequestID != "edge-42" {
+		t.Errorf("expected the ID in the problem, got %+v, %v", p, err)
+	}
+
+	for _, sent := range []string{"", "has space", strings.Repeat("x", 129)} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set(requestIDHeader, sent)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if id := rec.Header().Get(requestIDHeader); len(id) != 32 || id != seen {
+			t.Errorf("expected a generated ID in place of %q, got %q", sent, id)
+		}
+	}
+}
+
+func TestAccessLogMiddleware(t *testing.T) {
+	var out bytes.Buffer
+	logger, err := newAccessLogger("json", &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := requestIDMiddleware(accessLogMiddleware(logger, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("hello"))
+	})))
+
+	req := httptest.NewRequest("POST", "/games/2024/1?dryRun=true", nil)
+	req.RemoteAddr = "192.0.2.7:5000"
+	req.Header.Set(requestIDHeader, "abc")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	var line struct {
+		Msg       string `json:"msg"`
+		Method    string `json:"method"`
+		Path      string `json:"path"`
+		Status    int    `json:"status"`
+		Latency   int64  `json:"latency"`
+		Bytes     int64  `json:"bytes"`
+		ClientIP  string `json:"clientIp"`
+		RequestID string `json:"requestId"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &line); err != nil {
+		t.Fatalf("%v: %s", err, out.String())
+	}
+	if line.Msg != "request" || line.Method != "POST" || line.Path != "/games/2024/1" || line.Status != http.StatusCreated ||
+		line.Bytes != 5 || line.ClientIP != "192.0.2.7" || line.RequestID != "abc" {
+		t.Errorf("unexpected access log %s", out.String())
+	}
+
+	if logger, err := newAccessLogger("off", &out); err != nil || logger != nil {
+		t.Errorf("expected no logger with ACCESS_LOG=off, got %v, %v", logger, err)
+	}
+	if _, err := newAccessLogger("xml", &out); err == nil {
+		t.Error("expected an unknown format to be refused")
+	}
+}
+
+func TestStatusRecorderUnwraps(t *testing.T) {
+	rec := httptest.NewRecorder()
+	w := &statusRecorder{ResponseWriter: rec}
+	if err := http.NewResponseController(w).Flush(); err != nil {
+		t.Errorf("expected flushes to reach the underlying writer, got %v", err)
+	}
+	if !rec.Flushed {
+		t.Error("expected the recorder to be flushed")
+	}
+}
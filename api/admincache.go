@@ -0,0 +1,183 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jjway/rewatchableGamesApi-go/store"
+)
+
+// CacheFileStats describes one cached week file
+type CacheFileStats struct {
+	File     string    `json:"file"`
+	Games    int       `json:"games"`
+	Bytes    int       `json:"bytes"`
+	LoadedAt time.Time `json:"loadedAt"`
+	Pinned   bool      `json:"pinned,omitempty"`
+}
+
+// CacheStats is the /admin/cache document. Entries and Bytes are the
+// resident weeks, Weeks every known one including those evicted; the
+// limits are omitted when unlimited.
+type CacheStats struct {
+	Entries      int              `json:"entries"`
+	Bytes        int              `json:"bytes"`
+	MaxEntries   int              `json:"maxEntries,omitempty"`
+	MaxBytes     int              `json:"maxBytes,omitempty"`
+	PinnedSeason string           `json:"pinnedSeason,omitempty"`
+	Weeks        int              `json:"weeks"`
+	Hits         uint64           `json:"hits"`
+	Misses       uint64           `json:"misses"`
+	Evictions    uint64           `json:"evictions"`
+	Files        []CacheFileStats `json:"files"`
+}
+
+// PurgeResult lists the week files a purge re-read from the store
+type PurgeResult struct {
+	Purged []string          `json:"purged"`
+	Failed map[string]string `json:"failed,omitempty"`
+}
+
+// cacheStats summarizes the cache, by file name
+func cacheStats() CacheStats {
+	stats := cache.stats()
+	sort.Slice(stats.Files, func(i, j int) bool { return stats.Files[i].File < stats.Files[j].File })
+	return stats
+}
+
+// purgeCache re-reads every week file matching prefix ("" for all, "2024/"
+// for a season or "2024/3.json" for a week) from the store, cached or not,
+// and forgets the files remembered as missing
+func purgeCache(prefix string) (PurgeResult, error) {
+	names, err := purgeTargets(prefix)
+	if err != nil {
+		return PurgeResult{}, err
+	}
+
+	missingFilesMu.Lock()
+	for name := range missingFiles {
+		if strings.HasPrefix(name, prefix) {
+			delete(missingFiles, name)
+		}
+	}
+	missingFilesMu.Unlock()
+
+	result := PurgeResult{Purged: make([]string, 0, len(names))}
+	for _, name := range names {
+		if err := reloadFile(name); err != nil {
+			if result.Failed == nil {
+				result.Failed = make(map[string]string)
+			}
+			result.Failed[name] = err.Error()
+			continue
+		}
+		result.Purged = append(result.Purged, name)
+	}
+	return result, nil
+}
+
+// purgeTargets lists the week files matching prefix, known to the cache or
+// in the store, sorted
+func purgeTargets(prefix string) ([]string, error) {
+	names := make(map[string]bool)
+	for name := range cache.weekCounts() {
+		if strings.HasPrefix(name, prefix) {
+			names[name] = true
+		}
+	}
+
+	listed, err := dataStore.ListFiles()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range listed {
+		if strings.HasPrefix(name, prefix) {
+			names[name] = true
+		}
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	return sorted, nil
+}
+
+// purgeDryRun reports the cache entries purgeCache would drop
+func purgeDryRun(prefix string) (*DryRunReport, error) {
+	names, err := purgeTargets(prefix)
+	if err != nil {
+		return nil, err
+	}
+	rep := newDryRunReport("purge " + prefix)
+	for _, name := range names {
+		rep.Invalidations = append(rep.Invalidations, "week "+name)
+	}
+	missingFilesMu.Lock()
+	for name := range missingFiles {
+		if strings.HasPrefix(name, prefix) {
+			rep.Invalidations = append(rep.Invalidations, "missing "+name)
+		}
+	}
+	missingFilesMu.Unlock()
+	if len(names) > 0 {
+		rep.Invalidations = append(rep.Invalidations, "top games", "quantiles")
+	}
+	sort.Strings(rep.Invalidations)
+	return rep, nil
+}
+
+// handleCacheStats reports the budget and counters of the cache and the
+// size and load time of every resident week file
+func handleCacheStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(cacheStats()); err != nil {
+		writeError(w, r, http.StatusInternalServerError, "error encoding response")
+	}
+}
+
+// handleCachePurge forces week files to be re-read from the store, all of
+// them or only those of ?year= and optionally ?week=
+func handleCachePurge(w http.ResponseWriter, r *http.Request) {
+	year, week := r.URL.Query().Get("year"), r.URL.Query().Get("week")
+	prefix := ""
+	switch {
+	case week != "" && year == "":
+		writeQueryError(w, r, &QueryError{Param: "week", Value: week, Message: "requires year"})
+		return
+	case week != "":
+		prefix = store.WeekFile(year, week)
+	case year != "":
+		prefix = year + "/"
+	}
+
+	dryRun, qerr := dryRunRequested(r)
+	if qerr != nil {
+		writeQueryError(w, r, qerr)
+		return
+	}
+	if dryRun {
+		rep, err := purgeDryRun(prefix)
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, "could not list data files")
+			return
+		}
+		writeDryRun(w, r, rep)
+		return
+	}
+
+	result, err := purgeCache(prefix)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "could not list data files")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		writeError(w, r, http.StatusInternalServerError, "error encoding response")
+	}
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCacheStats(t *testing.T) {
+	dir := setupTestData(t)
+	useTestStore(t, dir)
+	loadGameStats("2024/1.json")
+
+	rec := httptest.NewRecorder()
+	handleCacheStats(rec, httptest.NewRequest("GET", "/admin/cache", nil))
+
+	var stats CacheStats
+	if err := json.Unmarshal(rec.Body.Bytes(), &stats); err != nil {
+		t.Fatal(err)
+	}
+	info, _ := os.Stat(filepath.Join(dir, "2024", "1.json"))
+	if stats.Entries != 1 || stats.Bytes != int(info.Size()) {
+		t.Errorf("expected 1 entry of %d bytes, got %+v", info.Size(), stats)
+	}
+	if f := stats.Files[0]; f.File != "2024/1.json" || f.Games != 1 || f.LoadedAt.IsZero() {
+		t.Errorf("unexpected file stats %+v", f)
+	}
+}
+
+func TestCachePurge(t *testing.T) {
+	dir := setupTestData(t)
+	useTestStore(t, dir)
+	loadGameStats("2024/1.json")
+	loadGameStats("2024/2.json")
+
+	// Fix a week file behind the cache's back, and publish a missing week
+	os.WriteFile(filepath.Join(dir, "2024", "1.json"), []byte(`[{"id": "fixed"}]`), 0644)
+	if _, err := loadGameStats("2024/3.json"); err == nil {
+		t.Fatal("expected week 3 to be missing")
+	}
+	os.WriteFile(filepath.Join(dir, "2024", "3.json"), []byte(`[{"id": "new"}]`), 0644)
+
+	rec := httptest.NewRecorder()
+	handleCachePurge(rec, httptest.NewRequest("POST", "/admin/cache/purge?year=2024&week=1", nil))
+	var result PurgeResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Purged) != 1 || result.Purged[0] != "2024/1.json" {
+		t.Errorf("expected only week 1 to be purged, got %+v", result)
+	}
+	if games, _ := loadGameStats("2024/1.json"); len(games) != 1 || games[0].ID != "fixed" {
+		t.Errorf("expected the fixed week, got %+v", games)
+	}
+	if _, err := loadGameStats("2024/3.json"); err == nil {
+		t.Error("expected week 3 to still be remembered as missing")
+	}
+
+	rec = httptest.NewRecorder()
+	handleCachePurge(rec, httptest.NewRequest("POST", "/admin/cache/purge?year=2024", nil))
+	if games, err := loadGameStats("2024/3.json"); err != nil || games[0].ID != "new" {
+		t.Errorf("expected week 3 after a season purge, got %v, %v", games, err)
+	}
+}
+
+func TestCachePurgeRequiresYearForWeek(t *testing.T) {
+	rec := httptest.NewRecorder()
+	handleCachePurge(rec, httptest.NewRequest("POST", "/admin/cache/purge?week=1", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", rec.Code)
+	}
+}
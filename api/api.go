@@ -0,0 +1,1048 @@
+// Package api serves the rewatchability ratings of NFL games over HTTP
+// from the week files of a store, and implements the commands of the
+// server binary.
+package api
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"go.opentelemetry.io/otel/attribute"
+	"golang.org/x/sync/singleflight"
+
+	"github.com/jjway/rewatchableGamesApi-go/extensions"
+	"github.com/jjway/rewatchableGamesApi-go/ratings"
+
+	"github.com/jjway/rewatchableGamesApi-go/store"
+)
+
+var json = jsoniter.ConfigCompatibleWithStandardLibrary
+
+// GameStats is a game as stored in the week files
+type GameStats = ratings.GameStats
+
+// cacheEntry is a decoded week file and the time it was loaded
+type cacheEntry struct {
+	games    []GameStats
+	size     int
+	hash     string // hex SHA-256 of the week file
+	loadedAt time.Time
+}
+
+// cacheTTL is how long an entry is served before it is re-read from the
+// store; zero keeps entries until they are reloaded or evicted
+var cacheTTL time.Duration
+
+// loadGameStats loads game stats from cache or the store
+func loadGameStats(name string) ([]GameStats, error) {
+	return loadGameStatsContext(context.Background(), name)
+}
+
+// loadGameStatsContext is loadGameStats traced under the span of ctx
+func loadGameStatsContext(ctx context.Context, name string) ([]GameStats, error) {
+	ctx, span := startChildSpan(ctx, "cache.lookup", attribute.String("week.file", name))
+	defer span.End()
+	if entry, ok := cache.get(name, cacheTTL); ok {
+		span.SetAttributes(attribute.Bool("cache.hit", true))
+		return entry.games, nil
+	}
+	span.SetAttributes(attribute.Bool("cache.hit", false))
+	if knownMissing(name) {
+		return nil, errMissing(name)
+	}
+
+	// Concurrent misses of a week share one read and decode
+	v, err, _ := coldLoads.Do(name, func() (any, error) {
+		return loadUncached(ctx, name)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.([]GameStats), nil
+}
+
+// coldLoads coalesces the store reads of loadGameStats by file name
+var coldLoads singleflight.Group
+
+// loadUncached reads name from the store and caches it
+func loadUncached(ctx context.Context, name string) ([]GameStats, error) {
+	evicted := cache.evicted(name)
+	_, span := startChildSpan(ctx, "store.read", attribute.String("week.file", name))
+	// The read is shared by every caller waiting on name, so it must
+	// outlive the request that started it
+	gameList, size, hash, err := readGameStatsContext(context.WithoutCancel(ctx), name)
+	span.SetAttributes(attribute.Int("store.bytes", size))
+	endSpan(span, err)
+	if errors.Is(err, fs.ErrNotExist) {
+		markMissing(name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// A week evicted for space comes back as it was; anything else may
+	// have changed
+	if evicted {
+		cache.set(name, cacheEntry{games: gameList, size: size, hash: hash, loadedAt: clock.Now()})
+	} else {
+		setCached(name, gameList, size, hash)
+	}
+	return gameList, nil
+}
+
+// catalogGames returns the games of a known week for the views built from
+// the whole cache, reading an evicted week back from the store. Unlike
+// loadGameStats it ignores the TTL and invalidates nothing, so it can be
+// called under the locks of those views.
+func catalogGames(name string) ([]GameStats, bool) {
+	if entry, ok := cache.peek(name); ok {
+		return entry.games, true
+	}
+	games, size, hash, err := readGameStats(name)
+	if err != nil {
+		return nil, false
+	}
+	cache.set(name, cacheEntry{games: games, size: size, hash: hash, loadedAt: clock.Now()})
+	return games, true
+}
+
+// setCached stores games, decoded from size bytes hashing to hash, as the
+// cache entry for name
+func setCached(name string, games []GameStats, size int, hash string) {
+	existed := cache.set(name, cacheEntry{games: games, size: size, hash: hash, loadedAt: clock.Now()})
+
+	forgetMissing(name)
+	responses.invalidate(name)
+	invalidateTopGames()
+	if !existed {
+		invalidateSeasonResponses(name)
+	}
+	invalidateQuantiles()
+	signalPublished(name)
+}
+
+// cacheHash returns the hash of the cached week file name, "" if it is
+// not cached
+func cacheHash(name string) string {
+	entry, _ := cache.peek(name)
+	return entry.hash
+}
+
+// cacheLoadedAt returns when the week file name was loaded into the cache,
+// or the zero time if it is not cached
+func cacheLoadedAt(name string) time.Time {
+	entry, _ := cache.peek(name)
+	return entry.loadedAt
+}
+
+// readGameStats reads and decodes a week file from the store, bypassing
+// the cache. size is the length of the file in bytes and hash its hex
+// SHA-256.
+func readGameStats(name string) (games []GameStats, size int, hash string, err error) {
+	return readGameStatsContext(context.Background(), name)
+}
+
+// readGameStatsContext is readGameStats with the store read bound to ctx
+func readGameStatsContext(ctx context.Context, name string) (games []GameStats, size int, hash string, err error) {
+	data, err := store.ReadFile(ctx, dataStore, name)
+	if err != nil {
+		return nil, 0, "", err
+	}
+
+	games, err = decodeWeekFile(name, data)
+	if err != nil {
+		return nil, len(data), "", err
+	}
+	checked := validations.check(name, games)
+	// A version validation rejects games of is not one to serve with ?asOf=
+	if len(checked) == len(games) {
+		recordSnapshot(name, data)
+	}
+	return checked, len(data), sha256Hex(data), nil
+}
+
+// preloadWorkers is the number of week files preloadCache reads at once,
+// PRELOAD_WORKERS
+var preloadWorkers = 8
+
+// preloadCache loads all available data files at startup, preloadWorkers
+// at a time, and reports its progress to /readyz
+func preloadCache(s store.Store) {
+	names, err := s.ListFiles()
+	if err != nil {
+		log.Printf("Warning: could not list data files: %v", err)
+		return
+	}
+	readiness.start(len(names))
+
+	queue := make(chan string)
+	var count atomic.Int64
+	var wg sync.WaitGroup
+	for range max(1, min(preloadWorkers, len(names))) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range queue {
+				if games, err := loadGameStats(name); err == nil {
+					indexFile(name, games)
+					count.Add(1)
+				}
+				readiness.fileRead()
+			}
+		}()
+	}
+	for _, name := range names {
+		queue <- name
+	}
+	close(queue)
+	wg.Wait()
+	log.Printf("Preloaded %d data files into cache", count.Load())
+
+	// Warm the all-time top list, the most requested view, and the season
+	// distributions behind ?normalize=
+	topGames(raters[defaultAlgorithm], "")
+	warmNormalization()
+}
+
+// ProcessedGameStats is the response structure for /games/:year/:week
+type ProcessedGameStats struct {
+	ID                string  `json:"id"`
+	Season            string  `json:"season,omitempty"`
+	Week              string  `json:"week,omitempty"`
+	WeekLabel         string  `json:"weekLabel,omitempty"`
+	Slug              string  `json:"slug,omitempty"`
+	FullName          string  `json:"fullName"`
+	ShortName         string  `json:"shortName"`
+	HomeTeam          *Team   `json:"homeTeam,omitempty"`
+	AwayTeam          *Team   `json:"awayTeam,omitempty"`
+	MatchupQuality    string  `json:"matchupQuality"`
+	OffensiveRating   float64 `json:"offensiveRating" unit:"score" range:"0-6.5" better:"higher" desc:"Points awarded for offensive production"`
+	DefensiveBigPlays float64 `json:"defensiveBigPlays" unit:"score" range:"0-7" better:"higher" desc:"Weighted defensive and special teams big plays"`
+	ScenarioRating    float64 `json:"scenarioRating" unit:"score" range:"0-6" better:"higher" desc:"How dramatic the game script was"`
+	TotalRating       float64 `json:"totalRating" unit:"score" range:"2-14" better:"higher" desc:"Overall rewatchability, the sum of the three ratings"`
+	Algorithm         string  `json:"algorithm"`
+	Blowout           bool    `json:"blowout"`
+
+	// NormalizedRating is TotalRating relative to the season, as a
+	// percentile or z-score per Normalization, with ?normalize=
+	NormalizedRating *float64 `json:"normalizedRating,omitempty"`
+	Normalization    string   `json:"normalization,omitempty"`
+
+	// Scores of the raters registered by the extensions package
+	Extensions map[string]float64 `json:"extensions,omitempty"`
+
+	// Conditions are the kickoff and weather, once backfilled
+	Conditions *GameConditions `json:"conditions,omitempty"`
+
+	// CommunityRating is the mean of the votes of the users on the game,
+	// from 1 to 5 stars, once it has some
+	CommunityRating *float64 `json:"communityRating,omitempty" unit:"score" range:"1-5" better:"higher" desc:"Mean of the votes of the users, in stars"`
+	CommunityVotes  int      `json:"communityVotes,omitempty"`
+
+	// stats are the raw stats the ratings were computed from, for sorting
+	stats *GameStats
+}
+
+func computeOffensiveRating(gameStats GameStats) float64 {
+	return ratingConfig.Offense(gameStats).Total()
+}
+
+// processGame computes the ratings of a single game with rater
+func processGame(rater Rater, g GameStats) ProcessedGameStats {
+	b := rater.Rate(g)
+	communityRating, communityVotes := community.rating(g.ID)
+	awayTeam, homeTeam := gameTeams(g.ShortName, g.FullName)
+	return ProcessedGameStats{
+		ID:                g.ID,
+		FullName:          g.FullName,
+		ShortName:         g.ShortName,
+		HomeTeam:          homeTeam,
+		AwayTeam:          awayTeam,
+		MatchupQuality:    g.MatchupQuality,
+		OffensiveRating:   b.OffensiveRating,
+		DefensiveBigPlays: b.DefensiveBigPlays,
+		ScenarioRating:    b.ScenarioRating,
+		TotalRating:       b.TotalRating,
+		Algorithm:         b.Algorithm,
+		Blowout:           isBlowout(g),
+		Extensions:        extensions.Rate(extensionGame{&g}),
+		Conditions:        g.Conditions,
+		CommunityRating:   communityRating,
+		CommunityVotes:    communityVotes,
+		stats:             &g,
+	}
+}
+
+// processGames computes the ratings of every game in gameList with rater
+func processGames(rater Rater, gameList []GameStats) []ProcessedGameStats {
+	// Pre-allocate slice with exact capacity needed
+	processed := make([]ProcessedGameStats, 0, len(gameList))
+	for _, g := range gameList {
+		processed = append(processed, processGame(rater, g))
+	}
+	return processed
+}
+
+// isBlowout reports whether g was decided by at least the configured
+// blowout margin
+func isBlowout(g GameStats) bool {
+	return ratingConfig.IsBlowout(g)
+}
+
+func computeDefensiveBigPlays(gameStats GameStats) float64 {
+	return ratingConfig.Defense(gameStats).Total()
+}
+
+// corsMiddleware lets browsers call the API from origins, or from any
+// origin when empty
+func corsMiddleware(origins []string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(origins) == 0 {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		} else {
+			w.Header().Add("Vary", "Origin")
+			if origin := r.Header.Get("Origin"); slices.Contains(origins, origin) {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+			}
+		}
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, X-API-Key, Idempotency-Key, If-Match, If-None-Match, X-Voter-ID, X-Request-ID")
+		w.Header().Set("Access-Control-Expose-Headers", "X-Request-ID, Link, Deprecation, Sunset")
+
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func handleGamesYearWeek(w http.ResponseWriter, r *http.Request) {
+	year := r.PathValue("year")
+	week := r.PathValue("week")
+
+	query, qerr := parseGameQuery(r.URL.Query())
+	if qerr != nil {
+		writeQueryError(w, r, qerr)
+		return
+	}
+
+	rater, qerr := raterFor(r)
+	if qerr != nil {
+		writeQueryError(w, r, qerr)
+		return
+	}
+	asOf, qerr := parseAsOf(r)
+	if qerr != nil {
+		writeQueryError(w, r, qerr)
+		return
+	}
+	format, qerr := responseFormat(r)
+	if qerr != nil {
+		writeQueryError(w, r, qerr)
+		return
+	}
+	w.Header().Add("Vary", "Accept")
+	// CSV and NDJSON rows are streamed rather than cached
+	rows := format != formatJSON && !isCrawler(r)
+
+	// Crawlers get the summary representation under their own cache policy.
+	// The version is part of the key since /v2/ requests carry no ?algo=.
+	name := store.WeekFile(year, week)
+	key, policy := weekResponseKey(r, rater), "week"
+	if isCrawler(r) {
+		key, policy = "crawler:"+key, "crawler"
+	}
+	// Percentile filters depend on every cached week, so their responses
+	// live in a bucket dropped along with the quantiles
+	bucket := name
+	if query.percentile {
+		bucket, key = quantileResponses, name+"|"+key
+	}
+	if resp, ok := responses.get(bucket, key); ok && asOf.IsZero() && !rows {
+		setLinkHeader(w, r, weekLinks(r, year, week))
+		writeCachedResponse(w, r, resp, policy)
+		return
+	}
+
+	// Past versions come from the snapshots and are not cached
+	var gameList []GameStats
+	var servedAt time.Time
+	var err error
+	if asOf.IsZero() {
+		gameList, err = loadGameStatsContext(r.Context(), name)
+		servedAt = cacheLoadedAt(name)
+	} else {
+		gameList, servedAt, err = snapshotGames(name, asOf)
+	}
+	if errors.Is(err, fs.ErrNotExist) && !asOf.IsZero() {
+		writeError(w, r, http.StatusNotFound, "no data for week "+week+" of "+year+" as of "+asOf.UTC().Format(time.RFC3339))
+		return
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		setCacheHeaders(w, "missing")
+		writeError(w, r, http.StatusNotFound, "no data for week "+week+" of "+year)
+		return
+	}
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "error reading data")
+		return
+	}
+
+	processed := rateGames(r.Context(), rater, gameList)
+	for i := range processed {
+		processed[i].setLocation(year, week)
+	}
+
+	// Sorted by OffensiveRating descending unless the query says otherwise
+	processed, reports := query.explain(processed)
+	if rows {
+		setLinkHeader(w, r, weekLinks(r, year, week))
+		if err := writeGameRows(w, r, format, policy, processed); err != nil {
+			log.Printf("Warning: streaming %s: %v", r.URL.RequestURI(), err)
+		}
+		return
+	}
+
+	var body any = processed
+	switch {
+	case isCrawler(r):
+		body = crawlerSummary(processed)
+	case isSpoilerFree(r):
+		body = spoilerFreeGames(processed)
+	}
+	switch {
+	case isCrawler(r):
+	case isExplainFilters(r) && isLinks(r):
+		links := weekLinks(r, year, week)
+		body = Explained{Games: body, Matched: len(processed), Filters: reports, Links: &links}
+	case isExplainFilters(r):
+		body = Explained{Games: body, Matched: len(processed), Filters: reports}
+	case isLinks(r):
+		body = Linked{Games: body, Links: weekLinks(r, year, week)}
+	}
+
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "error encoding response")
+		return
+	}
+	encoded = append(encoded, '\n')
+	resp := cachedResponse{body: encoded, etag: etagFor(encoded), lastModified: servedAt}
+	if asOf.IsZero() {
+		resp = responses.put(bucket, key, encoded, servedAt)
+	}
+	setLinkHeader(w, r, weekLinks(r, year, week))
+	writeCachedResponse(w, r, resp, policy)
+}
+
+// writeCachedResponse writes a pre-encoded JSON response under a cache
+// policy, answering 304 when the client already holds it
+func writeCachedResponse(w http.ResponseWriter, r *http.Request, resp cachedResponse, policy string) {
+	w.Header().Set("Content-Type", "application/json")
+	setCacheHeaders(w, policy)
+	if notModified(w, r, resp.etag, resp.lastModified) {
+		return
+	}
+	w.Write(resp.body)
+}
+
+// handleGameByID returns the full raw GameStats for a single game of a week
+func handleGameByID(w http.ResponseWriter, r *http.Request) {
+	year := r.PathValue("year")
+	week := r.PathValue("week")
+	id := r.PathValue("id")
+
+	g, ok := lookupGame(w, r, year, week, id)
+	if !ok {
+		return
+	}
+
+	var body any = g
+	if isSpoilerFree(r) {
+		stats, err := spoilerFreeStats(g)
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, "error encoding response")
+			return
+		}
+		body = stats
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	setCacheHeaders(w, "game")
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		writeError(w, r, http.StatusInternalServerError, "error encoding response")
+	}
+}
+
+// seasonWeek holds the games of one week of a season
+type seasonWeek struct {
+	Week  string
+	Games []GameStats
+}
+
+// loadSeason loads the consecutive weeks of a season, stopping at the
+// first missing week, then the playoff rounds played so far. Seasons of
+// 17 weeks end their regular season at the missing week 18.
+func loadSeason(ctx context.Context, year string) []seasonWeek {
+	weeks := make([]seasonWeek, 0, regularSeasonWeeks+len(postseasonWeeks))
+
+	load := func(names []string) {
+		for _, week := range names {
+			gameList, err := loadGameStatsContext(ctx, store.WeekFile(year, week))
+			if errors.Is(err, fs.ErrNotExist) {
+				// Stop if a week is missing
+				break
+			}
+			if err != nil {
+				continue
+			}
+			weeks = append(weeks, seasonWeek{Week: week, Games: gameList})
+		}
+	}
+	names := seasonWeekNames()
+	load(names[:regularSeasonWeeks])
+	if len(weeks) > 0 {
+		load(postseasonWeeks)
+	}
+	return weeks
+}
+
+// rawSeasonDeprecated is when /seasons/{year}/games replaced the raw
+// season dump
+var rawSeasonDeprecated = time.Date(2026, time.October, 17, 0, 0, 0, 0, time.UTC)
+
+// handleGamesYear serves the raw full-season dump. It is deprecated in
+// favour of /seasons/{year}/games and only answers when ?raw=true is
+// given; other requests are redirected to the successor endpoint.
+func handleGamesYear(w http.ResponseWriter, r *http.Request) {
+	year := r.PathValue("year")
+
+	successor := versionedPath(r, "/seasons/"+year+"/games")
+	w.Header().Set("Deprecation", deprecationDate(rawSeasonDeprecated))
+	w.Header().Set("Link", "<"+successor+">; rel=\"successor-version\"")
+
+	if r.URL.Query().Get("raw") != "true" {
+		if r.URL.RawQuery != "" {
+			successor += "?" + r.URL.RawQuery
+		}
+		http.Redirect(w, r, successor, http.StatusPermanentRedirect)
+		return
+	}
+
+	page, paginated, qerr := parsePagination(r.URL.Query())
+	if qerr != nil {
+		writeQueryError(w, r, qerr)
+		return
+	}
+
+	// Pre-allocate with estimated capacity (18 weeks * ~16 games, plus
+	// the 13 playoff games)
+	allGameStats := make([]GameStats, 0, 301)
+	for _, sw := range loadSeason(r.Context(), year) {
+		allGameStats = append(allGameStats, sw.Games...)
+	}
+
+	var body any = allGameStats
+	if paginated {
+		body = paginate(allGameStats, page)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	setCacheHeaders(w, "raw")
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		writeError(w, r, http.StatusInternalServerError, "error encoding response")
+	}
+}
+
+// runServe runs the server until SIGTERM, the default command
+func runServe(args []string) error {
+	fset := flag.NewFlagSet("serve", flag.ContinueOnError)
+	configPath := fset.String("config", os.Getenv("CONFIG_FILE"), "YAML file of the settings, $CONFIG_FILE by default")
+	registerConfigFlags(fset)
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+	cfg, err := resolveSettings(*configPath, fset)
+	if err != nil {
+		return err
+	}
+	if err := validateConfig(cfg); err != nil {
+		return err
+	}
+
+	// Keep the last log lines for /admin/logs
+	log.SetOutput(io.MultiWriter(os.Stderr, recentLogs))
+
+	// Replicas serve the published snapshots only, without a store
+	if u := cfg.Replica.URL; u != "" {
+		return runReplica(cfg, u)
+	}
+
+	// SIGTERM from a rollout drains in-flight requests before exiting
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	drain := shutdownTimeout(cfg)
+
+	srv := &server{mux: newMux(), port: listenPort(cfg)}
+	components = srv.components(cfg, drain)
+	if err := components.start(ctx); err != nil {
+		var replica errServeReplica
+		if errors.As(err, &replica) {
+			return runReplica(cfg, replica.url)
+		}
+		return err
+	}
+
+	fmt.Printf("Server listening on :%s\n", srv.port)
+	err = serve(ctx, newHTTPServer(":"+srv.port, srv.handler), srv.ln, drain)
+	stopCtx, cancel := context.WithTimeout(context.Background(), drain)
+	defer cancel()
+	if err := components.stop(stopCtx); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	if err != nil {
+		return err
+	}
+	log.Printf("Server stopped")
+	return nil
+}
+
+// newMux builds the routes of the server
+func newMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", handleIndex)
+	mux.Handle("GET /games", withCost(seasonRangeCost, withProfile(http.HandlerFunc(handleGamesRange))))
+	mux.Handle("GET /games/top", withProfile(http.HandlerFunc(handleTopGames)))
+	mux.Handle("GET /games/{year}/top", withProfile(http.HandlerFunc(handleTopGames)))
+	mux.Handle("GET /games/{year}/{week}", withProfile(http.HandlerFunc(handleGamesYearWeek)))
+	mux.Handle("POST /games/{year}/{week}", requireRole(roleAdmin, withIdempotency(http.HandlerFunc(handleIngestWeek))))
+	mux.HandleFunc("GET /games/{year}/{week}/status", handleWeekStatus)
+	mux.HandleFunc("GET /games/{year}/{week}/wait", handleWeekWait)
+	mux.HandleFunc("GET /games/{year}/{week}/{id}", handleGameByID)
+	mux.HandleFunc("GET /games/{year}/{week}/{id}/rating", handleGameRating)
+	mux.HandleFunc("GET /games/{year}", handleGamesYear)
+	mux.Handle("POST /games/{id}/votes", requireRole(roleRead, http.HandlerFunc(handleGameVote)))
+	mux.HandleFunc("GET /seasons", handleSeasons)
+	mux.Handle("GET /seasons/{year}/games", withCost(fixedCost(1), withProfile(http.HandlerFunc(handleSeasonGames))))
+	mux.HandleFunc("GET /teams", handleTeams)
+	mux.Handle("GET /teams/{team}/games", withProfile(http.HandlerFunc(handleTeamGames)))
+	mux.HandleFunc("GET /teams/{team}/summary/{year}", handleTeamSeasonSummary)
+	mux.HandleFunc("GET /matchups/{teamA}/{teamB}", handleMatchup)
+	mux.Handle("GET /search", withProfile(http.HandlerFunc(handleSearch)))
+	mux.HandleFunc("GET /leagues", handleLeagues)
+	mux.Handle("GET /leagues/{league}/games/{year}/{week}", withProfile(http.HandlerFunc(handleLeagueWeek)))
+	mux.HandleFunc("GET /g/{slug}", handleSlug)
+	mux.Handle("GET /bulk/{year}", withCost(fixedCost(1), http.HandlerFunc(handleBulkYear)))
+	mux.HandleFunc("GET /robots.txt", handleRobots)
+	mux.HandleFunc("GET /readyz", handleReadyz)
+	mux.HandleFunc("GET /feed.rss", handleFeed("rss"))
+	mux.HandleFunc("GET /feed.atom", handleFeed("atom"))
+	mux.HandleFunc("GET /calendar.ics", handleCalendar)
+	mux.HandleFunc("GET /corrections.json", handleCorrections("json"))
+	mux.HandleFunc("GET /corrections.rss", handleCorrections("rss"))
+	mux.HandleFunc("GET /live", handleLive)
+	mux.Handle("POST /plan", withCost(fixedCost(1), http.HandlerFunc(handlePlan)))
+	mux.HandleFunc("GET /meta/fields", handleFieldsMeta)
+	mux.HandleFunc("GET /meta/query-syntax", handleQuerySyntax)
+	mux.HandleFunc("GET /meta/idmap/{id}", handleIDMap)
+	mux.Handle("GET /graphql", withCost(fixedCost(graphqlQueryCost), http.HandlerFunc(handleGraphQL)))
+	mux.HandleFunc("GET /openapi.json", handleOpenAPI)
+	mux.HandleFunc("GET /docs", handleDocs)
+	mux.Handle("POST /graphql", withCost(fixedCost(graphqlQueryCost), http.HandlerFunc(handleGraphQL)))
+	mux.HandleFunc("GET /events", handleEvents)
+	mux.HandleFunc("GET /votes/{year}", handleVoteStandings)
+	mux.Handle("POST /votes/{year}", requireRole(roleRead, http.HandlerFunc(handleVote)))
+	mux.Handle("GET /profile", requireRole(roleRead, http.HandlerFunc(handleGetProfile)))
+	mux.Handle("PUT /profile", requireRole(roleRead, http.HandlerFunc(handlePutProfile)))
+	mux.Handle("POST /admin/votes/{year}", requireRole(roleAdmin, withIdempotency(http.HandlerFunc(handleOpenVoting))))
+	mux.Handle("POST /admin/votes/{year}/close", requireRole(roleAdmin, withIdempotency(http.HandlerFunc(handleCloseVoting))))
+	mux.Handle("GET /admin/consistency", requireRole(roleAdmin, http.HandlerFunc(handleConsistency)))
+	mux.Handle("GET /admin/validate", requireRole(roleAdmin, http.HandlerFunc(handleValidationReport)))
+	mux.Handle("GET /admin/backfill", requireRole(roleAdmin, http.HandlerFunc(handleBackfillStatus)))
+	mux.Handle("POST /admin/backfill", requireRole(roleAdmin, withIdempotency(http.HandlerFunc(handleStartBackfill))))
+	mux.Handle("GET /admin/cache", requireRole(roleAdmin, http.HandlerFunc(handleCacheStats)))
+	mux.Handle("POST /admin/cache/purge", requireRole(roleAdmin, withIdempotency(http.HandlerFunc(handleCachePurge))))
+	mux.Handle("GET /admin/digest/preview", requireRole(roleAdmin, http.HandlerFunc(handleDigestPreview)))
+	mux.Handle("GET /admin/webhooks", requireRole(roleAdmin, http.HandlerFunc(handleListWebhooks)))
+	mux.Handle("POST /admin/webhooks", requireRole(roleAdmin, withIdempotency(http.HandlerFunc(handleRegisterWebhook))))
+	mux.Handle("DELETE /admin/webhooks/{id}", requireRole(roleAdmin, http.HandlerFunc(handleDeleteWebhook)))
+	mux.Handle("GET /admin/logs", requireRole(roleAdmin, http.HandlerFunc(handleRecentLogs)))
+	mux.Handle("GET /admin/vars", requireRole(roleAdmin, expvar.Handler()))
+	mux.Handle("POST /admin/refresh", requireRole(roleAdmin, withIdempotency(http.HandlerFunc(handleRefresh))))
+
+	// Routes contributed by downstream forks
+	for _, route := range extensions.Routes() {
+		mux.Handle(route.Pattern, route.Handler)
+	}
+	mux.Handle("/", fallbackHandler(mux))
+
+	// /v1/... serves the routes above as the current version of the API,
+	// of which the unprefixed routes are deprecated aliases. A later
+	// version changing the response shapes mounts its own routes next to
+	// it, in place of the algorithm prefix of the same name.
+	mux.Handle("/"+currentAPIVersion+"/", withAPIVersion(currentAPIVersion, mux))
+
+	// /v2/games/... serves the same routes rated with that algorithm, a
+	// deprecated alias of /v1/games/...?algo=v2
+	for _, version := range algorithmNames() {
+		if version != currentAPIVersion {
+			mux.Handle("GET /"+version+"/", withRater(raters[version], mux))
+		}
+	}
+	return mux
+}
+
+// errServeReplica stops the startup of a server whose data directory is
+// missing in DATA_DIR_MODE=proxy, to serve the bucket at url instead
+type errServeReplica struct {
+	url string
+}
+
+func (e errServeReplica) Error() string {
+	return "serving the snapshots of " + e.url + " as a read replica"
+}
+
+// server is the state its components share while starting
+type server struct {
+	mux     *http.ServeMux
+	handler http.Handler
+	port    string
+	tls     *tlsSetup
+	ln      net.Listener
+}
+
+// components lists the subsystems of the server with their dependencies.
+// Background jobs run on the context of their start until they are
+// stopped.
+func (srv *server) components(cfg Config, drain time.Duration) *lifecycle {
+	l := newLifecycle()
+
+	var shutdownTracing func(context.Context) error
+	l.add(&component{
+		name: "tracing",
+		start: func(ctx context.Context) (err error) {
+			shutdownTracing, err = startTracing(ctx, cfg)
+			return err
+		},
+		stop: func(ctx context.Context) error {
+			ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			defer cancel()
+			if err := shutdownTracing(ctx); err != nil {
+				return fmt.Errorf("flushing traces: %w", err)
+			}
+			return nil
+		},
+	})
+
+	l.add(&component{name: "cache", start: func(context.Context) error {
+		c, err := cacheFromConfig(cfg)
+		if err != nil {
+			return err
+		}
+		cache = c
+		if ttl := cfg.Cache.TTL; ttl != 0 {
+			cacheTTL = ttl
+		}
+		if n := cfg.Store.PreloadWorkers; n != 0 {
+			preloadWorkers = n
+		}
+		if path := cfg.Cache.PolicyConfig; path != "" {
+			if err := loadCachePolicies(path); err != nil {
+				return fmt.Errorf("load cache policies: %w", err)
+			}
+		}
+		return nil
+	}})
+
+	l.add(&component{name: "ratings", start: func(context.Context) error {
+		rc, ok, err := ratingConfigFromConfig(cfg)
+		if err != nil {
+			return fmt.Errorf("load rating config: %w", err)
+		}
+		if ok {
+			ratingConfig = rc
+			log.Printf("Loaded custom rating config")
+		}
+		if path := cfg.Ratings.LeaguesConfig; path != "" {
+			if err := loadLeagues(path); err != nil {
+				return fmt.Errorf("load leagues: %w", err)
+			}
+			log.Printf("Leagues: %s", strings.Join(leagueNames(), ", "))
+		}
+		if names := extensions.RaterNames(); len(names) > 0 {
+			log.Printf("Extension raters: %s", strings.Join(names, ", "))
+		}
+		b, err := computeBudgetFromConfig(cfg)
+		if err != nil {
+			return err
+		}
+		setComputeBudget(b)
+		return nil
+	}})
+
+	l.add(&component{name: "store", start: func(context.Context) error {
+		s, err := openStore(cfg)
+		if err != nil {
+			return fmt.Errorf("open store: %w", err)
+		}
+		// A missing or read-only data directory is handled per DATA_DIR_MODE
+		if ds, ok := s.(*store.Dir); ok {
+			resolved, upstream, err := resolveDataDir(ds, cfg)
+			if err != nil {
+				return err
+			}
+			if upstream != "" {
+				return errServeReplica{url: upstream}
+			}
+			s = resolved
+		}
+		dataStore = s
+		if err := loadTeams(s); err != nil {
+			return fmt.Errorf("load teams: %w", err)
+		}
+
+		mode, err := parseValidationMode(cfg.Store.ValidationMode)
+		if err != nil {
+			return err
+		}
+		validations.setMode(mode)
+
+		// Record the served versions of the week files from the preload on
+		if dir := cfg.Store.SnapshotDir; dir != "" {
+			snapshots = newSnapshotStore(dir)
+		}
+		if path := cfg.Store.IDMapPath; path != "" {
+			index, err := loadIDMappings(path)
+			if err != nil {
+				return fmt.Errorf("load ID mappings: %w", err)
+			}
+			setIDMappings(index)
+		}
+		return nil
+	}})
+
+	// Pick up edited week files without a restart
+	var watcher io.Closer
+	l.add(&component{
+		name: "watcher",
+		deps: []string{"store", "cache"},
+		start: func(context.Context) error {
+			root, ok := localRoot(dataStore)
+			if !ok {
+				return nil
+			}
+			w, err := watchDataDir(root)
+			if err != nil {
+				log.Printf("Warning: hot reload disabled: %v", err)
+				return nil
+			}
+			watcher = w
+			return nil
+		},
+		stop: func(context.Context) error {
+			if watcher == nil {
+				return nil
+			}
+			return watcher.Close()
+		},
+	})
+
+	// Reload the active season as its weeks are played, for the stores
+	// that cannot be watched
+	l.add(&component{
+		name: "refresher",
+		deps: []string{"store", "cache"},
+		start: func(ctx context.Context) error {
+			if err := startRefresher(ctx, dataStore, cfg.Cache.RefreshInterval); err != nil {
+				return fmt.Errorf("start the cache refresher: %w", err)
+			}
+			return nil
+		},
+	})
+
+	var repo Repository
+	l.add(&component{
+		name: "userdata",
+		start: func(context.Context) (err error) {
+			if repo, err = openRepository(cfg); err != nil {
+				return fmt.Errorf("open the user data: %w", err)
+			}
+			if votes, err = loadVoteBook(repo); err != nil {
+				return fmt.Errorf("load votes: %w", err)
+			}
+			if community, err = loadCommunityBook(repo); err != nil {
+				return fmt.Errorf("load community votes: %w", err)
+			}
+			if webhooks, err = loadWebhookRegistry(repo); err != nil {
+				return fmt.Errorf("load webhooks: %w", err)
+			}
+			if profiles, err = loadProfileBook(repo); err != nil {
+				return fmt.Errorf("load profiles: %w", err)
+			}
+			return nil
+		},
+		stop: func(context.Context) error { return repo.Close() },
+	})
+
+	l.add(&component{name: "notifiers", start: func(context.Context) error {
+		feedSiteURL = strings.TrimSuffix(cfg.Server.FeedSiteURL, "/")
+		calendarStreamURL = cfg.Server.CalendarStreamURL
+		if path := cfg.Ingest.NotifiersConfig; path != "" {
+			n, err := loadNotifiers(path)
+			if err != nil {
+				return fmt.Errorf("load notifiers: %w", err)
+			}
+			notifiers = n
+			log.Printf("Loaded %d notification channels", len(notifiers))
+		}
+		return nil
+	}})
+
+	l.add(&component{name: "auth", start: func(context.Context) error {
+		path := cfg.Auth.APIKeysConfig
+		if path == "" {
+			log.Printf("Warning: API_KEYS_CONFIG is not set, admin routes are disabled")
+			return nil
+		}
+		keys, err := loadAPIKeys(path)
+		if err != nil {
+			return fmt.Errorf("load API keys: %w", err)
+		}
+		apiKeys = keys
+		log.Printf("Loaded %d API keys", len(apiKeys))
+		return nil
+	}})
+
+	// Mirror the routes to a bucket after each ingestion
+	l.add(&component{name: "publisher", deps: []string{"store"}, start: func(context.Context) error {
+		u := cfg.Publish.URL
+		if u == "" {
+			return nil
+		}
+		creds, err := objectCredentials("PUBLISH", cfg.publishAuth())
+		if err != nil {
+			return err
+		}
+		snapshotPublisher = newPublisher(store.NewObject(u, creds), srv.mux)
+		log.Printf("Publishing snapshots to %s", u)
+		return nil
+	}})
+
+	l.add(&component{name: "handler", start: func(context.Context) error {
+		if list := cfg.Server.PrefetchHints; list != nil {
+			hints, err := parsePrefetchHints(list)
+			if err != nil {
+				return err
+			}
+			prefetchHints = hints
+		}
+		proxies, err := parseTrustedProxies(cfg.Server.TrustedProxies)
+		if err != nil {
+			return err
+		}
+		trustedProxies = proxies
+
+		// Chain middlewares: Request ID -> Tracing -> Access log -> Recovery -> CORS -> Load shedding -> Bot throttle -> Compression -> Deprecation -> Handler
+		handler := botMiddleware(compressMiddleware(deprecationMiddleware(srv.mux)))
+		if path := cfg.RateLimits.LoadSheddingConfig; path != "" {
+			lc, err := loadLoadSheddingConfig(path)
+			if err != nil {
+				return fmt.Errorf("load load shedding config: %w", err)
+			}
+			if lc.MaxInFlight > 0 {
+				handler = loadSheddingMiddleware(newLoadShedder(lc), srv.mux, handler)
+				log.Printf("Load shedding above %d requests in flight", lc.MaxInFlight)
+			}
+		}
+		origins, err := parseCORSOrigins(cfg.Server.CORSOrigins)
+		if err != nil {
+			return err
+		}
+		handler = corsMiddleware(origins, handler)
+
+		// Outermost, the request ID and the access log see every request
+		accessLog, err := newAccessLogger(cfg.Server.AccessLog, os.Stderr)
+		if err != nil {
+			return err
+		}
+		srv.handler = requestIDMiddleware(tracingMiddleware(srv.mux, accessLogMiddleware(accessLog, recoverMiddleware(handler))))
+		return nil
+	}})
+
+	l.add(&component{
+		name: "fetcher",
+		deps: []string{"store", "cache", "ratings", "userdata", "notifiers", "publisher"},
+		start: func(ctx context.Context) error {
+			if err := startFetcher(ctx, cfg); err != nil {
+				return fmt.Errorf("start ESPN fetcher: %w", err)
+			}
+			return nil
+		},
+	})
+	l.add(&component{
+		name: "live",
+		deps: []string{"ratings"},
+		start: func(ctx context.Context) error {
+			if err := startLive(ctx, cfg); err != nil {
+				return fmt.Errorf("start live mode: %w", err)
+			}
+			return nil
+		},
+	})
+
+	// The listeners open last, once everything they serve is up
+	l.add(&component{
+		name: "listener",
+		deps: []string{"tracing", "handler", "auth", "watcher"},
+		start: func(ctx context.Context) (err error) {
+			if srv.tls, err = tlsFromConfig(cfg); err != nil {
+				return fmt.Errorf("set up TLS: %w", err)
+			}
+			if srv.ln, err = srv.tls.listen(srv.port); err != nil {
+				return err
+			}
+			return srv.tls.serveHTTP(ctx, drain)
+		},
+	})
+
+	// Preload all data files into cache while the server listens, /readyz
+	// holding load balancers off until it is done
+	l.add(&component{
+		name: "preload",
+		deps: []string{"listener", "store", "cache"},
+		start: func(context.Context) error {
+			go func() {
+				preloadCache(dataStore)
+				logConsistency(dataStore)
+				readiness.markReady()
+			}()
+			return nil
+		},
+		health: func() error {
+			if !readiness.ready.Load() {
+				return errors.New("preloading")
+			}
+			return nil
+		},
+	})
+	return l
+}
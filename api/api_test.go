@@ -1,12 +1,18 @@
-package main
+package api
 
 import (
+	"errors"
+	"io/fs"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"sync"
 	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/jjway/rewatchableGamesApi-go/store"
 )
 
 var testData = `[
@@ -66,22 +72,37 @@ func setupTestData(t *testing.T) string {
 	return tmpDir
 }
 
+// useTestStore points the store at dir and clears the caches for the test
+func useTestStore(t *testing.T, dir string) {
+	t.Helper()
+
+	cache = newWeekCache(0, 0, "")
+	responses = newResponseCache()
+	invalidateQuantiles()
+	invalidateTopGames()
+	missingFilesMu.Lock()
+	missingFiles = make(map[string]time.Time)
+	missingFilesMu.Unlock()
+
+	oldStore := dataStore
+	dataStore = store.NewDir(dir)
+	t.Cleanup(func() { dataStore = oldStore })
+}
+
 func TestHandleGamesYearWeek(t *testing.T) {
 	// Clear cache before test
-	cacheMu.Lock()
-	cache = make(map[string][]GameStats)
-	cacheMu.Unlock()
+	cache = newWeekCache(0, 0, "")
 
 	tmpDir := setupTestData(t)
+	useTestStore(t, tmpDir)
 
 	// Create a request handler that uses our temp data directory
 	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		year := r.PathValue("year")
 		week := r.PathValue("week")
-		path := filepath.Join(tmpDir, year, week+".json")
 
-		gameList, err := loadGameStats(path)
-		if os.IsNotExist(err) {
+		gameList, err := loadGameStats(store.WeekFile(year, week))
+		if errors.Is(err, fs.ErrNotExist) {
 			w.WriteHeader(http.StatusNotFound)
 			w.Write([]byte("No data"))
 			return
@@ -144,25 +165,18 @@ func TestHandleGamesYearWeek(t *testing.T) {
 
 func TestHandleGamesYear(t *testing.T) {
 	// Clear cache before test
-	cacheMu.Lock()
-	cache = make(map[string][]GameStats)
-	cacheMu.Unlock()
+	cache = newWeekCache(0, 0, "")
 
 	tmpDir := setupTestData(t)
+	useTestStore(t, tmpDir)
 
 	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		year := r.PathValue("year")
 		allGameStats := make([]GameStats, 0, 288)
 
 		for week := 1; week <= 18; week++ {
-			path := filepath.Join(tmpDir, year, string(rune('0'+week))+".json")
-			if week >= 10 {
-				path = filepath.Join(tmpDir, year, string(rune('0'+week/10))+string(rune('0'+week%10))+".json")
-			}
-			path = filepath.Join(tmpDir, year, itoa(week)+".json")
-
-			gameList, err := loadGameStats(path)
-			if os.IsNotExist(err) {
+			gameList, err := loadGameStats(store.WeekFile(year, itoa(week)))
+			if errors.Is(err, fs.ErrNotExist) {
 				break
 			}
 			if err != nil {
@@ -206,9 +220,7 @@ func itoa(n int) string {
 
 func TestCachePreventsDuplicateFileReads(t *testing.T) {
 	// Clear cache before test
-	cacheMu.Lock()
-	cache = make(map[string][]GameStats)
-	cacheMu.Unlock()
+	cache = newWeekCache(0, 0, "")
 
 	tmpDir := t.TempDir()
 	yearDir := filepath.Join(tmpDir, "2024")
@@ -216,7 +228,9 @@ func TestCachePreventsDuplicateFileReads(t *testing.T) {
 		t.Fatalf("failed to create test directory: %v", err)
 	}
 
+	useTestStore(t, tmpDir)
 	testFile := filepath.Join(yearDir, "1.json")
+	testName := store.WeekFile("2024", "1")
 	if err := os.WriteFile(testFile, []byte(testData), 0644); err != nil {
 		t.Fatalf("failed to write test data: %v", err)
 	}
@@ -225,15 +239,13 @@ func TestCachePreventsDuplicateFileReads(t *testing.T) {
 	var readCount atomic.Int32
 
 	// First read - should hit disk
-	_, err := loadGameStats(testFile)
+	_, err := loadGameStats(testName)
 	if err != nil {
 		t.Fatalf("first load failed: %v", err)
 	}
 
 	// Check cache has the data
-	cacheMu.RLock()
-	_, exists := cache[testFile]
-	cacheMu.RUnlock()
+	_, exists := cache.peek(testName)
 	if !exists {
 		t.Fatal("data should be in cache after first load")
 	}
@@ -246,7 +258,7 @@ func TestCachePreventsDuplicateFileReads(t *testing.T) {
 
 	// These should all succeed using cached data
 	for i := 0; i < 10; i++ {
-		data, err := loadGameStats(testFile)
+		data, err := loadGameStats(testName)
 		if err != nil {
 			t.Fatalf("cached load %d failed: %v", i, err)
 		}
@@ -261,3 +273,99 @@ func TestCachePreventsDuplicateFileReads(t *testing.T) {
 		t.Errorf("expected 10 successful cached reads, got %d", readCount.Load())
 	}
 }
+
+// gatedStore counts the reads of the wrapped store and holds them until
+// release is closed
+type gatedStore struct {
+	store.Store
+	reads   atomic.Int32
+	release chan struct{}
+}
+
+func (s *gatedStore) ReadFile(name string) ([]byte, error) {
+	s.reads.Add(1)
+	<-s.release
+	return s.Store.ReadFile(name)
+}
+
+func TestConcurrentMissesShareOneRead(t *testing.T) {
+	useTestStore(t, setupTestData(t))
+	gated := &gatedStore{Store: dataStore, release: make(chan struct{})}
+	dataStore = gated
+
+	const callers = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			games, err := loadGameStats("2024/1.json")
+			if err == nil && len(games) != 1 {
+				err = errors.New("unexpected games")
+			}
+			errs <- err
+		}()
+	}
+	for gated.reads.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(gated.release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := gated.reads.Load(); n != 1 {
+		t.Errorf("expected the concurrent misses to share 1 read, got %d", n)
+	}
+}
+
+func TestHandleGameByID(t *testing.T) {
+	// Clear cache before test
+	cache = newWeekCache(0, 0, "")
+
+	useTestStore(t, setupTestData(t))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /games/{year}/{week}/{id}", handleGameByID)
+
+	req := httptest.NewRequest("GET", "/games/2024/1/game1", nil)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+
+	var game GameStats
+	if err := json.Unmarshal(rec.Body.Bytes(), &game); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if game.ID != "game1" {
+		t.Errorf("expected game ID 'game1', got '%s'", game.ID)
+	}
+	if game.Defense.Interceptions != 3 {
+		t.Errorf("expected raw defense block with 3 interceptions, got %v", game.Defense.Interceptions)
+	}
+
+	// Unknown ID returns a JSON 404
+	req = httptest.NewRequest("GET", "/games/2024/1/nope", nil)
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", rec.Code)
+	}
+	var body Problem
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("expected JSON error body: %v", err)
+	}
+	if body.Status != http.StatusNotFound || body.Detail == "" {
+		t.Errorf("expected a 404 problem with a detail, got %+v", body)
+	}
+}
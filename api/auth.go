@@ -0,0 +1,111 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+)
+
+// API key roles. admin implies read.
+const (
+	roleRead  = "read"
+	roleAdmin = "admin"
+)
+
+// APIKey is one entry of the API_KEYS_CONFIG file
+type APIKey struct {
+	Name  string   `json:"name"`
+	Key   string   `json:"key"`
+	Roles []string `json:"roles"`
+
+	// ComputeBudget overrides the default compute budget of the key
+	ComputeBudget *Budget `json:"computeBudget,omitempty"`
+}
+
+// hasRole reports whether k was granted role
+func (k APIKey) hasRole(role string) bool {
+	for _, r := range k.Roles {
+		if r == role || r == roleAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// apiKeys maps the SHA-256 of each configured key to its entry, so lookups
+// do not compare secrets byte by byte
+var apiKeys = make(map[[sha256.Size]byte]APIKey)
+
+// loadAPIKeys reads a JSON array of APIKey from path, which may be
+// encrypted and reference its keys as env: or file: secrets
+func loadAPIKeys(path string) (map[[sha256.Size]byte]APIKey, error) {
+	data, err := readConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var list []APIKey
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+
+	keys := make(map[[sha256.Size]byte]APIKey, len(list))
+	for _, k := range list {
+		if err := resolveSecrets(&k.Key); err != nil {
+			return nil, fmt.Errorf("api key %q: %w", k.Name, err)
+		}
+		if k.Key == "" {
+			return nil, fmt.Errorf("api key %q: key is required", k.Name)
+		}
+		for _, role := range k.Roles {
+			if role != roleRead && role != roleAdmin {
+				return nil, fmt.Errorf("api key %q: unknown role %q", k.Name, role)
+			}
+		}
+		if b := k.ComputeBudget; b != nil && (b.Rate <= 0 || b.Burst < 1) {
+			return nil, fmt.Errorf("api key %q: compute budget needs a positive rate and burst", k.Name)
+		}
+		keys[sha256.Sum256([]byte(k.Key))] = k
+	}
+	return keys, nil
+}
+
+// apiKeyContextKey carries the authenticated APIKey of a request
+type apiKeyContextKey struct{}
+
+// requestAPIKey returns the key a request was authenticated with
+func requestAPIKey(r *http.Request) (APIKey, bool) {
+	k, ok := r.Context().Value(apiKeyContextKey{}).(APIKey)
+	return k, ok
+}
+
+// lookupAPIKey returns the configured key matching the X-API-Key header
+func lookupAPIKey(r *http.Request) (APIKey, bool) {
+	header := r.Header.Get("X-API-Key")
+	if header == "" {
+		return APIKey{}, false
+	}
+	k, ok := apiKeys[sha256.Sum256([]byte(header))]
+	return k, ok
+}
+
+// requireRole only lets requests whose X-API-Key was granted role through.
+// Without configured keys every request is rejected.
+func requireRole(role string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-API-Key") == "" {
+			writeError(w, r, http.StatusUnauthorized, "missing X-API-Key header")
+			return
+		}
+		k, ok := lookupAPIKey(r)
+		if !ok {
+			writeError(w, r, http.StatusUnauthorized, "unknown API key")
+			return
+		}
+		if !k.hasRole(role) {
+			writeError(w, r, http.StatusForbidden, "API key lacks the "+role+" role")
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), apiKeyContextKey{}, k)))
+	})
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"crypto/sha256"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// useAPIKeys installs keys for the duration of a test
+func useAPIKeys(t *testing.T, keys ...APIKey) {
+	t.Helper()
+	old := apiKeys
+	apiKeys = make(map[[sha256.Size]byte]APIKey)
+	for _, k := range keys {
+		apiKeys[sha256.Sum256([]byte(k.Key))] = k
+	}
+	t.Cleanup(func() { apiKeys = old })
+}
+
+func TestRequireRole(t *testing.T) {
+	useAPIKeys(t,
+		APIKey{Name: "reader", Key: "r-key", Roles: []string{roleRead}},
+		APIKey{Name: "ops", Key: "a-key", Roles: []string{roleAdmin}},
+	)
+
+	var seen string
+	handler := requireRole(roleAdmin, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		k, _ := requestAPIKey(r)
+		seen = k.Name
+	}))
+
+	for key, want := range map[string]int{
+		"":      http.StatusUnauthorized,
+		"nope":  http.StatusUnauthorized,
+		"r-key": http.StatusForbidden,
+		"a-key": http.StatusOK,
+	} {
+		req := httptest.NewRequest("GET", "/admin/consistency", nil)
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != want {
+			t.Errorf("key %q: expected %d, got %d", key, want, rec.Code)
+		}
+	}
+	if seen != "ops" {
+		t.Errorf("expected the handler to see the ops key, got %q", seen)
+	}
+}
+
+func TestAdminImpliesRead(t *testing.T) {
+	if !(APIKey{Roles: []string{roleAdmin}}).hasRole(roleRead) {
+		t.Error("expected admin keys to have the read role")
+	}
+	if (APIKey{Roles: []string{roleRead}}).hasRole(roleAdmin) {
+		t.Error("expected read keys not to have the admin role")
+	}
+}
+
+func TestLoadAPIKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	os.WriteFile(path, []byte(`[{"name": "ops", "key": "secret", "roles": ["admin"]}]`), 0644)
+
+	keys, err := loadAPIKeys(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if k, ok := keys[sha256.Sum256([]byte("secret"))]; !ok || k.Name != "ops" {
+		t.Errorf("expected the ops key, got %+v", keys)
+	}
+
+	for _, bad := range []string{`[{"name": "x", "roles": ["read"]}]`, `[{"key": "k", "roles": ["root"]}]`} {
+		os.WriteFile(path, []byte(bad), 0644)
+		if _, err := loadAPIKeys(path); err == nil {
+			t.Errorf("expected %s to be rejected", bad)
+		}
+	}
+}
@@ -0,0 +1,548 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jjway/rewatchableGamesApi-go/ratings"
+
+	"github.com/jjway/rewatchableGamesApi-go/store"
+)
+
+// The conditions of a game, added to existing seasons by the backfill job
+type (
+	GameConditions = ratings.GameConditions
+	GameWeather    = ratings.GameWeather
+)
+
+// conditionsProvider looks up the conditions of a game. A nil result
+// without error means the provider does not know the game.
+type conditionsProvider interface {
+	Name() string
+	Conditions(ctx context.Context, year, week string, g GameStats) (*GameConditions, error)
+}
+
+// newConditionsProvider builds the provider of spec: "espn" or empty for
+// the ESPN game summaries, or a URL template where {id}, {year} and {week}
+// are replaced and which returns a GameConditions document
+func newConditionsProvider(spec string) (conditionsProvider, error) {
+	switch {
+	case spec == "" || spec == "espn":
+		return espnConditions{newESPNFetcher(espnAPIURL)}, nil
+	case strings.HasPrefix(spec, "http://") || strings.HasPrefix(spec, "https://"):
+		if !strings.Contains(spec, "{id}") {
+			return nil, fmt.Errorf("conditions provider %q: the URL must contain {id}", spec)
+		}
+		return &templateConditions{url: spec, client: &http.Client{Timeout: 30 * time.Second}}, nil
+	default:
+		return nil, fmt.Errorf("unknown conditions provider %q", spec)
+	}
+}
+
+// espnConditions reads the conditions from the game info of the ESPN
+// summary, whose event ids are the game ids of the data files
+type espnConditions struct {
+	f *espnFetcher
+}
+
+// espnGameInfo is the subset of the game summary holding the conditions
+type espnGameInfo struct {
+	Header struct {
+		Competitions []struct {
+			Date string `json:"date"`
+		} `json:"competitions"`
+	} `json:"header"`
+	GameInfo struct {
+		Venue struct {
+			FullName string `json:"fullName"`
+			Indoor   bool   `json:"indoor"`
+		} `json:"venue"`
+		Weather *struct {
+			DisplayValue string  `json:"displayValue"`
+			Temperature  float64 `json:"temperature"`
+		} `json:"weather"`
+	} `json:"gameInfo"`
+}
+
+func (p espnConditions) Name() string { return "espn" }
+
+func (p espnConditions) Conditions(ctx context.Context, year, week string, g GameStats) (*GameConditions, error) {
+	var info espnGameInfo
+	if err := p.f.getJSON(ctx, "/summary", url.Values{"event": {g.ID}}, &info); err != nil {
+		return nil, err
+	}
+	if len(info.Header.Competitions) == 0 {
+		return nil, nil
+	}
+	kickoff, err := parseESPNTime(info.Header.Competitions[0].Date)
+	if err != nil {
+		return nil, fmt.Errorf("game %s: %w", g.ID, err)
+	}
+	c := &GameConditions{
+		Kickoff: kickoff,
+		Venue:   info.GameInfo.Venue.FullName,
+		Indoor:  info.GameInfo.Venue.Indoor,
+	}
+	if w := info.GameInfo.Weather; w != nil && !c.Indoor {
+		c.Weather = &GameWeather{Condition: w.DisplayValue, TemperatureF: w.Temperature}
+	}
+	return c, nil
+}
+
+// parseESPNTime parses ESPN dates, which usually omit the seconds
+func parseESPNTime(s string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02T15:04Z07:00", s); err == nil {
+		return t.UTC(), nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid kickoff %q", s)
+	}
+	return t.UTC(), nil
+}
+
+// templateConditions reads the conditions from an HTTP provider, one GET
+// per game
+type templateConditions struct {
+	url    string
+	client *http.Client
+}
+
+func (p *templateConditions) Name() string {
+	u, err := url.Parse(p.url)
+	if err != nil {
+		return "http"
+	}
+	return u.Host
+}
+
+func (p *templateConditions) Conditions(ctx context.Context, year, week string, g GameStats) (*GameConditions, error) {
+	u := strings.NewReplacer("{id}", url.PathEscape(g.ID), "{year}", year, "{week}", week).Replace(p.url)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("GET %s: unexpected status %d", u, resp.StatusCode)
+	}
+	var c GameConditions
+	if err := json.NewDecoder(resp.Body).Decode(&c); err != nil {
+		return nil, fmt.Errorf("GET %s: %w", u, err)
+	}
+	if c.Kickoff.IsZero() {
+		return nil, fmt.Errorf("GET %s: no kickoff", u)
+	}
+	return &c, nil
+}
+
+// provenanceFile is the store file recording the backfills of each week
+// file, listed with the files by the support bundle manifest
+const provenanceFile = "provenance.json"
+
+// Provenance records one backfill of a week file
+type Provenance struct {
+	Job      string    `json:"job"`
+	Provider string    `json:"provider"`
+	Games    int       `json:"games"`
+	At       time.Time `json:"at"`
+}
+
+// provenanceMu serializes the read-modify-write of the provenance file
+var provenanceMu sync.Mutex
+
+// loadProvenance reads the provenance of the week files of s, by file
+func loadProvenance(s store.Store) (map[string][]Provenance, error) {
+	data, err := s.ReadFile(provenanceFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return make(map[string][]Provenance), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var p map[string][]Provenance
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("%s: %w", provenanceFile, err)
+	}
+	if p == nil {
+		p = make(map[string][]Provenance)
+	}
+	return p, nil
+}
+
+// recordProvenance appends p to the provenance of the week file name
+func recordProvenance(ws store.WritableStore, name string, p Provenance) error {
+	provenanceMu.Lock()
+	defer provenanceMu.Unlock()
+	all, err := loadProvenance(ws)
+	if err != nil {
+		return err
+	}
+	all[name] = append(all[name], p)
+	data, err := json.MarshalIndent(all, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ws.WriteFile(provenanceFile, data)
+}
+
+// BackfillResult summarizes the backfill of a season
+type BackfillResult struct {
+	Season   string   `json:"season"`
+	Provider string   `json:"provider"`
+	Weeks    int      `json:"weeks"`
+	Games    int      `json:"games"`
+	Skipped  int      `json:"skipped"`
+	Failed   []string `json:"failed,omitempty"`
+}
+
+// backfillSeason adds the conditions of provider to the games of a season
+// missing them, or to every game with force, rewriting the week files
+// that changed. A game the provider fails on is listed and skipped; the
+// season is aborted only when ctx is done.
+func backfillSeason(ctx context.Context, ws store.WritableStore, provider conditionsProvider, year string, force bool) (BackfillResult, error) {
+	result := BackfillResult{Season: year, Provider: provider.Name()}
+	weeks, err := seasonWeekFiles(ws, year)
+	if err != nil {
+		return result, err
+	}
+
+	for _, name := range weeks {
+		week := strings.TrimSuffix(strings.TrimPrefix(name, year+"/"), ".json")
+		games, err := readWeek(ws, name)
+		if err != nil {
+			return result, err
+		}
+
+		// Look the games up outside ingestMu, which would block ingestion
+		// for the whole week
+		found := make(map[string]*GameConditions)
+		for _, g := range games {
+			if g.ID == "" || (g.Conditions != nil && !force) {
+				result.Skipped++
+				continue
+			}
+			c, err := provider.Conditions(ctx, year, week, g)
+			if ctx.Err() != nil {
+				return result, ctx.Err()
+			}
+			if err != nil {
+				log.Printf("Warning: backfill %s game %s: %v", name, g.ID, err)
+				result.Failed = append(result.Failed, g.ID)
+				continue
+			}
+			if c == nil {
+				result.Skipped++
+				continue
+			}
+			found[g.ID] = c
+		}
+		if len(found) == 0 {
+			continue
+		}
+
+		n, err := applyConditions(ws, name, found)
+		if err != nil {
+			return result, fmt.Errorf("%s: %w", name, err)
+		}
+		if n == 0 {
+			continue
+		}
+		result.Weeks++
+		result.Games += n
+		p := Provenance{Job: "conditions", Provider: provider.Name(), Games: n, At: clock.Now().UTC()}
+		if err := recordProvenance(ws, name, p); err != nil {
+			return result, fmt.Errorf("record provenance: %w", err)
+		}
+	}
+	return result, nil
+}
+
+// errNoSeasonData is returned for a season without week files
+var errNoSeasonData = errors.New("no data for season")
+
+// seasonWeekFiles lists the week files of a season in s, in week order
+func seasonWeekFiles(s store.Store, year string) ([]string, error) {
+	names, err := s.ListFiles()
+	if err != nil {
+		return nil, err
+	}
+	var weeks []string
+	for _, name := range names {
+		if store.IsWeekFile(name) && strings.HasPrefix(name, year+"/") {
+			weeks = append(weeks, name)
+		}
+	}
+	if len(weeks) == 0 {
+		return nil, fmt.Errorf("%w %s", errNoSeasonData, year)
+	}
+	sort.Slice(weeks, func(i, j int) bool {
+		wi, _ := weekOrder(strings.TrimSuffix(strings.TrimPrefix(weeks[i], year+"/"), ".json"))
+		wj, _ := weekOrder(strings.TrimSuffix(strings.TrimPrefix(weeks[j], year+"/"), ".json"))
+		return wi < wj
+	})
+	return weeks, nil
+}
+
+// backfillDryRun reports the week files a backfill of the season could
+// rewrite: those with games missing conditions, or every week with force.
+// The provider is not called, so weeks it has nothing for are listed too.
+func backfillDryRun(s store.Store, year string, force bool) (*DryRunReport, error) {
+	weeks, err := seasonWeekFiles(s, year)
+	if err != nil {
+		return nil, err
+	}
+	rep := newDryRunReport("backfill " + year)
+	for _, name := range weeks {
+		games, err := readWeek(s, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, g := range games {
+			if g.ID != "" && (g.Conditions == nil || force) {
+				rep.storeWeek(name)
+				break
+			}
+		}
+	}
+	if len(rep.Writes) > 0 {
+		rep.Writes = append(rep.Writes, provenanceFile)
+	}
+	return rep, nil
+}
+
+// readWeek reads and decodes a week file from s, bypassing the cache
+func readWeek(s store.Store, name string) ([]GameStats, error) {
+	data, err := s.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return decodeWeekFile(name, data)
+}
+
+// applyConditions sets the conditions found by game id on the current
+// content of a week file and stores it, returning how many games changed.
+// The week is re-read under ingestMu so an upload made during the lookups
+// is not overwritten.
+func applyConditions(ws store.WritableStore, name string, found map[string]*GameConditions) (int, error) {
+	ingestMu.Lock()
+	defer ingestMu.Unlock()
+	games, err := readWeek(ws, name)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for i := range games {
+		if c, ok := found[games[i].ID]; ok {
+			games[i].Conditions = c
+			n++
+		}
+	}
+	if n == 0 {
+		return 0, nil
+	}
+	data, err := json.Marshal(games)
+	if err != nil {
+		return 0, err
+	}
+	_, err = storeWeek(ws, name, games, data)
+	return n, err
+}
+
+// BackfillStatus is the state of the last backfill started from the API
+type BackfillStatus struct {
+	State      string          `json:"state"` // running, done or failed
+	Season     string          `json:"season"`
+	Force      bool            `json:"force"`
+	StartedAt  time.Time       `json:"startedAt"`
+	FinishedAt *time.Time      `json:"finishedAt,omitempty"`
+	Result     *BackfillResult `json:"result,omitempty"`
+	Error      string          `json:"error,omitempty"`
+}
+
+// The backfill started by POST /admin/backfill; one runs at a time
+var (
+	backfillMu  sync.Mutex
+	backfillJob *BackfillStatus
+
+	// conditionsProviderSpec is the CONDITIONS_PROVIDER of the admin
+	// backfill, set at startup
+	conditionsProviderSpec string
+
+	// newBackfillProvider builds the provider of the admin backfill,
+	// replaced by tests
+	newBackfillProvider = func() (conditionsProvider, error) {
+		return newConditionsProvider(conditionsProviderSpec)
+	}
+)
+
+// handleStartBackfill starts the backfill of ?year= in the background,
+// with ?force=true to replace conditions already stored. The job can be
+// followed at GET /admin/backfill. ?dryRun=true answers at once with the
+// weeks it could rewrite.
+func handleStartBackfill(w http.ResponseWriter, r *http.Request) {
+	ws, ok := dataStore.(store.WritableStore)
+	if !ok {
+		writeError(w, r, http.StatusNotImplemented, "the configured store is read-only")
+		return
+	}
+	year := r.URL.Query().Get("year")
+	if _, err := strconv.Atoi(year); err != nil {
+		writeQueryError(w, r, &QueryError{Param: "year", Value: year, Message: "must be a season year"})
+		return
+	}
+	force := false
+	if v := r.URL.Query().Get("force"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			writeQueryError(w, r, &QueryError{Param: "force", Value: v, Message: "must be true or false"})
+			return
+		}
+		force = b
+	}
+	dryRun, qerr := dryRunRequested(r)
+	if qerr != nil {
+		writeQueryError(w, r, qerr)
+		return
+	}
+	if dryRun {
+		rep, err := backfillDryRun(ws, year, force)
+		if errors.Is(err, errNoSeasonData) {
+			writeError(w, r, http.StatusNotFound, err.Error())
+			return
+		}
+		if err != nil {
+			log.Printf("Error: backfill dry run: %v", err)
+			writeError(w, r, http.StatusInternalServerError, "could not read the season")
+			return
+		}
+		writeDryRun(w, r, rep)
+		return
+	}
+	provider, err := newBackfillProvider()
+	if err != nil {
+		log.Printf("Error: backfill: %v", err)
+		writeError(w, r, http.StatusInternalServerError, "the conditions provider is misconfigured")
+		return
+	}
+
+	backfillMu.Lock()
+	if backfillJob != nil && backfillJob.State == "running" {
+		backfillMu.Unlock()
+		writeError(w, r, http.StatusConflict, "a backfill of season "+backfillJob.Season+" is running")
+		return
+	}
+	job := &BackfillStatus{State: "running", Season: year, Force: force, StartedAt: clock.Now().UTC()}
+	backfillJob = job
+	status := *job
+	backfillMu.Unlock()
+
+	go func() {
+		result, err := backfillSeason(context.Background(), ws, provider, year, force)
+		backfillMu.Lock()
+		defer backfillMu.Unlock()
+		finished := clock.Now().UTC()
+		job.FinishedAt, job.Result, job.State = &finished, &result, "done"
+		if err != nil {
+			job.State, job.Error = "failed", err.Error()
+			log.Printf("Error: backfill of %s: %v", year, err)
+			return
+		}
+		log.Printf("Backfilled season %s from %s: %d games in %d weeks", year, result.Provider, result.Games, result.Weeks)
+	}()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Location", "/admin/backfill")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(status)
+}
+
+// handleBackfillStatus serves the state of the last backfill
+func handleBackfillStatus(w http.ResponseWriter, r *http.Request) {
+	backfillMu.Lock()
+	var status BackfillStatus
+	ok := backfillJob != nil
+	if ok {
+		status = *backfillJob
+	}
+	backfillMu.Unlock()
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "no backfill was started")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		writeError(w, r, http.StatusInternalServerError, "error encoding response")
+	}
+}
+
+// runBackfill implements the backfill command:
+//
+//	rewatchable backfill [-data dir] [-provider espn|url] [-force] [-dry-run] [-lang lang] year...
+func runBackfill(args []string) error {
+	fset := flag.NewFlagSet("backfill", flag.ContinueOnError)
+	dir := fset.String("data", "data", "data directory")
+	spec := fset.String("provider", os.Getenv("CONDITIONS_PROVIDER"), "conditions provider, espn or a URL template with {id}")
+	force := fset.Bool("force", false, "replace the conditions already stored")
+	dryRun := fset.Bool("dry-run", false, "print the week files that could be rewritten")
+	langArg := langFlag(fset)
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+	lang, err := checkLang(*langArg)
+	if err != nil {
+		return err
+	}
+	if fset.NArg() == 0 {
+		return errors.New(translate(lang, "cli.backfill.usage"))
+	}
+	provider, err := newConditionsProvider(*spec)
+	if err != nil {
+		return err
+	}
+
+	ws := store.NewDir(*dir)
+	dataStore = ws
+	for _, year := range fset.Args() {
+		if *dryRun {
+			rep, err := backfillDryRun(ws, year, *force)
+			if err != nil {
+				return fmt.Errorf("backfill %s: %w", year, err)
+			}
+			if err := printDryRun(os.Stdout, rep); err != nil {
+				return err
+			}
+			continue
+		}
+		result, err := backfillSeason(context.Background(), ws, provider, year, *force)
+		if err != nil {
+			return fmt.Errorf("backfill %s: %w", year, err)
+		}
+		log.Print(translate(lang, "cli.backfill.done",
+			year, result.Provider, result.Games, result.Weeks, result.Skipped, len(result.Failed)))
+	}
+	return nil
+}
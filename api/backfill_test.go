@@ -0,0 +1,185 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jjway/rewatchableGamesApi-go/store"
+)
+
+// fakeConditions knows the games of its map and fails on "bad"
+type fakeConditions map[string]*GameConditions
+
+func (p fakeConditions) Name() string { return "fake" }
+
+func (p fakeConditions) Conditions(ctx context.Context, year, week string, g GameStats) (*GameConditions, error) {
+	if g.ID == "bad" {
+		return nil, errors.New("boom")
+	}
+	return p[g.ID], nil
+}
+
+func TestBackfillSeason(t *testing.T) {
+	useFakeClock(t)
+	dir := setupTestData(t)
+	useTestStore(t, dir)
+	ws := store.NewDir(dir)
+	kickoff := time.Date(2024, 9, 6, 0, 20, 0, 0, time.UTC)
+	provider := fakeConditions{"game1": {
+		Kickoff: kickoff,
+		Venue:   "Arrowhead Stadium",
+		Weather: &GameWeather{Condition: "Clear", TemperatureF: 72},
+	}}
+
+	result, err := backfillSeason(context.Background(), ws, provider, "2024", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Weeks != 2 || result.Games != 2 || result.Provider != "fake" {
+		t.Fatalf("unexpected result %+v", result)
+	}
+
+	// The week files, cache and processed games carry the conditions
+	games, err := readWeek(ws, "2024/1.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := games[0].Conditions; c == nil || !c.Kickoff.Equal(kickoff) || c.Weather.TemperatureF != 72 {
+		t.Errorf("unexpected stored conditions %+v", c)
+	}
+	cached, err := loadGameStats("2024/2.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := processGame(raters[defaultAlgorithm], cached[0]); p.Conditions == nil || p.Conditions.Venue != "Arrowhead Stadium" {
+		t.Errorf("expected the cached week to have the conditions, got %+v", p.Conditions)
+	}
+
+	manifest, err := storeManifest(ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range manifest {
+		if len(entry.Provenance) != 1 || entry.Provenance[0].Provider != "fake" || entry.Provenance[0].Games != 1 {
+			t.Errorf("%s: unexpected provenance %+v", entry.File, entry.Provenance)
+		}
+	}
+
+	// Games with conditions are skipped unless forced
+	result, err = backfillSeason(context.Background(), ws, provider, "2024", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Games != 0 || result.Skipped != 2 {
+		t.Errorf("expected a second run to skip every game, got %+v", result)
+	}
+	result, err = backfillSeason(context.Background(), ws, provider, "2024", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Games != 2 {
+		t.Errorf("expected a forced run to rewrite every game, got %+v", result)
+	}
+
+	if _, err := backfillSeason(context.Background(), ws, provider, "1999", false); err == nil {
+		t.Error("expected an error for a season without data")
+	}
+}
+
+func TestTemplateConditions(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/2024/1/game1" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"kickoff": "2024-09-06T00:20:00Z", "venue": "Arrowhead Stadium", "weather": {"condition": "Clear", "temperatureF": 72, "windMph": 8}}`))
+	}))
+	defer srv.Close()
+
+	p, err := newConditionsProvider(srv.URL + "/{year}/{week}/{id}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := p.Conditions(context.Background(), "2024", "1", GameStats{ID: "game1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c == nil || c.Venue != "Arrowhead Stadium" || c.Weather.WindMph != 8 {
+		t.Errorf("unexpected conditions %+v", c)
+	}
+	if c, err := p.Conditions(context.Background(), "2024", "1", GameStats{ID: "other"}); c != nil || err != nil {
+		t.Errorf("expected an unknown game to be skipped, got %+v, %v", c, err)
+	}
+
+	if _, err := newConditionsProvider(srv.URL + "/games"); err == nil {
+		t.Error("expected a URL without {id} to be rejected")
+	}
+	if _, err := newConditionsProvider("weather.gov"); err == nil {
+		t.Error("expected an unknown provider to be rejected")
+	}
+}
+
+func TestParseESPNTime(t *testing.T) {
+	for _, s := range []string{"2024-09-06T00:20Z", "2024-09-06T00:20:00Z", "2024-09-05T20:20:00-04:00"} {
+		got, err := parseESPNTime(s)
+		if err != nil || !got.Equal(time.Date(2024, 9, 6, 0, 20, 0, 0, time.UTC)) {
+			t.Errorf("parseESPNTime(%q) = %v, %v", s, got, err)
+		}
+	}
+}
+
+func TestHandleBackfill(t *testing.T) {
+	useFakeClock(t)
+	useTestStore(t, setupTestData(t))
+	useAPIKeys(t, APIKey{Name: "ops", Key: "admin-key", Roles: []string{roleAdmin}})
+	old := newBackfillProvider
+	newBackfillProvider = func() (conditionsProvider, error) {
+		return fakeConditions{"game1": {Kickoff: time.Date(2024, 9, 6, 0, 20, 0, 0, time.UTC)}}, nil
+	}
+	t.Cleanup(func() {
+		newBackfillProvider = old
+		backfillMu.Lock()
+		backfillJob = nil
+		backfillMu.Unlock()
+	})
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /admin/backfill", requireRole(roleAdmin, http.HandlerFunc(handleBackfillStatus)))
+	mux.Handle("POST /admin/backfill", requireRole(roleAdmin, http.HandlerFunc(handleStartBackfill)))
+	do := func(method, target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		req.Header.Set("X-API-Key", "admin-key")
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := do("GET", "/admin/backfill"); rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 before any backfill, got %d", rec.Code)
+	}
+	if rec := do("POST", "/admin/backfill?year=latest"); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an invalid year, got %d", rec.Code)
+	}
+	rec := do("POST", "/admin/backfill?year=2024")
+	if rec.Code != http.StatusAccepted || rec.Header().Get("Location") != "/admin/backfill" {
+		t.Fatalf("expected status 202, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var status BackfillStatus
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		rec := do("GET", "/admin/backfill")
+		if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+			t.Fatal(err)
+		}
+		if status.State != "running" {
+			break
+		}
+	}
+	if status.State != "done" || status.Result == nil || status.Result.Games != 2 {
+		t.Errorf("unexpected backfill status %+v", status)
+	}
+}
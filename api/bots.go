@@ -0,0 +1,131 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+)
+
+// crawlerTokens are User-Agent substrings identifying crawlers and scrapers
+var crawlerTokens = []string{
+	"bot", "crawl", "spider", "slurp", "scrape",
+	"facebookexternalhit",
+}
+
+// botLimiter applies a stricter budget to crawlers than to browsers:
+// one request per second with a small burst
+var botLimiter = newRateLimiter(1, 5)
+
+// isCrawler reports whether the request comes from a crawler User-Agent
+func isCrawler(r *http.Request) bool {
+	ua := strings.ToLower(r.UserAgent())
+	if ua == "" {
+		return false
+	}
+	for _, token := range crawlerTokens {
+		if strings.Contains(ua, token) {
+			return true
+		}
+	}
+	return false
+}
+
+// botMiddleware rate limits crawler traffic per client IP. Regular
+// clients pass through untouched.
+func botMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isCrawler(r) && r.URL.Path != "/robots.txt" && !botLimiter.allow(clientIP(r)) {
+			w.Header().Set("Retry-After", "1")
+			writeError(w, r, http.StatusTooManyRequests, "crawl rate exceeded, see /robots.txt")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// GameSummary is the lightweight representation served to crawlers
+type GameSummary struct {
+	ID          string  `json:"id"`
+	ShortName   string  `json:"shortName"`
+	TotalRating float64 `json:"totalRating"`
+}
+
+// crawlerSummary reduces processed games to their summary representation
+func crawlerSummary(processed []ProcessedGameStats) []GameSummary {
+	summaries := make([]GameSummary, 0, len(processed))
+	for _, p := range processed {
+		summaries = append(summaries, GameSummary{
+			ID:          p.ID,
+			ShortName:   p.ShortName,
+			TotalRating: p.TotalRating,
+		})
+	}
+	return summaries
+}
+
+// writeCrawlerSummary writes the summary representation of processed with
+// a long cache lifetime, so crawlers are answered by intermediate caches
+func writeCrawlerSummary(w http.ResponseWriter, r *http.Request, processed []ProcessedGameStats) {
+	w.Header().Set("Content-Type", "application/json")
+	setCacheHeaders(w, "crawler")
+	if err := json.NewEncoder(w).Encode(crawlerSummary(processed)); err != nil {
+		writeError(w, r, http.StatusInternalServerError, "error encoding response")
+	}
+}
+
+// BulkWeek is one week of the /bulk/{year} document
+type BulkWeek struct {
+	// Week is the position of the week in the season, the playoff rounds
+	// following week 18; Name is the week number or the round
+	Week  int                  `json:"week"`
+	Name  string               `json:"name"`
+	Label string               `json:"label"`
+	Games []ProcessedGameStats `json:"games"`
+}
+
+// handleBulkYear serves every processed game of a season in one document.
+// It is the endpoint crawlers and bulk consumers should use instead of
+// walking /games/{year}/{week}; robots.txt points them here.
+func handleBulkYear(w http.ResponseWriter, r *http.Request) {
+	year := r.PathValue("year")
+
+	rater, qerr := raterFor(r)
+	if qerr != nil {
+		writeQueryError(w, r, qerr)
+		return
+	}
+
+	season := loadSeason(r.Context(), year)
+	if len(season) == 0 {
+		writeError(w, r, http.StatusNotFound, "no data for season "+year)
+		return
+	}
+
+	weeks := make([]BulkWeek, 0, len(season))
+	for _, sw := range season {
+		order, _ := weekOrder(sw.Week)
+		games := processGames(rater, sw.Games)
+		for i := range games {
+			games[i].setLocation(year, sw.Week)
+		}
+		weeks = append(weeks, BulkWeek{Week: order, Name: sw.Week, Label: weekLabel(sw.Week), Games: games})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	setCacheHeaders(w, "bulk")
+	if err := json.NewEncoder(w).Encode(weeks); err != nil {
+		writeError(w, r, http.StatusInternalServerError, "error encoding response")
+	}
+}
+
+const robotsTxt = `# Per-week routes are rate limited for crawlers.
+# Fetch whole seasons from /bulk/{year} instead.
+User-agent: *
+Allow: /bulk/
+Disallow: /games/
+`
+
+func handleRobots(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	setCacheHeaders(w, "robots")
+	w.Write([]byte(robotsTxt))
+}
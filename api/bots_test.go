@@ -0,0 +1,134 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIsCrawler(t *testing.T) {
+	tests := []struct {
+		ua   string
+		want bool
+	}{
+		{"Mozilla/5.0 (compatible; Googlebot/2.1)", true},
+		{"python-requests/2.31", false},
+		{"Go-http-client/2.0", false},
+		{"Mozilla/5.0 (Macintosh; Intel Mac OS X 14_0) Safari/605.1.15", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/games/2024/1", nil)
+		req.Header.Set("User-Agent", tt.ua)
+		if got := isCrawler(req); got != tt.want {
+			t.Errorf("isCrawler(%q) = %v, want %v", tt.ua, got, tt.want)
+		}
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	old := trustedProxies
+	t.Cleanup(func() { trustedProxies = old })
+	var err error
+	if trustedProxies, err = parseTrustedProxies([]string{"10.0.0.0/8", " 192.0.2.1 "}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		remote string
+		xff    []string
+		want   string
+	}{
+		{"198.51.100.9:5000", nil, "198.51.100.9"},
+		// Only a trusted proxy may name the client
+		{"198.51.100.9:5000", []string{"203.0.113.5"}, "198.51.100.9"},
+		{"10.1.2.3:5000", []string{"203.0.113.5"}, "203.0.113.5"},
+		{"10.1.2.3:5000", nil, "10.1.2.3"},
+		// The hops the client wrote itself are skipped
+		{"10.1.2.3:5000", []string{"1.1.1.1, 203.0.113.5, 10.9.9.9"}, "203.0.113.5"},
+		{"192.0.2.1:5000", []string{"1.1.1.1", "203.0.113.5, 10.9.9.9"}, "203.0.113.5"},
+		{"10.1.2.3:5000", []string{"203.0.113.5, garbage"}, "10.1.2.3"},
+		{"[::ffff:10.1.2.3]:5000", []string{"2001:db8::1"}, "2001:db8::1"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/games/2024/1", nil)
+		req.RemoteAddr = tt.remote
+		for _, v := range tt.xff {
+			req.Header.Add("X-Forwarded-For", v)
+		}
+		if got := clientIP(req); got != tt.want {
+			t.Errorf("%s %v: expected %s, got %s", tt.remote, tt.xff, tt.want, got)
+		}
+	}
+
+	if _, err := parseTrustedProxies([]string{"10.0.0.0/33"}); err == nil {
+		t.Error("expected an invalid range to be rejected")
+	}
+}
+
+func TestBotMiddlewareThrottlesCrawlers(t *testing.T) {
+	oldLimiter := botLimiter
+	botLimiter = newRateLimiter(0, 2)
+	t.Cleanup(func() { botLimiter = oldLimiter })
+
+	handler := botMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	codes := make([]int, 0, 3)
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest("GET", "/games/2024/1", nil)
+		req.Header.Set("User-Agent", "Bingbot/2.0")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		codes = append(codes, rec.Code)
+	}
+	if codes[0] != http.StatusOK || codes[1] != http.StatusOK || codes[2] != http.StatusTooManyRequests {
+		t.Errorf("expected 200, 200, 429 for crawler, got %v", codes)
+	}
+
+	// Browsers are never throttled
+	req := httptest.NewRequest("GET", "/games/2024/1", nil)
+	req.Header.Set("User-Agent", "Mozilla/5.0 Firefox/120.0")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected 200 for browser, got %d", rec.Code)
+	}
+}
+
+func TestCrawlerGetsSummary(t *testing.T) {
+	useTestStore(t, setupTestData(t))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /games/{year}/{week}", handleGamesYearWeek)
+	mux.HandleFunc("GET /bulk/{year}", handleBulkYear)
+
+	req := httptest.NewRequest("GET", "/games/2024/1", nil)
+	req.Header.Set("User-Agent", "AhrefsBot/7.0")
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	var summaries []map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &summaries); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if len(summaries) != 1 || len(summaries[0]) != 3 {
+		t.Errorf("expected one 3-field summary, got %v", summaries)
+	}
+	if cc := rec.Header().Get("Cache-Control"); cc != cachePolicies["crawler"].header() {
+		t.Errorf("expected long cache lifetime for crawlers, got %q", cc)
+	}
+
+	req = httptest.NewRequest("GET", "/bulk/2024", nil)
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	var weeks []BulkWeek
+	if err := json.Unmarshal(rec.Body.Bytes(), &weeks); err != nil {
+		t.Fatalf("failed to parse bulk response: %v", err)
+	}
+	if len(weeks) != 2 || weeks[1].Week != 2 {
+		t.Errorf("expected weeks 1 and 2 in bulk response, got %+v", weeks)
+	}
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+
+	"github.com/jjway/rewatchableGamesApi-go/ratings"
+
+	"github.com/jjway/rewatchableGamesApi-go/store"
+)
+
+// The rating breakdown of a game, component by component
+type (
+	RatingBreakdown  = ratings.Breakdown
+	OffenseBreakdown = ratings.OffenseBreakdown
+	DefenseBreakdown = ratings.DefenseBreakdown
+)
+
+// breakdownGame computes the rating breakdown of a game with the rating
+// config
+func breakdownGame(g GameStats) RatingBreakdown {
+	return ratingConfig.Breakdown(g)
+}
+
+// findGame looks up a game by ID in a cached week
+func findGame(year, week, id string) (GameStats, error) {
+	gameList, err := loadGameStats(store.WeekFile(year, week))
+	if err != nil {
+		return GameStats{}, err
+	}
+	for _, g := range gameList {
+		if g.ID == id {
+			return g, nil
+		}
+	}
+	return GameStats{}, errGameNotFound
+}
+
+var errGameNotFound = errors.New("game not found")
+
+// lookupGame finds a game for a handler, writing the 404 or 500 response
+// itself when it cannot
+func lookupGame(w http.ResponseWriter, r *http.Request, year, week, id string) (GameStats, bool) {
+	g, err := findGame(year, week, id)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		setCacheHeaders(w, "missing")
+		writeError(w, r, http.StatusNotFound, "no data for this week")
+		return g, false
+	case errors.Is(err, errGameNotFound):
+		writeError(w, r, http.StatusNotFound, "game "+id+" not found")
+		return g, false
+	case err != nil:
+		writeError(w, r, http.StatusInternalServerError, "error reading data")
+		return g, false
+	}
+	return g, true
+}
+
+// handleGameRating explains why a game got its rating, component by
+// component
+func handleGameRating(w http.ResponseWriter, r *http.Request) {
+	year := r.PathValue("year")
+	week := r.PathValue("week")
+	id := r.PathValue("id")
+
+	rater, qerr := raterFor(r)
+	if qerr != nil {
+		writeQueryError(w, r, qerr)
+		return
+	}
+
+	g, ok := lookupGame(w, r, year, week, id)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	setCacheHeaders(w, "game")
+	if err := json.NewEncoder(w).Encode(rater.Rate(g)); err != nil {
+		writeError(w, r, http.StatusInternalServerError, "error encoding response")
+	}
+}
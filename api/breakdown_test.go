@@ -0,0 +1,48 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandleGameRating(t *testing.T) {
+	useTestStore(t, setupTestData(t))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /games/{year}/{week}/{id}/rating", handleGameRating)
+
+	req := httptest.NewRequest("GET", "/games/2024/1/game1/rating", nil)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	var b RatingBreakdown
+	if err := json.Unmarshal(rec.Body.Bytes(), &b); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+
+	// testData: 55 points, 850 yards, 5.5 YPA, QBRs 110 and 105
+	if b.Offense.TotalPoints != 1 || b.Offense.TotalYards != 1 || b.Offense.YardsPerAttempt != 1 {
+		t.Errorf("unexpected offense buckets %+v", b.Offense)
+	}
+	if b.Offense.HomeQBR != 0.5 || b.Offense.AwayQBR != 0.5 {
+		t.Errorf("unexpected QBR bonuses %+v", b.Offense)
+	}
+	// 1 defensive TD (x3), 2 fumbles, 3 interceptions, 1 goal line stand
+	if b.Defense.DefensiveTds != 3 || b.DefensiveBigPlays != 9 {
+		t.Errorf("unexpected defense breakdown %+v", b.Defense)
+	}
+	if b.TotalRating != b.OffensiveRating+b.DefensiveBigPlays+b.ScenarioRating {
+		t.Errorf("components do not add up: %+v", b)
+	}
+
+	req = httptest.NewRequest("GET", "/games/2024/1/nope/rating", nil)
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", rec.Code)
+	}
+}
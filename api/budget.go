@@ -0,0 +1,131 @@
+package api
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Budget is a compute allowance in cost units, refilled at Rate units per
+// second up to Burst. One unit is roughly the work of rating and filtering
+// one season of games.
+type Budget struct {
+	Rate  float64 `json:"rate"`
+	Burst int     `json:"burst"`
+}
+
+// defaultComputeBudget lets a client scan a few seasons per second and
+// run a full-history query every minute or so
+var defaultComputeBudget = Budget{Rate: 1, Burst: 60}
+
+// computeBudgets holds the limiter of the default budget, shared by
+// anonymous clients and keys without their own budget, and one limiter per
+// key with a budget override
+var computeBudgets = struct {
+	mu         sync.Mutex
+	shared     *rateLimiter
+	overridden map[string]*rateLimiter
+}{overridden: make(map[string]*rateLimiter)}
+
+// computeBudgetFromConfig applies COMPUTE_BUDGET_RATE and
+// COMPUTE_BUDGET_BURST, when set, over the default budget
+func computeBudgetFromConfig(cfg Config) (Budget, error) {
+	b := defaultComputeBudget
+	switch rate := cfg.RateLimits.ComputeBudgetRate; {
+	case rate < 0:
+		return b, fmt.Errorf("invalid COMPUTE_BUDGET_RATE %v: must be a positive number", rate)
+	case rate > 0:
+		b.Rate = rate
+	}
+	switch burst := cfg.RateLimits.ComputeBudgetBurst; {
+	case burst < 0:
+		return b, fmt.Errorf("invalid COMPUTE_BUDGET_BURST %d: must be a positive integer", burst)
+	case burst > 0:
+		b.Burst = burst
+	}
+	return b, nil
+}
+
+// setComputeBudget replaces the default budget, resetting every client
+func setComputeBudget(b Budget) {
+	computeBudgets.mu.Lock()
+	defer computeBudgets.mu.Unlock()
+	defaultComputeBudget = b
+	computeBudgets.shared = nil
+	clear(computeBudgets.overridden)
+}
+
+// computeLimiter returns the limiter and bucket key charged for r: the API
+// key when the request carries a known one, the client IP otherwise
+func computeLimiter(r *http.Request) (*rateLimiter, string, Budget) {
+	computeBudgets.mu.Lock()
+	defer computeBudgets.mu.Unlock()
+
+	k, ok := lookupAPIKey(r)
+	if ok && k.ComputeBudget != nil {
+		l, found := computeBudgets.overridden[k.Name]
+		if !found {
+			l = newRateLimiter(k.ComputeBudget.Rate, k.ComputeBudget.Burst)
+			computeBudgets.overridden[k.Name] = l
+		}
+		return l, k.Name, *k.ComputeBudget
+	}
+	if computeBudgets.shared == nil {
+		computeBudgets.shared = newRateLimiter(defaultComputeBudget.Rate, defaultComputeBudget.Burst)
+	}
+	if ok {
+		return computeBudgets.shared, "key:" + k.Name, defaultComputeBudget
+	}
+	return computeBudgets.shared, "ip:" + clientIP(r), defaultComputeBudget
+}
+
+// withCost charges the cost of each request to the client's compute budget
+// and answers 429 with a Retry-After once it is spent, so a consumer of
+// the expensive endpoints cannot starve the cheap game lists. Requests
+// costing more than a whole budget are rejected with 400.
+func withCost(cost func(*http.Request) float64, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := cost(r)
+		l, key, budget := computeLimiter(r)
+		w.Header().Set("X-Compute-Cost", strconv.FormatFloat(n, 'f', -1, 64))
+
+		if n > float64(budget.Burst) {
+			writeError(w, r, http.StatusBadRequest, fmt.Sprintf("request costs %g compute units, more than the budget of %d; narrow it down", n, budget.Burst))
+			return
+		}
+		if ok, wait := l.take(key, n); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			writeError(w, r, http.StatusTooManyRequests, fmt.Sprintf("compute budget exhausted, retry in %s", wait.Round(time.Second)))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// spendCompute charges n more units to the compute budget of r, for the
+// handlers whose cost is only known as they run
+func spendCompute(r *http.Request, n float64) error {
+	l, key, _ := computeLimiter(r)
+	if ok, wait := l.take(key, n); !ok {
+		return fmt.Errorf("compute budget exhausted, retry in %s", wait.Round(time.Second))
+	}
+	return nil
+}
+
+// fixedCost is the cost model of endpoints doing the same work per request
+func fixedCost(n float64) func(*http.Request) float64 {
+	return func(*http.Request) float64 { return n }
+}
+
+// seasonRangeCost charges one unit per season of a /games range query.
+// Invalid ranges cost one unit and are rejected by the handler.
+func seasonRangeCost(r *http.Request) float64 {
+	from, to, qerr := parseSeasonRange(r)
+	if qerr != nil || to < from {
+		return 1
+	}
+	return float64(to - from + 1)
+}
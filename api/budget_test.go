@@ -0,0 +1,71 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWithCost(t *testing.T) {
+	c := useFakeClock(t)
+	old := defaultComputeBudget
+	setComputeBudget(Budget{Rate: 1, Burst: 5})
+	t.Cleanup(func() { setComputeBudget(old) })
+	useAPIKeys(t,
+		APIKey{Name: "plain", Key: "p-key", Roles: []string{roleRead}},
+		APIKey{Name: "heavy", Key: "h-key", Roles: []string{roleRead}, ComputeBudget: &Budget{Rate: 10, Burst: 50}},
+	)
+
+	h := withCost(seasonRangeCost, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	do := func(url, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", url, nil)
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Three seasons, then three more: the budget of 5 is spent
+	if rec := do("/games?from=2020&to=2022", ""); rec.Code != http.StatusOK || rec.Header().Get("X-Compute-Cost") != "3" {
+		t.Fatalf("expected the first request through, got %d cost %q", rec.Code, rec.Header().Get("X-Compute-Cost"))
+	}
+	rec := do("/games?from=2020&to=2022", "")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", rec.Code)
+	}
+	if ra := rec.Header().Get("Retry-After"); ra != "1" {
+		t.Errorf("expected Retry-After 1, got %q", ra)
+	}
+
+	// Keys have their own buckets, overrides their own budget
+	if rec := do("/games?from=2020&to=2022", "p-key"); rec.Code != http.StatusOK {
+		t.Errorf("expected a keyed client to have its own budget, got %d", rec.Code)
+	}
+	if rec := do("/games?from=2000&to=2030", "p-key"); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected a request above the whole budget to be rejected, got %d", rec.Code)
+	}
+	if rec := do("/games?from=2000&to=2030", "h-key"); rec.Code != http.StatusOK {
+		t.Errorf("expected the larger budget to allow the request, got %d", rec.Code)
+	}
+
+	c.Advance(time.Second)
+	if rec := do("/games?from=2020&to=2022", ""); rec.Code != http.StatusOK {
+		t.Errorf("expected the budget to refill, got %d", rec.Code)
+	}
+}
+
+func TestComputeBudgetFromConfig(t *testing.T) {
+	var cfg Config
+	cfg.RateLimits.ComputeBudgetRate = 0.5
+	cfg.RateLimits.ComputeBudgetBurst = 20
+	if b, err := computeBudgetFromConfig(cfg); err != nil || b != (Budget{Rate: 0.5, Burst: 20}) {
+		t.Errorf("unexpected budget %+v, %v", b, err)
+	}
+	cfg.RateLimits.ComputeBudgetBurst = -1
+	if _, err := computeBudgetFromConfig(cfg); err == nil {
+		t.Error("expected an error for a negative burst")
+	}
+}
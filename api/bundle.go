@@ -0,0 +1,266 @@
+package api
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/jjway/rewatchableGamesApi-go/store"
+)
+
+// secretEnv are the settings whose values never leave the host
+var secretEnv = map[string]bool{
+	"OBJECT_STORE_TOKEN": true, "OBJECT_STORE_SECRET_ACCESS_KEY": true,
+	"PUBLISH_TOKEN": true, "PUBLISH_SECRET_ACCESS_KEY": true,
+	"REPLICA_TOKEN": true, "REPLICA_SECRET_ACCESS_KEY": true,
+	"USER_DATA_DSN": true,
+}
+
+const redacted = "REDACTED"
+
+// Version is the release of the binary, which the main package sets from
+// the version stamped by the release tool
+var Version string
+
+// VersionInfo identifies the build that produced a bundle
+type VersionInfo struct {
+	Version   string `json:"version"`
+	Embedded  bool   `json:"embeddedData,omitempty"`
+	GoVersion string `json:"goVersion"`
+	OS        string `json:"os"`
+	Arch      string `json:"arch"`
+	Revision  string `json:"revision,omitempty"`
+	BuildTime string `json:"buildTime,omitempty"`
+	Modified  bool   `json:"modified,omitempty"`
+}
+
+// ManifestEntry is one data file of the store
+type ManifestEntry struct {
+	File   string `json:"file"`
+	Bytes  int    `json:"bytes"`
+	SHA256 string `json:"sha256"`
+	Error  string `json:"error,omitempty"`
+
+	// Provenance lists the backfills that rewrote the file
+	Provenance []Provenance `json:"provenance,omitempty"`
+}
+
+func versionInfo() VersionInfo {
+	v := VersionInfo{Version: "devel", Embedded: EmbeddedData != nil, GoVersion: runtime.Version(), OS: runtime.GOOS, Arch: runtime.GOARCH}
+	if Version != "" {
+		v.Version = Version
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return v
+	}
+	if Version == "" && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		v.Version = info.Main.Version
+	}
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			v.Revision = s.Value
+		case "vcs.time":
+			v.BuildTime = s.Value
+		case "vcs.modified":
+			v.Modified = s.Value == "true"
+		}
+	}
+	return v
+}
+
+// redactedConfig returns the settings of cfg that are set, by variable
+// name, with secrets and URL credentials removed
+func redactedConfig(cfg Config) map[string]string {
+	config := make(map[string]string)
+	for _, s := range cfg.settings() {
+		v := s.String()
+		if v == "" {
+			continue
+		}
+		name := s.env
+		switch {
+		case secretEnv[name]:
+			v = redacted
+		case strings.Contains(v, "@") && strings.Contains(v, "://"):
+			// user:password@host
+			scheme, rest, _ := strings.Cut(v, "://")
+			v = scheme + "://" + redacted + rest[strings.LastIndex(rest, "@"):]
+		}
+		config[name] = v
+	}
+	return config
+}
+
+// storeManifest lists every data file of s with its size and checksum
+func storeManifest(s store.Store) ([]ManifestEntry, error) {
+	names, err := s.ListFiles()
+	if err != nil {
+		return nil, err
+	}
+	provenance, err := loadProvenance(s)
+	if err != nil {
+		return nil, err
+	}
+	manifest := make([]ManifestEntry, 0, len(names))
+	for _, name := range names {
+		entry := ManifestEntry{File: name, Provenance: provenance[name]}
+		data, err := s.ReadFile(name)
+		if err != nil {
+			entry.Error = err.Error()
+		} else {
+			sum := sha256.Sum256(data)
+			entry.Bytes = len(data)
+			entry.SHA256 = hex.EncodeToString(sum[:])
+		}
+		manifest = append(manifest, entry)
+	}
+	return manifest, nil
+}
+
+// writeSupportBundle writes the bundle zip to out. When server is set, the
+// cache stats, consistency report and recent logs of that running
+// instance are included, fetched with an admin key.
+func writeSupportBundle(out io.Writer, s store.Store, cfg Config, server, key string) error {
+	zw := zip.NewWriter(out)
+	now := clock.Now()
+
+	add := func(name string, data []byte) error {
+		f, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: now})
+		if err != nil {
+			return err
+		}
+		_, err = f.Write(data)
+		return err
+	}
+	addJSON := func(name string, v any) error {
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+		return add(name, append(data, '\n'))
+	}
+
+	if err := addJSON("version.json", versionInfo()); err != nil {
+		return err
+	}
+	if err := addJSON("config.json", redactedConfig(cfg)); err != nil {
+		return err
+	}
+	manifest, err := storeManifest(s)
+	if err != nil {
+		if err := add("manifest.error.txt", []byte(err.Error()+"\n")); err != nil {
+			return err
+		}
+	} else if err := addJSON("manifest.json", manifest); err != nil {
+		return err
+	}
+
+	if server == "" {
+		if err := add("server.txt", []byte("No -server given: cache stats, consistency and logs were not collected.\n")); err != nil {
+			return err
+		}
+		return zw.Close()
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	for file, path := range map[string]string{
+		"cache.json":       "/admin/cache",
+		"consistency.json": "/admin/consistency",
+		"logs.txt":         "/admin/logs",
+	} {
+		data, err := fetchAdmin(client, strings.TrimSuffix(server, "/")+path, key)
+		if err != nil {
+			file, data = file+".error.txt", []byte(err.Error()+"\n")
+		}
+		if err := add(file, data); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// fetchAdmin GETs an admin endpoint with the X-API-Key header
+func fetchAdmin(client *http.Client, url, key string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if key != "" {
+		req.Header.Set("X-API-Key", key)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: unexpected status %d: %s", url, resp.StatusCode, data)
+	}
+	return data, nil
+}
+
+// runSupportBundle implements the support-bundle command:
+//
+//	rewatchable support-bundle [-o file] [-config file] [-server url] [-key key]
+//
+// The bundle describes the settings of -config, or CONFIG_FILE, under the
+// environment, as serve resolves them.
+func runSupportBundle(args []string) error {
+	fset := flag.NewFlagSet("support-bundle", flag.ContinueOnError)
+	configPath := fset.String("config", os.Getenv("CONFIG_FILE"), "YAML file of the settings of the server, $CONFIG_FILE by default")
+	output := fset.String("o", "", "output zip (default rewatchable-support-<time>.zip)")
+	server := fset.String("server", "", "URL of the running server to collect stats and logs from")
+	key := fset.String("key", os.Getenv("SUPPORT_API_KEY"), "admin API key for -server, or an env: or file: reference to it")
+	langArg := langFlag(fset)
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+	lang, err := checkLang(*langArg)
+	if err != nil {
+		return err
+	}
+	if *key, err = resolveSecret(*key); err != nil {
+		return err
+	}
+	if *output == "" {
+		*output = "rewatchable-support-" + clock.Now().UTC().Format("20060102-150405") + ".zip"
+	}
+
+	cfg, err := resolveSettings(*configPath, nil)
+	if err != nil {
+		return err
+	}
+	s, err := openStore(cfg)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	if err := writeSupportBundle(f, s, cfg, *server, *key); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	log.Print(translate(lang, "cli.bundle.done", *output))
+	return nil
+}
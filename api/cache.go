@@ -0,0 +1,260 @@
+package api
+
+import (
+	"container/list"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// weekCache is the LRU cache of the decoded week files. It is bounded by
+// an entry count and an approximate byte budget, the sum of the week file
+// sizes, either unlimited when zero. The weeks of the pinned season, the
+// latest one unless configured, are never evicted.
+//
+// The cache also keeps the catalog of every week file loaded or ingested,
+// with its game count, so evicting a week does not remove it from
+// /seasons and the whole-cache views can reload it.
+type weekCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	maxBytes   int
+	pinSeason  string
+
+	order *list.List // of *cacheItem, most recently used first
+	items map[string]*list.Element
+	weeks map[string]int
+	bytes int
+
+	hits, misses, evictions uint64
+}
+
+type cacheItem struct {
+	name  string
+	entry cacheEntry
+}
+
+func newWeekCache(maxEntries, maxBytes int, pinSeason string) *weekCache {
+	return &weekCache{
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		pinSeason:  pinSeason,
+		order:      list.New(),
+		items:      make(map[string]*list.Element),
+		weeks:      make(map[string]int),
+	}
+}
+
+// get returns the entry of name and marks it as recently used. An entry
+// older than ttl, when set, counts as a miss.
+func (c *weekCache) get(name string, ttl time.Duration) (cacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[name]
+	if !ok || (ttl > 0 && clock.Now().Sub(el.Value.(*cacheItem).entry.loadedAt) >= ttl) {
+		c.misses++
+		return cacheEntry{}, false
+	}
+	c.hits++
+	c.order.MoveToFront(el)
+	return el.Value.(*cacheItem).entry, true
+}
+
+// peek returns the entry of name without touching its recency or the
+// hit counters
+func (c *weekCache) peek(name string) (cacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[name]; ok {
+		return el.Value.(*cacheItem).entry, true
+	}
+	return cacheEntry{}, false
+}
+
+// set stores the entry of name and evicts the least recently used weeks
+// over the budget. known reports whether the week was in the catalog,
+// resident or evicted.
+func (c *weekCache) set(name string, entry cacheEntry) (known bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, known = c.weeks[name]
+	count := 0
+	for _, g := range entry.games {
+		if g.ID != "" {
+			count++
+		}
+	}
+	c.weeks[name] = count
+
+	if el, ok := c.items[name]; ok {
+		item := el.Value.(*cacheItem)
+		c.bytes += entry.size - item.entry.size
+		item.entry = entry
+		c.order.MoveToFront(el)
+	} else {
+		c.items[name] = c.order.PushFront(&cacheItem{name: name, entry: entry})
+		c.bytes += entry.size
+	}
+	c.evict()
+	return known
+}
+
+// evicted reports whether name is in the catalog but not resident
+func (c *weekCache) evicted(name string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, known := c.weeks[name]
+	_, resident := c.items[name]
+	return known && !resident
+}
+
+// remove drops name from the cache and the catalog, reporting whether it
+// was known
+func (c *weekCache) remove(name string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, known := c.weeks[name]
+	delete(c.weeks, name)
+	if el, ok := c.items[name]; ok {
+		c.bytes -= el.Value.(*cacheItem).entry.size
+		c.order.Remove(el)
+		delete(c.items, name)
+	}
+	return known
+}
+
+// weekCounts returns the catalog: every known week file and its number of
+// games
+func (c *weekCache) weekCounts() map[string]int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	weeks := make(map[string]int, len(c.weeks))
+	for name, n := range c.weeks {
+		weeks[name] = n
+	}
+	return weeks
+}
+
+// pinnedSeason returns the season whose weeks are never evicted. The
+// caller holds c.mu.
+func (c *weekCache) pinnedSeason() string {
+	if c.pinSeason != "" {
+		return c.pinSeason
+	}
+	latest := ""
+	for name := range c.weeks {
+		if season, _, _ := strings.Cut(name, "/"); season > latest {
+			latest = season
+		}
+	}
+	return latest
+}
+
+// evict drops the least recently used unpinned entries until the cache
+// fits its budget, always keeping the most recent one. The caller holds
+// c.mu.
+func (c *weekCache) evict() {
+	over := func() bool {
+		return (c.maxEntries > 0 && len(c.items) > c.maxEntries) || (c.maxBytes > 0 && c.bytes > c.maxBytes)
+	}
+	if !over() {
+		return
+	}
+	pinned := c.pinnedSeason() + "/"
+	for el := c.order.Back(); el != nil && el != c.order.Front() && over(); {
+		prev := el.Prev()
+		item := el.Value.(*cacheItem)
+		if !strings.HasPrefix(item.name, pinned) {
+			c.order.Remove(el)
+			delete(c.items, item.name)
+			c.bytes -= item.entry.size
+			c.evictions++
+		}
+		el = prev
+	}
+}
+
+// stats describes the budget, counters and resident entries of the cache
+func (c *weekCache) stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	pinned := c.pinnedSeason()
+	stats := CacheStats{
+		Entries:      len(c.items),
+		Bytes:        c.bytes,
+		MaxEntries:   c.maxEntries,
+		MaxBytes:     c.maxBytes,
+		PinnedSeason: pinned,
+		Weeks:        len(c.weeks),
+		Hits:         c.hits,
+		Misses:       c.misses,
+		Evictions:    c.evictions,
+		Files:        make([]CacheFileStats, 0, len(c.items)),
+	}
+	for el := c.order.Front(); el != nil; el = el.Next() {
+		item := el.Value.(*cacheItem)
+		stats.Files = append(stats.Files, CacheFileStats{
+			File:     item.name,
+			Games:    len(item.entry.games),
+			Bytes:    item.entry.size,
+			LoadedAt: item.entry.loadedAt,
+			Pinned:   strings.HasPrefix(item.name, pinned+"/"),
+		})
+	}
+	return stats
+}
+
+// cache holds the decoded week files, keyed by store file name
+var cache = newWeekCache(0, 0, "")
+
+// parseByteSize parses a byte count with an optional KB, MB or GB suffix
+// (powers of 1024), e.g. "256MB"
+func parseByteSize(s string) (int, error) {
+	multiplier := 1
+	upper := strings.ToUpper(strings.TrimSpace(s))
+	for _, unit := range []struct {
+		suffix string
+		size   int
+	}{{"KB", 1 << 10}, {"MB", 1 << 20}, {"GB", 1 << 30}, {"B", 1}} {
+		if strings.HasSuffix(upper, unit.suffix) {
+			upper, multiplier = strings.TrimSpace(strings.TrimSuffix(upper, unit.suffix)), unit.size
+			break
+		}
+	}
+	n, err := strconv.Atoi(upper)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid byte size %q", s)
+	}
+	return n * multiplier, nil
+}
+
+// byteSize is a setting in bytes, in the format of parseByteSize
+type byteSize int
+
+func (b *byteSize) UnmarshalText(text []byte) error {
+	n, err := parseByteSize(string(text))
+	if err != nil {
+		return err
+	}
+	*b = byteSize(n)
+	return nil
+}
+
+// cacheFromConfig builds the cache configured by CACHE_MAX_ENTRIES,
+// CACHE_MAX_BYTES and CACHE_PIN_SEASON
+func cacheFromConfig(cfg Config) (*weekCache, error) {
+	c := cfg.Cache
+	if c.MaxEntries < 0 {
+		return nil, fmt.Errorf("invalid CACHE_MAX_ENTRIES %d: must not be negative", c.MaxEntries)
+	}
+	pin := ""
+	switch {
+	case c.PinSeason < 0:
+		return nil, fmt.Errorf("invalid CACHE_PIN_SEASON %d: must be a season year", c.PinSeason)
+	case c.PinSeason > 0:
+		pin = strconv.Itoa(c.PinSeason)
+	}
+	return newWeekCache(c.MaxEntries, int(c.MaxBytes), pin), nil
+}
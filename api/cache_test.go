@@ -0,0 +1,125 @@
+package api
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWeekCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	useFakeClock(t)
+	c := newWeekCache(2, 0, "2024")
+	entry := func(size int) cacheEntry {
+		return cacheEntry{games: []GameStats{{ID: "g"}}, size: size}
+	}
+	c.set("2023/1.json", entry(10))
+	c.set("2023/2.json", entry(10))
+	c.get("2023/1.json", 0)
+	c.set("2023/3.json", entry(10))
+
+	if _, ok := c.peek("2023/2.json"); ok {
+		t.Error("expected the least recently used week to be evicted")
+	}
+	if !c.evicted("2023/2.json") {
+		t.Error("expected the evicted week to stay in the catalog")
+	}
+	if _, ok := c.peek("2023/1.json"); !ok {
+		t.Error("expected the recently read week to stay resident")
+	}
+	if n := len(c.weekCounts()); n != 3 {
+		t.Errorf("expected 3 weeks in the catalog, got %d", n)
+	}
+
+	// The pinned season stays whatever the budget
+	c.set("2024/1.json", entry(10))
+	c.set("2024/2.json", entry(10))
+	c.set("2024/3.json", entry(10))
+	stats := c.stats()
+	if stats.Entries != 3 || stats.PinnedSeason != "2024" {
+		t.Fatalf("expected the 3 pinned weeks alone, got %+v", stats)
+	}
+	for _, f := range stats.Files {
+		if !f.Pinned {
+			t.Errorf("expected %s to be pinned", f.File)
+		}
+	}
+	if stats.Hits != 1 || stats.Evictions != 3 || stats.Bytes != 30 {
+		t.Errorf("unexpected counters %+v", stats)
+	}
+}
+
+func TestWeekCacheByteBudget(t *testing.T) {
+	c := newWeekCache(0, 25, "2099")
+	c.set("2024/1.json", cacheEntry{size: 10})
+	c.set("2024/2.json", cacheEntry{size: 10})
+	c.set("2024/3.json", cacheEntry{size: 10})
+	if stats := c.stats(); stats.Entries != 2 || stats.Bytes != 20 {
+		t.Errorf("expected 2 entries of 20 bytes, got %+v", stats)
+	}
+
+	// A single week over the budget is still kept
+	c.set("2024/4.json", cacheEntry{size: 100})
+	if stats := c.stats(); stats.Entries != 1 || stats.Files[0].File != "2024/4.json" {
+		t.Errorf("expected the new week alone, got %+v", stats)
+	}
+}
+
+func TestEvictedWeekReloads(t *testing.T) {
+	dir := setupTestData(t)
+	os.MkdirAll(filepath.Join(dir, "2023"), 0755)
+	os.WriteFile(filepath.Join(dir, "2023", "1.json"), []byte(testData), 0644)
+	useTestStore(t, dir)
+	cache = newWeekCache(1, 0, "")
+	for _, name := range []string{"2023/1.json", "2024/1.json", "2024/2.json"} {
+		loadGameStats(name)
+	}
+
+	if seasons := availableSeasons(); len(seasons) != 2 || len(seasons[1].Weeks) != 2 {
+		t.Fatalf("expected evicted weeks to stay listed, got %+v", seasons)
+	}
+	if !cache.evicted("2023/1.json") {
+		t.Fatal("expected the older season to be evicted")
+	}
+	misses := cache.stats().Misses
+	games, err := loadGameStats("2023/1.json")
+	if err != nil || len(games) != 1 {
+		t.Fatalf("expected the evicted week to reload, got %v %v", games, err)
+	}
+	if stats := cache.stats(); stats.Misses != misses+1 {
+		t.Errorf("expected a miss, got %+v", stats)
+	}
+	if _, err := loadGameStats("2023/1.json"); err != nil || cache.stats().Hits == 0 {
+		t.Errorf("expected a hit on the reloaded week")
+	}
+}
+
+func TestCacheFromConfig(t *testing.T) {
+	for _, tt := range []struct {
+		env     map[string]string
+		entries int
+		bytes   int
+		wantErr bool
+	}{
+		{env: map[string]string{}},
+		{env: map[string]string{"CACHE_MAX_ENTRIES": "64", "CACHE_MAX_BYTES": "256MB"}, entries: 64, bytes: 256 << 20},
+		{env: map[string]string{"CACHE_MAX_BYTES": "512 kb"}, bytes: 512 << 10},
+		{env: map[string]string{"CACHE_MAX_BYTES": "1000"}, bytes: 1000},
+		{env: map[string]string{"CACHE_MAX_ENTRIES": "-1"}, wantErr: true},
+		{env: map[string]string{"CACHE_MAX_BYTES": "lots"}, wantErr: true},
+		{env: map[string]string{"CACHE_PIN_SEASON": "current"}, wantErr: true},
+	} {
+		var cfg Config
+		err := resolveConfig(&cfg, nil, lookupOf(tt.env))
+		var c *weekCache
+		if err == nil {
+			c, err = cacheFromConfig(cfg)
+		}
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%v: unexpected error %v", tt.env, err)
+			continue
+		}
+		if err == nil && (c.maxEntries != tt.entries || c.maxBytes != tt.bytes) {
+			t.Errorf("%v: expected %d entries and %d bytes, got %d and %d", tt.env, tt.entries, tt.bytes, c.maxEntries, c.maxBytes)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// CachePolicy describes the Cache-Control directives emitted for a route.
+// MaxAge applies to browsers, SMaxAge to shared caches such as CDNs, and
+// the stale-* directives let the edge keep serving while it revalidates
+// or while the origin is failing. All values are in seconds; zero omits
+// the directive.
+type CachePolicy struct {
+	MaxAge               int `json:"maxAge"`
+	SMaxAge              int `json:"sMaxAge"`
+	StaleWhileRevalidate int `json:"staleWhileRevalidate"`
+	StaleIfError         int `json:"staleIfError"`
+}
+
+// cachePolicies holds the policy of each route class. Week, game, team and
+// search data only change when new data is published; crawler, bulk and raw
+// season dumps are large and can be held at the edge for a full day. 404s
+// for missing weeks are only cached briefly since the week may be published
+// at any time. Vote standings change with every vote and are only held
+// for a few seconds. Feeds are polled by readers and follow new weeks
+// within 15 minutes. Live ratings are refreshed every few minutes and only
+// held for one.
+var cachePolicies = map[string]CachePolicy{
+	"week":    {MaxAge: 3600, SMaxAge: 3600, StaleWhileRevalidate: 600, StaleIfError: 86400},
+	"game":    {MaxAge: 3600, SMaxAge: 3600, StaleWhileRevalidate: 600, StaleIfError: 86400},
+	"season":  {MaxAge: 3600, SMaxAge: 3600, StaleWhileRevalidate: 600, StaleIfError: 86400},
+	"team":    {MaxAge: 3600, SMaxAge: 3600, StaleWhileRevalidate: 600, StaleIfError: 86400},
+	"search":  {MaxAge: 3600, SMaxAge: 3600, StaleWhileRevalidate: 600, StaleIfError: 86400},
+	"raw":     {MaxAge: 86400, SMaxAge: 86400, StaleWhileRevalidate: 3600, StaleIfError: 604800},
+	"bulk":    {MaxAge: 86400, SMaxAge: 86400, StaleWhileRevalidate: 3600, StaleIfError: 604800},
+	"crawler": {MaxAge: 86400, SMaxAge: 86400, StaleWhileRevalidate: 3600, StaleIfError: 604800},
+	"robots":  {MaxAge: 86400, SMaxAge: 86400},
+	"meta":    {MaxAge: 86400, SMaxAge: 86400},
+	"missing": {MaxAge: 60, SMaxAge: 60},
+	"votes":   {MaxAge: 10, SMaxAge: 10},
+	"feed":    {MaxAge: 900, SMaxAge: 900, StaleWhileRevalidate: 600, StaleIfError: 86400},
+	"live":    {MaxAge: 60, SMaxAge: 60},
+}
+
+// header renders the policy as a Cache-Control value
+func (p CachePolicy) header() string {
+	parts := []string{"public", "max-age=" + strconv.Itoa(p.MaxAge)}
+	if p.SMaxAge > 0 {
+		parts = append(parts, "s-maxage="+strconv.Itoa(p.SMaxAge))
+	}
+	if p.StaleWhileRevalidate > 0 {
+		parts = append(parts, "stale-while-revalidate="+strconv.Itoa(p.StaleWhileRevalidate))
+	}
+	if p.StaleIfError > 0 {
+		parts = append(parts, "stale-if-error="+strconv.Itoa(p.StaleIfError))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// setCacheHeaders applies the policy of route to the response
+func setCacheHeaders(w http.ResponseWriter, route string) {
+	p, ok := cachePolicies[route]
+	if !ok {
+		p = CachePolicy{MaxAge: 3600}
+	}
+	w.Header().Set("Cache-Control", p.header())
+}
+
+// loadCachePolicies overrides the default policies with the routes found
+// in the JSON object at path, e.g. {"week": {"maxAge": 60, "sMaxAge": 600}}
+func loadCachePolicies(path string) error {
+	data, err := readConfigFile(path)
+	if err != nil {
+		return err
+	}
+	var overrides map[string]CachePolicy
+	if err := json.Unmarshal(data, &overrides); err != nil {
+		return fmt.Errorf("parse %s: %w", path, err)
+	}
+	for route, p := range overrides {
+		if _, ok := cachePolicies[route]; !ok {
+			return fmt.Errorf("unknown cache policy route %q", route)
+		}
+		cachePolicies[route] = p
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package api
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCachePolicyHeader(t *testing.T) {
+	p := CachePolicy{MaxAge: 60, SMaxAge: 600, StaleWhileRevalidate: 30, StaleIfError: 86400}
+	want := "public, max-age=60, s-maxage=600, stale-while-revalidate=30, stale-if-error=86400"
+	if got := p.header(); got != want {
+		t.Errorf("header() = %q, want %q", got, want)
+	}
+
+	if got := (CachePolicy{MaxAge: 60}).header(); got != "public, max-age=60" {
+		t.Errorf("expected zero directives to be omitted, got %q", got)
+	}
+}
+
+func TestLoadCachePolicies(t *testing.T) {
+	saved := make(map[string]CachePolicy, len(cachePolicies))
+	for k, v := range cachePolicies {
+		saved[k] = v
+	}
+	t.Cleanup(func() { cachePolicies = saved })
+
+	path := filepath.Join(t.TempDir(), "cache.json")
+	if err := os.WriteFile(path, []byte(`{"week": {"maxAge": 60, "sMaxAge": 600}}`), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	if err := loadCachePolicies(path); err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if cachePolicies["week"].SMaxAge != 600 {
+		t.Errorf("expected week s-maxage override, got %+v", cachePolicies["week"])
+	}
+
+	if err := os.WriteFile(path, []byte(`{"nope": {"maxAge": 1}}`), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	if err := loadCachePolicies(path); err == nil {
+		t.Error("expected error for unknown route")
+	}
+}
@@ -0,0 +1,238 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jjway/rewatchableGamesApi-go/store"
+)
+
+const (
+	// calendarWeeks and calendarGames are the default number of recent
+	// weeks of /calendar.ics and of games suggested in each
+	calendarWeeks = 4
+	calendarGames = 3
+)
+
+// calendarStreamURL is the template of the streaming link of the events,
+// from CALENDAR_STREAM_URL, with {id}, {season}, {week} and {slug}
+// replaced by those of the game. The events link to the page of the feed
+// items when it is unset.
+var calendarStreamURL string
+
+// calendarEvent is the rewatch suggestion of a game, on the Tuesday
+// following its week
+type calendarEvent struct {
+	UID         string
+	Date        time.Time
+	Summary     string
+	Description string
+	URL         string
+}
+
+// seasonOpener returns the Thursday of the first game of an NFL season,
+// the one following Labor Day, the first Monday of September
+func seasonOpener(season int) time.Time {
+	day := time.Date(season, time.September, 1, 0, 0, 0, 0, time.UTC)
+	for day.Weekday() != time.Monday {
+		day = day.AddDate(0, 0, 1)
+	}
+	return day.AddDate(0, 0, 3)
+}
+
+// rewatchDate returns the Tuesday after the games of a week, once the
+// Monday night game is played. The week before the Super Bowl is off.
+func rewatchDate(season, week string) (time.Time, bool) {
+	year, err := strconv.Atoi(season)
+	order, ok := weekOrder(week)
+	if err != nil || !ok {
+		return time.Time{}, false
+	}
+	if week == "superbowl" {
+		order++
+	}
+	return seasonOpener(year).AddDate(0, 0, 7*(order-1)+5), true
+}
+
+// recentWeeks returns the n most recent cached weeks, oldest first
+func recentWeeks(n int) []gameLocation {
+	var weeks []gameLocation
+	for _, s := range availableSeasons() {
+		for _, w := range s.Weeks {
+			weeks = append(weeks, gameLocation{Season: s.Season, Week: w.Week})
+		}
+	}
+	if len(weeks) > n {
+		weeks = weeks[len(weeks)-n:]
+	}
+	return weeks
+}
+
+// buildCalendar suggests the perWeek best games, rated by rater, of each
+// of the recent weeks, described in lang. updated is when the most
+// recently loaded of these weeks was.
+func buildCalendar(r *http.Request, rater Rater, lang string, weeks, perWeek int) (events []calendarEvent, updated time.Time) {
+	site := feedSiteURL
+	if site == "" {
+		site = requestBaseURL(r)
+	}
+	for _, loc := range recentWeeks(weeks) {
+		date, ok := rewatchDate(loc.Season, loc.Week)
+		if !ok {
+			continue
+		}
+		name := store.WeekFile(loc.Season, loc.Week)
+		games, err := loadGameStats(name)
+		if err != nil {
+			continue
+		}
+		if loaded := cacheLoadedAt(name); loaded.After(updated) {
+			updated = loaded
+		}
+
+		processed := processGames(rater, games)
+		kept := processed[:0]
+		for _, p := range processed {
+			if p.ID != "" {
+				p.setLocation(loc.Season, loc.Week)
+				kept = append(kept, p)
+			}
+		}
+		sort.SliceStable(kept, func(i, j int) bool { return kept[i].TotalRating > kept[j].TotalRating })
+		if len(kept) > perWeek {
+			kept = kept[:perWeek]
+		}
+
+		label := localWeekLabel(lang, loc.Week)
+		for _, g := range spoilerFreeGames(kept) {
+			link := site + "/g/" + g.Slug
+			stream := link
+			if calendarStreamURL != "" {
+				stream = strings.NewReplacer("{id}", g.ID, "{season}", g.Season, "{week}", g.Week, "{slug}", g.Slug).Replace(calendarStreamURL)
+			}
+			events = append(events, calendarEvent{
+				UID:     loc.Season + "-" + loc.Week + "-" + g.ID + "@rewatchable-games",
+				Date:    date,
+				Summary: translate(lang, "calendar.event", g.ShortName, g.TotalRating),
+				Description: translate(lang, "feed.item",
+					g.FullName, label, loc.Season, g.TotalRating, localQuality(lang, g.MatchupQuality)) +
+					"\n" + translate(lang, "calendar.watch", stream),
+				URL: link,
+			})
+		}
+	}
+	return events, updated
+}
+
+// icsEscape escapes a TEXT value of RFC 5545
+var icsEscape = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`)
+
+// writeICSLine writes a content line, folded at 75 octets without
+// splitting a UTF-8 sequence
+func writeICSLine(b *bytes.Buffer, line string) {
+	for limit := 75; len(line) > limit; limit = 74 {
+		cut := limit
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		b.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+	}
+	b.WriteString(line + "\r\n")
+}
+
+// renderCalendar renders the events as an iCalendar document
+func renderCalendar(events []calendarEvent, lang string, updated time.Time) []byte {
+	if updated.IsZero() {
+		updated = time.Unix(0, 0)
+	}
+	stamp := updated.UTC().Format("20060102T150405Z")
+	refresh := fmt.Sprintf("PT%dM", cachePolicies["feed"].MaxAge/60)
+
+	var b bytes.Buffer
+	for _, line := range []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//Rewatchable Games//Rewatch suggestions//" + strings.ToUpper(lang),
+		"CALSCALE:GREGORIAN",
+		"METHOD:PUBLISH",
+		"X-WR-CALNAME:" + icsEscape.Replace(translate(lang, "calendar.name")),
+		"X-WR-CALDESC:" + icsEscape.Replace(translate(lang, "calendar.description")),
+		"REFRESH-INTERVAL;VALUE=DURATION:" + refresh,
+		"X-PUBLISHED-TTL:" + refresh,
+	} {
+		writeICSLine(&b, line)
+	}
+	for _, e := range events {
+		for _, line := range []string{
+			"BEGIN:VEVENT",
+			"UID:" + e.UID,
+			"DTSTAMP:" + stamp,
+			"DTSTART;VALUE=DATE:" + e.Date.Format("20060102"),
+			"DTEND;VALUE=DATE:" + e.Date.AddDate(0, 0, 1).Format("20060102"),
+			"SUMMARY:" + icsEscape.Replace(e.Summary),
+			"DESCRIPTION:" + icsEscape.Replace(e.Description),
+			"URL:" + e.URL,
+			"TRANSP:TRANSPARENT",
+			"END:VEVENT",
+		} {
+			writeICSLine(&b, line)
+		}
+	}
+	writeICSLine(&b, "END:VCALENDAR")
+	return b.Bytes()
+}
+
+// parseCount reads a positive integer query parameter of at most max,
+// def when missing
+func parseCount(r *http.Request, param string, def, max int) (int, *QueryError) {
+	v := r.URL.Query().Get(param)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 || n > max {
+		return 0, &QueryError{Param: param, Value: v, Message: "must be an integer between 1 and " + strconv.Itoa(max)}
+	}
+	return n, nil
+}
+
+// handleCalendar serves /calendar.ics, the ?n= best games of each of the
+// ?weeks= most recent weeks as all-day events without spoilers, for
+// calendar apps to subscribe to
+func handleCalendar(w http.ResponseWriter, r *http.Request) {
+	rater, qerr := raterFor(r)
+	if qerr != nil {
+		writeQueryError(w, r, qerr)
+		return
+	}
+	lang, qerr := requestLang(w, r)
+	if qerr != nil {
+		writeQueryError(w, r, qerr)
+		return
+	}
+	weeks, qerr := parseCount(r, "weeks", calendarWeeks, regularSeasonWeeks+len(postseasonWeeks))
+	if qerr != nil {
+		writeQueryError(w, r, qerr)
+		return
+	}
+	perWeek, qerr := parseCount(r, "n", calendarGames, feedGames)
+	if qerr != nil {
+		writeQueryError(w, r, qerr)
+		return
+	}
+
+	events, updated := buildCalendar(r, rater, lang, weeks, perWeek)
+	body := renderCalendar(events, lang, updated)
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	setCacheHeaders(w, "feed")
+	if notModified(w, r, etagFor(body), updated) {
+		return
+	}
+	w.Write(body)
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRewatchDate(t *testing.T) {
+	for _, tt := range []struct{ season, week, want string }{
+		{"2024", "1", "2024-09-10"},
+		{"2024", "18", "2025-01-07"},
+		{"2024", "conference", "2025-01-28"},
+		{"2024", "superbowl", "2025-02-11"},
+		{"2021", "1", "2021-09-14"},
+	} {
+		date, ok := rewatchDate(tt.season, tt.week)
+		if !ok || date.Format("2006-01-02") != tt.want {
+			t.Errorf("%s week %s: expected %s, got %s", tt.season, tt.week, tt.want, date.Format("2006-01-02"))
+		}
+	}
+	if _, ok := rewatchDate("2024", "bowls"); ok {
+		t.Error("expected no date for an unknown week")
+	}
+}
+
+func TestWriteICSLine(t *testing.T) {
+	var b bytes.Buffer
+	writeICSLine(&b, "SUMMARY:"+strings.Repeat("é", 50))
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\r\n"), "\r\n")
+	if len(lines) != 2 || len(lines[0]) > 75 || !strings.HasPrefix(lines[1], " ") {
+		t.Fatalf("expected a folded line, got %q", lines)
+	}
+	if unfolded := lines[0] + lines[1][1:]; unfolded != "SUMMARY:"+strings.Repeat("é", 50) {
+		t.Errorf("expected the folding to keep the UTF-8 sequences, got %q", unfolded)
+	}
+}
+
+func TestCalendar(t *testing.T) {
+	useFakeClock(t)
+	useTestStore(t, setupTestData(t))
+	preloadCache(dataStore)
+	old := calendarStreamURL
+	calendarStreamURL = "https://stream.example/{season}/{week}/{id}"
+	t.Cleanup(func() { calendarStreamURL = old })
+
+	get := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handleCalendar(rec, httptest.NewRequest("GET", target, nil))
+		return rec
+	}
+
+	rec := get("/calendar.ics")
+	body := rec.Body.String()
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/calendar") {
+		t.Fatalf("unexpected response %d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if strings.Count(body, "BEGIN:VEVENT") != 2 || !strings.HasSuffix(body, "END:VCALENDAR\r\n") {
+		t.Fatalf("expected an event for each week, got %s", body)
+	}
+	for _, want := range []string{
+		"UID:2024-2-game1@rewatchable-games",
+		"DTSTART;VALUE=DATE:20240917",
+		"SUMMARY:Rewatch A @ B (21.5)",
+		"Watch: https://stream.example/2024/2/game1",
+	} {
+		if !strings.Contains(strings.ReplaceAll(body, "\r\n ", ""), want) {
+			t.Errorf("expected %q in %s", want, body)
+		}
+	}
+	if strings.Contains(body, "points") {
+		t.Errorf("expected no spoiler, got %s", body)
+	}
+
+	if rec := get("/calendar.ics?weeks=1&lang=fr"); strings.Count(rec.Body.String(), "BEGIN:VEVENT") != 1 || !strings.Contains(rec.Body.String(), "À revoir") {
+		t.Errorf("expected the last week in French, got %s", rec.Body)
+	}
+	for _, target := range []string{"/calendar.ics?weeks=0", "/calendar.ics?n=11", "/calendar.ics?algo=v9"} {
+		if rec := get(target); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", target, rec.Code)
+		}
+	}
+}
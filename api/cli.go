@@ -0,0 +1,239 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/jjway/rewatchableGamesApi-go/store"
+)
+
+// commands are the subcommands of the binary. Without one it serves the
+// API, as it always has.
+var commands = map[string]func(args []string) error{
+	"serve":          runServe,
+	"validate":       runValidate,
+	"rate":           runRate,
+	"ingest":         runIngest,
+	"compact":        runCompact,
+	"backfill":       runBackfill,
+	"support-bundle": runSupportBundle,
+	"perfcheck":      runPerfcheck,
+}
+
+// runCommand runs the subcommand named by the first of args, serve when
+// there is none
+func RunCommand(args []string) error {
+	name := "serve"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	run, ok := commands[name]
+	if !ok {
+		names := make([]string, 0, len(commands))
+		for name := range commands {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		return errors.New(translate(envLang(), "cli.usage", name, strings.Join(names, ", ")))
+	}
+	return run(args)
+}
+
+// validateStore checks every week file of s against the schema of
+// GameStats, as strictly as ingestion does, and its games as the load
+// does, then the invariants of checkConsistency
+func validateStore(s store.Store) (ConsistencyReport, error) {
+	names, err := s.ListFiles()
+	if err != nil {
+		return ConsistencyReport{}, err
+	}
+	var schema []Violation
+	for _, name := range names {
+		season, week, _ := strings.Cut(strings.TrimSuffix(name, ".json"), "/")
+		data, err := s.ReadFile(name)
+		if err != nil {
+			// checkConsistency reports the unreadable files
+			continue
+		}
+		games, err := decodeWeekFile(name, data)
+		if err != nil {
+			continue
+		}
+		var strict []GameStats
+		if err := strictJSON.Unmarshal(data, &strict); err != nil {
+			schema = append(schema, Violation{Season: season, Week: week, Check: "schema", Message: err.Error()})
+		}
+		for _, issue := range validateGames(games) {
+			schema = append(schema, Violation{Season: season, Week: week, Check: issue.Rule, Message: fmt.Sprintf("game %d (%s): %s", issue.Index, issue.GameID, issue.Message)})
+		}
+	}
+
+	report, err := checkConsistency(s)
+	if err != nil {
+		return report, err
+	}
+	report.Violations = append(schema, report.Violations...)
+	return report, nil
+}
+
+// runValidate checks the week files of a data directory and reports every
+// problem, failing when there is one
+func runValidate(args []string) error {
+	fset := flag.NewFlagSet("validate", flag.ContinueOnError)
+	format := fset.String("format", "text", "report format, text or json")
+	langArg := langFlag(fset)
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+	lang, err := checkLang(*langArg)
+	if err != nil {
+		return err
+	}
+	if fset.NArg() != 1 || (*format != "text" && *format != "json") {
+		return errors.New(translate(lang, "cli.validate.usage"))
+	}
+	dir := fset.Arg(0)
+	if info, err := os.Stat(dir); err != nil {
+		return err
+	} else if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+
+	// validateStore reports the issues of the games, not the load
+	validations.setMode(validationOff)
+	dataStore = store.NewDir(dir)
+	report, err := validateStore(dataStore)
+	if err != nil {
+		return err
+	}
+	if err := printValidation(os.Stdout, report, *format); err != nil {
+		return err
+	}
+	if n := len(report.Violations); n > 0 {
+		return errors.New(translate(lang, "cli.validate.failed", n, report.Files))
+	}
+	fmt.Fprintln(os.Stderr, translate(lang, "cli.validate.ok", report.Files, report.Seasons))
+	return nil
+}
+
+// printValidation writes the report as JSON, or a line per problem
+func printValidation(w io.Writer, report ConsistencyReport, format string) error {
+	if format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+	for _, v := range report.Violations {
+		file := v.Season
+		if v.Week != "" {
+			file += "/" + v.Week
+		}
+		if _, err := fmt.Fprintf(w, "%s [%s] %s\n", file, v.Check, v.Message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runRate prints the ratings of the games of a week file, ordered as the
+// API orders them
+func runRate(args []string) error {
+	fset := flag.NewFlagSet("rate", flag.ContinueOnError)
+	algo := fset.String("algo", defaultAlgorithm, "rating algorithm, one of "+strings.Join(algorithmNames(), ", "))
+	format := fset.String("format", formatJSON, "output format, json or csv")
+	sortBy := fset.String("sort", "", "sort field, as ?sort= of the API")
+	langArg := langFlag(fset)
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+	lang, err := checkLang(*langArg)
+	if err != nil {
+		return err
+	}
+	if fset.NArg() != 1 || (*format != formatJSON && *format != formatCSV) {
+		return errors.New(translate(lang, "cli.rate.usage"))
+	}
+	rater, ok := raters[*algo]
+	if !ok {
+		return fmt.Errorf("unknown algorithm %q: must be one of %s", *algo, strings.Join(algorithmNames(), ", "))
+	}
+	values := url.Values{}
+	if *sortBy != "" {
+		values.Set("sort", *sortBy)
+	}
+	query, qerr := parseGameQuery(values)
+	if qerr != nil {
+		return fmt.Errorf("-sort: %s", qerr.Message)
+	}
+
+	path := fset.Arg(0)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	games, err := decodeWeekFile(path, data)
+	if err != nil {
+		return err
+	}
+	processed, _ := query.explain(processGames(rater, games))
+
+	if *format == formatCSV {
+		return writeGameCSV(os.Stdout, processed, false)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(processed)
+}
+
+// runIngest fetches a week from ESPN once into a data directory, the
+// current week unless -year and -week say otherwise
+func runIngest(args []string) error {
+	fset := flag.NewFlagSet("ingest", flag.ContinueOnError)
+	dir := fset.String("data", "data", "data directory")
+	year := fset.String("year", "", "season of the week to fetch")
+	week := fset.String("week", "", "week or playoff round to fetch")
+	dryRun := fset.Bool("dry-run", false, "print the fetched games instead of storing them")
+	langArg := langFlag(fset)
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+	lang, err := checkLang(*langArg)
+	if err != nil {
+		return err
+	}
+	if fset.NArg() != 0 || (*year == "") != (*week == "") || (*week != "" && !isValidWeek(*week)) {
+		return errors.New(translate(lang, "cli.ingest.usage"))
+	}
+
+	f := newESPNFetcher(espnAPIURL)
+	ctx := context.Background()
+	if *dryRun {
+		_, games, err := f.fetchGames(ctx, *year, *week)
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(games)
+	}
+
+	ws := store.NewDir(*dir)
+	dataStore = ws
+	result, err := f.fetchWeek(ctx, ws, *year, *week)
+	if err != nil {
+		return err
+	}
+	key := "cli.ingest.done"
+	if !result.Stored {
+		key = "cli.ingest.skipped"
+	}
+	fmt.Fprintln(os.Stderr, translate(lang, key, result.Week, result.Season, result.Completed, result.Games))
+	return nil
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunCommand(t *testing.T) {
+	err := RunCommand([]string{"bogus"})
+	if err == nil || !strings.Contains(err.Error(), "backfill, compact, ingest, perfcheck, rate, serve, support-bundle, validate") {
+		t.Errorf("expected the list of commands, got %v", err)
+	}
+	for _, args := range [][]string{
+		{"validate"},
+		{"validate", "-format", "xml", "data"},
+		{"rate"},
+		{"rate", "-format", "ndjson", "data/2024/1.json"},
+		{"ingest", "-year", "2024"},
+		{"ingest", "-year", "2024", "-week", "twenty"},
+	} {
+		if err := RunCommand(args); err == nil || !strings.Contains(err.Error(), "usage") {
+			t.Errorf("%v: expected the usage, got %v", args, err)
+		}
+	}
+}
+
+func TestValidateStore(t *testing.T) {
+	dir := setupTestData(t)
+	useTestStore(t, dir)
+	os.WriteFile(filepath.Join(dir, "2024", "3.json"), []byte(`[{"id": "x", "shortName": "A @ B", "offense": {"totalPoints": "many"}}]`), 0644)
+	os.WriteFile(filepath.Join(dir, "2024", "4.json"), []byte(`[{"id": "y", "shortName": "A @ B", "colour": "red"}]`), 0644)
+
+	report, err := validateStore(dataStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checks := make(map[string]string)
+	for _, v := range report.Violations {
+		checks[v.Season+"/"+v.Week] += v.Check + " "
+	}
+	if !strings.Contains(checks["2024/3"], "readable") || !strings.Contains(checks["2024/4"], "schema") {
+		t.Errorf("expected the bad type and the unknown field to be reported, got %+v", report.Violations)
+	}
+	if strings.Contains(checks["2024/1"], "schema") {
+		t.Errorf("expected no schema problem in a valid week, got %q", checks["2024/1"])
+	}
+
+	var out bytes.Buffer
+	if err := printValidation(&out, report, "text"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "2024/4 [schema] ") {
+		t.Errorf("expected a line per problem, got %s", out.String())
+	}
+}
+
+func TestRunIngest(t *testing.T) {
+	// The test store is restored, with the cache, after the command
+	// replaced it
+	useTestStore(t, setupTestData(t))
+	dir := t.TempDir()
+	useESPN(t, newTestESPN(t).URL)
+	t.Cleanup(func() { unindexFile("2024/5.json"); setWeekState("2024/5.json", "") })
+
+	if err := runIngest([]string{"-data", dir}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "2024", "5.json"))
+	if err != nil || !strings.Contains(string(data), `"401"`) {
+		t.Errorf("expected the fetched week to be stored, got %s, %v", data, err)
+	}
+}
@@ -0,0 +1,16 @@
+package api
+
+import "time"
+
+// Clock abstracts the current time so tests can control cache expiry and
+// rate limiting deterministically
+type Clock interface {
+	Now() time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+// clock is the time source used by the cache and limiters
+var clock Clock = realClock{}
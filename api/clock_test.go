@@ -0,0 +1,71 @@
+package api
+
+import (
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/jjway/rewatchableGamesApi-go/store"
+)
+
+// fakeClock is a Clock that only moves when advanced
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+// useFakeClock installs a fakeClock for the duration of the test
+func useFakeClock(t *testing.T) *fakeClock {
+	t.Helper()
+	c := &fakeClock{now: time.Date(2024, 9, 8, 12, 0, 0, 0, time.UTC)}
+	old := clock
+	clock = c
+	t.Cleanup(func() { clock = old })
+	return c
+}
+
+func TestCacheTTLExpiry(t *testing.T) {
+	c := useFakeClock(t)
+	fsys := fstest.MapFS{"2024/1.json": {Data: []byte(testData)}}
+
+	cache = newWeekCache(0, 0, "")
+	oldStore, oldTTL := dataStore, cacheTTL
+	dataStore, cacheTTL = store.NewFS(fsys), time.Hour
+	t.Cleanup(func() { dataStore, cacheTTL = oldStore, oldTTL })
+
+	if _, err := loadGameStats("2024/1.json"); err != nil {
+		t.Fatalf("first load failed: %v", err)
+	}
+
+	// Still fresh: served from cache even though the file is gone
+	delete(fsys, "2024/1.json")
+	c.Advance(59 * time.Minute)
+	if _, err := loadGameStats("2024/1.json"); err != nil {
+		t.Fatalf("expected cached load within TTL, got %v", err)
+	}
+
+	// Expired: re-read from the store, which now reports it missing
+	c.Advance(2 * time.Minute)
+	if _, err := loadGameStats("2024/1.json"); err == nil {
+		t.Fatal("expected re-read after TTL expiry")
+	}
+}
+
+func TestRateLimiterRefillsWithClock(t *testing.T) {
+	c := useFakeClock(t)
+	l := newRateLimiter(1, 1)
+
+	if !l.allow("client") {
+		t.Fatal("expected first request to pass")
+	}
+	if l.allow("client") {
+		t.Fatal("expected bucket to be empty")
+	}
+	c.Advance(time.Second)
+	if !l.allow("client") {
+		t.Error("expected a token after one second")
+	}
+}
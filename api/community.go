@@ -0,0 +1,180 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"sync"
+
+	"github.com/jjway/rewatchableGamesApi-go/store"
+)
+
+// GameVote is the body of POST /games/{id}/votes: a thumb, up or down, or
+// 1 to 5 stars. A thumb up counts as 5 stars and a thumb down as 1.
+type GameVote struct {
+	Thumb string `json:"thumb,omitempty"`
+	Stars int    `json:"stars,omitempty"`
+}
+
+// stars returns the vote on the 1 to 5 scale, false when it is not one
+// valid vote
+func (v GameVote) stars() (int, bool) {
+	switch {
+	case v.Thumb != "" && v.Stars != 0:
+		return 0, false
+	case v.Thumb == "up":
+		return 5, true
+	case v.Thumb == "down":
+		return 1, true
+	case v.Thumb == "" && v.Stars >= 1 && v.Stars <= 5:
+		return v.Stars, true
+	}
+	return 0, false
+}
+
+// CommunityScore is the response of POST /games/{id}/votes
+type CommunityScore struct {
+	ID              string   `json:"id"`
+	Season          string   `json:"season"`
+	Week            string   `json:"week"`
+	CommunityRating *float64 `json:"communityRating,omitempty"`
+	CommunityVotes  int      `json:"communityVotes"`
+}
+
+// communityBook holds the stars each voter gave each game, saved to its
+// repository, by game, after each change
+type communityBook struct {
+	mu    sync.Mutex
+	repo  Repository
+	games map[string]map[string]int
+}
+
+// community is the community book of the server, persisted to the user
+// data repository
+var community = newCommunityBook(newFileRepository(nil))
+
+func newCommunityBook(repo Repository) *communityBook {
+	return &communityBook{repo: repo, games: make(map[string]map[string]int)}
+}
+
+// loadCommunityBook reads the votes saved in repo
+func loadCommunityBook(repo Repository) (*communityBook, error) {
+	b := newCommunityBook(repo)
+	docs, err := repo.Load(context.Background(), communityCollection)
+	if err != nil {
+		return nil, err
+	}
+	for id, doc := range docs {
+		var stars map[string]int
+		if err := json.Unmarshal(doc, &stars); err != nil {
+			return nil, fmt.Errorf("community votes of %s: %w", id, err)
+		}
+		b.games[id] = stars
+	}
+	return b, nil
+}
+
+// cast records the stars voter gave gameID, replacing a previous vote of
+// theirs
+func (b *communityBook) cast(gameID, voter string, stars int) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	next := make(map[string]int, len(b.games[gameID])+1)
+	for v, s := range b.games[gameID] {
+		next[v] = s
+	}
+	next[voter] = stars
+	data, err := json.Marshal(next)
+	if err != nil {
+		return err
+	}
+	if err := b.repo.Put(context.Background(), communityCollection, gameID, data); err != nil {
+		return err
+	}
+	b.games[gameID] = next
+	return nil
+}
+
+// rating returns the mean stars of gameID, rounded to a tenth, and the
+// number of votes. The rating is nil before the first vote.
+func (b *communityBook) rating(gameID string) (*float64, int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	stars := b.games[gameID]
+	if len(stars) == 0 {
+		return nil, 0
+	}
+	sum := 0
+	for _, s := range stars {
+		sum += s
+	}
+	mean := math.Round(float64(sum)/float64(len(stars))*10) / 10
+	return &mean, len(stars)
+}
+
+// handleGameVote records the vote of the request's voter, named as for
+// the season votes, for a game identified by any of its IDs. Voting again
+// replaces the vote.
+func handleGameVote(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	idIndexMu.RLock()
+	m, ok := lookupID("", id)
+	idIndexMu.RUnlock()
+	gameID := m.IDs["espn"]
+	if !ok || gameID == "" || m.Season == "" {
+		writeError(w, r, http.StatusNotFound, "unknown game "+id)
+		return
+	}
+	voter, err := requestVoter(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxVoteBytes))
+	if err != nil {
+		writeError(w, r, http.StatusRequestEntityTooLarge, "request body is too large")
+		return
+	}
+	var vote GameVote
+	if err := json.Unmarshal(data, &vote); err != nil {
+		writeError(w, r, http.StatusBadRequest, "request body must be a JSON object with a thumb or stars")
+		return
+	}
+	stars, ok := vote.stars()
+	if !ok {
+		writeError(w, r, http.StatusUnprocessableEntity, "a vote is a thumb, up or down, or 1 to 5 stars")
+		return
+	}
+	if dryRun, qerr := dryRunRequested(r); qerr != nil {
+		writeQueryError(w, r, qerr)
+		return
+	} else if dryRun {
+		rep := newDryRunReport("vote for game " + gameID)
+		rep.writeFile(community.repo.Location(communityCollection))
+		writeDryRun(w, r, rep)
+		return
+	}
+
+	if err := community.cast(gameID, voter, stars); err != nil {
+		log.Printf("Error: save community votes: %v", err)
+		writeError(w, r, http.StatusInternalServerError, "could not record the vote")
+		return
+	}
+	// The cached responses of the week and those built from every week
+	// carry the rating
+	responses.invalidate(store.WeekFile(m.Season, m.Week))
+	responses.invalidate(quantileResponses)
+	invalidateTopGames()
+
+	score := CommunityScore{ID: gameID, Season: m.Season, Week: m.Week}
+	score.CommunityRating, score.CommunityVotes = community.rating(gameID)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(score); err != nil {
+		writeError(w, r, http.StatusInternalServerError, "error encoding response")
+	}
+}
@@ -0,0 +1,105 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func useCommunityBook(t *testing.T, path string) {
+	t.Helper()
+	old := community
+	b, err := loadCommunityBook(newFileRepository(map[string]string{communityCollection: path}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	community = b
+	t.Cleanup(func() { community = old })
+}
+
+func TestGameVotes(t *testing.T) {
+	dir := setupTestData(t)
+	useTestStore(t, dir)
+	useAPIKeys(t, APIKey{Name: "frontend", Key: "read-key", Roles: []string{roleRead}})
+	path := filepath.Join(t.TempDir(), "community.json")
+	useCommunityBook(t, path)
+
+	os.WriteFile(filepath.Join(dir, "2024", "3.json"), []byte(`[
+		{"id": "g1", "shortName": "A @ B", "fullName": "A at B"},
+		{"id": "g2", "shortName": "C @ D", "fullName": "C at D"}
+	]`), 0644)
+	games, err := loadGameStats("2024/3.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	indexFile("2024/3.json", games)
+	t.Cleanup(func() { unindexFile("2024/3.json") })
+
+	mux := http.NewServeMux()
+	mux.Handle("POST /games/{id}/votes", requireRole(roleRead, http.HandlerFunc(handleGameVote)))
+	mux.HandleFunc("GET /games/{year}/{week}", handleGamesYearWeek)
+	do := func(method, target, voter, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("X-API-Key", "read-key")
+		if voter != "" {
+			req.Header.Set("X-Voter-ID", voter)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Warm the cached response the votes must invalidate
+	do("GET", "/games/2024/3?sort=communityRating", "", "")
+
+	for _, tt := range []struct {
+		target, voter, body string
+		status              int
+		rating              float64
+	}{
+		{"/games/g2/votes", "u1", `{"thumb": "up"}`, http.StatusOK, 5},
+		{"/games/g2/votes", "u2", `{"stars": 2}`, http.StatusOK, 3.5},
+		// Voting again replaces the vote, under any ID of the game
+		{"/games/2024-w3-c-d/votes", "u1", `{"stars": 4}`, http.StatusOK, 3},
+		{"/games/g2/votes", "u3", `{"stars": 6}`, http.StatusUnprocessableEntity, 0},
+		{"/games/g2/votes", "u3", `{"thumb": "up", "stars": 5}`, http.StatusUnprocessableEntity, 0},
+		{"/games/g2/votes", "u3", `not json`, http.StatusBadRequest, 0},
+		{"/games/nope/votes", "u3", `{"thumb": "down"}`, http.StatusNotFound, 0},
+	} {
+		rec := do("POST", tt.target, tt.voter, tt.body)
+		if rec.Code != tt.status {
+			t.Errorf("%s %s: expected status %d, got %d: %s", tt.target, tt.body, tt.status, rec.Code, rec.Body.String())
+			continue
+		}
+		if tt.status != http.StatusOK {
+			continue
+		}
+		var score CommunityScore
+		if err := json.Unmarshal(rec.Body.Bytes(), &score); err != nil {
+			t.Fatal(err)
+		}
+		if score.ID != "g2" || score.CommunityRating == nil || *score.CommunityRating != tt.rating {
+			t.Errorf("%s %s: expected a rating of %v, got %+v", tt.target, tt.body, tt.rating, score)
+		}
+	}
+
+	rec := do("GET", "/games/2024/3?sort=communityRating", "", "")
+	var list []ProcessedGameStats
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].ID != "g2" || list[0].CommunityVotes != 2 || list[1].CommunityRating != nil {
+		t.Errorf("expected the voted game first with its rating, got %+v", list)
+	}
+
+	reloaded, err := loadCommunityBook(newFileRepository(map[string]string{communityCollection: path}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rating, n := reloaded.rating("g2"); rating == nil || *rating != 3 || n != 2 {
+		t.Errorf("expected the votes to be saved, got %v from %d votes", rating, n)
+	}
+}
@@ -0,0 +1,173 @@
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jjway/rewatchableGamesApi-go/store"
+)
+
+// compactSeason merges the week files of dir/year, playoff rounds
+// included, into a season file and its index. Weeks must run from 1 to at
+// least 18 unless force is set.
+// With remove, the week files and the emptied year directory are deleted
+// once the season file is written.
+func compactSeason(dir, year string, force, remove bool) (store.SeasonIndex, error) {
+	var idx store.SeasonIndex
+	yearDir := filepath.Join(dir, year)
+	weeks, err := compactWeeks(dir, year, force)
+	if err != nil {
+		return idx, err
+	}
+
+	var season bytes.Buffer
+	for _, week := range weeks {
+		data, err := os.ReadFile(filepath.Join(yearDir, week+".json"))
+		if err != nil {
+			return idx, err
+		}
+		games, err := decodeWeekFile(store.WeekFile(year, week), data)
+		if err != nil {
+			return idx, err
+		}
+
+		offset := int64(season.Len())
+		zw := gzip.NewWriter(&season)
+		zw.Write(data)
+		if err := zw.Close(); err != nil {
+			return idx, err
+		}
+		idx.Weeks = append(idx.Weeks, store.SeasonIndexEntry{
+			Week:   week,
+			Offset: offset,
+			Length: int64(season.Len()) - offset,
+			Games:  len(games),
+		})
+	}
+
+	indexData, err := json.Marshal(idx)
+	if err != nil {
+		return idx, err
+	}
+	if err := store.WriteFileAtomic(filepath.Join(dir, year+store.SeasonFileExt), season.Bytes()); err != nil {
+		return idx, err
+	}
+	if err := store.WriteFileAtomic(filepath.Join(dir, year+store.SeasonIndexExt), indexData); err != nil {
+		return idx, err
+	}
+
+	if remove {
+		for _, week := range weeks {
+			if err := os.Remove(filepath.Join(yearDir, week+".json")); err != nil {
+				return idx, err
+			}
+		}
+		// Only succeeds if nothing else lives in the year directory
+		os.Remove(yearDir)
+	}
+	return idx, nil
+}
+
+// compactWeeks lists the week files of dir/year in week order, checking
+// the season is complete unless force is set
+func compactWeeks(dir, year string, force bool) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(dir, year))
+	if err != nil {
+		return nil, err
+	}
+	var weeks []string
+	regular := 0
+	for _, e := range entries {
+		week := strings.TrimSuffix(e.Name(), ".json")
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") || !isValidWeek(week) {
+			continue
+		}
+		weeks = append(weeks, week)
+		if !isPostseason(week) {
+			regular++
+		}
+	}
+	sort.Slice(weeks, func(i, j int) bool {
+		oi, _ := weekOrder(weeks[i])
+		oj, _ := weekOrder(weeks[j])
+		return oi < oj
+	})
+
+	if !force {
+		for i, w := range weeks[:regular] {
+			if w != strconv.Itoa(i+1) {
+				return nil, fmt.Errorf("season %s is missing week %d", year, i+1)
+			}
+		}
+		if regular < regularSeasonWeeks {
+			return nil, fmt.Errorf("season %s has %d weeks, not complete (use -force)", year, regular)
+		}
+	}
+	return weeks, nil
+}
+
+// compactDryRun reports the files compactSeason would write and remove
+func compactDryRun(dir, year string, force, remove bool) (*DryRunReport, error) {
+	weeks, err := compactWeeks(dir, year, force)
+	if err != nil {
+		return nil, err
+	}
+	rep := newDryRunReport("compact " + year)
+	rep.Writes = append(rep.Writes, filepath.Join(dir, year+store.SeasonFileExt), filepath.Join(dir, year+store.SeasonIndexExt))
+	if remove {
+		for _, week := range weeks {
+			rep.Deletes = append(rep.Deletes, filepath.Join(dir, year, week+".json"))
+		}
+	}
+	return rep, nil
+}
+
+// runCompact implements the compact command:
+//
+//	rewatchable compact [-data dir] [-force] [-keep] [-dry-run] [-lang lang] year...
+func runCompact(args []string) error {
+	fset := flag.NewFlagSet("compact", flag.ContinueOnError)
+	dir := fset.String("data", "data", "data directory")
+	force := fset.Bool("force", false, "compact incomplete seasons")
+	keep := fset.Bool("keep", false, "keep the week files after compaction")
+	dryRun := fset.Bool("dry-run", false, "print the files that would be written and removed")
+	langArg := langFlag(fset)
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+	lang, err := checkLang(*langArg)
+	if err != nil {
+		return err
+	}
+	if fset.NArg() == 0 {
+		return errors.New(translate(lang, "cli.compact.usage"))
+	}
+
+	for _, year := range fset.Args() {
+		if *dryRun {
+			rep, err := compactDryRun(*dir, year, *force, !*keep)
+			if err != nil {
+				return fmt.Errorf("compact %s: %w", year, err)
+			}
+			if err := printDryRun(os.Stdout, rep); err != nil {
+				return err
+			}
+			continue
+		}
+		idx, err := compactSeason(*dir, year, *force, !*keep)
+		if err != nil {
+			return fmt.Errorf("compact %s: %w", year, err)
+		}
+		log.Print(translate(lang, "cli.compact.done", year, len(idx.Weeks)))
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jjway/rewatchableGamesApi-go/store"
+)
+
+func TestCompactSeason(t *testing.T) {
+	dir := t.TempDir()
+	yearDir := filepath.Join(dir, "2023")
+	if err := os.MkdirAll(yearDir, 0755); err != nil {
+		t.Fatalf("failed to create year dir: %v", err)
+	}
+	for _, week := range append(seasonWeekNames()[:18], "wildcard") {
+		path := filepath.Join(yearDir, week+".json")
+		if err := os.WriteFile(path, []byte(testData), 0644); err != nil {
+			t.Fatalf("failed to write week: %v", err)
+		}
+	}
+
+	idx, err := compactSeason(dir, "2023", false, true)
+	if err != nil {
+		t.Fatalf("compact failed: %v", err)
+	}
+	if len(idx.Weeks) != 19 || idx.Weeks[0].Games != 1 || idx.Weeks[18].Week != "wildcard" {
+		t.Errorf("unexpected index %+v", idx)
+	}
+	if _, err := os.Stat(yearDir); !os.IsNotExist(err) {
+		t.Errorf("expected year directory to be removed, got %v", err)
+	}
+
+	// The store transparently reads the compacted layout
+	s := store.NewDir(dir)
+	names, err := s.ListFiles()
+	if err != nil || len(names) != 19 {
+		t.Fatalf("expected 19 compacted weeks, got %v: %v", names, err)
+	}
+	data, err := s.ReadFile("2023/12.json")
+	if err != nil {
+		t.Fatalf("read compacted week failed: %v", err)
+	}
+	if string(data) != testData {
+		t.Error("compacted week does not round-trip")
+	}
+	if _, err := s.ReadFile("2023/19.json"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected not-exist for unknown week, got %v", err)
+	}
+}
+
+func TestCompactRefusesIncompleteSeason(t *testing.T) {
+	dir := setupTestData(t)
+	if _, err := compactSeason(dir, "2024", false, true); err == nil {
+		t.Fatal("expected error for a 2-week season")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "2024", "1.json")); err != nil {
+		t.Errorf("week files must be untouched: %v", err)
+	}
+
+	if _, err := compactSeason(dir, "2024", true, false); err != nil {
+		t.Errorf("expected -force to compact: %v", err)
+	}
+}
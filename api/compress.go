@@ -0,0 +1,217 @@
+package api
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+)
+
+// minCompressSize is the smallest body worth compressing: below it the
+// encoding overhead outweighs the savings
+const minCompressSize = 1024
+
+// brotliLevel trades ratio for speed on bodies rendered per request
+const brotliLevel = 5
+
+// compressor is the part of gzip.Writer and brotli.Writer the middleware
+// uses, so either can be pooled and reset onto the next response
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+	Reset(io.Writer)
+}
+
+// compressors pool the writers by encoding. Both allocate hundreds of
+// kilobytes of state that a request would otherwise throw away.
+var compressors = map[string]*sync.Pool{
+	"br":   {New: func() any { return brotli.NewWriterLevel(io.Discard, brotliLevel) }},
+	"gzip": {New: func() any { return gzip.NewWriter(io.Discard) }},
+}
+
+// acceptedEncoding picks the encoding of an Accept-Encoding header: br
+// over gzip unless the client weighs gzip higher, "" for none
+func acceptedEncoding(header string) string {
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = f
+		}
+		if name == "*" {
+			name = "br"
+		}
+		if _, ok := compressors[name]; !ok || q <= 0 {
+			continue
+		}
+		if q > bestQ || q == bestQ && name == "br" {
+			best, bestQ = name, q
+		}
+	}
+	return best
+}
+
+// compressWriter compresses a response once it is known to be worth it:
+// a successful response reaching minCompressSize, or flushed before, that
+// is not already encoded. Smaller bodies and errors are sent as they are.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding    string
+	ifNoneMatch string // as the client sent it
+
+	status  int
+	buf     []byte
+	started bool       // the header was sent
+	zw      compressor // set once compressing
+}
+
+// compressible reports whether the response so far may be compressed
+func (w *compressWriter) compressible() bool {
+	if w.status < 200 || w.status >= 300 || w.status == http.StatusNoContent || w.status == http.StatusPartialContent {
+		return false
+	}
+	h := w.Header()
+	if h.Get("Content-Encoding") != "" {
+		return false
+	}
+	ct := h.Get("Content-Type")
+	return !strings.HasPrefix(ct, "image/") && !strings.HasPrefix(ct, "video/") && ct != "application/zip" && ct != "application/gzip"
+}
+
+func (w *compressWriter) WriteHeader(status int) {
+	if w.started || w.status != 0 {
+		return
+	}
+	if status < 200 {
+		// Informational responses go out at once
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	w.status = status
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if !w.started {
+		if !w.compressible() {
+			w.start(false)
+		} else if len(w.buf)+len(b) < minCompressSize {
+			w.buf = append(w.buf, b...)
+			return len(b), nil
+		} else {
+			w.start(true)
+		}
+	}
+	if w.zw != nil {
+		return w.zw.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// start sends the header, compressed or not, and the body buffered so far
+func (w *compressWriter) start(compress bool) {
+	w.started = true
+	h := w.Header()
+	etag := h.Get("ETag")
+	// A 304 revalidating a compressed body carries the ETag of that body
+	if w.status == http.StatusNotModified && etag != "" && etagMatches(w.ifNoneMatch, encodedETag(etag, w.encoding)) {
+		h.Set("ETag", encodedETag(etag, w.encoding))
+	}
+	if compress {
+		if etag != "" {
+			h.Set("ETag", encodedETag(etag, w.encoding))
+		}
+		h.Set("Content-Encoding", w.encoding)
+		h.Del("Content-Length")
+		w.zw = compressors[w.encoding].Get().(compressor)
+		w.zw.Reset(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	if len(w.buf) > 0 {
+		if w.zw != nil {
+			w.zw.Write(w.buf)
+		} else {
+			w.ResponseWriter.Write(w.buf)
+		}
+	}
+	w.buf = nil
+}
+
+// Flush sends what was written so far. A response flushed early is
+// streamed, so it is compressed whatever its size.
+func (w *compressWriter) Flush() {
+	if !w.started {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		w.start(w.compressible())
+	}
+	if w.zw != nil {
+		w.zw.Flush()
+	}
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// close ends the response, returning the writer to its pool
+func (w *compressWriter) close() {
+	if !w.started {
+		if w.status == 0 && w.buf == nil {
+			// Nothing was written: net/http sends the 200 itself
+			return
+		}
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		w.start(false)
+	}
+	if w.zw != nil {
+		w.zw.Close()
+		w.zw.Reset(io.Discard)
+		compressors[w.encoding].Put(w.zw)
+		w.zw = nil
+	}
+}
+
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// compressMiddleware compresses the responses with Brotli or gzip,
+// whichever the client prefers. A compressed body gets an ETag of its own,
+// that the conditional headers are matched against as the ETag of the
+// body it was compressed from.
+func compressMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		encoding := acceptedEncoding(r.Header.Get("Accept-Encoding"))
+		// Event streams are flushed event by event and stay uncompressed
+		if encoding == "" || r.Header.Get("Accept") == "text/event-stream" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressWriter{ResponseWriter: w, encoding: encoding, ifNoneMatch: r.Header.Get("If-None-Match")}
+		defer cw.close()
+		if r.Header.Get("If-None-Match") != "" || r.Header.Get("If-Match") != "" {
+			r = r.Clone(r.Context())
+			for _, name := range []string{"If-None-Match", "If-Match"} {
+				if v := r.Header.Get(name); v != "" {
+					r.Header.Set(name, decodeETags(v, encoding))
+				}
+			}
+		}
+		next.ServeHTTP(cw, r)
+	})
+}
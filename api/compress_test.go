@@ -0,0 +1,189 @@
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andybalholm/brotli"
+)
+
+func TestAcceptedEncoding(t *testing.T) {
+	for header, want := range map[string]string{
+		"":                        "",
+		"identity":                "",
+		"gzip":                    "gzip",
+		"gzip, deflate, br":       "br",
+		"br;q=0.5, gzip":          "gzip",
+		"br;q=0, gzip;q=0.1":      "gzip",
+		"*":                       "br",
+		"GZIP;q=1.0, deflate":     "gzip",
+		"gzip;q=0, br;q=0":        "",
+		"gzip;q=oops, br;q=0.001": "br",
+	} {
+		if got := acceptedEncoding(header); got != want {
+			t.Errorf("acceptedEncoding(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestCompressMiddleware(t *testing.T) {
+	large := strings.Repeat(`{"id": "game"},`, 200)
+	handler := compressMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/large":
+			w.Header().Set("Content-Type", "application/json")
+			// Written in pieces, crossing minCompressSize midway
+			for i := 0; i < len(large); i += 100 {
+				io.WriteString(w, large[i:min(i+100, len(large))])
+			}
+		case "/small":
+			io.WriteString(w, `{"ok": true}`)
+		case "/missing":
+			writeError(w, r, http.StatusNotFound, strings.Repeat("not found ", 200))
+		case "/not-modified":
+			w.WriteHeader(http.StatusNotModified)
+		}
+	}))
+	get := func(path, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Accept-Encoding", accept)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for _, encoding := range []string{"br", "gzip"} {
+		// Twice, the second time with a pooled writer
+		for range 2 {
+			rec := get("/large", encoding)
+			if rec.Header().Get("Content-Encoding") != encoding || rec.Header().Get("Vary") != "Accept-Encoding" {
+				t.Fatalf("%s: expected a compressed response varying on Accept-Encoding, got %v", encoding, rec.Header())
+			}
+			var r io.Reader
+			if encoding == "br" {
+				r = brotli.NewReader(rec.Body)
+			} else {
+				zr, err := gzip.NewReader(rec.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				r = zr
+			}
+			body, err := io.ReadAll(r)
+			if err != nil || string(body) != large {
+				t.Errorf("%s: expected the body back, got %d bytes, %v", encoding, len(body), err)
+			}
+		}
+	}
+
+	if rec := get("/large", "identity"); rec.Header().Get("Content-Encoding") != "" || rec.Body.String() != large || rec.Header().Get("Vary") != "Accept-Encoding" {
+		t.Errorf("expected an uncompressed response without gzip or br, got %v", rec.Header())
+	}
+	if rec := get("/small", "gzip"); rec.Header().Get("Content-Encoding") != "" || rec.Body.String() != `{"ok": true}` {
+		t.Errorf("expected a tiny body uncompressed, got %v %q", rec.Header(), rec.Body)
+	}
+	if rec := get("/missing", "gzip"); rec.Code != http.StatusNotFound || rec.Header().Get("Content-Encoding") != "" || !bytes.Contains(rec.Body.Bytes(), []byte("not found")) {
+		t.Errorf("expected the 404 uncompressed, got %d %v", rec.Code, rec.Header())
+	}
+	if rec := get("/not-modified", "br"); rec.Code != http.StatusNotModified || rec.Header().Get("Content-Encoding") != "" || rec.Body.Len() != 0 {
+		t.Errorf("expected a bare 304, got %d %v", rec.Code, rec.Header())
+	}
+}
+
+func TestCompressMiddlewareETags(t *testing.T) {
+	large := strings.Repeat(`{"id": "game"},`, 200)
+	handler := compressMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := large
+		if r.URL.Path == "/small" {
+			body = `{"ok": true}`
+		}
+		if notModified(w, r, etagFor([]byte(body)), time.Time{}) {
+			return
+		}
+		io.WriteString(w, body)
+	}))
+	get := func(path, accept, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Accept-Encoding", accept)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	identity := get("/large", "identity", "").Header().Get("ETag")
+	gz := get("/large", "gzip", "").Header().Get("ETag")
+	br := get("/large", "br", "").Header().Get("ETag")
+	if identity == "" || gz != encodedETag(identity, "gzip") || br != encodedETag(identity, "br") || gz == br {
+		t.Fatalf("expected an ETag per encoding, got %s, %s and %s", identity, gz, br)
+	}
+	if small := get("/small", "gzip", "").Header().Get("ETag"); small != etagFor([]byte(`{"ok": true}`)) {
+		t.Errorf("expected the ETag of an uncompressed body as it is, got %s", small)
+	}
+
+	for _, tt := range []struct {
+		accept, ifNoneMatch string
+		status              int
+		etag                string
+	}{
+		{"gzip", gz, http.StatusNotModified, gz},
+		{"gzip", `"other", W/` + gz, http.StatusNotModified, gz},
+		{"gzip", identity, http.StatusNotModified, identity},
+		{"gzip", br, http.StatusOK, gz},
+		{"br", br, http.StatusNotModified, br},
+		{"identity", gz, http.StatusOK, identity},
+		{"identity", identity, http.StatusNotModified, identity},
+	} {
+		rec := get("/large", tt.accept, tt.ifNoneMatch)
+		if rec.Code != tt.status || rec.Header().Get("ETag") != tt.etag {
+			t.Errorf("%s with %s: expected %d and ETag %s, got %d and %s", tt.accept, tt.ifNoneMatch, tt.status, tt.etag, rec.Code, rec.Header().Get("ETag"))
+		}
+	}
+}
+
+func TestCompressMiddlewareFlush(t *testing.T) {
+	handler := compressMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "first\n")
+		if err := http.NewResponseController(w).Flush(); err != nil {
+			t.Errorf("expected the flush to reach the connection, got %v", err)
+		}
+		io.WriteString(w, "second\n")
+	}))
+	req := httptest.NewRequest("GET", "/stream", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if !rec.Flushed || rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("expected a flushed gzip stream, got %v", rec.Header())
+	}
+	zr, _ := gzip.NewReader(rec.Body)
+	if body, _ := io.ReadAll(zr); string(body) != "first\nsecond\n" {
+		t.Errorf("expected both writes, got %q", body)
+	}
+}
+
+func BenchmarkCompressMiddleware(b *testing.B) {
+	body := []byte(strings.Repeat(`{"id": "game", "totalRating": 7.5},`, 500))
+	handler := compressMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(body)
+	}))
+	for _, encoding := range []string{"br", "gzip"} {
+		b.Run(encoding, func(b *testing.B) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("Accept-Encoding", encoding)
+			b.ReportAllocs()
+			for b.Loop() {
+				handler.ServeHTTP(httptest.NewRecorder(), req)
+			}
+		})
+	}
+}
@@ -0,0 +1,467 @@
+package api
+
+import (
+	"bytes"
+	"encoding"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config gathers the settings of the server. Each one is read, in order of
+// precedence, from its flag of serve, its environment variable, the YAML
+// file of -config or CONFIG_FILE, and its default. The flag of a setting
+// is its variable in lowercase with dashes, -data-dir for DATA_DIR.
+//
+// The components take the resolved settings when they start; none is
+// exported to the environment of the process. A zero value leaves a
+// setting at its default.
+type Config struct {
+	Server struct {
+		Port              int           `yaml:"port" env:"PORT"`
+		HTTPPort          string        `yaml:"httpPort" env:"HTTP_PORT"`
+		ShutdownTimeout   time.Duration `yaml:"shutdownTimeout" env:"SHUTDOWN_TIMEOUT"`
+		AccessLog         string        `yaml:"accessLog" env:"ACCESS_LOG"`
+		CORSOrigins       []string      `yaml:"corsOrigins" env:"CORS_ORIGINS"`
+		PrefetchHints     []string      `yaml:"prefetchHints" env:"PREFETCH_HINTS"`
+		TrustedProxies    []string      `yaml:"trustedProxies" env:"TRUSTED_PROXIES"`
+		FeedSiteURL       string        `yaml:"feedSiteUrl" env:"FEED_SITE_URL"`
+		CalendarStreamURL string        `yaml:"calendarStreamUrl" env:"CALENDAR_STREAM_URL"`
+	} `yaml:"server"`
+
+	TLS struct {
+		CertFile          string   `yaml:"certFile" env:"TLS_CERT_FILE"`
+		KeyFile           string   `yaml:"keyFile" env:"TLS_KEY_FILE"`
+		AutocertDomains   []string `yaml:"autocertDomains" env:"TLS_AUTOCERT_DOMAINS"`
+		AutocertEmail     string   `yaml:"autocertEmail" env:"TLS_AUTOCERT_EMAIL"`
+		AutocertCache     string   `yaml:"autocertCache" env:"TLS_AUTOCERT_CACHE"`
+		AutocertDirectory string   `yaml:"autocertDirectory" env:"TLS_AUTOCERT_DIRECTORY"`
+	} `yaml:"tls"`
+
+	Store struct {
+		Backend                        string `yaml:"backend" env:"STORE_BACKEND"`
+		DataDir                        string `yaml:"dataDir" env:"DATA_DIR"`
+		DataDirMode                    string `yaml:"dataDirMode" env:"DATA_DIR_MODE"`
+		SQLitePath                     string `yaml:"sqlitePath" env:"SQLITE_PATH"`
+		ObjectStoreURL                 string `yaml:"objectStoreUrl" env:"OBJECT_STORE_URL"`
+		ObjectStoreToken               string `yaml:"objectStoreToken" env:"OBJECT_STORE_TOKEN"`
+		ObjectStoreTokenFile           string `yaml:"objectStoreTokenFile" env:"OBJECT_STORE_TOKEN_FILE"`
+		ObjectStoreAccessKeyID         string `yaml:"objectStoreAccessKeyId" env:"OBJECT_STORE_ACCESS_KEY_ID"`
+		ObjectStoreSecretAccessKey     string `yaml:"objectStoreSecretAccessKey" env:"OBJECT_STORE_SECRET_ACCESS_KEY"`
+		ObjectStoreSecretAccessKeyFile string `yaml:"objectStoreSecretAccessKeyFile" env:"OBJECT_STORE_SECRET_ACCESS_KEY_FILE"`
+		ObjectStoreRegion              string `yaml:"objectStoreRegion" env:"OBJECT_STORE_REGION"`
+		SnapshotDir                    string `yaml:"snapshotDir" env:"SNAPSHOT_DIR"`
+		ValidationMode                 string `yaml:"validationMode" env:"VALIDATION_MODE"`
+		IDMapPath                      string `yaml:"idmapPath" env:"IDMAP_PATH"`
+		PreloadWorkers                 int    `yaml:"preloadWorkers" env:"PRELOAD_WORKERS"`
+	} `yaml:"store"`
+
+	Cache struct {
+		TTL             time.Duration `yaml:"ttl" env:"CACHE_TTL"`
+		MaxEntries      int           `yaml:"maxEntries" env:"CACHE_MAX_ENTRIES"`
+		MaxBytes        byteSize      `yaml:"maxBytes" env:"CACHE_MAX_BYTES"`
+		PinSeason       int           `yaml:"pinSeason" env:"CACHE_PIN_SEASON"`
+		RefreshInterval time.Duration `yaml:"refreshInterval" env:"CACHE_REFRESH_INTERVAL"`
+		PolicyConfig    string        `yaml:"policyConfig" env:"CACHE_POLICY_CONFIG"`
+	} `yaml:"cache"`
+
+	RateLimits struct {
+		ComputeBudgetRate  float64 `yaml:"computeBudgetRate" env:"COMPUTE_BUDGET_RATE"`
+		ComputeBudgetBurst int     `yaml:"computeBudgetBurst" env:"COMPUTE_BUDGET_BURST"`
+		LoadSheddingConfig string  `yaml:"loadSheddingConfig" env:"LOAD_SHEDDING_CONFIG"`
+	} `yaml:"rateLimits"`
+
+	// Ratings.Weights is a rating config inline, in the format of the
+	// files of Ratings.Config
+	Ratings struct {
+		Config        string         `yaml:"config" env:"RATING_CONFIG"`
+		Weights       map[string]any `yaml:"weights" env:"RATING_CONFIG_JSON"`
+		LeaguesConfig string         `yaml:"leaguesConfig" env:"LEAGUES_CONFIG"`
+	} `yaml:"ratings"`
+
+	Auth struct {
+		APIKeysConfig string `yaml:"apiKeysConfig" env:"API_KEYS_CONFIG"`
+	} `yaml:"auth"`
+
+	UserData struct {
+		Backend       string `yaml:"backend" env:"USER_DATA_BACKEND"`
+		DSN           string `yaml:"dsn" env:"USER_DATA_DSN"`
+		VotesPath     string `yaml:"votesPath" env:"VOTES_PATH"`
+		CommunityPath string `yaml:"communityPath" env:"COMMUNITY_PATH"`
+		WebhooksPath  string `yaml:"webhooksPath" env:"WEBHOOKS_PATH"`
+		ProfilesPath  string `yaml:"profilesPath" env:"PROFILES_PATH"`
+	} `yaml:"userData"`
+
+	Ingest struct {
+		ESPNAPIURL         string        `yaml:"espnApiUrl" env:"ESPN_API_URL"`
+		ESPNFetchInterval  time.Duration `yaml:"espnFetchInterval" env:"ESPN_FETCH_INTERVAL"`
+		LiveInterval       time.Duration `yaml:"liveInterval" env:"LIVE_INTERVAL"`
+		ConditionsProvider string        `yaml:"conditionsProvider" env:"CONDITIONS_PROVIDER"`
+		NotifiersConfig    string        `yaml:"notifiersConfig" env:"NOTIFIERS_CONFIG"`
+	} `yaml:"ingest"`
+
+	Publish struct {
+		URL                 string `yaml:"url" env:"PUBLISH_URL"`
+		Token               string `yaml:"token" env:"PUBLISH_TOKEN"`
+		TokenFile           string `yaml:"tokenFile" env:"PUBLISH_TOKEN_FILE"`
+		AccessKeyID         string `yaml:"accessKeyId" env:"PUBLISH_ACCESS_KEY_ID"`
+		SecretAccessKey     string `yaml:"secretAccessKey" env:"PUBLISH_SECRET_ACCESS_KEY"`
+		SecretAccessKeyFile string `yaml:"secretAccessKeyFile" env:"PUBLISH_SECRET_ACCESS_KEY_FILE"`
+		Region              string `yaml:"region" env:"PUBLISH_REGION"`
+	} `yaml:"publish"`
+
+	Replica struct {
+		URL                 string        `yaml:"url" env:"REPLICA_URL"`
+		Token               string        `yaml:"token" env:"REPLICA_TOKEN"`
+		TokenFile           string        `yaml:"tokenFile" env:"REPLICA_TOKEN_FILE"`
+		AccessKeyID         string        `yaml:"accessKeyId" env:"REPLICA_ACCESS_KEY_ID"`
+		SecretAccessKey     string        `yaml:"secretAccessKey" env:"REPLICA_SECRET_ACCESS_KEY"`
+		SecretAccessKeyFile string        `yaml:"secretAccessKeyFile" env:"REPLICA_SECRET_ACCESS_KEY_FILE"`
+		Region              string        `yaml:"region" env:"REPLICA_REGION"`
+		PollInterval        time.Duration `yaml:"pollInterval" env:"REPLICA_POLL_INTERVAL"`
+	} `yaml:"replica"`
+
+	Secrets struct {
+		AgeIdentityFile string `yaml:"ageIdentityFile" env:"AGE_IDENTITY_FILE"`
+		SOPSBinary      string `yaml:"sopsBinary" env:"SOPS_BINARY"`
+		SOPSAgeKeyFile  string `yaml:"sopsAgeKeyFile" env:"SOPS_AGE_KEY_FILE"`
+	} `yaml:"secrets"`
+
+	Tracing struct {
+		Endpoint           string `yaml:"endpoint" env:"OTEL_EXPORTER_OTLP_ENDPOINT"`
+		TracesEndpoint     string `yaml:"tracesEndpoint" env:"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"`
+		ServiceName        string `yaml:"serviceName" env:"OTEL_SERVICE_NAME"`
+		ResourceAttributes string `yaml:"resourceAttributes" env:"OTEL_RESOURCE_ATTRIBUTES"`
+		Sampler            string `yaml:"sampler" env:"OTEL_TRACES_SAMPLER"`
+		SamplerArg         string `yaml:"samplerArg" env:"OTEL_TRACES_SAMPLER_ARG"`
+		Disabled           bool   `yaml:"disabled" env:"OTEL_SDK_DISABLED"`
+	} `yaml:"tracing"`
+}
+
+// The shortest intervals of the background jobs, which are off when their
+// interval is unset
+const (
+	minRefreshInterval = time.Second
+	minFetchInterval   = time.Minute
+	minLiveInterval    = 30 * time.Second
+)
+
+// checkInterval reports the interval d of the variable env when it is set
+// below min. validateConfig and the jobs share it, so a setting that
+// passes validation also starts its job.
+func checkInterval(env string, d, min time.Duration) error {
+	if d != 0 && d < min {
+		return fmt.Errorf("invalid %s %s: must be a duration of at least %s", env, d, min)
+	}
+	return nil
+}
+
+// configSetting is a field of Config
+type configSetting struct {
+	key   string // path in the config file, e.g. store.dataDir
+	env   string
+	value reflect.Value
+}
+
+// settings lists the fields of c in order
+func (c *Config) settings() []configSetting {
+	var settings []configSetting
+	sections := reflect.ValueOf(c).Elem()
+	for i := 0; i < sections.NumField(); i++ {
+		section, sectionKey := sections.Field(i), sections.Type().Field(i).Tag.Get("yaml")
+		for j := 0; j < section.NumField(); j++ {
+			f := section.Type().Field(j)
+			settings = append(settings, configSetting{
+				key:   sectionKey + "." + f.Tag.Get("yaml"),
+				env:   f.Tag.Get("env"),
+				value: section.Field(j),
+			})
+		}
+	}
+	return settings
+}
+
+var durationType = reflect.TypeFor[time.Duration]()
+
+// set parses v, in the format of the environment variable of the setting:
+// lists are comma-separated and the inline rating config is JSON. An empty
+// v resets the setting, but an empty list stays set, to turn off a list
+// with a default.
+func (s configSetting) set(v string) error {
+	if u, ok := s.value.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		if v == "" {
+			s.value.SetZero()
+			return nil
+		}
+		if err := u.UnmarshalText([]byte(v)); err != nil {
+			return fmt.Errorf("invalid %s %q: %v", s.env, v, err)
+		}
+		return nil
+	}
+	switch s.value.Interface().(type) {
+	case []string:
+		list := []string{}
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		s.value.Set(reflect.ValueOf(list))
+		return nil
+	case map[string]any:
+		var m map[string]any
+		if v != "" {
+			if err := json.Unmarshal([]byte(v), &m); err != nil {
+				return fmt.Errorf("invalid %s: %v", s.env, err)
+			}
+		}
+		s.value.Set(reflect.ValueOf(m))
+		return nil
+	}
+	if v == "" {
+		s.value.SetZero()
+		return nil
+	}
+
+	var err error
+	switch {
+	case s.value.Type() == durationType:
+		var d time.Duration
+		if d, err = time.ParseDuration(v); err == nil {
+			s.value.SetInt(int64(d))
+		} else {
+			err = errors.New("must be a duration such as 30s or 5m")
+		}
+	case s.value.Kind() == reflect.String:
+		s.value.SetString(v)
+	case s.value.Kind() == reflect.Int:
+		var n int
+		if n, err = strconv.Atoi(v); err == nil {
+			s.value.SetInt(int64(n))
+		} else {
+			err = errors.New("must be an integer")
+		}
+	case s.value.Kind() == reflect.Float64:
+		var f float64
+		if f, err = strconv.ParseFloat(v, 64); err == nil {
+			s.value.SetFloat(f)
+		} else {
+			err = errors.New("must be a number")
+		}
+	case s.value.Kind() == reflect.Bool:
+		var b bool
+		if b, err = strconv.ParseBool(v); err == nil {
+			s.value.SetBool(b)
+		} else {
+			err = errors.New("must be true or false")
+		}
+	default:
+		return fmt.Errorf("%s: unsupported setting type %s", s.key, s.value.Type())
+	}
+	if err != nil {
+		return fmt.Errorf("invalid %s %q: %v", s.env, v, err)
+	}
+	return nil
+}
+
+// String formats the setting as its environment variable, empty when it
+// is unset
+func (s configSetting) String() string {
+	if s.value.IsZero() {
+		return ""
+	}
+	switch v := s.value.Interface().(type) {
+	case []string:
+		return strings.Join(v, ",")
+	case map[string]any:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+	return fmt.Sprint(s.value.Interface())
+}
+
+// configFlagName is the serve flag of the environment variable env
+func configFlagName(env string) string {
+	return strings.ToLower(strings.ReplaceAll(env, "_", "-"))
+}
+
+// registerConfigFlags adds a flag to fset for each setting
+func registerConfigFlags(fset *flag.FlagSet) {
+	for _, s := range new(Config).settings() {
+		fset.String(configFlagName(s.env), "", "overrides $"+s.env+" and "+s.key+" of the config file")
+	}
+}
+
+// loadConfig reads a YAML config file, which may be encrypted. Unknown
+// keys are errors, so a misspelled setting does not go unnoticed.
+func loadConfig(path string) (Config, error) {
+	var cfg Config
+	data, err := readConfigFile(path)
+	if err != nil {
+		return cfg, err
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+		return cfg, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// resolveConfig overrides the settings of cfg with their environment
+// variables, then with the flags set on fset, which may be nil. Every
+// value that does not parse is reported at once.
+func resolveConfig(cfg *Config, fset *flag.FlagSet, lookupEnv func(string) (string, bool)) error {
+	flags := make(map[string]string)
+	if fset != nil {
+		fset.Visit(func(f *flag.Flag) { flags[f.Name] = f.Value.String() })
+	}
+
+	var errs []error
+	for _, s := range cfg.settings() {
+		v, ok := flags[configFlagName(s.env)]
+		if !ok {
+			v, ok = lookupEnv(s.env)
+		}
+		if !ok {
+			continue
+		}
+		if err := s.set(v); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// resolveSettings loads the config file at path, when set, under the
+// environment and the flags of fset. The decryption settings come first,
+// for an encrypted config file.
+func resolveSettings(path string, fset *flag.FlagSet) (Config, error) {
+	var cfg Config
+	if err := resolveConfig(&cfg, fset, os.LookupEnv); err != nil {
+		return cfg, fmt.Errorf("invalid configuration: %w", err)
+	}
+	setDecryptKeys(cfg)
+	if path == "" {
+		return cfg, nil
+	}
+
+	cfg, err := loadConfig(path)
+	if err != nil {
+		return cfg, fmt.Errorf("load config: %w", err)
+	}
+	if err := resolveConfig(&cfg, fset, os.LookupEnv); err != nil {
+		return cfg, fmt.Errorf("invalid configuration: %w", err)
+	}
+	setDecryptKeys(cfg)
+	return cfg, nil
+}
+
+// validateConfig checks the settings before any component starts,
+// reporting every invalid one at once
+func validateConfig(cfg Config) error {
+	var errs []error
+	check := func(err error) {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	oneOf := func(name, v string, values ...string) {
+		if v != "" && !slices.Contains(values, v) {
+			check(fmt.Errorf("invalid %s %q: must be %s", name, v, strings.Join(values, ", ")))
+		}
+	}
+
+	if p := cfg.Server.Port; p < 0 || p > 65535 {
+		check(fmt.Errorf("invalid PORT %d: must be a port from 1 to 65535", p))
+	}
+	if v := cfg.Server.HTTPPort; v != "" && v != "off" {
+		if n, err := strconv.Atoi(v); err != nil || n < 1 || n > 65535 {
+			check(fmt.Errorf("invalid HTTP_PORT %q: must be a port from 1 to 65535 or off", v))
+		}
+	}
+	for _, d := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"SHUTDOWN_TIMEOUT", cfg.Server.ShutdownTimeout},
+		{"CACHE_TTL", cfg.Cache.TTL},
+		{"CACHE_REFRESH_INTERVAL", cfg.Cache.RefreshInterval},
+		{"ESPN_FETCH_INTERVAL", cfg.Ingest.ESPNFetchInterval},
+		{"LIVE_INTERVAL", cfg.Ingest.LiveInterval},
+		{"REPLICA_POLL_INTERVAL", cfg.Replica.PollInterval},
+	} {
+		if d.value < 0 {
+			check(fmt.Errorf("invalid %s %s: must not be negative", d.name, d.value))
+		}
+	}
+	check(checkInterval("CACHE_REFRESH_INTERVAL", cfg.Cache.RefreshInterval, minRefreshInterval))
+	check(checkInterval("ESPN_FETCH_INTERVAL", cfg.Ingest.ESPNFetchInterval, minFetchInterval))
+	check(checkInterval("LIVE_INTERVAL", cfg.Ingest.LiveInterval, minLiveInterval))
+	if n := cfg.Store.PreloadWorkers; n < 0 {
+		check(fmt.Errorf("invalid PRELOAD_WORKERS %d: must be a positive integer", n))
+	}
+	oneOf("STORE_BACKEND", cfg.Store.Backend, "dir", "embedded", "sqlite", "s3", "gcs")
+	oneOf("DATA_DIR_MODE", cfg.Store.DataDirMode, "fail", "snapshot", "proxy")
+	oneOf("USER_DATA_BACKEND", cfg.UserData.Backend, "file", "sqlite", "postgres")
+
+	_, err := cacheFromConfig(cfg)
+	check(err)
+	_, err = computeBudgetFromConfig(cfg)
+	check(err)
+	_, err = newAccessLogger(cfg.Server.AccessLog, io.Discard)
+	check(err)
+	_, err = parseValidationMode(cfg.Store.ValidationMode)
+	check(err)
+	_, err = parsePrefetchHints(cfg.Server.PrefetchHints)
+	check(err)
+	_, err = parseCORSOrigins(cfg.Server.CORSOrigins)
+	check(err)
+	_, err = parseTrustedProxies(cfg.Server.TrustedProxies)
+	check(err)
+	check(checkTLS(cfg))
+	_, err = tracingSampler(cfg.Tracing.Sampler, cfg.Tracing.SamplerArg)
+	check(err)
+	_, err = resourceAttributes(cfg.Tracing.ResourceAttributes)
+	check(err)
+	if _, _, err := ratingConfigFromConfig(cfg); err != nil {
+		check(fmt.Errorf("invalid rating config: %w", err))
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// parseCORSOrigins parses CORS_ORIGINS, the origins allowed to call the
+// API from a browser. Every origin is allowed when it is empty or lists
+// "*".
+func parseCORSOrigins(list []string) ([]string, error) {
+	var origins []string
+	for _, origin := range list {
+		origin = strings.TrimSpace(origin)
+		switch origin {
+		case "":
+			continue
+		case "*":
+			return nil, nil
+		}
+		u, err := url.Parse(origin)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || (u.Path != "" && u.Path != "/") {
+			return nil, fmt.Errorf("invalid CORS_ORIGINS: %q must be an origin such as https://example.com", origin)
+		}
+		origins = append(origins, u.Scheme+"://"+u.Host)
+	}
+	return origins, nil
+}
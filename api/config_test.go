@@ -0,0 +1,176 @@
+package api
+
+import (
+	"context"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	os.WriteFile(path, []byte(`server:
+  port: 9000
+  corsOrigins: [https://a.example, https://b.example]
+store:
+  dataDir: /srv/data
+cache:
+  maxBytes: 64MB
+ratings:
+  weights:
+    blowoutMargin: 17
+`), 0644)
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fset := flag.NewFlagSet("serve", flag.ContinueOnError)
+	registerConfigFlags(fset)
+	if err := fset.Parse([]string{"-data-dir", "/flag/data"}); err != nil {
+		t.Fatal(err)
+	}
+	env := map[string]string{"PORT": "8080", "DATA_DIR": "/env/data", "CACHE_TTL": "90s"}
+	if err := resolveConfig(&cfg, fset, lookupOf(env)); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Store.DataDir != "/flag/data" {
+		t.Errorf("expected the flag to win, got %q", cfg.Store.DataDir)
+	}
+	if cfg.Server.Port != 8080 {
+		t.Errorf("expected the environment to win over the file, got %d", cfg.Server.Port)
+	}
+	if cfg.Cache.TTL != 90*time.Second || cfg.Cache.MaxBytes != 64<<20 {
+		t.Errorf("expected typed settings, got %v and %d", cfg.Cache.TTL, cfg.Cache.MaxBytes)
+	}
+	if !slices.Equal(cfg.Server.CORSOrigins, []string{"https://a.example", "https://b.example"}) {
+		t.Errorf("expected the origins of the file, got %v", cfg.Server.CORSOrigins)
+	}
+	if rc, ok, err := ratingConfigFromConfig(cfg); err != nil || !ok || rc.BlowoutMargin != 17 {
+		t.Errorf("expected the inline rating config, got %+v, %v, %v", rc, ok, err)
+	}
+
+	err = resolveConfig(&cfg, nil, lookupOf(map[string]string{"PORT": "http", "CACHE_TTL": "soon"}))
+	if err == nil || !strings.Contains(err.Error(), "PORT") || !strings.Contains(err.Error(), "CACHE_TTL") {
+		t.Errorf("expected both invalid values to be reported, got %v", err)
+	}
+
+	os.WriteFile(path, []byte("store:\n  datadir: /srv/data\n"), 0644)
+	if _, err := loadConfig(path); err == nil || !strings.Contains(err.Error(), "datadir") {
+		t.Errorf("expected the misspelled setting to be reported, got %v", err)
+	}
+}
+
+// lookupOf looks the variables up in vars
+func lookupOf(vars map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		v, ok := vars[name]
+		return v, ok
+	}
+}
+
+// configOf resolves the settings of the variables vars
+func configOf(t *testing.T, vars map[string]string) Config {
+	t.Helper()
+	var cfg Config
+	if err := resolveConfig(&cfg, nil, lookupOf(vars)); err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func TestConfigSettings(t *testing.T) {
+	seen := make(map[string]bool)
+	for _, s := range new(Config).settings() {
+		if s.env == "" || seen[s.env] {
+			t.Errorf("expected every setting to have its own variable, got %q twice or empty", s.env)
+		}
+		seen[s.env] = true
+	}
+	if !seen["PORT"] || !seen["RATING_CONFIG_JSON"] {
+		t.Errorf("expected the settings of the server, got %v", seen)
+	}
+}
+
+func TestResolveSettingsKeepsEnvironment(t *testing.T) {
+	useDecryptKeys(t)
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(path, []byte("publish:\n  token: s3cr3t\nstore:\n  preloadWorkers: 4\n"), 0600)
+	cfg, err := resolveSettings(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Publish.Token != "s3cr3t" || cfg.Store.PreloadWorkers != 4 {
+		t.Errorf("expected the settings of the file, got %+v", cfg)
+	}
+	if _, ok := os.LookupEnv("PUBLISH_TOKEN"); ok {
+		t.Error("expected the settings to stay out of the environment")
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+	cfg := configOf(t, map[string]string{
+		"PORT":                   "70000",
+		"CACHE_TTL":              "-1s",
+		"STORE_BACKEND":          "ftp",
+		"CACHE_MAX_ENTRIES":      "-1",
+		"COMPUTE_BUDGET_RATE":    "-0.5",
+		"CORS_ORIGINS":           "example.com",
+		"TLS_CERT_FILE":          "cert.pem",
+		"RATING_CONFIG_JSON":     `{"blowoutMargin": "many"}`,
+		"CACHE_REFRESH_INTERVAL": "500ms",
+		"HTTP_PORT":              "off",
+		"TLS_AUTOCERT_DOMAINS":   "",
+		"TRUSTED_PROXIES":        "10.0.0.0/8,lb.internal",
+	})
+	err := validateConfig(cfg)
+	if err == nil {
+		t.Fatal("expected an invalid configuration")
+	}
+	for _, name := range []string{"PORT", "CACHE_TTL", "STORE_BACKEND", "CACHE_MAX_ENTRIES", "COMPUTE_BUDGET_RATE", "CORS_ORIGINS", "TLS_KEY_FILE", "rating config", "CACHE_REFRESH_INTERVAL", "TRUSTED_PROXIES"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("expected %s to be reported, got %v", name, err)
+		}
+	}
+	if strings.Contains(err.Error(), "HTTP_PORT") {
+		t.Errorf("expected HTTP_PORT=off to be valid, got %v", err)
+	}
+
+	// The refresher rejects what validation rejects
+	if err := startRefresher(context.Background(), dataStore, cfg.Cache.RefreshInterval); err == nil {
+		t.Error("expected the refresher to reject an interval below 1s")
+	}
+
+	cfg = configOf(t, map[string]string{"PORT": "9000", "CACHE_TTL": "5m", "CACHE_REFRESH_INTERVAL": "1s"})
+	if err := validateConfig(cfg); err != nil {
+		t.Errorf("expected a valid configuration, got %v", err)
+	}
+}
+
+func TestCORSOrigins(t *testing.T) {
+	if origins, err := parseCORSOrigins([]string{"https://a.example/", "*"}); err != nil || origins != nil {
+		t.Errorf("expected * to allow every origin, got %v %v", origins, err)
+	}
+	origins, err := parseCORSOrigins([]string{"https://a.example/", "http://localhost:3000"})
+	if err != nil || len(origins) != 2 || origins[0] != "https://a.example" {
+		t.Fatalf("unexpected origins %v %v", origins, err)
+	}
+
+	handler := corsMiddleware(origins, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for origin, want := range map[string]string{"http://localhost:3000": "http://localhost:3000", "https://evil.example": ""} {
+		req := httptest.NewRequest("GET", "/seasons", nil)
+		req.Header.Set("Origin", origin)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if got := rec.Header().Get("Access-Control-Allow-Origin"); got != want || rec.Header().Get("Vary") != "Origin" {
+			t.Errorf("%s: expected %q, got %q", origin, want, got)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jjway/rewatchableGamesApi-go/store"
+)
+
+// Plausible number of games in a regular season week. Bye weeks bring a
+// full slate of 16 down to 13 or 14.
+const (
+	minGamesPerWeek = 10
+	maxGamesPerWeek = 16
+)
+
+// postseasonGames is the plausible number of games of each playoff round,
+// the wild card round having grown from 4 to 6 games in 2020
+var postseasonGames = map[string][2]int{
+	"wildcard":   {4, 6},
+	"divisional": {4, 4},
+	"conference": {2, 2},
+	"superbowl":  {1, 1},
+}
+
+// Violation is one failed invariant of the data set
+type Violation struct {
+	Season  string `json:"season"`
+	Week    string `json:"week,omitempty"`
+	Check   string `json:"check"`
+	Message string `json:"message"`
+}
+
+// ConsistencyReport is the result of checkConsistency
+type ConsistencyReport struct {
+	Seasons    int         `json:"seasons"`
+	Files      int         `json:"files"`
+	Violations []Violation `json:"violations"`
+}
+
+// checkConsistency verifies the week files of every season in the store:
+// weeks are contiguous from 1, each week and playoff round has a plausible
+// number of games, every game has an ID and IDs are unique within a season
+func checkConsistency(s store.Store) (ConsistencyReport, error) {
+	report := ConsistencyReport{Violations: []Violation{}}
+
+	names, err := s.ListFiles()
+	if err != nil {
+		return report, err
+	}
+
+	seasons := make(map[string][]string)
+	for _, name := range names {
+		season, file, _ := strings.Cut(name, "/")
+		seasons[season] = append(seasons[season], strings.TrimSuffix(file, ".json"))
+	}
+	report.Seasons = len(seasons)
+	report.Files = len(names)
+
+	years := make([]string, 0, len(seasons))
+	for year := range seasons {
+		years = append(years, year)
+	}
+	sort.Strings(years)
+
+	for _, year := range years {
+		report.Violations = append(report.Violations, checkSeason(year, seasons[year])...)
+	}
+	return report, nil
+}
+
+func checkSeason(year string, weekNames []string) []Violation {
+	var violations []Violation
+	add := func(week, check, format string, args ...any) {
+		violations = append(violations, Violation{
+			Season: year, Week: week, Check: check, Message: fmt.Sprintf(format, args...),
+		})
+	}
+
+	var weeks []int
+	var playoffs []string
+	for _, name := range weekNames {
+		if isPostseason(name) {
+			playoffs = append(playoffs, name)
+			continue
+		}
+		n, err := strconv.Atoi(name)
+		if err != nil || n < 1 {
+			add(name, "week-name", "week file %q is not a positive week number or a playoff round", name)
+			continue
+		}
+		weeks = append(weeks, n)
+	}
+	sort.Ints(weeks)
+	sort.Slice(playoffs, func(i, j int) bool {
+		oi, _ := weekOrder(playoffs[i])
+		oj, _ := weekOrder(playoffs[j])
+		return oi < oj
+	})
+
+	// Week gaps
+	for i, week := range weeks {
+		if want := i + 1; week != want {
+			add(strconv.Itoa(want), "week-gap", "week %d is missing before week %d", want, week)
+			break
+		}
+	}
+
+	ordered := make([]string, 0, len(weeks)+len(playoffs))
+	for _, week := range weeks {
+		ordered = append(ordered, strconv.Itoa(week))
+	}
+	ordered = append(ordered, playoffs...)
+
+	// Per-week game counts and season-wide ID uniqueness
+	seen := make(map[string]string)
+	for _, weekStr := range ordered {
+		games, err := loadGameStats(store.WeekFile(year, weekStr))
+		if err != nil {
+			add(weekStr, "readable", "could not load week: %v", err)
+			continue
+		}
+		minGames, maxGames := minGamesPerWeek, maxGamesPerWeek
+		if bounds, ok := postseasonGames[weekStr]; ok {
+			minGames, maxGames = bounds[0], bounds[1]
+		}
+		if n := len(games); n < minGames || n > maxGames {
+			add(weekStr, "game-count", "%d games, expected between %d and %d", n, minGames, maxGames)
+		}
+		for i, g := range games {
+			if g.ID == "" {
+				add(weekStr, "game-id", "game at index %d has no id", i)
+				continue
+			}
+			if prev, dup := seen[g.ID]; dup {
+				add(weekStr, "unique-id", "game %s already appears in week %s", g.ID, prev)
+				continue
+			}
+			seen[g.ID] = weekStr
+		}
+	}
+	return violations
+}
+
+// logConsistency runs checkConsistency and logs every violation
+func logConsistency(s store.Store) {
+	report, err := checkConsistency(s)
+	if err != nil {
+		log.Printf("Warning: consistency check failed: %v", err)
+		return
+	}
+	for _, v := range report.Violations {
+		log.Printf("Consistency: %s week %s [%s] %s", v.Season, v.Week, v.Check, v.Message)
+	}
+	log.Printf("Consistency check: %d seasons, %d files, %d violations",
+		report.Seasons, report.Files, len(report.Violations))
+}
+
+// handleConsistency serves the current consistency report
+func handleConsistency(w http.ResponseWriter, r *http.Request) {
+	report, err := checkConsistency(dataStore)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "could not list data files")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		writeError(w, r, http.StatusInternalServerError, "error encoding response")
+	}
+}
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/jjway/rewatchableGamesApi-go/store"
+)
+
+// weekOf returns a week file with n games whose IDs start with prefix
+func weekOf(prefix string, n int) []byte {
+	games := make([]string, n)
+	for i := range games {
+		games[i] = `{"id":"` + prefix + string(rune('a'+i)) + `"}`
+	}
+	return []byte("[" + strings.Join(games, ",") + "]")
+}
+
+func TestCheckConsistency(t *testing.T) {
+	fsys := fstest.MapFS{
+		"2024/1.json": {Data: weekOf("w1", 14)},
+		"2024/2.json": {Data: weekOf("w2", 3)},
+		"2024/4.json": {Data: weekOf("w1", 14)},
+	}
+	cache = newWeekCache(0, 0, "")
+	oldStore := dataStore
+	dataStore = store.NewFS(fsys)
+	t.Cleanup(func() { dataStore = oldStore })
+
+	report, err := checkConsistency(dataStore)
+	if err != nil {
+		t.Fatalf("check failed: %v", err)
+	}
+
+	checks := make(map[string]int)
+	for _, v := range report.Violations {
+		checks[v.Check]++
+	}
+	if checks["week-gap"] != 1 {
+		t.Errorf("expected one week gap, got %+v", report.Violations)
+	}
+	if checks["game-count"] != 1 {
+		t.Errorf("expected one implausible game count, got %+v", report.Violations)
+	}
+	if checks["unique-id"] != 14 {
+		t.Errorf("expected 14 duplicate IDs, got %d", checks["unique-id"])
+	}
+	if report.Seasons != 1 || report.Files != 3 {
+		t.Errorf("unexpected totals %+v", report)
+	}
+}
+
+func TestCheckConsistencyPlayoffs(t *testing.T) {
+	fsys := fstest.MapFS{
+		"2023/1.json":          {Data: weekOf("w1", 14)},
+		"2023/wildcard.json":   {Data: weekOf("wc", 6)},
+		"2023/divisional.json": {Data: weekOf("dv", 3)},
+	}
+	cache = newWeekCache(0, 0, "")
+	oldStore := dataStore
+	dataStore = store.NewFS(fsys)
+	t.Cleanup(func() { dataStore = oldStore })
+
+	report, err := checkConsistency(dataStore)
+	if err != nil {
+		t.Fatalf("check failed: %v", err)
+	}
+	if v := report.Violations; len(v) != 1 || v[0].Week != "divisional" || v[0].Check != "game-count" {
+		t.Errorf("expected only the short divisional round to be flagged, got %+v", v)
+	}
+}
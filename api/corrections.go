@@ -0,0 +1,309 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultCorrections is how many corrections the feeds list without
+// ?limit=
+const defaultCorrections = 50
+
+// CorrectedGame is a game a correction changed. Fields lists the stats
+// that changed, not their values, so the feed stays spoiler-free.
+type CorrectedGame struct {
+	ID           string   `json:"id"`
+	Slug         string   `json:"slug"`
+	ShortName    string   `json:"shortName"`
+	Change       string   `json:"change"` // updated, added or removed
+	Fields       []string `json:"fields,omitempty"`
+	RatingBefore *float64 `json:"ratingBefore,omitempty"`
+	RatingAfter  *float64 `json:"ratingAfter,omitempty"`
+}
+
+// WeekCorrection is a version of a week file served after its initial
+// publication, with the games it changed
+type WeekCorrection struct {
+	Season      string          `json:"season"`
+	Week        string          `json:"week"`
+	WeekLabel   string          `json:"weekLabel"`
+	Version     string          `json:"version"` // SHA-256 prefix of the corrected file
+	PublishedAt time.Time       `json:"publishedAt"`
+	CorrectedAt time.Time       `json:"correctedAt"`
+	Games       []CorrectedGame `json:"games"`
+}
+
+// CorrectionsFeed is the response of GET /corrections.json, the latest
+// correction first
+type CorrectionsFeed struct {
+	Algorithm   string           `json:"algorithm"`
+	Corrections []WeekCorrection `json:"corrections"`
+}
+
+// changedFields lists the stats, and the names, that differ between two
+// versions of a game
+func changedFields(before, after *GameStats) []string {
+	var fields []string
+	for _, f := range []struct {
+		name string
+		a, b string
+	}{
+		{"fullName", before.FullName, after.FullName},
+		{"shortName", before.ShortName, after.ShortName},
+		{"matchupQuality", before.MatchupQuality, after.MatchupQuality},
+	} {
+		if f.a != f.b {
+			fields = append(fields, f.name)
+		}
+	}
+	for path := range statPaths {
+		if stat(before, path) != stat(after, path) {
+			fields = append(fields, path)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// ratingOf is the rating of g by rater
+func ratingOf(rater Rater, g GameStats) *float64 {
+	r := processGame(rater, g).TotalRating
+	return &r
+}
+
+// diffWeeks lists the games of a week that changed between two of its
+// versions, in the order of the newer one and the removed games last
+func diffWeeks(rater Rater, season, week string, before, after []GameStats) []CorrectedGame {
+	old := make(map[string]*GameStats, len(before))
+	for i := range before {
+		old[before[i].ID] = &before[i]
+	}
+	corrected := func(g *GameStats, change string) CorrectedGame {
+		return CorrectedGame{ID: g.ID, Slug: gameSlug(season, week, g.ShortName, g.ID), ShortName: g.ShortName, Change: change}
+	}
+
+	var games []CorrectedGame
+	seen := make(map[string]bool, len(after))
+	for i := range after {
+		g := &after[i]
+		seen[g.ID] = true
+		prev, ok := old[g.ID]
+		if !ok {
+			c := corrected(g, "added")
+			c.RatingAfter = ratingOf(rater, *g)
+			games = append(games, c)
+			continue
+		}
+		fields := changedFields(prev, g)
+		if len(fields) == 0 {
+			continue
+		}
+		c := corrected(g, "updated")
+		c.Fields = fields
+		c.RatingBefore, c.RatingAfter = ratingOf(rater, *prev), ratingOf(rater, *g)
+		games = append(games, c)
+	}
+	for i := range before {
+		if g := &before[i]; !seen[g.ID] {
+			c := corrected(g, "removed")
+			c.RatingBefore = ratingOf(rater, *g)
+			games = append(games, c)
+		}
+	}
+	return games
+}
+
+// weekCorrections lists the corrections of the week file name from its
+// snapshot history: every version after the first that changed a game
+func weekCorrections(s *snapshotStore, rater Rater, name string) ([]WeekCorrection, error) {
+	s.mu.Lock()
+	entries, err := s.history(name)
+	s.mu.Unlock()
+	if err != nil || len(entries) < 2 {
+		return nil, err
+	}
+	season, week, _ := strings.Cut(strings.TrimSuffix(name, ".json"), "/")
+
+	version := func(e snapshotEntry) ([]GameStats, error) {
+		data, err := os.ReadFile(s.objectPath(e.SHA256))
+		if err != nil {
+			return nil, err
+		}
+		return decodeWeekFile(name, data)
+	}
+	prev, err := version(entries[0])
+	if err != nil {
+		return nil, err
+	}
+	var corrections []WeekCorrection
+	for _, e := range entries[1:] {
+		games, err := version(e)
+		if err != nil {
+			return nil, err
+		}
+		if changed := diffWeeks(rater, season, week, prev, games); len(changed) > 0 {
+			corrections = append(corrections, WeekCorrection{
+				Season:      season,
+				Week:        week,
+				WeekLabel:   weekLabel(week),
+				Version:     e.SHA256[:12],
+				PublishedAt: entries[0].At,
+				CorrectedAt: e.At,
+				Games:       changed,
+			})
+		}
+		prev = games
+	}
+	return corrections, nil
+}
+
+// listCorrections lists the corrections of every week with a snapshot
+// history, of season when set, made after since, the latest first
+func listCorrections(s *snapshotStore, rater Rater, season string, since time.Time, limit int) ([]WeekCorrection, error) {
+	names, err := latestSnapshots{s}.ListFiles()
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	corrections := []WeekCorrection{}
+	for _, name := range names {
+		if season != "" && !strings.HasPrefix(name, season+"/") {
+			continue
+		}
+		week, err := weekCorrections(s, rater, name)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		for _, c := range week {
+			if c.CorrectedAt.After(since) {
+				corrections = append(corrections, c)
+			}
+		}
+	}
+	sort.SliceStable(corrections, func(i, j int) bool { return corrections[i].CorrectedAt.After(corrections[j].CorrectedAt) })
+	if len(corrections) > limit {
+		corrections = corrections[:limit]
+	}
+	return corrections, nil
+}
+
+// parseCorrectionsQuery parses the ?season=, ?since= and ?limit= of the
+// corrections feeds
+func parseCorrectionsQuery(r *http.Request) (season string, since time.Time, limit int, qerr *QueryError) {
+	q := r.URL.Query()
+	season, limit = q.Get("season"), defaultCorrections
+	if season != "" {
+		if _, err := strconv.Atoi(season); err != nil || len(season) != 4 {
+			return "", since, 0, &QueryError{Param: "season", Value: season, Message: "must be a four-digit year"}
+		}
+	}
+	if v := q.Get("since"); v != "" {
+		if since, qerr = parseTime("since", v); qerr != nil {
+			return "", since, 0, qerr
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxPageLimit {
+			return "", since, 0, &QueryError{Param: "limit", Value: v, Message: "must be an integer between 1 and " + strconv.Itoa(maxPageLimit)}
+		}
+		limit = n
+	}
+	return season, since, limit, nil
+}
+
+// renderCorrectionsRSS renders the corrections as RSS 2.0, one item per
+// corrected week version, served from self
+func renderCorrectionsRSS(corrections []WeekCorrection, lang, base, self string) ([]byte, error) {
+	doc := rssDocument{Version: "2.0", Atom: "http://www.w3.org/2005/Atom", Channel: rssChannel{
+		Title:       translate(lang, "corrections.title"),
+		Link:        base + "/corrections.json",
+		Description: translate(lang, "corrections.description"),
+		Language:    lang,
+		Self:        atomLink{Href: self, Rel: "self", Type: "application/rss+xml"},
+		TTL:         cachePolicies["feed"].MaxAge / 60,
+	}}
+	if len(corrections) > 0 {
+		doc.Channel.LastBuildDate = corrections[0].CorrectedAt.UTC().Format(time.RFC1123Z)
+	}
+	for _, c := range corrections {
+		names := make([]string, 0, len(c.Games))
+		for _, g := range c.Games {
+			names = append(names, g.ShortName)
+		}
+		label := localWeekLabel(lang, c.Week)
+		doc.Channel.Items = append(doc.Channel.Items, rssItem{
+			Title:       translate(lang, "corrections.item.title", label, c.Season),
+			Link:        base + "/games/" + c.Season + "/" + c.Week,
+			GUID:        rssGUID{Value: "tag:rewatchable-games," + c.Season + ":" + c.Week + "/corrections/" + c.Version},
+			Description: translate(lang, "corrections.item", len(c.Games), strings.Join(names, ", ")),
+			Category:    label,
+			PubDate:     c.CorrectedAt.UTC().Format(time.RFC1123Z),
+		})
+	}
+	return marshalFeed(doc)
+}
+
+// handleCorrections serves the games whose stats or ratings changed after
+// their week was first served, from the snapshot history, as JSON on
+// /corrections.json and as RSS on /corrections.rss, for the caches
+// downstream to know which games to refresh
+func handleCorrections(format string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if snapshots == nil {
+			writeError(w, r, http.StatusNotFound, "snapshots are not enabled on this server")
+			return
+		}
+		rater, qerr := raterFor(r)
+		if qerr != nil {
+			writeQueryError(w, r, qerr)
+			return
+		}
+		lang, qerr := requestLang(w, r)
+		if qerr != nil {
+			writeQueryError(w, r, qerr)
+			return
+		}
+		season, since, limit, qerr := parseCorrectionsQuery(r)
+		if qerr != nil {
+			writeQueryError(w, r, qerr)
+			return
+		}
+		corrections, err := listCorrections(snapshots, rater, season, since, limit)
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, "error reading the snapshot history")
+			return
+		}
+
+		var body []byte
+		contentType := "application/json"
+		if format == "rss" {
+			contentType = "application/rss+xml; charset=utf-8"
+			base := requestBaseURL(r)
+			body, err = renderCorrectionsRSS(corrections, lang, base, base+r.URL.Path)
+		} else {
+			body, err = json.Marshal(CorrectionsFeed{Algorithm: rater.Version(), Corrections: corrections})
+		}
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, "error encoding response")
+			return
+		}
+
+		var updated time.Time
+		if len(corrections) > 0 {
+			updated = corrections[0].CorrectedAt
+		}
+		w.Header().Set("Content-Type", contentType)
+		setCacheHeaders(w, "feed")
+		if notModified(w, r, etagFor(body), updated) {
+			return
+		}
+		w.Write(body)
+	}
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCorrections(t *testing.T) {
+	snapshots = newSnapshotStore(t.TempDir())
+	t.Cleanup(func() { snapshots = nil })
+	t0 := time.Date(2024, 9, 8, 12, 0, 0, 0, time.UTC)
+
+	for i, step := range []struct {
+		name, data string
+		at         time.Time
+	}{
+		{"2024/1.json", `[{"id": "a", "shortName": "BUF @ KC", "offense": {"totalPoints": 40}}, {"id": "b", "shortName": "NE @ NYJ"}]`, t0},
+		{"2024/2.json", `[{"id": "c", "shortName": "DAL @ PHI"}]`, t0},
+		{"2024/1.json", `[{"id": "a", "shortName": "BUF @ KC", "offense": {"totalPoints": 41}}, {"id": "b", "shortName": "NE @ NYJ"}, {"id": "d", "shortName": "LV @ DEN"}]`, t0.Add(time.Hour)},
+		{"2024/1.json", `[{"id": "a", "shortName": "BUF @ KC", "offense": {"totalPoints": 41}}, {"id": "d", "shortName": "LV @ DEN"}]`, t0.Add(2 * time.Hour)},
+	} {
+		if err := snapshots.record(step.name, []byte(step.data), step.at); err != nil {
+			t.Fatalf("record %d: %v", i, err)
+		}
+	}
+
+	corrections, err := listCorrections(snapshots, raters[defaultAlgorithm], "", time.Time{}, defaultCorrections)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(corrections) != 2 {
+		t.Fatalf("expected the two later versions of week 1, got %+v", corrections)
+	}
+	latest, first := corrections[0], corrections[1]
+	if !latest.CorrectedAt.Equal(t0.Add(2*time.Hour)) || !latest.PublishedAt.Equal(t0) {
+		t.Errorf("expected the latest correction first, got %+v", latest)
+	}
+	if len(latest.Games) != 1 || latest.Games[0].ID != "b" || latest.Games[0].Change != "removed" {
+		t.Errorf("expected game b removed, got %+v", latest.Games)
+	}
+	if len(first.Games) != 2 {
+		t.Fatalf("expected games a and d in the first correction, got %+v", first.Games)
+	}
+	a, d := first.Games[0], first.Games[1]
+	if a.Change != "updated" || !slices.Equal(a.Fields, []string{"offense.totalPoints"}) || a.Slug != "2024-w1-buf-kc" {
+		t.Errorf("expected totalPoints of a updated, got %+v", a)
+	}
+	if a.RatingBefore == nil || a.RatingAfter == nil {
+		t.Errorf("expected the ratings before and after, got %+v", a)
+	}
+	if d.Change != "added" || d.RatingBefore != nil || d.RatingAfter == nil {
+		t.Errorf("expected game d added, got %+v", d)
+	}
+
+	if since, _ := listCorrections(snapshots, raters[defaultAlgorithm], "", t0.Add(90*time.Minute), defaultCorrections); len(since) != 1 {
+		t.Errorf("expected one correction since 13:30, got %+v", since)
+	}
+	if other, _ := listCorrections(snapshots, raters[defaultAlgorithm], "2023", time.Time{}, defaultCorrections); len(other) != 0 {
+		t.Errorf("expected no correction in 2023, got %+v", other)
+	}
+}
+
+func TestHandleCorrections(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /corrections.json", handleCorrections("json"))
+	mux.HandleFunc("GET /corrections.rss", handleCorrections("rss"))
+	get := func(url string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest("GET", url, nil))
+		return rec
+	}
+
+	if rec := get("/corrections.json"); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 with snapshots disabled, got %d", rec.Code)
+	}
+
+	snapshots = newSnapshotStore(t.TempDir())
+	t.Cleanup(func() { snapshots = nil })
+	t0 := time.Date(2024, 9, 8, 12, 0, 0, 0, time.UTC)
+	snapshots.record("2024/1.json", []byte(`[{"id": "a", "shortName": "BUF @ KC"}]`), t0)
+	snapshots.record("2024/1.json", []byte(`[{"id": "a", "shortName": "BUF @ KC", "offense": {"totalPoints": 41}}]`), t0.Add(time.Hour))
+
+	rec := get("/corrections.json")
+	var feed CorrectionsFeed
+	if err := json.Unmarshal(rec.Body.Bytes(), &feed); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("expected a corrections feed, got %d %s", rec.Code, rec.Body)
+	}
+	if len(feed.Corrections) != 1 || feed.Corrections[0].Games[0].ID != "a" || feed.Algorithm != defaultAlgorithm {
+		t.Errorf("expected the correction of game a, got %+v", feed)
+	}
+	if lm := rec.Header().Get("Last-Modified"); lm != "Sun, 08 Sep 2024 13:00:00 GMT" {
+		t.Errorf("expected Last-Modified of the latest correction, got %q", lm)
+	}
+
+	rec = get("/corrections.rss?lang=fr")
+	body := rec.Body.String()
+	if rec.Code != http.StatusOK || !strings.Contains(body, "<title>Corrections : Semaine 1, 2024</title>") || !strings.Contains(body, "/games/2024/1</link>") {
+		t.Errorf("expected an RSS item for week 1, got %d %s", rec.Code, body)
+	}
+
+	for _, url := range []string{"/corrections.json?since=yesterday", "/corrections.json?limit=0", "/corrections.json?season=24"} {
+		if rec := get(url); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", url, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jjway/rewatchableGamesApi-go/store"
+)
+
+// DATA_DIR_MODE picks what the server does when its local data directory
+// is missing or read-only:
+//
+//	(unset)   serve the embedded data of binaries built with it, or
+//	          serve the directory anyway, warning that every week will
+//	          404 or that writes are disabled
+//	fail      exit at startup
+//	snapshot  serve the latest versions kept in SNAPSHOT_DIR, or the
+//	          embedded data, read-only
+//	proxy     serve the snapshots published to REPLICA_URL, or PUBLISH_URL,
+//	          as a read replica
+//
+// A read-only directory that exists is served read-only in every mode but
+// fail: ingestion, ESPN fetches and backfills answer 501.
+const (
+	dataDirWarn     = ""
+	dataDirFail     = "fail"
+	dataDirSnapshot = "snapshot"
+	dataDirProxy    = "proxy"
+)
+
+// dataDirModeHelp is the guidance logged with a missing data directory
+const dataDirModeHelp = "set DATA_DIR to the directory of the week files, or DATA_DIR_MODE to fail, snapshot or proxy"
+
+// readOnlyStore hides the WriteFile of a store whose directory cannot be
+// written, so the write paths answer as for any read-only backend
+type readOnlyStore struct {
+	store.Store
+}
+
+// latestSnapshots serves the latest version of each week kept in a
+// snapshotStore
+type latestSnapshots struct {
+	s *snapshotStore
+}
+
+func (l latestSnapshots) ReadFile(name string) ([]byte, error) {
+	data, _, err := l.s.lookup(name, time.Now())
+	return data, err
+}
+
+func (l latestSnapshots) ListFiles() ([]string, error) {
+	root := filepath.Join(l.s.root, "history")
+	var names []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if name := strings.TrimSuffix(filepath.ToSlash(rel), "l"); store.IsWeekFile(name) {
+			names = append(names, name)
+		}
+		return nil
+	})
+	return names, err
+}
+
+// localRoot returns the directory of a local store, watched for changes
+func localRoot(s store.Store) (string, bool) {
+	if ro, ok := s.(readOnlyStore); ok {
+		s = ro.Store
+	}
+	ds, ok := s.(*store.Dir)
+	if !ok {
+		return "", false
+	}
+	return ds.Root(), true
+}
+
+// writable reports whether files can be created in dir
+func writable(dir string) bool {
+	f, err := os.CreateTemp(dir, ".writable-*")
+	if err != nil {
+		return false
+	}
+	f.Close()
+	os.Remove(f.Name())
+	return true
+}
+
+// resolveDataDir applies DATA_DIR_MODE to the local store ds. It returns
+// the store to serve, or in proxy mode the bucket URL to replicate.
+func resolveDataDir(ds *store.Dir, cfg Config) (store.Store, string, error) {
+	mode := cfg.Store.DataDirMode
+	switch mode {
+	case dataDirWarn, dataDirFail, dataDirSnapshot, dataDirProxy:
+	default:
+		return nil, "", fmt.Errorf("invalid DATA_DIR_MODE %q: must be fail, snapshot or proxy", mode)
+	}
+
+	info, err := os.Stat(ds.Root())
+	switch {
+	case err == nil && !info.IsDir():
+		err = fmt.Errorf("%s is not a directory", ds.Root())
+	case errors.Is(err, fs.ErrNotExist):
+		err = fmt.Errorf("data directory %s does not exist", ds.Root())
+	}
+	if err != nil {
+		return missingDataDir(ds, mode, cfg, err)
+	}
+
+	if writable(ds.Root()) {
+		return ds, "", nil
+	}
+	if mode == dataDirFail {
+		return nil, "", fmt.Errorf("data directory %s is read-only (DATA_DIR_MODE=fail): make it writable, or unset DATA_DIR_MODE to serve it read-only", ds.Root())
+	}
+	log.Printf("Warning: data directory %s is read-only: serving it without ingestion, ESPN fetches or backfills", ds.Root())
+	return readOnlyStore{ds}, "", nil
+}
+
+// missingDataDir picks the store of a data directory that cannot be read
+func missingDataDir(ds *store.Dir, mode string, cfg Config, cause error) (store.Store, string, error) {
+	switch mode {
+	case dataDirFail:
+		return nil, "", fmt.Errorf("%v (DATA_DIR_MODE=fail): set DATA_DIR to the directory of the week files", cause)
+	case dataDirSnapshot:
+		if dir := cfg.Store.SnapshotDir; dir != "" {
+			log.Printf("Warning: %v: serving the latest snapshots of %s, read-only", cause, dir)
+			return latestSnapshots{newSnapshotStore(dir)}, "", nil
+		}
+		if EmbeddedData != nil {
+			log.Printf("Warning: %v: serving the embedded data, read-only", cause)
+			return store.NewFS(EmbeddedData), "", nil
+		}
+		return nil, "", fmt.Errorf("%v: DATA_DIR_MODE=snapshot needs SNAPSHOT_DIR or a binary with embedded data", cause)
+	case dataDirProxy:
+		for _, u := range []string{cfg.Replica.URL, cfg.Publish.URL} {
+			if u != "" {
+				log.Printf("Warning: %v: proxying the snapshots published to %s", cause, u)
+				return nil, u, nil
+			}
+		}
+		return nil, "", fmt.Errorf("%v: DATA_DIR_MODE=proxy needs REPLICA_URL or PUBLISH_URL", cause)
+	}
+	if EmbeddedData != nil {
+		log.Printf("Warning: %v: serving the embedded data, read-only", cause)
+		return store.NewFS(EmbeddedData), "", nil
+	}
+	log.Printf("Warning: %v, every week will 404: %s", cause, dataDirModeHelp)
+	return ds, "", nil
+}
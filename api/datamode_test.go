@@ -0,0 +1,109 @@
+package api
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jjway/rewatchableGamesApi-go/store"
+)
+
+func TestResolveMissingDataDir(t *testing.T) {
+	missing := store.NewDir(filepath.Join(t.TempDir(), "data"))
+	old := EmbeddedData
+	EmbeddedData = nil
+	t.Cleanup(func() { EmbeddedData = old })
+
+	if s, upstream, err := resolveDataDir(missing, Config{}); err != nil || s != missing || upstream != "" {
+		t.Errorf("expected the directory to be served with a warning, got %v, %q, %v", s, upstream, err)
+	}
+	if _, _, err := resolveDataDir(missing, configOf(t, map[string]string{"DATA_DIR_MODE": "fail"})); err == nil {
+		t.Error("expected fail mode to refuse a missing directory")
+	}
+	if _, _, err := resolveDataDir(missing, configOf(t, map[string]string{"DATA_DIR_MODE": "maybe"})); err == nil {
+		t.Error("expected an unknown mode to be refused")
+	}
+
+	_, upstream, err := resolveDataDir(missing, configOf(t, map[string]string{"DATA_DIR_MODE": "proxy", "PUBLISH_URL": "https://bucket.example/api"}))
+	if err != nil || upstream != "https://bucket.example/api" {
+		t.Errorf("expected to proxy the published snapshots, got %q, %v", upstream, err)
+	}
+	if _, _, err := resolveDataDir(missing, configOf(t, map[string]string{"DATA_DIR_MODE": "proxy"})); err == nil {
+		t.Error("expected proxy mode to need a bucket")
+	}
+
+	if _, _, err := resolveDataDir(missing, configOf(t, map[string]string{"DATA_DIR_MODE": "snapshot"})); err == nil {
+		t.Error("expected snapshot mode to need snapshots")
+	}
+
+	snapDir := t.TempDir()
+	snaps := newSnapshotStore(snapDir)
+	snaps.record("2024/1.json", []byte(`[{"id": "old"}]`), time.Now().Add(-time.Hour))
+	snaps.record("2024/1.json", []byte(testData), time.Now().Add(-time.Minute))
+	s, _, err := resolveDataDir(missing, configOf(t, map[string]string{"DATA_DIR_MODE": "snapshot", "SNAPSHOT_DIR": snapDir}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.(store.WritableStore); ok {
+		t.Error("expected the snapshots to be served read-only")
+	}
+	if names, err := s.ListFiles(); err != nil || !slices.Equal(names, []string{"2024/1.json"}) {
+		t.Errorf("unexpected files %v, %v", names, err)
+	}
+	if data, err := s.ReadFile("2024/1.json"); err != nil || string(data) != testData {
+		t.Errorf("expected the latest version, got %s, %v", data, err)
+	}
+}
+
+func TestResolveMissingDataDirEmbedded(t *testing.T) {
+	missing := store.NewDir(filepath.Join(t.TempDir(), "data"))
+	old := EmbeddedData
+	EmbeddedData = os.DirFS(setupTestData(t))
+	t.Cleanup(func() { EmbeddedData = old })
+
+	// Binaries with embedded data fall back to it, read-only
+	for _, mode := range []string{"", "snapshot"} {
+		s, _, err := resolveDataDir(missing, configOf(t, map[string]string{"DATA_DIR_MODE": mode}))
+		if err != nil {
+			t.Fatalf("%q: %v", mode, err)
+		}
+		if _, ok := s.(*store.FS); !ok {
+			t.Errorf("%q: expected the embedded store, got %T", mode, s)
+		}
+		if _, err := s.ReadFile("2024/1.json"); err != nil {
+			t.Errorf("%q: expected to read the embedded data, got %v", mode, err)
+		}
+	}
+	if _, _, err := resolveDataDir(missing, configOf(t, map[string]string{"DATA_DIR_MODE": "fail"})); err == nil {
+		t.Error("expected fail mode to refuse a missing directory even with embedded data")
+	}
+}
+
+func TestResolveReadOnlyDataDir(t *testing.T) {
+	dir := setupTestData(t)
+	ds := store.NewDir(dir)
+	if s, _, err := resolveDataDir(ds, Config{}); err != nil || s != ds {
+		t.Fatalf("expected a writable directory to be served as is, got %v, %v", s, err)
+	}
+	if root, ok := localRoot(readOnlyStore{ds}); !ok || root != dir {
+		t.Errorf("expected a read-only directory to still be watched, got %q", root)
+	}
+
+	os.Chmod(dir, 0555)
+	t.Cleanup(func() { os.Chmod(dir, 0755) })
+	if writable(dir) {
+		t.Skip("permissions are not enforced for this user")
+	}
+	s, _, err := resolveDataDir(ds, Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.(store.WritableStore); ok {
+		t.Error("expected a read-only store")
+	}
+	if _, _, err := resolveDataDir(ds, configOf(t, map[string]string{"DATA_DIR_MODE": "fail"})); err == nil {
+		t.Error("expected fail mode to refuse a read-only directory")
+	}
+}
@@ -0,0 +1,140 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"unicode/utf8"
+)
+
+var (
+	utf8BOM    = []byte{0xEF, 0xBB, 0xBF}
+	utf16BOMBE = []byte{0xFE, 0xFF}
+	utf16BOMLE = []byte{0xFF, 0xFE}
+)
+
+// nonFiniteTokens are the non-standard number literals some third-party
+// JSON exports write for NaN and infinite values
+var nonFiniteTokens = [][]byte{
+	[]byte("-Infinity"), []byte("+Infinity"), []byte("Infinity"), []byte("NaN"),
+}
+
+// decodeWeekFile decodes the week file name, tolerating what third-party
+// exports get wrong: a UTF-8 BOM is stripped, invalid UTF-8 is replaced
+// with U+FFFD and NaN or Infinity values are read as null, each with a
+// warning naming the file and field. UTF-16 files are rejected.
+func decodeWeekFile(name string, data []byte) ([]GameStats, error) {
+	data = bytes.TrimPrefix(data, utf8BOM)
+	if bytes.HasPrefix(data, utf16BOMBE) || bytes.HasPrefix(data, utf16BOMLE) {
+		return nil, fmt.Errorf("%s: UTF-16 encoded, expected UTF-8", name)
+	}
+
+	if !utf8.Valid(data) {
+		log.Printf("Warning: %s: invalid UTF-8 near field %q, replaced", name, keyBefore(data, invalidUTF8Offset(data)))
+		data = bytes.ToValidUTF8(data, []byte("\uFFFD"))
+	}
+
+	data, fields := sanitizeNonFinite(data)
+	for _, field := range fields {
+		log.Printf("Warning: %s: field %q is not a finite number, treated as 0", name, field)
+	}
+
+	var gameList []GameStats
+	if err := json.Unmarshal(data, &gameList); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return gameList, nil
+}
+
+// sanitizeNonFinite replaces the NaN and Infinity literals outside of
+// strings with null and returns the keys of the fields holding them
+func sanitizeNonFinite(data []byte) ([]byte, []string) {
+	var out []byte
+	var fields []string
+	lastKey := ""
+	copied := 0
+
+	for i := 0; i < len(data); {
+		if data[i] == '"' {
+			end := stringEnd(data, i)
+			j := end
+			for j < len(data) && isJSONSpace(data[j]) {
+				j++
+			}
+			if j < len(data) && data[j] == ':' {
+				lastKey = string(data[i+1 : end-1])
+			}
+			i = end
+			continue
+		}
+
+		matched := false
+		for _, token := range nonFiniteTokens {
+			if bytes.HasPrefix(data[i:], token) {
+				out = append(append(out, data[copied:i]...), "null"...)
+				fields = append(fields, lastKey)
+				i += len(token)
+				copied = i
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			i++
+		}
+	}
+
+	if out == nil {
+		return data, nil
+	}
+	return append(out, data[copied:]...), fields
+}
+
+// stringEnd returns the index just past the JSON string starting at i
+func stringEnd(data []byte, i int) int {
+	for j := i + 1; j < len(data); j++ {
+		switch data[j] {
+		case '\\':
+			j++
+		case '"':
+			return j + 1
+		}
+	}
+	return len(data)
+}
+
+func isJSONSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// invalidUTF8Offset returns the offset of the first invalid UTF-8 sequence
+func invalidUTF8Offset(data []byte) int {
+	for i := 0; i < len(data); {
+		r, size := utf8.DecodeRune(data[i:])
+		if r == utf8.RuneError && size == 1 {
+			return i
+		}
+		i += size
+	}
+	return len(data)
+}
+
+// keyBefore returns the last object key preceding offset, to locate a
+// problem in a file
+func keyBefore(data []byte, offset int) string {
+	for end := offset - 1; end > 0; end-- {
+		if data[end] != ':' {
+			continue
+		}
+		close := bytes.LastIndexByte(data[:end], '"')
+		if close <= 0 {
+			return ""
+		}
+		open := bytes.LastIndexByte(data[:close], '"')
+		if open < 0 {
+			return ""
+		}
+		return string(data[open+1 : close])
+	}
+	return ""
+}
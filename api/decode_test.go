@@ -0,0 +1,62 @@
+package api
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDecodeWeekFileStripsBOM(t *testing.T) {
+	games, err := decodeWeekFile("2024/1.json", append([]byte{0xEF, 0xBB, 0xBF}, `[{"id":"a"}]`...))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(games) != 1 || games[0].ID != "a" {
+		t.Errorf("unexpected games %+v", games)
+	}
+}
+
+func TestDecodeWeekFileRejectsUTF16(t *testing.T) {
+	_, err := decodeWeekFile("2024/1.json", []byte{0xFF, 0xFE, '[', 0, ']', 0})
+	if err == nil || !strings.Contains(err.Error(), "2024/1.json") {
+		t.Errorf("expected an error naming the file, got %v", err)
+	}
+}
+
+func TestDecodeWeekFileSanitizesNonFinite(t *testing.T) {
+	data := `[{"id":"a","fullName":"NaN @ Infinity","offense":{"homeQBR":NaN,"awayQBR":-Infinity,"totalPoints":50}}]`
+	games, err := decodeWeekFile("2024/1.json", []byte(data))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	g := games[0]
+	if g.Offense.HomeQBR != 0 || g.Offense.AwayQBR != 0 || g.Offense.TotalPoints != 50 {
+		t.Errorf("expected non-finite values read as 0, got %+v", g.Offense)
+	}
+	if g.FullName != "NaN @ Infinity" {
+		t.Errorf("expected strings to be left alone, got %q", g.FullName)
+	}
+}
+
+func TestSanitizeNonFiniteReportsFields(t *testing.T) {
+	_, fields := sanitizeNonFinite([]byte(`{"a": 1, "b\"": NaN, "c": [Infinity]}`))
+	if strings.Join(fields, ",") != `b\",c` {
+		t.Errorf("unexpected fields %q", fields)
+	}
+}
+
+func TestDecodeWeekFileReplacesInvalidUTF8(t *testing.T) {
+	games, err := decodeWeekFile("2024/1.json", []byte("[{\"id\":\"a\",\"fullName\":\"Caf\xe9\"}]"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if games[0].FullName != "Caf\uFFFD" {
+		t.Errorf("expected the invalid byte to be replaced, got %q", games[0].FullName)
+	}
+}
+
+func TestDecodeWeekFileNamesFileOnSyntaxError(t *testing.T) {
+	_, err := decodeWeekFile("2024/3.json", []byte(`[{"id": }]`))
+	if err == nil || !strings.HasPrefix(err.Error(), "2024/3.json: ") {
+		t.Errorf("expected an error prefixed with the file, got %v", err)
+	}
+}
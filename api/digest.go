@@ -0,0 +1,73 @@
+package api
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"strconv"
+
+	"github.com/jjway/rewatchableGamesApi-go/store"
+)
+
+// DigestPreview is what the notification channels would send for a week
+type DigestPreview struct {
+	Kind     string           `json:"kind"`
+	Year     string           `json:"year"`
+	Week     string           `json:"week"`
+	Title    string           `json:"title"`
+	Games    int              `json:"games"`
+	Channels []ChannelPreview `json:"channels"`
+}
+
+// handleDigestPreview renders the week-published digest of ?year= and
+// ?week= from the current data with every configured channel's template,
+// without sending anything, so editors can review it before the send
+func handleDigestPreview(w http.ResponseWriter, r *http.Request) {
+	year, week := r.URL.Query().Get("year"), r.URL.Query().Get("week")
+	if _, err := strconv.Atoi(year); err != nil {
+		writeQueryError(w, r, &QueryError{Param: "year", Value: year, Message: "must be a season year"})
+		return
+	}
+	if !isValidWeek(week) {
+		writeQueryError(w, r, &QueryError{Param: "week", Value: week, Message: invalidWeekMessage})
+		return
+	}
+
+	games, err := loadGameStats(store.WeekFile(year, week))
+	if errors.Is(err, fs.ErrNotExist) {
+		writeError(w, r, http.StatusNotFound, "no data for week "+week+" of "+year)
+		return
+	}
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "error reading data")
+		return
+	}
+
+	ev := weekPublishedEvent(year, week, games)
+	preview := DigestPreview{
+		Kind:     ev.Kind,
+		Year:     year,
+		Week:     week,
+		Title:    ev.Title,
+		Games:    len(ev.Games),
+		Channels: []ChannelPreview{},
+	}
+	for _, n := range activeNotifiers() {
+		p, ok := n.(previewer)
+		if !ok {
+			continue
+		}
+		cp, err := p.Preview(ev)
+		if err != nil {
+			writeError(w, r, http.StatusUnprocessableEntity, "channel "+n.Name()+": "+err.Error())
+			return
+		}
+		preview.Channels = append(preview.Channels, cp)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(preview); err != nil {
+		writeError(w, r, http.StatusInternalServerError, "error encoding response")
+	}
+}
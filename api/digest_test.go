@@ -0,0 +1,58 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandleDigestPreview(t *testing.T) {
+	useTestStore(t, setupTestData(t))
+
+	var configs []Notifier
+	for _, cfg := range []ChannelConfig{
+		{Type: "discord", Name: "editors", URL: "http://discord.invalid/hook", Template: "{{.Title}}: {{(index .Games 0).ShortName}}", Retry: RetryPolicy{MaxAttempts: 3}},
+		{Type: "email", SMTPAddr: "smtp.invalid:25", From: "bot@example.com", To: []string{"fans@example.com"}},
+	} {
+		n, err := newNotifier(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		configs = append(configs, n)
+	}
+	old := notifiers
+	notifiers = configs
+	t.Cleanup(func() { notifiers = old })
+
+	rec := httptest.NewRecorder()
+	handleDigestPreview(rec, httptest.NewRequest("GET", "/admin/digest/preview?year=2024&week=1", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var preview DigestPreview
+	if err := json.Unmarshal(rec.Body.Bytes(), &preview); err != nil {
+		t.Fatal(err)
+	}
+	if preview.Title != "Week 1 of 2024 is out" || preview.Games != 1 || len(preview.Channels) != 2 {
+		t.Fatalf("unexpected preview %+v", preview)
+	}
+	if c := preview.Channels[0]; c.Channel != "editors" || c.Body != `{"content":"Week 1 of 2024 is out: A @ B"}` {
+		t.Errorf("unexpected discord preview %+v", c)
+	}
+	if c := preview.Channels[1]; c.ContentType != "message/rfc822" || !strings.Contains(c.Body, "Subject: Week 1 of 2024 is out\r\n") || !strings.Contains(c.Body, "1. A @ B (21.5)") {
+		t.Errorf("unexpected email preview %+v", c)
+	}
+
+	for target, status := range map[string]int{
+		"/admin/digest/preview?year=2024&week=9":  http.StatusNotFound,
+		"/admin/digest/preview?year=2024&week=42": http.StatusBadRequest,
+		"/admin/digest/preview?week=1":            http.StatusBadRequest,
+	} {
+		rec := httptest.NewRecorder()
+		handleDigestPreview(rec, httptest.NewRequest("GET", target, nil))
+		if rec.Code != status {
+			t.Errorf("%s: expected status %d, got %d", target, status, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// DryRunReport is what a mutating operation would change, served in place
+// of its result with ?dryRun=true and printed by the commands run with
+// --dry-run. Nothing is written, invalidated or sent.
+type DryRunReport struct {
+	DryRun        bool     `json:"dryRun"`
+	Operation     string   `json:"operation"`
+	Writes        []string `json:"writes"`        // store names and files written
+	Deletes       []string `json:"deletes"`       // store names and files removed
+	Invalidations []string `json:"invalidations"` // cache entries dropped
+	Notifications []string `json:"notifications"` // events, channels and webhooks called
+}
+
+func newDryRunReport(operation string) *DryRunReport {
+	return &DryRunReport{
+		DryRun:        true,
+		Operation:     operation,
+		Writes:        []string{},
+		Deletes:       []string{},
+		Invalidations: []string{},
+		Notifications: []string{},
+	}
+}
+
+// dryRunRequested parses ?dryRun=
+func dryRunRequested(r *http.Request) (bool, *QueryError) {
+	v := r.URL.Query().Get("dryRun")
+	if v == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, &QueryError{Param: "dryRun", Value: v, Message: "must be true or false"}
+	}
+	return b, nil
+}
+
+// invalidate records the cache entries setCached drops for name
+func (rep *DryRunReport) invalidate(name string) {
+	season, _, _ := strings.Cut(name, "/")
+	if _, resident := cache.peek(name); resident {
+		rep.Invalidations = append(rep.Invalidations, "week "+name)
+	}
+	if knownMissing(name) {
+		rep.Invalidations = append(rep.Invalidations, "missing "+name)
+	}
+	rep.Invalidations = append(rep.Invalidations, "responses "+name)
+	if _, known := cache.weekCounts()[name]; !known {
+		rep.Invalidations = append(rep.Invalidations, "responses "+season+"/")
+	}
+	rep.Invalidations = append(rep.Invalidations, "top games", "quantiles")
+}
+
+// storeWeek records what storeWeek does for name: the week written with
+// its snapshot and the static publish, and the cache entries dropped
+func (rep *DryRunReport) storeWeek(name string) {
+	rep.Writes = append(rep.Writes, name)
+	if snapshots != nil {
+		rep.Writes = append(rep.Writes, "snapshot "+name)
+	}
+	if snapshotPublisher != nil {
+		rep.Writes = append(rep.Writes, "publish")
+	}
+	rep.invalidate(name)
+}
+
+// event records the /events message of kind for name
+func (rep *DryRunReport) event(kind, name string) {
+	rep.Notifications = append(rep.Notifications, "event "+kind+" "+name)
+}
+
+// notifyAll records the channels and webhooks notifyAll calls
+func (rep *DryRunReport) notifyAll() {
+	for _, n := range activeNotifiers() {
+		rep.Notifications = append(rep.Notifications, "notifier "+n.Name())
+	}
+}
+
+// writeFile records a file written when path is set, as the registries
+// only persist then
+func (rep *DryRunReport) writeFile(path string) {
+	if path != "" {
+		rep.Writes = append(rep.Writes, path)
+	}
+}
+
+// printDryRun prints rep for the commands run with --dry-run
+func printDryRun(w io.Writer, rep *DryRunReport) error {
+	data, err := json.MarshalIndent(rep, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
+
+// writeDryRun serves rep as the response of a dry run
+func writeDryRun(w http.ResponseWriter, r *http.Request, rep *DryRunReport) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(rep); err != nil {
+		writeError(w, r, http.StatusInternalServerError, "error encoding response")
+	}
+}
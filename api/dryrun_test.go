@@ -0,0 +1,147 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func decodeDryRun(t *testing.T, rec *httptest.ResponseRecorder) DryRunReport {
+	t.Helper()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body)
+	}
+	var rep DryRunReport
+	if err := json.Unmarshal(rec.Body.Bytes(), &rep); err != nil {
+		t.Fatal(err)
+	}
+	if !rep.DryRun {
+		t.Errorf("expected a dry run report, got %s", rec.Body)
+	}
+	return rep
+}
+
+func TestIngestDryRun(t *testing.T) {
+	dir := setupTestData(t)
+	useTestStore(t, dir)
+	loadGameStats("2024/1.json")
+	old := notifiers
+	notifiers = []Notifier{&webhookNotifier{name: "ops"}}
+	t.Cleanup(func() { notifiers = old })
+
+	rec := ingest(t, "/games/2024/1?dryRun=true", `[{"id": "fixed", "shortName": "BUF @ KC"}]`)
+	rep := decodeDryRun(t, rec)
+	if rep.Operation != "ingest 2024/1.json" || !slices.Equal(rep.Writes, []string{"2024/1.json"}) {
+		t.Errorf("unexpected report %+v", rep)
+	}
+	for _, want := range []string{"week 2024/1.json", "responses 2024/1.json", "top games"} {
+		if !slices.Contains(rep.Invalidations, want) {
+			t.Errorf("expected %q in %v", want, rep.Invalidations)
+		}
+	}
+	if !slices.Equal(rep.Notifications, []string{"event ingested 2024/1.json", "notifier ops"}) {
+		t.Errorf("unexpected notifications %v", rep.Notifications)
+	}
+
+	// Nothing changed
+	if games, _ := loadGameStats("2024/1.json"); games[0].ID != "game1" {
+		t.Errorf("expected the cached week untouched, got %v", games)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "2024", "1.json")); strings.Contains(string(data), "fixed") {
+		t.Error("expected the week file untouched")
+	}
+
+	// A dry run is validated like the real upload
+	if rec := ingest(t, "/games/2024/1?dryRun=true", `{}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected status 422 for an invalid payload, got %d", rec.Code)
+	}
+	if rec := ingest(t, "/games/2024/1?dryRun=maybe", `[]`); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an invalid dryRun, got %d", rec.Code)
+	}
+}
+
+func TestDryRunSkipsIdempotency(t *testing.T) {
+	useTestStore(t, setupTestData(t))
+	handler := withIdempotency(http.HandlerFunc(handleIngestWeek))
+	post := func(url string) *httptest.ResponseRecorder {
+		mux := http.NewServeMux()
+		mux.Handle("POST /games/{year}/{week}", handler)
+		req := httptest.NewRequest("POST", url, strings.NewReader(`[{"id": "g3", "shortName": "BUF @ KC"}]`))
+		req.Header.Set("Idempotency-Key", "week-3")
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	decodeDryRun(t, post("/games/2024/3?dryRun=true"))
+	if rec := post("/games/2024/3"); rec.Code != http.StatusCreated {
+		t.Errorf("expected the real upload to run after its dry run, got %d", rec.Code)
+	}
+}
+
+func TestPurgeAndBackfillDryRun(t *testing.T) {
+	useTestStore(t, setupTestData(t))
+	loadGameStats("2024/1.json")
+
+	rec := httptest.NewRecorder()
+	handleCachePurge(rec, httptest.NewRequest("POST", "/admin/cache/purge?year=2024&dryRun=true", nil))
+	rep := decodeDryRun(t, rec)
+	if !slices.Contains(rep.Invalidations, "week 2024/2.json") || len(rep.Writes) != 0 {
+		t.Errorf("unexpected purge report %+v", rep)
+	}
+
+	backfillMu.Lock()
+	oldJob := backfillJob
+	backfillJob = nil
+	backfillMu.Unlock()
+	t.Cleanup(func() { backfillJob = oldJob })
+
+	rec = httptest.NewRecorder()
+	handleStartBackfill(rec, httptest.NewRequest("POST", "/admin/backfill?year=2024&dryRun=true", nil))
+	rep = decodeDryRun(t, rec)
+	if !slices.Equal(rep.Writes, []string{"2024/1.json", "2024/2.json", provenanceFile}) {
+		t.Errorf("unexpected backfill report %+v", rep)
+	}
+	backfillMu.Lock()
+	started := backfillJob != nil
+	backfillMu.Unlock()
+	if started {
+		t.Error("expected no backfill job to start")
+	}
+
+	rec = httptest.NewRecorder()
+	handleStartBackfill(rec, httptest.NewRequest("POST", "/admin/backfill?year=1999&dryRun=true", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for a season without data, got %d", rec.Code)
+	}
+}
+
+func TestCompactDryRun(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "2023"), 0755)
+	os.WriteFile(filepath.Join(dir, "2023", "1.json"), []byte(testData), 0644)
+
+	if _, err := compactDryRun(dir, "2023", false, true); err == nil {
+		t.Error("expected an incomplete season to be refused")
+	}
+	rep, err := compactDryRun(dir, "2023", true, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rep.Writes) != 2 || !slices.Equal(rep.Deletes, []string{filepath.Join(dir, "2023", "1.json")}) {
+		t.Errorf("unexpected report %+v", rep)
+	}
+
+	var out bytes.Buffer
+	if err := printDryRun(&out, rep); err != nil || !strings.Contains(out.String(), `"operation": "compact 2023"`) {
+		t.Errorf("unexpected output %q, %v", out.String(), err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "2023", "1.json")); err != nil {
+		t.Errorf("expected the week file untouched, got %v", err)
+	}
+}
@@ -0,0 +1,543 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jjway/rewatchableGamesApi-go/store"
+)
+
+// defaultESPNURL is the public site API the fetcher reads from
+const defaultESPNURL = "https://site.api.espn.com/apis/site/v2/sports/football/nfl"
+
+// ESPN season types of the regular season and the playoffs
+const (
+	espnRegularSeason = 2
+	espnPostseason    = 3
+)
+
+// espnPostseasonWeeks maps the playoff rounds to ESPN postseason week
+// numbers; week 4 is the Pro Bowl, which is not stored
+var espnPostseasonWeeks = map[string]int{
+	"wildcard":   1,
+	"divisional": 2,
+	"conference": 3,
+	"superbowl":  5,
+}
+
+// Play yardage thresholds of big and explosive plays
+const (
+	bigPlayYards       = 20
+	explosivePlayYards = 40
+)
+
+// espnFetcher pulls the current week from ESPN and stores it as a week
+// file. Stats ESPN does not publish, such as team efficiency and the
+// scenario rating, are left at zero.
+type espnFetcher struct {
+	baseURL string
+	client  *http.Client
+
+	// mu serializes fetches from the ticker and /admin/refresh
+	mu sync.Mutex
+}
+
+func newESPNFetcher(baseURL string) *espnFetcher {
+	if baseURL == "" {
+		baseURL = defaultESPNURL
+	}
+	return &espnFetcher{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		client:  &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// fetcher is set at startup when ESPN_FETCH_INTERVAL is configured
+var fetcher *espnFetcher
+
+// espnAPIURL is the ESPN_API_URL of the fetches, from the environment for
+// the commands and from the settings for the server
+var espnAPIURL = os.Getenv("ESPN_API_URL")
+
+// espnScoreboard is the subset of the scoreboard response we need
+type espnScoreboard struct {
+	Season struct {
+		Year int `json:"year"`
+		Type int `json:"type"`
+	} `json:"season"`
+	Week struct {
+		Number int `json:"number"`
+	} `json:"week"`
+	Events []espnEvent `json:"events"`
+}
+
+type espnEvent struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	ShortName string `json:"shortName"`
+	Status    struct {
+		Period       int    `json:"period"`
+		DisplayClock string `json:"displayClock"`
+		Type         struct {
+			State     string `json:"state"` // pre, in or post
+			Completed bool   `json:"completed"`
+		} `json:"type"`
+	} `json:"status"`
+	Competitions []struct {
+		Competitors []struct {
+			HomeAway string `json:"homeAway"`
+			Score    string `json:"score"`
+		} `json:"competitors"`
+	} `json:"competitions"`
+}
+
+// espnSummary is the subset of the game summary response we need
+type espnSummary struct {
+	Boxscore struct {
+		Teams []struct {
+			HomeAway   string `json:"homeAway"`
+			Statistics []struct {
+				Name         string `json:"name"`
+				DisplayValue string `json:"displayValue"`
+			} `json:"statistics"`
+		} `json:"teams"`
+		Players []struct {
+			HomeAway   string          `json:"homeAway"`
+			Statistics []espnStatGroup `json:"statistics"`
+		} `json:"players"`
+	} `json:"boxscore"`
+	ScoringPlays []struct {
+		Period struct {
+			Number int `json:"number"`
+		} `json:"period"`
+		HomeScore int `json:"homeScore"`
+		AwayScore int `json:"awayScore"`
+	} `json:"scoringPlays"`
+	WinProbability []struct {
+		HomeWinPercentage float64 `json:"homeWinPercentage"`
+		PlayID            string  `json:"playId"`
+	} `json:"winprobability"`
+	Drives struct {
+		Previous []struct {
+			Plays []struct {
+				ID          string  `json:"id"`
+				StatYardage float64 `json:"statYardage"`
+				Period      struct {
+					Number int `json:"number"`
+				} `json:"period"`
+			} `json:"plays"`
+		} `json:"previous"`
+	} `json:"drives"`
+}
+
+// espnStatGroup is a player stat table of a team, e.g. passing
+type espnStatGroup struct {
+	Name     string   `json:"name"`
+	Labels   []string `json:"labels"`
+	Athletes []struct {
+		Stats []string `json:"stats"`
+	} `json:"athletes"`
+}
+
+// FetchResult summarizes one fetch
+type FetchResult struct {
+	Season    string `json:"season"`
+	Week      string `json:"week"`
+	Games     int    `json:"games"`
+	Completed int    `json:"completed"`
+	Stored    bool   `json:"stored"`
+}
+
+func (f *espnFetcher) getJSON(ctx context.Context, path string, query url.Values, v any) error {
+	u := f.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: unexpected status %d", u, resp.StatusCode)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// fetchWeek downloads a week or playoff round, the current one when year
+// and week are empty, and stores its completed games. A week with games
+// still to play is stored and marked in progress.
+func (f *espnFetcher) fetchWeek(ctx context.Context, ws store.WritableStore, year, week string) (FetchResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	result, games, err := f.fetchGames(ctx, year, week)
+	if err != nil || len(games) == 0 {
+		return result, err
+	}
+
+	data, err := json.Marshal(games)
+	if err != nil {
+		return result, err
+	}
+	name := store.WeekFile(result.Season, result.Week)
+	ingestMu.Lock()
+	_, err = ingestWeek(ws, name, games, data)
+	ingestMu.Unlock()
+	if err != nil {
+		return result, err
+	}
+	if result.Completed < result.Games {
+		setWeekState(name, weekInProgress)
+	}
+	result.Stored = true
+	return result, nil
+}
+
+// fetchGames downloads the completed games of a week or playoff round, the
+// current one when year and week are empty, without storing them
+func (f *espnFetcher) fetchGames(ctx context.Context, year, week string) (FetchResult, []GameStats, error) {
+	query := url.Values{}
+	if year != "" && week != "" {
+		query.Set("dates", year)
+		if n, ok := espnPostseasonWeeks[week]; ok {
+			query.Set("seasontype", strconv.Itoa(espnPostseason))
+			query.Set("week", strconv.Itoa(n))
+		} else {
+			query.Set("seasontype", strconv.Itoa(espnRegularSeason))
+			query.Set("week", week)
+		}
+	}
+	var board espnScoreboard
+	if err := f.getJSON(ctx, "/scoreboard", query, &board); err != nil {
+		return FetchResult{}, nil, err
+	}
+
+	season, week, ok := board.seasonWeek()
+	result := FetchResult{Season: season, Week: week, Games: len(board.Events)}
+	if !ok {
+		return result, nil, nil
+	}
+	order, _ := weekOrder(result.Week)
+
+	var games []GameStats
+	for _, ev := range board.Events {
+		if !ev.Status.Type.Completed {
+			continue
+		}
+		var summary espnSummary
+		if err := f.getJSON(ctx, "/summary", url.Values{"event": {ev.ID}}, &summary); err != nil {
+			return result, nil, fmt.Errorf("event %s: %w", ev.ID, err)
+		}
+		games = append(games, espnGameStats(order, ev, summary))
+	}
+	result.Completed = len(games)
+	return result, games, nil
+}
+
+// seasonWeek returns the season and the week name of the scoreboard, ok
+// false for the preseason and the Pro Bowl, which are not stored
+func (b espnScoreboard) seasonWeek() (season, week string, ok bool) {
+	season = strconv.Itoa(b.Season.Year)
+	switch b.Season.Type {
+	case espnRegularSeason:
+		return season, strconv.Itoa(b.Week.Number), true
+	case espnPostseason:
+		for name, n := range espnPostseasonWeeks {
+			if n == b.Week.Number {
+				return season, name, true
+			}
+		}
+		return season, "", false
+	}
+	return season, strconv.Itoa(b.Week.Number), false
+}
+
+// espnGameStats transforms an ESPN game into GameStats, week being the
+// position of the week in the season
+func espnGameStats(week int, ev espnEvent, s espnSummary) GameStats {
+	g := GameStats{ID: ev.ID, Week: week, FullName: ev.Name, ShortName: ev.ShortName}
+
+	var homeScore, awayScore float64
+	if len(ev.Competitions) > 0 {
+		for _, c := range ev.Competitions[0].Competitors {
+			score, _ := strconv.ParseFloat(c.Score, 64)
+			if c.HomeAway == "home" {
+				homeScore = score
+			} else {
+				awayScore = score
+			}
+		}
+	}
+	g.Offense.TotalPoints = homeScore + awayScore
+	g.Scenario.MarginOfVictory = math.Abs(homeScore - awayScore)
+
+	// Team totals are summed over both teams
+	for _, team := range s.Boxscore.Teams {
+		for _, st := range team.Statistics {
+			v := leadingNumber(st.DisplayValue)
+			switch st.Name {
+			case "totalOffensivePlays":
+				g.Offense.TotalPlays += v
+			case "totalYards":
+				g.Offense.TotalYards += v
+			case "netPassingYards":
+				g.Offense.TotalPassYards += v
+			case "rushingYards":
+				g.Offense.TotalRushYards += v
+			case "interceptions":
+				g.Defense.Interceptions += v
+			case "fumblesLost":
+				g.Defense.FumbleRecs += v
+			case "defensiveTouchdowns":
+				g.Defense.DefensiveTds += v
+			case "sacksYardsLost":
+				g.Defense.Sacks += v
+			}
+		}
+	}
+	if g.Offense.TotalPlays > 0 {
+		g.Offense.TotalYardsPerAttempt = round2(g.Offense.TotalYards / g.Offense.TotalPlays)
+	}
+
+	// Passer rating of each team's leading passer
+	for _, team := range s.Boxscore.Players {
+		rating := passerRating(team.Statistics)
+		if team.HomeAway == "home" {
+			g.Offense.HomeQBR = rating
+		} else {
+			g.Offense.AwayQBR = rating
+		}
+	}
+
+	periods := make(map[string]int)
+	for _, drive := range s.Drives.Previous {
+		for _, play := range drive.Plays {
+			periods[play.ID] = play.Period.Number
+			if play.StatYardage >= bigPlayYards {
+				g.Offense.OffensiveBigPlays++
+			}
+			if play.StatYardage >= explosivePlayYards {
+				g.Offense.OffensiveExplosivePlays++
+			}
+		}
+	}
+	if g.Offense.TotalPlays > 0 {
+		g.Offense.ExplosiveRate = round2(g.Offense.OffensiveExplosivePlays / g.Offense.TotalPlays)
+	}
+
+	// Lead changes from the scoring plays
+	leader := 0
+	for _, play := range s.ScoringPlays {
+		now := sign(float64(play.HomeScore - play.AwayScore))
+		if now != 0 && leader != 0 && now != leader {
+			g.Scenario.LeadershipChange++
+			if play.Period.Number >= 4 {
+				g.Scenario.FourthQuarterLeadershipChange++
+			}
+		}
+		if now != 0 {
+			leader = now
+		}
+	}
+
+	// Win probability from the eventual winner's side
+	homeWon := homeScore >= awayScore
+	sd := &g.Scenario.ScenarioData
+	sd.MinWinProbability, sd.Min4th = 1, 1
+	seen4th := false
+	prev := 0
+	for _, wp := range s.WinProbability {
+		p := wp.HomeWinPercentage
+		if !homeWon {
+			p = 1 - p
+		}
+		fourth := periods[wp.PlayID] >= 4
+		sd.MaxWinProbability = math.Max(sd.MaxWinProbability, p)
+		sd.MinWinProbability = math.Min(sd.MinWinProbability, p)
+		if fourth {
+			seen4th = true
+			sd.Max4th = math.Max(sd.Max4th, p)
+			sd.Min4th = math.Min(sd.Min4th, p)
+		}
+		now := sign(p - 0.5)
+		if now != 0 && prev != 0 && now != prev {
+			sd.InversionOfLead++
+			if fourth {
+				sd.Inv4th++
+			}
+		}
+		if now != 0 {
+			prev = now
+		}
+	}
+	if len(s.WinProbability) == 0 {
+		sd.MinWinProbability = 0
+	}
+	if !seen4th {
+		sd.Min4th = 0
+	}
+	return g
+}
+
+// passerRating returns the RTG of the first passer listed, ESPN listing the
+// starter first
+func passerRating(stats []espnStatGroup) float64 {
+	for _, st := range stats {
+		if st.Name != "passing" || len(st.Athletes) == 0 {
+			continue
+		}
+		for i, label := range st.Labels {
+			if label == "RTG" && i < len(st.Athletes[0].Stats) {
+				return leadingNumber(st.Athletes[0].Stats[i])
+			}
+		}
+	}
+	return 0
+}
+
+// leadingNumber parses the first number of an ESPN display value, e.g. 3
+// for "3-21"
+func leadingNumber(s string) float64 {
+	end := 0
+	for end < len(s) && (s[end] == '.' || (s[end] >= '0' && s[end] <= '9')) {
+		end++
+	}
+	v, _ := strconv.ParseFloat(s[:end], 64)
+	return v
+}
+
+func sign(v float64) int {
+	switch {
+	case v > 0:
+		return 1
+	case v < 0:
+		return -1
+	}
+	return 0
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// runFetcher fetches the current week every interval until ctx is done
+func runFetcher(ctx context.Context, f *espnFetcher, ws store.WritableStore, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if result, err := f.fetchWeek(ctx, ws, "", ""); err != nil {
+			log.Printf("Warning: ESPN fetch failed: %v", err)
+		} else if result.Stored {
+			log.Printf("Fetched week %s of %s from ESPN (%d/%d games final)", result.Week, result.Season, result.Completed, result.Games)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// startFetcher starts the scheduled fetch when ESPN_FETCH_INTERVAL is set
+func startFetcher(ctx context.Context, cfg Config) error {
+	espnAPIURL = cfg.Ingest.ESPNAPIURL
+	conditionsProviderSpec = cfg.Ingest.ConditionsProvider
+	interval := cfg.Ingest.ESPNFetchInterval
+	if interval == 0 {
+		return nil
+	}
+	if err := checkInterval("ESPN_FETCH_INTERVAL", interval, minFetchInterval); err != nil {
+		return err
+	}
+	ws, ok := dataStore.(store.WritableStore)
+	if !ok {
+		return fmt.Errorf("ESPN_FETCH_INTERVAL needs a writable store")
+	}
+	fetcher = newESPNFetcher(espnAPIURL)
+	go runFetcher(ctx, fetcher, ws, interval)
+	log.Printf("Fetching from ESPN every %s", interval)
+	return nil
+}
+
+// handleRefresh fetches the current week from ESPN now, or the week of
+// ?year= and ?week=. With ?dryRun=true the week is fetched but not stored.
+func handleRefresh(w http.ResponseWriter, r *http.Request) {
+	ws, ok := dataStore.(store.WritableStore)
+	if !ok {
+		writeError(w, r, http.StatusNotImplemented, "the configured store is read-only")
+		return
+	}
+	f := fetcher
+	if f == nil {
+		f = newESPNFetcher(espnAPIURL)
+	}
+
+	year, week := r.URL.Query().Get("year"), r.URL.Query().Get("week")
+	if (year == "") != (week == "") {
+		writeQueryError(w, r, &QueryError{Param: "week", Value: week, Message: "year and week go together"})
+		return
+	}
+	if year != "" {
+		if _, err := strconv.Atoi(year); err != nil {
+			writeQueryError(w, r, &QueryError{Param: "year", Value: year, Message: "must be a season year"})
+			return
+		}
+		if !isValidWeek(week) {
+			writeQueryError(w, r, &QueryError{Param: "week", Value: week, Message: invalidWeekMessage})
+			return
+		}
+	}
+
+	dryRun, qerr := dryRunRequested(r)
+	if qerr != nil {
+		writeQueryError(w, r, qerr)
+		return
+	}
+	if dryRun {
+		result, games, err := f.fetchGames(r.Context(), year, week)
+		if err != nil {
+			log.Printf("Warning: ESPN refresh failed: %v", err)
+			writeError(w, r, http.StatusBadGateway, "fetch from ESPN failed")
+			return
+		}
+		rep := newDryRunReport("refresh " + result.Season + " " + result.Week)
+		if len(games) > 0 {
+			name := store.WeekFile(result.Season, result.Week)
+			rep.storeWeek(name)
+			rep.event("ingested", name)
+		}
+		writeDryRun(w, r, rep)
+		return
+	}
+
+	result, err := f.fetchWeek(r.Context(), ws, year, week)
+	if err != nil {
+		log.Printf("Warning: ESPN refresh failed: %v", err)
+		writeError(w, r, http.StatusBadGateway, "fetch from ESPN failed")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		writeError(w, r, http.StatusInternalServerError, "error encoding response")
+	}
+}
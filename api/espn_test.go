@@ -0,0 +1,196 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jjway/rewatchableGamesApi-go/store"
+)
+
+const testScoreboard = `{
+	"season": {"year": 2024, "type": 2},
+	"week": {"number": 5},
+	"events": [
+		{"id": "401", "name": "Buffalo Bills at Kansas City Chiefs", "shortName": "BUF @ KC",
+		 "status": {"type": {"completed": true}},
+		 "competitions": [{"competitors": [
+			{"homeAway": "home", "score": "27"},
+			{"homeAway": "away", "score": "24"}]}]},
+		{"id": "402", "name": "Detroit Lions at Green Bay Packers", "shortName": "DET @ GB",
+		 "status": {"type": {"completed": false}},
+		 "competitions": [{"competitors": [
+			{"homeAway": "home", "score": "0"},
+			{"homeAway": "away", "score": "0"}]}]}
+	]
+}`
+
+const testSummary = `{
+	"boxscore": {
+		"teams": [
+			{"homeAway": "away", "statistics": [
+				{"name": "totalOffensivePlays", "displayValue": "60"},
+				{"name": "totalYards", "displayValue": "350"},
+				{"name": "interceptions", "displayValue": "1"},
+				{"name": "sacksYardsLost", "displayValue": "2-14"}]},
+			{"homeAway": "home", "statistics": [
+				{"name": "totalOffensivePlays", "displayValue": "65"},
+				{"name": "totalYards", "displayValue": "400"},
+				{"name": "sacksYardsLost", "displayValue": "3-21"}]}
+		],
+		"players": [
+			{"homeAway": "home", "statistics": [
+				{"name": "passing", "labels": ["C/ATT", "YDS", "RTG"], "athletes": [{"stats": ["20/30", "250", "101.5"]}]}]}
+		]
+	},
+	"scoringPlays": [
+		{"period": {"number": 1}, "homeScore": 7, "awayScore": 0},
+		{"period": {"number": 2}, "homeScore": 7, "awayScore": 10},
+		{"period": {"number": 4}, "homeScore": 14, "awayScore": 10},
+		{"period": {"number": 4}, "homeScore": 14, "awayScore": 17},
+		{"period": {"number": 4}, "homeScore": 27, "awayScore": 24}
+	],
+	"winprobability": [
+		{"homeWinPercentage": 0.6, "playId": "1"},
+		{"homeWinPercentage": 0.3, "playId": "2"},
+		{"homeWinPercentage": 0.2, "playId": "3"},
+		{"homeWinPercentage": 1, "playId": "4"}
+	],
+	"drives": {"previous": [{"plays": [
+		{"id": "1", "statYardage": 45, "period": {"number": 1}},
+		{"id": "2", "statYardage": 22, "period": {"number": 2}},
+		{"id": "3", "statYardage": 5, "period": {"number": 4}},
+		{"id": "4", "statYardage": 8, "period": {"number": 4}}
+	]}]}
+}`
+
+// useESPN points the fetches at url for the test
+func useESPN(t *testing.T, url string) {
+	t.Helper()
+	old := espnAPIURL
+	espnAPIURL = url
+	t.Cleanup(func() { espnAPIURL = old })
+}
+
+func newTestESPN(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/scoreboard":
+			w.Write([]byte(testScoreboard))
+		case "/summary":
+			if r.URL.Query().Get("event") != "401" {
+				t.Errorf("unexpected summary request for %q", r.URL.Query().Get("event"))
+			}
+			w.Write([]byte(testSummary))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestESPNGameStats(t *testing.T) {
+	var board espnScoreboard
+	var summary espnSummary
+	if err := json.Unmarshal([]byte(testScoreboard), &board); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(testSummary), &summary); err != nil {
+		t.Fatal(err)
+	}
+
+	g := espnGameStats(5, board.Events[0], summary)
+	checks := []struct {
+		name      string
+		got, want float64
+	}{
+		{"totalPoints", g.Offense.TotalPoints, 51},
+		{"marginOfVictory", g.Scenario.MarginOfVictory, 3},
+		{"totalPlays", g.Offense.TotalPlays, 125},
+		{"totalYards", g.Offense.TotalYards, 750},
+		{"totalYardsPerAttempt", g.Offense.TotalYardsPerAttempt, 6},
+		{"sacks", g.Defense.Sacks, 5},
+		{"interceptions", g.Defense.Interceptions, 1},
+		{"homeQBR", g.Offense.HomeQBR, 101.5},
+		{"offensiveBigPlays", g.Offense.OffensiveBigPlays, 2},
+		{"offensiveExplosivePlays", g.Offense.OffensiveExplosivePlays, 1},
+		{"leadershipChange", g.Scenario.LeadershipChange, 4},
+		{"fourthQuarterLeadershipChange", g.Scenario.FourthQuarterLeadershipChange, 3},
+		{"maxWinProbability", g.Scenario.ScenarioData.MaxWinProbability, 1},
+		{"minWinProbability", g.Scenario.ScenarioData.MinWinProbability, 0.2},
+		{"inversionOfLead", g.Scenario.ScenarioData.InversionOfLead, 2},
+		{"inv_4th", g.Scenario.ScenarioData.Inv4th, 1},
+		{"min_4th", g.Scenario.ScenarioData.Min4th, 0.2},
+	}
+	for _, c := range checks {
+		if c.got != c.want {
+			t.Errorf("%s: expected %v, got %v", c.name, c.want, c.got)
+		}
+	}
+	if g.ID != "401" || g.Week != 5 || g.ShortName != "BUF @ KC" {
+		t.Errorf("unexpected identity %q week %d %q", g.ID, g.Week, g.ShortName)
+	}
+}
+
+func TestFetchWeek(t *testing.T) {
+	useTestStore(t, setupTestData(t))
+	t.Cleanup(func() { unindexFile("2024/5.json") })
+	srv := newTestESPN(t)
+
+	result, err := newESPNFetcher(srv.URL).fetchWeek(context.Background(), dataStore.(store.WritableStore), "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Stored || result.Season != "2024" || result.Week != "5" || result.Games != 2 || result.Completed != 1 {
+		t.Errorf("unexpected result %+v", result)
+	}
+
+	games, err := loadGameStats("2024/5.json")
+	if err != nil || len(games) != 1 || games[0].ID != "401" {
+		t.Fatalf("expected the completed game to be stored, got %v, %v", games, err)
+	}
+	weekStatesMu.RLock()
+	state := weekStates["2024/5.json"]
+	weekStatesMu.RUnlock()
+	if state != weekInProgress {
+		t.Errorf("expected a partially played week to be in progress, got %q", state)
+	}
+	setWeekState("2024/5.json", "")
+}
+
+func TestHandleRefresh(t *testing.T) {
+	useTestStore(t, setupTestData(t))
+	t.Cleanup(func() {
+		unindexFile("2024/5.json")
+		setWeekState("2024/5.json", "")
+	})
+	useESPN(t, newTestESPN(t).URL)
+
+	tests := []struct {
+		url    string
+		status int
+	}{
+		{"/admin/refresh", http.StatusOK},
+		{"/admin/refresh?year=2024&week=5", http.StatusOK},
+		{"/admin/refresh?year=2024", http.StatusBadRequest},
+		{"/admin/refresh?year=2024&week=19", http.StatusBadRequest},
+		{"/admin/refresh?year=latest&week=5", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		handleRefresh(rec, httptest.NewRequest("POST", tt.url, nil))
+		if rec.Code != tt.status {
+			t.Errorf("%s: expected %d, got %d: %s", tt.url, tt.status, rec.Code, rec.Body)
+		}
+	}
+
+	useESPN(t, "http://127.0.0.1:1")
+	rec := httptest.NewRecorder()
+	handleRefresh(rec, httptest.NewRequest("POST", "/admin/refresh", nil))
+	if rec.Code != http.StatusBadGateway {
+		t.Errorf("expected 502 when ESPN is unreachable, got %d", rec.Code)
+	}
+}
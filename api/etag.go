@@ -0,0 +1,80 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// etagFor returns a strong ETag derived from the content of body
+func etagFor(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// encodedETag returns the ETag of the body of etag sent with encoding.
+// Each encoding of a body is a representation of its own, which the
+// caches keying on Vary: Accept-Encoding must not confuse. Weak ETags are
+// kept as they are.
+func encodedETag(etag, encoding string) string {
+	if len(etag) < 2 || !strings.HasPrefix(etag, `"`) || !strings.HasSuffix(etag, `"`) {
+		return etag
+	}
+	return etag[:len(etag)-1] + "-" + encoding + `"`
+}
+
+// decodeETags appends to a conditional header the ETags of the bodies its
+// ETags of encoding were derived from, for the handlers to compare with
+// the ETags they compute
+func decodeETags(header, encoding string) string {
+	suffix := "-" + encoding + `"`
+	decoded := header
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if etag, ok := strings.CutSuffix(candidate, suffix); ok && strings.HasPrefix(etag, `"`) {
+			decoded += ", " + etag + `"`
+		}
+	}
+	return decoded
+}
+
+// notModified sets the validators of a response and reports whether the
+// request's conditional headers match them, in which case a 304 has been
+// written and the caller must not write a body
+func notModified(w http.ResponseWriter, r *http.Request, etag string, lastModified time.Time) bool {
+	w.Header().Set("ETag", etag)
+	if !lastModified.IsZero() {
+		w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	// If-None-Match takes precedence over If-Modified-Since
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		if !etagMatches(inm, etag) {
+			return false
+		}
+	} else if ims := r.Header.Get("If-Modified-Since"); ims != "" && !lastModified.IsZero() {
+		t, err := http.ParseTime(ims)
+		if err != nil || lastModified.Truncate(time.Second).After(t) {
+			return false
+		}
+	} else {
+		return false
+	}
+
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// etagMatches reports whether an If-None-Match header lists etag, using
+// the weak comparison RFC 9110 prescribes for this header
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWeekConditionalRequests(t *testing.T) {
+	useTestStore(t, setupTestData(t))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /games/{year}/{week}", handleGamesYearWeek)
+
+	req := httptest.NewRequest("GET", "/games/2024/1", nil)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	etag := rec.Header().Get("ETag")
+	lastModified := rec.Header().Get("Last-Modified")
+	if etag == "" || lastModified == "" {
+		t.Fatalf("expected validators, got ETag %q Last-Modified %q", etag, lastModified)
+	}
+
+	tests := []struct {
+		header, value string
+		want          int
+	}{
+		{"If-None-Match", etag, http.StatusNotModified},
+		{"If-None-Match", `W/` + etag + `, "other"`, http.StatusNotModified},
+		{"If-None-Match", `"stale"`, http.StatusOK},
+		{"If-Modified-Since", lastModified, http.StatusNotModified},
+		{"If-Modified-Since", "Mon, 01 Jan 2001 00:00:00 GMT", http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/games/2024/1", nil)
+		req.Header.Set(tt.header, tt.value)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+
+		if rec.Code != tt.want {
+			t.Errorf("%s: %s: expected status %d, got %d", tt.header, tt.value, tt.want, rec.Code)
+		}
+		if tt.want == http.StatusNotModified && rec.Body.Len() != 0 {
+			t.Errorf("%s: expected empty body on 304", tt.header)
+		}
+	}
+}
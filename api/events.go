@@ -0,0 +1,174 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// eventHistory is how many events are kept for clients reconnecting
+	// with Last-Event-ID
+	eventHistory = 64
+
+	// eventHeartbeat keeps idle streams open through proxies
+	eventHeartbeat = 25 * time.Second
+
+	// eventRetry is the reconnection delay suggested to clients
+	eventRetry = 5 * time.Second
+
+	// eventBuffer is how many events a slow client may lag behind before
+	// its stream is closed
+	eventBuffer = 16
+)
+
+// WeekEvent is the data of a "week" event of /events, sent when a week is
+// ingested or its file reloaded. TopGame is the best rated game in its
+// spoiler-free representation.
+type WeekEvent struct {
+	ID      int              `json:"-"`
+	Reason  string           `json:"reason"` // ingested or reloaded
+	Season  string           `json:"season"`
+	Week    string           `json:"week"`
+	Label   string           `json:"label"`
+	Games   int              `json:"games"`
+	TopGame *SpoilerFreeGame `json:"topGame,omitempty"`
+	At      time.Time        `json:"at"`
+}
+
+// eventHub fans the week events out to the /events streams, keeping the
+// last ones for reconnecting clients
+type eventHub struct {
+	mu      sync.Mutex
+	nextID  int
+	history []WeekEvent
+	subs    map[chan WeekEvent]struct{}
+}
+
+// weekEvents is the hub of /events
+var weekEvents = newEventHub()
+
+func newEventHub() *eventHub {
+	return &eventHub{nextID: 1, subs: make(map[chan WeekEvent]struct{})}
+}
+
+// subscribe returns a channel of the events after lastID, starting with
+// those still in the history, and a function to unsubscribe. The channel
+// is closed when the client falls too far behind.
+func (h *eventHub) subscribe(lastID int) (<-chan WeekEvent, func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ch := make(chan WeekEvent, eventBuffer+eventHistory)
+	for _, ev := range h.history {
+		if ev.ID > lastID {
+			ch <- ev
+		}
+	}
+	h.subs[ch] = struct{}{}
+	return ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if _, ok := h.subs[ch]; ok {
+			delete(h.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// broadcast numbers ev and sends it to every stream
+func (h *eventHub) broadcast(ev WeekEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ev.ID = h.nextID
+	h.nextID++
+	h.history = append(h.history, ev)
+	if len(h.history) > eventHistory {
+		h.history = h.history[len(h.history)-eventHistory:]
+	}
+	for ch := range h.subs {
+		select {
+		case ch <- ev:
+		default:
+			// The client will reconnect and catch up from the history
+			delete(h.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// publishWeekEvent tells the /events streams that the week file name now
+// holds games
+func publishWeekEvent(reason, name string, games []GameStats) {
+	season, file, _ := strings.Cut(name, "/")
+	week := strings.TrimSuffix(file, ".json")
+	ev := WeekEvent{
+		Reason: reason,
+		Season: season,
+		Week:   week,
+		Label:  weekLabel(week),
+		At:     clock.Now().UTC(),
+	}
+	processed := processGames(raters[defaultAlgorithm], games)
+	best := -1
+	for i, g := range processed {
+		if g.ID == "" {
+			continue
+		}
+		ev.Games++
+		if best < 0 || g.TotalRating > processed[best].TotalRating {
+			best = i
+		}
+	}
+	if best >= 0 {
+		processed[best].setLocation(season, week)
+		top := spoilerFreeGames(processed[best : best+1])[0]
+		ev.TopGame = &top
+	}
+	weekEvents.broadcast(ev)
+}
+
+// handleEvents streams the week events as Server-Sent Events, replaying
+// those after the Last-Event-ID of a reconnecting client
+func handleEvents(w http.ResponseWriter, r *http.Request) {
+	rc := http.NewResponseController(w)
+	// The stream outlives the server's write timeout
+	rc.SetWriteDeadline(time.Time{})
+
+	lastID, _ := strconv.Atoi(r.Header.Get("Last-Event-ID"))
+	events, unsubscribe := weekEvents.subscribe(lastID)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Accel-Buffering", "no")
+	fmt.Fprintf(w, "retry: %d\n\n", eventRetry.Milliseconds())
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(eventHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(ev)
+			if err != nil {
+				return
+			}
+			fmt.Fprintf(w, "id: %d\nevent: week\ndata: %s\n\n", ev.ID, data)
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+		case <-r.Context().Done():
+			return
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
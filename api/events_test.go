@@ -0,0 +1,100 @@
+package api
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func useEventHub(t *testing.T) {
+	t.Helper()
+	old := weekEvents
+	weekEvents = newEventHub()
+	t.Cleanup(func() { weekEvents = old })
+}
+
+func TestHandleEvents(t *testing.T) {
+	useEventHub(t)
+	games, err := decodeWeekFile("2024/1.json", []byte(testData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	publishWeekEvent("ingested", "2024/1.json", games)
+
+	srv := httptest.NewServer(http.HandlerFunc(handleEvents))
+	defer srv.Close()
+	req, _ := http.NewRequest("GET", srv.URL, nil)
+	req.Header.Set("Last-Event-ID", "1")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+
+	// The first event was seen before the reconnection and is not replayed
+	publishWeekEvent("reloaded", "2024/2.json", games)
+
+	lines := make(chan string)
+	go func() {
+		sc := bufio.NewScanner(resp.Body)
+		for sc.Scan() {
+			lines <- sc.Text()
+		}
+		close(lines)
+	}()
+	// Skip the retry field up to the event, then read its fields
+	var got []string
+	timeout := time.After(5 * time.Second)
+	for len(got) < 3 {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				t.Fatalf("stream closed, got %q", got)
+			}
+			if strings.HasPrefix(line, "id:") || len(got) > 0 {
+				got = append(got, line)
+			}
+		case <-timeout:
+			t.Fatalf("no event received, got %q", got)
+		}
+	}
+
+	if got[0] != "id: 2" || got[1] != "event: week" {
+		t.Fatalf("unexpected event %q", got)
+	}
+	var ev WeekEvent
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(got[2], "data: ")), &ev); err != nil {
+		t.Fatal(err)
+	}
+	if ev.Reason != "reloaded" || ev.Season != "2024" || ev.Week != "2" || ev.Games != 1 {
+		t.Errorf("unexpected event %+v", ev)
+	}
+	if ev.TopGame == nil || ev.TopGame.ShortName != "A @ B" || ev.TopGame.Slug == "" {
+		t.Errorf("unexpected top game %+v", ev.TopGame)
+	}
+}
+
+func TestEventHubDropsSlowSubscribers(t *testing.T) {
+	h := newEventHub()
+	events, unsubscribe := h.subscribe(0)
+	defer unsubscribe()
+	for i := 0; i < eventBuffer+eventHistory+1; i++ {
+		h.broadcast(WeekEvent{Season: "2024"})
+	}
+	n := 0
+	for range events {
+		n++
+	}
+	if n != eventBuffer+eventHistory {
+		t.Errorf("expected the stream to close after %d events, got %d", eventBuffer+eventHistory, n)
+	}
+	if len(h.history) != eventHistory || h.history[0].ID != eventBuffer+2 {
+		t.Errorf("unexpected history of %d events starting at %d", len(h.history), h.history[0].ID)
+	}
+}
@@ -0,0 +1,282 @@
+package api
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/jjway/rewatchableGamesApi-go/store"
+)
+
+// feedGames is how many games of the week the feeds list
+const feedGames = 10
+
+// feedSiteURL is the site the feed items link to, from FEED_SITE_URL. The
+// items link to the API's /g/{slug} redirect when it is unset.
+var feedSiteURL string
+
+// weekFeed is the content of the feeds: the best games of the most recent
+// week, built from the cache on each request so a new or reloaded week is
+// picked up at once
+type weekFeed struct {
+	Lang    string
+	Title   string
+	Season  string
+	Week    string
+	Updated time.Time
+	Items   []feedItem
+}
+
+// feedItem is a game of a feed, described without spoilers
+type feedItem struct {
+	Title       string
+	Link        string
+	Description string
+	Game        SpoilerFreeGame
+}
+
+// latestWeek returns the most recent cached week, ok false when there is
+// no data
+func latestWeek() (season, week string, ok bool) {
+	seasons := availableSeasons()
+	if len(seasons) == 0 {
+		return "", "", false
+	}
+	s := seasons[len(seasons)-1]
+	if len(s.Weeks) == 0 {
+		return "", "", false
+	}
+	return s.Season, s.Weeks[len(s.Weeks)-1].Week, true
+}
+
+// requestBaseURL is the scheme and host the client used to reach the API,
+// https behind a proxy setting X-Forwarded-Proto
+func requestBaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// buildWeekFeed rates the most recent week with rater and keeps its best
+// games, described in lang
+func buildWeekFeed(r *http.Request, rater Rater, lang string) weekFeed {
+	feed := weekFeed{Lang: lang, Title: translate(lang, "feed.title")}
+	season, week, ok := latestWeek()
+	if !ok {
+		return feed
+	}
+	name := store.WeekFile(season, week)
+	games, err := loadGameStats(name)
+	if err != nil {
+		return feed
+	}
+	feed.Season, feed.Week = season, week
+	feed.Title = translate(lang, "feed.title.week", localWeekLabel(lang, week), season)
+	feed.Updated = cacheLoadedAt(name).UTC()
+
+	processed := processGames(rater, games)
+	kept := processed[:0]
+	for _, p := range processed {
+		if p.ID != "" {
+			p.setLocation(season, week)
+			kept = append(kept, p)
+		}
+	}
+	sort.SliceStable(kept, func(i, j int) bool { return kept[i].TotalRating > kept[j].TotalRating })
+	if len(kept) > feedGames {
+		kept = kept[:feedGames]
+	}
+
+	site := feedSiteURL
+	if site == "" {
+		site = requestBaseURL(r)
+	}
+	for i, g := range spoilerFreeGames(kept) {
+		feed.Items = append(feed.Items, feedItem{
+			Title: fmt.Sprintf("%d. %s (%.1f)", i+1, g.ShortName, g.TotalRating),
+			Link:  site + "/g/" + g.Slug,
+			Description: translate(lang, "feed.item",
+				g.FullName, localWeekLabel(lang, week), season, g.TotalRating, localQuality(lang, g.MatchupQuality)),
+			Game: g,
+		})
+	}
+	return feed
+}
+
+type rssDocument struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Atom    string     `xml:"xmlns:atom,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	Language      string    `xml:"language"`
+	Self          atomLink  `xml:"atom:link"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	TTL           int       `xml:"ttl"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	GUID        rssGUID `xml:"guid"`
+	Description string  `xml:"description"`
+	Category    string  `xml:"category"`
+	PubDate     string  `xml:"pubDate,omitempty"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type atomDocument struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Lang    string      `xml:"xml:lang,attr,omitempty"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Author  atomAuthor  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	Title    string   `xml:"title"`
+	ID       string   `xml:"id"`
+	Updated  string   `xml:"updated"`
+	Link     atomLink `xml:"link"`
+	Summary  string   `xml:"summary"`
+	Category struct {
+		Term string `xml:"term,attr"`
+	} `xml:"category"`
+}
+
+// renderRSS renders feed as RSS 2.0, served from self
+func renderRSS(feed weekFeed, base, self string) ([]byte, error) {
+	doc := rssDocument{Version: "2.0", Atom: "http://www.w3.org/2005/Atom", Channel: rssChannel{
+		Title:       feed.Title,
+		Link:        base + "/seasons",
+		Description: translate(feed.Lang, "feed.description"),
+		Language:    feed.Lang,
+		Self:        atomLink{Href: self, Rel: "self", Type: "application/rss+xml"},
+		TTL:         cachePolicies["feed"].MaxAge / 60,
+	}}
+	if !feed.Updated.IsZero() {
+		doc.Channel.LastBuildDate = feed.Updated.Format(time.RFC1123Z)
+	}
+	for _, item := range feed.Items {
+		doc.Channel.Items = append(doc.Channel.Items, rssItem{
+			Title:       item.Title,
+			Link:        item.Link,
+			GUID:        rssGUID{Value: feedItemID(feed, item)},
+			Description: item.Description,
+			Category:    localWeekLabel(feed.Lang, feed.Week),
+			PubDate:     doc.Channel.LastBuildDate,
+		})
+	}
+	return marshalFeed(doc)
+}
+
+// renderAtom renders feed as Atom 1.0, served from self
+func renderAtom(feed weekFeed, base, self string) ([]byte, error) {
+	updated := feed.Updated
+	if updated.IsZero() {
+		updated = time.Unix(0, 0).UTC()
+	}
+	doc := atomDocument{
+		Lang:    feed.Lang,
+		Title:   feed.Title,
+		ID:      self,
+		Updated: updated.Format(time.RFC3339),
+		Links:   []atomLink{{Href: self, Rel: "self", Type: "application/atom+xml"}, {Href: base + "/seasons"}},
+		Author:  atomAuthor{Name: "Rewatchable Games"},
+	}
+	for _, item := range feed.Items {
+		entry := atomEntry{
+			Title:   item.Title,
+			ID:      feedItemID(feed, item),
+			Updated: doc.Updated,
+			Link:    atomLink{Href: item.Link},
+			Summary: item.Description,
+		}
+		entry.Category.Term = localWeekLabel(feed.Lang, feed.Week)
+		doc.Entries = append(doc.Entries, entry)
+	}
+	return marshalFeed(doc)
+}
+
+// feedItemID is the stable identifier of a feed item, the same for a
+// game whatever its rank or rating
+func feedItemID(feed weekFeed, item feedItem) string {
+	return "tag:rewatchable-games," + feed.Season + ":" + feed.Week + "/" + item.Game.ID
+}
+
+func marshalFeed(doc any) ([]byte, error) {
+	data, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(data, '\n')...), nil
+}
+
+// handleFeed serves the feed of the most recent week as RSS on
+// /feed.rss and as Atom on /feed.atom, in the language of ?lang= or
+// Accept-Language
+func handleFeed(format string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rater, qerr := raterFor(r)
+		if qerr != nil {
+			writeQueryError(w, r, qerr)
+			return
+		}
+		lang, qerr := requestLang(w, r)
+		if qerr != nil {
+			writeQueryError(w, r, qerr)
+			return
+		}
+		feed := buildWeekFeed(r, rater, lang)
+		base := requestBaseURL(r)
+		self := base + r.URL.Path
+
+		var body []byte
+		var err error
+		contentType := "application/rss+xml; charset=utf-8"
+		if format == "atom" {
+			contentType = "application/atom+xml; charset=utf-8"
+			body, err = renderAtom(feed, base, self)
+		} else {
+			body, err = renderRSS(feed, base, self)
+		}
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, "error encoding response")
+			return
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		setCacheHeaders(w, "feed")
+		if notModified(w, r, etagFor(body), feed.Updated) {
+			return
+		}
+		w.Write(body)
+	}
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFeeds(t *testing.T) {
+	useFakeClock(t)
+	useTestStore(t, setupTestData(t))
+	preloadCache(dataStore)
+
+	get := func(target string, header http.Header) *httptest.ResponseRecorder {
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /feed.rss", handleFeed("rss"))
+		mux.HandleFunc("GET /feed.atom", handleFeed("atom"))
+		req := httptest.NewRequest("GET", target, nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get("/feed.rss", http.Header{"X-Forwarded-Proto": {"https"}})
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "application/rss+xml") {
+		t.Fatalf("unexpected response %d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if cc := rec.Header().Get("Cache-Control"); cc != cachePolicies["feed"].header() {
+		t.Errorf("unexpected Cache-Control %s", cc)
+	}
+	var rss rssDocument
+	if err := xml.Unmarshal(rec.Body.Bytes(), &rss); err != nil {
+		t.Fatal(err)
+	}
+	if rss.Channel.Title != "Most rewatchable games of Week 2, 2024" || len(rss.Channel.Items) != 1 {
+		t.Fatalf("unexpected channel %+v", rss.Channel)
+	}
+	item := rss.Channel.Items[0]
+	if item.Title != "1. A @ B (21.5)" || item.Link != "https://example.com/g/2024-w2-a-b" || item.GUID.Value != "tag:rewatchable-games,2024:2/game1" {
+		t.Errorf("unexpected item %+v", item)
+	}
+	if strings.Contains(item.Description, "points") || !strings.Contains(item.Description, "Rewatchability 21.5") {
+		t.Errorf("unexpected description %q", item.Description)
+	}
+
+	// The validators let readers poll cheaply
+	etag := rec.Header().Get("ETag")
+	if rec := get("/feed.rss", http.Header{"X-Forwarded-Proto": {"https"}, "If-None-Match": {etag}}); rec.Code != http.StatusNotModified {
+		t.Errorf("expected status 304, got %d", rec.Code)
+	}
+
+	old := feedSiteURL
+	feedSiteURL = "https://rewatchable.example"
+	t.Cleanup(func() { feedSiteURL = old })
+	rec = get("/feed.atom", nil)
+	var atom atomDocument
+	if err := xml.Unmarshal(rec.Body.Bytes(), &atom); err != nil {
+		t.Fatal(err)
+	}
+	if atom.ID != "http://example.com/feed.atom" || len(atom.Entries) != 1 {
+		t.Fatalf("unexpected feed %+v", atom)
+	}
+	if e := atom.Entries[0]; e.Link.Href != "https://rewatchable.example/g/2024-w2-a-b" || e.Category.Term != "Week 2" {
+		t.Errorf("unexpected entry %+v", e)
+	}
+
+	// French readers get the text in French
+	rec = get("/feed.rss?lang=fr", nil)
+	rss = rssDocument{}
+	if err := xml.Unmarshal(rec.Body.Bytes(), &rss); err != nil {
+		t.Fatal(err)
+	}
+	if rss.Channel.Language != "fr" || rss.Channel.Title != "Les matchs les plus à revoir : Semaine 2, 2024" {
+		t.Errorf("unexpected channel %+v", rss.Channel)
+	}
+	if d := rss.Channel.Items[0].Description; !strings.Contains(d, "Semaine 2 de la saison 2024") || !strings.Contains(d, "qualité de l'affiche élevée") {
+		t.Errorf("unexpected description %q", d)
+	}
+	if rec.Header().Get("Content-Language") != "fr" {
+		t.Errorf("unexpected Content-Language %q", rec.Header().Get("Content-Language"))
+	}
+
+	if rec := get("/feed.rss?algo=v0", nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an unknown algorithm, got %d", rec.Code)
+	}
+}
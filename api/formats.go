@@ -0,0 +1,151 @@
+package api
+
+import (
+	"bufio"
+	"encoding/csv"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Representations of the game lists
+const (
+	formatJSON   = "json"
+	formatCSV    = "csv"
+	formatNDJSON = "ndjson"
+)
+
+// formatMediaTypes maps the media types of the Accept header to formats
+var formatMediaTypes = map[string]string{
+	"application/json":     formatJSON,
+	"text/csv":             formatCSV,
+	"application/x-ndjson": formatNDJSON,
+	"application/ndjson":   formatNDJSON,
+}
+
+// responseFormat returns the representation asked for with ?format=, or
+// else the first media type of the Accept header that has one. JSON is the
+// default.
+func responseFormat(r *http.Request) (string, *QueryError) {
+	switch v := r.URL.Query().Get("format"); v {
+	case "":
+	case formatJSON, formatCSV, formatNDJSON:
+		return v, nil
+	default:
+		return "", &QueryError{Param: "format", Value: v, Message: "must be json, csv or ndjson"}
+	}
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		if format, ok := formatMediaTypes[mediaType]; ok {
+			return format, nil
+		}
+	}
+	return formatJSON, nil
+}
+
+// gameColumn is one CSV column of a processed game
+type gameColumn struct {
+	name string
+	get  func(ProcessedGameStats) string
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// gameColumns are the CSV columns, in order. The spoiler-free ones are the
+// fields of SpoilerFreeGame.
+var gameColumns = []struct {
+	gameColumn
+	spoilerFree bool
+}{
+	{gameColumn{"id", func(p ProcessedGameStats) string { return p.ID }}, true},
+	{gameColumn{"season", func(p ProcessedGameStats) string { return p.Season }}, true},
+	{gameColumn{"week", func(p ProcessedGameStats) string { return p.Week }}, true},
+	{gameColumn{"weekLabel", func(p ProcessedGameStats) string { return p.WeekLabel }}, true},
+	{gameColumn{"slug", func(p ProcessedGameStats) string { return p.Slug }}, true},
+	{gameColumn{"fullName", func(p ProcessedGameStats) string { return p.FullName }}, true},
+	{gameColumn{"shortName", func(p ProcessedGameStats) string { return p.ShortName }}, true},
+	{gameColumn{"matchupQuality", func(p ProcessedGameStats) string { return p.MatchupQuality }}, true},
+	{gameColumn{"offensiveRating", func(p ProcessedGameStats) string { return formatFloat(p.OffensiveRating) }}, false},
+	{gameColumn{"defensiveBigPlays", func(p ProcessedGameStats) string { return formatFloat(p.DefensiveBigPlays) }}, false},
+	{gameColumn{"scenarioRating", func(p ProcessedGameStats) string { return formatFloat(p.ScenarioRating) }}, false},
+	{gameColumn{"totalRating", func(p ProcessedGameStats) string { return formatFloat(p.TotalRating) }}, true},
+	{gameColumn{"algorithm", func(p ProcessedGameStats) string { return p.Algorithm }}, true},
+	{gameColumn{"blowout", func(p ProcessedGameStats) string { return strconv.FormatBool(p.Blowout) }}, false},
+}
+
+// normalizedColumn is appended when the games were normalized
+var normalizedColumn = gameColumn{"normalizedRating", func(p ProcessedGameStats) string {
+	if p.NormalizedRating == nil {
+		return ""
+	}
+	return formatFloat(*p.NormalizedRating)
+}}
+
+// writeGameRows streams games as CSV or NDJSON under the cache policy of
+// the route, honoring ?spoilerFree=. Errors after the first rows can no
+// longer change the status and are only returned.
+func writeGameRows(w http.ResponseWriter, r *http.Request, format, policy string, games []ProcessedGameStats) error {
+	spoilerFree := isSpoilerFree(r)
+	setCacheHeaders(w, policy)
+
+	if format == formatNDJSON {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		bw := bufio.NewWriterSize(w, 32<<10)
+		for i, p := range games {
+			var item any = p
+			if spoilerFree {
+				item = spoilerFreeGames(games[i : i+1])[0]
+			}
+			data, err := json.Marshal(item)
+			if err != nil {
+				return err
+			}
+			bw.Write(data)
+			if err := bw.WriteByte('\n'); err != nil {
+				return err
+			}
+		}
+		return bw.Flush()
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	return writeGameCSV(w, games, spoilerFree)
+}
+
+// writeGameCSV writes games as CSV with a header row, without the stats of
+// the ratings when spoilerFree is set
+func writeGameCSV(w io.Writer, games []ProcessedGameStats, spoilerFree bool) error {
+	var columns []gameColumn
+	for _, c := range gameColumns {
+		if c.spoilerFree || !spoilerFree {
+			columns = append(columns, c.gameColumn)
+		}
+	}
+	if len(games) > 0 && games[0].Normalization != "" {
+		columns = append(columns, normalizedColumn)
+	}
+
+	cw := csv.NewWriter(w)
+	row := make([]string, len(columns))
+	for i, c := range columns {
+		row[i] = c.name
+	}
+	cw.Write(row)
+	for _, p := range games {
+		for i, c := range columns {
+			row[i] = c.get(p)
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
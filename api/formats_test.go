@@ -0,0 +1,108 @@
+package api
+
+import (
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestResponseFormat(t *testing.T) {
+	tests := []struct {
+		url, accept, want string
+	}{
+		{"/games/2024/1", "", formatJSON},
+		{"/games/2024/1", "text/csv", formatCSV},
+		{"/games/2024/1", "application/x-ndjson", formatNDJSON},
+		{"/games/2024/1", "text/html, text/csv;q=0.9, */*;q=0.8", formatCSV},
+		{"/games/2024/1", "application/json, text/csv", formatJSON},
+		{"/games/2024/1?format=csv", "application/json", formatCSV},
+		{"/games/2024/1?format=ndjson", "", formatNDJSON},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.url, nil)
+		req.Header.Set("Accept", tt.accept)
+		if got, qerr := responseFormat(req); qerr != nil || got != tt.want {
+			t.Errorf("%s with Accept %q: got %q, %v; want %q", tt.url, tt.accept, got, qerr, tt.want)
+		}
+	}
+	if _, qerr := responseFormat(httptest.NewRequest("GET", "/games/2024/1?format=xml", nil)); qerr == nil || qerr.Param != "format" {
+		t.Errorf("expected a format error, got %v", qerr)
+	}
+}
+
+func TestGamesYearWeekCSV(t *testing.T) {
+	useTestStore(t, setupTestData(t))
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /games/{year}/{week}", handleGamesYearWeek)
+
+	req := httptest.NewRequest("GET", "/games/2024/1", nil)
+	req.Header.Set("Accept", "text/csv")
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/csv") {
+		t.Fatalf("expected CSV, got %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if vary := rec.Header().Get("Vary"); vary != "Accept" {
+		t.Errorf("expected Vary: Accept, got %q", vary)
+	}
+	records, err := csv.NewReader(rec.Body).ReadAll()
+	if err != nil || len(records) != 2 {
+		t.Fatalf("expected a header and one row, got %v, %v", records, err)
+	}
+	if records[0][0] != "id" || records[1][0] != "game1" || records[1][2] != "1" {
+		t.Errorf("unexpected rows %v", records)
+	}
+	if got := records[1][len(gameColumns)-3]; got != "21.5" {
+		t.Errorf("expected totalRating 21.5, got %q in %v", got, records[0])
+	}
+
+	// The JSON response cached meanwhile must not be served to CSV clients
+	mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/games/2024/1", nil))
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/games/2024/1?format=csv&spoilerFree=true&normalize=percentile", nil))
+	records, _ = csv.NewReader(rec.Body).ReadAll()
+	header := strings.Join(records[0], ",")
+	if strings.Contains(header, "offensiveRating") || !strings.HasSuffix(header, "algorithm,normalizedRating") {
+		t.Errorf("unexpected spoiler-free normalized header %q", header)
+	}
+
+	// Scripts exporting the rows are not crawlers
+	req = httptest.NewRequest("GET", "/games/2024/1?format=csv", nil)
+	req.Header.Set("User-Agent", "python-requests/2.31.0")
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/csv") {
+		t.Errorf("expected CSV for python-requests, got %q", rec.Header().Get("Content-Type"))
+	}
+}
+
+func TestGamesRangeNDJSON(t *testing.T) {
+	useTestStore(t, setupTestData(t))
+	preloadCache(dataStore)
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/games?from=2024&to=2024", nil)
+	req.Header.Set("Accept", "application/x-ndjson")
+	handleGamesRange(rec, req)
+	if rec.Header().Get("Content-Type") != "application/x-ndjson" {
+		t.Fatalf("expected NDJSON, got %q", rec.Header().Get("Content-Type"))
+	}
+	lines := strings.Split(strings.TrimSuffix(rec.Body.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected one line per game, got %q", rec.Body)
+	}
+	for _, line := range lines {
+		var g ProcessedGameStats
+		if err := json.Unmarshal([]byte(line), &g); err != nil || g.ID != "game1" {
+			t.Errorf("unexpected line %q: %v", line, err)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	handleGamesRange(rec, httptest.NewRequest("GET", "/games?format=ndjson&limit=1", nil))
+	if n := strings.Count(rec.Body.String(), "\n"); n != 1 {
+		t.Errorf("expected pagination to apply to rows, got %d lines", n)
+	}
+}
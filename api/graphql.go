@@ -0,0 +1,830 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/jjway/rewatchableGamesApi-go/store"
+)
+
+// maxGraphQLBytes caps the body of a POST /graphql request
+const maxGraphQLBytes = 64 << 10
+
+const (
+	// maxGraphQLDepth, maxGraphQLFields and maxGraphQLAliases cap the
+	// nesting, the number of selected fields and the number of aliased
+	// fields of a query
+	maxGraphQLDepth   = 4
+	maxGraphQLFields  = 200
+	maxGraphQLAliases = 10
+
+	// graphqlQueryCost is the compute cost of parsing and validating a
+	// query, charged up front; each game list resolved costs graphqlCosts
+	// more
+	graphqlQueryCost = 1
+)
+
+// graphqlCosts is the compute cost of resolving the fields scanning games,
+// by "Type.field", charged each time one resolves
+var graphqlCosts = map[string]float64{
+	"Query.games": 1,
+	"Team.games":  1,
+}
+
+// graphqlSchema is the schema served by /graphql, in SDL. The game
+// arguments are the query parameters of the list routes; a game list
+// needs a season or a team. Spoiler-free games resolve their spoiler
+// fields to null.
+//
+// /graphql implements the subset of GraphQL these clients need: query
+// operations with variables, aliases and __typename. Mutations,
+// subscriptions, fragments, directives and introspection are not
+// supported; the schema is served by GET /graphql?sdl instead.
+const graphqlSchema = `type Query {
+  seasons: [Season!]!
+  games(season: String, week: String, team: String, q: String, sort: String, order: String,
+        normalize: String, algo: String, spoilerFree: Boolean, limit: Int, offset: Int): [Game!]!
+  team(name: String!): Team
+  teams: [Team!]!
+}
+
+type Season { season: String! games: Int! weeks: [Week!]! }
+type Week { week: String! label: String! games: Int! }
+
+type Team {
+  name: String!
+  games(season: String, week: String, q: String, sort: String, order: String,
+        normalize: String, algo: String, spoilerFree: Boolean, limit: Int, offset: Int): [Game!]!
+}
+
+type Game {
+  id: String! season: String week: String weekLabel: String slug: String
+  fullName: String! shortName: String! homeTeam: JSON awayTeam: JSON matchupQuality: String!
+  offensiveRating: Float defensiveBigPlays: Float scenarioRating: Float totalRating: Float!
+  algorithm: String! blowout: Boolean
+  normalizedRating: Float normalization: String extensions: JSON conditions: JSON
+  communityRating: Float communityVotes: Int
+}`
+
+// graphqlTypes lists the fields of each object type. A field maps to the
+// type of its objects, or to "" for scalars.
+var graphqlTypes = map[string]map[string]string{
+	"Query":  {"seasons": "Season", "games": "Game", "team": "Team", "teams": "Team"},
+	"Season": {"season": "", "games": "", "weeks": "Week"},
+	"Week":   {"week": "", "label": "", "games": ""},
+	"Team":   {"name": "", "games": "Game"},
+	"Game":   jsonFieldTypes(reflect.TypeOf(ProcessedGameStats{})),
+}
+
+// graphqlArgs lists the arguments of the fields taking some, by
+// "Type.field"
+var graphqlArgs = map[string][]string{
+	"Query.games": {"season", "week", "team", "q", "sort", "order", "normalize", "algo", "spoilerFree", "limit", "offset"},
+	"Query.team":  {"name"},
+	"Team.games":  {"season", "week", "q", "sort", "order", "normalize", "algo", "spoilerFree", "limit", "offset"},
+}
+
+// jsonFieldTypes returns the JSON names of the exported fields of t as
+// scalar fields
+func jsonFieldTypes(t reflect.Type) map[string]string {
+	fields := make(map[string]string)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if f.IsExported() && name != "" && name != "-" {
+			fields[name] = ""
+		}
+	}
+	return fields
+}
+
+// GraphQLRequest is the body of POST /graphql, or the parameters of
+// GET /graphql
+type GraphQLRequest struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName,omitempty"`
+	Variables     map[string]any `json:"variables,omitempty"`
+}
+
+// GraphQLError is one entry of the errors of a response
+type GraphQLError struct {
+	Message string `json:"message"`
+	Path    []any  `json:"path,omitempty"`
+}
+
+// GraphQLResponse is the response of /graphql
+type GraphQLResponse struct {
+	Data   any            `json:"data,omitempty"`
+	Errors []GraphQLError `json:"errors,omitempty"`
+}
+
+// gqlToken is a lexeme of a GraphQL document and its 1-based position
+type gqlToken struct {
+	kind string // name, string, number, punct or end
+	text string
+	pos  int
+}
+
+// lexGraphQL splits a GraphQL document into tokens, skipping the
+// insignificant commas, whitespace and comments
+func lexGraphQL(s string) ([]gqlToken, error) {
+	var tokens []gqlToken
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			i++
+		case c == '#':
+			for i < len(s) && s[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(s[i:], "..."):
+			return nil, fmt.Errorf("at position %d: fragments are not supported", i+1)
+		case strings.IndexByte("{}():$!=[]", c) >= 0:
+			tokens = append(tokens, gqlToken{kind: "punct", text: string(c), pos: i + 1})
+			i++
+		case c == '"':
+			j := i + 1
+			for j < len(s) && s[j] != '"' {
+				if s[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(s) {
+				return nil, fmt.Errorf("at position %d: unterminated string", i+1)
+			}
+			text, err := strconv.Unquote(s[i : j+1])
+			if err != nil {
+				return nil, fmt.Errorf("at position %d: invalid string", i+1)
+			}
+			tokens = append(tokens, gqlToken{kind: "string", text: text, pos: i + 1})
+			i = j + 1
+		case c == '-' || c >= '0' && c <= '9':
+			j := i + 1
+			for j < len(s) && strings.IndexByte("0123456789.eE+-", s[j]) >= 0 {
+				j++
+			}
+			tokens = append(tokens, gqlToken{kind: "number", text: s[i:j], pos: i + 1})
+			i = j
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			j := i + 1
+			for j < len(s) && (s[j] == '_' || s[j] >= 'a' && s[j] <= 'z' || s[j] >= 'A' && s[j] <= 'Z' || s[j] >= '0' && s[j] <= '9') {
+				j++
+			}
+			tokens = append(tokens, gqlToken{kind: "name", text: s[i:j], pos: i + 1})
+			i = j
+		default:
+			return nil, fmt.Errorf("at position %d: unexpected character %q", i+1, c)
+		}
+	}
+	return append(tokens, gqlToken{kind: "end", pos: len(s) + 1}), nil
+}
+
+// gqlField is one field of a selection set
+type gqlField struct {
+	alias     string
+	name      string
+	args      map[string]gqlValue
+	selection []*gqlField
+}
+
+// gqlValue is an argument value: a literal, or the variable it names
+type gqlValue struct {
+	variable string
+	literal  any
+}
+
+// gqlVariable is a variable definition of an operation
+type gqlVariable struct {
+	name     string
+	required bool
+	def      *gqlValue
+}
+
+// gqlOperation is a parsed query operation
+type gqlOperation struct {
+	name      string
+	variables []gqlVariable
+	selection []*gqlField
+}
+
+type gqlParser struct {
+	tokens []gqlToken
+	next   int
+}
+
+func (p *gqlParser) peek() gqlToken { return p.tokens[p.next] }
+
+func (p *gqlParser) advance() gqlToken {
+	t := p.tokens[p.next]
+	if t.kind != "end" {
+		p.next++
+	}
+	return t
+}
+
+// is reports whether the next token is the punctuator text
+func (p *gqlParser) is(text string) bool {
+	t := p.peek()
+	return t.kind == "punct" && t.text == text
+}
+
+func (p *gqlParser) expect(text string) error {
+	if t := p.advance(); t.kind != "punct" || t.text != text {
+		return fmt.Errorf("at position %d: expected %q, got %s", t.pos, text, describeGQL(t))
+	}
+	return nil
+}
+
+func (p *gqlParser) name() (string, error) {
+	t := p.advance()
+	if t.kind != "name" {
+		return "", fmt.Errorf("at position %d: expected a name, got %s", t.pos, describeGQL(t))
+	}
+	return t.text, nil
+}
+
+func describeGQL(t gqlToken) string {
+	if t.kind == "end" {
+		return "end of document"
+	}
+	return strconv.Quote(t.text)
+}
+
+// parseGraphQL parses a document of query operations
+func parseGraphQL(doc string) ([]gqlOperation, error) {
+	tokens, err := lexGraphQL(doc)
+	if err != nil {
+		return nil, err
+	}
+	p := &gqlParser{tokens: tokens}
+	var ops []gqlOperation
+	for p.peek().kind != "end" {
+		op, err := p.parseOperation()
+		if err != nil {
+			return nil, err
+		}
+		ops = append(ops, op)
+	}
+	if len(ops) == 0 {
+		return nil, errors.New("the document has no operation")
+	}
+	return ops, nil
+}
+
+func (p *gqlParser) parseOperation() (gqlOperation, error) {
+	var op gqlOperation
+	if t := p.peek(); t.kind == "name" {
+		if t.text != "query" {
+			return op, fmt.Errorf("at position %d: only queries are supported", t.pos)
+		}
+		p.advance()
+		if p.peek().kind == "name" {
+			op.name = p.advance().text
+		}
+		if p.is("(") {
+			vars, err := p.parseVariables()
+			if err != nil {
+				return op, err
+			}
+			op.variables = vars
+		}
+	}
+	selection, err := p.parseSelection()
+	op.selection = selection
+	return op, err
+}
+
+func (p *gqlParser) parseVariables() ([]gqlVariable, error) {
+	p.advance()
+	var vars []gqlVariable
+	for !p.is(")") {
+		if err := p.expect("$"); err != nil {
+			return nil, err
+		}
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		required, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		v := gqlVariable{name: name, required: required}
+		if p.is("=") {
+			p.advance()
+			def, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			v.def = &def
+		}
+		vars = append(vars, v)
+	}
+	p.advance()
+	return vars, nil
+}
+
+// parseType skips a type reference, reporting whether it is non-null
+func (p *gqlParser) parseType() (bool, error) {
+	if p.is("[") {
+		p.advance()
+		if _, err := p.parseType(); err != nil {
+			return false, err
+		}
+		if err := p.expect("]"); err != nil {
+			return false, err
+		}
+	} else if _, err := p.name(); err != nil {
+		return false, err
+	}
+	if p.is("!") {
+		p.advance()
+		return true, nil
+	}
+	return false, nil
+}
+
+func (p *gqlParser) parseSelection() ([]*gqlField, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	var fields []*gqlField
+	for !p.is("}") {
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		f := &gqlField{alias: name, name: name}
+		if p.is(":") {
+			p.advance()
+			if f.name, err = p.name(); err != nil {
+				return nil, err
+			}
+		}
+		if p.is("(") {
+			p.advance()
+			f.args = make(map[string]gqlValue)
+			for !p.is(")") {
+				arg, err := p.name()
+				if err != nil {
+					return nil, err
+				}
+				if err := p.expect(":"); err != nil {
+					return nil, err
+				}
+				if f.args[arg], err = p.parseValue(); err != nil {
+					return nil, err
+				}
+			}
+			p.advance()
+		}
+		if p.is("{") {
+			if f.selection, err = p.parseSelection(); err != nil {
+				return nil, err
+			}
+		}
+		fields = append(fields, f)
+	}
+	p.advance()
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("at position %d: empty selection", p.peek().pos)
+	}
+	return fields, nil
+}
+
+func (p *gqlParser) parseValue() (gqlValue, error) {
+	t := p.advance()
+	switch {
+	case t.kind == "punct" && t.text == "$":
+		name, err := p.name()
+		return gqlValue{variable: name}, err
+	case t.kind == "string":
+		return gqlValue{literal: t.text}, nil
+	case t.kind == "number":
+		n, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return gqlValue{}, fmt.Errorf("at position %d: invalid number %s", t.pos, t.text)
+		}
+		return gqlValue{literal: n}, nil
+	case t.kind == "name" && (t.text == "true" || t.text == "false"):
+		return gqlValue{literal: t.text == "true"}, nil
+	case t.kind == "name" && t.text == "null":
+		return gqlValue{}, nil
+	case t.kind == "name":
+		// Enum values are passed on as their name
+		return gqlValue{literal: t.text}, nil
+	}
+	return gqlValue{}, fmt.Errorf("at position %d: expected a value, got %s", t.pos, describeGQL(t))
+}
+
+// gqlResult is an object of the response, keeping the order of the
+// selection
+type gqlResult struct {
+	keys   []string
+	values map[string]any
+}
+
+func (o *gqlResult) set(key string, v any) {
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = v
+}
+
+func (o *gqlResult) MarshalJSON() ([]byte, error) {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, k := range o.keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		key, _ := json.Marshal(k)
+		value, err := json.Marshal(o.values[k])
+		if err != nil {
+			return nil, err
+		}
+		b.Write(key)
+		b.WriteByte(':')
+		b.Write(value)
+	}
+	b.WriteByte('}')
+	return []byte(b.String()), nil
+}
+
+// gqlExecution executes one operation, collecting the field errors
+type gqlExecution struct {
+	variables map[string]any
+	errors    []GraphQLError
+
+	// charge spends the cost of a field on the client's compute budget;
+	// charged is the total spent
+	charge  func(float64) error
+	charged float64
+}
+
+// executeGraphQL runs the operation of doc named operationName, or its
+// only operation, charging the cost of the game lists it resolves
+func executeGraphQL(req GraphQLRequest, charge func(float64) error) GraphQLResponse {
+	ops, err := parseGraphQL(req.Query)
+	if err != nil {
+		return GraphQLResponse{Errors: []GraphQLError{{Message: "syntax error " + err.Error()}}}
+	}
+	var op *gqlOperation
+	for i := range ops {
+		if ops[i].name == req.OperationName || req.OperationName == "" && len(ops) == 1 {
+			op = &ops[i]
+			break
+		}
+	}
+	if op == nil {
+		msg := "unknown operation " + strconv.Quote(req.OperationName)
+		if req.OperationName == "" {
+			msg = "operationName is required for a document with several operations"
+		}
+		return GraphQLResponse{Errors: []GraphQLError{{Message: msg}}}
+	}
+
+	if err := validateSelection("Query", op.selection); err != nil {
+		return GraphQLResponse{Errors: []GraphQLError{{Message: err.Error()}}}
+	}
+	if err := checkGraphQLLimits(op.selection); err != nil {
+		return GraphQLResponse{Errors: []GraphQLError{{Message: err.Error()}}}
+	}
+
+	e := &gqlExecution{variables: make(map[string]any), charge: charge}
+	for _, v := range op.variables {
+		value, ok := req.Variables[v.name]
+		switch {
+		case ok:
+			e.variables[v.name] = value
+		case v.def != nil:
+			e.variables[v.name] = v.def.literal
+		case v.required:
+			return GraphQLResponse{Errors: []GraphQLError{{Message: "variable $" + v.name + " is required"}}}
+		}
+	}
+
+	data := e.selectObject("Query", nil, op.selection, nil)
+	return GraphQLResponse{Data: data, Errors: e.errors}
+}
+
+// checkGraphQLLimits rejects a selection nested deeper than
+// maxGraphQLDepth or with more than maxGraphQLFields fields or
+// maxGraphQLAliases aliases
+func checkGraphQLLimits(selection []*gqlField) error {
+	fields, aliases := 0, 0
+	var walk func(selection []*gqlField, depth int) error
+	walk = func(selection []*gqlField, depth int) error {
+		if depth > maxGraphQLDepth {
+			return fmt.Errorf("query is nested deeper than %d levels", maxGraphQLDepth)
+		}
+		for _, f := range selection {
+			if fields++; fields > maxGraphQLFields {
+				return fmt.Errorf("query selects more than %d fields", maxGraphQLFields)
+			}
+			if f.alias != f.name {
+				if aliases++; aliases > maxGraphQLAliases {
+					return fmt.Errorf("query has more than %d aliases", maxGraphQLAliases)
+				}
+			}
+			if err := walk(f.selection, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return walk(selection, 1)
+}
+
+// validateSelection checks the fields, arguments and subselections of a
+// selection on an object of typ against the schema
+func validateSelection(typ string, selection []*gqlField) error {
+	for _, f := range selection {
+		if f.name == "__typename" {
+			continue
+		}
+		fieldType, ok := graphqlTypes[typ][f.name]
+		if !ok {
+			return fmt.Errorf("cannot query field %q on type %s", f.name, typ)
+		}
+		for name := range f.args {
+			if !slices.Contains(graphqlArgs[typ+"."+f.name], name) {
+				return fmt.Errorf("unknown argument %q on field %s.%s", name, typ, f.name)
+			}
+		}
+		switch {
+		case fieldType == "" && f.selection != nil:
+			return fmt.Errorf("field %q of type %s is a scalar and takes no selection", f.name, typ)
+		case fieldType != "" && f.selection == nil:
+			return fmt.Errorf("field %q of type %s must have a selection of subfields", f.name, typ)
+		case fieldType != "":
+			if err := validateSelection(fieldType, f.selection); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// selectObject resolves the selection on an object of typ. Field errors
+// are recorded and resolve to null.
+func (e *gqlExecution) selectObject(typ string, obj any, selection []*gqlField, path []any) *gqlResult {
+	out := &gqlResult{values: make(map[string]any)}
+	for _, f := range selection {
+		fieldPath := append(path[:len(path):len(path)], f.alias)
+		v, err := e.resolveField(typ, obj, f, fieldPath)
+		if err != nil {
+			e.errors = append(e.errors, GraphQLError{Message: err.Error(), Path: fieldPath})
+			v = nil
+		}
+		out.set(f.alias, v)
+	}
+	return out
+}
+
+// resolveField resolves a field of obj and its selection
+func (e *gqlExecution) resolveField(typ string, obj any, f *gqlField, path []any) (any, error) {
+	if f.name == "__typename" {
+		return typ, nil
+	}
+	fieldType := graphqlTypes[typ][f.name]
+
+	args, err := e.arguments(f)
+	if err != nil {
+		return nil, err
+	}
+	if cost := graphqlCosts[typ+"."+f.name]; cost > 0 {
+		if err := e.charge(cost); err != nil {
+			return nil, err
+		}
+		e.charged += cost
+	}
+	v, err := resolveGraphQL(typ, obj, f.name, args)
+	if err != nil || fieldType == "" || v == nil {
+		return v, err
+	}
+
+	if list, ok := v.([]any); ok {
+		items := make([]any, len(list))
+		for i, item := range list {
+			items[i] = e.selectObject(fieldType, item, f.selection, append(path[:len(path):len(path)], i))
+		}
+		return items, nil
+	}
+	return e.selectObject(fieldType, v, f.selection, path), nil
+}
+
+// arguments returns the arguments of f, with the variables substituted,
+// as the query parameters of the matching REST route
+func (e *gqlExecution) arguments(f *gqlField) (url.Values, error) {
+	values := make(url.Values)
+	for name, arg := range f.args {
+		v := arg.literal
+		if arg.variable != "" {
+			var ok bool
+			if v, ok = e.variables[arg.variable]; !ok {
+				return nil, fmt.Errorf("variable $%s is not defined", arg.variable)
+			}
+		}
+		switch v := v.(type) {
+		case nil:
+		case string:
+			values.Set(name, v)
+		case bool:
+			values.Set(name, strconv.FormatBool(v))
+		case float64:
+			values.Set(name, strconv.FormatFloat(v, 'f', -1, 64))
+		default:
+			return nil, fmt.Errorf("argument %q must be a scalar", name)
+		}
+	}
+	return values, nil
+}
+
+// resolveGraphQL returns the value of a field of obj: a []any for lists,
+// and for objects the value selectObject resolves their fields on
+func resolveGraphQL(typ string, obj any, field string, args url.Values) (any, error) {
+	switch typ + "." + field {
+	case "Query.seasons":
+		return genericJSON(availableSeasons())
+	case "Query.games":
+		return graphqlGames(args)
+	case "Query.team":
+		name := args.Get("name")
+		if len(gamesForTeam(name)) == 0 {
+			return nil, nil
+		}
+		return name, nil
+	case "Query.teams":
+		var teams []any
+		for _, team := range teamAbbreviations() {
+			teams = append(teams, team)
+		}
+		return teams, nil
+	case "Team.name":
+		return obj, nil
+	case "Team.games":
+		args.Set("team", obj.(string))
+		return graphqlGames(args)
+	}
+
+	// Fields of the generic objects
+	m, _ := obj.(map[string]any)
+	return m[field], nil
+}
+
+// graphqlGames resolves a game list from its arguments, the way the list
+// routes do from their query parameters
+func graphqlGames(args url.Values) (any, error) {
+	rater := raters[defaultAlgorithm]
+	if v := args.Get("algo"); v != "" {
+		var ok bool
+		if rater, ok = raters[v]; !ok {
+			return nil, fmt.Errorf("algo: must be one of %s", strings.Join(algorithmNames(), ", "))
+		}
+	}
+	query, qerr := parseGameQuery(args)
+	if qerr != nil {
+		return nil, qerr
+	}
+	page, paginated, qerr := parsePagination(args)
+	if qerr != nil {
+		return nil, qerr
+	}
+
+	season, week, team := args.Get("season"), args.Get("week"), args.Get("team")
+	if week != "" && !isValidWeek(week) {
+		return nil, errors.New("week: " + invalidWeekMessage)
+	}
+	var games []ProcessedGameStats
+	switch {
+	case team != "":
+		for _, g := range gamesForTeam(team) {
+			if (season == "" || g.Season == season) && (week == "" || g.Week == week) {
+				games = append(games, g)
+			}
+		}
+		if rater.Version() != defaultAlgorithm {
+			games = rerateGames(rater, games)
+		}
+	case season != "" && week != "":
+		gameList, err := loadGameStats(store.WeekFile(season, week))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, errors.New("error reading data")
+		}
+		games = processGames(rater, gameList)
+		for i := range games {
+			games[i].setLocation(season, week)
+		}
+	case season != "":
+		games = seasonGames(context.Background(), rater, season)
+	default:
+		return nil, errors.New("games needs a season or a team")
+	}
+
+	games = query.apply(games)
+	if paginated {
+		games = paginate(games, page).Items
+	}
+	if args.Get("spoilerFree") == "true" {
+		return genericJSON(spoilerFreeGames(games))
+	}
+	return genericJSON(games)
+}
+
+// genericJSON converts v to its generic JSON form, so that objects become
+// maps keyed by their JSON field names
+func genericJSON(v any) (any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var generic any
+	if err := json.Unmarshal(data, &generic); err != nil {
+		return nil, err
+	}
+	if generic == nil {
+		// A nil slice is an empty list
+		return []any{}, nil
+	}
+	return generic, nil
+}
+
+// handleGraphQL serves GraphQL queries of the games, teams and seasons, so
+// clients fetch only the fields they render. Queries come as a JSON body
+// of POST, or as ?query=, ?variables= and ?operationName= of GET;
+// GET /graphql?sdl returns the schema. Each game list resolved is charged
+// to the compute budget, and resolves to an error once it is spent.
+func handleGraphQL(w http.ResponseWriter, r *http.Request) {
+	var req GraphQLRequest
+	var status int
+	var err error
+	if r.Method == http.MethodGet {
+		values := r.URL.Query()
+		if values.Has("sdl") {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			setCacheHeaders(w, "meta")
+			io.WriteString(w, graphqlSchema+"\n")
+			return
+		}
+		req.Query, req.OperationName = values.Get("query"), values.Get("operationName")
+		if v := values.Get("variables"); v != "" {
+			if err = json.Unmarshal([]byte(v), &req.Variables); err != nil {
+				status, err = http.StatusBadRequest, errors.New("variables must be a JSON object")
+			}
+		}
+	} else {
+		data, rerr := io.ReadAll(http.MaxBytesReader(w, r.Body, maxGraphQLBytes))
+		switch {
+		case rerr != nil:
+			status, err = http.StatusRequestEntityTooLarge, fmt.Errorf("request body exceeds %d bytes", maxGraphQLBytes)
+		case json.Unmarshal(data, &req) != nil:
+			status, err = http.StatusBadRequest, errors.New("request body must be a JSON object with a query")
+		}
+	}
+	if err == nil && strings.TrimSpace(req.Query) == "" {
+		status, err = http.StatusBadRequest, errors.New("query is required")
+	}
+
+	resp := GraphQLResponse{Errors: []GraphQLError{{Message: fmt.Sprint(err)}}}
+	if err == nil {
+		charged := 0.0
+		status, resp = http.StatusOK, executeGraphQL(req, func(n float64) error {
+			if err := spendCompute(r, n); err != nil {
+				return err
+			}
+			charged += n
+			return nil
+		})
+		w.Header().Set("X-Compute-Cost", strconv.FormatFloat(graphqlQueryCost+charged, 'f', -1, 64))
+		if resp.Data == nil {
+			// The document could not be executed at all
+			status = http.StatusBadRequest
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method == http.MethodGet && status == http.StatusOK {
+		setCacheHeaders(w, "season")
+	} else {
+		w.Header().Set("Cache-Control", "no-store")
+	}
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		writeError(w, r, http.StatusInternalServerError, "error encoding response")
+	}
+}
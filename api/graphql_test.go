@@ -0,0 +1,160 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func serveGraphQL(t *testing.T, req *http.Request) (int, map[string]any) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	handleGraphQL(rec, req)
+	var body map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("failed to parse response %q: %v", rec.Body.String(), err)
+	}
+	return rec.Code, body
+}
+
+func TestHandleGraphQL(t *testing.T) {
+	useTestStore(t, setupTestData(t))
+	teamIndexMu.Lock()
+	teamIndex = make(map[string][]ProcessedGameStats)
+	matchupIndex = make(map[string][]ProcessedGameStats)
+	teamIndexMu.Unlock()
+	preloadCache(dataStore)
+
+	query := `query Games($season: String!, $limit: Int = 1) {
+		games(season: $season, limit: $limit, sort: "totalRating") { id rating: totalRating week }
+		seasons { season weeks { week } }
+		team(name: "A") { name games(week: "2", spoilerFree: true) { shortName offensiveRating } }
+	}`
+	body := `{"query": ` + string(mustJSON(t, query)) + `, "variables": {"season": "2024"}}`
+	code, resp := serveGraphQL(t, httptest.NewRequest("POST", "/graphql", strings.NewReader(body)))
+	if code != http.StatusOK || resp["errors"] != nil {
+		t.Fatalf("expected status 200 without errors, got %d: %v", code, resp)
+	}
+	data := resp["data"].(map[string]any)
+
+	games := data["games"].([]any)
+	if len(games) != 1 {
+		t.Fatalf("expected 1 game with limit 1, got %v", games)
+	}
+	game := games[0].(map[string]any)
+	if len(game) != 3 || game["id"] != "game1" || game["rating"] != 21.5 {
+		t.Errorf("expected only the selected fields, got %v", game)
+	}
+
+	seasons := data["seasons"].([]any)
+	if len(seasons) != 1 || len(seasons[0].(map[string]any)["weeks"].([]any)) != 2 {
+		t.Errorf("unexpected seasons %v", seasons)
+	}
+
+	team := data["team"].(map[string]any)
+	teamGames := team["games"].([]any)
+	if team["name"] != "A" || len(teamGames) != 1 {
+		t.Fatalf("unexpected team %v", team)
+	}
+	if g := teamGames[0].(map[string]any); g["shortName"] != "A @ B" || g["offensiveRating"] != nil {
+		t.Errorf("expected spoiler fields to be null in spoiler-free mode, got %v", g)
+	}
+}
+
+func TestHandleGraphQLKeepsSelectionOrder(t *testing.T) {
+	useTestStore(t, setupTestData(t))
+
+	q := url.Values{"query": {`{ games(season: "2024", week: "1") { totalRating id } }`}}
+	rec := httptest.NewRecorder()
+	handleGraphQL(rec, httptest.NewRequest("GET", "/graphql?"+q.Encode(), nil))
+	if want := `{"data":{"games":[{"totalRating":21.5,"id":"game1"}]}}`; strings.TrimSpace(rec.Body.String()) != want {
+		t.Errorf("expected %s, got %s", want, rec.Body.String())
+	}
+	if rec.Header().Get("Cache-Control") == "no-store" {
+		t.Error("expected GET queries to be cacheable")
+	}
+}
+
+func TestHandleGraphQLErrors(t *testing.T) {
+	useTestStore(t, setupTestData(t))
+
+	tests := []struct {
+		query   string
+		status  int
+		message string
+	}{
+		{`{ games(season: "2024") { id`, http.StatusBadRequest, "syntax error at position"},
+		{`mutation { games { id } }`, http.StatusBadRequest, "only queries are supported"},
+		{`{ games { id } }`, http.StatusOK, "games needs a season or a team"},
+		{`{ games(season: "2024") { score } }`, http.StatusBadRequest, `cannot query field "score" on type Game`},
+		{`{ games(season: "2024") }`, http.StatusBadRequest, "must have a selection of subfields"},
+		{`{ games(season: "2024", color: "red") { id } }`, http.StatusBadRequest, `unknown argument "color"`},
+		{`{ games(season: "2024", sort: "nope") { id } }`, http.StatusOK, "sort:"},
+		{`{ seasons { season } ... on Query { teams { name } } }`, http.StatusBadRequest, "fragments are not supported"},
+	}
+	for _, tt := range tests {
+		q := url.Values{"query": {tt.query}}
+		code, resp := serveGraphQL(t, httptest.NewRequest("GET", "/graphql?"+q.Encode(), nil))
+		if code != tt.status {
+			t.Errorf("%s: expected status %d, got %d", tt.query, tt.status, code)
+		}
+		errs, _ := resp["errors"].([]any)
+		if len(errs) != 1 || !strings.Contains(errs[0].(map[string]any)["message"].(string), tt.message) {
+			t.Errorf("%s: expected an error containing %q, got %v", tt.query, tt.message, resp["errors"])
+		}
+		if tt.status == http.StatusOK && resp["data"].(map[string]any)["games"] != nil {
+			t.Errorf("%s: expected the failed field to be null", tt.query)
+		}
+	}
+}
+
+func TestHandleGraphQLSchema(t *testing.T) {
+	rec := httptest.NewRecorder()
+	handleGraphQL(rec, httptest.NewRequest("GET", "/graphql?sdl", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "type Query") {
+		t.Errorf("expected the schema, got %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func mustJSON(t *testing.T, v any) []byte {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestHandleGraphQLLimits(t *testing.T) {
+	useTestStore(t, setupTestData(t))
+	preloadCache(dataStore)
+	useFakeClock(t)
+	old := defaultComputeBudget
+	setComputeBudget(Budget{Rate: 1, Burst: 2})
+	t.Cleanup(func() { setComputeBudget(old) })
+
+	var aliases strings.Builder
+	for i := 0; i <= maxGraphQLAliases; i++ {
+		fmt.Fprintf(&aliases, "s%d: seasons { season } ", i)
+	}
+	q := url.Values{"query": {"{ " + aliases.String() + "}"}}
+	if code, resp := serveGraphQL(t, httptest.NewRequest("GET", "/graphql?"+q.Encode(), nil)); code != http.StatusBadRequest {
+		t.Errorf("expected too many aliases to be rejected, got %d %v", code, resp)
+	}
+
+	// Both teams scan their games, the budget of 2 is then spent
+	q = url.Values{"query": {`{ teams { name games { id } } }`}}
+	rec := httptest.NewRecorder()
+	handleGraphQL(rec, httptest.NewRequest("GET", "/graphql?"+q.Encode(), nil))
+	if rec.Code != http.StatusOK || strings.Contains(rec.Body.String(), "errors") || rec.Header().Get("X-Compute-Cost") != "3" {
+		t.Fatalf("expected the lists within the budget, got %d cost %s %s", rec.Code, rec.Header().Get("X-Compute-Cost"), rec.Body)
+	}
+	code, resp := serveGraphQL(t, httptest.NewRequest("GET", "/graphql?"+q.Encode(), nil))
+	errs, _ := resp["errors"].([]any)
+	if code != http.StatusOK || len(errs) != 2 || !strings.Contains(errs[0].(map[string]any)["message"].(string), "compute budget exhausted") {
+		t.Errorf("expected the lists over the budget to fail, got %d %v", code, resp)
+	}
+}
@@ -0,0 +1,140 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// idempotencyTTL is how long the result of a keyed request is replayed
+const idempotencyTTL = 24 * time.Hour
+
+// maxIdempotencyKey bounds the length of an Idempotency-Key header
+const maxIdempotencyKey = 255
+
+// idempotentResult is the stored outcome of a keyed request. A result
+// without a status is still in flight.
+type idempotentResult struct {
+	fingerprint [sha256.Size]byte
+	status      int
+	header      http.Header
+	body        []byte
+	createdAt   time.Time
+}
+
+// idempotencyResults holds the results by API key name, method, path and
+// Idempotency-Key
+var (
+	idempotencyResults   = make(map[string]*idempotentResult)
+	idempotencyResultsMu sync.Mutex
+)
+
+// recorder captures a response so it can be stored and replayed
+type recorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *recorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *recorder) Write(p []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	rec.body.Write(p)
+	return rec.ResponseWriter.Write(p)
+}
+
+// withIdempotency makes admin mutations safe to retry. A request carrying
+// an Idempotency-Key header runs once; retries with the same key and body
+// within idempotencyTTL get the stored response back with
+// Idempotent-Replayed: true. Reusing a key for a different body is a 422,
+// and a retry while the first request still runs is a 409. 5xx responses
+// are not stored so the request can be retried. It must wrap handlers
+// behind requireRole, keys being scoped to the API key.
+func withIdempotency(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A dry run changes nothing, so it neither replays nor reserves
+		// the key of the real request
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" || r.URL.Query().Get("dryRun") == "true" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKey {
+			writeError(w, r, http.StatusBadRequest, "Idempotency-Key must be at most "+strconv.Itoa(maxIdempotencyKey)+" characters")
+			return
+		}
+
+		// The body is read up front for its fingerprint; one byte past the
+		// ingest limit is enough for the handler to reject it
+		data, err := io.ReadAll(io.LimitReader(r.Body, maxIngestBytes+1))
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "error reading request body")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(data))
+		fingerprint := sha256.Sum256(append([]byte(r.URL.RawQuery+"\n"), data...))
+
+		apiKey, _ := requestAPIKey(r)
+		scope := apiKey.Name + " " + r.Method + " " + r.URL.Path + " " + key
+		now := clock.Now()
+
+		idempotencyResultsMu.Lock()
+		for k, res := range idempotencyResults {
+			if res.status != 0 && now.Sub(res.createdAt) >= idempotencyTTL {
+				delete(idempotencyResults, k)
+			}
+		}
+		res, ok := idempotencyResults[scope]
+		switch {
+		case ok && res.fingerprint != fingerprint:
+			idempotencyResultsMu.Unlock()
+			writeError(w, r, http.StatusUnprocessableEntity, "Idempotency-Key was already used for a different request")
+			return
+		case ok && res.status == 0:
+			idempotencyResultsMu.Unlock()
+			writeError(w, r, http.StatusConflict, "a request with this Idempotency-Key is still being processed")
+			return
+		case ok:
+			idempotencyResultsMu.Unlock()
+			for name, values := range res.header {
+				w.Header()[name] = values
+			}
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(res.status)
+			w.Write(res.body)
+			return
+		}
+		idempotencyResults[scope] = &idempotentResult{fingerprint: fingerprint, createdAt: now}
+		idempotencyResultsMu.Unlock()
+
+		rec := &recorder{ResponseWriter: w}
+		defer func() {
+			idempotencyResultsMu.Lock()
+			defer idempotencyResultsMu.Unlock()
+			if rec.status == 0 || rec.status >= 500 {
+				delete(idempotencyResults, scope)
+				return
+			}
+			idempotencyResults[scope] = &idempotentResult{
+				fingerprint: fingerprint,
+				status:      rec.status,
+				header:      w.Header().Clone(),
+				body:        rec.body.Bytes(),
+				createdAt:   clock.Now(),
+			}
+		}()
+		next.ServeHTTP(rec, r)
+	})
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestWithIdempotency(t *testing.T) {
+	useAPIKeys(t,
+		APIKey{Name: "ops", Key: "a-key", Roles: []string{roleAdmin}},
+		APIKey{Name: "other", Key: "o-key", Roles: []string{roleAdmin}},
+	)
+
+	var calls atomic.Int32
+	status := http.StatusCreated
+	h := requireRole(roleAdmin, withIdempotency(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		w.Header().Set("Location", "/games/2024/3")
+		w.WriteHeader(status)
+		w.Write([]byte(strings.Repeat("x", int(n))))
+	})))
+	post := func(apiKey, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/games/2024/3", strings.NewReader(body))
+		req.Header.Set("X-API-Key", apiKey)
+		if key != "" {
+			req.Header.Set("Idempotency-Key", key)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	first := post("a-key", "k1", "[1]")
+	retry := post("a-key", "k1", "[1]")
+	if calls.Load() != 1 {
+		t.Fatalf("expected the retry not to run the handler, got %d calls", calls.Load())
+	}
+	if retry.Code != first.Code || retry.Body.String() != first.Body.String() || retry.Header().Get("Location") != "/games/2024/3" {
+		t.Errorf("expected the stored response, got %d %q", retry.Code, retry.Body)
+	}
+	if retry.Header().Get("Idempotent-Replayed") != "true" {
+		t.Error("expected Idempotent-Replayed on the replay")
+	}
+
+	if rec := post("a-key", "k1", "[2]"); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 for a reused key, got %d", rec.Code)
+	}
+
+	// Keys are scoped to the API key, and requests without one always run
+	post("o-key", "k1", "[1]")
+	post("a-key", "", "[1]")
+	post("a-key", "", "[1]")
+	if calls.Load() != 4 {
+		t.Errorf("expected 4 calls, got %d", calls.Load())
+	}
+
+	// Server errors are not stored
+	status = http.StatusInternalServerError
+	post("a-key", "k2", "[1]")
+	post("a-key", "k2", "[1]")
+	if calls.Load() != 6 {
+		t.Errorf("expected failed requests to run again, got %d calls", calls.Load())
+	}
+
+	if rec := post("a-key", strings.Repeat("k", 256), "[1]"); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an oversized key, got %d", rec.Code)
+	}
+}
+
+func TestWithIdempotencyInFlight(t *testing.T) {
+	useAPIKeys(t, APIKey{Name: "ops", Key: "a-key", Roles: []string{roleAdmin}})
+
+	var inner *httptest.ResponseRecorder
+	var h http.Handler
+	h = requireRole(roleAdmin, withIdempotency(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A retry arriving while the first request runs
+		req := httptest.NewRequest("POST", "/admin/refresh", nil)
+		req.Header.Set("X-API-Key", "a-key")
+		req.Header.Set("Idempotency-Key", "busy")
+		inner = httptest.NewRecorder()
+		h.ServeHTTP(inner, req)
+	})))
+
+	req := httptest.NewRequest("POST", "/admin/refresh", nil)
+	req.Header.Set("X-API-Key", "a-key")
+	req.Header.Set("Idempotency-Key", "busy")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if inner.Code != http.StatusConflict {
+		t.Errorf("expected 409 while in flight, got %d", inner.Code)
+	}
+}
@@ -0,0 +1,230 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// IDMapping is a game's ID in each data provider's namespace, e.g.
+// {"espn": "401671789", "nflverse": "2024_01_BAL_KC", "slug": "2024-w1-bal-kc"}
+type IDMapping struct {
+	Season string            `json:"season,omitempty"`
+	Week   string            `json:"week,omitempty"`
+	IDs    map[string]string `json:"ids"`
+}
+
+// idDerivers compute a game's ID in a namespace from the week files. A
+// provider whose IDs cannot be derived lists them in the mapping file
+// instead; new derivable providers only need to register here.
+var idDerivers = map[string]func(season, week string, g GameStats) string{
+	"espn": func(_, _ string, g GameStats) string { return g.ID },
+	"slug": func(season, week string, g GameStats) string {
+		return gameSlug(season, week, g.ShortName, g.ID)
+	},
+	"nflverse": nflverseGameID,
+}
+
+// nflverseTeams maps the ESPN abbreviations nflverse spells differently
+var nflverseTeams = map[string]string{"WSH": "WAS", "LAR": "LA"}
+
+// nflverseGameID builds the nflverse game_id, {season}_{week}_{away}_{home}
+// with the playoff rounds numbered after week 18. Seasons with 17 regular
+// season weeks number them one lower and need the mapping file.
+func nflverseGameID(season, week string, g GameStats) string {
+	teams := splitMatchup(g.ShortName)
+	order, ok := weekOrder(week)
+	if len(teams) != 2 || !ok {
+		return ""
+	}
+	for i, t := range teams {
+		t = strings.ToUpper(t)
+		if alias, ok := nflverseTeams[t]; ok {
+			t = alias
+		}
+		teams[i] = t
+	}
+	return fmt.Sprintf("%s_%02d_%s_%s", season, order, teams[0], teams[1])
+}
+
+// The ID index: mappings derived from the loaded weeks, and those of the
+// mapping file, both keyed by "namespace:id"
+var (
+	idIndexMu  sync.RWMutex
+	idIndex    = make(map[string]*IDMapping)
+	idOverride = make(map[string]*IDMapping)
+)
+
+// idKey is the index key of an ID; slugs are case-insensitive
+func idKey(namespace, id string) string {
+	if namespace == "slug" {
+		id = strings.ToLower(id)
+	}
+	return namespace + ":" + id
+}
+
+// loadIDMappings reads the mapping file, a JSON array of IDMapping
+// maintained by hand or by enrichment jobs. Its IDs take precedence over
+// the derived ones and it may list games missing from the data.
+func loadIDMappings(path string) (map[string]*IDMapping, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var mappings []*IDMapping
+	if err := json.Unmarshal(data, &mappings); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	index := make(map[string]*IDMapping)
+	for i, m := range mappings {
+		if len(m.IDs) == 0 {
+			return nil, fmt.Errorf("%s: mapping %d has no ids", path, i)
+		}
+		for ns, id := range m.IDs {
+			key := idKey(ns, id)
+			if _, dup := index[key]; dup {
+				return nil, fmt.Errorf("%s: %s is mapped twice", path, key)
+			}
+			index[key] = m
+		}
+	}
+	return index, nil
+}
+
+// setIDMappings replaces the mappings of the mapping file
+func setIDMappings(index map[string]*IDMapping) {
+	idIndexMu.Lock()
+	idOverride = index
+	idIndexMu.Unlock()
+}
+
+func indexIDs(season, week string, games []GameStats) {
+	idIndexMu.Lock()
+	defer idIndexMu.Unlock()
+	for _, g := range games {
+		if g.ID == "" {
+			continue
+		}
+		m := &IDMapping{Season: season, Week: week, IDs: make(map[string]string)}
+		for ns, derive := range idDerivers {
+			if id := derive(season, week, g); id != "" {
+				m.IDs[ns] = id
+			}
+		}
+		for ns, id := range m.IDs {
+			idIndex[idKey(ns, id)] = m
+		}
+	}
+}
+
+func unindexIDs(season, week string) {
+	idIndexMu.Lock()
+	defer idIndexMu.Unlock()
+	for key, m := range idIndex {
+		if m.Season == season && m.Week == week {
+			delete(idIndex, key)
+		}
+	}
+}
+
+// idNamespaces returns the known namespaces, the derived ones first
+func idNamespaces() []string {
+	seen := make(map[string]bool)
+	var derived, listed []string
+	for ns := range idDerivers {
+		seen[ns] = true
+		derived = append(derived, ns)
+	}
+	for key := range idOverride {
+		if ns, _, _ := strings.Cut(key, ":"); !seen[ns] {
+			seen[ns] = true
+			listed = append(listed, ns)
+		}
+	}
+	sort.Strings(derived)
+	sort.Strings(listed)
+	return append(derived, listed...)
+}
+
+// lookupID resolves id, in namespace or in any namespace when empty, to
+// its mapping: the derived IDs of the game with those of the mapping file
+// on top. The caller holds idIndexMu.
+func lookupID(namespace, id string) (IDMapping, bool) {
+	namespaces := []string{namespace}
+	if namespace == "" {
+		namespaces = idNamespaces()
+	}
+	var derived, listed *IDMapping
+	for _, ns := range namespaces {
+		key := idKey(ns, id)
+		if derived, listed = idIndex[key], idOverride[key]; derived != nil || listed != nil {
+			break
+		}
+	}
+	if derived == nil && listed == nil {
+		return IDMapping{}, false
+	}
+
+	// Join the two through any ID they share
+	if derived == nil {
+		for ns, other := range listed.IDs {
+			if derived = idIndex[idKey(ns, other)]; derived != nil {
+				break
+			}
+		}
+	}
+	if listed == nil {
+		for ns, other := range derived.IDs {
+			if listed = idOverride[idKey(ns, other)]; listed != nil {
+				break
+			}
+		}
+	}
+
+	m := IDMapping{IDs: make(map[string]string)}
+	for _, src := range []*IDMapping{derived, listed} {
+		if src == nil {
+			continue
+		}
+		if src.Season != "" {
+			m.Season, m.Week = src.Season, src.Week
+		}
+		for ns, other := range src.IDs {
+			m.IDs[ns] = other
+		}
+	}
+	return m, true
+}
+
+// handleIDMap resolves a game ID of any provider, or of the namespace of
+// ?ns=, to its IDs in every other
+func handleIDMap(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	ns := r.URL.Query().Get("ns")
+
+	idIndexMu.RLock()
+	known := ns == ""
+	for _, name := range idNamespaces() {
+		known = known || name == ns
+	}
+	m, ok := lookupID(ns, id)
+	idIndexMu.RUnlock()
+
+	if !known {
+		writeQueryError(w, r, &QueryError{Param: "ns", Value: ns, Message: "unknown namespace"})
+		return
+	}
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "unknown game "+id)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	setCacheHeaders(w, "meta")
+	if err := json.NewEncoder(w).Encode(m); err != nil {
+		writeError(w, r, http.StatusInternalServerError, "error encoding response")
+	}
+}
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func useIDIndex(t *testing.T) {
+	t.Helper()
+	idIndexMu.Lock()
+	oldIndex, oldOverride := idIndex, idOverride
+	idIndex, idOverride = make(map[string]*IDMapping), make(map[string]*IDMapping)
+	idIndexMu.Unlock()
+	t.Cleanup(func() {
+		idIndexMu.Lock()
+		idIndex, idOverride = oldIndex, oldOverride
+		idIndexMu.Unlock()
+	})
+}
+
+func TestNflverseGameID(t *testing.T) {
+	for _, tt := range []struct {
+		week, shortName, want string
+	}{
+		{"1", "BAL @ KC", "2024_01_BAL_KC"},
+		{"12", "LAR @ WSH", "2024_12_LA_WAS"},
+		{"wildcard", "PIT @ BAL", "2024_19_PIT_BAL"},
+		{"superbowl", "KC VS PHI", "2024_22_KC_PHI"},
+		{"1", "TBD", ""},
+	} {
+		if got := nflverseGameID("2024", tt.week, GameStats{ShortName: tt.shortName}); got != tt.want {
+			t.Errorf("nflverseGameID(%s, %s) = %q, want %q", tt.week, tt.shortName, got, tt.want)
+		}
+	}
+}
+
+func TestHandleIDMap(t *testing.T) {
+	useIDIndex(t)
+	indexIDs("2024", "1", []GameStats{{ID: "401671789", ShortName: "BAL @ KC"}})
+
+	path := filepath.Join(t.TempDir(), "idmap.json")
+	mapping := `[
+		{"ids": {"espn": "401671789", "pfr": "202409050kan"}},
+		{"season": "1999", "week": "1", "ids": {"nflverse": "1999_01_BUF_IND", "pfr": "199909120clt"}}
+	]`
+	if err := os.WriteFile(path, []byte(mapping), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	index, err := loadIDMappings(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setIDMappings(index)
+
+	get := func(target string) (*httptest.ResponseRecorder, IDMapping) {
+		rec := httptest.NewRecorder()
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /meta/idmap/{id}", handleIDMap)
+		mux.ServeHTTP(rec, httptest.NewRequest("GET", target, nil))
+		var m IDMapping
+		if rec.Code == http.StatusOK {
+			if err := json.Unmarshal(rec.Body.Bytes(), &m); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return rec, m
+	}
+
+	// Every ID of the game resolves to the same mapping
+	for _, id := range []string{"401671789", "2024_01_BAL_KC", "2024-W1-BAL-KC", "202409050kan"} {
+		rec, m := get("/meta/idmap/" + id)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d", id, rec.Code)
+		}
+		want := map[string]string{"espn": "401671789", "nflverse": "2024_01_BAL_KC", "slug": "2024-w1-bal-kc", "pfr": "202409050kan"}
+		if m.Season != "2024" || m.Week != "1" || len(m.IDs) != len(want) {
+			t.Fatalf("%s: unexpected mapping %+v", id, m)
+		}
+		for ns, v := range want {
+			if m.IDs[ns] != v {
+				t.Errorf("%s: expected %s %s, got %q", id, ns, v, m.IDs[ns])
+			}
+		}
+	}
+
+	// Games missing from the data resolve from the mapping file alone
+	if rec, m := get("/meta/idmap/199909120clt?ns=pfr"); rec.Code != http.StatusOK || m.IDs["nflverse"] != "1999_01_BUF_IND" {
+		t.Errorf("unexpected response %d %+v", rec.Code, m)
+	}
+	if rec, _ := get("/meta/idmap/401671789?ns=nflverse"); rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for an ID of another namespace, got %d", rec.Code)
+	}
+	if rec, _ := get("/meta/idmap/401671789?ns=yahoo"); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an unknown namespace, got %d", rec.Code)
+	}
+
+	unindexIDs("2024", "1")
+	if rec, m := get("/meta/idmap/2024_01_BAL_KC"); rec.Code != http.StatusNotFound {
+		t.Errorf("expected the unindexed week to be gone, got %+v", m)
+	}
+}
+
+func TestLoadIDMappingsRejectsDuplicates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "idmap.json")
+	data := `[{"ids": {"espn": "1"}}, {"ids": {"espn": "1", "pfr": "x"}}]`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadIDMappings(path); err == nil {
+		t.Error("expected an ID mapped twice to be rejected")
+	}
+}
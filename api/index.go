@@ -0,0 +1,87 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/jjway/rewatchableGamesApi-go/extensions"
+)
+
+// APIIndex is the response of GET /, so a new client can find its way
+// around without the docs
+type APIIndex struct {
+	Name             string          `json:"name"`
+	Version          string          `json:"version"`
+	BasePath         string          `json:"basePath"` // prefix of the endpoints, the current API version
+	OpenAPI          string          `json:"openapi"`
+	Docs             string          `json:"docs"`
+	Algorithms       []string        `json:"algorithms"`
+	DefaultAlgorithm string          `json:"defaultAlgorithm"`
+	Coverage         DataCoverage    `json:"coverage"`
+	Endpoints        []IndexEndpoint `json:"endpoints"`
+}
+
+// DataCoverage is the span of the available data
+type DataCoverage struct {
+	FirstSeason string `json:"firstSeason,omitempty"`
+	LastSeason  string `json:"lastSeason,omitempty"`
+	Seasons     int    `json:"seasons"`
+	Weeks       int    `json:"weeks"`
+	Games       int    `json:"games"`
+}
+
+// IndexEndpoint is one route of the index
+type IndexEndpoint struct {
+	Method  string `json:"method,omitempty"`
+	Path    string `json:"path"`
+	Summary string `json:"summary,omitempty"`
+	Role    string `json:"role,omitempty"` // role the X-API-Key needs, if any
+}
+
+// dataCoverage sums up availableSeasons
+func dataCoverage() DataCoverage {
+	var c DataCoverage
+	seasons := availableSeasons()
+	for _, s := range seasons {
+		c.Weeks += len(s.Weeks)
+		c.Games += s.Games
+	}
+	if c.Seasons = len(seasons); c.Seasons > 0 {
+		c.FirstSeason, c.LastSeason = seasons[0].Season, seasons[len(seasons)-1].Season
+	}
+	return c
+}
+
+// apiIndex lists the documented routes, then those of the extensions
+func apiIndex() APIIndex {
+	idx := APIIndex{
+		Name:             translate(defaultLang, "docs.title"),
+		Version:          versionInfo().Version,
+		BasePath:         "/" + currentAPIVersion,
+		OpenAPI:          "/" + currentAPIVersion + "/openapi.json",
+		Docs:             "/" + currentAPIVersion + "/docs",
+		Algorithms:       algorithmNames(),
+		DefaultAlgorithm: defaultAlgorithm,
+		Coverage:         dataCoverage(),
+	}
+	for _, route := range openAPIRoutes() {
+		idx.Endpoints = append(idx.Endpoints, IndexEndpoint{Method: route.method, Path: route.path, Summary: route.summary, Role: route.role})
+	}
+	for _, route := range extensions.Routes() {
+		method, path, ok := strings.Cut(route.Pattern, " ")
+		if !ok {
+			method, path = "", route.Pattern
+		}
+		idx.Endpoints = append(idx.Endpoints, IndexEndpoint{Method: method, Path: path})
+	}
+	return idx
+}
+
+// handleIndex serves the API index at the root
+func handleIndex(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	setCacheHeaders(w, "season")
+	if err := json.NewEncoder(w).Encode(apiIndex()); err != nil {
+		writeError(w, r, http.StatusInternalServerError, "error encoding response")
+	}
+}
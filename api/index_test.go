@@ -0,0 +1,52 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandleIndex(t *testing.T) {
+	useTestStore(t, setupTestData(t))
+	preloadCache(dataStore)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", handleIndex)
+	mux.Handle("/", fallbackHandler(mux))
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	var idx APIIndex
+	if err := json.Unmarshal(rec.Body.Bytes(), &idx); err != nil {
+		t.Fatal(err)
+	}
+	if idx.OpenAPI != "/v1/openapi.json" || idx.BasePath != "/v1" || idx.Version == "" || idx.DefaultAlgorithm != defaultAlgorithm {
+		t.Errorf("unexpected index %+v", idx)
+	}
+	want := DataCoverage{FirstSeason: "2024", LastSeason: "2024", Seasons: 1, Weeks: 2, Games: 2}
+	if idx.Coverage != want {
+		t.Errorf("expected coverage %+v, got %+v", want, idx.Coverage)
+	}
+	found := false
+	for _, e := range idx.Endpoints {
+		if e.Method == "GET" && e.Path == "/games/{year}/{week}" && e.Summary != "" {
+			found = true
+		}
+		if e.Path == "/admin/cache" && e.Role != roleAdmin {
+			t.Errorf("expected the admin role on %+v", e)
+		}
+	}
+	if !found {
+		t.Error("expected the week route in the index")
+	}
+
+	// Other paths still get the problem 404
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/nope", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 off the root, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,293 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+
+	"github.com/jjway/rewatchableGamesApi-go/store"
+)
+
+// maxIngestBytes bounds an uploaded week file; real weeks are under 50KB
+const maxIngestBytes = 5 << 20
+
+// strictJSON rejects fields GameStats does not know, so a payload in the
+// wrong shape fails instead of being stored mostly empty
+var strictJSON = jsoniter.Config{
+	EscapeHTML:             true,
+	SortMapKeys:            true,
+	ValidateJsonRawMessage: true,
+	DisallowUnknownFields:  true,
+}.Froze()
+
+// validateWeekPayload decodes an uploaded week and checks the games
+func validateWeekPayload(data []byte) ([]GameStats, error) {
+	var games []GameStats
+	if err := strictJSON.Unmarshal(data, &games); err != nil {
+		return nil, fmt.Errorf("invalid week payload: %w", err)
+	}
+	if len(games) == 0 {
+		return nil, errors.New("week payload has no games")
+	}
+	seen := make(map[string]bool, len(games))
+	for i, g := range games {
+		switch {
+		case g.ID == "":
+			return nil, fmt.Errorf("game %d has no id", i)
+		case seen[g.ID]:
+			return nil, fmt.Errorf("duplicate game id %s", g.ID)
+		case g.ShortName == "":
+			return nil, fmt.Errorf("game %s has no shortName", g.ID)
+		}
+		seen[g.ID] = true
+	}
+	return games, nil
+}
+
+// ingestMu serializes week writes, so an If-Match check and the write it
+// guards cannot interleave with another publisher
+var ingestMu sync.Mutex
+
+// weekHash returns the hex SHA-256 of a stored week file, the hash listed
+// by the support bundle manifest. ok is false when the week does not exist.
+func weekHash(name string) (hash string, ok bool, err error) {
+	data, err := dataStore.ReadFile(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return sha256Hex(data), true, nil
+}
+
+// weekExists reports whether the store has the week file name, from the
+// cache or a stat of the file when it can, without reading the week into
+// the cache. Weeks in a compacted season file cannot be statted and are
+// read.
+func weekExists(name string) (bool, error) {
+	if _, ok := cache.peek(name); ok {
+		return true, nil
+	}
+	if ss, ok := dataStore.(store.StatStore); ok {
+		_, err := ss.Stat(name)
+		if err == nil {
+			return true, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return false, err
+		}
+	}
+	_, ok, err := weekHash(name)
+	return ok, err
+}
+
+// sha256Hex returns the hex SHA-256 of data
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// matchesETag reports whether an If-Match or If-None-Match header lists
+// the strong entity tag of hash, or * for any existing week
+func matchesETag(header, hash string, exists bool) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" && exists {
+			return true
+		}
+		if exists && strings.Trim(tag, `"`) == hash && !strings.HasPrefix(tag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// checkWritePreconditions evaluates If-Match and If-None-Match against the
+// stored week, writing a 412 when the week changed since the publisher
+// last saw it
+func checkWritePreconditions(w http.ResponseWriter, r *http.Request, name string) bool {
+	ifMatch, ifNoneMatch := r.Header.Get("If-Match"), r.Header.Get("If-None-Match")
+	if ifMatch == "" && ifNoneMatch == "" {
+		return true
+	}
+	hash, exists, err := weekHash(name)
+	if err != nil {
+		log.Printf("Error: hash %s: %v", name, err)
+		writeError(w, r, http.StatusInternalServerError, "error reading data")
+		return false
+	}
+	if exists {
+		w.Header().Set("ETag", `"`+hash+`"`)
+	}
+	switch {
+	case ifMatch != "" && !exists:
+		writeError(w, r, http.StatusPreconditionFailed, "week does not exist yet")
+		return false
+	case ifMatch != "" && !matchesETag(ifMatch, hash, exists):
+		writeError(w, r, http.StatusPreconditionFailed, "week was modified, its current hash is "+hash)
+		return false
+	case ifNoneMatch != "" && matchesETag(ifNoneMatch, hash, exists):
+		writeError(w, r, http.StatusPreconditionFailed, "week already exists")
+		return false
+	}
+	return true
+}
+
+// ingestWeek stores a validated new week and announces it on /events. It
+// returns the games served, those validation did not reject.
+func ingestWeek(ws store.WritableStore, name string, games []GameStats, data []byte) ([]GameStats, error) {
+	games, err := storeWeek(ws, name, games, data)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Ingested %s (%d games)", name, len(games))
+	publishWeekEvent("ingested", name, games)
+	return games, nil
+}
+
+// storeWeek writes a week to the store and swaps it into the cache and
+// indexes in one step, so readers see the old or the new week but never a
+// partial one. The games are validated as when the week is read, and
+// those validation rejects are not served.
+func storeWeek(ws store.WritableStore, name string, games []GameStats, data []byte) ([]GameStats, error) {
+	setWeekState(name, weekInProgress)
+	defer setWeekState(name, "")
+
+	if err := ws.WriteFile(name, data); err != nil {
+		return nil, err
+	}
+	checked := validations.check(name, games)
+	if len(checked) == len(games) {
+		recordSnapshot(name, data)
+	}
+	setCached(name, checked, len(data), sha256Hex(data))
+	unindexFile(name)
+	indexFile(name, checked)
+	schedulePublish()
+	return checked, nil
+}
+
+// handleIngestWeek stores an uploaded week file. With If-Match carrying
+// the hash of the week being replaced, or If-None-Match: * to only create
+// it, a stale upload is rejected with 412 instead of overwriting a week
+// another publisher changed. The response ETag is the hash of the stored
+// week. With ?dryRun=true the upload is validated and checked against the
+// preconditions, and the changes it would make are reported instead.
+func handleIngestWeek(w http.ResponseWriter, r *http.Request) {
+	year := r.PathValue("year")
+	week := r.PathValue("week")
+
+	ws, ok := dataStore.(store.WritableStore)
+	if !ok {
+		writeError(w, r, http.StatusNotImplemented, "the configured store is read-only")
+		return
+	}
+	if _, err := strconv.Atoi(year); err != nil {
+		writeError(w, r, http.StatusNotFound, "unknown season "+year)
+		return
+	}
+	if !isValidWeek(week) {
+		writeError(w, r, http.StatusNotFound, "unknown week "+week)
+		return
+	}
+	dryRun, qerr := dryRunRequested(r)
+	if qerr != nil {
+		writeQueryError(w, r, qerr)
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIngestBytes))
+	if err != nil {
+		writeError(w, r, http.StatusRequestEntityTooLarge, "week payload exceeds 5MB")
+		return
+	}
+	data = bytes.TrimPrefix(data, utf8BOM)
+	games, err := validateWeekPayload(data)
+	if err != nil {
+		writeError(w, r, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	name := store.WeekFile(year, week)
+	ingestMu.Lock()
+	if !checkWritePreconditions(w, r, name) {
+		ingestMu.Unlock()
+		return
+	}
+	if dryRun {
+		rep := newDryRunReport("ingest " + name)
+		rep.storeWeek(name)
+		rep.event("ingested", name)
+		rep.notifyAll()
+		ingestMu.Unlock()
+		writeDryRun(w, r, rep)
+		return
+	}
+	existed, err := weekExists(name)
+	if err != nil {
+		ingestMu.Unlock()
+		log.Printf("Error: check %s: %v", name, err)
+		writeError(w, r, http.StatusInternalServerError, "error reading data")
+		return
+	}
+	games, err = ingestWeek(ws, name, games, data)
+	ingestMu.Unlock()
+	if err != nil {
+		log.Printf("Error: ingest %s: %v", name, err)
+		writeError(w, r, http.StatusInternalServerError, "could not store week")
+		return
+	}
+	go notifyWeekPublished(year, week, games)
+
+	sum := sha256.Sum256(data)
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+
+	status := http.StatusOK
+	if !existed {
+		status = http.StatusCreated
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", versionedPath(r, "/games/"+year+"/"+week))
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(WeekStatus{Season: year, Week: week, Status: weekPublished, Games: len(games), SHA256: hex.EncodeToString(sum[:])})
+}
+
+// notifyWeekPublished tells the notification channels about a new week,
+// best games first
+func notifyWeekPublished(year, week string, games []GameStats) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	notifyAll(ctx, weekPublishedEvent(year, week, games))
+}
+
+// weekPublishedEvent is the notification of a published week, its games
+// rated with the default algorithm, best first
+func weekPublishedEvent(year, week string, games []GameStats) NotifyEvent {
+	processed := processGames(raters[defaultAlgorithm], games)
+	for i := range processed {
+		processed[i].setLocation(year, week)
+	}
+	sort.SliceStable(processed, func(i, j int) bool { return processed[i].TotalRating > processed[j].TotalRating })
+	return NotifyEvent{
+		Kind:  "week-published",
+		Year:  year,
+		Week:  week,
+		Title: "Week " + week + " of " + year + " is out",
+		Games: processed,
+	}
+}
@@ -0,0 +1,198 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jjway/rewatchableGamesApi-go/store"
+)
+
+func ingest(t *testing.T, url, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	return ingestWithHeaders(t, url, body, nil)
+}
+
+func ingestWithHeaders(t *testing.T, url, body string, header map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /games/{year}/{week}", handleIngestWeek)
+	req := httptest.NewRequest("POST", url, strings.NewReader(body))
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestIngestWeek(t *testing.T) {
+	dir := setupTestData(t)
+	useTestStore(t, dir)
+
+	rec := ingest(t, "/games/2024/3", `[{"id": "g3", "shortName": "BUF @ KC", "fullName": "Buffalo Bills at Kansas City Chiefs"}]`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body)
+	}
+	if knownMissing("2024/3.json") {
+		t.Error("expected the existence check not to mark the new week missing")
+	}
+	if loc := rec.Header().Get("Location"); loc != "/games/2024/3" {
+		t.Errorf("unexpected Location %q", loc)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "2024", "3.json"))
+	if err != nil || !strings.Contains(string(data), `"g3"`) {
+		t.Errorf("expected the week to be written, got %q, %v", data, err)
+	}
+	if games, err := loadGameStats("2024/3.json"); err != nil || games[0].ID != "g3" {
+		t.Errorf("expected the week in the cache, got %v, %v", games, err)
+	}
+	if games := gamesForTeam("chiefs"); len(games) != 1 || games[0].ID != "g3" {
+		t.Errorf("expected the week in the team index, got %v", games)
+	}
+
+	// Replacing a week answers 200
+	rec = ingest(t, "/games/2024/3", `[{"id": "g4", "shortName": "DET @ GB"}]`)
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected 200 for a replaced week, got %d", rec.Code)
+	}
+	if games := gamesForTeam("chiefs"); len(games) != 0 {
+		t.Errorf("expected the replaced game to leave the team index, got %v", games)
+	}
+
+	// A week of the store not read yet is found without being loaded
+	cache.remove("2024/2.json")
+	if rec := ingest(t, "/games/2024/2", `[{"id": "g5", "shortName": "NYJ @ MIA"}]`); rec.Code != http.StatusOK {
+		t.Errorf("expected 200 for a stored week out of the cache, got %d", rec.Code)
+	}
+}
+
+func TestIngestWeekValidation(t *testing.T) {
+	useTestStore(t, setupTestData(t))
+
+	tests := []struct {
+		url, body string
+		status    int
+	}{
+		{"/games/2024/3", `{"id": "x"}`, http.StatusUnprocessableEntity},
+		{"/games/2024/3", `[]`, http.StatusUnprocessableEntity},
+		{"/games/2024/3", `[{"shortName": "A @ B"}]`, http.StatusUnprocessableEntity},
+		{"/games/2024/3", `[{"id": "a", "shortName": "A @ B"}, {"id": "a", "shortName": "A @ B"}]`, http.StatusUnprocessableEntity},
+		{"/games/2024/3", `[{"id": "a", "shortName": "A @ B", "offense": {"points": 3}}]`, http.StatusUnprocessableEntity},
+		{"/games/2024/19", `[{"id": "a", "shortName": "A @ B"}]`, http.StatusNotFound},
+		{"/games/latest/1", `[{"id": "a", "shortName": "A @ B"}]`, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if rec := ingest(t, tt.url, tt.body); rec.Code != tt.status {
+			t.Errorf("%s %s: expected %d, got %d", tt.url, tt.body, tt.status, rec.Code)
+		}
+	}
+	if _, err := loadGameStats("2024/3.json"); err == nil {
+		t.Error("expected rejected payloads not to be stored")
+	}
+}
+
+func TestIngestWeekRejectsInvalidGames(t *testing.T) {
+	useTestStore(t, setupTestData(t))
+	t.Cleanup(func() {
+		validations.setMode(validationFlag)
+		unindexFile("2024/3.json")
+	})
+	validations.setMode(validationReject)
+
+	rec := ingest(t, "/games/2024/3", badWeek)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body)
+	}
+	var status WeekStatus
+	if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil || status.Games != 1 {
+		t.Errorf("expected the games served to be counted, got %+v, %v", status, err)
+	}
+	if games, ok := residentGames("2024/3.json"); !ok || len(games) != 1 || games[0].ID != "1" {
+		t.Errorf("expected the rejected games not to be served, got %+v", games)
+	}
+
+	rec = httptest.NewRecorder()
+	handleValidationReport(rec, httptest.NewRequest("GET", "/admin/validate", nil))
+	var report ValidationReport
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Rejected != 2 {
+		t.Errorf("expected /admin/validate to list the uploaded week, got %+v", report)
+	}
+}
+
+func TestIngestWeekReadOnlyStore(t *testing.T) {
+	old := dataStore
+	dataStore = store.NewFS(os.DirFS(t.TempDir()))
+	t.Cleanup(func() { dataStore = old })
+
+	if rec := ingest(t, "/games/2024/1", `[{"id": "a", "shortName": "A @ B"}]`); rec.Code != http.StatusNotImplemented {
+		t.Errorf("expected 501, got %d", rec.Code)
+	}
+}
+
+func TestIngestWeekIfMatch(t *testing.T) {
+	dir := setupTestData(t)
+	useTestStore(t, dir)
+
+	current, ok, err := weekHash("2024/1.json")
+	if err != nil || !ok {
+		t.Fatalf("expected a hash for 2024/1.json, got %v, %v", ok, err)
+	}
+	body := `[{"id": "g1", "shortName": "BUF @ KC", "fullName": "Buffalo Bills at Kansas City Chiefs"}]`
+
+	rec := ingestWithHeaders(t, "/games/2024/1", body, map[string]string{"If-Match": `"stale"`})
+	if rec.Code != http.StatusPreconditionFailed {
+		t.Fatalf("expected 412 for a stale hash, got %d: %s", rec.Code, rec.Body)
+	}
+	if etag := rec.Header().Get("ETag"); etag != `"`+current+`"` {
+		t.Errorf("expected the current ETag on 412, got %q", etag)
+	}
+	if games, _ := loadGameStats("2024/1.json"); games[0].ID != "game1" {
+		t.Errorf("expected a stale upload to leave the week unchanged, got %q", games[0].ID)
+	}
+
+	rec = ingestWithHeaders(t, "/games/2024/1", body, map[string]string{"If-Match": `"other", "` + current + `"`})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 for the current hash, got %d: %s", rec.Code, rec.Body)
+	}
+	updated, _, _ := weekHash("2024/1.json")
+	if etag := rec.Header().Get("ETag"); etag != `"`+updated+`"` {
+		t.Errorf("expected ETag %q of the stored week, got %q", updated, etag)
+	}
+
+	// The second publisher still holds the old hash
+	rec = ingestWithHeaders(t, "/games/2024/1", body, map[string]string{"If-Match": `"` + current + `"`})
+	if rec.Code != http.StatusPreconditionFailed {
+		t.Errorf("expected 412 after the week changed, got %d", rec.Code)
+	}
+	rec = ingestWithHeaders(t, "/games/2024/1", body, map[string]string{"If-Match": `W/"` + updated + `"`})
+	if rec.Code != http.StatusPreconditionFailed {
+		t.Errorf("expected a weak tag not to match, got %d", rec.Code)
+	}
+}
+
+func TestIngestWeekCreateOnly(t *testing.T) {
+	dir := setupTestData(t)
+	useTestStore(t, dir)
+
+	body := `[{"id": "g3", "shortName": "BUF @ KC", "fullName": "Buffalo Bills at Kansas City Chiefs"}]`
+	if rec := ingestWithHeaders(t, "/games/2024/3", body, map[string]string{"If-Match": "*"}); rec.Code != http.StatusPreconditionFailed {
+		t.Errorf("expected If-Match: * to require an existing week, got %d", rec.Code)
+	}
+	if rec := ingestWithHeaders(t, "/games/2024/3", body, map[string]string{"If-None-Match": "*"}); rec.Code != http.StatusCreated {
+		t.Fatalf("expected If-None-Match: * to create the week, got %d: %s", rec.Code, rec.Body)
+	}
+	if rec := ingestWithHeaders(t, "/games/2024/3", body, map[string]string{"If-None-Match": "*"}); rec.Code != http.StatusPreconditionFailed {
+		t.Errorf("expected If-None-Match: * to reject an existing week, got %d", rec.Code)
+	}
+	if rec := ingestWithHeaders(t, "/games/2024/3", body, map[string]string{"If-Match": "*"}); rec.Code != http.StatusOK {
+		t.Errorf("expected If-Match: * to replace an existing week, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,321 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jjway/rewatchableGamesApi-go/ratings"
+
+	"github.com/jjway/rewatchableGamesApi-go/store"
+)
+
+// defaultLeague is the league of the routes without a /leagues/{league}/
+// prefix, whose week files sit at the root of the store
+const defaultLeague = "nfl"
+
+// League is a competition the API rates, with its own season calendar,
+// week files and rating config. The week files of the other leagues than
+// the default one live under leagues/{league}/ in the store, e.g.
+// leagues/ncaaf/2024/bowls.json.
+type League struct {
+	Name               string            `json:"name"`
+	Title              string            `json:"title"`
+	RegularSeasonWeeks int               `json:"regularSeasonWeeks"`
+	Postseason         []string          `json:"postseason"`
+	PostseasonLabels   map[string]string `json:"postseasonLabels,omitempty"`
+	// RatingConfig is the path of the league's rating config, the
+	// server's one when empty
+	RatingConfig string `json:"ratingConfig,omitempty"`
+
+	config *RatingConfig
+	cache  *weekCache
+}
+
+// leagues are the served leagues by name. LEAGUES_CONFIG adds to or
+// replaces all but the default one.
+var leagues = builtinLeagues()
+
+func builtinLeagues() map[string]*League {
+	return map[string]*League{
+		defaultLeague: {
+			Name:               defaultLeague,
+			Title:              "NFL",
+			RegularSeasonWeeks: regularSeasonWeeks,
+			Postseason:         postseasonWeeks,
+			PostseasonLabels:   postseasonLabels,
+		},
+		"ncaaf": {
+			Name:               "ncaaf",
+			Title:              "College football",
+			RegularSeasonWeeks: 15,
+			Postseason:         []string{"bowls"},
+			PostseasonLabels:   map[string]string{"bowls": "Bowl Season"},
+			cache:              newWeekCache(0, 0, ""),
+		},
+	}
+}
+
+// loadLeagues reads a JSON list of leagues and registers them, loading
+// their rating configs
+func loadLeagues(path string) error {
+	data, err := readConfigFile(path)
+	if err != nil {
+		return err
+	}
+	var configs []League
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return fmt.Errorf("parse %s: %w", path, err)
+	}
+	for _, l := range configs {
+		if l.Name == defaultLeague {
+			return fmt.Errorf("league %s: the default league is configured by RATING_CONFIG", l.Name)
+		}
+		if l.Name == "" || slugPart(l.Name) != l.Name {
+			return fmt.Errorf("league %q: the name must be lowercase letters, digits and dashes", l.Name)
+		}
+		if l.RegularSeasonWeeks < 1 {
+			return fmt.Errorf("league %s: regularSeasonWeeks must be at least 1", l.Name)
+		}
+		if l.RatingConfig != "" {
+			cfg, err := loadRatingConfig(l.RatingConfig)
+			if err != nil {
+				return fmt.Errorf("league %s: load rating config: %w", l.Name, err)
+			}
+			l.config = &cfg
+		}
+		if l.Title == "" {
+			l.Title = strings.ToUpper(l.Name)
+		}
+		l.cache = newWeekCache(0, 0, "")
+		leagues[l.Name] = &l
+	}
+	return nil
+}
+
+// leagueNames lists the served leagues in order
+func leagueNames() []string {
+	names := make([]string, 0, len(leagues))
+	for name := range leagues {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// weekNames returns every week name of a season of the league in order
+func (l *League) weekNames() []string {
+	names := make([]string, 0, l.RegularSeasonWeeks+len(l.Postseason))
+	for week := 1; week <= l.RegularSeasonWeeks; week++ {
+		names = append(names, strconv.Itoa(week))
+	}
+	return append(names, l.Postseason...)
+}
+
+// isValidWeek reports whether week names a week of a season of the league
+func (l *League) isValidWeek(week string) bool {
+	if n, err := strconv.Atoi(week); err == nil {
+		return n >= 1 && n <= l.RegularSeasonWeeks
+	}
+	for _, name := range l.Postseason {
+		if week == name {
+			return true
+		}
+	}
+	return false
+}
+
+// weekLabel returns the display name of a week of the league
+func (l *League) weekLabel(week string) string {
+	if label, ok := l.PostseasonLabels[week]; ok {
+		return label
+	}
+	if _, err := strconv.Atoi(week); err == nil {
+		return "Week " + week
+	}
+	return week
+}
+
+// weekFile returns the name of a week file of the league in the store
+func (l *League) weekFile(year, week string) string {
+	if l.Name == defaultLeague {
+		return store.WeekFile(year, week)
+	}
+	return "leagues/" + l.Name + "/" + store.WeekFile(year, week)
+}
+
+// ratingConfig returns the scoring model of the league
+func (l *League) ratingConfig() RatingConfig {
+	if l.config != nil {
+		return *l.config
+	}
+	return ratingConfig
+}
+
+// rater returns the version of the rating algorithm with the league's
+// scoring model, an error for the versions that cannot take one
+func (l *League) rater(version string) (Rater, error) {
+	switch version {
+	case "v1":
+		return ratings.NewV1(l.ratingConfig()), nil
+	case "v2":
+		return ratings.NewV2(l.ratingConfig()), nil
+	}
+	return nil, fmt.Errorf("%s games are rated with v1 or v2, not %s", l.Title, version)
+}
+
+// leagueCacheTTL is how long the week files of the other leagues are
+// served before being re-read: the data directory watcher and the ingest
+// only know the default league, so they are re-read after a minute unless
+// CACHE_TTL says otherwise
+func leagueCacheTTL() time.Duration {
+	if cacheTTL > 0 {
+		return cacheTTL
+	}
+	return time.Minute
+}
+
+// games reads a week file of the league through its own cache, kept apart
+// from the default league's so the seasons, quantiles and indexes stay
+// those of the default league
+func (l *League) games(name string) ([]GameStats, error) {
+	if entry, ok := l.cache.get(name, leagueCacheTTL()); ok {
+		return entry.games, nil
+	}
+	data, err := dataStore.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	games, err := decodeWeekFile(name, data)
+	if err != nil {
+		return nil, err
+	}
+	games = validations.check(name, games)
+	l.cache.set(name, cacheEntry{games: games, size: len(data), loadedAt: clock.Now()})
+	return games, nil
+}
+
+// LeagueSummary describes a served league for GET /leagues
+type LeagueSummary struct {
+	Name    string   `json:"name"`
+	Title   string   `json:"title"`
+	Default bool     `json:"default,omitempty"`
+	Weeks   []string `json:"weeks"`
+	Path    string   `json:"path"`
+}
+
+func handleLeagues(w http.ResponseWriter, r *http.Request) {
+	summaries := make([]LeagueSummary, 0, len(leagues))
+	for _, name := range leagueNames() {
+		l := leagues[name]
+		summaries = append(summaries, LeagueSummary{
+			Name:    l.Name,
+			Title:   l.Title,
+			Default: l.Name == defaultLeague,
+			Weeks:   l.weekNames(),
+			Path:    versionedPath(r, "/leagues/"+l.Name+"/games/{year}/{week}"),
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	setCacheHeaders(w, "meta")
+	if err := json.NewEncoder(w).Encode(summaries); err != nil {
+		writeError(w, r, http.StatusInternalServerError, "error encoding response")
+	}
+}
+
+// handleLeagueWeek serves the rated games of a week of a league. The
+// default league's are those of /games/{year}/{week}.
+func handleLeagueWeek(w http.ResponseWriter, r *http.Request) {
+	league, ok := leagues[r.PathValue("league")]
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "unknown league "+r.PathValue("league")+", must be one of "+strings.Join(leagueNames(), ", "))
+		return
+	}
+	if league.Name == defaultLeague {
+		handleGamesYearWeek(w, r)
+		return
+	}
+
+	year := r.PathValue("year")
+	week := r.PathValue("week")
+	query, qerr := parseGameQuery(r.URL.Query())
+	if qerr != nil {
+		writeQueryError(w, r, qerr)
+		return
+	}
+	// The percentiles are those of the default league's games
+	if query.percentile {
+		param := "minPercentile"
+		if query.normalize != "" {
+			param = "normalize"
+		}
+		writeQueryError(w, r, &QueryError{Param: param, Value: r.URL.Query().Get(param), Message: "is only available for " + defaultLeague + " games"})
+		return
+	}
+	base, qerr := raterFor(r)
+	if qerr != nil {
+		writeQueryError(w, r, qerr)
+		return
+	}
+	rater, err := league.rater(base.Version())
+	if err != nil {
+		writeQueryError(w, r, &QueryError{Param: "algo", Value: base.Version(), Message: err.Error()})
+		return
+	}
+	format, qerr := responseFormat(r)
+	if qerr != nil {
+		writeQueryError(w, r, qerr)
+		return
+	}
+	w.Header().Add("Vary", "Accept")
+
+	if _, err := strconv.Atoi(year); err != nil || !league.isValidWeek(week) {
+		setCacheHeaders(w, "missing")
+		writeError(w, r, http.StatusNotFound, "no data for week "+week+" of "+year+" in "+league.Title)
+		return
+	}
+	gameList, err := league.games(league.weekFile(year, week))
+	if errors.Is(err, fs.ErrNotExist) {
+		setCacheHeaders(w, "missing")
+		writeError(w, r, http.StatusNotFound, "no data for week "+week+" of "+year+" in "+league.Title)
+		return
+	}
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "error reading data")
+		return
+	}
+
+	cfg := league.ratingConfig()
+	processed := rateGames(r.Context(), rater, gameList)
+	for i := range processed {
+		p := &processed[i]
+		p.Season, p.Week, p.WeekLabel = year, week, league.weekLabel(week)
+		p.Blowout = cfg.IsBlowout(gameList[i])
+	}
+	processed, reports := query.explain(processed)
+
+	if format != formatJSON {
+		if err := writeGameRows(w, r, format, "week", processed); err != nil {
+			log.Printf("Warning: streaming %s: %v", r.URL.RequestURI(), err)
+		}
+		return
+	}
+	var body any = processed
+	if isSpoilerFree(r) {
+		body = spoilerFreeGames(processed)
+	}
+	if isExplainFilters(r) {
+		body = Explained{Games: body, Matched: len(processed), Filters: reports}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	setCacheHeaders(w, "week")
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		writeError(w, r, http.StatusInternalServerError, "error encoding response")
+	}
+}
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLeagues(t *testing.T) {
+	dir := setupTestData(t)
+	useTestStore(t, dir)
+	leagues = builtinLeagues()
+	t.Cleanup(func() { leagues = builtinLeagues() })
+
+	week := filepath.Join(dir, "leagues", "ncaaf", "2024")
+	os.MkdirAll(week, 0755)
+	os.WriteFile(filepath.Join(week, "bowls.json"), []byte(`[{"id": "cfb1", "shortName": "OSU @ MICH",
+		"fullName": "Ohio State Buckeyes at Michigan Wolverines", "offense": {"totalPlays": 140, "totalPoints": 80},
+		"scenario": {"marginOfVictory": 14}}]`), 0644)
+	cfgPath := filepath.Join(dir, "ncaaf.yaml")
+	os.WriteFile(cfgPath, []byte("totalPoints: [{min: 75, points: 5}]\nblowoutMargin: 10\n"), 0644)
+	leaguesPath := filepath.Join(dir, "leagues.json")
+	os.WriteFile(leaguesPath, []byte(`[{"name": "ncaaf", "title": "College football", "regularSeasonWeeks": 15,
+		"postseason": ["bowls"], "postseasonLabels": {"bowls": "Bowl Season"}, "ratingConfig": "`+cfgPath+`"}]`), 0644)
+	mux := newMux()
+
+	get := func(target string) (*httptest.ResponseRecorder, []ProcessedGameStats) {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest("GET", target, nil))
+		var games []ProcessedGameStats
+		if rec.Code == http.StatusOK {
+			if err := json.Unmarshal(rec.Body.Bytes(), &games); err != nil {
+				t.Fatalf("%s: %v", target, err)
+			}
+		}
+		return rec, games
+	}
+
+	// The default league is served from the root of the store
+	rec, games := get("/v1/leagues/nfl/games/2024/1")
+	if rec.Code != http.StatusOK || len(games) != 1 || games[0].ID != "game1" {
+		t.Errorf("expected the games of /games/2024/1, got %d %s", rec.Code, rec.Body)
+	}
+
+	rec, games = get("/v1/leagues/ncaaf/games/2024/bowls")
+	if rec.Code != http.StatusOK || len(games) != 1 || games[0].WeekLabel != "Bowl Season" || games[0].OffensiveRating != 3 || games[0].Blowout {
+		t.Errorf("expected the bowl rated with the default config, got %d %s", rec.Code, rec.Body)
+	}
+	if _, ok := cache.peek("leagues/ncaaf/2024/bowls.json"); ok {
+		t.Error("expected the league weeks to stay out of the default league's cache")
+	}
+
+	if err := loadLeagues(leaguesPath); err != nil {
+		t.Fatal(err)
+	}
+	rec, games = get("/v2/leagues/ncaaf/games/2024/bowls")
+	if rec.Code != http.StatusOK || games[0].OffensiveRating != 5 || !games[0].Blowout || games[0].Algorithm != "v2" {
+		t.Errorf("expected the league's rating config, got %d %s", rec.Code, rec.Body)
+	}
+
+	for target, want := range map[string]int{
+		"/leagues/nhl/games/2024/1":                       http.StatusNotFound,
+		"/leagues/ncaaf/games/2024/16":                    http.StatusNotFound,
+		"/leagues/ncaaf/games/2024/superbowl":             http.StatusNotFound,
+		"/leagues/ncaaf/games/2023/bowls":                 http.StatusNotFound,
+		"/leagues/ncaaf/games/2024/bowls?minPercentile=5": http.StatusBadRequest,
+	} {
+		if rec, _ := get(target); rec.Code != want {
+			t.Errorf("%s: expected status %d, got %d", target, want, rec.Code)
+		}
+	}
+
+	// A version without a league scoring model is not swapped for another
+	raters["v9"] = extensionRater{}
+	t.Cleanup(func() { delete(raters, "v9") })
+	rec, _ = get("/v1/leagues/ncaaf/games/2024/bowls?algo=v9")
+	var p Problem
+	if err := json.Unmarshal(rec.Body.Bytes(), &p); rec.Code != http.StatusBadRequest || err != nil || p.Param != "algo" {
+		t.Errorf("expected a 400 problem on algo, got %d %s", rec.Code, rec.Body)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/v1/leagues", nil))
+	var summaries []LeagueSummary
+	json.Unmarshal(rec.Body.Bytes(), &summaries)
+	if len(summaries) != 2 || !summaries[1].Default || len(summaries[0].Weeks) != 16 || !strings.HasPrefix(summaries[0].Path, "/v1/leagues/ncaaf/") {
+		t.Errorf("expected ncaaf and the default nfl, got %+v", summaries)
+	}
+
+	os.WriteFile(leaguesPath, []byte(`[{"name": "nfl", "regularSeasonWeeks": 17}]`), 0644)
+	if err := loadLeagues(leaguesPath); err == nil {
+		t.Error("expected the default league to be rejected")
+	}
+}
+
+// extensionRater stands for a rater registered by a downstream fork
+type extensionRater struct{ v1Rater }
+
+func (extensionRater) Version() string { return "v9" }
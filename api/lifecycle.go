@@ -0,0 +1,233 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// defaultStartTimeout bounds the start of a component that sets none
+const defaultStartTimeout = 30 * time.Second
+
+// component is a subsystem of the server started and stopped by a
+// lifecycle: the store, the cache, the background jobs and so on
+type component struct {
+	name string
+	// deps name the components started before this one and stopped after
+	deps []string
+	// timeout bounds start, defaultStartTimeout when zero
+	timeout time.Duration
+
+	// start readies the component. Its context lives until the component
+	// is stopped, so background goroutines can run on it.
+	start func(ctx context.Context) error
+	// stop releases the component within the deadline of ctx, nil when
+	// cancelling the context of start is enough
+	stop func(ctx context.Context) error
+	// health reports a running component that cannot serve, nil when
+	// running is enough
+	health func() error
+}
+
+// The states of a component in /readyz
+const (
+	componentPending  = "pending"
+	componentStarting = "starting"
+	componentRunning  = "running"
+	componentFailed   = "failed"
+	componentStopped  = "stopped"
+)
+
+// ComponentHealth is the state of a component in GET /readyz
+type ComponentHealth struct {
+	Name    string `json:"name"`
+	State   string `json:"state"`
+	Healthy bool   `json:"healthy"`
+}
+
+// lifecycle starts components after their dependencies and stops them in
+// the reverse order, so a job never runs without the store it writes to
+type lifecycle struct {
+	mu         sync.Mutex
+	components []*component
+	byName     map[string]*component
+	states     map[string]string
+	cancels    map[string]context.CancelFunc
+	started    []*component
+}
+
+func newLifecycle() *lifecycle {
+	return &lifecycle{
+		byName:  make(map[string]*component),
+		states:  make(map[string]string),
+		cancels: make(map[string]context.CancelFunc),
+	}
+}
+
+// components is the lifecycle of the running server, nil in tests and
+// replicas
+var components *lifecycle
+
+// add registers c
+func (l *lifecycle) add(c *component) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.components = append(l.components, c)
+	l.byName[c.name] = c
+	l.states[c.name] = componentPending
+}
+
+// order sorts the components after their dependencies, keeping the order
+// they were added in otherwise
+func (l *lifecycle) order() ([]*component, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, c := range l.components {
+		for _, dep := range c.deps {
+			if l.byName[dep] == nil {
+				return nil, fmt.Errorf("component %s depends on unknown component %s", c.name, dep)
+			}
+		}
+	}
+
+	var ordered []*component
+	const visiting, done = 1, 2
+	marks := make(map[string]int)
+	var visit func(c *component, path []string) error
+	visit = func(c *component, path []string) error {
+		switch marks[c.name] {
+		case done:
+			return nil
+		case visiting:
+			return fmt.Errorf("components depend on each other: %v", append(path, c.name))
+		}
+		marks[c.name] = visiting
+		for _, dep := range c.deps {
+			if err := visit(l.byName[dep], append(path, c.name)); err != nil {
+				return err
+			}
+		}
+		marks[c.name] = done
+		ordered = append(ordered, c)
+		return nil
+	}
+	for _, c := range l.components {
+		if err := visit(c, nil); err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
+
+func (l *lifecycle) setState(name, state string) {
+	l.mu.Lock()
+	l.states[name] = state
+	l.mu.Unlock()
+}
+
+// start starts the components in dependency order. When one fails or
+// outlasts its timeout, those already started are stopped again and its
+// error is returned.
+func (l *lifecycle) start(ctx context.Context) error {
+	ordered, err := l.order()
+	if err != nil {
+		return err
+	}
+	for _, c := range ordered {
+		if err := l.startComponent(ctx, c); err != nil {
+			stopCtx, cancel := context.WithTimeout(context.Background(), defaultStartTimeout)
+			l.stop(stopCtx)
+			cancel()
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *lifecycle) startComponent(ctx context.Context, c *component) error {
+	timeout := c.timeout
+	if timeout == 0 {
+		timeout = defaultStartTimeout
+	}
+	l.setState(c.name, componentStarting)
+	runCtx, cancel := context.WithCancel(ctx)
+	began := time.Now()
+
+	errc := make(chan error, 1)
+	go func() { errc <- c.start(runCtx) }()
+	var err error
+	select {
+	case err = <-errc:
+	case <-time.After(timeout):
+		err = fmt.Errorf("did not start within %s", timeout)
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if err != nil {
+		cancel()
+		l.setState(c.name, componentFailed)
+		return fmt.Errorf("start %s: %w", c.name, err)
+	}
+
+	l.mu.Lock()
+	l.states[c.name] = componentRunning
+	l.cancels[c.name] = cancel
+	l.started = append(l.started, c)
+	l.mu.Unlock()
+	if d := time.Since(began); d >= time.Second {
+		log.Printf("Started %s in %s", c.name, d.Round(time.Millisecond))
+	}
+	return nil
+}
+
+// stop stops the started components in the reverse order, each within
+// what is left of the deadline of ctx, and returns their errors
+func (l *lifecycle) stop(ctx context.Context) error {
+	l.mu.Lock()
+	started := l.started
+	l.started = nil
+	l.mu.Unlock()
+
+	var errs []error
+	for i := len(started) - 1; i >= 0; i-- {
+		c := started[i]
+		if c.stop != nil {
+			if err := c.stop(ctx); err != nil {
+				errs = append(errs, fmt.Errorf("stop %s: %w", c.name, err))
+			}
+		}
+		l.mu.Lock()
+		if cancel := l.cancels[c.name]; cancel != nil {
+			cancel()
+		}
+		delete(l.cancels, c.name)
+		l.states[c.name] = componentStopped
+		l.mu.Unlock()
+	}
+	return errors.Join(errs...)
+}
+
+// health reports the state of every component in the order they were
+// added, and whether all of them are running and healthy
+func (l *lifecycle) health() ([]ComponentHealth, bool) {
+	l.mu.Lock()
+	list := make([]ComponentHealth, 0, len(l.components))
+	checks := make([]func() error, 0, len(l.components))
+	for _, c := range l.components {
+		list = append(list, ComponentHealth{Name: c.name, State: l.states[c.name]})
+		checks = append(checks, c.health)
+	}
+	l.mu.Unlock()
+
+	// The checks run unlocked, as they may take the locks of their
+	// subsystems
+	healthy := true
+	for i := range list {
+		list[i].Healthy = list[i].State == componentRunning && (checks[i] == nil || checks[i]() == nil)
+		healthy = healthy && list[i].Healthy
+	}
+	return list, healthy
+}
@@ -0,0 +1,139 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recordingLifecycle returns a lifecycle whose components append their
+// starts and stops to events
+func recordingLifecycle(events *[]string, specs ...*component) *lifecycle {
+	l := newLifecycle()
+	for _, c := range specs {
+		name := c.name
+		if c.start == nil {
+			c.start = func(context.Context) error {
+				*events = append(*events, "start "+name)
+				return nil
+			}
+		}
+		c.stop = func(context.Context) error {
+			*events = append(*events, "stop "+name)
+			return nil
+		}
+		l.add(c)
+	}
+	return l
+}
+
+func TestLifecycleOrder(t *testing.T) {
+	var events []string
+	l := recordingLifecycle(&events,
+		&component{name: "fetcher", deps: []string{"store", "cache"}},
+		&component{name: "cache"},
+		&component{name: "store", deps: []string{"cache"}},
+		&component{name: "auth"},
+	)
+	if err := l.start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"start cache", "start store", "start fetcher", "start auth",
+		"stop auth", "stop fetcher", "stop store", "stop cache",
+	}
+	if !slices.Equal(events, want) {
+		t.Errorf("expected %v, got %v", want, events)
+	}
+
+	for _, specs := range [][]*component{
+		{{name: "a", deps: []string{"b"}}, {name: "b", deps: []string{"a"}}},
+		{{name: "a", deps: []string{"missing"}}},
+	} {
+		if err := recordingLifecycle(&events, specs...).start(context.Background()); err == nil {
+			t.Errorf("expected an error for %s depending on %v", specs[0].name, specs[0].deps)
+		}
+	}
+}
+
+func TestLifecycleStartFailure(t *testing.T) {
+	var events []string
+	var jobCtx context.Context
+	l := recordingLifecycle(&events,
+		&component{name: "store"},
+		&component{name: "job", start: func(ctx context.Context) error {
+			jobCtx = ctx
+			return nil
+		}},
+		&component{name: "notifiers", start: func(context.Context) error { return errors.New("bad config") }},
+		&component{name: "late"},
+	)
+	err := l.start(context.Background())
+	if err == nil || err.Error() != "start notifiers: bad config" {
+		t.Fatalf("expected the error of notifiers, got %v", err)
+	}
+	// What started is stopped again, and the jobs are cancelled
+	if want := []string{"start store", "stop job", "stop store"}; !slices.Equal(events, want) {
+		t.Errorf("expected %v, got %v", want, events)
+	}
+	if jobCtx.Err() == nil {
+		t.Error("expected the context of the job to be cancelled")
+	}
+	list, healthy := l.health()
+	if healthy || list[2].State != componentFailed || list[3].State != componentPending {
+		t.Errorf("unexpected health %+v", list)
+	}
+
+	slow := newLifecycle()
+	slow.add(&component{name: "slow", timeout: 10 * time.Millisecond, start: func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	}})
+	if err := slow.start(context.Background()); err == nil || !strings.Contains(err.Error(), "did not start within 10ms") {
+		t.Errorf("expected a timeout, got %v", err)
+	}
+}
+
+func TestReadyzComponents(t *testing.T) {
+	t.Cleanup(func() { readiness = startupReadiness{}; components = nil })
+	readiness = startupReadiness{}
+	readiness.markReady()
+
+	healthErr := errors.New("unreachable")
+	components = newLifecycle()
+	components.add(&component{name: "store", start: func(context.Context) error { return nil }})
+	components.add(&component{name: "userdata", start: func(context.Context) error { return nil }, health: func() error { return healthErr }})
+
+	get := func() (int, Readiness) {
+		rec := httptest.NewRecorder()
+		handleReadyz(rec, httptest.NewRequest("GET", "/readyz", nil))
+		var status Readiness
+		if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+			t.Fatal(err)
+		}
+		return rec.Code, status
+	}
+
+	if code, status := get(); code != http.StatusServiceUnavailable || status.Components[0].State != componentPending {
+		t.Errorf("expected 503 before the components start, got %d %+v", code, status)
+	}
+	if err := components.start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	code, status := get()
+	if code != http.StatusServiceUnavailable || !status.Components[0].Healthy || status.Components[1].Healthy {
+		t.Errorf("expected an unhealthy component to hold readiness, got %d %+v", code, status)
+	}
+	healthErr = nil
+	if code, status := get(); code != http.StatusOK || !status.Ready {
+		t.Errorf("expected 200 once every component is healthy, got %d %+v", code, status)
+	}
+}
@@ -0,0 +1,155 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/jjway/rewatchableGamesApi-go/store"
+)
+
+// WeekLinks are the navigation links of a week response. The previous and
+// next weeks are the adjacent available weeks of the same season, skipping
+// gaps and running on into the playoff rounds, and are omitted at either
+// end of the season.
+type WeekLinks struct {
+	Self     string `json:"self"`
+	PrevWeek string `json:"prevWeek,omitempty"`
+	NextWeek string `json:"nextWeek,omitempty"`
+	Season   string `json:"season"`
+}
+
+// Linked wraps a week response with its links for ?links=true
+type Linked struct {
+	Games any       `json:"games"`
+	Links WeekLinks `json:"links"`
+}
+
+// isLinks reports whether the request asked for ?links=true
+func isLinks(r *http.Request) bool {
+	return r.URL.Query().Get("links") == "true"
+}
+
+// adjacentWeeks returns the closest cached weeks of season before and
+// after week in canonical order, "" when there is none
+func adjacentWeeks(season, week string) (prev, next string) {
+	order, ok := weekOrder(week)
+	if !ok {
+		return "", ""
+	}
+	prevOrder, nextOrder := 0, 0
+
+	for name := range cache.weekCounts() {
+		s, file, _ := strings.Cut(name, "/")
+		if s != season || !store.IsWeekFile(name) {
+			continue
+		}
+		w := strings.TrimSuffix(file, ".json")
+		n, ok := weekOrder(w)
+		if !ok {
+			continue
+		}
+		if n < order && n > prevOrder {
+			prev, prevOrder = w, n
+		}
+		if n > order && (nextOrder == 0 || n < nextOrder) {
+			next, nextOrder = w, n
+		}
+	}
+	return prev, next
+}
+
+// pathPrefix returns the API version or algorithm prefix the request was
+// served under, "" for the unprefixed routes
+func pathPrefix(r *http.Request) string {
+	if prefix := versionedPath(r, ""); prefix != "" {
+		return prefix
+	}
+	if rater, ok := r.Context().Value(raterKey{}).(Rater); ok {
+		return "/" + rater.Version()
+	}
+	return ""
+}
+
+// weekLinks builds the links of the week response to r. They keep the
+// API version or algorithm prefix and the query parameters of the week,
+// so a client paging through weeks keeps its sort and filters.
+func weekLinks(r *http.Request, year, week string) WeekLinks {
+	prefix := pathPrefix(r)
+	query := canonicalQuery(r.URL.Query(), weekQueryParams())
+	if query != "" {
+		query = "?" + query
+	}
+	weekPath := func(w string) string {
+		return prefix + "/games/" + year + "/" + w + query
+	}
+
+	links := WeekLinks{
+		Self:   prefix + "/games/" + year + "/" + week + query,
+		Season: prefix + "/seasons/" + year + "/games",
+	}
+	prev, next := adjacentWeeks(year, week)
+	if prev != "" {
+		links.PrevWeek = weekPath(prev)
+	}
+	if next != "" {
+		links.NextWeek = weekPath(next)
+	}
+	return links
+}
+
+// prefetchHints are the links of a week response also sent with
+// rel="prefetch", so browsers and CDNs warm the likely next requests. They
+// come from PREFETCH_HINTS, a list of next, prev and season, "off" or
+// empty for none.
+var prefetchHints = []string{"next", "season"}
+
+// parsePrefetchHints parses a PREFETCH_HINTS value
+func parsePrefetchHints(list []string) ([]string, error) {
+	if len(list) == 1 && (list[0] == "off" || list[0] == "none") {
+		return nil, nil
+	}
+	var hints []string
+	for _, hint := range list {
+		switch hint {
+		case "next", "prev", "season":
+		default:
+			return nil, fmt.Errorf("invalid PREFETCH_HINTS %q: %q must be next, prev or season", strings.Join(list, ","), hint)
+		}
+		if !slices.Contains(hints, hint) {
+			hints = append(hints, hint)
+		}
+	}
+	return hints, nil
+}
+
+// setLinkHeader sends the links as an RFC 8288 Link header, for clients
+// that keep the plain array response, followed by the prefetch hints.
+// Crawlers do not prefetch and get the navigation links only.
+func setLinkHeader(w http.ResponseWriter, r *http.Request, links WeekLinks) {
+	parts := []string{"<" + links.Season + `>; rel="up"`}
+	if links.PrevWeek != "" {
+		parts = append(parts, "<"+links.PrevWeek+`>; rel="prev"`)
+	}
+	if links.NextWeek != "" {
+		parts = append(parts, "<"+links.NextWeek+`>; rel="next"`)
+	}
+	if !isCrawler(r) {
+		targets := map[string]string{"next": links.NextWeek, "prev": links.PrevWeek, "season": links.Season}
+		for _, hint := range prefetchHints {
+			// A week at either end of the season has nothing to prefetch
+			if target := targets[hint]; target != "" {
+				parts = append(parts, "<"+target+`>; rel="prefetch"`)
+			}
+		}
+	}
+	w.Header().Add("Link", strings.Join(parts, ", "))
+}
+
+// invalidateSeasonResponses drops the cached week responses of the season
+// of the week file name, whose links change when a week appears or goes
+func invalidateSeasonResponses(name string) {
+	season, _, _ := strings.Cut(name, "/")
+	responses.invalidatePrefix(season + "/")
+}
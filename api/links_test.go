@@ -0,0 +1,119 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestWeekLinks(t *testing.T) {
+	dir := setupTestData(t)
+	useTestStore(t, dir)
+	// Week 3 is missing, so week 2 links to week 4
+	if err := os.WriteFile(filepath.Join(dir, "2024", "4.json"), []byte(testData), 0644); err != nil {
+		t.Fatal(err)
+	}
+	preloadCache(dataStore)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /games/{year}/{week}", handleGamesYearWeek)
+	mux.Handle("GET /v2/", withRater(raters["v2"], mux))
+
+	get := func(url string) (*httptest.ResponseRecorder, Linked) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest("GET", url, nil))
+		var body Linked
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("%s: failed to parse response: %v", url, err)
+		}
+		return rec, body
+	}
+
+	_, body := get("/games/2024/2?links=true")
+	want := WeekLinks{
+		Self:     "/games/2024/2?links=true",
+		PrevWeek: "/games/2024/1?links=true",
+		NextWeek: "/games/2024/4?links=true",
+		Season:   "/seasons/2024/games",
+	}
+	if body.Links != want {
+		t.Errorf("expected %+v, got %+v", want, body.Links)
+	}
+
+	rec, body := get("/v2/games/2024/1?links=true")
+	if body.Links.PrevWeek != "" || body.Links.NextWeek != "/v2/games/2024/2?links=true" {
+		t.Errorf("unexpected links at the start of the season %+v", body.Links)
+	}
+	if link := rec.Header().Get("Link"); link != `</v2/seasons/2024/games>; rel="up", </v2/games/2024/2?links=true>; rel="next", </v2/games/2024/2?links=true>; rel="prefetch", </v2/seasons/2024/games>; rel="prefetch"` {
+		t.Errorf("unexpected Link header %q", link)
+	}
+
+	// A new week updates the links of the cached responses of its season
+	if _, body = get("/games/2024/4?links=true"); body.Links.NextWeek != "" {
+		t.Errorf("expected no next week, got %+v", body.Links)
+	}
+	setCached("2024/5.json", nil, 0, "")
+	if _, body = get("/games/2024/4?links=true"); body.Links.NextWeek != "/games/2024/5?links=true" {
+		t.Errorf("expected the new week in the links, got %+v", body.Links)
+	}
+
+	// Plain responses stay arrays and carry the links in the header
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/games/2024/4", nil))
+	var games []ProcessedGameStats
+	if err := json.Unmarshal(rec.Body.Bytes(), &games); err != nil {
+		t.Fatalf("expected an array without ?links=true: %v", err)
+	}
+	if link := rec.Header().Get("Link"); link != `</seasons/2024/games>; rel="up", </games/2024/2>; rel="prev", </games/2024/5>; rel="next", </games/2024/5>; rel="prefetch", </seasons/2024/games>; rel="prefetch"` {
+		t.Errorf("unexpected Link header %q", link)
+	}
+}
+
+func TestPrefetchHints(t *testing.T) {
+	dir := setupTestData(t)
+	useTestStore(t, dir)
+	preloadCache(dataStore)
+	old := prefetchHints
+	t.Cleanup(func() { prefetchHints = old })
+
+	link := func(userAgent string) string {
+		t.Helper()
+		req := httptest.NewRequest("GET", "/games/2024/2", nil)
+		req.SetPathValue("year", "2024")
+		req.SetPathValue("week", "2")
+		req.Header.Set("User-Agent", userAgent)
+		rec := httptest.NewRecorder()
+		handleGamesYearWeek(rec, req)
+		return rec.Header().Get("Link")
+	}
+
+	// Week 2 is the last week of the test data: no next week to prefetch
+	if got := link("test"); got != `</seasons/2024/games>; rel="up", </games/2024/1>; rel="prev", </seasons/2024/games>; rel="prefetch"` {
+		t.Errorf("unexpected Link header %q", got)
+	}
+	prefetchHints = []string{"prev"}
+	if got := link("test"); got != `</seasons/2024/games>; rel="up", </games/2024/1>; rel="prev", </games/2024/1>; rel="prefetch"` {
+		t.Errorf("unexpected Link header %q", got)
+	}
+	if got := link("Googlebot/2.1"); got != `</seasons/2024/games>; rel="up", </games/2024/1>; rel="prev"` {
+		t.Errorf("expected no prefetch hints for crawlers, got %q", got)
+	}
+
+	for v, want := range map[string][]string{
+		"off":                 nil,
+		"season, next,season": {"season", "next"},
+		"prev":                {"prev"},
+	} {
+		list := configOf(t, map[string]string{"PREFETCH_HINTS": v}).Server.PrefetchHints
+		if got, err := parsePrefetchHints(list); err != nil || !slices.Equal(got, want) {
+			t.Errorf("%q: expected %v, got %v, %v", v, want, got, err)
+		}
+	}
+	if _, err := parsePrefetchHints([]string{"next", "summary"}); err == nil {
+		t.Error("expected an error for an unknown hint")
+	}
+}
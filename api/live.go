@@ -0,0 +1,189 @@
+package api
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+)
+
+// espnInProgress is the ESPN status state of a game being played
+const espnInProgress = "in"
+
+// liveTracker polls the current scoreboard and keeps the stats of the
+// games in progress so far. Their ratings are provisional: the rating of
+// a finished game comes from the week file once the fetcher stores it.
+// Live mode is experimental and only runs when LIVE_INTERVAL is set.
+type liveTracker struct {
+	fetcher *espnFetcher
+
+	mu        sync.RWMutex
+	season    string
+	week      string
+	games     []liveGame
+	updatedAt time.Time
+}
+
+// liveGame is the partial stats of a game in progress at its last poll
+type liveGame struct {
+	stats     GameStats
+	period    int
+	clock     string
+	updatedAt time.Time
+}
+
+// live is set at startup when LIVE_INTERVAL is configured
+var live *liveTracker
+
+func newLiveTracker(f *espnFetcher) *liveTracker {
+	return &liveTracker{fetcher: f}
+}
+
+// poll reads the games in progress from the scoreboard. A game whose
+// summary cannot be read keeps the stats of the previous poll, and games
+// that finished drop out.
+func (t *liveTracker) poll(ctx context.Context) error {
+	var board espnScoreboard
+	if err := t.fetcher.getJSON(ctx, "/scoreboard", nil, &board); err != nil {
+		return err
+	}
+	season, week, ok := board.seasonWeek()
+	if !ok {
+		t.set(season, week, nil)
+		return nil
+	}
+	order, _ := weekOrder(week)
+
+	t.mu.RLock()
+	previous := make(map[string]liveGame, len(t.games))
+	for _, g := range t.games {
+		previous[g.stats.ID] = g
+	}
+	t.mu.RUnlock()
+
+	var games []liveGame
+	for _, ev := range board.Events {
+		if ev.Status.Type.State != espnInProgress {
+			continue
+		}
+		var summary espnSummary
+		if err := t.fetcher.getJSON(ctx, "/summary", url.Values{"event": {ev.ID}}, &summary); err != nil {
+			log.Printf("Warning: live summary of event %s failed: %v", ev.ID, err)
+			if g, ok := previous[ev.ID]; ok {
+				games = append(games, g)
+			}
+			continue
+		}
+		games = append(games, liveGame{
+			stats:     espnGameStats(order, ev, summary),
+			period:    ev.Status.Period,
+			clock:     ev.Status.DisplayClock,
+			updatedAt: clock.Now(),
+		})
+	}
+	t.set(season, week, games)
+	return nil
+}
+
+func (t *liveTracker) set(season, week string, games []liveGame) {
+	t.mu.Lock()
+	t.season, t.week, t.games, t.updatedAt = season, week, games, clock.Now()
+	t.mu.Unlock()
+}
+
+// LiveGame is the provisional rating of a game in progress. Like the
+// spoiler-free games it leaves out the score, so a viewer can decide
+// whether to start a delayed watch.
+type LiveGame struct {
+	SpoilerFreeGame
+	Period      int       `json:"period"`
+	Clock       string    `json:"clock"`
+	Provisional bool      `json:"provisional"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// LiveBoard is the response of GET /live
+type LiveBoard struct {
+	Season      string     `json:"season,omitempty"`
+	Week        string     `json:"week,omitempty"`
+	WeekLabel   string     `json:"weekLabel,omitempty"`
+	Provisional bool       `json:"provisional"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+	Games       []LiveGame `json:"games"`
+}
+
+// board rates the games in progress with rater, the most exciting first
+func (t *liveTracker) board(rater Rater) LiveBoard {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	b := LiveBoard{Season: t.season, Week: t.week, Provisional: true, UpdatedAt: t.updatedAt, Games: []LiveGame{}}
+	if t.week != "" {
+		b.WeekLabel = weekLabel(t.week)
+	}
+	for _, g := range t.games {
+		p := processGame(rater, g.stats)
+		p.setLocation(t.season, t.week)
+		b.Games = append(b.Games, LiveGame{
+			SpoilerFreeGame: spoilerFreeGames([]ProcessedGameStats{p})[0],
+			Period:          g.period,
+			Clock:           g.clock,
+			Provisional:     true,
+			UpdatedAt:       g.updatedAt,
+		})
+	}
+	sort.SliceStable(b.Games, func(i, j int) bool { return b.Games[i].TotalRating > b.Games[j].TotalRating })
+	return b
+}
+
+// runLive polls the games in progress every interval until ctx is done
+func runLive(ctx context.Context, t *liveTracker, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := t.poll(ctx); err != nil {
+			log.Printf("Warning: live poll failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// startLive starts live mode when LIVE_INTERVAL is set
+func startLive(ctx context.Context, cfg Config) error {
+	interval := cfg.Ingest.LiveInterval
+	if interval == 0 {
+		return nil
+	}
+	if err := checkInterval("LIVE_INTERVAL", interval, minLiveInterval); err != nil {
+		return err
+	}
+	live = newLiveTracker(newESPNFetcher(cfg.Ingest.ESPNAPIURL))
+	go runLive(ctx, live, interval)
+	log.Printf("Rating games in progress every %s (experimental)", interval)
+	return nil
+}
+
+// handleLive serves the provisional ratings of the games in progress
+func handleLive(w http.ResponseWriter, r *http.Request) {
+	t := live
+	if t == nil {
+		writeError(w, r, http.StatusNotFound, "live mode is not enabled")
+		return
+	}
+	rater, qerr := raterFor(r)
+	if qerr != nil {
+		writeQueryError(w, r, qerr)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	setCacheHeaders(w, "live")
+	if err := json.NewEncoder(w).Encode(t.board(rater)); err != nil {
+		writeError(w, r, http.StatusInternalServerError, "error encoding response")
+	}
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testLiveScoreboard = `{
+	"season": {"year": 2024, "type": 2},
+	"week": {"number": 5},
+	"events": [
+		{"id": "401", "name": "Buffalo Bills at Kansas City Chiefs", "shortName": "BUF @ KC",
+		 "status": {"period": 3, "displayClock": "4:12", "type": {"state": "in"}},
+		 "competitions": [{"competitors": [
+			{"homeAway": "home", "score": "27"},
+			{"homeAway": "away", "score": "24"}]}]},
+		{"id": "402", "name": "Detroit Lions at Green Bay Packers", "shortName": "DET @ GB",
+		 "status": {"type": {"state": "pre"}}},
+		{"id": "403", "name": "Miami Dolphins at New York Jets", "shortName": "MIA @ NYJ",
+		 "status": {"type": {"state": "post", "completed": true}}}
+	]
+}`
+
+func TestLive(t *testing.T) {
+	useFakeClock(t)
+	summaries := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/scoreboard":
+			w.Write([]byte(testLiveScoreboard))
+		case "/summary":
+			summaries++
+			if summaries > 1 {
+				http.Error(w, "unavailable", http.StatusServiceUnavailable)
+				return
+			}
+			w.Write([]byte(testSummary))
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	get := func() (*httptest.ResponseRecorder, LiveBoard) {
+		rec := httptest.NewRecorder()
+		handleLive(rec, httptest.NewRequest("GET", "/live", nil))
+		var b LiveBoard
+		if rec.Code == http.StatusOK {
+			if err := json.Unmarshal(rec.Body.Bytes(), &b); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return rec, b
+	}
+
+	old := live
+	t.Cleanup(func() { live = old })
+	live = nil
+	if rec, _ := get(); rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 with live mode off, got %d", rec.Code)
+	}
+
+	live = newLiveTracker(newESPNFetcher(srv.URL))
+	if err := live.poll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	rec, b := get()
+	if cc := rec.Header().Get("Cache-Control"); cc != cachePolicies["live"].header() {
+		t.Errorf("unexpected Cache-Control %s", cc)
+	}
+	if !b.Provisional || b.Season != "2024" || b.Week != "5" || len(b.Games) != 1 {
+		t.Fatalf("expected the game in progress alone, got %+v", b)
+	}
+	g := b.Games[0]
+	if g.ID != "401" || !g.Provisional || g.Period != 3 || g.Clock != "4:12" || g.Slug != "2024-w5-buf-kc" || g.TotalRating == 0 {
+		t.Errorf("unexpected game %+v", g)
+	}
+	if strings.Contains(rec.Body.String(), "totalPoints") {
+		t.Errorf("expected no score in %s", rec.Body.String())
+	}
+
+	// A failed summary keeps the last provisional rating
+	if err := live.poll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, b := get(); len(b.Games) != 1 || b.Games[0].TotalRating != g.TotalRating {
+		t.Errorf("expected the previous rating to be kept, got %+v", b.Games)
+	}
+}
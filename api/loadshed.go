@@ -0,0 +1,231 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Load shedding priorities. A request is admitted while the requests in
+// flight stay under its priority's share of MaxInFlight, so the lower
+// priorities are turned away first as the server fills up.
+const (
+	priorityCritical = "critical"
+	priorityHigh     = "high"
+	priorityNormal   = "normal"
+	priorityLow      = "low"
+)
+
+// LoadSheddingConfig is the LOAD_SHEDDING_CONFIG file. Classes and
+// Thresholds override the defaults entry by entry.
+type LoadSheddingConfig struct {
+	// MaxInFlight is the number of concurrent requests the server takes
+	// on; zero disables load shedding
+	MaxInFlight int `json:"maxInFlight"`
+
+	// Classes maps a route class to its priority
+	Classes map[string]string `json:"classes"`
+
+	// Thresholds maps a priority to the share of MaxInFlight, between 0
+	// and 1, up to which it is admitted
+	Thresholds map[string]float64 `json:"thresholds"`
+}
+
+// routeClasses groups the routes, by mux pattern, into classes of similar
+// cost. Unlisted routes, such as those of the extensions, are "default".
+var routeClasses = map[string]string{
+	"GET /games/{year}/{week}":                  "week",
+	"GET /games/{year}/{week}/status":           "week",
+	"GET /games/{year}/{week}/{id}":             "week",
+	"GET /games/{year}/{week}/{id}/rating":      "week",
+	"GET /games/{year}":                         "week",
+	"GET /g/{slug}":                             "week",
+	"GET /games/{year}/{week}/wait":             "longpoll",
+	"GET /events":                               "longpoll",
+	"GET /games/top":                            "list",
+	"GET /games/{year}/top":                     "list",
+	"GET /seasons":                              "list",
+	"GET /{$}":                                  "list",
+	"GET /teams":                                "list",
+	"GET /teams/{team}/games":                   "list",
+	"GET /teams/{team}/summary/{year}":          "list",
+	"GET /matchups/{teamA}/{teamB}":             "list",
+	"GET /search":                               "list",
+	"GET /leagues":                              "list",
+	"GET /leagues/{league}/games/{year}/{week}": "week",
+	"GET /meta/fields":                          "list",
+	"GET /meta/query-syntax":                    "list",
+	"GET /meta/idmap/{id}":                      "list",
+	"GET /openapi.json":                         "list",
+	"GET /docs":                                 "list",
+	"GET /robots.txt":                           "list",
+	"GET /readyz":                               "probe",
+	"GET /feed.rss":                             "week",
+	"GET /calendar.ics":                         "week",
+	"GET /feed.atom":                            "week",
+	"GET /corrections.json":                     "list",
+	"GET /corrections.rss":                      "list",
+	"GET /live":                                 "list",
+	"POST /plan":                                "analytics",
+	"GET /votes/{year}":                         "list",
+	"POST /votes/{year}":                        "list",
+	"POST /games/{id}/votes":                    "list",
+	"GET /games":                                "analytics",
+	"GET /seasons/{year}/games":                 "analytics",
+	"GET /graphql":                              "analytics",
+	"POST /graphql":                             "analytics",
+	"GET /bulk/{year}":                          "export",
+	"POST /games/{year}/{week}":                 "admin",
+	"GET /admin/consistency":                    "admin",
+	"GET /admin/validate":                       "admin",
+	"GET /admin/backfill":                       "admin",
+	"POST /admin/backfill":                      "admin",
+	"GET /admin/cache":                          "admin",
+	"POST /admin/cache/purge":                   "admin",
+	"GET /admin/logs":                           "admin",
+	"GET /admin/vars":                           "admin",
+	"GET /admin/webhooks":                       "admin",
+	"POST /admin/webhooks":                      "admin",
+	"DELETE /admin/webhooks/{id}":               "admin",
+	"GET /admin/digest/preview":                 "admin",
+	"POST /admin/refresh":                       "admin",
+	"POST /admin/votes/{year}":                  "admin",
+	"POST /admin/votes/{year}/close":            "admin",
+}
+
+// defaultLoadShedding keeps the cached week lookups, the admin routes and
+// the readiness probe serving until the server is full, and sheds the season scans and
+// exports once it is half full
+var defaultLoadShedding = LoadSheddingConfig{
+	Classes: map[string]string{
+		"week":      priorityCritical,
+		"admin":     priorityCritical,
+		"probe":     priorityCritical,
+		"list":      priorityNormal,
+		"longpoll":  priorityNormal,
+		"default":   priorityNormal,
+		"analytics": priorityLow,
+		"export":    priorityLow,
+	},
+	Thresholds: map[string]float64{
+		priorityCritical: 1,
+		priorityHigh:     0.9,
+		priorityNormal:   0.75,
+		priorityLow:      0.5,
+	},
+}
+
+// loadShedder counts the requests in flight against a LoadSheddingConfig
+type loadShedder struct {
+	mu       sync.Mutex
+	cfg      LoadSheddingConfig
+	inFlight int
+}
+
+func newLoadShedder(cfg LoadSheddingConfig) *loadShedder {
+	return &loadShedder{cfg: cfg}
+}
+
+// acquire admits a request of class, reporting false when it is shed
+func (s *loadShedder) acquire(class string) bool {
+	priority, ok := s.cfg.Classes[class]
+	if !ok {
+		priority = s.cfg.Classes["default"]
+	}
+	limit := s.cfg.Thresholds[priority] * float64(s.cfg.MaxInFlight)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if float64(s.inFlight) >= limit {
+		return false
+	}
+	s.inFlight++
+	return true
+}
+
+func (s *loadShedder) release() {
+	s.mu.Lock()
+	s.inFlight--
+	s.mu.Unlock()
+}
+
+// loadLoadSheddingConfig reads a LoadSheddingConfig from path over the
+// defaults
+func loadLoadSheddingConfig(path string) (LoadSheddingConfig, error) {
+	cfg := LoadSheddingConfig{
+		Classes:    make(map[string]string),
+		Thresholds: make(map[string]float64),
+	}
+	for class, p := range defaultLoadShedding.Classes {
+		cfg.Classes[class] = p
+	}
+	for p, t := range defaultLoadShedding.Thresholds {
+		cfg.Thresholds[p] = t
+	}
+
+	data, err := readConfigFile(path)
+	if err != nil {
+		return cfg, err
+	}
+	var overrides LoadSheddingConfig
+	if err := json.Unmarshal(data, &overrides); err != nil {
+		return cfg, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if overrides.MaxInFlight < 0 {
+		return cfg, fmt.Errorf("maxInFlight must not be negative")
+	}
+	cfg.MaxInFlight = overrides.MaxInFlight
+	for p, t := range overrides.Thresholds {
+		if _, ok := cfg.Thresholds[p]; !ok {
+			return cfg, fmt.Errorf("unknown load shedding priority %q", p)
+		}
+		if t <= 0 || t > 1 {
+			return cfg, fmt.Errorf("threshold of %s must be in (0, 1]", p)
+		}
+		cfg.Thresholds[p] = t
+	}
+	for class, p := range overrides.Classes {
+		if _, ok := cfg.Classes[class]; !ok {
+			return cfg, fmt.Errorf("unknown route class %q", class)
+		}
+		if _, ok := cfg.Thresholds[p]; !ok {
+			return cfg, fmt.Errorf("route class %s: unknown priority %q", class, p)
+		}
+		cfg.Classes[class] = p
+	}
+	return cfg, nil
+}
+
+// routeClass returns the class of the route mux would serve r with,
+// looking through the /v1/... API version and /v2/... algorithm prefixes
+func routeClass(mux *http.ServeMux, r *http.Request) string {
+	probe := r
+	for _, version := range append([]string{currentAPIVersion}, algorithmNames()...) {
+		if rest, ok := strings.CutPrefix(r.URL.Path, "/"+version+"/"); ok {
+			probe = r.Clone(r.Context())
+			probe.URL.Path = "/" + rest
+			break
+		}
+	}
+	_, pattern := mux.Handler(probe)
+	if class, ok := routeClasses[pattern]; ok {
+		return class
+	}
+	return "default"
+}
+
+// loadSheddingMiddleware turns requests away with 503 once the server is
+// too busy for their route class, cheapest classes last
+func loadSheddingMiddleware(s *loadShedder, mux *http.ServeMux, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		class := routeClass(mux, r)
+		if !s.acquire(class) {
+			w.Header().Set("Retry-After", "1")
+			writeError(w, r, http.StatusServiceUnavailable, "server is overloaded, "+class+" requests are shed; retry shortly")
+			return
+		}
+		defer s.release()
+		next.ServeHTTP(w, r)
+	})
+}
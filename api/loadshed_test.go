@@ -0,0 +1,119 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestLoadShedderPriorities(t *testing.T) {
+	cfg := defaultLoadShedding
+	cfg.MaxInFlight = 4
+	s := newLoadShedder(cfg)
+
+	// Half full: exports are shed, week lookups still admitted
+	s.acquire("week")
+	s.acquire("week")
+	if s.acquire("export") {
+		t.Error("expected an export to be shed at half capacity")
+	}
+	if !s.acquire("list") {
+		t.Error("expected a list request to be admitted below 75%")
+	}
+	if s.acquire("extension-route") {
+		t.Error("expected an unknown class to use the default priority")
+	}
+	if !s.acquire("week") {
+		t.Error("expected a week request to be admitted below capacity")
+	}
+	if s.acquire("week") {
+		t.Error("expected every request to be shed at capacity")
+	}
+
+	s.release()
+	s.release()
+	if !s.acquire("list") {
+		t.Error("expected capacity to be released")
+	}
+}
+
+func TestLoadSheddingMiddleware(t *testing.T) {
+	block := make(chan struct{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /games/{year}/{week}", func(w http.ResponseWriter, r *http.Request) { <-block })
+	mux.HandleFunc("GET /bulk/{year}", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("GET /seasons", func(w http.ResponseWriter, r *http.Request) {})
+
+	cfg := defaultLoadShedding
+	cfg.MaxInFlight = 2
+	s := newLoadShedder(cfg)
+	handler := loadSheddingMiddleware(s, mux, mux)
+
+	done := make(chan struct{})
+	go func() {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/games/2024/1", nil))
+		close(done)
+	}()
+	for {
+		s.mu.Lock()
+		n := s.inFlight
+		s.mu.Unlock()
+		if n == 1 {
+			break
+		}
+		runtime.Gosched()
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/v2/bulk/2024", nil))
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("expected a versioned export to be shed with Retry-After, got %d", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/seasons", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected a list request to be served, got %d", rec.Code)
+	}
+
+	close(block)
+	<-done
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/bulk/2024", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected exports to be served once idle, got %d", rec.Code)
+	}
+}
+
+func TestLoadLoadSheddingConfig(t *testing.T) {
+	write := func(body string) string {
+		path := filepath.Join(t.TempDir(), "shed.json")
+		os.WriteFile(path, []byte(body), 0644)
+		return path
+	}
+
+	cfg, err := loadLoadSheddingConfig(write(`{"maxInFlight": 100, "classes": {"analytics": "normal"}, "thresholds": {"low": 0.3}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.MaxInFlight != 100 || cfg.Classes["analytics"] != priorityNormal || cfg.Classes["export"] != priorityLow || cfg.Thresholds[priorityLow] != 0.3 {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+	if defaultLoadShedding.Classes["analytics"] != priorityLow {
+		t.Error("expected the defaults to be left untouched")
+	}
+
+	for _, body := range []string{
+		`{"maxInFlight": -1}`,
+		`{"classes": {"graphql": "low"}}`,
+		`{"classes": {"export": "urgent"}}`,
+		`{"thresholds": {"low": 1.5}}`,
+		`{"thresholds": {"urgent": 0.5}}`,
+	} {
+		if _, err := loadLoadSheddingConfig(write(body)); err == nil {
+			t.Errorf("%s: expected an error", body)
+		}
+	}
+}
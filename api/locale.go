@@ -0,0 +1,221 @@
+package api
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// defaultLang is the language of the messages missing from a catalog and
+// of requests without a supported preference
+const defaultLang = "en"
+
+// messages is the catalog of the text meant for people, by language then
+// message key: the feeds, the HTML views and the CLI output. JSON
+// responses stay in English so clients can rely on them. The values are
+// fmt formats.
+var messages = map[string]map[string]string{
+	"en": {
+		"week":                    "Week %s",
+		"round.wildcard":          "Wild Card",
+		"round.divisional":        "Divisional Round",
+		"round.conference":        "Conference Championships",
+		"round.superbowl":         "Super Bowl",
+		"quality.high":            "high",
+		"quality.medium":          "medium",
+		"quality.low":             "low",
+		"docs.title":              "Rewatchable Games API",
+		"feed.title":              "Most rewatchable games",
+		"feed.title.week":         "Most rewatchable games of %s, %s",
+		"feed.description":        "The best games of the most recent NFL week, rated for rewatchability without spoilers",
+		"feed.item":               "%s, %s of %s. Rewatchability %.1f, matchup quality %s.",
+		"calendar.name":           "Rewatchable games",
+		"calendar.description":    "The best games of the recent NFL weeks to rewatch, without spoilers",
+		"calendar.event":          "Rewatch %s (%.1f)",
+		"calendar.watch":          "Watch: %s",
+		"corrections.title":       "Rewatchable games data corrections",
+		"corrections.description": "The games whose stats or ratings changed after their week was first published",
+		"corrections.item.title":  "Corrections to %s, %s",
+		"corrections.item":        "%d games corrected: %s",
+		"cli.lang":                "output language, one of %s",
+		"cli.compact.usage":       "usage: compact [-data dir] [-force] [-keep] [-dry-run] [-lang lang] year...",
+		"cli.compact.done":        "Compacted season %s: %d weeks",
+		"cli.backfill.usage":      "usage: backfill [-data dir] [-provider espn|url] [-force] [-dry-run] [-lang lang] year...",
+		"cli.backfill.done":       "Backfilled season %s from %s: %d games in %d weeks, %d skipped, %d failed",
+		"cli.bundle.done":         "Wrote %s",
+		"cli.perfcheck.ok":        "All %d scenarios within the baseline",
+		"cli.perfcheck.regressed": "%d of %d scenarios regressed",
+		"cli.perfcheck.updated":   "Wrote the baseline of %d scenarios to %s",
+		"cli.usage":               "unknown command %q: must be one of %s",
+		"cli.validate.usage":      "usage: validate [-format text|json] [-lang lang] dir",
+		"cli.validate.ok":         "%d files of %d seasons are valid",
+		"cli.validate.failed":     "%d problems in %d files",
+		"cli.rate.usage":          "usage: rate [-algo version] [-format json|csv] [-sort field] [-lang lang] file",
+		"cli.ingest.usage":        "usage: ingest [-data dir] [-year year -week week] [-dry-run] [-lang lang]",
+		"cli.ingest.done":         "Stored week %s of %s: %d of %d games final",
+		"cli.ingest.skipped":      "Nothing stored for week %s of %s: %d of %d games final",
+	},
+	"fr": {
+		"week":                    "Semaine %s",
+		"round.wildcard":          "Tour de wild card",
+		"round.divisional":        "Tour de division",
+		"round.conference":        "Finales de conférence",
+		"round.superbowl":         "Super Bowl",
+		"quality.high":            "élevée",
+		"quality.medium":          "moyenne",
+		"quality.low":             "faible",
+		"docs.title":              "API Rewatchable Games",
+		"feed.title":              "Les matchs les plus à revoir",
+		"feed.title.week":         "Les matchs les plus à revoir : %s, %s",
+		"feed.description":        "Les meilleurs matchs de la dernière semaine de NFL, notés selon leur intérêt à être revus, sans spoiler",
+		"feed.item":               "%s, %s de la saison %s. Intérêt %.1f, qualité de l'affiche %s.",
+		"calendar.name":           "Matchs à revoir",
+		"calendar.description":    "Les meilleurs matchs des dernières semaines de NFL à revoir, sans spoiler",
+		"calendar.event":          "À revoir : %s (%.1f)",
+		"calendar.watch":          "Regarder : %s",
+		"corrections.title":       "Corrections des données des matchs à revoir",
+		"corrections.description": "Les matchs dont les statistiques ou la note ont changé après la première publication de leur semaine",
+		"corrections.item.title":  "Corrections : %s, %s",
+		"corrections.item":        "%d matchs corrigés : %s",
+		"cli.lang":                "langue de sortie, parmi %s",
+		"cli.compact.usage":       "usage : compact [-data dossier] [-force] [-keep] [-dry-run] [-lang langue] année...",
+		"cli.compact.done":        "Saison %s compactée : %d semaines",
+		"cli.backfill.usage":      "usage : backfill [-data dossier] [-provider espn|url] [-force] [-dry-run] [-lang langue] année...",
+		"cli.backfill.done":       "Saison %s complétée depuis %s : %d matchs sur %d semaines, %d ignorés, %d en échec",
+		"cli.bundle.done":         "%s écrit",
+		"cli.perfcheck.ok":        "Les %d scénarios restent dans la référence",
+		"cli.perfcheck.regressed": "%d scénarios sur %d en régression",
+		"cli.perfcheck.updated":   "Référence de %d scénarios écrite dans %s",
+		"cli.usage":               "commande %q inconnue : doit être parmi %s",
+		"cli.validate.usage":      "usage : validate [-format text|json] [-lang langue] dossier",
+		"cli.validate.ok":         "%d fichiers de %d saisons sont valides",
+		"cli.validate.failed":     "%d problèmes dans %d fichiers",
+		"cli.rate.usage":          "usage : rate [-algo version] [-format json|csv] [-sort champ] [-lang langue] fichier",
+		"cli.ingest.usage":        "usage : ingest [-data dossier] [-year année -week semaine] [-dry-run] [-lang langue]",
+		"cli.ingest.done":         "Semaine %s de %s enregistrée : %d matchs terminés sur %d",
+		"cli.ingest.skipped":      "Rien d'enregistré pour la semaine %s de %s : %d matchs terminés sur %d",
+	},
+}
+
+// languages returns the languages of the catalog, sorted
+func languages() []string {
+	langs := make([]string, 0, len(messages))
+	for lang := range messages {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// translate formats the message key in lang, falling back to the default
+// language and then to the key itself
+func translate(lang, key string, args ...any) string {
+	format, ok := messages[lang][key]
+	if !ok {
+		if format, ok = messages[defaultLang][key]; !ok {
+			format = key
+		}
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// localWeekLabel is weekLabel in lang
+func localWeekLabel(lang, week string) string {
+	if isPostseason(week) {
+		return translate(lang, "round."+week)
+	}
+	return translate(lang, "week", week)
+}
+
+// localQuality translates a matchup quality, leaving unknown ones as they
+// are
+func localQuality(lang, quality string) string {
+	if _, ok := messages[defaultLang]["quality."+quality]; !ok {
+		return quality
+	}
+	return translate(lang, "quality."+quality)
+}
+
+// matchLang returns the supported language of a tag such as "fr-CA" or
+// "fr_FR.UTF-8", ok false when there is none
+func matchLang(tag string) (string, bool) {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if i := strings.IndexAny(tag, "-_.@"); i >= 0 {
+		tag = tag[:i]
+	}
+	_, ok := messages[tag]
+	return tag, ok
+}
+
+// negotiateLang picks the language of an Accept-Language header, the
+// supported one of highest quality, or the default language
+func negotiateLang(header string) string {
+	best, bestQ := defaultLang, 0.0
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			var err error
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if lang, ok := matchLang(tag); ok && q > bestQ {
+			best, bestQ = lang, q
+		}
+	}
+	return best
+}
+
+// requestLang returns the language of a localized view: ?lang= when set,
+// for clients such as feed readers that cannot send headers, else the
+// Accept-Language header. It marks the response as varying with the
+// header and sets its Content-Language.
+func requestLang(w http.ResponseWriter, r *http.Request) (string, *QueryError) {
+	lang := negotiateLang(r.Header.Get("Accept-Language"))
+	if v := r.URL.Query().Get("lang"); v != "" {
+		var ok bool
+		if lang, ok = matchLang(v); !ok {
+			return "", &QueryError{Param: "lang", Value: v, Message: "must be one of " + strings.Join(languages(), ", ")}
+		}
+	}
+	w.Header().Add("Vary", "Accept-Language")
+	w.Header().Set("Content-Language", lang)
+	return lang, nil
+}
+
+// envLang returns the language of the LC_ALL, LC_MESSAGES or LANG locale,
+// the first set, or the default language
+func envLang() string {
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if v := os.Getenv(name); v != "" {
+			if lang, ok := matchLang(v); ok {
+				return lang
+			}
+			break
+		}
+	}
+	return defaultLang
+}
+
+// langFlag adds the -lang flag of the CLI commands to fset, defaulting to
+// the locale of the environment
+func langFlag(fset *flag.FlagSet) *string {
+	return fset.String("lang", envLang(), translate(envLang(), "cli.lang", strings.Join(languages(), ", ")))
+}
+
+// checkLang validates the -lang flag
+func checkLang(lang string) (string, error) {
+	matched, ok := matchLang(lang)
+	if !ok {
+		return "", fmt.Errorf("unsupported language %q: must be one of %s", lang, strings.Join(languages(), ", "))
+	}
+	return matched, nil
+}
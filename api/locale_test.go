@@ -0,0 +1,90 @@
+package api
+
+import (
+	"flag"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNegotiateLang(t *testing.T) {
+	for _, tt := range []struct {
+		header, want string
+	}{
+		{"", "en"},
+		{"fr-CA,fr;q=0.9,en;q=0.8", "fr"},
+		{"de-DE,en;q=0.5,fr;q=0.7", "fr"},
+		{"en-US,fr;q=0", "en"},
+		{"de, es;q=0.8", "en"},
+		{"FR", "fr"},
+		{"fr;q=oops,en;q=0.1", "en"},
+	} {
+		if got := negotiateLang(tt.header); got != tt.want {
+			t.Errorf("negotiateLang(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestTranslate(t *testing.T) {
+	if got := translate("fr", "week", "3"); got != "Semaine 3" {
+		t.Errorf("unexpected translation %q", got)
+	}
+	if got := localWeekLabel("fr", "conference"); got != "Finales de conférence" {
+		t.Errorf("unexpected round %q", got)
+	}
+	if got := localQuality("fr", "epic"); got != "epic" {
+		t.Errorf("expected an unknown quality unchanged, got %q", got)
+	}
+
+	// Every message of the default language is in the other catalogs
+	for lang, catalog := range messages {
+		for key := range messages[defaultLang] {
+			if _, ok := catalog[key]; !ok {
+				t.Errorf("%s: missing %s", lang, key)
+			}
+		}
+	}
+}
+
+func TestRequestLang(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/docs?lang=fr-FR", nil)
+	req.Header.Set("Accept-Language", "en")
+	if lang, qerr := requestLang(rec, req); qerr != nil || lang != "fr" {
+		t.Fatalf("expected ?lang= to take precedence, got %q %v", lang, qerr)
+	}
+	if rec.Header().Get("Content-Language") != "fr" || rec.Header().Get("Vary") != "Accept-Language" {
+		t.Errorf("unexpected headers %v", rec.Header())
+	}
+	if _, qerr := requestLang(httptest.NewRecorder(), httptest.NewRequest("GET", "/docs?lang=de", nil)); qerr == nil || qerr.Param != "lang" {
+		t.Errorf("expected an unsupported language to be rejected, got %v", qerr)
+	}
+
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest("GET", "/docs", nil)
+	req.Header.Set("Accept-Language", "fr-BE")
+	handleDocs(rec, req)
+	if body := rec.Body.String(); !strings.Contains(body, `<html lang="fr">`) || !strings.Contains(body, "<title>API Rewatchable Games</title>") {
+		t.Errorf("unexpected page %s", body)
+	}
+}
+
+func TestLangFlag(t *testing.T) {
+	t.Setenv("LC_ALL", "")
+	t.Setenv("LC_MESSAGES", "")
+	t.Setenv("LANG", "fr_FR.UTF-8")
+	fset := flag.NewFlagSet("test", flag.ContinueOnError)
+	lang := langFlag(fset)
+	if *lang != "fr" {
+		t.Errorf("expected the locale's language by default, got %q", *lang)
+	}
+	if err := fset.Parse([]string{"--lang", "en"}); err != nil || *lang != "en" {
+		t.Errorf("expected --lang to override, got %q %v", *lang, err)
+	}
+	if _, err := checkLang("klingon"); err == nil {
+		t.Error("expected an unsupported language to be rejected")
+	}
+	if err := runCompact([]string{"-lang", "fr"}); err == nil || !strings.HasPrefix(err.Error(), "usage : compact") {
+		t.Errorf("expected the French usage, got %v", err)
+	}
+}
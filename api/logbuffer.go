@@ -0,0 +1,65 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"sync"
+)
+
+// recentLogLines is how many log lines are kept for /admin/logs and
+// support bundles
+const recentLogLines = 1000
+
+// logRing keeps the last lines written to the log
+type logRing struct {
+	mu    sync.Mutex
+	lines []string
+	next  int
+	full  bool
+}
+
+func newLogRing(n int) *logRing {
+	return &logRing{lines: make([]string, n)}
+}
+
+// recentLogs receives a copy of everything logged by the server
+var recentLogs = newLogRing(recentLogLines)
+
+func (l *logRing) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, line := range bytes.SplitAfter(p, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		l.lines[l.next] = string(line)
+		l.next = (l.next + 1) % len(l.lines)
+		if l.next == 0 {
+			l.full = true
+		}
+	}
+	return len(p), nil
+}
+
+// String returns the kept lines, oldest first
+func (l *logRing) String() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var b bytes.Buffer
+	if l.full {
+		for _, line := range l.lines[l.next:] {
+			b.WriteString(line)
+		}
+	}
+	for _, line := range l.lines[:l.next] {
+		b.WriteString(line)
+	}
+	return b.String()
+}
+
+// handleRecentLogs serves the last log lines as plain text
+func handleRecentLogs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write([]byte(recentLogs.String()))
+}
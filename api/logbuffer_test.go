@@ -0,0 +1,22 @@
+package api
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestLogRingKeepsLastLines(t *testing.T) {
+	l := newLogRing(3)
+	for i := 1; i <= 5; i++ {
+		fmt.Fprintf(l, "line %d\n", i)
+	}
+	if got := l.String(); got != "line 3\nline 4\nline 5\n" {
+		t.Errorf("unexpected lines %q", got)
+	}
+
+	l = newLogRing(3)
+	l.Write([]byte("a\nb\n"))
+	if got := l.String(); got != "a\nb\n" {
+		t.Errorf("unexpected lines %q", got)
+	}
+}
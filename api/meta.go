@@ -0,0 +1,83 @@
+package api
+
+import (
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FieldMeta describes one numeric field, generated from the unit, range,
+// better and desc struct tags
+type FieldMeta struct {
+	Field          string    `json:"field"`
+	Unit           string    `json:"unit,omitempty"`
+	Description    string    `json:"description,omitempty"`
+	TypicalRange   []float64 `json:"typicalRange,omitempty"`
+	HigherIsBetter *bool     `json:"higherIsBetter"`
+}
+
+// FieldsMeta is the /meta/fields document. Typical ranges span the 5th to
+// the 95th percentile of the published data.
+type FieldsMeta struct {
+	Stats   []FieldMeta `json:"stats"`
+	Ratings []FieldMeta `json:"ratings"`
+}
+
+// fieldsMeta is built once, struct tags do not change at runtime
+var fieldsMeta = FieldsMeta{
+	Stats:   buildFieldMeta(reflect.TypeOf(GameStats{}), ""),
+	Ratings: buildFieldMeta(reflect.TypeOf(ProcessedGameStats{}), ""),
+}
+
+// buildFieldMeta lists the tagged numeric fields of t, by JSON path
+func buildFieldMeta(t reflect.Type, prefix string) []FieldMeta {
+	var fields []FieldMeta
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		if f.Type.Kind() == reflect.Struct {
+			fields = append(fields, buildFieldMeta(f.Type, prefix+name+".")...)
+			continue
+		}
+		if f.Tag.Get("unit") == "" {
+			continue
+		}
+
+		m := FieldMeta{
+			Field:       prefix + name,
+			Unit:        f.Tag.Get("unit"),
+			Description: f.Tag.Get("desc"),
+		}
+		if lo, hi, ok := strings.Cut(f.Tag.Get("range"), "-"); ok {
+			min, err1 := strconv.ParseFloat(lo, 64)
+			max, err2 := strconv.ParseFloat(hi, 64)
+			if err1 == nil && err2 == nil {
+				m.TypicalRange = []float64{min, max}
+			}
+		}
+		switch f.Tag.Get("better") {
+		case "higher":
+			m.HigherIsBetter = new(bool)
+			*m.HigherIsBetter = true
+		case "lower":
+			m.HigherIsBetter = new(bool)
+		}
+		fields = append(fields, m)
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Field < fields[j].Field })
+	return fields
+}
+
+// handleFieldsMeta describes every stat and rating field
+func handleFieldsMeta(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	setCacheHeaders(w, "meta")
+	if err := json.NewEncoder(w).Encode(fieldsMeta); err != nil {
+		writeError(w, r, http.StatusInternalServerError, "error encoding response")
+	}
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFieldsMetaCoversEveryStat(t *testing.T) {
+	described := make(map[string]FieldMeta)
+	for _, m := range fieldsMeta.Stats {
+		described[m.Field] = m
+	}
+	for path := range statPaths {
+		if _, ok := described[path]; !ok {
+			t.Errorf("stat %s has no unit metadata", path)
+		}
+	}
+
+	m := described["offense.totalPoints"]
+	if m.Unit != "points" || len(m.TypicalRange) != 2 || m.HigherIsBetter == nil || !*m.HigherIsBetter {
+		t.Errorf("unexpected totalPoints metadata %+v", m)
+	}
+	if m := described["scenario.marginOfVictory"]; m.HigherIsBetter == nil || *m.HigherIsBetter {
+		t.Errorf("expected a lower margin to be better, got %+v", m)
+	}
+	if m := described["offense.totalPlays"]; m.HigherIsBetter != nil {
+		t.Errorf("expected totalPlays to be neutral, got %+v", m)
+	}
+}
+
+func TestHandleFieldsMeta(t *testing.T) {
+	rec := httptest.NewRecorder()
+	handleFieldsMeta(rec, httptest.NewRequest("GET", "/meta/fields", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	var meta FieldsMeta
+	if err := json.Unmarshal(rec.Body.Bytes(), &meta); err != nil {
+		t.Fatal(err)
+	}
+	if len(meta.Ratings) != len(ratingFields) {
+		t.Errorf("expected %d rating fields, got %d", len(ratingFields), len(meta.Ratings))
+	}
+}
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"io/fs"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jjway/rewatchableGamesApi-go/store"
+)
+
+// missingTTL is how long a week file is remembered as missing before the
+// store is asked again
+const missingTTL = time.Minute
+
+// maxMissingFiles bounds the number of week files remembered as missing
+const maxMissingFiles = 1024
+
+// missingFiles remembers week files the store reported as absent, such as
+// unpublished future weeks that frontends keep probing
+var (
+	missingFiles   = make(map[string]time.Time)
+	missingFilesMu sync.RWMutex
+)
+
+// knownMissing reports whether name was recently found missing
+func knownMissing(name string) bool {
+	missingFilesMu.RLock()
+	since, ok := missingFiles[name]
+	missingFilesMu.RUnlock()
+	return ok && clock.Now().Sub(since) < missingTTL
+}
+
+// markMissing remembers that name is missing. Only the weeks that may be
+// published are remembered, those with a valid week name in a known season
+// or the one following it, so garbage paths do not fill the map; expired
+// entries are dropped as new ones come in.
+func markMissing(name string) {
+	if !plausibleWeekFile(name) {
+		return
+	}
+	now := clock.Now()
+	missingFilesMu.Lock()
+	defer missingFilesMu.Unlock()
+	if len(missingFiles) >= maxMissingFiles {
+		for n, since := range missingFiles {
+			if now.Sub(since) >= missingTTL {
+				delete(missingFiles, n)
+			}
+		}
+		if len(missingFiles) >= maxMissingFiles {
+			return
+		}
+	}
+	missingFiles[name] = now
+}
+
+// plausibleWeekFile reports whether name is a valid week of a season from
+// the first known one to the one after the latest, or of any season up to
+// next year when none is known yet
+func plausibleWeekFile(name string) bool {
+	if !store.IsWeekFile(name) {
+		return false
+	}
+	season, file, _ := strings.Cut(name, "/")
+	year, err := strconv.Atoi(season)
+	if err != nil || len(season) != 4 || !isValidWeek(strings.TrimSuffix(file, ".json")) {
+		return false
+	}
+	first, latest := 0, 0
+	for known := range cache.weekCounts() {
+		s, _, _ := strings.Cut(known, "/")
+		if y, err := strconv.Atoi(s); err == nil {
+			if first == 0 || y < first {
+				first = y
+			}
+			latest = max(latest, y)
+		}
+	}
+	if latest == 0 {
+		return year <= clock.Now().Year()+1
+	}
+	return year >= first && year <= latest+1
+}
+
+// forgetMissing clears name once it has been published
+func forgetMissing(name string) {
+	missingFilesMu.Lock()
+	delete(missingFiles, name)
+	missingFilesMu.Unlock()
+}
+
+// errMissing is returned for week files known to be missing
+func errMissing(name string) error {
+	return &fs.PathError{Op: "read", Path: name, Err: fs.ErrNotExist}
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/jjway/rewatchableGamesApi-go/store"
+)
+
+// countingFS counts week file reads on the wrapped filesystem
+type countingFS struct {
+	fstest.MapFS
+	reads int
+}
+
+func (c *countingFS) ReadFile(name string) ([]byte, error) {
+	if store.IsWeekFile(name) {
+		c.reads++
+	}
+	return c.MapFS.ReadFile(name)
+}
+
+func TestMissingWeeksAreRemembered(t *testing.T) {
+	c := useFakeClock(t)
+	useTestStore(t, t.TempDir())
+	fsys := &countingFS{MapFS: fstest.MapFS{}}
+	dataStore = store.NewFS(fsys)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /games/{year}/{week}", handleGamesYearWeek)
+
+	for i := 0; i < 5; i++ {
+		req := httptest.NewRequest("GET", "/games/2024/9", nil)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusNotFound {
+			t.Fatalf("expected status 404, got %d", rec.Code)
+		}
+		if cc := rec.Header().Get("Cache-Control"); cc != cachePolicies["missing"].header() {
+			t.Errorf("expected short cache lifetime on 404, got %q", cc)
+		}
+	}
+	if fsys.reads != 1 {
+		t.Errorf("expected a single store read for repeated 404s, got %d", fsys.reads)
+	}
+
+	// Once the week is published and the entry expires, it is served
+	fsys.MapFS["2024/9.json"] = &fstest.MapFile{Data: []byte(testData)}
+	c.Advance(missingTTL)
+	if _, err := loadGameStats("2024/9.json"); err != nil {
+		t.Errorf("expected published week to load after expiry, got %v", err)
+	}
+}
+
+func TestSetCachedForgetsMissing(t *testing.T) {
+	useTestStore(t, t.TempDir())
+
+	if _, err := loadGameStats("2024/1.json"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected missing week, got %v", err)
+	}
+	setCached("2024/1.json", []GameStats{{ID: "x"}}, 0, "")
+	if knownMissing("2024/1.json") {
+		t.Error("expected reload to clear the missing marker")
+	}
+}
+
+func TestMissingGarbageIsNotRemembered(t *testing.T) {
+	useFakeClock(t)
+	useTestStore(t, setupTestData(t))
+	preloadCache(dataStore)
+
+	for _, name := range []string{"2024/99.json", "abcd/1.json", "2024/x.json", "1990/1.json", "2031/1.json", "x/y/z.json"} {
+		loadGameStats(name)
+		if knownMissing(name) {
+			t.Errorf("%s: expected a garbage path not to be remembered", name)
+		}
+	}
+	for _, name := range []string{"2024/5.json", "2025/1.json"} {
+		loadGameStats(name)
+		if !knownMissing(name) {
+			t.Errorf("%s: expected a week that may be published to be remembered", name)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package api
+
+import (
+	"math"
+	"net/url"
+	"sort"
+	"sync"
+)
+
+// Modes of ?normalize=
+const (
+	normalizePercentile = "percentile"
+	normalizeZScore     = "zscore"
+)
+
+// ratingMoments is the mean and standard deviation of the TotalRatings of
+// a season
+type ratingMoments struct {
+	mean, stddev float64
+}
+
+// seasonMoments caches ratingMoments like the sorted ratings they are
+// computed from, and is dropped along with them
+var (
+	seasonMoments   = make(map[string]ratingMoments)
+	seasonMomentsMu sync.Mutex
+)
+
+// momentsOf returns the rating moments of season rated with algo
+func momentsOf(algo, season string) ratingMoments {
+	key := algo + "/" + season
+	seasonMomentsMu.Lock()
+	defer seasonMomentsMu.Unlock()
+	if m, ok := seasonMoments[key]; ok {
+		return m
+	}
+
+	ratings := sortedRatings(algo, season)
+	var m ratingMoments
+	if len(ratings) > 0 {
+		for _, r := range ratings {
+			m.mean += r
+		}
+		m.mean /= float64(len(ratings))
+		for _, r := range ratings {
+			m.stddev += (r - m.mean) * (r - m.mean)
+		}
+		m.stddev = math.Sqrt(m.stddev / float64(len(ratings)))
+	}
+	seasonMoments[key] = m
+	return m
+}
+
+// parseNormalize parses ?normalize=, percentile or zscore
+func parseNormalize(values url.Values) (string, *QueryError) {
+	switch v := values.Get("normalize"); v {
+	case "", normalizePercentile, normalizeZScore:
+		return v, nil
+	default:
+		return "", &QueryError{Param: "normalize", Value: v, Message: "must be percentile or zscore"}
+	}
+}
+
+// normalizedRating returns TotalRating relative to the other games of the
+// season: the percentage of them rated at or below it, or its distance to
+// the season mean in standard deviations
+func normalizedRating(p ProcessedGameStats, mode string) float64 {
+	switch mode {
+	case normalizePercentile:
+		ratings := sortedRatings(p.Algorithm, p.Season)
+		if len(ratings) == 0 {
+			return 0
+		}
+		atOrBelow := sort.Search(len(ratings), func(i int) bool { return ratings[i] > p.TotalRating })
+		return round2(100 * float64(atOrBelow) / float64(len(ratings)))
+	case normalizeZScore:
+		m := momentsOf(p.Algorithm, p.Season)
+		if m.stddev == 0 {
+			return 0
+		}
+		return round2((p.TotalRating - m.mean) / m.stddev)
+	}
+	return 0
+}
+
+// normalizeGames sets the NormalizedRating of games, tagged with their
+// season, when mode is not empty
+func normalizeGames(games []ProcessedGameStats, mode string) {
+	if mode == "" {
+		return
+	}
+	for i := range games {
+		v := normalizedRating(games[i], mode)
+		games[i].NormalizedRating = &v
+		games[i].Normalization = mode
+	}
+}
+
+// warmNormalization computes the season distributions of the default
+// algorithm ahead of the first normalized request
+func warmNormalization() {
+	for _, s := range availableSeasons() {
+		momentsOf(defaultAlgorithm, s.Season)
+	}
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/jjway/rewatchableGamesApi-go/store"
+)
+
+func TestNormalizedRating(t *testing.T) {
+	useQuantileCache(t, map[string]int{"2023": 10, "2024": 20})
+
+	tests := []struct {
+		season string
+		rating float64
+		mode   string
+		want   float64
+	}{
+		{"2023", 9, normalizePercentile, 90},
+		{"2024", 9, normalizePercentile, 45},
+		{"2024", 19, normalizePercentile, 95},
+		{"2023", 5.5, normalizeZScore, 0},
+		{"2023", 10, normalizeZScore, 1.57},
+		{"2023", 1, normalizeZScore, -1.57},
+		{"2024", 10, normalizeZScore, -0.09},
+	}
+	for _, tt := range tests {
+		p := ProcessedGameStats{Season: tt.season, TotalRating: tt.rating, Algorithm: "v1"}
+		if got := normalizedRating(p, tt.mode); got != tt.want {
+			t.Errorf("%s of %v in %s: got %v, want %v", tt.mode, tt.rating, tt.season, got, tt.want)
+		}
+	}
+
+	// Reloading a week recomputes the distributions
+	setCached(store.WeekFile("2023", "1"), nil, 0, "")
+	p := ProcessedGameStats{Season: "2023", TotalRating: 10, Algorithm: "v1"}
+	if got := normalizedRating(p, normalizeZScore); got != 0 {
+		t.Errorf("expected no z-score for an emptied season, got %v", got)
+	}
+}
+
+func TestNormalizeQuery(t *testing.T) {
+	useQuantileCache(t, map[string]int{"2023": 10, "2024": 20})
+
+	values, _ := url.ParseQuery("normalize=percentile&sort=totalRating")
+	q, qerr := parseGameQuery(values)
+	if qerr != nil {
+		t.Fatal(qerr)
+	}
+	if !q.percentile {
+		t.Error("expected normalized responses to depend on the quantiles")
+	}
+	games := q.apply([]ProcessedGameStats{
+		{ID: "a", Season: "2023", TotalRating: 9, Algorithm: "v1"},
+		{ID: "b", Season: "2024", TotalRating: 10, Algorithm: "v1"},
+	})
+	if games[0].NormalizedRating == nil || *games[0].NormalizedRating != 50 || games[0].Normalization != normalizePercentile {
+		t.Errorf("unexpected normalization of b: %+v", games[0])
+	}
+	if *games[1].NormalizedRating != 90 {
+		t.Errorf("expected a to rank higher in its season, got %v", *games[1].NormalizedRating)
+	}
+
+	values, _ = url.ParseQuery("sort=totalRating")
+	q, _ = parseGameQuery(values)
+	if games := q.apply([]ProcessedGameStats{{ID: "a", Season: "2023", TotalRating: 9}}); games[0].NormalizedRating != nil {
+		t.Errorf("expected no normalized rating by default, got %v", *games[0].NormalizedRating)
+	}
+
+	values, _ = url.ParseQuery("normalize=minmax")
+	if _, qerr := parseGameQuery(values); qerr == nil || qerr.Param != "normalize" {
+		t.Errorf("expected a normalize error, got %v", qerr)
+	}
+}
@@ -0,0 +1,394 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// NotifyEvent is the payload handed to every notification channel
+type NotifyEvent struct {
+	Kind  string               `json:"kind"`
+	Year  string               `json:"year,omitempty"`
+	Week  string               `json:"week,omitempty"`
+	Title string               `json:"title"`
+	Games []ProcessedGameStats `json:"games,omitempty"`
+}
+
+// Notifier delivers a NotifyEvent to a single channel
+type Notifier interface {
+	Name() string
+	Notify(ctx context.Context, ev NotifyEvent) error
+}
+
+// ChannelPreview is what a notifier would send for an event
+type ChannelPreview struct {
+	Channel     string `json:"channel"`
+	ContentType string `json:"contentType"`
+	Body        string `json:"body"`
+}
+
+// previewer is implemented by the notifiers able to render an event
+// without sending it
+type previewer interface {
+	Preview(ev NotifyEvent) (ChannelPreview, error)
+}
+
+// RetryPolicy controls how many times a failed delivery is retried
+type RetryPolicy struct {
+	MaxAttempts int `json:"maxAttempts"`
+	BackoffMs   int `json:"backoffMs"`
+}
+
+// ChannelConfig is the per-channel configuration of a notifier
+type ChannelConfig struct {
+	Type     string      `json:"type"`
+	Name     string      `json:"name"`
+	URL      string      `json:"url"`
+	Template string      `json:"template"`
+	Retry    RetryPolicy `json:"retry"`
+
+	// Webhook only: the HMAC key signing the payloads, and how many of
+	// the best games they list, all when zero
+	Secret string `json:"secret"`
+	Top    int    `json:"top"`
+
+	// Email only
+	SMTPAddr string   `json:"smtpAddr"`
+	Username string   `json:"username"`
+	Password string   `json:"password"`
+	From     string   `json:"from"`
+	To       []string `json:"to"`
+}
+
+// defaultNotifyTemplate renders the top games of an event as plain text
+const defaultNotifyTemplate = `{{.Title}}
+{{range $i, $g := .Games}}{{if lt $i 5}}{{inc $i}}. {{$g.ShortName}} ({{printf "%.1f" $g.TotalRating}})
+{{end}}{{end}}`
+
+var notifyFuncs = template.FuncMap{"inc": func(i int) int { return i + 1 }}
+
+// notifierFactories maps a channel type to its constructor. New channels
+// only need to register a factory here.
+var notifierFactories = map[string]func(ChannelConfig) (Notifier, error){
+	"webhook": newWebhookNotifier,
+	"discord": newDiscordNotifier,
+	"slack":   newSlackNotifier,
+	"email":   newEmailNotifier,
+}
+
+// newNotifier builds the notifier for cfg, wrapped with its retry policy
+func newNotifier(cfg ChannelConfig) (Notifier, error) {
+	factory, ok := notifierFactories[cfg.Type]
+	if !ok {
+		return nil, fmt.Errorf("unknown notifier type %q", cfg.Type)
+	}
+	n, err := factory(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("notifier %s: %w", cfg.Type, err)
+	}
+	if cfg.Retry.MaxAttempts > 1 {
+		n = &retryNotifier{Notifier: n, policy: cfg.Retry}
+	}
+	return n, nil
+}
+
+// loadNotifiers reads a JSON array of ChannelConfig from path, which may be
+// encrypted and reference its URLs and credentials as env: or file:
+// secrets
+func loadNotifiers(path string) ([]Notifier, error) {
+	data, err := readConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var configs []ChannelConfig
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	notifiers := make([]Notifier, 0, len(configs))
+	for _, cfg := range configs {
+		if err := resolveSecrets(&cfg.URL, &cfg.Secret, &cfg.Username, &cfg.Password); err != nil {
+			return nil, fmt.Errorf("notifier %s: %w", channelName(cfg), err)
+		}
+		n, err := newNotifier(cfg)
+		if err != nil {
+			return nil, err
+		}
+		notifiers = append(notifiers, n)
+	}
+	return notifiers, nil
+}
+
+// notifiers are the channels configured at startup
+var notifiers []Notifier
+
+// activeNotifiers returns the configured channels followed by the
+// webhooks registered through /admin/webhooks
+func activeNotifiers() []Notifier {
+	return append(append([]Notifier(nil), notifiers...), webhooks.notifiers()...)
+}
+
+// notifyAll delivers ev to every channel, logging failures
+func notifyAll(ctx context.Context, ev NotifyEvent) {
+	for _, n := range activeNotifiers() {
+		if err := n.Notify(ctx, ev); err != nil {
+			log.Printf("Warning: notifier %s failed: %v", n.Name(), err)
+		}
+	}
+}
+
+// retryNotifier retries a failed delivery with a linear backoff
+type retryNotifier struct {
+	Notifier
+	policy RetryPolicy
+}
+
+func (r *retryNotifier) Preview(ev NotifyEvent) (ChannelPreview, error) {
+	p, ok := r.Notifier.(previewer)
+	if !ok {
+		return ChannelPreview{}, fmt.Errorf("notifier %s cannot be previewed", r.Name())
+	}
+	return p.Preview(ev)
+}
+
+func (r *retryNotifier) Notify(ctx context.Context, ev NotifyEvent) error {
+	var err error
+	for attempt := 1; attempt <= r.policy.MaxAttempts; attempt++ {
+		if err = r.Notifier.Notify(ctx, ev); err == nil {
+			return nil
+		}
+		if attempt == r.policy.MaxAttempts {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(r.policy.BackoffMs*attempt) * time.Millisecond):
+		}
+	}
+	return err
+}
+
+// messageTemplate parses the channel template, falling back to the default
+func messageTemplate(cfg ChannelConfig) (*template.Template, error) {
+	text := cfg.Template
+	if text == "" {
+		text = defaultNotifyTemplate
+	}
+	return template.New(cfg.Type).Funcs(notifyFuncs).Parse(text)
+}
+
+func render(tmpl *template.Template, ev NotifyEvent) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, ev); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// postJSON sends body as JSON to url and treats any non-2xx as an error
+func postJSON(ctx context.Context, url string, body any) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	return postPayload(ctx, url, payload, nil)
+}
+
+// postPayload sends a JSON payload to url with the extra header
+func postPayload(ctx context.Context, url string, payload []byte, header http.Header) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("POST %s: unexpected status %d", url, resp.StatusCode)
+	}
+	return nil
+}
+
+// webhookNotifier POSTs the raw event as JSON, signed when it has a
+// secret
+type webhookNotifier struct {
+	name   string
+	url    string
+	secret string
+	top    int
+}
+
+func newWebhookNotifier(cfg ChannelConfig) (Notifier, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("url is required")
+	}
+	if cfg.Top < 0 {
+		return nil, fmt.Errorf("top must not be negative")
+	}
+	return &webhookNotifier{name: channelName(cfg), url: cfg.URL, secret: cfg.Secret, top: cfg.Top}, nil
+}
+
+func (n *webhookNotifier) Name() string { return n.name }
+
+// payload is the JSON of ev, cut to the best games
+func (n *webhookNotifier) payload(ev NotifyEvent) ([]byte, error) {
+	if n.top > 0 && len(ev.Games) > n.top {
+		ev.Games = ev.Games[:n.top]
+	}
+	return json.Marshal(ev)
+}
+
+func (n *webhookNotifier) Notify(ctx context.Context, ev NotifyEvent) error {
+	payload, err := n.payload(ev)
+	if err != nil {
+		return err
+	}
+	var header http.Header
+	if n.secret != "" {
+		ts := strconv.FormatInt(clock.Now().Unix(), 10)
+		header = http.Header{}
+		header.Set("X-Webhook-Timestamp", ts)
+		header.Set("X-Webhook-Signature", "sha256="+signWebhook(n.secret, ts, payload))
+	}
+	return postPayload(ctx, n.url, payload, header)
+}
+
+func (n *webhookNotifier) Preview(ev NotifyEvent) (ChannelPreview, error) {
+	payload, err := n.payload(ev)
+	return ChannelPreview{Channel: n.name, ContentType: "application/json", Body: string(payload)}, err
+}
+
+// signWebhook returns the hex HMAC-SHA256 of "timestamp.payload" under
+// secret. Receivers recompute it, and reject old timestamps to stop
+// replays.
+func signWebhook(secret, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// chatNotifier renders a text message and POSTs it under a single JSON
+// field, which covers both Discord ("content") and Slack ("text") webhooks
+type chatNotifier struct {
+	name  string
+	url   string
+	field string
+	tmpl  *template.Template
+}
+
+func newChatNotifier(cfg ChannelConfig, field string) (Notifier, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("url is required")
+	}
+	tmpl, err := messageTemplate(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &chatNotifier{name: channelName(cfg), url: cfg.URL, field: field, tmpl: tmpl}, nil
+}
+
+func newDiscordNotifier(cfg ChannelConfig) (Notifier, error) {
+	return newChatNotifier(cfg, "content")
+}
+
+func newSlackNotifier(cfg ChannelConfig) (Notifier, error) {
+	return newChatNotifier(cfg, "text")
+}
+
+func (n *chatNotifier) Name() string { return n.name }
+
+func (n *chatNotifier) Notify(ctx context.Context, ev NotifyEvent) error {
+	text, err := render(n.tmpl, ev)
+	if err != nil {
+		return err
+	}
+	return postJSON(ctx, n.url, map[string]string{n.field: text})
+}
+
+func (n *chatNotifier) Preview(ev NotifyEvent) (ChannelPreview, error) {
+	text, err := render(n.tmpl, ev)
+	if err != nil {
+		return ChannelPreview{}, err
+	}
+	payload, err := json.Marshal(map[string]string{n.field: text})
+	return ChannelPreview{Channel: n.name, ContentType: "application/json", Body: string(payload)}, err
+}
+
+// emailNotifier sends the rendered message over SMTP
+type emailNotifier struct {
+	name string
+	cfg  ChannelConfig
+	tmpl *template.Template
+}
+
+func newEmailNotifier(cfg ChannelConfig) (Notifier, error) {
+	if cfg.SMTPAddr == "" || cfg.From == "" || len(cfg.To) == 0 {
+		return nil, fmt.Errorf("smtpAddr, from and to are required")
+	}
+	tmpl, err := messageTemplate(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &emailNotifier{name: channelName(cfg), cfg: cfg, tmpl: tmpl}, nil
+}
+
+func (n *emailNotifier) Name() string { return n.name }
+
+// message renders the email of ev, headers included
+func (n *emailNotifier) message(ev NotifyEvent) (string, error) {
+	text, err := render(n.tmpl, ev)
+	if err != nil {
+		return "", err
+	}
+	return "From: " + n.cfg.From + "\r\n" +
+		"To: " + strings.Join(n.cfg.To, ", ") + "\r\n" +
+		"Subject: " + ev.Title + "\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n\r\n" + text, nil
+}
+
+func (n *emailNotifier) Preview(ev NotifyEvent) (ChannelPreview, error) {
+	msg, err := n.message(ev)
+	return ChannelPreview{Channel: n.name, ContentType: "message/rfc822", Body: msg}, err
+}
+
+func (n *emailNotifier) Notify(ctx context.Context, ev NotifyEvent) error {
+	msg, err := n.message(ev)
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if n.cfg.Username != "" {
+		host := n.cfg.SMTPAddr
+		if i := strings.LastIndex(host, ":"); i >= 0 {
+			host = host[:i]
+		}
+		auth = smtp.PlainAuth("", n.cfg.Username, n.cfg.Password, host)
+	}
+	return smtp.SendMail(n.cfg.SMTPAddr, auth, n.cfg.From, n.cfg.To, []byte(msg))
+}
+
+func channelName(cfg ChannelConfig) string {
+	if cfg.Name != "" {
+		return cfg.Name
+	}
+	return cfg.Type
+}
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestDiscordNotifierRendersTemplate(t *testing.T) {
+	var got map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &got)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	n, err := newNotifier(ChannelConfig{Type: "discord", URL: srv.URL})
+	if err != nil {
+		t.Fatalf("failed to build notifier: %v", err)
+	}
+
+	ev := NotifyEvent{
+		Title: "Week 1 ratings are in",
+		Games: []ProcessedGameStats{{ShortName: "BAL @ KC", TotalRating: 12.5}},
+	}
+	if err := n.Notify(context.Background(), ev); err != nil {
+		t.Fatalf("notify failed: %v", err)
+	}
+
+	if !strings.Contains(got["content"], "1. BAL @ KC (12.5)") {
+		t.Errorf("unexpected message: %q", got["content"])
+	}
+}
+
+func TestRetryNotifier(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	n, err := newNotifier(ChannelConfig{
+		Type:  "webhook",
+		URL:   srv.URL,
+		Retry: RetryPolicy{MaxAttempts: 3},
+	})
+	if err != nil {
+		t.Fatalf("failed to build notifier: %v", err)
+	}
+
+	if err := n.Notify(context.Background(), NotifyEvent{Title: "test"}); err != nil {
+		t.Fatalf("expected success after retries, got %v", err)
+	}
+	if calls.Load() != 3 {
+		t.Errorf("expected 3 attempts, got %d", calls.Load())
+	}
+}
+
+func TestUnknownNotifierType(t *testing.T) {
+	if _, err := newNotifier(ChannelConfig{Type: "carrier-pigeon"}); err == nil {
+		t.Error("expected error for unknown notifier type")
+	}
+}
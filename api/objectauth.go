@@ -0,0 +1,59 @@
+package api
+
+import (
+	"fmt"
+
+	"github.com/jjway/rewatchableGamesApi-go/store"
+)
+
+// bucketAuth are the credential settings of a bucket: an access key and
+// region, or a token. The secrets may come from files.
+type bucketAuth struct {
+	AccessKeyID         string
+	SecretAccessKey     string
+	SecretAccessKeyFile string
+	Region              string
+	Token               string
+	TokenFile           string
+}
+
+// The credentials of the buckets of the store, the publisher and the
+// replica
+func (c Config) objectStoreAuth() bucketAuth {
+	s := c.Store
+	return bucketAuth{s.ObjectStoreAccessKeyID, s.ObjectStoreSecretAccessKey, s.ObjectStoreSecretAccessKeyFile, s.ObjectStoreRegion, s.ObjectStoreToken, s.ObjectStoreTokenFile}
+}
+
+func (c Config) publishAuth() bucketAuth {
+	p := c.Publish
+	return bucketAuth{p.AccessKeyID, p.SecretAccessKey, p.SecretAccessKeyFile, p.Region, p.Token, p.TokenFile}
+}
+
+func (c Config) replicaAuth() bucketAuth {
+	r := c.Replica
+	return bucketAuth{r.AccessKeyID, r.SecretAccessKey, r.SecretAccessKeyFile, r.Region, r.Token, r.TokenFile}
+}
+
+// objectCredentials checks the credentials of a bucket, whose settings are
+// the variables of prefix: {prefix}_ACCESS_KEY_ID, {prefix}_SECRET_ACCESS_KEY
+// and {prefix}_REGION, or {prefix}_TOKEN
+func objectCredentials(prefix string, a bucketAuth) (store.Credentials, error) {
+	c := store.Credentials{AccessKeyID: a.AccessKeyID, Region: a.Region}
+	var err error
+	if c.SecretAccessKey, err = settingSecret(a.SecretAccessKey, a.SecretAccessKeyFile); err != nil {
+		return c, fmt.Errorf("read %s_SECRET_ACCESS_KEY: %w", prefix, err)
+	}
+	if c.Token, err = settingSecret(a.Token, a.TokenFile); err != nil {
+		return c, fmt.Errorf("read %s_TOKEN: %w", prefix, err)
+	}
+	if (c.AccessKeyID == "") != (c.SecretAccessKey == "") {
+		return c, fmt.Errorf("%s_ACCESS_KEY_ID and %s_SECRET_ACCESS_KEY must be set together", prefix, prefix)
+	}
+	if c.AccessKeyID != "" && c.Token != "" {
+		return c, fmt.Errorf("set either an access key or %s_TOKEN, not both", prefix)
+	}
+	if c.Region == "" {
+		c.Region = store.DefaultRegion
+	}
+	return c, nil
+}
@@ -0,0 +1,29 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/jjway/rewatchableGamesApi-go/store"
+)
+
+func TestObjectCredentials(t *testing.T) {
+	var cfg Config
+	cfg.Store.ObjectStoreAccessKeyID = "id"
+	if _, err := objectCredentials("OBJECT_STORE", cfg.objectStoreAuth()); err == nil {
+		t.Error("expected an access key without its secret to be rejected")
+	}
+	cfg.Store.ObjectStoreSecretAccessKey = "secret"
+	c, err := objectCredentials("OBJECT_STORE", cfg.objectStoreAuth())
+	if err != nil || c.Region != store.DefaultRegion {
+		t.Errorf("expected the default region, got %+v, %v", c, err)
+	}
+
+	cfg = configOf(t, map[string]string{
+		"STORE_BACKEND":      "s3",
+		"OBJECT_STORE_URL":   "https://bucket.s3.amazonaws.com",
+		"OBJECT_STORE_TOKEN": "tok",
+	})
+	if _, err := openStore(cfg); err == nil {
+		t.Error("expected a bearer token to be rejected for S3")
+	}
+}
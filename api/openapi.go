@@ -0,0 +1,435 @@
+package api
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// openAPIRoute documents one route. The schemas of the request and
+// response bodies are generated from the Go types of body and response,
+// so the document follows the handlers as their types change.
+type openAPIRoute struct {
+	method, path string
+	summary      string
+	query        []string // keys of openAPIParams
+	role         string   // role the X-API-Key needs, if any
+	body         any      // request body
+	response     any      // 200 JSON body, or the media type of a text body
+	status       int      // success status without body, e.g. 204
+}
+
+// openAPIParam is a query parameter shared by several routes
+type openAPIParam struct {
+	typ, desc string
+}
+
+// openAPIParams are the query parameters, by name
+var openAPIParams = map[string]openAPIParam{
+	"algo":            {"string", "Rating algorithm version; the /{version}/ path prefix takes precedence"},
+	"sort":            {"string", "Compound sort, e.g. scenarioRating:desc,totalPoints:desc; see /meta/fields"},
+	"order":           {"string", "Direction of the sort keys without one: asc or desc"},
+	"q":               {"string", "Filter expression, see /meta/query-syntax; on /search, the words of the team names to find"},
+	"matchupQuality":  {"string", "Only games of this matchup quality"},
+	"minPercentile":   {"number", "Only games rated at or above this percentile, 0 to 100"},
+	"percentileScope": {"string", "all (default) or season, the games minPercentile compares against"},
+	"excludeBlowouts": {"boolean", "Leave out the games flagged as blowouts"},
+	"teams":           {"string", "Comma-separated teams, any of which must play"},
+	"useProfile":      {"boolean", "Apply the favorite teams of the key's profile as teams; requires X-API-Key"},
+	"normalize":       {"string", "percentile or zscore, adds normalizedRating relative to the season"},
+	"spoilerFree":     {"boolean", "Leave out the fields revealing the outcome"},
+	"explainFilters":  {"boolean", "Wrap the games with the number each filter removed"},
+	"format":          {"string", "json, csv or ndjson; the Accept header works too"},
+	"limit":           {"integer", "Page size; paginated responses are wrapped in a Page"},
+	"offset":          {"integer", "Index of the first item of the page"},
+	"links":           {"boolean", "Wrap the games with the links to the adjacent weeks"},
+	"asOf":            {"string", "RFC 3339 time or date of a past version of the week"},
+	"timeout":         {"string", "How long to wait for the week, e.g. 30s"},
+	"since":           {"string", "RFC 3339 time or date after which to list the changes"},
+	"season":          {"string", "Only this season"},
+	"from":            {"integer", "First season"},
+	"to":              {"integer", "Last season"},
+	"n":               {"integer", "Number of games"},
+	"weeks":           {"integer", "Number of recent weeks"},
+	"raw":             {"boolean", "Serve the deprecated raw dump instead of redirecting"},
+	"year":            {"string", "Season"},
+	"week":            {"string", "Week of the season"},
+	"force":           {"boolean", "Replace the data already stored"},
+	"ns":              {"string", "Namespace of the ID, e.g. espn, nflverse or slug"},
+	"dryRun":          {"boolean", "Report what would change, as a DryRunReport, without applying it"},
+	"lang":            {"string", "Language of the text, en or fr; the Accept-Language header works too"},
+	"query":           {"string", "GraphQL document"},
+	"variables":       {"string", "GraphQL variables, as a JSON object"},
+	"operationName":   {"string", "GraphQL operation to run"},
+	"sdl":             {"boolean", "Return the GraphQL schema"},
+}
+
+// listParams are the sort, filter and representation parameters of the
+// game lists, with a min<Field> filter per rating field
+func listParams() []string {
+	params := []string{"algo", "sort", "order", "q"}
+	for _, field := range sortedFieldNames() {
+		params = append(params, "min"+strings.ToUpper(field[:1])+field[1:])
+	}
+	return append(params, "matchupQuality", "minPercentile", "percentileScope", "excludeBlowouts",
+		"teams", "useProfile", "normalize", "spoilerFree", "explainFilters", "format", "limit", "offset")
+}
+
+// leagueParams are the parameters of the weeks of a league: the
+// percentiles and pagination compare with and page through the default
+// league's games
+func leagueParams() []string {
+	var params []string
+	for _, param := range listParams() {
+		switch param {
+		case "minPercentile", "percentileScope", "normalize", "limit", "offset":
+		default:
+			params = append(params, param)
+		}
+	}
+	return params
+}
+
+// openAPIRoutes are the documented routes
+func openAPIRoutes() []openAPIRoute {
+	games := []ProcessedGameStats{}
+	return []openAPIRoute{
+		{method: "GET", path: "/", summary: "Index of the endpoints, the data coverage and the API version", response: APIIndex{}},
+		{method: "GET", path: "/games/{year}/{week}", summary: "Rated games of a week", query: append(listParams(), "links", "asOf"), response: games},
+		{method: "POST", path: "/games/{year}/{week}", summary: "Publish the games of a week", role: roleAdmin, query: []string{"dryRun"}, body: []GameStats{}, response: WeekStatus{}},
+		{method: "GET", path: "/games/{year}/{week}/status", summary: "Publication status of a week", response: WeekStatus{}},
+		{method: "GET", path: "/games/{year}/{week}/wait", summary: "The week's games once published, or 204 at the timeout", query: []string{"timeout", "algo", "spoilerFree"}, response: games},
+		{method: "GET", path: "/games/{year}/{week}/{id}", summary: "Raw stats of a game", query: []string{"spoilerFree"}, response: GameStats{}},
+		{method: "GET", path: "/games/{year}/{week}/{id}/rating", summary: "Breakdown of a game's rating", query: []string{"algo"}, response: RatingBreakdown{}},
+		{method: "GET", path: "/games/{year}", summary: "Deprecated raw season dump, see /seasons/{year}/games", query: []string{"raw", "limit", "offset"}, response: []GameStats{}},
+		{method: "GET", path: "/games", summary: "Rated games of a range of seasons", query: append(listParams(), "from", "to"), response: games},
+		{method: "GET", path: "/games/top", summary: "Best games of all time", query: []string{"n", "algo", "teams", "useProfile", "normalize", "spoilerFree", "format"}, response: games},
+		{method: "GET", path: "/games/{year}/top", summary: "Best games of a season", query: []string{"n", "algo", "teams", "useProfile", "normalize", "spoilerFree", "format"}, response: games},
+		{method: "GET", path: "/g/{slug}", summary: "Redirect to the game of a slug", status: http.StatusFound},
+		{method: "GET", path: "/seasons", summary: "Available seasons and weeks", response: []SeasonSummary{}},
+		{method: "GET", path: "/seasons/{year}/games", summary: "Rated games of a season", query: listParams(), response: games},
+		{method: "GET", path: "/bulk/{year}", summary: "Every week of a season, for exports", query: []string{"algo"}, response: []BulkWeek{}},
+		{method: "GET", path: "/teams", summary: "Names, abbreviations, divisions, colors and logos of the teams", response: []Team{}},
+		{method: "GET", path: "/teams/{team}/games", summary: "Games of a team", query: []string{"algo", "teams", "useProfile", "spoilerFree", "format"}, response: games},
+		{method: "GET", path: "/teams/{team}/summary/{year}", summary: "Season averages of a team", query: []string{"algo", "spoilerFree"}, response: TeamSeasonSummary{}},
+		{method: "GET", path: "/search", summary: "Rated games whose team names match the words of q", query: append(listParams(), "year"), response: games},
+		{method: "GET", path: "/leagues", summary: "Served leagues and the weeks of their seasons", response: []LeagueSummary{}},
+		{method: "GET", path: "/leagues/{league}/games/{year}/{week}", summary: "Rated games of a week of a league, nfl being those of /games/{year}/{week}", query: leagueParams(), response: games},
+		{method: "GET", path: "/matchups/{teamA}/{teamB}", summary: "Games between two teams, most rewatchable first", query: []string{"algo", "spoilerFree", "format"}, response: games},
+		{method: "GET", path: "/events", summary: "Server-Sent Events of the ingested and reloaded weeks, WeekEvent data", response: "text/event-stream"},
+		{method: "GET", path: "/votes/{year}", summary: "Live standings of the season's votes", response: VoteStandings{}},
+		{method: "POST", path: "/votes/{year}", summary: "Vote for a game", role: roleRead, body: Vote{}, status: http.StatusCreated},
+		{method: "GET", path: "/profile", summary: "Favorite teams of the voter", role: roleRead, response: Profile{}},
+		{method: "PUT", path: "/profile", summary: "Save the favorite teams of the voter, applied with useProfile", role: roleRead, query: []string{"dryRun"}, body: Profile{}, response: Profile{}},
+		{method: "POST", path: "/games/{id}/votes", summary: "Rate a game with a thumb or stars", role: roleRead, query: []string{"dryRun"}, body: GameVote{}, response: CommunityScore{}},
+		{method: "POST", path: "/admin/votes/{year}", summary: "Open the season's votes", role: roleAdmin, query: []string{"dryRun"}, body: OpenVotingRequest{}, response: VotingWindow{}},
+		{method: "POST", path: "/admin/votes/{year}/close", summary: "Close the season's votes", role: roleAdmin, query: []string{"dryRun"}, status: http.StatusNoContent},
+		{method: "GET", path: "/graphql", summary: "GraphQL query: queries with variables and aliases, without fragments, directives or introspection", query: []string{"query", "variables", "operationName", "sdl"}, response: GraphQLResponse{}},
+		{method: "POST", path: "/graphql", summary: "GraphQL query: queries with variables and aliases, without fragments, directives or introspection", body: GraphQLRequest{}, response: GraphQLResponse{}},
+		{method: "GET", path: "/meta/fields", summary: "Units, ranges and descriptions of the fields", response: FieldsMeta{}},
+		{method: "GET", path: "/meta/query-syntax", summary: "Grammar of ?q=", response: QuerySyntax{}},
+		{method: "GET", path: "/meta/idmap/{id}", summary: "IDs of a game in every data provider", query: []string{"ns"}, response: IDMapping{}},
+		{method: "GET", path: "/robots.txt", summary: "Crawler rules", response: "text/plain"},
+		{method: "GET", path: "/readyz", summary: "Readiness, 503 until the week files are preloaded", response: Readiness{}},
+		{method: "GET", path: "/feed.rss", summary: "RSS feed of the best games of the most recent week", query: []string{"algo", "lang"}, response: "application/rss+xml"},
+		{method: "GET", path: "/feed.atom", summary: "Atom feed of the best games of the most recent week", query: []string{"algo", "lang"}, response: "application/atom+xml"},
+		{method: "GET", path: "/calendar.ics", summary: "iCalendar of the best games of the recent weeks, to subscribe to", query: []string{"weeks", "n", "algo", "lang"}, response: "text/calendar"},
+		{method: "GET", path: "/corrections.json", summary: "Games whose stats or ratings changed after their week was first published", query: []string{"algo", "season", "since", "limit"}, response: CorrectionsFeed{}},
+		{method: "GET", path: "/corrections.rss", summary: "RSS feed of the corrected weeks", query: []string{"algo", "lang", "season", "since", "limit"}, response: "application/rss+xml"},
+		{method: "POST", path: "/plan", summary: "Plan a rewatch of several games fitting a time budget", query: []string{"algo"}, body: PlanRequest{}, response: RewatchPlan{}},
+		{method: "GET", path: "/live", summary: "Provisional ratings of the games in progress (experimental)", query: []string{"algo"}, response: LiveBoard{}},
+		{method: "GET", path: "/openapi.json", summary: "This document", response: map[string]any{}},
+		{method: "GET", path: "/docs", summary: "Interactive documentation", query: []string{"lang"}, response: "text/html"},
+		{method: "GET", path: "/admin/consistency", summary: "Consistency check of the store", role: roleAdmin, response: ConsistencyReport{}},
+		{method: "GET", path: "/admin/validate", summary: "Validation of the loaded week files", role: roleAdmin, response: ValidationReport{}},
+		{method: "GET", path: "/admin/backfill", summary: "State of the last conditions backfill", role: roleAdmin, response: BackfillStatus{}},
+		{method: "POST", path: "/admin/backfill", summary: "Backfill the kickoff and weather of a season", role: roleAdmin, query: []string{"year", "force", "dryRun"}, response: BackfillStatus{}, status: http.StatusAccepted},
+		{method: "GET", path: "/admin/cache", summary: "Cache statistics", role: roleAdmin, response: CacheStats{}},
+		{method: "POST", path: "/admin/cache/purge", summary: "Drop cached weeks", role: roleAdmin, query: []string{"year", "week", "dryRun"}, response: PurgeResult{}},
+		{method: "GET", path: "/admin/digest/preview", summary: "What the notification channels would send for a week", role: roleAdmin, query: []string{"year", "week"}, response: DigestPreview{}},
+		{method: "GET", path: "/admin/logs", summary: "Recent log lines", role: roleAdmin, response: "text/plain"},
+		{method: "GET", path: "/admin/vars", summary: "Runtime counters, such as the panics recovered, in expvar format", role: roleAdmin, response: map[string]any{}},
+		{method: "GET", path: "/admin/webhooks", summary: "Registered webhooks", role: roleAdmin, response: []Webhook{}},
+		{method: "POST", path: "/admin/webhooks", summary: "Register a webhook called when a week is published", role: roleAdmin, query: []string{"dryRun"}, body: RegisterWebhookRequest{}, response: Webhook{}, status: http.StatusCreated},
+		{method: "DELETE", path: "/admin/webhooks/{id}", summary: "Unregister a webhook", role: roleAdmin, query: []string{"dryRun"}, status: http.StatusNoContent},
+		{method: "POST", path: "/admin/refresh", summary: "Fetch the current week from ESPN now", role: roleAdmin, query: []string{"year", "week", "dryRun"}, response: FetchResult{}},
+	}
+}
+
+// openAPISchemas builds the components.schemas of the document
+type openAPISchemas map[string]any
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schemaName names the schema of a struct, "Page[api.ProcessedGameStats]"
+// becoming "PageProcessedGameStats"
+func schemaName(t reflect.Type) string {
+	name := t.Name()
+	base, arg, ok := strings.Cut(name, "[")
+	if !ok {
+		return name
+	}
+	arg = strings.TrimSuffix(arg, "]")
+	arg = arg[strings.LastIndexAny(arg, "./")+1:]
+	return base + arg
+}
+
+// schemaFor returns the schema of t, registering the structs it uses
+func (c openAPISchemas) schemaFor(t reflect.Type) map[string]any {
+	switch t.Kind() {
+	case reflect.Pointer:
+		s := c.schemaFor(t.Elem())
+		if _, ok := s["$ref"]; ok {
+			return map[string]any{"allOf": []any{s}, "nullable": true}
+		}
+		s["nullable"] = true
+		return s
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": c.schemaFor(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": c.schemaFor(t.Elem())}
+	case reflect.Struct:
+		if t == timeType {
+			return map[string]any{"type": "string", "format": "date-time"}
+		}
+		// Anonymous structs, such as the stat groups of GameStats, are inlined
+		if t.Name() == "" {
+			return c.structSchema(t)
+		}
+		name := schemaName(t)
+		if _, ok := c[name]; !ok {
+			// Registered first so recursive types terminate
+			c[name] = nil
+			c[name] = c.structSchema(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + name}
+	}
+	// Interfaces hold any JSON value
+	return map[string]any{}
+}
+
+// structSchema lists the JSON fields of t. Fields without omitempty are
+// required; the desc and unit tags become the descriptions.
+func (c openAPISchemas) structSchema(t reflect.Type) map[string]any {
+	props := make(map[string]any)
+	var required []string
+	var walk func(t reflect.Type)
+	walk = func(t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			tag := f.Tag.Get("json")
+			if f.Anonymous && tag == "" {
+				walk(f.Type)
+				continue
+			}
+			name, opts, _ := strings.Cut(tag, ",")
+			if !f.IsExported() || name == "-" {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			s := c.schemaFor(f.Type)
+			if desc := f.Tag.Get("desc"); desc != "" {
+				if unit := f.Tag.Get("unit"); unit != "" {
+					desc += " (" + unit + ")"
+				}
+				if _, ok := s["$ref"]; !ok {
+					s["description"] = desc
+				}
+			}
+			props[name] = s
+			if !strings.Contains(opts, "omitempty") && f.Type.Kind() != reflect.Pointer {
+				required = append(required, name)
+			}
+		}
+	}
+	walk(t)
+
+	s := map[string]any{"type": "object", "properties": props}
+	if len(required) > 0 {
+		sort.Strings(required)
+		s["required"] = required
+	}
+	return s
+}
+
+// operationID derives an operation ID from the method and path, e.g.
+// getGamesYearWeek
+func operationID(method, path string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+	upper := true
+	for _, r := range path {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if upper {
+				r = unicode.ToUpper(r)
+			}
+			b.WriteRune(r)
+			upper = false
+		default:
+			upper = true
+		}
+	}
+	return b.String()
+}
+
+// buildOpenAPI generates the OpenAPI 3 document of the routes
+func buildOpenAPI() map[string]any {
+	schemas := openAPISchemas{}
+	problem := map[string]any{
+		"description": "RFC 7807 problem",
+		"content":     map[string]any{"application/problem+json": map[string]any{"schema": schemas.schemaFor(reflect.TypeOf(Problem{}))}},
+	}
+	// The response of ?dryRun=true, which the routes do not list
+	schemas.schemaFor(reflect.TypeOf(DryRunReport{}))
+
+	paths := make(map[string]map[string]any)
+	for _, route := range openAPIRoutes() {
+		var params []any
+		for _, segment := range strings.Split(route.path, "/") {
+			if name, ok := strings.CutPrefix(segment, "{"); ok {
+				params = append(params, map[string]any{
+					"name": strings.TrimSuffix(name, "}"), "in": "path", "required": true,
+					"schema": map[string]any{"type": "string"},
+				})
+			}
+		}
+		for _, name := range route.query {
+			p := openAPIParams[name]
+			if strings.HasPrefix(name, "min") && p.typ == "" {
+				p = openAPIParam{"number", "Only games with " + strings.ToLower(name[3:4]) + name[4:] + " at least this value"}
+			}
+			params = append(params, map[string]any{
+				"name": name, "in": "query", "description": p.desc,
+				"schema": map[string]any{"type": p.typ},
+			})
+		}
+
+		status := route.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		responses := map[string]any{"default": problem}
+		switch v := route.response.(type) {
+		case nil:
+			responses[strconv.Itoa(status)] = map[string]any{"description": http.StatusText(status)}
+		case string:
+			responses[strconv.Itoa(status)] = map[string]any{
+				"description": http.StatusText(status),
+				"content":     map[string]any{v: map[string]any{"schema": map[string]any{"type": "string"}}},
+			}
+		default:
+			responses[strconv.Itoa(status)] = map[string]any{
+				"description": http.StatusText(status),
+				"content":     map[string]any{"application/json": map[string]any{"schema": schemas.schemaFor(reflect.TypeOf(v))}},
+			}
+		}
+
+		op := map[string]any{
+			"operationId": operationID(route.method, route.path),
+			"summary":     route.summary,
+			"responses":   responses,
+		}
+		if len(params) > 0 {
+			op["parameters"] = params
+		}
+		if route.body != nil {
+			op["requestBody"] = map[string]any{
+				"required": true,
+				"content":  map[string]any{"application/json": map[string]any{"schema": schemas.schemaFor(reflect.TypeOf(route.body))}},
+			}
+		}
+		if route.role != "" {
+			op["security"] = []any{map[string]any{"apiKey": []string{}}}
+			op["description"] = "Needs an API key with the " + route.role + " role."
+		}
+		if paths[route.path] == nil {
+			paths[route.path] = make(map[string]any)
+		}
+		paths[route.path][strings.ToLower(route.method)] = op
+	}
+
+	info := map[string]any{
+		"title":   "Rewatchable Games API",
+		"version": versionInfo().Version,
+		"description": "Rewatchability ratings of NFL games. The routes are served under /" + currentAPIVersion + "/; " +
+			"the unprefixed paths are deprecated aliases, sunset on " + unversionedSunset.Format(time.DateOnly) + ". " +
+			"?algo= picks the rating algorithm (" + strings.Join(algorithmNames(), ", ") + ").",
+	}
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info":    info,
+		"servers": []map[string]any{{"url": "/" + currentAPIVersion}},
+		"paths":   paths,
+		"components": map[string]any{
+			"schemas": schemas,
+			"securitySchemes": map[string]any{
+				"apiKey": map[string]any{"type": "apiKey", "in": "header", "name": "X-API-Key"},
+			},
+		},
+	}
+}
+
+// openAPIDocument is built once, on the first request
+var openAPIDocument = sync.OnceValues(func() ([]byte, error) {
+	return json.Marshal(buildOpenAPI())
+})
+
+// handleOpenAPI serves the OpenAPI document of the API
+func handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	doc, err := openAPIDocument()
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "error encoding response")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	setCacheHeaders(w, "meta")
+	w.Write(doc)
+}
+
+// docsPage renders the openapi.json next to it with Swagger UI, formatted with the
+// language and the title
+const docsPage = `<!DOCTYPE html>
+<html lang="%s">
+<head>
+<meta charset="utf-8">
+<title>%s</title>
+<link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+<div id="swagger-ui"></div>
+<script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+<script>SwaggerUIBundle({url: "openapi.json", dom_id: "#swagger-ui"});</script>
+</body>
+</html>
+`
+
+// handleDocs serves the interactive documentation
+func handleDocs(w http.ResponseWriter, r *http.Request) {
+	lang, qerr := requestLang(w, r)
+	if qerr != nil {
+		writeQueryError(w, r, qerr)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	setCacheHeaders(w, "meta")
+	fmt.Fprintf(w, docsPage, lang, html.EscapeString(translate(lang, "docs.title")))
+}
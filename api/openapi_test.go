@@ -0,0 +1,75 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestOpenAPIDocumentsEveryRoute(t *testing.T) {
+	rec := httptest.NewRecorder()
+	handleOpenAPI(rec, httptest.NewRequest("GET", "/openapi.json", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	var doc struct {
+		OpenAPI    string                               `json:"openapi"`
+		Paths      map[string]map[string]map[string]any `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]map[string]any `json:"properties"`
+				Required   []string                  `json:"required"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.OpenAPI != "3.0.3" {
+		t.Errorf("unexpected version %q", doc.OpenAPI)
+	}
+
+	// Every route of the server has a class, so none goes undocumented
+	for pattern := range routeClasses {
+		method, path, _ := strings.Cut(pattern, " ")
+		path = strings.TrimSuffix(path, "{$}")
+		if _, ok := doc.Paths[path][strings.ToLower(method)]; !ok {
+			t.Errorf("%s is not documented", pattern)
+		}
+	}
+
+	game, ok := doc.Components.Schemas["ProcessedGameStats"]
+	if !ok {
+		t.Fatal("expected a ProcessedGameStats schema")
+	}
+	if desc, _ := game.Properties["totalRating"]["description"].(string); !strings.Contains(desc, "rewatchability") {
+		t.Errorf("expected the desc tag as description, got %q", desc)
+	}
+	if _, ok := game.Properties["stats"]; ok {
+		t.Error("unexported fields must not be documented")
+	}
+	if strings.Contains(strings.Join(game.Required, ","), "normalizedRating") {
+		t.Error("omitempty fields must not be required")
+	}
+	if _, ok := doc.Components.Schemas["GameStats"].Properties["scenario"]["properties"]; !ok {
+		t.Error("expected the anonymous stat groups to be inlined")
+	}
+	if op := doc.Paths["/admin/cache"]["get"]; op["security"] == nil {
+		t.Error("expected admin routes to require an API key")
+	}
+}
+
+func TestOperationID(t *testing.T) {
+	if id := operationID("GET", "/teams/{team}/summary/{year}"); id != "getTeamsTeamSummaryYear" {
+		t.Errorf("unexpected operation ID %q", id)
+	}
+}
+
+func TestHandleDocs(t *testing.T) {
+	rec := httptest.NewRecorder()
+	handleDocs(rec, httptest.NewRequest("GET", "/docs", nil))
+	if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/html") || !strings.Contains(rec.Body.String(), `url: "openapi.json"`) {
+		t.Errorf("expected the Swagger UI page, got %q", rec.Body.String())
+	}
+}
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"net/url"
+	"strconv"
+)
+
+// maxPageLimit caps ?limit= so a single page stays reasonably small
+const maxPageLimit = 500
+
+// pagination is the parsed ?limit= and ?offset= of a list request
+type pagination struct {
+	limit  int
+	offset int
+}
+
+// Page is the envelope returned by paginated list endpoints
+type Page[T any] struct {
+	Total  int `json:"total"`
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+	Items  []T `json:"items"`
+}
+
+// parsePagination parses ?limit= and ?offset=. ok is false when neither
+// parameter is present, in which case callers keep their unpaginated
+// response shape.
+func parsePagination(values url.Values) (p pagination, ok bool, qerr *QueryError) {
+	limitStr, offsetStr := values.Get("limit"), values.Get("offset")
+	if limitStr == "" && offsetStr == "" {
+		return p, false, nil
+	}
+
+	p.limit = maxPageLimit
+	if limitStr != "" {
+		n, err := strconv.Atoi(limitStr)
+		if err != nil || n < 1 || n > maxPageLimit {
+			return p, false, &QueryError{Param: "limit", Value: limitStr, Message: "must be an integer between 1 and " + strconv.Itoa(maxPageLimit)}
+		}
+		p.limit = n
+	}
+	if offsetStr != "" {
+		n, err := strconv.Atoi(offsetStr)
+		if err != nil || n < 0 {
+			return p, false, &QueryError{Param: "offset", Value: offsetStr, Message: "must be a non-negative integer"}
+		}
+		p.offset = n
+	}
+	return p, true, nil
+}
+
+// paginate slices items according to p and wraps them in a Page
+func paginate[T any](items []T, p pagination) Page[T] {
+	start := min(p.offset, len(items))
+	end := min(start+p.limit, len(items))
+	return Page[T]{
+		Total:  len(items),
+		Limit:  p.limit,
+		Offset: p.offset,
+		Items:  items[start:end],
+	}
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestPaginate(t *testing.T) {
+	items := []int{1, 2, 3, 4, 5}
+
+	page := paginate(items, pagination{limit: 2, offset: 1})
+	if page.Total != 5 || len(page.Items) != 2 || page.Items[0] != 2 {
+		t.Errorf("unexpected page %+v", page)
+	}
+
+	page = paginate(items, pagination{limit: 10, offset: 4})
+	if len(page.Items) != 1 || page.Items[0] != 5 {
+		t.Errorf("expected last item only, got %+v", page)
+	}
+
+	page = paginate(items, pagination{limit: 10, offset: 99})
+	if len(page.Items) != 0 {
+		t.Errorf("expected empty page past the end, got %+v", page)
+	}
+}
+
+func TestParsePaginationErrors(t *testing.T) {
+	for _, query := range []string{"limit=0", "limit=abc", "limit=100000", "offset=-1"} {
+		values, _ := url.ParseQuery(query)
+		if _, _, qerr := parsePagination(values); qerr == nil {
+			t.Errorf("%q: expected error", query)
+		}
+	}
+}
+
+func TestHandleGamesYearPaginated(t *testing.T) {
+	useTestStore(t, setupTestData(t))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /games/{year}", handleGamesYear)
+
+	req := httptest.NewRequest("GET", "/games/2024?raw=true&limit=1&offset=1", nil)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	var page Page[GameStats]
+	if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if page.Total != 2 || page.Limit != 1 || page.Offset != 1 || len(page.Items) != 1 {
+		t.Errorf("unexpected envelope %+v", page)
+	}
+}
@@ -0,0 +1,261 @@
+package api
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jjway/rewatchableGamesApi-go/store"
+)
+
+// perfScenario is one request perfcheck times against the benchmark
+// dataset
+type perfScenario struct {
+	Name    string
+	Pattern string
+	Handler http.HandlerFunc
+	Path    string
+}
+
+// perfScenarios are the hot paths: the cached week lookups, the season
+// scans and the indexed team views
+var perfScenarios = []perfScenario{
+	{"week", "GET /games/{year}/{week}", handleGamesYearWeek, "/games/2012/9"},
+	{"week-csv", "GET /games/{year}/{week}", handleGamesYearWeek, "/games/2012/9?format=csv"},
+	{"week-normalized", "GET /games/{year}/{week}", handleGamesYearWeek, "/games/2012/9?normalize=percentile"},
+	{"season", "GET /seasons/{year}/games", handleSeasonGames, "/seasons/2012/games"},
+	{"top", "GET /games/top", handleTopGames, "/games/top"},
+	{"season-top", "GET /games/{year}/top", handleTopGames, "/games/2012/top"},
+	{"team", "GET /teams/{team}/games", handleTeamGames, "/teams/KC/games"},
+	{"matchup", "GET /matchups/{teamA}/{teamB}", handleMatchup, "/matchups/KC/BUF"},
+	{"seasons", "GET /seasons", handleSeasons, "/seasons"},
+}
+
+// perfMux routes the scenario paths to their handlers
+func perfMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	seen := make(map[string]bool)
+	for _, s := range perfScenarios {
+		if !seen[s.Pattern] {
+			mux.HandleFunc(s.Pattern, s.Handler)
+			seen[s.Pattern] = true
+		}
+	}
+	return mux
+}
+
+// PerfResult is the measure of one scenario
+type PerfResult struct {
+	P95Micros   float64 `json:"p95Micros"`   // 95th percentile handler latency
+	AllocsPerOp float64 `json:"allocsPerOp"` // heap allocations per request
+	BytesPerOp  float64 `json:"bytesPerOp"`  // heap bytes allocated per request
+}
+
+// PerfBaseline is the stored perfcheck baseline, by scenario name
+type PerfBaseline struct {
+	Dataset   string                `json:"dataset"`
+	Scenarios map[string]PerfResult `json:"scenarios"`
+}
+
+// benchDataset identifies the generated dataset, so a baseline measured
+// on other data is not compared
+func benchDataset() string {
+	return fmt.Sprintf("%d seasons from %d, %d weeks, seed %d", benchSeasons, benchFirstSeason, benchWeeks, benchSeed)
+}
+
+// measureScenario serves path n times, after a warm up, and returns its
+// latency and allocations
+func measureScenario(h http.Handler, path string, n int) (PerfResult, error) {
+	serve := func() (time.Duration, error) {
+		req := httptest.NewRequest("GET", path, nil)
+		rec := httptest.NewRecorder()
+		start := time.Now()
+		h.ServeHTTP(rec, req)
+		elapsed := time.Since(start)
+		if rec.Code != http.StatusOK {
+			return 0, fmt.Errorf("%s: status %d", path, rec.Code)
+		}
+		return elapsed, nil
+	}
+	for range 10 {
+		if _, err := serve(); err != nil {
+			return PerfResult{}, err
+		}
+	}
+
+	runtime.GC()
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	latencies := make([]time.Duration, n)
+	for i := range latencies {
+		d, err := serve()
+		if err != nil {
+			return PerfResult{}, err
+		}
+		latencies[i] = d
+	}
+	runtime.ReadMemStats(&after)
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	p95 := latencies[(len(latencies)*95-1)/100]
+	return PerfResult{
+		P95Micros:   float64(p95.Nanoseconds()) / 1e3,
+		AllocsPerOp: float64(after.Mallocs-before.Mallocs) / float64(n),
+		BytesPerOp:  float64(after.TotalAlloc-before.TotalAlloc) / float64(n),
+	}, nil
+}
+
+// loadBenchData generates the benchmark dataset in dir and serves it
+func loadBenchData(dir string) error {
+	if err := generateBenchData(dir); err != nil {
+		return fmt.Errorf("generate benchmark data: %w", err)
+	}
+	dataStore = store.NewDir(dir)
+	cache = newWeekCache(0, 0, "")
+	responses = newResponseCache()
+	invalidateQuantiles()
+	invalidateTopGames()
+	preloadCache(dataStore)
+	return nil
+}
+
+// runPerfScenarios measures every scenario over the loaded dataset
+func runPerfScenarios(n int) (map[string]PerfResult, error) {
+	mux := perfMux()
+	results := make(map[string]PerfResult, len(perfScenarios))
+	for _, s := range perfScenarios {
+		res, err := measureScenario(mux, s.Path, n)
+		if err != nil {
+			return nil, fmt.Errorf("scenario %s: %w", s.Name, err)
+		}
+		results[s.Name] = res
+	}
+	return results, nil
+}
+
+// perfRegressions lists the scenarios whose p95 latency or allocations
+// grew beyond the latency and allocs shares of their baseline. Latency
+// growth under floor is timer noise on the fast paths and is let through,
+// and scenarios without a baseline are not checked.
+func perfRegressions(base, current map[string]PerfResult, latency, allocs float64, floor time.Duration) []string {
+	var regressed []string
+	for _, s := range perfScenarios {
+		b, ok := base[s.Name]
+		c := current[s.Name]
+		if !ok {
+			continue
+		}
+		switch {
+		case c.P95Micros > b.P95Micros*(1+latency) && c.P95Micros-b.P95Micros > float64(floor.Microseconds()):
+			regressed = append(regressed, fmt.Sprintf("%s: p95 %.0fµs, baseline %.0fµs", s.Name, c.P95Micros, b.P95Micros))
+		case c.AllocsPerOp > b.AllocsPerOp*(1+allocs) && c.AllocsPerOp-b.AllocsPerOp >= 1:
+			regressed = append(regressed, fmt.Sprintf("%s: %.0f allocs/op, baseline %.0f", s.Name, c.AllocsPerOp, b.AllocsPerOp))
+		}
+	}
+	return regressed
+}
+
+// printPerfResults prints the measures next to their baseline
+func printPerfResults(w io.Writer, base, current map[string]PerfResult) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SCENARIO\tP95\tBASELINE\tALLOCS/OP\tBASELINE\tBYTES/OP")
+	for _, s := range perfScenarios {
+		c := current[s.Name]
+		p95, allocs := "-", "-"
+		if b, ok := base[s.Name]; ok {
+			p95, allocs = fmt.Sprintf("%.0fµs", b.P95Micros), fmt.Sprintf("%.0f", b.AllocsPerOp)
+		}
+		fmt.Fprintf(tw, "%s\t%.0fµs\t%s\t%.0f\t%s\t%.0f\n", s.Name, c.P95Micros, p95, c.AllocsPerOp, allocs, c.BytesPerOp)
+	}
+	return tw.Flush()
+}
+
+// runPerfcheck is the perfcheck command: it times the scenarios over the
+// generated dataset and fails when one regressed against the baseline, or
+// stores a new baseline with -update
+func runPerfcheck(args []string) error {
+	fset := flag.NewFlagSet("perfcheck", flag.ContinueOnError)
+	baselinePath := fset.String("baseline", filepath.Join("perf", "baseline.json"), "baseline file")
+	n := fset.Int("n", 500, "requests timed per scenario")
+	latency := fset.Float64("latency", 0.25, "tolerated p95 latency growth, as a share of the baseline")
+	allocs := fset.Float64("allocs", 0.10, "tolerated allocation growth, as a share of the baseline")
+	floor := fset.Duration("latency-floor", 100*time.Microsecond, "p95 latency growth always tolerated")
+	update := fset.Bool("update", false, "store the measures as the new baseline")
+	data := fset.String("data", "", "keep the generated dataset in this directory")
+	langArg := langFlag(fset)
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+	lang, err := checkLang(*langArg)
+	if err != nil {
+		return err
+	}
+	if *n < 20 {
+		return errors.New("perfcheck: -n must be at least 20")
+	}
+
+	dir := *data
+	if dir == "" {
+		if dir, err = os.MkdirTemp("", "perfcheck"); err != nil {
+			return err
+		}
+		defer os.RemoveAll(dir)
+	}
+	if err := loadBenchData(dir); err != nil {
+		return err
+	}
+	current, err := runPerfScenarios(*n)
+	if err != nil {
+		return err
+	}
+
+	if *update {
+		if err := printPerfResults(os.Stdout, nil, current); err != nil {
+			return err
+		}
+		out, err := json.MarshalIndent(PerfBaseline{Dataset: benchDataset(), Scenarios: current}, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(*baselinePath), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(*baselinePath, append(out, '\n'), 0644); err != nil {
+			return err
+		}
+		fmt.Println(translate(lang, "cli.perfcheck.updated", len(current), *baselinePath))
+		return nil
+	}
+
+	raw, err := os.ReadFile(*baselinePath)
+	if err != nil {
+		return fmt.Errorf("read baseline: %w", err)
+	}
+	var base PerfBaseline
+	if err := json.Unmarshal(raw, &base); err != nil {
+		return fmt.Errorf("parse %s: %w", *baselinePath, err)
+	}
+	if base.Dataset != benchDataset() {
+		return fmt.Errorf("%s was measured on another dataset (%s), store a new one with -update", *baselinePath, base.Dataset)
+	}
+	if err := printPerfResults(os.Stdout, base.Scenarios, current); err != nil {
+		return err
+	}
+	if regressed := perfRegressions(base.Scenarios, current, *latency, *allocs, *floor); len(regressed) > 0 {
+		for _, r := range regressed {
+			fmt.Println(r)
+		}
+		return errors.New(translate(lang, "cli.perfcheck.regressed", len(regressed), len(current)))
+	}
+	fmt.Println(translate(lang, "cli.perfcheck.ok", len(current)))
+	return nil
+}
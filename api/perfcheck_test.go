@@ -0,0 +1,90 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPerfRegressions(t *testing.T) {
+	base := map[string]PerfResult{
+		"week": {P95Micros: 1000, AllocsPerOp: 50},
+		"team": {P95Micros: 1000, AllocsPerOp: 50},
+		"top":  {P95Micros: 20, AllocsPerOp: 2},
+	}
+	current := map[string]PerfResult{
+		"week":    {P95Micros: 1400, AllocsPerOp: 50},  // slower
+		"team":    {P95Micros: 1100, AllocsPerOp: 60},  // allocates more
+		"top":     {P95Micros: 60, AllocsPerOp: 2.5},   // noise
+		"matchup": {P95Micros: 9000, AllocsPerOp: 900}, // no baseline
+	}
+	got := perfRegressions(base, current, 0.25, 0.10, 100*time.Microsecond)
+	want := []string{"week: p95 1400µs, baseline 1000µs", "team: 60 allocs/op, baseline 50"}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("expected %q, got %q", want[i], got[i])
+		}
+	}
+}
+
+func TestRunPerfcheck(t *testing.T) {
+	dir := t.TempDir()
+	useTestStore(t, dir)
+	baseline := filepath.Join(dir, "perf", "baseline.json")
+
+	if err := runPerfcheck([]string{"-update", "-n", "20", "-baseline", baseline, "-data", filepath.Join(dir, "data")}); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := os.ReadFile(baseline)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var base PerfBaseline
+	if err := json.Unmarshal(raw, &base); err != nil {
+		t.Fatal(err)
+	}
+	if base.Dataset != benchDataset() || len(base.Scenarios) != len(perfScenarios) {
+		t.Errorf("unexpected baseline %+v", base)
+	}
+	for name, res := range base.Scenarios {
+		if res.P95Micros <= 0 || res.AllocsPerOp <= 0 {
+			t.Errorf("expected measures for %s, got %+v", name, res)
+		}
+	}
+
+	base.Dataset = "other"
+	raw, _ = json.Marshal(base)
+	os.WriteFile(baseline, raw, 0644)
+	if err := runPerfcheck([]string{"-n", "20", "-baseline", baseline}); err == nil {
+		t.Error("expected a baseline of another dataset to be refused")
+	}
+}
+
+// BenchmarkScenarios runs the perfcheck scenarios with go test -bench
+func BenchmarkScenarios(b *testing.B) {
+	dir := b.TempDir()
+	oldStore := dataStore
+	b.Cleanup(func() { dataStore = oldStore })
+	if err := loadBenchData(dir); err != nil {
+		b.Fatal(err)
+	}
+	mux := perfMux()
+	for _, s := range perfScenarios {
+		b.Run(s.Name, func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				rec := httptest.NewRecorder()
+				mux.ServeHTTP(rec, httptest.NewRequest("GET", s.Path, nil))
+				if rec.Code != http.StatusOK {
+					b.Fatalf("%s: status %d", s.Path, rec.Code)
+				}
+			}
+		})
+	}
+}
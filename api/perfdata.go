@@ -0,0 +1,104 @@
+package api
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// The benchmark dataset is generated rather than committed: a few full
+// seasons of made-up games whose stats are drawn from the typical ranges
+// of the range struct tags, so the data looks like the published weeks
+// to the raters and the indexes. The same seed always yields the same
+// files, so timings stay comparable with a stored baseline.
+const (
+	benchFirstSeason = 2010
+	benchSeasons     = 4
+	benchWeeks       = 18
+	benchSeed        = 1
+)
+
+// benchTeams are the teams of the generated matchups, abbreviation then
+// full name
+var benchTeams = [][2]string{
+	{"ARI", "Arizona Cardinals"}, {"ATL", "Atlanta Falcons"}, {"BAL", "Baltimore Ravens"}, {"BUF", "Buffalo Bills"},
+	{"CAR", "Carolina Panthers"}, {"CHI", "Chicago Bears"}, {"CIN", "Cincinnati Bengals"}, {"CLE", "Cleveland Browns"},
+	{"DAL", "Dallas Cowboys"}, {"DEN", "Denver Broncos"}, {"DET", "Detroit Lions"}, {"GB", "Green Bay Packers"},
+	{"HOU", "Houston Texans"}, {"IND", "Indianapolis Colts"}, {"JAX", "Jacksonville Jaguars"}, {"KC", "Kansas City Chiefs"},
+	{"LV", "Las Vegas Raiders"}, {"LAC", "Los Angeles Chargers"}, {"LAR", "Los Angeles Rams"}, {"MIA", "Miami Dolphins"},
+	{"MIN", "Minnesota Vikings"}, {"NE", "New England Patriots"}, {"NO", "New Orleans Saints"}, {"NYG", "New York Giants"},
+	{"NYJ", "New York Jets"}, {"PHI", "Philadelphia Eagles"}, {"PIT", "Pittsburgh Steelers"}, {"SF", "San Francisco 49ers"},
+	{"SEA", "Seattle Seahawks"}, {"TB", "Tampa Bay Buccaneers"}, {"TEN", "Tennessee Titans"}, {"WSH", "Washington Commanders"},
+}
+
+// generateBenchData writes the benchmark dataset to dir as
+// {year}/{week}.json files, every team playing each week
+func generateBenchData(dir string) error {
+	r := rand.New(rand.NewPCG(benchSeed, benchSeed))
+	for year := benchFirstSeason; year < benchFirstSeason+benchSeasons; year++ {
+		yearDir := filepath.Join(dir, strconv.Itoa(year))
+		if err := os.MkdirAll(yearDir, 0755); err != nil {
+			return err
+		}
+		for week := 1; week <= benchWeeks; week++ {
+			order := r.Perm(len(benchTeams))
+			games := make([]GameStats, 0, len(order)/2)
+			for i := 0; i+1 < len(order); i += 2 {
+				away, home := benchTeams[order[i]], benchTeams[order[i+1]]
+				g := GameStats{
+					ID:             fmt.Sprintf("%d%02d%02d", year, week, i/2),
+					FullName:       away[1] + " at " + home[1],
+					ShortName:      away[0] + " @ " + home[0],
+					MatchupQuality: strconv.FormatFloat(20+75*r.Float64(), 'f', 1, 64),
+				}
+				fillTypicalStats(r, reflect.ValueOf(&g).Elem())
+				games = append(games, g)
+			}
+			data, err := json.Marshal(games)
+			if err != nil {
+				return err
+			}
+			if err := os.WriteFile(filepath.Join(yearDir, strconv.Itoa(week)+".json"), data, 0644); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// fillTypicalStats sets every float field of v that has a range tag to a
+// random value in that range, whole numbers for counts
+func fillTypicalStats(r *rand.Rand, v reflect.Value) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f, fv := t.Field(i), v.Field(i)
+		if f.Type.Kind() == reflect.Struct {
+			fillTypicalStats(r, fv)
+			continue
+		}
+		if f.Type.Kind() != reflect.Float64 {
+			continue
+		}
+		lo, hi, ok := strings.Cut(f.Tag.Get("range"), "-")
+		if !ok {
+			continue
+		}
+		min, err1 := strconv.ParseFloat(lo, 64)
+		max, err2 := strconv.ParseFloat(hi, 64)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		x := min + (max-min)*r.Float64()
+		if f.Tag.Get("unit") == "count" || f.Tag.Get("unit") == "points" {
+			x = math.Round(x)
+		} else {
+			x = math.Round(x*1000) / 1000
+		}
+		fv.SetFloat(x)
+	}
+}
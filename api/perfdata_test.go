@@ -0,0 +1,44 @@
+package api
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGenerateBenchData(t *testing.T) {
+	a, b := t.TempDir(), t.TempDir()
+	if err := generateBenchData(a); err != nil {
+		t.Fatal(err)
+	}
+	if err := generateBenchData(b); err != nil {
+		t.Fatal(err)
+	}
+
+	first, _ := os.ReadFile(filepath.Join(a, "2010", "1.json"))
+	again, _ := os.ReadFile(filepath.Join(b, "2010", "1.json"))
+	if !bytes.Equal(first, again) {
+		t.Error("expected the same seed to generate the same files")
+	}
+
+	games, err := decodeWeekFile("2010/1.json", first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(games) != len(benchTeams)/2 {
+		t.Fatalf("expected every team to play, got %d games", len(games))
+	}
+	g := games[0]
+	if g.Offense.TotalPlays < 107 || g.Offense.TotalPlays > 132 || g.Offense.TotalPlays != float64(int(g.Offense.TotalPlays)) {
+		t.Errorf("expected a whole play count in the typical range, got %v", g.Offense.TotalPlays)
+	}
+	if p := processGame(raters[defaultAlgorithm], g); p.TotalRating == 0 {
+		t.Errorf("expected a rated game, got %+v", p)
+	}
+
+	entries, _ := os.ReadDir(filepath.Join(a, "2013"))
+	if len(entries) != benchWeeks {
+		t.Errorf("expected %d weeks in the last season, got %d", benchWeeks, len(entries))
+	}
+}
@@ -0,0 +1,249 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxPlanBytes bounds a PlanRequest
+const maxPlanBytes = 16 << 10
+
+// The length of a rewatch is estimated from the number of plays: a full
+// broadcast runs about three hours for 125 plays, the breaks between plays
+// growing with them, and a condensed replay keeps about 20 seconds a play.
+const (
+	typicalPlays       = 125
+	broadcastBase      = 40 * time.Minute
+	broadcastPerPlay   = 69 * time.Second
+	condensedPerPlay   = 21 * time.Second
+	familyFriendlyTime = 3 * time.Hour
+	maxPlanGames       = 10
+)
+
+// planArchetypes are the tags a plan spreads its games across, in order of
+// precedence when a game has several; games with none are "standard"
+var planArchetypes = []string{"comeback", "close", "defensiveScore", "blowout"}
+
+// PlanRequest is the body of POST /plan
+type PlanRequest struct {
+	Budget          string   `json:"budget"`                    // time available, e.g. 5h or 4h30m
+	Format          string   `json:"format,omitempty"`          // full (default) or condensed
+	MaxGames        int      `json:"maxGames,omitempty"`        // at most this many games, up to 10
+	BreakMinutes    int      `json:"breakMinutes,omitempty"`    // pause between two games
+	Seasons         []string `json:"seasons,omitempty"`         // only games of these seasons
+	Teams           []string `json:"teams,omitempty"`           // only games of these teams
+	ExcludeBlowouts bool     `json:"excludeBlowouts,omitempty"` // leave out the blowouts
+	FamilyFriendly  bool     `json:"familyFriendly,omitempty"`  // only family-friendly games
+	AllowRepeats    bool     `json:"allowRepeats,omitempty"`    // don't prefer distinct archetypes
+	ShowArchetypes  bool     `json:"showArchetypes,omitempty"`  // reveal the archetypes, which can spoil
+}
+
+// PlannedGame is one game of a RewatchPlan. Like the feeds it leaves out
+// the score, the plan being meant for watching.
+type PlannedGame struct {
+	SpoilerFreeGame
+	Order          int    `json:"order"`
+	StartMinute    int    `json:"startMinute"` // from the start of the plan
+	LengthMinutes  int    `json:"lengthMinutes"`
+	FamilyFriendly bool   `json:"familyFriendly"`
+	Archetype      string `json:"archetype,omitempty"`
+}
+
+// RewatchPlan is the response of POST /plan: games fitting the budget,
+// the best one last
+type RewatchPlan struct {
+	Format        string        `json:"format"`
+	BudgetMinutes int           `json:"budgetMinutes"`
+	TotalMinutes  int           `json:"totalMinutes"`
+	Games         []PlannedGame `json:"games"`
+}
+
+// rewatchLength estimates the time to rewatch g in full or condensed
+func rewatchLength(g *GameStats, condensed bool) time.Duration {
+	plays := g.Offense.TotalPlays
+	if plays <= 0 {
+		plays = typicalPlays
+	}
+	if condensed {
+		return time.Duration(plays) * condensedPerPlay
+	}
+	return broadcastBase + time.Duration(plays)*broadcastPerPlay
+}
+
+// gameArchetype is the first planArchetypes tag of p
+func gameArchetype(p ProcessedGameStats) string {
+	for _, name := range planArchetypes {
+		if queryTags[name].has(p) {
+			return name
+		}
+	}
+	return "standard"
+}
+
+// isFamilyFriendly reports whether p makes a family evening: a full
+// broadcast under three hours that is not a blowout
+func isFamilyFriendly(p ProcessedGameStats) bool {
+	return p.stats != nil && !p.Blowout && rewatchLength(p.stats, false) <= familyFriendlyTime
+}
+
+// planCandidate is a game considered for a plan
+type planCandidate struct {
+	game      ProcessedGameStats
+	length    time.Duration
+	archetype string
+}
+
+// validate checks req and returns its budget
+func (req PlanRequest) validate() (time.Duration, error) {
+	budget, err := time.ParseDuration(req.Budget)
+	if err != nil || budget < 30*time.Minute || budget > 24*time.Hour {
+		return 0, fmt.Errorf("budget must be a duration between 30m and 24h")
+	}
+	if req.Format != "" && req.Format != "full" && req.Format != "condensed" {
+		return 0, fmt.Errorf("format must be full or condensed")
+	}
+	if req.MaxGames < 0 || req.MaxGames > maxPlanGames {
+		return 0, fmt.Errorf("maxGames must be between 1 and %d", maxPlanGames)
+	}
+	if req.BreakMinutes < 0 || req.BreakMinutes > 120 {
+		return 0, fmt.Errorf("breakMinutes must be between 0 and 120")
+	}
+	return budget, nil
+}
+
+// planCandidates lists the games of the available seasons matching req,
+// the best rated first
+func planCandidates(ctx context.Context, rater Rater, req PlanRequest) []planCandidate {
+	teams := make([]string, 0, len(req.Teams))
+	for _, t := range req.Teams {
+		teams = append(teams, strings.ToLower(strings.TrimSpace(t)))
+	}
+	var candidates []planCandidate
+	for _, s := range availableSeasons() {
+		if len(req.Seasons) > 0 && !slices.Contains(req.Seasons, s.Season) {
+			continue
+		}
+		for _, g := range seasonGames(ctx, rater, s.Season) {
+			if req.ExcludeBlowouts && g.Blowout || req.FamilyFriendly && !isFamilyFriendly(g) {
+				continue
+			}
+			if len(teams) > 0 && !slices.ContainsFunc(teamKeys(*g.stats), func(k string) bool { return slices.Contains(teams, k) }) {
+				continue
+			}
+			candidates = append(candidates, planCandidate{
+				game:      g,
+				length:    rewatchLength(g.stats, req.Format == "condensed"),
+				archetype: gameArchetype(g),
+			})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].game.TotalRating > candidates[j].game.TotalRating })
+	return candidates
+}
+
+// buildPlan picks the best rated games fitting budget, breaks included.
+// Unless req allows repeats, a first pass takes one game per archetype and
+// a second fills the time left with the best of the rest.
+func buildPlan(ctx context.Context, rater Rater, req PlanRequest, budget time.Duration) RewatchPlan {
+	maxGames := req.MaxGames
+	if maxGames == 0 {
+		maxGames = maxPlanGames
+	}
+	pause := time.Duration(req.BreakMinutes) * time.Minute
+
+	var picked []planCandidate
+	var used time.Duration
+	taken := make(map[string]bool)
+	seen := make(map[string]bool)
+	pick := func(c planCandidate, distinct bool) {
+		key := c.game.Season + "/" + c.game.Week + "/" + c.game.ID
+		if len(picked) >= maxGames || taken[key] || distinct && seen[c.archetype] {
+			return
+		}
+		need := c.length
+		if len(picked) > 0 {
+			need += pause
+		}
+		if used+need > budget {
+			return
+		}
+		picked = append(picked, c)
+		used += need
+		taken[key], seen[c.archetype] = true, true
+	}
+	candidates := planCandidates(ctx, rater, req)
+	if !req.AllowRepeats {
+		for _, c := range candidates {
+			pick(c, true)
+		}
+	}
+	for _, c := range candidates {
+		pick(c, false)
+	}
+
+	// Build up to the best game
+	sort.SliceStable(picked, func(i, j int) bool { return picked[i].game.TotalRating < picked[j].game.TotalRating })
+	format := req.Format
+	if format == "" {
+		format = "full"
+	}
+	plan := RewatchPlan{Format: format, BudgetMinutes: int(budget.Minutes()), Games: []PlannedGame{}}
+	var start time.Duration
+	for i, c := range picked {
+		if i > 0 {
+			start += pause
+		}
+		g := PlannedGame{
+			SpoilerFreeGame: spoilerFreeGames([]ProcessedGameStats{c.game})[0],
+			Order:           i + 1,
+			StartMinute:     int(start.Minutes()),
+			LengthMinutes:   int(math.Round(c.length.Minutes())),
+			FamilyFriendly:  isFamilyFriendly(c.game),
+		}
+		if req.ShowArchetypes {
+			g.Archetype = c.archetype
+		}
+		plan.Games = append(plan.Games, g)
+		start += c.length
+	}
+	plan.TotalMinutes = int(math.Round(start.Minutes()))
+	return plan
+}
+
+// handlePlan builds a rewatch plan for the time budget and preferences of
+// the PlanRequest body
+func handlePlan(w http.ResponseWriter, r *http.Request) {
+	rater, qerr := raterFor(r)
+	if qerr != nil {
+		writeQueryError(w, r, qerr)
+		return
+	}
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPlanBytes))
+	if err != nil {
+		writeError(w, r, http.StatusRequestEntityTooLarge, "request body is too large")
+		return
+	}
+	var req PlanRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		writeError(w, r, http.StatusBadRequest, "request body must be a JSON object with a budget")
+		return
+	}
+	budget, err := req.validate()
+	if err != nil {
+		writeError(w, r, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(buildPlan(r.Context(), rater, req, budget)); err != nil {
+		writeError(w, r, http.StatusInternalServerError, "error encoding response")
+	}
+}
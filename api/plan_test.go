@@ -0,0 +1,117 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// setupPlanData writes a season of four games: two comebacks, a close game
+// and a blowout, of 120 plays each
+func setupPlanData(t *testing.T) string {
+	t.Helper()
+	var games []GameStats
+	for _, g := range []struct {
+		id, short, full string
+		margin, changes float64
+	}{
+		{"c1", "BUF @ KC", "Buffalo Bills at Kansas City Chiefs", 3, 2},
+		{"c2", "DET @ GB", "Detroit Lions at Green Bay Packers", 4, 1},
+		{"cl", "MIA @ NYJ", "Miami Dolphins at New York Jets", 2, 0},
+		{"bl", "SF @ SEA", "San Francisco 49ers at Seattle Seahawks", 35, 0},
+	} {
+		s := GameStats{ID: g.id, ShortName: g.short, FullName: g.full, MatchupQuality: "high"}
+		s.Offense.TotalPlays = 120
+		s.Offense.TotalPoints = 50
+		s.Scenario.MarginOfVictory = g.margin
+		s.Scenario.FourthQuarterLeadershipChange = g.changes
+		s.Scenario.LeadershipChange = g.changes + 1
+		games = append(games, s)
+	}
+	data, _ := json.Marshal(games)
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "2023"), 0755)
+	os.WriteFile(filepath.Join(dir, "2023", "1.json"), data, 0644)
+	return dir
+}
+
+func postPlan(t *testing.T, body string) (*httptest.ResponseRecorder, RewatchPlan) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	handlePlan(rec, httptest.NewRequest("POST", "/plan", strings.NewReader(body)))
+	var plan RewatchPlan
+	if rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), &plan); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return rec, plan
+}
+
+func TestRewatchLength(t *testing.T) {
+	g := &GameStats{}
+	if d := rewatchLength(g, false); d.Hours() < 2.5 || d.Hours() > 3.5 {
+		t.Errorf("expected about three hours for a typical broadcast, got %s", d)
+	}
+	if d := rewatchLength(g, true); d.Minutes() < 30 || d.Minutes() > 60 {
+		t.Errorf("expected under an hour for a condensed replay, got %s", d)
+	}
+}
+
+func TestHandlePlan(t *testing.T) {
+	useTestStore(t, setupPlanData(t))
+	preloadCache(dataStore)
+
+	// Full broadcasts of three hours: one fits in five hours
+	rec, plan := postPlan(t, `{"budget": "5h"}`)
+	if rec.Code != http.StatusOK || len(plan.Games) != 1 || plan.TotalMinutes > 300 || plan.Format != "full" {
+		t.Fatalf("expected one full game in 5h, got %d %+v", rec.Code, plan)
+	}
+	if plan.Games[0].Archetype != "" {
+		t.Error("expected the archetype to stay hidden")
+	}
+	if strings.Contains(rec.Body.String(), "marginOfVictory") {
+		t.Error("expected a spoiler-free plan")
+	}
+
+	// Condensed replays: distinct archetypes first, building up to the best
+	_, plan = postPlan(t, `{"budget": "2h", "format": "condensed", "maxGames": 2, "breakMinutes": 10, "showArchetypes": true}`)
+	if len(plan.Games) != 2 {
+		t.Fatalf("expected two condensed games, got %+v", plan)
+	}
+	first, second := plan.Games[0], plan.Games[1]
+	if first.Archetype == second.Archetype {
+		t.Errorf("expected distinct archetypes, got %s twice", first.Archetype)
+	}
+	if first.TotalRating > second.TotalRating || first.Order != 1 || second.Order != 2 {
+		t.Errorf("expected the best game last, got %+v", plan.Games)
+	}
+	if second.StartMinute < first.LengthMinutes+10-1 || plan.TotalMinutes > 120 {
+		t.Errorf("expected the break between the games, got %+v", plan)
+	}
+
+	_, plan = postPlan(t, `{"budget": "8h", "format": "condensed", "teams": ["nyj"], "excludeBlowouts": true}`)
+	if len(plan.Games) != 1 || plan.Games[0].ID != "cl" {
+		t.Errorf("expected the Jets game alone, got %+v", plan.Games)
+	}
+	_, plan = postPlan(t, `{"budget": "8h", "format": "condensed", "familyFriendly": true}`)
+	for _, g := range plan.Games {
+		if g.ID == "bl" || !g.FamilyFriendly {
+			t.Errorf("expected family-friendly games only, got %+v", g)
+		}
+	}
+
+	for body, status := range map[string]int{
+		`{"budget": "10m"}`:                      http.StatusUnprocessableEntity,
+		`{"budget": "5h", "format": "extended"}`: http.StatusUnprocessableEntity,
+		`{"budget": "5h", "maxGames": 50}`:       http.StatusUnprocessableEntity,
+		`[1, 2]`:                                 http.StatusBadRequest,
+	} {
+		if rec, _ := postPlan(t, body); rec.Code != status {
+			t.Errorf("%s: expected status %d, got %d", body, status, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Problem is an RFC 7807 problem details object, the body of every error
+// response
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+
+	// Extension members naming the offending query parameter
+	Param string `json:"param,omitempty"`
+	Value string `json:"value,omitempty"`
+
+	// RequestID is the X-Request-ID of the request, to quote when
+	// reporting the error
+	RequestID string `json:"requestId,omitempty"`
+}
+
+// newProblem returns the problem for status with a human-readable detail,
+// about the request r
+func newProblem(r *http.Request, status int, detail string) Problem {
+	return Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  r.URL.RequestURI(),
+		RequestID: requestID(r.Context()),
+	}
+}
+
+// writeError writes an application/problem+json response
+func writeError(w http.ResponseWriter, r *http.Request, status int, detail string) {
+	writeProblem(w, newProblem(r, status, detail))
+}
+
+func writeProblem(w http.ResponseWriter, p Problem) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
+
+// fallbackHandler answers the requests no route matched: 405 when the
+// path exists for another method, 404 otherwise. It is registered as "/"
+// so the mux never falls back to its plain-text errors.
+func fallbackHandler(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var allowed []string
+		for _, method := range []string{http.MethodGet, http.MethodPost} {
+			if method == r.Method {
+				continue
+			}
+			probe := r.Clone(r.Context())
+			probe.Method = method
+			if _, pattern := mux.Handler(probe); pattern != "" && pattern != "/" {
+				allowed = append(allowed, method)
+			}
+		}
+		if len(allowed) > 0 {
+			w.Header().Set("Allow", strings.Join(allowed, ", "))
+			writeError(w, r, http.StatusMethodNotAllowed, r.Method+" is not supported on "+r.URL.Path)
+			return
+		}
+		writeError(w, r, http.StatusNotFound, "no route for "+r.URL.Path)
+	})
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFallbackHandler(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /games/{year}/{week}", handleGamesYearWeek)
+	mux.Handle("/", fallbackHandler(mux))
+
+	tests := []struct {
+		method, url string
+		status      int
+	}{
+		{"GET", "/nope", http.StatusNotFound},
+		{"POST", "/games/2024/1", http.StatusMethodNotAllowed},
+		{"DELETE", "/nope", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.url, nil))
+
+		if rec.Code != tt.status {
+			t.Errorf("%s %s: expected %d, got %d", tt.method, tt.url, tt.status, rec.Code)
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != "application/problem+json" {
+			t.Errorf("%s %s: expected problem content type, got %q", tt.method, tt.url, ct)
+		}
+		var p Problem
+		if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+			t.Fatalf("%s %s: invalid problem body: %v", tt.method, tt.url, err)
+		}
+		if p.Status != tt.status || p.Title != http.StatusText(tt.status) || p.Instance != tt.url {
+			t.Errorf("%s %s: unexpected problem %+v", tt.method, tt.url, p)
+		}
+	}
+}
+
+func TestMissingWeekIsProblem(t *testing.T) {
+	useTestStore(t, setupTestData(t))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /games/{year}/{week}", handleGamesYearWeek)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/games/1999/1", nil))
+
+	var p Problem
+	if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+		t.Fatalf("expected a problem body, got %q", rec.Body.String())
+	}
+	if p.Status != http.StatusNotFound || p.Detail != "no data for week 1 of 1999" {
+		t.Errorf("unexpected problem %+v", p)
+	}
+}
+
+func TestFallbackAllowsPost(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /admin/cache/purge", handleCachePurge)
+	mux.Handle("/", fallbackHandler(mux))
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/admin/cache/purge", nil))
+	if rec.Code != http.StatusMethodNotAllowed || rec.Header().Get("Allow") != "POST" {
+		t.Errorf("expected 405 allowing POST, got %d %q", rec.Code, rec.Header().Get("Allow"))
+	}
+}
@@ -0,0 +1,233 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Bounds of a saved profile
+const (
+	maxProfileBytes   = 4 << 10
+	maxFavoriteTeams  = 32
+	maxTeamNameLength = 64
+)
+
+// Profile is the body of PUT /profile and the response of GET /profile:
+// the favorite teams that ?useProfile=true applies to the game lists as
+// ?teams=
+type Profile struct {
+	FavoriteTeams []string `json:"favoriteTeams"`
+}
+
+// profileBook holds the profile of each voter, named as for the votes,
+// saved to its repository after each change
+type profileBook struct {
+	mu       sync.Mutex
+	repo     Repository
+	profiles map[string]Profile
+}
+
+// profiles is the profile book of the server, persisted to the user data
+// repository
+var profiles = newProfileBook(newFileRepository(nil))
+
+func newProfileBook(repo Repository) *profileBook {
+	return &profileBook{repo: repo, profiles: make(map[string]Profile)}
+}
+
+// loadProfileBook reads the profiles saved in repo
+func loadProfileBook(repo Repository) (*profileBook, error) {
+	b := newProfileBook(repo)
+	docs, err := repo.Load(context.Background(), profilesCollection)
+	if err != nil {
+		return nil, err
+	}
+	for owner, doc := range docs {
+		var p Profile
+		if err := json.Unmarshal(doc, &p); err != nil {
+			return nil, fmt.Errorf("profile of %s: %w", owner, err)
+		}
+		b.profiles[owner] = p
+	}
+	return b, nil
+}
+
+// get returns the profile of owner, false when none was saved
+func (b *profileBook) get(owner string) (Profile, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	p, ok := b.profiles[owner]
+	return p, ok
+}
+
+// put saves p as the profile of owner, replacing a previous one
+func (b *profileBook) put(owner string, p Profile) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	if err := b.repo.Put(context.Background(), profilesCollection, owner, data); err != nil {
+		return err
+	}
+	b.profiles[owner] = p
+	return nil
+}
+
+// cleanFavoriteTeams trims the teams of a profile and drops the
+// duplicates, an error when the list is empty or a team could not be
+// passed as ?teams=
+func cleanFavoriteTeams(teams []string) ([]string, error) {
+	if len(teams) == 0 || len(teams) > maxFavoriteTeams {
+		return nil, fmt.Errorf("favoriteTeams must list 1 to %d teams", maxFavoriteTeams)
+	}
+	seen := make(map[string]bool)
+	cleaned := make([]string, 0, len(teams))
+	for _, team := range teams {
+		team = strings.TrimSpace(team)
+		if team == "" || len(team) > maxTeamNameLength || strings.Contains(team, ",") {
+			return nil, fmt.Errorf("invalid team %s: must be a name of at most %d bytes without commas", strconv.Quote(team), maxTeamNameLength)
+		}
+		if key := strings.ToLower(team); !seen[key] {
+			seen[key] = true
+			cleaned = append(cleaned, team)
+		}
+	}
+	return cleaned, nil
+}
+
+// handleGetProfile returns the profile of the request's voter
+func handleGetProfile(w http.ResponseWriter, r *http.Request) {
+	owner, err := requestVoter(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	p, ok := profiles.get(owner)
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "no saved profile")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(p); err != nil {
+		writeError(w, r, http.StatusInternalServerError, "error encoding response")
+	}
+}
+
+// handlePutProfile saves the profile of the request's voter, replacing
+// a previous one
+func handlePutProfile(w http.ResponseWriter, r *http.Request) {
+	owner, err := requestVoter(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxProfileBytes))
+	if err != nil {
+		writeError(w, r, http.StatusRequestEntityTooLarge, "request body is too large")
+		return
+	}
+	var p Profile
+	if err := json.Unmarshal(data, &p); err != nil {
+		writeError(w, r, http.StatusBadRequest, "request body must be a JSON object with favoriteTeams")
+		return
+	}
+	if p.FavoriteTeams, err = cleanFavoriteTeams(p.FavoriteTeams); err != nil {
+		writeError(w, r, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	if dryRun, qerr := dryRunRequested(r); qerr != nil {
+		writeQueryError(w, r, qerr)
+		return
+	} else if dryRun {
+		rep := newDryRunReport("save the profile of " + owner)
+		rep.writeFile(profiles.repo.Location(profilesCollection))
+		writeDryRun(w, r, rep)
+		return
+	}
+
+	if err := profiles.put(owner, p); err != nil {
+		log.Printf("Error: save profiles: %v", err)
+		writeError(w, r, http.StatusInternalServerError, "could not save the profile")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(p); err != nil {
+		writeError(w, r, http.StatusInternalServerError, "error encoding response")
+	}
+}
+
+// withProfile applies, on ?useProfile=true, the favorite teams of the
+// request's voter to the game list of next as ?teams=, unless the request
+// sets its own. The key is required then, like on PUT /profile, and the
+// response is private to it.
+func withProfile(next http.Handler) http.Handler {
+	applied := requireRole(roleRead, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		owner, err := requestVoter(r)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		p, ok := profiles.get(owner)
+		if !ok {
+			writeError(w, r, http.StatusNotFound, "no saved profile, save one with PUT /profile")
+			return
+		}
+		values := r.URL.Query()
+		values.Del("useProfile")
+		if values.Get("teams") == "" {
+			values.Set("teams", strings.Join(p.FavoriteTeams, ","))
+		}
+		r = r.Clone(r.Context())
+		r.URL.RawQuery = values.Encode()
+		next.ServeHTTP(&privateWriter{ResponseWriter: w}, r)
+	}))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch v := r.URL.Query().Get("useProfile"); v {
+		case "", "false":
+			next.ServeHTTP(w, r)
+		case "true":
+			applied.ServeHTTP(w, r)
+		default:
+			writeQueryError(w, r, &QueryError{Param: "useProfile", Value: v, Message: "must be true or false"})
+		}
+	})
+}
+
+// privateWriter keeps the shared caches from storing a response built
+// from the profile of a key. Unwrap lets http.ResponseController reach the
+// underlying writer.
+type privateWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (w *privateWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.Header().Set("Cache-Control", "private, no-cache")
+		w.Header().Add("Vary", "X-API-Key, X-Voter-ID")
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *privateWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *privateWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
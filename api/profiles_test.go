@@ -0,0 +1,124 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func useProfileBook(t *testing.T, path string) {
+	t.Helper()
+	old := profiles
+	b, err := loadProfileBook(newFileRepository(map[string]string{profilesCollection: path}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	profiles = b
+	t.Cleanup(func() { profiles = old })
+}
+
+func TestProfiles(t *testing.T) {
+	dir := setupTestData(t)
+	useTestStore(t, dir)
+	useAPIKeys(t, APIKey{Name: "frontend", Key: "read-key", Roles: []string{roleRead}})
+	path := filepath.Join(t.TempDir(), "profiles.json")
+	useProfileBook(t, path)
+
+	os.WriteFile(filepath.Join(dir, "2024", "3.json"), []byte(`[
+		{"id": "g1", "shortName": "A @ B", "fullName": "A at B"},
+		{"id": "g2", "shortName": "C @ D", "fullName": "C at D"}
+	]`), 0644)
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /profile", requireRole(roleRead, http.HandlerFunc(handleGetProfile)))
+	mux.Handle("PUT /profile", requireRole(roleRead, http.HandlerFunc(handlePutProfile)))
+	mux.Handle("GET /games/{year}/{week}", withProfile(http.HandlerFunc(handleGamesYearWeek)))
+	do := func(method, target, key, voter, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		if voter != "" {
+			req.Header.Set("X-Voter-ID", voter)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+	ids := func(rec *httptest.ResponseRecorder) []string {
+		var games []ProcessedGameStats
+		if err := json.Unmarshal(rec.Body.Bytes(), &games); err != nil {
+			t.Fatalf("failed to parse response %s: %v", rec.Body, err)
+		}
+		var ids []string
+		for _, g := range games {
+			ids = append(ids, g.ID)
+		}
+		return ids
+	}
+
+	for _, tt := range []struct {
+		key, voter string
+		status     int
+	}{
+		{"", "", http.StatusUnauthorized},
+		{"nope", "", http.StatusUnauthorized},
+		{"read-key", "", http.StatusNotFound},
+	} {
+		if rec := do("GET", "/games/2024/3?useProfile=true", tt.key, tt.voter, ""); rec.Code != tt.status {
+			t.Errorf("key %q: expected %d before a profile is saved, got %d", tt.key, tt.status, rec.Code)
+		}
+	}
+
+	for _, body := range []string{`{"favoriteTeams": []}`, `{"favoriteTeams": ["D", " "]}`, `{"favoriteTeams": ["C,D"]}`} {
+		if rec := do("PUT", "/profile", "read-key", "", body); rec.Code != http.StatusUnprocessableEntity {
+			t.Errorf("%s: expected 422, got %d", body, rec.Code)
+		}
+	}
+	if rec := do("PUT", "/profile", "read-key", "", `not json`); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a body that is not JSON, got %d", rec.Code)
+	}
+	rec := do("PUT", "/profile", "read-key", "", `{"favoriteTeams": [" D ", "d"]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected the profile to be saved, got %d: %s", rec.Code, rec.Body)
+	}
+	rec = do("GET", "/profile", "read-key", "", "")
+	var saved Profile
+	if err := json.Unmarshal(rec.Body.Bytes(), &saved); err != nil || !slices.Equal(saved.FavoriteTeams, []string{"D"}) {
+		t.Errorf("expected the trimmed teams without duplicates, got %s", rec.Body)
+	}
+
+	rec = do("GET", "/games/2024/3?useProfile=true", "read-key", "", "")
+	if got := ids(rec); !slices.Equal(got, []string{"g2"}) {
+		t.Errorf("expected the games of the favorite teams, got %v", got)
+	}
+	if cc := rec.Header().Get("Cache-Control"); cc != "private, no-cache" {
+		t.Errorf("expected a private response, got Cache-Control %q", cc)
+	}
+	// An explicit ?teams= wins over the profile
+	if got := ids(do("GET", "/games/2024/3?useProfile=true&teams=A", "read-key", "", "")); !slices.Equal(got, []string{"g1"}) {
+		t.Errorf("expected the games of ?teams=, got %v", got)
+	}
+	if got := ids(do("GET", "/games/2024/3?useProfile=false", "", "", "")); len(got) != 2 {
+		t.Errorf("expected every game without the profile, got %v", got)
+	}
+	if rec := do("GET", "/games/2024/3?useProfile=yes", "read-key", "", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid useProfile, got %d", rec.Code)
+	}
+	// Each voter of a key has their own profile
+	if rec := do("GET", "/profile", "read-key", "u1", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("expected another voter to have no profile, got %d", rec.Code)
+	}
+
+	b, err := loadProfileBook(newFileRepository(map[string]string{profilesCollection: path}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p, ok := b.get("frontend"); !ok || !slices.Equal(p.FavoriteTeams, []string{"D"}) {
+		t.Errorf("expected the profile to be saved to %s, got %+v", path, p)
+	}
+}
@@ -0,0 +1,221 @@
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jjway/rewatchableGamesApi-go/store"
+)
+
+// publishTimeout bounds one full publication
+const publishTimeout = 10 * time.Minute
+
+// publisher renders every public route after each ingestion and uploads
+// the gzipped responses under a new version prefix of a bucket, so a
+// static mirror of the API can be served from the bucket alone:
+//
+//	20240908T120000Z/games/2024/1.json.gz
+//	20240908T120000Z/v2/games/2024/1.json.gz
+//	latest.json
+//
+// latest.json names the last complete version and is written last.
+// Publications requested while one runs are coalesced into a single rerun.
+type publisher struct {
+	dest    *store.Object
+	handler http.Handler
+
+	mu      sync.Mutex
+	running bool
+	pending bool
+}
+
+// PublishManifest is the latest.json document of a bucket
+type PublishManifest struct {
+	Version     string    `json:"version"`
+	PublishedAt time.Time `json:"publishedAt"`
+	Objects     int       `json:"objects"`
+}
+
+// snapshotPublisher is set from PUBLISH_URL; nil disables publishing
+var snapshotPublisher *publisher
+
+func newPublisher(dest *store.Object, handler http.Handler) *publisher {
+	return &publisher{dest: dest, handler: handler}
+}
+
+// schedulePublish starts a publication after a data change
+func schedulePublish() {
+	if snapshotPublisher != nil {
+		snapshotPublisher.schedule()
+	}
+}
+
+func (p *publisher) schedule() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.running {
+		p.pending = true
+		return
+	}
+	p.running = true
+	go p.loop()
+}
+
+func (p *publisher) loop() {
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+		m, err := p.publish(ctx)
+		cancel()
+		if err != nil {
+			log.Printf("Error: publish snapshots: %v", err)
+		} else {
+			log.Printf("Published %d snapshots as version %s", m.Objects, m.Version)
+		}
+
+		p.mu.Lock()
+		if !p.pending {
+			p.running = false
+			p.mu.Unlock()
+			return
+		}
+		p.pending = false
+		p.mu.Unlock()
+	}
+}
+
+// publish renders and uploads every route under a new version
+func (p *publisher) publish(ctx context.Context) (PublishManifest, error) {
+	m := PublishManifest{PublishedAt: clock.Now().UTC()}
+	m.Version = m.PublishedAt.Format("20060102T150405Z")
+
+	for _, route := range publishRoutes() {
+		if err := ctx.Err(); err != nil {
+			return m, err
+		}
+		body, ok, err := p.render(ctx, route)
+		if err != nil {
+			return m, fmt.Errorf("render %s: %w", route, err)
+		}
+		if !ok {
+			continue
+		}
+		if err := p.dest.Put(ctx, m.Version+route+".json.gz", body, "application/json", "gzip"); err != nil {
+			return m, err
+		}
+		m.Objects++
+	}
+
+	latest, err := json.Marshal(m)
+	if err != nil {
+		return m, err
+	}
+	return m, p.dest.Put(ctx, "latest.json", latest, "application/json", "")
+}
+
+// render serves route through the handler and returns the gzipped body of
+// a 200 response
+func (p *publisher) render(ctx context.Context, route string) ([]byte, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, route, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	rec := &bufferedResponse{header: make(http.Header)}
+	p.handler.ServeHTTP(rec, req)
+	if rec.status != 0 && rec.status != http.StatusOK {
+		return nil, false, nil
+	}
+
+	var buf bytes.Buffer
+	gz, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	gz.Write(rec.body.Bytes())
+	if err := gz.Close(); err != nil {
+		return nil, false, err
+	}
+	return buf.Bytes(), true, nil
+}
+
+// publishRoutes lists the routes of the static mirror: the season, week,
+// top and team lists of every cached season, for every algorithm
+func publishRoutes() []string {
+	base := []string{"/seasons", "/games/top"}
+	for _, s := range availableSeasons() {
+		base = append(base,
+			"/seasons/"+s.Season+"/games",
+			"/bulk/"+s.Season,
+			"/games/"+s.Season+"/top",
+		)
+		for _, w := range s.Weeks {
+			base = append(base, "/games/"+s.Season+"/"+w.Week)
+		}
+	}
+	for _, team := range teamAbbreviations() {
+		base = append(base, "/teams/"+team+"/games")
+	}
+
+	var routes []string
+	for _, version := range algorithmNames() {
+		prefix := ""
+		if version != defaultAlgorithm {
+			prefix = "/" + version
+		}
+		for _, route := range base {
+			routes = append(routes, prefix+route)
+		}
+	}
+	return routes
+}
+
+// teamAbbreviations returns the abbreviations of the indexed teams, taken
+// from the short names of their games
+func teamAbbreviations() []string {
+	seen := make(map[string]bool)
+	teamIndexMu.RLock()
+	for _, games := range teamIndex {
+		for _, g := range games {
+			for _, team := range splitMatchup(g.ShortName) {
+				seen[team] = true
+			}
+		}
+	}
+	teamIndexMu.RUnlock()
+
+	teams := make([]string, 0, len(seen))
+	for team := range seen {
+		if !strings.ContainsAny(team, " /") {
+			teams = append(teams, team)
+		}
+	}
+	sort.Strings(teams)
+	return teams
+}
+
+// bufferedResponse is an http.ResponseWriter keeping the response in
+// memory
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header { return b.header }
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	if b.status == 0 {
+		b.status = status
+	}
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	if b.status == 0 {
+		b.status = http.StatusOK
+	}
+	return b.body.Write(p)
+}
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jjway/rewatchableGamesApi-go/store"
+)
+
+func TestPublishSnapshots(t *testing.T) {
+	useFakeClock(t)
+	useTestStore(t, setupTestData(t))
+	teamIndexMu.Lock()
+	teamIndex = make(map[string][]ProcessedGameStats)
+	teamIndexMu.Unlock()
+	preloadCache(dataStore)
+
+	var mu sync.Mutex
+	var keys []string
+	objects := make(map[string][]byte)
+	bucket := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut || r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		data, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		keys = append(keys, r.URL.Path)
+		objects[r.URL.Path] = data
+		mu.Unlock()
+	}))
+	defer bucket.Close()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /games/{year}/{week}", handleGamesYearWeek)
+	mux.HandleFunc("GET /teams/{team}/games", handleTeamGames)
+	mux.HandleFunc("GET /seasons", handleSeasons)
+	for _, version := range algorithmNames() {
+		mux.Handle("GET /"+version+"/", withRater(raters[version], mux))
+	}
+
+	p := newPublisher(store.NewObject(bucket.URL+"/mirror", store.Credentials{Token: "secret"}), mux)
+	m, err := p.publish(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Version != "20240908T120000Z" {
+		t.Errorf("unexpected version %q", m.Version)
+	}
+	if keys[len(keys)-1] != "/mirror/latest.json" {
+		t.Errorf("expected latest.json to be written last, got %v", keys)
+	}
+	// Unregistered routes answer 404 and are skipped
+	if m.Objects != len(keys)-1 || m.Objects != 2*5 {
+		t.Errorf("expected 5 routes per algorithm, got %d objects: %v", m.Objects, keys)
+	}
+
+	for _, key := range []string{"/mirror/20240908T120000Z/games/2024/1.json.gz", "/mirror/20240908T120000Z/v2/teams/a/games.json.gz"} {
+		gz, err := gzip.NewReader(bytes.NewReader(objects[key]))
+		if err != nil {
+			t.Fatalf("%s: %v", key, err)
+		}
+		body, _ := io.ReadAll(gz)
+		var games []ProcessedGameStats
+		if err := json.Unmarshal(body, &games); err != nil || len(games) == 0 || games[0].ID != "game1" {
+			t.Errorf("%s: unexpected snapshot %s", key, body)
+		}
+	}
+
+	var latest PublishManifest
+	if err := json.Unmarshal(objects["/mirror/latest.json"], &latest); err != nil || latest.Version != m.Version {
+		t.Errorf("unexpected latest.json %s", objects["/mirror/latest.json"])
+	}
+}
+
+func TestPublishRoutes(t *testing.T) {
+	useTestStore(t, setupTestData(t))
+	teamIndexMu.Lock()
+	teamIndex = make(map[string][]ProcessedGameStats)
+	teamIndexMu.Unlock()
+	preloadCache(dataStore)
+
+	routes := strings.Join(publishRoutes(), " ")
+	for _, want := range []string{"/seasons", "/games/top", "/seasons/2024/games", "/bulk/2024", "/games/2024/top", "/games/2024/2", "/teams/b/games", "/v2/games/2024/1"} {
+		if !strings.Contains(routes+" ", want+" ") {
+			t.Errorf("expected route %s in %s", want, routes)
+		}
+	}
+	if strings.Contains(routes, "team b") {
+		t.Errorf("expected only team abbreviations, got %s", routes)
+	}
+}
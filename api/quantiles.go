@@ -0,0 +1,80 @@
+package api
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ratingQuantiles holds the sorted TotalRatings of the cached games, keyed
+// by algorithm and season ("" for all time). They are computed on first
+// use and dropped whenever a week file is reloaded or evicted.
+var (
+	ratingQuantiles   = make(map[string][]float64)
+	ratingQuantilesMu sync.Mutex
+)
+
+// sortedRatings returns the ascending TotalRatings of every cached game of
+// season, or of all seasons when season is empty, rated with algo
+func sortedRatings(algo, season string) []float64 {
+	key := algo + "/" + season
+	ratingQuantilesMu.Lock()
+	defer ratingQuantilesMu.Unlock()
+	if ratings, ok := ratingQuantiles[key]; ok {
+		return ratings
+	}
+
+	rater := raters[algo]
+	if rater == nil {
+		rater = raters[defaultAlgorithm]
+	}
+
+	var ratings []float64
+	for name := range cache.weekCounts() {
+		if season != "" && !strings.HasPrefix(name, season+"/") {
+			continue
+		}
+		games, _ := catalogGames(name)
+		for _, g := range games {
+			if g.ID != "" {
+				ratings = append(ratings, rater.Rate(g).TotalRating)
+			}
+		}
+	}
+
+	sort.Float64s(ratings)
+	ratingQuantiles[key] = ratings
+	return ratings
+}
+
+// ratingQuantile returns the TotalRating at percentile pct (0-100) of
+// season, or of all time when season is empty, by the nearest-rank method.
+// ok is false when no games are cached.
+func ratingQuantile(algo, season string, pct float64) (float64, bool) {
+	ratings := sortedRatings(algo, season)
+	if len(ratings) == 0 {
+		return 0, false
+	}
+	rank := int(math.Ceil(pct / 100 * float64(len(ratings))))
+	if rank < 1 {
+		rank = 1
+	}
+	return ratings[rank-1], true
+}
+
+// quantileResponses is the response cache bucket of percentile-filtered
+// responses
+const quantileResponses = "quantiles"
+
+// invalidateQuantiles drops the precomputed quantiles, and the responses
+// filtered with them, after a data change
+func invalidateQuantiles() {
+	ratingQuantilesMu.Lock()
+	clear(ratingQuantiles)
+	ratingQuantilesMu.Unlock()
+	seasonMomentsMu.Lock()
+	clear(seasonMoments)
+	seasonMomentsMu.Unlock()
+	responses.invalidate(quantileResponses)
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/jjway/rewatchableGamesApi-go/store"
+)
+
+// useQuantileCache fills the cache with seasons whose games have
+// scenario ratings 1..n, so their v1 TotalRatings are 1..n as well
+func useQuantileCache(t *testing.T, seasons map[string]int) {
+	t.Helper()
+	useTestStore(t, t.TempDir())
+
+	for season, n := range seasons {
+		games := make([]GameStats, n)
+		for i := range games {
+			games[i].ID = season + "-" + strconv.Itoa(i+1)
+			games[i].Scenario.ScenarioRating = float64(i + 1)
+		}
+		setCached(store.WeekFile(season, "1"), games, 0, "")
+	}
+}
+
+func TestRatingQuantile(t *testing.T) {
+	useQuantileCache(t, map[string]int{"2023": 10, "2024": 20})
+
+	tests := []struct {
+		season string
+		pct    float64
+		want   float64
+	}{
+		{"", 50, 8},
+		{"", 90, 17},
+		{"", 100, 20},
+		{"", 0, 1},
+		{"2023", 90, 9},
+		{"2024", 50, 10},
+	}
+	for _, tt := range tests {
+		got, ok := ratingQuantile("v1", tt.season, tt.pct)
+		if !ok || got != tt.want {
+			t.Errorf("season %q p%v: got %v (%v), want %v", tt.season, tt.pct, got, ok, tt.want)
+		}
+	}
+
+	// Reloading a week recomputes the quantiles
+	setCached(store.WeekFile("2023", "1"), nil, 0, "")
+	if got, _ := ratingQuantile("v1", "", 100); got != 20 {
+		t.Errorf("expected all-time max 20 after reload, got %v", got)
+	}
+	if _, ok := ratingQuantile("v1", "2023", 50); ok {
+		t.Error("expected no quantiles for an emptied season")
+	}
+}
+
+func TestMinPercentileFilter(t *testing.T) {
+	useQuantileCache(t, map[string]int{"2023": 10, "2024": 20})
+
+	games := []ProcessedGameStats{
+		{ID: "a", Season: "2023", TotalRating: 9, Algorithm: "v1"},
+		{ID: "b", Season: "2024", TotalRating: 19, Algorithm: "v1"},
+		{ID: "c", Season: "2024", TotalRating: 12, Algorithm: "v1"},
+	}
+	for query, want := range map[string]string{
+		"minPercentile=90&sort=totalRating":                        "b",
+		"minPercentile=90&percentileScope=season&sort=totalRating": "ba",
+		"minPercentile=0&sort=totalRating":                         "bca",
+	} {
+		values, _ := url.ParseQuery(query)
+		q, err := parseGameQuery(values)
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %v", query, err)
+		}
+		if got := ids(q.apply(append([]ProcessedGameStats(nil), games...))); got != want {
+			t.Errorf("%q: got %s, want %s", query, got, want)
+		}
+	}
+
+	for _, query := range []string{"minPercentile=101", "minPercentile=x", "minPercentile=50&percentileScope=week"} {
+		values, _ := url.ParseQuery(query)
+		if _, err := parseGameQuery(values); err == nil {
+			t.Errorf("%q: expected an error", query)
+		}
+	}
+}
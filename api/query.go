@@ -0,0 +1,347 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ratingFields are the ProcessedGameStats fields usable for sorting and
+// min* filters, keyed by their JSON name
+var ratingFields = map[string]func(ProcessedGameStats) float64{
+	"offensiveRating":   func(p ProcessedGameStats) float64 { return p.OffensiveRating },
+	"defensiveBigPlays": func(p ProcessedGameStats) float64 { return p.DefensiveBigPlays },
+	"scenarioRating":    func(p ProcessedGameStats) float64 { return p.ScenarioRating },
+	"totalRating":       func(p ProcessedGameStats) float64 { return p.TotalRating },
+	// Games without votes sort after those with
+	"communityRating": func(p ProcessedGameStats) float64 {
+		if p.CommunityRating == nil {
+			return 0
+		}
+		return *p.CommunityRating
+	},
+}
+
+// QueryError describes an invalid query parameter
+type QueryError struct {
+	Param   string
+	Value   string
+	Message string
+}
+
+func (e *QueryError) Error() string {
+	return e.Param + ": " + e.Message
+}
+
+// writeQueryError writes err as a 400 problem naming the parameter
+func writeQueryError(w http.ResponseWriter, r *http.Request, err *QueryError) {
+	p := newProblem(r, http.StatusBadRequest, err.Message)
+	p.Param, p.Value = err.Param, err.Value
+	writeProblem(w, p)
+}
+
+// gameFilter is one named step of the filter pipeline
+type gameFilter struct {
+	name string
+	keep func(ProcessedGameStats) bool
+}
+
+// sortKey is one key of a compound sort
+type sortKey struct {
+	field string
+	desc  bool
+	get   func(ProcessedGameStats) float64
+}
+
+// gameQuery is the parsed sort and filter parameters of a list request
+type gameQuery struct {
+	sortKeys []sortKey
+	filters  []gameFilter
+
+	// percentile is set when a filter or the normalization compares
+	// against the ratings of every cached week
+	percentile bool
+
+	// normalize is the ?normalize= mode
+	normalize string
+}
+
+// parseGameQuery parses ?sort= (see parseSortKeys), ?order=, ?min<Field>=, ?matchupQuality=,
+// ?minPercentile= (of all time, or of the game's season with
+// ?percentileScope=season), ?excludeBlowouts=, ?teams=, a
+// comma-separated list of teams matched with OR semantics, ?q= (see
+// queryGrammar) and ?normalize=
+func parseGameQuery(values url.Values) (gameQuery, *QueryError) {
+	var q gameQuery
+
+	normalize, qerr := parseNormalize(values)
+	if qerr != nil {
+		return q, qerr
+	}
+	q.normalize, q.percentile = normalize, normalize != ""
+
+	// ?order= is the direction of the keys that do not set their own
+	desc := true
+	switch v := values.Get("order"); v {
+	case "", "desc":
+	case "asc":
+		desc = false
+	default:
+		return q, &QueryError{Param: "order", Value: v, Message: "must be asc or desc"}
+	}
+
+	keys, qerr := parseSortKeys(values.Get("sort"), desc)
+	if qerr != nil {
+		return q, qerr
+	}
+	q.sortKeys = keys
+
+	for _, field := range sortedFieldNames() {
+		param := "min" + strings.ToUpper(field[:1]) + field[1:]
+		v := values.Get(param)
+		if v == "" {
+			continue
+		}
+		min, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return q, &QueryError{Param: param, Value: v, Message: "must be a number"}
+		}
+		get := ratingFields[field]
+		q.filters = append(q.filters, gameFilter{
+			name: param,
+			keep: func(p ProcessedGameStats) bool { return get(p) >= min },
+		})
+	}
+
+	if v := values.Get("matchupQuality"); v != "" {
+		q.filters = append(q.filters, gameFilter{
+			name: "matchupQuality",
+			keep: func(p ProcessedGameStats) bool { return strings.EqualFold(p.MatchupQuality, v) },
+		})
+	}
+
+	if v := values.Get("minPercentile"); v != "" {
+		pct, err := strconv.ParseFloat(v, 64)
+		if err != nil || pct < 0 || pct > 100 {
+			return q, &QueryError{Param: "minPercentile", Value: v, Message: "must be a number between 0 and 100"}
+		}
+		scope := values.Get("percentileScope")
+		if scope != "" && scope != "all" && scope != "season" {
+			return q, &QueryError{Param: "percentileScope", Value: scope, Message: "must be all or season"}
+		}
+		q.percentile = true
+		q.filters = append(q.filters, gameFilter{
+			name: "minPercentile",
+			keep: func(p ProcessedGameStats) bool {
+				season := ""
+				if scope == "season" {
+					season = p.Season
+				}
+				min, ok := ratingQuantile(p.Algorithm, season, pct)
+				return ok && p.TotalRating >= min
+			},
+		})
+	}
+
+	switch v := values.Get("excludeBlowouts"); v {
+	case "", "false":
+	case "true":
+		q.filters = append(q.filters, gameFilter{
+			name: "excludeBlowouts",
+			keep: func(p ProcessedGameStats) bool { return !p.Blowout },
+		})
+	default:
+		return q, &QueryError{Param: "excludeBlowouts", Value: v, Message: "must be true or false"}
+	}
+
+	if f, ok, qerr := parseTeams(values); qerr != nil {
+		return q, qerr
+	} else if ok {
+		q.filters = append(q.filters, f)
+	}
+
+	if v := values.Get("q"); v != "" {
+		filters, qerr := parseQueryDSL(v)
+		if qerr != nil {
+			return q, qerr
+		}
+		q.filters = append(q.filters, filters...)
+	}
+
+	return q, nil
+}
+
+// parseTeams parses ?teams=, a comma-separated list of teams of which a
+// game must play at least one, false when it is not set
+func parseTeams(values url.Values) (gameFilter, bool, *QueryError) {
+	v := values.Get("teams")
+	if v == "" {
+		return gameFilter{}, false, nil
+	}
+	wanted := make(map[string]bool)
+	for _, team := range strings.Split(v, ",") {
+		if team = strings.ToLower(strings.TrimSpace(team)); team != "" {
+			wanted[team] = true
+		}
+	}
+	if len(wanted) == 0 {
+		return gameFilter{}, false, &QueryError{Param: "teams", Value: v, Message: "must list at least one team"}
+	}
+	return gameFilter{
+		name: "teams",
+		keep: func(p ProcessedGameStats) bool {
+			for _, key := range matchupKeys(p.ShortName, p.FullName) {
+				if wanted[key] {
+					return true
+				}
+			}
+			return false
+		},
+	}, true, nil
+}
+
+// FilterReport is the number of games one filter removed, reported with
+// ?explainFilters=true
+type FilterReport struct {
+	Filter  string `json:"filter"`
+	Removed int    `json:"removed"`
+}
+
+// Explained wraps a list response with the filter reports
+type Explained struct {
+	Games   any            `json:"games"`
+	Matched int            `json:"matched"`
+	Filters []FilterReport `json:"filters"`
+
+	// Links of week responses requested with ?links=true
+	Links *WeekLinks `json:"links,omitempty"`
+}
+
+// isExplainFilters reports whether the request asked for ?explainFilters=true
+func isExplainFilters(r *http.Request) bool {
+	return r.URL.Query().Get("explainFilters") == "true"
+}
+
+// apply filters and sorts games in place and returns the kept games
+func (q gameQuery) apply(games []ProcessedGameStats) []ProcessedGameStats {
+	kept, _ := q.explain(games)
+	return kept
+}
+
+// explain is apply, also reporting how many games each filter removed.
+// Filters run in order, so a game is counted against the first filter
+// rejecting it.
+func (q gameQuery) explain(games []ProcessedGameStats) ([]ProcessedGameStats, []FilterReport) {
+	normalizeGames(games, q.normalize)
+	reports := make([]FilterReport, len(q.filters))
+	for i, f := range q.filters {
+		reports[i].Filter = f.name
+	}
+
+	kept := games[:0]
+	for _, g := range games {
+		ok := true
+		for i, f := range q.filters {
+			if !f.keep(g) {
+				reports[i].Removed++
+				ok = false
+				break
+			}
+		}
+		if ok {
+			kept = append(kept, g)
+		}
+	}
+	q.sort(kept)
+	return kept, reports
+}
+
+// sort orders games in place by the sort keys
+func (q gameQuery) sort(games []ProcessedGameStats) {
+	sort.SliceStable(games, func(i, j int) bool {
+		for _, k := range q.sortKeys {
+			a, b := k.get(games[i]), k.get(games[j])
+			if a == b {
+				continue
+			}
+			if k.desc {
+				return a > b
+			}
+			return a < b
+		}
+		return false
+	})
+}
+
+// parseSortKeys parses a compound sort such as
+// "scenarioRating:desc,totalPoints:desc". Keys are rating fields or game
+// stats, by path ("offense.totalPoints") or unambiguous name
+// ("totalPoints"). Keys without a direction use desc; an empty sort is
+// offensiveRating.
+func parseSortKeys(v string, desc bool) ([]sortKey, *QueryError) {
+	if v == "" {
+		return []sortKey{{field: "offensiveRating", desc: desc, get: ratingFields["offensiveRating"]}}, nil
+	}
+
+	var keys []sortKey
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(v, ",") {
+		field, dir, _ := strings.Cut(strings.TrimSpace(part), ":")
+		k := sortKey{field: field, desc: desc}
+		switch dir {
+		case "":
+		case "desc":
+			k.desc = true
+		case "asc":
+			k.desc = false
+		default:
+			return nil, &QueryError{Param: "sort", Value: v, Message: "direction of " + field + " must be asc or desc"}
+		}
+
+		get, ok := sortGetter(field)
+		if !ok {
+			return nil, &QueryError{Param: "sort", Value: v, Message: "unknown key " + field + ", must be one of " + fieldNames() + " or a stat listed by /meta/fields"}
+		}
+		if seen[field] {
+			return nil, &QueryError{Param: "sort", Value: v, Message: "duplicate key " + field}
+		}
+		seen[field] = true
+		k.get = get
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+// sortGetter resolves a sort key to a rating field or a game stat
+func sortGetter(field string) (func(ProcessedGameStats) float64, bool) {
+	if get, ok := ratingFields[field]; ok {
+		return get, true
+	}
+	path := field
+	if _, ok := statPaths[path]; !ok {
+		if path, ok = statLeaves[field]; !ok {
+			return nil, false
+		}
+	}
+	return func(p ProcessedGameStats) float64 {
+		if p.stats == nil {
+			return 0
+		}
+		return stat(p.stats, path)
+	}, true
+}
+
+func sortedFieldNames() []string {
+	names := make([]string, 0, len(ratingFields))
+	for name := range ratingFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func fieldNames() string {
+	return strings.Join(sortedFieldNames(), ", ")
+}
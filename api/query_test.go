@@ -0,0 +1,171 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+var queryGames = []ProcessedGameStats{
+	{ID: "a", MatchupQuality: "high", OffensiveRating: 5, ScenarioRating: 1, TotalRating: 8, Blowout: true},
+	{ID: "b", MatchupQuality: "low", OffensiveRating: 2, ScenarioRating: 9, TotalRating: 14},
+	{ID: "c", MatchupQuality: "high", OffensiveRating: 8, ScenarioRating: 4, TotalRating: 12},
+}
+
+func ids(games []ProcessedGameStats) string {
+	s := ""
+	for _, g := range games {
+		s += g.ID
+	}
+	return s
+}
+
+func TestGameQueryApply(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"", "cab"},
+		{"sort=totalRating", "bca"},
+		{"sort=scenarioRating&order=asc", "acb"},
+		{"minTotalRating=10", "cb"},
+		{"matchupQuality=HIGH&sort=totalRating", "ca"},
+		{"excludeBlowouts=true", "cb"},
+		{"excludeBlowouts=false", "cab"},
+	}
+	for _, tt := range tests {
+		values, _ := url.ParseQuery(tt.query)
+		q, err := parseGameQuery(values)
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %v", tt.query, err)
+		}
+		games := append([]ProcessedGameStats(nil), queryGames...)
+		if got := ids(q.apply(games)); got != tt.want {
+			t.Errorf("%q: got %s, want %s", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestGameQueryTeams(t *testing.T) {
+	games := []ProcessedGameStats{
+		{ID: "a", ShortName: "BUF @ KC", FullName: "Buffalo Bills at Kansas City Chiefs", OffensiveRating: 3},
+		{ID: "b", ShortName: "DET @ GB", FullName: "Detroit Lions at Green Bay Packers", OffensiveRating: 2},
+		{ID: "c", ShortName: "NYJ @ MIA", FullName: "New York Jets at Miami Dolphins", OffensiveRating: 1},
+	}
+	for query, want := range map[string]string{
+		"teams=KC":             "a",
+		"teams=buf,DET":        "ab",
+		"teams= lions , jets ": "bc",
+		"teams=SEA":            "",
+	} {
+		values, _ := url.ParseQuery(query)
+		q, err := parseGameQuery(values)
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %v", query, err)
+		}
+		if got := ids(q.apply(append([]ProcessedGameStats(nil), games...))); got != want {
+			t.Errorf("%q: got %s, want %s", query, got, want)
+		}
+	}
+}
+
+func TestGameQueryInvalidParams(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /games/{year}/{week}", handleGamesYearWeek)
+
+	for _, query := range []string{"sort=nope", "order=sideways", "minTotalRating=ten", "teams=,", "excludeBlowouts=yes"} {
+		req := httptest.NewRequest("GET", "/games/2024/1?"+query, nil)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%q: expected status 400, got %d", query, rec.Code)
+		}
+		var p Problem
+		if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+			t.Fatalf("%q: expected structured error: %v", query, err)
+		}
+		if p.Param == "" || p.Detail == "" || p.Status != http.StatusBadRequest {
+			t.Errorf("%q: incomplete error %+v", query, p)
+		}
+	}
+}
+
+func TestGameQueryExplain(t *testing.T) {
+	values, _ := url.ParseQuery("minTotalRating=10&matchupQuality=high")
+	q, err := parseGameQuery(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept, reports := q.explain(append([]ProcessedGameStats(nil), queryGames...))
+	if ids(kept) != "c" {
+		t.Errorf("expected only c to be kept, got %s", ids(kept))
+	}
+	want := []FilterReport{{"minTotalRating", 1}, {"matchupQuality", 1}}
+	if len(reports) != len(want) || reports[0] != want[0] || reports[1] != want[1] {
+		t.Errorf("got reports %+v, want %+v", reports, want)
+	}
+}
+
+func TestExplainFiltersResponse(t *testing.T) {
+	useTestStore(t, setupTestData(t))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /games/{year}/{week}", handleGamesYearWeek)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/games/2024/1?minTotalRating=1000&explainFilters=true", nil))
+
+	var body struct {
+		Games   []ProcessedGameStats `json:"games"`
+		Matched int                  `json:"matched"`
+		Filters []FilterReport       `json:"filters"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("expected an explained response: %v", err)
+	}
+	if len(body.Games) != 0 || body.Matched != 0 {
+		t.Errorf("expected no games, got %+v", body)
+	}
+	if len(body.Filters) != 1 || body.Filters[0] != (FilterReport{"minTotalRating", 1}) {
+		t.Errorf("expected minTotalRating to remove the game, got %+v", body.Filters)
+	}
+}
+
+func TestGameQueryCompoundSort(t *testing.T) {
+	stats := func(points float64) *GameStats {
+		g := &GameStats{}
+		g.Offense.TotalPoints = points
+		return g
+	}
+	games := []ProcessedGameStats{
+		{ID: "a", ScenarioRating: 5, TotalRating: 1, stats: stats(40)},
+		{ID: "b", ScenarioRating: 9, TotalRating: 2, stats: stats(30)},
+		{ID: "c", ScenarioRating: 5, TotalRating: 3, stats: stats(60)},
+		{ID: "d", ScenarioRating: 9, TotalRating: 3, stats: stats(50)},
+	}
+	for query, want := range map[string]string{
+		"sort=scenarioRating:desc,totalPoints:desc":        "dbca",
+		"sort=scenarioRating:asc,offense.totalPoints:desc": "cadb",
+		"sort=totalRating,scenarioRating:asc":              "cdba",
+		"sort=totalRating,scenarioRating&order=asc":        "abcd",
+		"sort=totalPoints":                                 "cdab",
+	} {
+		values, _ := url.ParseQuery(query)
+		q, err := parseGameQuery(values)
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %v", query, err)
+		}
+		if got := ids(q.apply(append([]ProcessedGameStats(nil), games...))); got != want {
+			t.Errorf("%q: got %s, want %s", query, got, want)
+		}
+	}
+
+	for _, query := range []string{"sort=totalRating:up", "sort=nope:desc", "sort=totalRating,totalRating", "sort=totalRating,"} {
+		values, _ := url.ParseQuery(query)
+		if _, err := parseGameQuery(values); err == nil {
+			t.Errorf("%q: expected an error", query)
+		}
+	}
+}
@@ -0,0 +1,401 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// queryGrammar is the grammar of ?q=, served by /meta/query-syntax.
+// Keywords are case-insensitive.
+const queryGrammar = `query      = or
+or         = and { "OR" and }
+and        = unary { "AND" unary }
+unary      = "NOT" unary | "(" or ")" | comparison | tag
+comparison = field op value
+tag        = "tags" ":" word
+op         = ">" | ">=" | "<" | "<=" | "=" | "!="
+value      = word | '"' text '"'`
+
+// queryTags are the tags of ?q=tags:<tag>, derived from the game stats
+var queryTags = map[string]struct {
+	desc string
+	has  func(ProcessedGameStats) bool
+}{
+	"blowout": {"The rater flagged the game as a blowout", func(p ProcessedGameStats) bool { return p.Blowout }},
+	"close": {"Decided by a field goal or less", func(p ProcessedGameStats) bool {
+		return p.stats != nil && p.stats.Scenario.MarginOfVictory <= 3
+	}},
+	"comeback": {"The lead changed hands in the fourth quarter", func(p ProcessedGameStats) bool {
+		return p.stats != nil && p.stats.Scenario.FourthQuarterLeadershipChange > 0
+	}},
+	"defensiveScore": {"A defensive or special teams touchdown", func(p ProcessedGameStats) bool {
+		return p.stats != nil && p.stats.Defense.DefensiveTds+p.stats.Defense.SpecialTeamsTd > 0
+	}},
+}
+
+// queryFieldAliases are the short names of ?q= for rating fields
+var queryFieldAliases = map[string]string{"rating": "totalRating"}
+
+// queryToken is a lexeme of ?q= and its 1-based position
+type queryToken struct {
+	kind string // word, string, op, (, ) or end
+	text string
+	pos  int
+}
+
+// lexQuery splits a ?q= expression into tokens
+func lexQuery(s string) ([]queryToken, error) {
+	var tokens []queryToken
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '(' || c == ')':
+			tokens = append(tokens, queryToken{kind: string(c), text: string(c), pos: i + 1})
+			i++
+		case strings.ContainsRune("<>=!:", rune(c)):
+			op := string(c)
+			if i+1 < len(s) && s[i+1] == '=' && c != '=' && c != ':' {
+				op += "="
+			}
+			if op == "!" {
+				return nil, fmt.Errorf("at position %d: expected !=", i+1)
+			}
+			tokens = append(tokens, queryToken{kind: "op", text: op, pos: i + 1})
+			i += len(op)
+		case c == '"':
+			end := strings.IndexByte(s[i+1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf("at position %d: unterminated string", i+1)
+			}
+			tokens = append(tokens, queryToken{kind: "string", text: s[i+1 : i+1+end], pos: i + 1})
+			i += end + 2
+		case isQueryWordByte(c):
+			start := i
+			for i < len(s) && isQueryWordByte(s[i]) {
+				i++
+			}
+			tokens = append(tokens, queryToken{kind: "word", text: s[start:i], pos: start + 1})
+		default:
+			return nil, fmt.Errorf("at position %d: unexpected character %q", i+1, c)
+		}
+	}
+	return append(tokens, queryToken{kind: "end", pos: len(s) + 1}), nil
+}
+
+func isQueryWordByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '.' || c == '_' || c == '-'
+}
+
+// queryParser is a recursive descent parser of queryGrammar
+type queryParser struct {
+	src    string
+	tokens []queryToken
+	next   int
+}
+
+func (p *queryParser) peek() queryToken { return p.tokens[p.next] }
+
+func (p *queryParser) advance() queryToken {
+	t := p.tokens[p.next]
+	if t.kind != "end" {
+		p.next++
+	}
+	return t
+}
+
+// keyword reports whether the next token is the keyword kw, consuming it
+func (p *queryParser) keyword(kw string) bool {
+	if t := p.peek(); t.kind == "word" && strings.EqualFold(t.text, kw) {
+		p.next++
+		return true
+	}
+	return false
+}
+
+// text returns the source of the tokens from index start to the current
+// one, naming the filters they compile to
+func (p *queryParser) text(start int) string {
+	from := p.tokens[start].pos - 1
+	to := p.tokens[p.next].pos - 1
+	return strings.TrimSpace(p.src[from:to])
+}
+
+func describe(t queryToken) string {
+	if t.kind == "end" {
+		return "end of query"
+	}
+	return strconv.Quote(t.text)
+}
+
+// parseOr parses an or rule. A lone conjunction is returned as one filter
+// per term, so ?explainFilters= reports each of them.
+func (p *queryParser) parseOr() ([]gameFilter, error) {
+	start := p.next
+	terms, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	if !p.keyword("OR") {
+		return terms, nil
+	}
+
+	alternatives := [][]gameFilter{terms}
+	for {
+		terms, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		alternatives = append(alternatives, terms)
+		if !p.keyword("OR") {
+			break
+		}
+	}
+	return []gameFilter{{
+		name: p.text(start),
+		keep: func(g ProcessedGameStats) bool {
+			for _, alt := range alternatives {
+				if all(alt, g) {
+					return true
+				}
+			}
+			return false
+		},
+	}}, nil
+}
+
+// all reports whether g is kept by every filter
+func all(filters []gameFilter, g ProcessedGameStats) bool {
+	for _, f := range filters {
+		if !f.keep(g) {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *queryParser) parseAnd() ([]gameFilter, error) {
+	var terms []gameFilter
+	for {
+		term, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, term...)
+		if !p.keyword("AND") {
+			return terms, nil
+		}
+	}
+}
+
+func (p *queryParser) parseUnary() ([]gameFilter, error) {
+	start := p.next
+	t := p.peek()
+	switch {
+	case p.keyword("NOT"):
+		inner, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return []gameFilter{{
+			name: p.text(start),
+			keep: func(g ProcessedGameStats) bool { return !all(inner, g) },
+		}}, nil
+	case t.kind == "(":
+		p.advance()
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.advance(); closing.kind != ")" {
+			return nil, fmt.Errorf("at position %d: expected ) to close the ( at position %d, got %s", closing.pos, t.pos, describe(closing))
+		}
+		return inner, nil
+	case t.kind == "word":
+		keep, err := p.parseComparison()
+		if err != nil {
+			return nil, err
+		}
+		return []gameFilter{{name: p.text(start), keep: keep}}, nil
+	}
+	return nil, fmt.Errorf("at position %d: expected a field, NOT or (, got %s", t.pos, describe(t))
+}
+
+// parseComparison parses a comparison or a tag
+func (p *queryParser) parseComparison() (func(ProcessedGameStats) bool, error) {
+	field := p.advance()
+	op := p.advance()
+	if op.kind != "op" {
+		return nil, fmt.Errorf("at position %d: expected an operator after %s, got %s", op.pos, field.text, describe(op))
+	}
+	value := p.advance()
+	if value.kind != "word" && value.kind != "string" {
+		return nil, fmt.Errorf("at position %d: expected a value after %s, got %s", value.pos, op.text, describe(value))
+	}
+	fail := func(msg string) error {
+		return fmt.Errorf("at position %d: %s", field.pos, msg)
+	}
+
+	name := field.text
+	if alias, ok := queryFieldAliases[name]; ok {
+		name = alias
+	}
+	switch {
+	case name == "tags":
+		if op.text != ":" {
+			return nil, fail("tags only supports tags:<tag>")
+		}
+		tag, ok := queryTags[value.text]
+		if !ok {
+			return nil, fmt.Errorf("at position %d: unknown tag %s, must be one of %s", value.pos, value.text, strings.Join(queryTagNames(), ", "))
+		}
+		return tag.has, nil
+	case op.text == ":":
+		return nil, fail(": is only used with tags, compare " + field.text + " with =")
+	case name == "team":
+		team := strings.ToLower(value.text)
+		return equality(op.text, field, func(g ProcessedGameStats) bool {
+			for _, key := range matchupKeys(g.ShortName, g.FullName) {
+				if key == team {
+					return true
+				}
+			}
+			return false
+		})
+	case name == "matchupQuality":
+		return equality(op.text, field, func(g ProcessedGameStats) bool { return strings.EqualFold(g.MatchupQuality, value.text) })
+	case name == "week":
+		want, ok := weekOrder(value.text)
+		if !ok {
+			return nil, fmt.Errorf("at position %d: week %s", value.pos, invalidWeekMessage)
+		}
+		return compare(op.text, func(g ProcessedGameStats) float64 {
+			order, _ := weekOrder(g.Week)
+			return float64(order)
+		}, float64(want)), nil
+	case name == "season":
+		want, err := strconv.Atoi(value.text)
+		if err != nil {
+			return nil, fmt.Errorf("at position %d: season must be a year, got %s", value.pos, describe(value))
+		}
+		return compare(op.text, func(g ProcessedGameStats) float64 {
+			season, _ := strconv.Atoi(g.Season)
+			return float64(season)
+		}, float64(want)), nil
+	}
+
+	get, ok := sortGetter(name)
+	if !ok {
+		return nil, fail("unknown field " + field.text + ", see /meta/query-syntax")
+	}
+	want, err := strconv.ParseFloat(value.text, 64)
+	if err != nil {
+		return nil, fmt.Errorf("at position %d: %s must be compared with a number, got %s", value.pos, field.text, describe(value))
+	}
+	return compare(op.text, get, want), nil
+}
+
+// equality builds the filter of = or != for fields without an order
+func equality(op string, field queryToken, match func(ProcessedGameStats) bool) (func(ProcessedGameStats) bool, error) {
+	switch op {
+	case "=":
+		return match, nil
+	case "!=":
+		return func(g ProcessedGameStats) bool { return !match(g) }, nil
+	}
+	return nil, fmt.Errorf("at position %d: %s only supports = and !=", field.pos, field.text)
+}
+
+// compare builds the filter of a numeric comparison
+func compare(op string, get func(ProcessedGameStats) float64, want float64) func(ProcessedGameStats) bool {
+	return func(g ProcessedGameStats) bool {
+		v := get(g)
+		switch op {
+		case ">":
+			return v > want
+		case ">=":
+			return v >= want
+		case "<":
+			return v < want
+		case "<=":
+			return v <= want
+		case "=":
+			return v == want
+		default:
+			return v != want
+		}
+	}
+}
+
+// parseQueryDSL compiles a ?q= expression into filters
+func parseQueryDSL(v string) ([]gameFilter, *QueryError) {
+	tokens, err := lexQuery(v)
+	if err != nil {
+		return nil, &QueryError{Param: "q", Value: v, Message: err.Error()}
+	}
+	p := &queryParser{src: v, tokens: tokens}
+	filters, err := p.parseOr()
+	if err == nil && p.peek().kind != "end" {
+		t := p.peek()
+		err = fmt.Errorf("at position %d: expected AND, OR or end of query, got %s", t.pos, describe(t))
+	}
+	if err != nil {
+		return nil, &QueryError{Param: "q", Value: v, Message: err.Error()}
+	}
+	return filters, nil
+}
+
+func queryTagNames() []string {
+	names := make([]string, 0, len(queryTags))
+	for name := range queryTags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// QuerySyntax is the /meta/query-syntax document
+type QuerySyntax struct {
+	Grammar   string            `json:"grammar"`
+	Fields    map[string]string `json:"fields"`
+	Operators []string          `json:"operators"`
+	Tags      map[string]string `json:"tags"`
+	Examples  []string          `json:"examples"`
+}
+
+// handleQuerySyntax describes the ?q= language
+func handleQuerySyntax(w http.ResponseWriter, r *http.Request) {
+	doc := QuerySyntax{
+		Grammar: queryGrammar,
+		Fields: map[string]string{
+			"rating":         "Alias of totalRating",
+			"team":           "A team of the game, by abbreviation, full name or nickname",
+			"matchupQuality": "Matchup quality, e.g. high",
+			"season":         "Season year",
+			"week":           "Week number or playoff round, ordered with the playoffs after week 18",
+			"<rating field>": "One of " + fieldNames(),
+			"<stat>":         "A game stat listed by /meta/fields, by path or unambiguous name",
+		},
+		Operators: []string{">", ">=", "<", "<=", "=", "!=", ":"},
+		Tags:      make(map[string]string, len(queryTags)),
+		Examples: []string{
+			"rating>12 AND team=KC AND tags:comeback",
+			`team="Buffalo Bills" AND week>=wildcard`,
+			"(tags:close OR tags:comeback) AND NOT tags:blowout",
+			"offense.totalPoints>=60 AND season>=2020",
+		},
+	}
+	for name, tag := range queryTags {
+		doc.Tags[name] = tag.desc
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	setCacheHeaders(w, "meta")
+	if err := json.NewEncoder(w).Encode(doc); err != nil {
+		writeError(w, r, http.StatusInternalServerError, "error encoding response")
+	}
+}
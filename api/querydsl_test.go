@@ -0,0 +1,111 @@
+package api
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestParseQueryDSL(t *testing.T) {
+	kc := &GameStats{}
+	kc.Scenario.FourthQuarterLeadershipChange = 1
+	kc.Scenario.MarginOfVictory = 3
+	blowout := &GameStats{}
+	blowout.Scenario.MarginOfVictory = 28
+	regular := &GameStats{}
+	regular.Scenario.MarginOfVictory = 10
+	games := []ProcessedGameStats{
+		{ID: "a", Season: "2023", Week: "wildcard", ShortName: "BUF @ KC", FullName: "Buffalo Bills at Kansas City Chiefs", MatchupQuality: "high", TotalRating: 14, OffensiveRating: 5, stats: kc},
+		{ID: "b", Season: "2024", Week: "3", ShortName: "KC @ DEN", FullName: "Kansas City Chiefs at Denver Broncos", MatchupQuality: "low", TotalRating: 9, Blowout: true, stats: blowout},
+		{ID: "c", Season: "2024", Week: "5", ShortName: "NYJ @ MIA", FullName: "New York Jets at Miami Dolphins", MatchupQuality: "high", TotalRating: 12.5, stats: regular},
+	}
+
+	tests := map[string]string{
+		"rating>12 AND team=KC AND tags:comeback": "a",
+		"rating>12":                                 "ac",
+		"rating >= 9 and team != kc":                "c",
+		`team="Kansas City Chiefs"`:                 "ab",
+		"team=chiefs AND NOT tags:blowout":          "a",
+		"tags:blowout OR matchupQuality=high":       "abc",
+		"(tags:close OR rating<10) AND season=2024": "b",
+		"week>=wildcard":                            "a",
+		"week<5 AND season>2023":                    "b",
+		"offensiveRating=5":                         "a",
+		"scenario.marginOfVictory>20":               "b",
+		"NOT (team=KC OR team=MIA)":                 "",
+	}
+	for q, want := range tests {
+		filters, qerr := parseQueryDSL(q)
+		if qerr != nil {
+			t.Errorf("%q: unexpected error: %v", q, qerr)
+			continue
+		}
+		var got string
+		for _, g := range games {
+			if all(filters, g) {
+				got += g.ID
+			}
+		}
+		if got != want {
+			t.Errorf("%q: got %q, want %q", q, got, want)
+		}
+	}
+}
+
+func TestParseQueryDSLErrors(t *testing.T) {
+	tests := map[string]string{
+		"rating>":             "at position 8: expected a value after >",
+		"rating 12":           `at position 8: expected an operator after rating, got "12"`,
+		"rating>12 AND":       "at position 14: expected a field, NOT or (, got end of query",
+		"rating>12 team=KC":   `at position 11: expected AND, OR or end of query, got "team"`,
+		"(rating>12":          "at position 11: expected ) to close the ( at position 1, got end of query",
+		"rating>high":         `at position 8: rating must be compared with a number, got "high"`,
+		"tags:late":           "at position 6: unknown tag late",
+		"tags=comeback":       "at position 1: tags only supports tags:<tag>",
+		"team>KC":             "at position 1: team only supports = and !=",
+		"bogus>1":             "at position 1: unknown field bogus",
+		"week=20":             "at position 6: week must be a week",
+		`team="Kansas City`:   "at position 6: unterminated string",
+		"rating>12 & team=KC": "at position 11: unexpected character '&'",
+		"rating!12":           "at position 7: expected !=",
+	}
+	for q, want := range tests {
+		_, qerr := parseQueryDSL(q)
+		if qerr == nil || qerr.Param != "q" || !strings.HasPrefix(qerr.Message, want) {
+			t.Errorf("%q: got %v, want a message starting with %q", q, qerr, want)
+		}
+	}
+}
+
+func TestQueryParamFilters(t *testing.T) {
+	values, _ := url.ParseQuery("q=rating>12 AND (tags:close OR tags:comeback)&minOffensiveRating=1")
+	q, qerr := parseGameQuery(values)
+	if qerr != nil {
+		t.Fatal(qerr)
+	}
+	var names []string
+	for _, f := range q.filters {
+		names = append(names, f.name)
+	}
+	if got := strings.Join(names, " | "); got != "minOffensiveRating | rating>12 | tags:close OR tags:comeback" {
+		t.Errorf("expected one reported filter per top-level term, got %q", got)
+	}
+}
+
+func TestHandleQuerySyntax(t *testing.T) {
+	rec := httptest.NewRecorder()
+	handleQuerySyntax(rec, httptest.NewRequest("GET", "/meta/query-syntax", nil))
+	var doc QuerySyntax
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Grammar != queryGrammar || len(doc.Tags) != len(queryTags) || len(doc.Examples) == 0 {
+		t.Errorf("unexpected document %+v", doc)
+	}
+	for _, example := range doc.Examples {
+		if _, qerr := parseQueryDSL(example); qerr != nil {
+			t.Errorf("example %q does not parse: %v", example, qerr)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package api
+
+import (
+	"bufio"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// parseSeasonRange parses ?from= and ?to=, defaulting to the first and
+// last available seasons
+func parseSeasonRange(r *http.Request) (from, to int, qerr *QueryError) {
+	seasons := availableSeasons()
+	if len(seasons) > 0 {
+		from, _ = strconv.Atoi(seasons[0].Season)
+		to, _ = strconv.Atoi(seasons[len(seasons)-1].Season)
+	}
+
+	for _, p := range []struct {
+		param string
+		dst   *int
+	}{{"from", &from}, {"to", &to}} {
+		v := r.URL.Query().Get(p.param)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return 0, 0, &QueryError{Param: p.param, Value: v, Message: "must be a season year"}
+		}
+		*p.dst = n
+	}
+	if from > to {
+		return 0, 0, &QueryError{Param: "from", Value: strconv.Itoa(from), Message: "must not be after to (" + strconv.Itoa(to) + ")"}
+	}
+	return from, to, nil
+}
+
+// handleGamesRange serves the games of the seasons ?from= through ?to=
+// with the sort and filter parameters of /games/{year}/{week}, e.g.
+// /games?from=2018&to=2023&minTotalRating=15 for the best games of six
+// seasons. Weeks are filtered one at a time from the cache, so only the
+// matching games are held, and the response is streamed as it is encoded.
+func handleGamesRange(w http.ResponseWriter, r *http.Request) {
+	from, to, qerr := parseSeasonRange(r)
+	if qerr != nil {
+		writeQueryError(w, r, qerr)
+		return
+	}
+	rater, qerr := raterFor(r)
+	if qerr != nil {
+		writeQueryError(w, r, qerr)
+		return
+	}
+	query, qerr := parseGameQuery(r.URL.Query())
+	if qerr != nil {
+		writeQueryError(w, r, qerr)
+		return
+	}
+	page, paginated, qerr := parsePagination(r.URL.Query())
+	if qerr != nil {
+		writeQueryError(w, r, qerr)
+		return
+	}
+	format, qerr := responseFormat(r)
+	if qerr != nil {
+		writeQueryError(w, r, qerr)
+		return
+	}
+	w.Header().Add("Vary", "Accept")
+
+	var games []ProcessedGameStats
+	var reports []FilterReport
+	for year := from; year <= to; year++ {
+		season := strconv.Itoa(year)
+		for _, sw := range loadSeason(r.Context(), season) {
+			processed := processGames(rater, sw.Games)
+			for i := range processed {
+				processed[i].setLocation(season, sw.Week)
+			}
+			kept, weekReports := query.explain(processed)
+			games = append(games, kept...)
+			if reports == nil {
+				reports = weekReports
+				continue
+			}
+			for i := range reports {
+				reports[i].Removed += weekReports[i].Removed
+			}
+		}
+	}
+	query.sort(games)
+	if reports == nil {
+		reports = make([]FilterReport, len(query.filters))
+		for i, f := range query.filters {
+			reports[i].Filter = f.name
+		}
+	}
+
+	if format != formatJSON {
+		if paginated {
+			games = paginate(games, page).Items
+		}
+		if err := writeGameRows(w, r, format, "season", games); err != nil {
+			log.Printf("Warning: streaming %s: %v", r.URL.RequestURI(), err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	setCacheHeaders(w, "season")
+
+	var body any
+	switch {
+	case isSpoilerFree(r) && paginated:
+		body = paginate(spoilerFreeGames(games), page)
+	case isSpoilerFree(r):
+		body = spoilerFreeGames(games)
+	case paginated:
+		body = paginate(games, page)
+	}
+	if isExplainFilters(r) {
+		if body == nil {
+			body = games
+		}
+		body = Explained{Games: body, Matched: len(games), Filters: reports}
+	}
+	if body != nil {
+		if err := json.NewEncoder(w).Encode(body); err != nil {
+			writeError(w, r, http.StatusInternalServerError, "error encoding response")
+		}
+		return
+	}
+	if err := writeJSONArray(w, games); err != nil {
+		log.Printf("Warning: streaming %s: %v", r.URL.RequestURI(), err)
+	}
+}
+
+// writeJSONArray encodes items as a JSON array one at a time, sending the
+// response in chunks instead of buffering the whole document. Errors after
+// the first chunk can no longer change the status and are only returned.
+func writeJSONArray[T any](w http.ResponseWriter, items []T) error {
+	bw := bufio.NewWriterSize(w, 32<<10)
+	bw.WriteByte('[')
+	for i, item := range items {
+		if i > 0 {
+			bw.WriteByte(',')
+		}
+		data, err := json.Marshal(item)
+		if err != nil {
+			return err
+		}
+		if _, err := bw.Write(data); err != nil {
+			return err
+		}
+	}
+	bw.WriteString("]\n")
+	return bw.Flush()
+}
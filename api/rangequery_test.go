@@ -0,0 +1,92 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHandleGamesRange(t *testing.T) {
+	dir := setupTestData(t)
+	useTestStore(t, dir)
+	for _, year := range []string{"2022", "2023"} {
+		if err := os.MkdirAll(filepath.Join(dir, year), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, year, "1.json"), []byte(testData), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	preloadCache(dataStore)
+
+	get := func(url string) *httptest.ResponseRecorder {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		handleGamesRange(rec, httptest.NewRequest("GET", url, nil))
+		return rec
+	}
+
+	tests := []struct {
+		url     string
+		seasons []string
+	}{
+		{"/games", []string{"2022", "2023", "2024", "2024"}},
+		{"/games?from=2023", []string{"2023", "2024", "2024"}},
+		{"/games?from=2022&to=2023&minTotalRating=1", []string{"2022", "2023"}},
+		{"/games?to=2022", []string{"2022"}},
+		{"/games?from=2022&minTotalRating=100", nil},
+		{"/games?from=2023&teams=b", []string{"2023", "2024", "2024"}},
+		{"/games?teams=Z", nil},
+	}
+	for _, tt := range tests {
+		rec := get(tt.url)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d: %s", tt.url, rec.Code, rec.Body)
+		}
+		var games []ProcessedGameStats
+		if err := json.Unmarshal(rec.Body.Bytes(), &games); err != nil {
+			t.Fatalf("%s: failed to parse response: %v", tt.url, err)
+		}
+		var seasons []string
+		for _, g := range games {
+			seasons = append(seasons, g.Season)
+		}
+		if len(seasons) != len(tt.seasons) {
+			t.Errorf("%s: expected seasons %v, got %v", tt.url, tt.seasons, seasons)
+		}
+	}
+
+	rec := get("/games?from=2022&to=2023&limit=1&explainFilters=true&minTotalRating=100")
+	var explained struct {
+		Games   Page[ProcessedGameStats] `json:"games"`
+		Matched int                      `json:"matched"`
+		Filters []FilterReport           `json:"filters"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &explained); err != nil {
+		t.Fatalf("failed to parse explained response: %v", err)
+	}
+	if explained.Matched != 0 || len(explained.Filters) != 1 || explained.Filters[0].Removed != 2 {
+		t.Errorf("unexpected explained response %+v", explained)
+	}
+
+	for _, url := range []string{"/games?from=2024&to=2022", "/games?from=last", "/games?to=0"} {
+		if rec := get(url); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", url, rec.Code)
+		}
+	}
+}
+
+func TestWriteJSONArray(t *testing.T) {
+	for _, items := range [][]int{nil, {1}, {1, 2, 3}} {
+		rec := httptest.NewRecorder()
+		if err := writeJSONArray(rec, items); err != nil {
+			t.Fatal(err)
+		}
+		var got []int
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil || len(got) != len(items) {
+			t.Errorf("%v: got %q, %v", items, rec.Body, err)
+		}
+	}
+}
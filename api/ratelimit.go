@@ -0,0 +1,146 @@
+package api
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenBucket tracks the remaining tokens of a single client
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter is a per-client token bucket limiter
+type rateLimiter struct {
+	mu      sync.Mutex
+	rate    float64 // tokens refilled per second
+	burst   float64
+	buckets map[string]*tokenBucket
+}
+
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	return &rateLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// allow consumes a token for key and reports whether the request may proceed
+func (l *rateLimiter) allow(key string) bool {
+	ok, _ := l.take(key, 1)
+	return ok
+}
+
+// take consumes n tokens for key. When fewer are left it consumes none and
+// returns how long until n tokens are available.
+func (l *rateLimiter) take(key string, n float64) (bool, time.Duration) {
+	now := clock.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[key]
+	if !ok {
+		// Drop idle clients before the map grows unbounded
+		if len(l.buckets) >= 10000 {
+			l.prune(now)
+		}
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens += now.Sub(b.last).Seconds() * l.rate
+	if b.tokens > l.burst {
+		b.tokens = l.burst
+	}
+	b.last = now
+
+	if b.tokens < n {
+		return false, time.Duration((n - b.tokens) / l.rate * float64(time.Second))
+	}
+	b.tokens -= n
+	return true, 0
+}
+
+// prune removes buckets that have refilled completely. Caller holds l.mu.
+func (l *rateLimiter) prune(now time.Time) {
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// trustedProxies are the networks of TRUSTED_PROXIES, the load balancers
+// and CDNs in front of the server whose X-Forwarded-For is believed
+var trustedProxies []netip.Prefix
+
+// parseTrustedProxies parses TRUSTED_PROXIES, CIDR ranges such as
+// 10.0.0.0/8 or single addresses
+func parseTrustedProxies(list []string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, v := range list {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		if addr, err := netip.ParseAddr(v); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		p, err := netip.ParsePrefix(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TRUSTED_PROXIES: %q must be an address or a CIDR range", v)
+		}
+		prefixes = append(prefixes, p.Masked())
+	}
+	return prefixes, nil
+}
+
+// isTrustedProxy reports whether ip is in TRUSTED_PROXIES
+func isTrustedProxy(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, p := range trustedProxies {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP returns the address of the client of r: the remote address
+// without its port or, when that is a trusted proxy, the right-most
+// X-Forwarded-For hop that is not one. The hops left of it were written
+// by the client and are not believed.
+func clientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	if !isTrustedProxy(ip) {
+		return ip
+	}
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if _, err := netip.ParseAddr(hop); err != nil {
+			break
+		}
+		ip = hop
+		if !isTrustedProxy(hop) {
+			break
+		}
+	}
+	return ip
+}
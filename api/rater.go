@@ -0,0 +1,84 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/jjway/rewatchableGamesApi-go/ratings"
+)
+
+// Rater is one version of the rating algorithm. Versions are registered in
+// raters and picked per request with ?algo= or a /{version}/ path prefix.
+type Rater = ratings.Rater
+
+// defaultAlgorithm is served when the request does not ask for a version
+const defaultAlgorithm = "v1"
+
+// raters are the registered rating algorithms, keyed by version
+var raters = map[string]Rater{
+	"v1": v1Rater{},
+	"v2": v2Rater{},
+}
+
+// v1Rater and v2Rater rate with the rating config of the moment, which
+// tests and RATING_CONFIG replace after the raters are registered
+type (
+	v1Rater struct{}
+	v2Rater struct{}
+)
+
+func (v1Rater) Version() string { return "v1" }
+
+func (v1Rater) Rate(g GameStats) RatingBreakdown {
+	return ratings.NewV1(ratingConfig).Rate(g)
+}
+
+func (v2Rater) Version() string { return "v2" }
+
+func (v2Rater) Rate(g GameStats) RatingBreakdown {
+	return ratings.NewV2(ratingConfig).Rate(g)
+}
+
+// raterKey is the context key set by the version path prefix
+type raterKey struct{}
+
+// withRater serves next with the version r, stripping its /{version}
+// prefix, unless nested under another prefix
+func withRater(r Rater, next http.Handler) http.Handler {
+	return http.StripPrefix("/"+r.Version(), http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if prefix := pathPrefix(req); prefix != "" {
+			writeError(w, req, http.StatusNotFound, "no route for "+prefix+"/"+r.Version()+req.URL.Path)
+			return
+		}
+		next.ServeHTTP(w, req.WithContext(context.WithValue(req.Context(), raterKey{}, r)))
+	}))
+}
+
+// raterFor returns the rater a request asked for: the path prefix wins over
+// ?algo=, and the default is v1
+func raterFor(r *http.Request) (Rater, *QueryError) {
+	if rater, ok := r.Context().Value(raterKey{}).(Rater); ok {
+		return rater, nil
+	}
+	v := r.URL.Query().Get("algo")
+	if v == "" {
+		return raters[defaultAlgorithm], nil
+	}
+	rater, ok := raters[v]
+	if !ok {
+		return nil, &QueryError{Param: "algo", Value: v, Message: "must be one of " + strings.Join(algorithmNames(), ", ")}
+	}
+	return rater, nil
+}
+
+// algorithmNames lists the registered versions in order
+func algorithmNames() []string {
+	names := make([]string, 0, len(raters))
+	for name := range raters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
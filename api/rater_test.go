@@ -0,0 +1,82 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestV1RaterMatchesBreakdown(t *testing.T) {
+	var g GameStats
+	g.Offense.TotalPlays = 100
+	g.Offense.TotalPoints = 55
+	g.Scenario.ScenarioRating = 8.5
+
+	b := raters["v1"].Rate(g)
+	want := breakdownGame(g)
+	if b.TotalRating != want.TotalRating || b.Algorithm != "v1" {
+		t.Errorf("got %+v, want total %v", b, want.TotalRating)
+	}
+}
+
+func TestV2RaterRewardsLateDrama(t *testing.T) {
+	var g GameStats
+	g.Scenario.ScenarioRating = 5
+	g.Scenario.MarginOfVictory = 3
+	g.Scenario.FourthQuarterLeadershipChange = 6
+
+	b := raters["v2"].Rate(g)
+	// Lead changes are capped at 4: 4*1.5 + 2 for a field goal finish
+	if b.ScenarioBonus != 8 {
+		t.Errorf("expected scenario bonus 8, got %v", b.ScenarioBonus)
+	}
+	if b.ScenarioRating != 13 || b.TotalRating != 13 {
+		t.Errorf("expected bonus in scenario and total ratings, got %+v", b)
+	}
+
+	g.Scenario.MarginOfVictory = 21
+	g.Scenario.FourthQuarterLeadershipChange = 0
+	if b := raters["v2"].Rate(g); b.ScenarioBonus != 0 {
+		t.Errorf("expected no bonus for a blowout, got %v", b.ScenarioBonus)
+	}
+}
+
+func TestAlgorithmSelection(t *testing.T) {
+	useTestStore(t, setupTestData(t))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /games/{year}/{week}", handleGamesYearWeek)
+	for _, version := range algorithmNames() {
+		mux.Handle("GET /"+version+"/", withRater(raters[version], mux))
+	}
+
+	algorithm := func(url string) string {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest("GET", url, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d", url, rec.Code)
+		}
+		var games []ProcessedGameStats
+		if err := json.Unmarshal(rec.Body.Bytes(), &games); err != nil {
+			t.Fatal(err)
+		}
+		return games[0].Algorithm
+	}
+
+	for url, want := range map[string]string{
+		"/games/2024/1":         "v1",
+		"/games/2024/1?algo=v2": "v2",
+		"/v2/games/2024/1":      "v2",
+		"/v1/games/2024/1":      "v1",
+	} {
+		if got := algorithm(url); got != want {
+			t.Errorf("%s: expected algorithm %s, got %s", url, want, got)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/games/2024/1?algo=v9", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown algorithm, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,44 @@
+package api
+
+import (
+	"github.com/jjway/rewatchableGamesApi-go/ratings"
+)
+
+// The scoring model of the rating formula
+type (
+	RatingConfig = ratings.Config
+	Tier         = ratings.Tier
+)
+
+// ratingConfig is the scoring model of the raters, from RATING_CONFIG or
+// RATING_CONFIG_JSON
+var ratingConfig = ratings.DefaultConfig()
+
+// loadRatingConfig reads a JSON or YAML rating config, which may be
+// encrypted. Fields missing from the file keep their default values.
+func loadRatingConfig(path string) (RatingConfig, error) {
+	data, err := readConfigFile(path)
+	if err != nil {
+		return RatingConfig{}, err
+	}
+	return ratings.ParseConfig(data, configExt(path))
+}
+
+// ratingConfigFromConfig loads the rating config from RATING_CONFIG (a
+// file path) or RATING_CONFIG_JSON (an inline JSON document). ok is false
+// when neither is set.
+func ratingConfigFromConfig(c Config) (cfg RatingConfig, ok bool, err error) {
+	if path := c.Ratings.Config; path != "" {
+		cfg, err = loadRatingConfig(path)
+		return cfg, true, err
+	}
+	if inline := c.Ratings.Weights; inline != nil {
+		data, err := json.Marshal(inline)
+		if err != nil {
+			return cfg, true, err
+		}
+		cfg, err = ratings.ParseConfig(data, ".json")
+		return cfg, true, err
+	}
+	return cfg, false, nil
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jjway/rewatchableGamesApi-go/ratings"
+)
+
+func TestLoadRatingConfigYAML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rating.yaml")
+	yamlConfig := `
+totalPoints:
+  - {min: 40, points: 5}
+defensiveTd: 6
+`
+	if err := os.WriteFile(path, []byte(yamlConfig), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	cfg, err := loadRatingConfig(path)
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if len(cfg.TotalPoints) != 1 || cfg.TotalPoints[0].Points != 5 || cfg.DefensiveTd != 6 {
+		t.Errorf("overrides not applied: %+v", cfg)
+	}
+	if cfg.Interception != 1 || len(cfg.QBR) != 2 {
+		t.Errorf("expected unset fields to keep defaults: %+v", cfg)
+	}
+}
+
+func TestRatingConfigChangesRating(t *testing.T) {
+	old := ratingConfig
+	t.Cleanup(func() { ratingConfig = old })
+
+	var g GameStats
+	g.Offense.TotalPlays = 100
+	g.Offense.TotalPoints = 55
+	g.Defense.DefensiveTds = 1
+
+	if got := computeOffensiveRating(g); got != 1 {
+		t.Fatalf("expected default offensive rating 1, got %v", got)
+	}
+
+	cfg, err := ratings.ParseConfig([]byte(`{"totalPoints": [{"min": 50, "points": 4}], "defensiveTd": 5}`), ".json")
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	ratingConfig = cfg
+
+	if got := computeOffensiveRating(g); got != 4 {
+		t.Errorf("expected configured offensive rating 4, got %v", got)
+	}
+	if got := computeDefensiveBigPlays(g); got != 5 {
+		t.Errorf("expected configured defensive big plays 5, got %v", got)
+	}
+}
+
+func TestBlowoutMargin(t *testing.T) {
+	old := ratingConfig
+	t.Cleanup(func() { ratingConfig = old })
+
+	var g GameStats
+	g.Scenario.MarginOfVictory = 24
+	if !processGame(raters[defaultAlgorithm], g).Blowout {
+		t.Error("expected a 24 point game to be a blowout by default")
+	}
+
+	cfg, err := ratings.ParseConfig([]byte("blowoutMargin: 28\n"), ".yaml")
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	ratingConfig = cfg
+	if processGame(raters[defaultAlgorithm], g).Blowout {
+		t.Error("expected a 24 point game not to be a blowout with a 28 point margin")
+	}
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// startupReadiness tracks the preload behind /readyz. The server listens
+// as soon as it starts so the liveness of the process shows, but reports
+// not ready until the week files are cached, so load balancers don't
+// route traffic to a cold instance.
+type startupReadiness struct {
+	ready  atomic.Bool
+	files  atomic.Int64
+	loaded atomic.Int64
+}
+
+var readiness startupReadiness
+
+// start records the number of files the preload reads
+func (s *startupReadiness) start(files int) {
+	s.files.Store(int64(files))
+	s.loaded.Store(0)
+}
+
+// fileRead records one more file read, loaded or not
+func (s *startupReadiness) fileRead() {
+	s.loaded.Add(1)
+}
+
+func (s *startupReadiness) markReady() {
+	s.ready.Store(true)
+}
+
+// Readiness is the response of GET /readyz
+type Readiness struct {
+	Ready  bool  `json:"ready"`
+	Files  int64 `json:"files"`  // week files to preload
+	Loaded int64 `json:"loaded"` // week files read so far
+
+	// Components are the subsystems of the server, ready once all of them
+	// are running and healthy
+	Components []ComponentHealth `json:"components,omitempty"`
+}
+
+// handleReadyz answers 200 once the preload is done and every component
+// is healthy, and 503 until then
+func handleReadyz(w http.ResponseWriter, r *http.Request) {
+	status := Readiness{
+		Ready:  readiness.ready.Load(),
+		Files:  readiness.files.Load(),
+		Loaded: readiness.loaded.Load(),
+	}
+	if l := components; l != nil {
+		var healthy bool
+		status.Components, healthy = l.health()
+		status.Ready = status.Ready && healthy
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if !status.Ready {
+		w.Header().Set("Retry-After", "1")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		writeError(w, r, http.StatusInternalServerError, "error encoding response")
+	}
+}
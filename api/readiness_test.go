@@ -0,0 +1,67 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParallelPreload(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "2023"), 0755)
+	for week := 1; week <= 18; week++ {
+		data := fmt.Sprintf(`[{"id": "g%d", "fullName": "Buffalo Bills at Kansas City Chiefs", "shortName": "BUF @ KC"}]`, week)
+		os.WriteFile(filepath.Join(dir, "2023", fmt.Sprintf("%d.json", week)), []byte(data), 0644)
+	}
+	useTestStore(t, dir)
+	old := preloadWorkers
+	preloadWorkers = 4
+	t.Cleanup(func() { preloadWorkers = old; readiness = startupReadiness{} })
+	readiness = startupReadiness{}
+
+	preloadCache(dataStore)
+	for week := 1; week <= 18; week++ {
+		if _, ok := cache.peek(fmt.Sprintf("2023/%d.json", week)); !ok {
+			t.Errorf("expected week %d to be preloaded", week)
+		}
+	}
+	if n := len(gamesForTeam("BUF")); n != 18 {
+		t.Errorf("expected 18 indexed games for BUF, got %d", n)
+	}
+	if readiness.files.Load() != 18 || readiness.loaded.Load() != 18 {
+		t.Errorf("expected 18 of 18 files read, got %d of %d", readiness.loaded.Load(), readiness.files.Load())
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	t.Cleanup(func() { readiness = startupReadiness{} })
+	readiness = startupReadiness{}
+	readiness.start(2)
+	readiness.fileRead()
+
+	get := func() (*httptest.ResponseRecorder, Readiness) {
+		rec := httptest.NewRecorder()
+		handleReadyz(rec, httptest.NewRequest("GET", "/readyz", nil))
+		var status Readiness
+		if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+			t.Fatal(err)
+		}
+		return rec, status
+	}
+
+	rec, status := get()
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("expected status 503 with Retry-After while preloading, got %d", rec.Code)
+	}
+	if status.Ready || status.Files != 2 || status.Loaded != 1 {
+		t.Errorf("unexpected status %+v", status)
+	}
+
+	readiness.markReady()
+	if rec, status := get(); rec.Code != http.StatusOK || !status.Ready {
+		t.Errorf("expected status 200 once ready, got %d %+v", rec.Code, status)
+	}
+}
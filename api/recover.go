@@ -0,0 +1,38 @@
+package api
+
+import (
+	"expvar"
+	"log"
+	"net/http"
+	"runtime/debug"
+)
+
+// panics counts the requests that panicked, published with the other
+// expvar counters at /admin/vars
+var panics = expvar.NewInt("panics")
+
+// recoverMiddleware turns a panicking handler into a problem 500, logging
+// the panic and its stack with the request ID instead of letting net/http
+// print it and drop the connection. A panic after the response started
+// can only abort it.
+func recoverMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{ResponseWriter: w}
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+			panics.Add(1)
+			log.Printf("Error: panic serving %s %s (request %s): %v\n%s", r.Method, r.URL.Path, requestID(r.Context()), v, debug.Stack())
+			if rec.status != 0 {
+				panic(http.ErrAbortHandler)
+			}
+			writeError(w, r, http.StatusInternalServerError, "internal server error")
+		}()
+		next.ServeHTTP(rec, r)
+	})
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestRecoverMiddleware(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	handler := requestIDMiddleware(recoverMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var games []GameStats
+		_ = games[3]
+	})))
+	before := panics.Value()
+
+	req := httptest.NewRequest("GET", "/games/2024/1", nil)
+	req.Header.Set(requestIDHeader, "boom-1")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusInternalServerError || rec.Header().Get("Content-Type") != "application/problem+json" {
+		t.Fatalf("expected a problem 500, got %d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	var p Problem
+	if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil || p.RequestID != "boom-1" || strings.Contains(p.Detail, "index") {
+		t.Errorf("unexpected problem %+v, %v", p, err)
+	}
+	if got := panics.Value(); got != before+1 {
+		t.Errorf("expected the panic to be counted, got %d after %d", got, before)
+	}
+	if out := logs.String(); !strings.Contains(out, "request boom-1") || !strings.Contains(out, "index out of range") {
+		t.Errorf("expected the panic logged with the request ID, got %q", out)
+	}
+}
+
+func TestRecoverAfterResponseStarted(t *testing.T) {
+	log.SetOutput(&bytes.Buffer{})
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	handler := recoverMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("[{"))
+		panic("nil game")
+	}))
+	defer func() {
+		if v := recover(); v != http.ErrAbortHandler {
+			t.Errorf("expected the response to be aborted, got %v", v)
+		}
+	}()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+}
@@ -0,0 +1,185 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jjway/rewatchableGamesApi-go/store"
+)
+
+// fileStamp identifies a version of a week file: its size and either its
+// modification time or, for stores that cannot stat it, the checksum of
+// its bytes
+type fileStamp struct {
+	size    int64
+	modTime time.Time
+	sum     [sha256.Size]byte
+}
+
+// refresher reloads the week files of the active season, the latest one in
+// the store, as they change. It is the fallback of the file watcher for
+// stores that cannot be watched, and also picks up the weeks added to the
+// season.
+type refresher struct {
+	store store.Store
+
+	mu     sync.Mutex
+	stamps map[string]fileStamp
+}
+
+func newRefresher(s store.Store) *refresher {
+	if ro, ok := s.(readOnlyStore); ok {
+		s = ro.Store
+	}
+	return &refresher{store: s, stamps: make(map[string]fileStamp)}
+}
+
+// stamp returns the current stamp of name, statting it when the store
+// can, reading it otherwise. Weeks in a compacted season file cannot be
+// statted and are read too.
+func (rf *refresher) stamp(name string) (fileStamp, error) {
+	if ss, ok := rf.store.(store.StatStore); ok {
+		info, err := ss.Stat(name)
+		if err == nil {
+			return fileStamp{size: info.Size(), modTime: info.ModTime()}, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return fileStamp{}, err
+		}
+	}
+	data, err := rf.store.ReadFile(name)
+	if err != nil {
+		return fileStamp{}, err
+	}
+	return fileStamp{size: int64(len(data)), sum: sha256.Sum256(data)}, nil
+}
+
+// matches reports whether the cached entry is the version of the week
+// file st stamps: of the same size, and loaded after its modification or
+// of the same checksum
+func (e cacheEntry) matches(st fileStamp) bool {
+	if int64(e.size) != st.size {
+		return false
+	}
+	if !st.modTime.IsZero() {
+		return e.loadedAt.After(st.modTime)
+	}
+	return e.hash == hex.EncodeToString(st.sum[:])
+}
+
+// activeSeason returns the latest season of names and its week files
+func activeSeason(names []string) (string, []string) {
+	latest := ""
+	var weeks []string
+	for _, name := range names {
+		if !store.IsWeekFile(name) {
+			continue
+		}
+		season, _, _ := strings.Cut(name, "/")
+		if _, err := strconv.Atoi(season); err != nil {
+			continue
+		}
+		switch {
+		case season > latest:
+			latest, weeks = season, []string{name}
+		case season == latest:
+			weeks = append(weeks, name)
+		}
+	}
+	return latest, weeks
+}
+
+// refresh checks the weeks of the active season once, reloading those
+// whose stamp changed since the last check, loading the new ones and
+// evicting those gone from the store. A cached week, the first time it is
+// seen too, is only reloaded when it differs from the cached version. It
+// returns the number of weeks reloaded.
+func (rf *refresher) refresh() (int, error) {
+	names, err := rf.store.ListFiles()
+	if err != nil {
+		return 0, err
+	}
+	season, weeks := activeSeason(names)
+	if season == "" {
+		return 0, nil
+	}
+
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+
+	listed := make(map[string]bool, len(weeks))
+	reloaded := 0
+	for _, name := range weeks {
+		listed[name] = true
+		st, err := rf.stamp(name)
+		if err != nil {
+			log.Printf("Warning: could not check %s for changes: %v", name, err)
+			continue
+		}
+		prev, seen := rf.stamps[name]
+		rf.stamps[name] = st
+		if seen && prev == st {
+			continue
+		}
+		if entry, ok := cache.peek(name); ok && entry.matches(st) {
+			// Unchanged since it was cached, or already reloaded by the
+			// file watcher
+			continue
+		}
+		if reloadFile(name) == nil {
+			reloaded++
+		}
+	}
+
+	// Weeks of the season deleted from the store
+	for name := range cache.weekCounts() {
+		if strings.HasPrefix(name, season+"/") && !listed[name] {
+			delete(rf.stamps, name)
+			evictFile(name)
+		}
+	}
+	for name := range rf.stamps {
+		if !listed[name] {
+			delete(rf.stamps, name)
+		}
+	}
+	return reloaded, nil
+}
+
+// run refreshes every interval until ctx is done
+func (rf *refresher) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if _, err := rf.refresh(); err != nil {
+			log.Printf("Warning: could not refresh the active season: %v", err)
+		}
+	}
+}
+
+// startRefresher starts the background refresh of the active season every
+// interval, CACHE_REFRESH_INTERVAL, when it is set
+func startRefresher(ctx context.Context, s store.Store, interval time.Duration) error {
+	if interval == 0 {
+		return nil
+	}
+	if err := checkInterval("CACHE_REFRESH_INTERVAL", interval, minRefreshInterval); err != nil {
+		return err
+	}
+	go newRefresher(s).run(ctx, interval)
+	log.Printf("Refreshing the active season every %s", interval)
+	return nil
+}
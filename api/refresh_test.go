@@ -0,0 +1,125 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/jjway/rewatchableGamesApi-go/store"
+)
+
+func TestRefresher(t *testing.T) {
+	dir := setupTestData(t)
+	os.MkdirAll(filepath.Join(dir, "2023"), 0755)
+	os.WriteFile(filepath.Join(dir, "2023", "1.json"), []byte(testData), 0644)
+	useTestStore(t, dir)
+	preloadCache(dataStore)
+	cache.remove("2023/1.json")
+	rf := newRefresher(dataStore)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /games/{year}/{week}", handleGamesYearWeek)
+	etag := func() string {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest("GET", "/games/2024/2", nil))
+		return rec.Header().Get("ETag")
+	}
+	before := etag()
+
+	for i := 0; i < 2; i++ {
+		if n, err := rf.refresh(); err != nil || n != 0 {
+			t.Fatalf("expected the preloaded weeks to be left alone, got %d reloaded, %v", n, err)
+		}
+	}
+	if etag() != before {
+		t.Error("expected the ETag of an unchanged week to be kept")
+	}
+
+	// Same size, later modification time
+	path := filepath.Join(dir, "2024", "2.json")
+	os.WriteFile(path, []byte(strings.Replace(testData, "game1", "game9", 1)), 0644)
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(path, later, later)
+	if n, err := rf.refresh(); err != nil || n != 1 {
+		t.Fatalf("expected the edited week to be reloaded, got %d, %v", n, err)
+	}
+	if after := etag(); after == before || after == "" {
+		t.Errorf("expected a new ETag, got %q", after)
+	}
+
+	os.WriteFile(filepath.Join(dir, "2024", "3.json"), []byte(testData), 0644)
+	os.Remove(filepath.Join(dir, "2024", "1.json"))
+	if n, err := rf.refresh(); err != nil || n != 1 {
+		t.Fatalf("expected the new week to be loaded, got %d, %v", n, err)
+	}
+	weeks := cache.weekCounts()
+	if weeks["2024/3.json"] != 1 {
+		t.Errorf("expected the new week in the catalog, got %v", weeks)
+	}
+	if _, ok := weeks["2024/1.json"]; ok {
+		t.Errorf("expected the deleted week to be evicted, got %v", weeks)
+	}
+	if _, ok := weeks["2023/1.json"]; ok {
+		t.Errorf("expected past seasons to be left alone, got %v", weeks)
+	}
+}
+
+func TestRefresherFirstCheck(t *testing.T) {
+	dir := setupTestData(t)
+	useTestStore(t, dir)
+	preloadCache(dataStore)
+	rf := newRefresher(dataStore)
+
+	// Same size, edited between the preload and the first check
+	path := filepath.Join(dir, "2024", "2.json")
+	os.WriteFile(path, []byte(strings.Replace(testData, "game1", "game9", 1)), 0644)
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(path, later, later)
+	if n, err := rf.refresh(); err != nil || n != 1 {
+		t.Fatalf("expected the week edited before the first check to be reloaded, got %d, %v", n, err)
+	}
+	if games, _ := residentGames("2024/2.json"); len(games) != 1 || games[0].ID != "game9" {
+		t.Errorf("expected the edited games, got %+v", games)
+	}
+
+	// Without Stat, the checksum tells the versions apart
+	fsys := fstest.MapFS{"2024/1.json": {Data: []byte(testData)}}
+	dataStore = statlessStore{store.NewFS(fsys)}
+	preloadCache(dataStore)
+	fsys["2024/1.json"] = &fstest.MapFile{Data: []byte(strings.Replace(testData, "game1", "game9", 1))}
+	if n, err := newRefresher(dataStore).refresh(); err != nil || n != 1 {
+		t.Fatalf("expected the changed bytes to be reloaded on the first check, got %d, %v", n, err)
+	}
+	if games, _ := residentGames("2024/1.json"); len(games) != 1 || games[0].ID != "game9" {
+		t.Errorf("expected the changed games, got %+v", games)
+	}
+}
+
+// statlessStore hides the Stat of the store it wraps
+type statlessStore struct {
+	store.Store
+}
+
+func TestRefresherChecksum(t *testing.T) {
+	fsys := fstest.MapFS{"2024/1.json": {Data: []byte(testData)}}
+	useTestStore(t, t.TempDir())
+	dataStore = statlessStore{store.NewFS(fsys)}
+	preloadCache(dataStore)
+	rf := newRefresher(dataStore)
+
+	if n, err := rf.refresh(); err != nil || n != 0 {
+		t.Fatalf("expected no reload, got %d, %v", n, err)
+	}
+	fsys["2024/1.json"] = &fstest.MapFile{Data: []byte(strings.Replace(testData, "game1", "game9", 1))}
+	if n, err := rf.refresh(); err != nil || n != 1 {
+		t.Fatalf("expected the changed bytes to be reloaded, got %d, %v", n, err)
+	}
+	if games, err := loadGameStats("2024/1.json"); err != nil || games[0].ID != "game9" {
+		t.Errorf("expected the new games, got %v %v", games, err)
+	}
+}
@@ -0,0 +1,214 @@
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/jjway/rewatchableGamesApi-go/store"
+)
+
+// defaultReplicaPollInterval is how often a replica checks latest.json
+const defaultReplicaPollInterval = 30 * time.Second
+
+// replica serves the routes mirrored by a publisher (see publisher) from
+// its bucket alone, with no data directory, switching to each new version
+// once latest.json names it. Objects of the current version are kept in
+// memory as they are requested.
+type replica struct {
+	source   *store.Object
+	interval time.Duration
+
+	mu       sync.RWMutex
+	manifest PublishManifest
+	objects  map[string][]byte
+}
+
+func newReplica(source *store.Object, interval time.Duration) *replica {
+	return &replica{source: source, interval: interval}
+}
+
+// poll reads latest.json and switches to its version when it changed
+func (rp *replica) poll() error {
+	data, err := rp.source.ReadFile("latest.json")
+	if err != nil {
+		return err
+	}
+	var m PublishManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return fmt.Errorf("parse latest.json: %w", err)
+	}
+	if m.Version == "" || !fs.ValidPath(m.Version) {
+		return fmt.Errorf("latest.json names an invalid version %q", m.Version)
+	}
+
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	if m.Version == rp.manifest.Version {
+		return nil
+	}
+	log.Printf("Replica serving version %s (%d objects)", m.Version, m.Objects)
+	rp.manifest, rp.objects = m, make(map[string][]byte)
+	return nil
+}
+
+// run polls the manifest until ctx is done
+func (rp *replica) run(ctx context.Context) {
+	ticker := time.NewTicker(rp.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := rp.poll(); err != nil {
+				log.Printf("Warning: replica poll: %v", err)
+			}
+		}
+	}
+}
+
+// object returns the response body of route in version, from memory or
+// else from the bucket
+func (rp *replica) object(version, route string) ([]byte, error) {
+	rp.mu.RLock()
+	body, ok := rp.objects[route]
+	rp.mu.RUnlock()
+	if ok {
+		return body, nil
+	}
+
+	data, err := rp.source.ReadFile(version + route + ".json.gz")
+	if err != nil {
+		return nil, err
+	}
+	// The HTTP client undoes the Content-Encoding of some buckets already
+	body = data
+	if len(data) > 1 && data[0] == 0x1f && data[1] == 0x8b {
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		if body, err = io.ReadAll(zr); err != nil {
+			return nil, err
+		}
+	}
+
+	rp.mu.Lock()
+	if rp.manifest.Version == version {
+		rp.objects[route] = body
+	}
+	rp.mu.Unlock()
+	return body, nil
+}
+
+// ServeHTTP serves the published representation of a route. Only the
+// routes as published are available, so query parameters are refused.
+func (rp *replica) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeError(w, r, http.StatusMethodNotAllowed, "read replicas only serve GET requests")
+		return
+	}
+	if r.URL.RawQuery != "" {
+		writeError(w, r, http.StatusBadRequest, "read replicas serve the published representations only, without query parameters")
+		return
+	}
+
+	rp.mu.RLock()
+	m := rp.manifest
+	rp.mu.RUnlock()
+	if m.Version == "" {
+		w.Header().Set("Retry-After", strconv.Itoa(int(rp.interval.Seconds())))
+		writeError(w, r, http.StatusServiceUnavailable, "no published version yet")
+		return
+	}
+
+	route := strings.TrimSuffix(r.URL.Path, "/")
+	if route == "" {
+		writeError(w, r, http.StatusNotFound, "no published data for "+r.URL.Path)
+		return
+	}
+	body, err := rp.object(m.Version, route)
+	if errors.Is(err, fs.ErrNotExist) {
+		writeError(w, r, http.StatusNotFound, "no published data for "+r.URL.Path)
+		return
+	}
+	if err != nil {
+		log.Printf("Error: replica read %s: %v", route, err)
+		writeError(w, r, http.StatusBadGateway, "error reading published data")
+		return
+	}
+
+	// Responses may change with the next version, so they are cached for
+	// one poll interval
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(int(rp.interval.Seconds())))
+	w.Header().Set("X-Snapshot-Version", m.Version)
+	w.Header().Set("Last-Modified", m.PublishedAt.UTC().Format(http.TimeFormat))
+	w.Write(body)
+}
+
+// runReplica serves the bucket at bucketURL, REPLICA_URL, as a read
+// replica until SIGTERM
+func runReplica(cfg Config, bucketURL string) error {
+	interval := defaultReplicaPollInterval
+	if d := cfg.Replica.PollInterval; d > 0 {
+		interval = d
+	}
+	drain := shutdownTimeout(cfg)
+
+	creds, err := objectCredentials("REPLICA", cfg.replicaAuth())
+	if err != nil {
+		return err
+	}
+	rp := newReplica(store.NewObject(bucketURL, creds), interval)
+	if err := rp.poll(); err != nil {
+		// Serve 503 until the first version is published
+		log.Printf("Warning: replica poll: %v", err)
+	}
+
+	tlsConf, err := tlsFromConfig(cfg)
+	if err != nil {
+		return err
+	}
+	port := listenPort(cfg)
+	ln, err := tlsConf.listen(port)
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go rp.run(ctx)
+	if err := tlsConf.serveHTTP(ctx, drain); err != nil {
+		return err
+	}
+
+	log.Printf("Read replica of %s listening on :%s", bucketURL, port)
+	accessLog, err := newAccessLogger(cfg.Server.AccessLog, os.Stderr)
+	if err != nil {
+		return err
+	}
+	origins, err := parseCORSOrigins(cfg.Server.CORSOrigins)
+	if err != nil {
+		return err
+	}
+	if trustedProxies, err = parseTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		return err
+	}
+	handler := requestIDMiddleware(accessLogMiddleware(accessLog, recoverMiddleware(corsMiddleware(origins, compressMiddleware(rp)))))
+	return serve(ctx, newHTTPServer(":"+port, handler), ln, drain)
+}
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jjway/rewatchableGamesApi-go/store"
+)
+
+func gzipped(t *testing.T, s string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write([]byte(s))
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReplica(t *testing.T) {
+	var mu sync.Mutex
+	objects := make(map[string][]byte)
+	bucket := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		data, ok := objects[r.URL.Path]
+		mu.Unlock()
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+	}))
+	defer bucket.Close()
+	publish := func(key string, data []byte) {
+		mu.Lock()
+		objects["/mirror/"+key] = data
+		mu.Unlock()
+	}
+
+	rp := newReplica(store.NewObject(bucket.URL+"/mirror", store.Credentials{}), time.Minute)
+	get := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		rp.ServeHTTP(rec, httptest.NewRequest("GET", target, nil))
+		return rec
+	}
+
+	if err := rp.poll(); err == nil {
+		t.Error("expected an error before the first publication")
+	}
+	if rec := get("/games/2024/1"); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503 before the first publication, got %d", rec.Code)
+	}
+
+	publish("v1/games/2024/1.json.gz", gzipped(t, `[{"id":"old"}]`))
+	publish("latest.json", []byte(`{"version":"v1","publishedAt":"2024-09-08T12:00:00Z","objects":1}`))
+	if err := rp.poll(); err != nil {
+		t.Fatal(err)
+	}
+	rec := get("/games/2024/1")
+	if rec.Code != http.StatusOK || rec.Body.String() != `[{"id":"old"}]` {
+		t.Fatalf("expected the published body, got %d %q", rec.Code, rec.Body.String())
+	}
+	if rec.Header().Get("X-Snapshot-Version") != "v1" || rec.Header().Get("Last-Modified") != "Sun, 08 Sep 2024 12:00:00 GMT" {
+		t.Errorf("unexpected headers %v", rec.Header())
+	}
+
+	// Objects are kept until the manifest names a new version
+	publish("v1/games/2024/1.json.gz", gzipped(t, `[{"id":"changed"}]`))
+	if rec := get("/games/2024/1"); rec.Body.String() != `[{"id":"old"}]` {
+		t.Errorf("expected the kept object, got %q", rec.Body.String())
+	}
+	publish("v2/games/2024/1.json.gz", []byte(`[{"id":"new"}]`))
+	publish("latest.json", []byte(`{"version":"v2","publishedAt":"2024-09-09T12:00:00Z","objects":1}`))
+	if err := rp.poll(); err != nil {
+		t.Fatal(err)
+	}
+	if rec := get("/games/2024/1"); rec.Body.String() != `[{"id":"new"}]` {
+		t.Errorf("expected the new version, got %q", rec.Body.String())
+	}
+
+	for target, status := range map[string]int{
+		"/games/2024/2":            http.StatusNotFound,
+		"/":                        http.StatusNotFound,
+		"/games/../latest":         http.StatusNotFound,
+		"/games/2024/1?sort=total": http.StatusBadRequest,
+	} {
+		if rec := get(target); rec.Code != status {
+			t.Errorf("%s: expected status %d, got %d", target, status, rec.Code)
+		}
+	}
+	rec = httptest.NewRecorder()
+	rp.ServeHTTP(rec, httptest.NewRequest("POST", "/games/2024/1", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected status 405 for POST, got %d", rec.Code)
+	}
+}
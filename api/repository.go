@@ -0,0 +1,265 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	jsoniter "github.com/json-iterator/go"
+
+	"github.com/jjway/rewatchableGamesApi-go/store"
+)
+
+// Repository persists the user data of the server, the votes, the
+// community votes on the games, the registered webhooks and the profiles,
+// as collections of JSON documents by key. The registries keep their data
+// in memory and write each change through.
+type Repository interface {
+	// Load returns the documents of collection by key
+	Load(ctx context.Context, collection string) (map[string][]byte, error)
+	// Put stores doc under key, replacing the document there
+	Put(ctx context.Context, collection, key string, doc []byte) error
+	// Delete removes the document under key, if any
+	Delete(ctx context.Context, collection, key string) error
+	// Location describes where collection is saved, for the dry runs,
+	// empty when it is not
+	Location(collection string) string
+	Close() error
+}
+
+// The collections of the user data
+const (
+	votesCollection     = "votes"
+	communityCollection = "community"
+	webhooksCollection  = "webhooks"
+	profilesCollection  = "profiles"
+)
+
+// openRepository opens the repository of USER_DATA_BACKEND:
+//
+//	file      the default, a JSON file per collection at VOTES_PATH,
+//	          COMMUNITY_PATH, WEBHOOKS_PATH and PROFILES_PATH, kept in
+//	          memory only when unset
+//	sqlite    the SQLite database at USER_DATA_DSN, for small self-hosts
+//	postgres  the PostgreSQL database of the USER_DATA_DSN connection
+//	          string, for deployments running several instances
+func openRepository(cfg Config) (Repository, error) {
+	backend := cfg.UserData.Backend
+	switch backend {
+	case "", "file":
+		return newFileRepository(map[string]string{
+			votesCollection:     cfg.UserData.VotesPath,
+			communityCollection: cfg.UserData.CommunityPath,
+			webhooksCollection:  cfg.UserData.WebhooksPath,
+			profilesCollection:  cfg.UserData.ProfilesPath,
+		}), nil
+	case "sqlite", "postgres":
+		dsn := cfg.UserData.DSN
+		if dsn == "" {
+			return nil, fmt.Errorf("USER_DATA_DSN is required for the %s user data backend", backend)
+		}
+		dsn, err := resolveSecret(dsn)
+		if err != nil {
+			return nil, err
+		}
+		return openSQLRepository(backend, dsn)
+	}
+	return nil, fmt.Errorf("unknown USER_DATA_BACKEND %q: must be file, sqlite or postgres", backend)
+}
+
+// fileRepository saves each collection as a JSON object of its documents
+// by key, rewritten on every change. Collections without a path are not
+// saved.
+type fileRepository struct {
+	mu    sync.Mutex
+	paths map[string]string
+	docs  map[string]map[string]jsoniter.RawMessage
+}
+
+func newFileRepository(paths map[string]string) *fileRepository {
+	return &fileRepository{paths: paths, docs: make(map[string]map[string]jsoniter.RawMessage)}
+}
+
+// collection returns the documents of name, read from its file the first
+// time. The caller holds r.mu.
+func (r *fileRepository) collection(name string) (map[string]jsoniter.RawMessage, error) {
+	if docs, ok := r.docs[name]; ok {
+		return docs, nil
+	}
+	docs := make(map[string]jsoniter.RawMessage)
+	if path := r.paths[name]; path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		if err == nil {
+			if err := json.Unmarshal(data, &docs); err != nil {
+				return nil, fmt.Errorf("parse %s: %w", path, err)
+			}
+		}
+	}
+	r.docs[name] = docs
+	return docs, nil
+}
+
+func (r *fileRepository) Load(_ context.Context, collection string) (map[string][]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	docs, err := r.collection(collection)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string][]byte, len(docs))
+	for key, doc := range docs {
+		out[key] = doc
+	}
+	return out, nil
+}
+
+// update applies change to collection and rewrites its file, leaving the
+// collection as it was when the write fails
+func (r *fileRepository) update(collection string, change func(map[string]jsoniter.RawMessage)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	path := r.paths[collection]
+	if path == "" {
+		return nil
+	}
+	docs, err := r.collection(collection)
+	if err != nil {
+		return err
+	}
+	next := make(map[string]jsoniter.RawMessage, len(docs)+1)
+	for key, doc := range docs {
+		next[key] = doc
+	}
+	change(next)
+	data, err := json.Marshal(next)
+	if err != nil {
+		return err
+	}
+	if err := store.WriteFileAtomic(path, data); err != nil {
+		return err
+	}
+	r.docs[collection] = next
+	return nil
+}
+
+func (r *fileRepository) Put(_ context.Context, collection, key string, doc []byte) error {
+	return r.update(collection, func(docs map[string]jsoniter.RawMessage) { docs[key] = doc })
+}
+
+func (r *fileRepository) Delete(_ context.Context, collection, key string) error {
+	return r.update(collection, func(docs map[string]jsoniter.RawMessage) { delete(docs, key) })
+}
+
+func (r *fileRepository) Location(collection string) string {
+	return r.paths[collection]
+}
+
+func (r *fileRepository) Close() error { return nil }
+
+// sqlRepository keeps the documents of every collection in one table of a
+// SQLite or PostgreSQL database:
+//
+//	CREATE TABLE user_data (
+//		collection TEXT NOT NULL,
+//		key        TEXT NOT NULL,
+//		doc        TEXT NOT NULL,
+//		PRIMARY KEY (collection, key)
+//	)
+type sqlRepository struct {
+	db      *sql.DB
+	dialect string // sqlite or postgres
+	name    string // for Location, without credentials
+}
+
+// sqlDrivers maps a dialect to its database/sql driver
+var sqlDrivers = map[string]string{"sqlite": "sqlite", "postgres": "pgx"}
+
+func openSQLRepository(dialect, dsn string) (*sqlRepository, error) {
+	db, err := sql.Open(sqlDrivers[dialect], dsn)
+	if err != nil {
+		return nil, err
+	}
+	if dialect == "sqlite" {
+		// One writer at a time, as SQLite allows
+		db.SetMaxOpenConns(1)
+	}
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS user_data (
+		collection TEXT NOT NULL,
+		key TEXT NOT NULL,
+		doc TEXT NOT NULL,
+		PRIMARY KEY (collection, key)
+	)`); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("create user_data table: %w", err)
+	}
+	name := dialect
+	if dialect == "sqlite" {
+		name += ":" + dsn
+	}
+	return &sqlRepository{db: db, dialect: dialect, name: name}, nil
+}
+
+// rebind turns the ? placeholders of query into the $1, $2... of
+// PostgreSQL
+func (r *sqlRepository) rebind(query string) string {
+	if r.dialect != "postgres" {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+func (r *sqlRepository) Load(ctx context.Context, collection string) (map[string][]byte, error) {
+	rows, err := r.db.QueryContext(ctx, r.rebind(`SELECT key, doc FROM user_data WHERE collection = ?`), collection)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	docs := make(map[string][]byte)
+	for rows.Next() {
+		var key, doc string
+		if err := rows.Scan(&key, &doc); err != nil {
+			return nil, err
+		}
+		docs[key] = []byte(doc)
+	}
+	return docs, rows.Err()
+}
+
+func (r *sqlRepository) Put(ctx context.Context, collection, key string, doc []byte) error {
+	_, err := r.db.ExecContext(ctx, r.rebind(`INSERT INTO user_data (collection, key, doc) VALUES (?, ?, ?)
+		ON CONFLICT (collection, key) DO UPDATE SET doc = excluded.doc`), collection, key, string(doc))
+	return err
+}
+
+func (r *sqlRepository) Delete(ctx context.Context, collection, key string) error {
+	_, err := r.db.ExecContext(ctx, r.rebind(`DELETE FROM user_data WHERE collection = ? AND key = ?`), collection, key)
+	return err
+}
+
+func (r *sqlRepository) Location(collection string) string {
+	return r.name + "#" + collection
+}
+
+func (r *sqlRepository) Close() error {
+	return r.db.Close()
+}
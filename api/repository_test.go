@@ -0,0 +1,125 @@
+package api
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testRepository runs the Repository contract against repo
+func testRepository(t *testing.T, repo Repository) {
+	t.Helper()
+	ctx := context.Background()
+	if docs, err := repo.Load(ctx, votesCollection); err != nil || len(docs) != 0 {
+		t.Fatalf("expected an empty collection, got %v, %v", docs, err)
+	}
+	for _, step := range []struct{ collection, key, doc string }{
+		{votesCollection, "2024", `{"window": 1}`},
+		{votesCollection, "2023", `{"window": 2}`},
+		{votesCollection, "2024", `{"window": 3}`},
+		{webhooksCollection, "2024", `{"id": "2024"}`},
+	} {
+		if err := repo.Put(ctx, step.collection, step.key, []byte(step.doc)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := repo.Delete(ctx, votesCollection, "2023"); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Delete(ctx, votesCollection, "1999"); err != nil {
+		t.Errorf("expected deleting a missing key to succeed, got %v", err)
+	}
+
+	docs, err := repo.Load(ctx, votesCollection)
+	if err != nil || len(docs) != 1 || string(docs["2024"]) != `{"window": 3}` {
+		t.Errorf("expected the latest 2024 document only, got %q, %v", docs, err)
+	}
+	if docs, _ := repo.Load(ctx, webhooksCollection); len(docs) != 1 {
+		t.Errorf("expected the collections apart, got %q", docs)
+	}
+}
+
+func TestFileRepository(t *testing.T) {
+	dir := t.TempDir()
+	paths := map[string]string{votesCollection: filepath.Join(dir, "votes.json"), webhooksCollection: filepath.Join(dir, "webhooks.json")}
+	testRepository(t, newFileRepository(paths))
+
+	// The files keep the format of VOTES_PATH and WEBHOOKS_PATH
+	data, err := os.ReadFile(paths[votesCollection])
+	if err != nil || string(data) != `{"2024":{"window": 3}}` {
+		t.Errorf("expected the documents by key, got %s, %v", data, err)
+	}
+	reopened := newFileRepository(paths)
+	if docs, _ := reopened.Load(context.Background(), votesCollection); string(docs["2024"]) != `{"window": 3}` {
+		t.Errorf("expected the saved documents back, got %q", docs)
+	}
+
+	memory := newFileRepository(nil)
+	if err := memory.Put(context.Background(), votesCollection, "2024", []byte(`{}`)); err != nil || memory.Location(votesCollection) != "" {
+		t.Errorf("expected a collection without a path not to be saved, got %v", err)
+	}
+}
+
+func TestSQLiteRepository(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "user.db")
+	repo, err := openRepository(configOf(t, map[string]string{"USER_DATA_BACKEND": "sqlite", "USER_DATA_DSN": path}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	testRepository(t, repo)
+	if loc := repo.Location(votesCollection); loc != "sqlite:"+path+"#votes" {
+		t.Errorf("unexpected location %q", loc)
+	}
+
+	// The vote book keeps its seasons across a restart
+	repo.Delete(context.Background(), votesCollection, "2024")
+	b, err := loadVoteBook(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	if err := b.open("2025", VotingWindow{Categories: []string{"gameOfTheYear"}, OpensAt: now, ClosesAt: now.Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.cast("2025", "gameOfTheYear", "u1", "game1", now); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := loadVoteBook(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, tally, ok := reloaded.tally("2025"); !ok || tally["gameOfTheYear"]["game1"] != 1 {
+		t.Errorf("expected the vote back, got %v", tally)
+	}
+}
+
+func TestPostgresRepository(t *testing.T) {
+	dsn := os.Getenv("USER_DATA_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("USER_DATA_TEST_POSTGRES_DSN is not set")
+	}
+	repo, err := openSQLRepository("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	repo.db.Exec(`DELETE FROM user_data WHERE collection IN ('votes', 'webhooks')`)
+	testRepository(t, repo)
+}
+
+func TestOpenRepository(t *testing.T) {
+	for _, env := range []map[string]string{
+		{"USER_DATA_BACKEND": "mongo"},
+		{"USER_DATA_BACKEND": "postgres"},
+	} {
+		if _, err := openRepository(configOf(t, env)); err == nil {
+			t.Errorf("%v: expected an error", env)
+		}
+	}
+	if q := (&sqlRepository{dialect: "postgres"}).rebind(`DELETE FROM t WHERE a = ? AND b = ?`); q != `DELETE FROM t WHERE a = $1 AND b = $2` {
+		t.Errorf("unexpected rebind %q", q)
+	}
+}
@@ -0,0 +1,171 @@
+package api
+
+import (
+	"container/list"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxCachedResponses bounds the number of encoded responses kept across
+// all weeks
+const maxCachedResponses = 2048
+
+// cachedResponse is a pre-encoded response body and its validators
+type cachedResponse struct {
+	body         []byte
+	etag         string
+	lastModified time.Time
+	createdAt    time.Time
+}
+
+// responseCache holds encoded responses per week file and query, so hot
+// weeks are served without re-rating or re-encoding. Entries are dropped
+// whenever the underlying week file is reloaded or evicted, and the least
+// recently used ones once there are more than maxEntries.
+type responseCache struct {
+	mu         sync.Mutex
+	maxEntries int
+
+	order   *list.List // of *responseItem, most recently used first
+	entries map[string]map[string]*list.Element
+}
+
+type responseItem struct {
+	name, key string
+	resp      cachedResponse
+}
+
+var responses = newResponseCache()
+
+func newResponseCache() *responseCache {
+	return &responseCache{
+		maxEntries: maxCachedResponses,
+		order:      list.New(),
+		entries:    make(map[string]map[string]*list.Element),
+	}
+}
+
+// get returns the cached response for the week file name and query key
+// and marks it as recently used
+func (c *responseCache) get(name, key string) (cachedResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[name][key]
+	if !ok {
+		return cachedResponse{}, false
+	}
+	resp := el.Value.(*responseItem).resp
+	if cacheTTL > 0 && clock.Now().Sub(resp.createdAt) >= cacheTTL {
+		return cachedResponse{}, false
+	}
+	c.order.MoveToFront(el)
+	return resp, true
+}
+
+// put caches body, computing its ETag, and returns the stored response
+func (c *responseCache) put(name, key string, body []byte, lastModified time.Time) cachedResponse {
+	resp := cachedResponse{
+		body:         body,
+		etag:         etagFor(body),
+		lastModified: lastModified,
+		createdAt:    clock.Now(),
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	byKey, ok := c.entries[name]
+	if !ok {
+		byKey = make(map[string]*list.Element)
+		c.entries[name] = byKey
+	}
+	if el, ok := byKey[key]; ok {
+		el.Value.(*responseItem).resp = resp
+		c.order.MoveToFront(el)
+		return resp
+	}
+	byKey[key] = c.order.PushFront(&responseItem{name: name, key: key, resp: resp})
+	for c.maxEntries > 0 && c.order.Len() > c.maxEntries {
+		c.removeElement(c.order.Back())
+	}
+	return resp
+}
+
+// removeElement drops an entry. The caller holds c.mu.
+func (c *responseCache) removeElement(el *list.Element) {
+	item := el.Value.(*responseItem)
+	c.order.Remove(el)
+	delete(c.entries[item.name], item.key)
+	if len(c.entries[item.name]) == 0 {
+		delete(c.entries, item.name)
+	}
+}
+
+// invalidate drops every cached response derived from the week file name
+func (c *responseCache) invalidate(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, el := range c.entries[name] {
+		c.removeElement(el)
+	}
+}
+
+// invalidatePrefix drops the cached responses of every week file whose
+// name starts with prefix
+func (c *responseCache) invalidatePrefix(prefix string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for name, byKey := range c.entries {
+		if strings.HasPrefix(name, prefix) {
+			for _, el := range byKey {
+				c.removeElement(el)
+			}
+		}
+	}
+}
+
+// len returns the number of cached responses
+func (c *responseCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// weekQueryParams returns the query parameters a week response depends
+// on. Anything else is ignored, and left out of the cache key and links.
+func weekQueryParams() []string {
+	params := []string{
+		"algo", "asOf", "excludeBlowouts", "explainFilters", "format", "links",
+		"matchupQuality", "minPercentile", "normalize", "order", "percentileScope",
+		"q", "sort", "spoilerFree", "teams",
+	}
+	for _, field := range sortedFieldNames() {
+		params = append(params, "min"+strings.ToUpper(field[:1])+field[1:])
+	}
+	return params
+}
+
+// canonicalQuery encodes the first value of each of params set in
+// values, sorted by name
+func canonicalQuery(values url.Values, params []string) string {
+	kept := make(url.Values)
+	for _, param := range params {
+		if v, ok := values[param]; ok && len(v) > 0 {
+			kept.Set(param, v[0])
+		}
+	}
+	return kept.Encode()
+}
+
+// weekResponseKey is the response cache key of a week request: the path
+// prefix its links keep, the version rating it and the query parameters it
+// recognizes
+func weekResponseKey(r *http.Request, rater Rater) string {
+	key := rater.Version() + "?" + canonicalQuery(r.URL.Query(), weekQueryParams())
+	if prefix := pathPrefix(r); prefix != "" {
+		key = prefix + " " + key
+	}
+	return key
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWeekResponsesAreCached(t *testing.T) {
+	useTestStore(t, setupTestData(t))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /games/{year}/{week}", handleGamesYearWeek)
+
+	get := func(url string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", url, nil)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	first := get("/games/2024/1?sort=totalRating").Body.String()
+	if _, ok := responses.get("2024/1.json", "v1?sort=totalRating"); !ok {
+		t.Fatal("expected encoded response to be cached")
+	}
+
+	// Poison the raw cache: a cached response must not look at it
+	cache.set("2024/1.json", cacheEntry{})
+	if got := get("/games/2024/1?sort=totalRating").Body.String(); got != first {
+		t.Errorf("expected identical cached body, got %q", got)
+	}
+
+	// Reloading the week drops its cached responses
+	setCached("2024/1.json", nil, 0, "")
+	if _, ok := responses.get("2024/1.json", "v1?sort=totalRating"); ok {
+		t.Error("expected responses to be invalidated with the raw cache")
+	}
+}
+
+func TestWeekResponseKey(t *testing.T) {
+	useTestStore(t, setupTestData(t))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /games/{year}/{week}", handleGamesYearWeek)
+	for _, target := range []string{"/games/2024/1?x=1", "/games/2024/1?x=2&sort=totalRating", "/games/2024/1?sort=totalRating&x=3", "/games/2024/1?sort=totalRating&sort=offensiveRating"} {
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", target, nil))
+	}
+	if n := responses.len(); n != 2 {
+		t.Errorf("expected the unknown parameters to share the cached responses, got %d", n)
+	}
+	if _, ok := responses.get("2024/1.json", "v1?sort=totalRating"); !ok {
+		t.Error("expected the key of the recognized parameters")
+	}
+}
+
+func TestResponseCacheBound(t *testing.T) {
+	c := newResponseCache()
+	c.maxEntries = 2
+	c.put("2024/1.json", "a", []byte("a"), time.Time{})
+	c.put("2024/2.json", "b", []byte("b"), time.Time{})
+	c.get("2024/1.json", "a")
+	c.put("2024/2.json", "c", []byte("c"), time.Time{})
+	if _, ok := c.get("2024/2.json", "b"); ok || c.len() != 2 {
+		t.Errorf("expected the least recently used response to go, %d left", c.len())
+	}
+	if _, ok := c.get("2024/1.json", "a"); !ok {
+		t.Error("expected the recently used response to stay")
+	}
+	c.invalidate("2024/2.json")
+	if c.len() != 1 {
+		t.Errorf("expected the week's responses to be dropped, %d left", c.len())
+	}
+}
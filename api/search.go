@@ -0,0 +1,211 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// searchIndex maps each word of the team names of the games, lowercased,
+// to the games it appears in, built at preload time along with the team
+// index
+var (
+	searchIndex   = make(map[string][]gameLocation)
+	searchIndexMu sync.RWMutex
+)
+
+// searchStopWords are the words of the matchup separators
+var searchStopWords = map[string]bool{"at": true, "vs": true}
+
+// searchTokens splits s into lowercase words of letters and digits,
+// without the matchup separators
+func searchTokens(s string) []string {
+	var tokens []string
+	for _, word := range strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if !searchStopWords[word] {
+			tokens = append(tokens, word)
+		}
+	}
+	return tokens
+}
+
+// indexSearch adds the games of a week to the search index, under the
+// words of their short and full names
+func indexSearch(season, week string, games []GameStats) {
+	searchIndexMu.Lock()
+	defer searchIndexMu.Unlock()
+	for _, g := range games {
+		if g.ID == "" {
+			continue
+		}
+		loc := gameLocation{Season: season, Week: week, ID: g.ID}
+		seen := make(map[string]bool)
+		for _, token := range searchTokens(g.ShortName + " " + g.FullName) {
+			if !seen[token] {
+				seen[token] = true
+				searchIndex[token] = append(searchIndex[token], loc)
+			}
+		}
+	}
+}
+
+func unindexSearch(season, week string) {
+	searchIndexMu.Lock()
+	defer searchIndexMu.Unlock()
+	for token, locs := range searchIndex {
+		kept := locs[:0]
+		for _, loc := range locs {
+			if loc.Season != season || loc.Week != week {
+				kept = append(kept, loc)
+			}
+		}
+		if len(kept) == 0 {
+			delete(searchIndex, token)
+		} else {
+			searchIndex[token] = kept
+		}
+	}
+}
+
+// searchGames returns the games whose names have a word starting with each
+// of the words of q, "chiefs bills" finding the games between the two
+// teams and "kan" those of Kansas City, of season when not empty
+func searchGames(q, season string) []gameLocation {
+	tokens := searchTokens(q)
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	searchIndexMu.RLock()
+	defer searchIndexMu.RUnlock()
+	var matched map[gameLocation]bool
+	for _, token := range tokens {
+		next := make(map[gameLocation]bool)
+		for word, locs := range searchIndex {
+			if !strings.HasPrefix(word, token) {
+				continue
+			}
+			for _, loc := range locs {
+				if (season == "" || loc.Season == season) && (matched == nil || matched[loc]) {
+					next[loc] = true
+				}
+			}
+		}
+		if len(next) == 0 {
+			return nil
+		}
+		matched = next
+	}
+
+	locs := make([]gameLocation, 0, len(matched))
+	for loc := range matched {
+		locs = append(locs, loc)
+	}
+	sort.Slice(locs, func(i, j int) bool {
+		a, b := locs[i], locs[j]
+		if a.Season != b.Season {
+			return a.Season < b.Season
+		}
+		wa, _ := weekOrder(a.Week)
+		wb, _ := weekOrder(b.Week)
+		if wa != wb {
+			return wa < wb
+		}
+		return a.ID < b.ID
+	})
+	return locs
+}
+
+// handleSearch serves the rated games matching ?q=, words of the team
+// names, in ?year= when set, with the sort, filter and pagination
+// parameters of the game lists
+func handleSearch(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
+	q := values.Get("q")
+	if len(searchTokens(q)) == 0 {
+		writeQueryError(w, r, &QueryError{Param: "q", Value: q, Message: "must have at least one word to search for"})
+		return
+	}
+	year := values.Get("year")
+	if _, err := strconv.Atoi(year); year != "" && err != nil {
+		writeQueryError(w, r, &QueryError{Param: "year", Value: year, Message: "must be a season year"})
+		return
+	}
+
+	// ?q= is the search here, not a filter expression
+	listValues := url.Values{}
+	for key, v := range values {
+		if key != "q" {
+			listValues[key] = v
+		}
+	}
+	query, qerr := parseGameQuery(listValues)
+	if qerr != nil {
+		writeQueryError(w, r, qerr)
+		return
+	}
+	rater, qerr := raterFor(r)
+	if qerr != nil {
+		writeQueryError(w, r, qerr)
+		return
+	}
+	page, paginated, qerr := parsePagination(values)
+	if qerr != nil {
+		writeQueryError(w, r, qerr)
+		return
+	}
+	format, qerr := responseFormat(r)
+	if qerr != nil {
+		writeQueryError(w, r, qerr)
+		return
+	}
+	w.Header().Add("Vary", "Accept")
+
+	games := make([]ProcessedGameStats, 0)
+	for _, loc := range searchGames(q, year) {
+		g, err := findGame(loc.Season, loc.Week, loc.ID)
+		if err != nil {
+			continue
+		}
+		p := processGame(rater, g)
+		p.setLocation(loc.Season, loc.Week)
+		games = append(games, p)
+	}
+	games, reports := query.explain(games)
+
+	if format != formatJSON {
+		if paginated {
+			games = paginate(games, page).Items
+		}
+		if err := writeGameRows(w, r, format, "search", games); err != nil {
+			log.Printf("Warning: streaming %s: %v", r.URL.RequestURI(), err)
+		}
+		return
+	}
+
+	var body any = games
+	switch {
+	case isSpoilerFree(r) && paginated:
+		body = paginate(spoilerFreeGames(games), page)
+	case isSpoilerFree(r):
+		body = spoilerFreeGames(games)
+	case paginated:
+		body = paginate(games, page)
+	}
+	if isExplainFilters(r) {
+		body = Explained{Games: body, Matched: len(games), Filters: reports}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	setCacheHeaders(w, "search")
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		writeError(w, r, http.StatusInternalServerError, "error encoding response")
+	}
+}
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestSearchTokens(t *testing.T) {
+	got := searchTokens("Kansas City Chiefs at Buffalo Bills, KC @ BUF")
+	want := []string{"kansas", "city", "chiefs", "buffalo", "bills", "kc", "buf"}
+	if !slices.Equal(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	dir := setupTestData(t)
+	useTestStore(t, dir)
+	os.MkdirAll(filepath.Join(dir, "2023"), 0755)
+	weeks := map[string]string{
+		"2023/5.json": `[{"id": "a", "shortName": "BUF @ KC", "fullName": "Buffalo Bills at Kansas City Chiefs"},
+			{"id": "b", "shortName": "NYJ @ MIA", "fullName": "New York Jets at Miami Dolphins"}]`,
+		"2024/3.json": `[{"id": "c", "shortName": "KC @ BUF", "fullName": "Kansas City Chiefs at Buffalo Bills"},
+			{"id": "d", "shortName": "KC @ LV", "fullName": "Kansas City Chiefs at Las Vegas Raiders"}]`,
+	}
+	for name, data := range weeks {
+		os.WriteFile(filepath.Join(dir, name), []byte(data), 0644)
+		games, err := loadGameStats(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		indexFile(name, games)
+		t.Cleanup(func() { unindexFile(name) })
+	}
+
+	search := func(target string) (int, []ProcessedGameStats) {
+		rec := httptest.NewRecorder()
+		handleSearch(rec, httptest.NewRequest("GET", target, nil))
+		var games []ProcessedGameStats
+		if rec.Code == http.StatusOK {
+			if err := json.Unmarshal(rec.Body.Bytes(), &games); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return rec.Code, games
+	}
+	ids := func(games []ProcessedGameStats) []string {
+		var ids []string
+		for _, g := range games {
+			ids = append(ids, g.ID)
+		}
+		slices.Sort(ids)
+		return ids
+	}
+
+	for _, tt := range []struct {
+		target string
+		want   []string
+	}{
+		{"/search?q=chiefs+bills", []string{"a", "c"}},
+		{"/search?q=Chiefs+Bills&year=2023", []string{"a"}},
+		{"/search?q=kan", []string{"a", "c", "d"}},
+		{"/search?q=kc+raiders", []string{"d"}},
+		{"/search?q=jets+chiefs", nil},
+		{"/search?q=chiefs&teams=LV,nyj", []string{"d"}},
+	} {
+		code, games := search(tt.target)
+		if code != http.StatusOK || !slices.Equal(ids(games), tt.want) {
+			t.Errorf("%s: expected %v, got %d %v", tt.target, tt.want, code, ids(games))
+		}
+	}
+
+	code, games := search("/search?q=chiefs&year=2024&sort=totalRating")
+	if code != http.StatusOK || len(games) != 2 || games[0].Season != "2024" || games[0].Slug == "" {
+		t.Errorf("expected the located games of 2024, got %d %+v", code, games)
+	}
+	for _, target := range []string{"/search", "/search?q=+@+", "/search?q=kc&year=last", "/search?q=kc&sort=bogus"} {
+		if code, _ := search(target); code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", target, code)
+		}
+	}
+
+	// Weeks leaving the cache leave the index
+	unindexFile("2024/3.json")
+	if _, games := search("/search?q=raiders"); len(games) != 0 {
+		t.Errorf("expected no game once the week is unindexed, got %v", ids(games))
+	}
+}
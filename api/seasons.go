@@ -0,0 +1,153 @@
+package api
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/jjway/rewatchableGamesApi-go/store"
+)
+
+// SeasonSummary lists the weeks available for a season
+type SeasonSummary struct {
+	Season string        `json:"season"`
+	Games  int           `json:"games"`
+	Weeks  []WeekSummary `json:"weeks"`
+}
+
+// WeekSummary is one available week and its number of games. Week is the
+// week number, or the name of a playoff round.
+type WeekSummary struct {
+	Week  string `json:"week"`
+	Label string `json:"label"`
+	Games int    `json:"games"`
+}
+
+// availableSeasons lists the cached weeks by season, oldest first. The
+// cache catalog holds every week file found by the preload scan and those
+// loaded or ingested since, evicted or not.
+func availableSeasons() []SeasonSummary {
+	bySeason := make(map[string]*SeasonSummary)
+	for name, count := range cache.weekCounts() {
+		if !store.IsWeekFile(name) {
+			continue
+		}
+		season, file, _ := strings.Cut(name, "/")
+		week := strings.TrimSuffix(file, ".json")
+		if !isValidWeek(week) {
+			continue
+		}
+		s, ok := bySeason[season]
+		if !ok {
+			s = &SeasonSummary{Season: season}
+			bySeason[season] = s
+		}
+		s.Games += count
+		s.Weeks = append(s.Weeks, WeekSummary{Week: week, Label: weekLabel(week), Games: count})
+	}
+
+	seasons := make([]SeasonSummary, 0, len(bySeason))
+	for _, s := range bySeason {
+		sort.Slice(s.Weeks, func(i, j int) bool {
+			wi, _ := weekOrder(s.Weeks[i].Week)
+			wj, _ := weekOrder(s.Weeks[j].Week)
+			return wi < wj
+		})
+		seasons = append(seasons, *s)
+	}
+	sort.Slice(seasons, func(i, j int) bool { return seasons[i].Season < seasons[j].Season })
+	return seasons
+}
+
+// handleSeasons serves the available seasons and weeks, so clients do not
+// have to probe week by week until a 404
+func handleSeasons(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	setCacheHeaders(w, "season")
+	if err := json.NewEncoder(w).Encode(availableSeasons()); err != nil {
+		writeError(w, r, http.StatusInternalServerError, "error encoding response")
+	}
+}
+
+// seasonGames returns the games of every loaded week of a season rated with
+// rater, tagged with their season and week
+func seasonGames(ctx context.Context, rater Rater, year string) []ProcessedGameStats {
+	var games []ProcessedGameStats
+	for _, sw := range loadSeason(ctx, year) {
+		for _, g := range rateGames(ctx, rater, sw.Games) {
+			g.setLocation(year, sw.Week)
+			games = append(games, g)
+		}
+	}
+	return games
+}
+
+// handleSeasonGames serves the processed games of a season with the same
+// sort and filter parameters as /games/{year}/{week}, paginated on demand
+func handleSeasonGames(w http.ResponseWriter, r *http.Request) {
+	year := r.PathValue("year")
+
+	rater, qerr := raterFor(r)
+	if qerr != nil {
+		writeQueryError(w, r, qerr)
+		return
+	}
+	query, qerr := parseGameQuery(r.URL.Query())
+	if qerr != nil {
+		writeQueryError(w, r, qerr)
+		return
+	}
+	page, paginated, qerr := parsePagination(r.URL.Query())
+	if qerr != nil {
+		writeQueryError(w, r, qerr)
+		return
+	}
+	format, qerr := responseFormat(r)
+	if qerr != nil {
+		writeQueryError(w, r, qerr)
+		return
+	}
+	w.Header().Add("Vary", "Accept")
+
+	games := seasonGames(r.Context(), rater, year)
+	if len(games) == 0 {
+		writeError(w, r, http.StatusNotFound, "no data for season "+year)
+		return
+	}
+	games, reports := query.explain(games)
+
+	if isCrawler(r) {
+		writeCrawlerSummary(w, r, games)
+		return
+	}
+	if format != formatJSON {
+		if paginated {
+			games = paginate(games, page).Items
+		}
+		if err := writeGameRows(w, r, format, "season", games); err != nil {
+			log.Printf("Warning: streaming %s: %v", r.URL.RequestURI(), err)
+		}
+		return
+	}
+
+	var body any = games
+	switch {
+	case isSpoilerFree(r) && paginated:
+		body = paginate(spoilerFreeGames(games), page)
+	case isSpoilerFree(r):
+		body = spoilerFreeGames(games)
+	case paginated:
+		body = paginate(games, page)
+	}
+	if isExplainFilters(r) {
+		body = Explained{Games: body, Matched: len(games), Filters: reports}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	setCacheHeaders(w, "season")
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		writeError(w, r, http.StatusInternalServerError, "error encoding response")
+	}
+}
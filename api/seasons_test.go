@@ -0,0 +1,135 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestHandleSeasonGames(t *testing.T) {
+	useTestStore(t, setupTestData(t))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /seasons/{year}/games", handleSeasonGames)
+
+	req := httptest.NewRequest("GET", "/seasons/2024/games?limit=1&minTotalRating=1", nil)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	var page Page[ProcessedGameStats]
+	if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if page.Total != 2 || len(page.Items) != 1 {
+		t.Errorf("unexpected envelope %+v", page)
+	}
+	if page.Items[0].Season != "2024" || page.Items[0].Week == "" {
+		t.Errorf("expected season and week on items, got %+v", page.Items[0])
+	}
+}
+
+func TestRawSeasonDumpIsGated(t *testing.T) {
+	useTestStore(t, setupTestData(t))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /games/{year}", handleGamesYear)
+
+	req := httptest.NewRequest("GET", "/games/2024?limit=5", nil)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusPermanentRedirect {
+		t.Fatalf("expected status 308, got %d", rec.Code)
+	}
+	if loc := rec.Header().Get("Location"); loc != "/seasons/2024/games?limit=5" {
+		t.Errorf("unexpected redirect target %q", loc)
+	}
+	if rec.Header().Get("Deprecation") != "@1792195200" {
+		t.Error("expected Deprecation header")
+	}
+
+	req = httptest.NewRequest("GET", "/games/2024?raw=true", nil)
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	var raw []GameStats
+	if err := json.Unmarshal(rec.Body.Bytes(), &raw); err != nil {
+		t.Fatalf("failed to parse raw response: %v", err)
+	}
+	if len(raw) != 2 {
+		t.Errorf("expected 2 raw games, got %d", len(raw))
+	}
+	if rec.Header().Get("Cache-Control") != cachePolicies["raw"].header() {
+		t.Errorf("expected raw cache policy, got %q", rec.Header().Get("Cache-Control"))
+	}
+}
+
+func TestHandleSeasons(t *testing.T) {
+	dir := setupTestData(t)
+	useTestStore(t, dir)
+	if err := os.MkdirAll(filepath.Join(dir, "2023"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, week := range []string{"10", "wildcard"} {
+		if err := os.WriteFile(filepath.Join(dir, "2023", week+".json"), []byte(testData), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	preloadCache(dataStore)
+
+	rec := httptest.NewRecorder()
+	handleSeasons(rec, httptest.NewRequest("GET", "/seasons", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	var seasons []SeasonSummary
+	if err := json.Unmarshal(rec.Body.Bytes(), &seasons); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+
+	want := []SeasonSummary{
+		{Season: "2023", Games: 2, Weeks: []WeekSummary{
+			{Week: "10", Label: "Week 10", Games: 1},
+			{Week: "wildcard", Label: "Wild Card", Games: 1},
+		}},
+		{Season: "2024", Games: 2, Weeks: []WeekSummary{
+			{Week: "1", Label: "Week 1", Games: 1},
+			{Week: "2", Label: "Week 2", Games: 1},
+		}},
+	}
+	if !reflect.DeepEqual(seasons, want) {
+		t.Errorf("expected %+v, got %+v", want, seasons)
+	}
+}
+
+func TestSeasonWithPlayoffs(t *testing.T) {
+	dir := setupTestData(t)
+	useTestStore(t, dir)
+	// The divisional round is not played yet; the Super Bowl file is stray
+	for _, week := range []string{"wildcard", "superbowl"} {
+		if err := os.WriteFile(filepath.Join(dir, "2024", week+".json"), []byte(testData), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var got []string
+	for _, sw := range loadSeason(context.Background(), "2024") {
+		got = append(got, sw.Week)
+	}
+	if want := []string{"1", "2", "wildcard"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected weeks %v, got %v", want, got)
+	}
+
+	games := seasonGames(context.Background(), raters[defaultAlgorithm], "2024")
+	last := games[len(games)-1]
+	if last.Week != "wildcard" || last.WeekLabel != "Wild Card" || last.Slug != "2024-wildcard-a-b" {
+		t.Errorf("unexpected playoff game %+v", last)
+	}
+}
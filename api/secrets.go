@@ -0,0 +1,180 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+)
+
+// Secrets in the config files, such as API keys, SMTP passwords and
+// webhook signing keys, can be references instead of values:
+//
+//	env:NAME    the value of the environment variable NAME
+//	file:PATH   the content of the file at PATH, without its final newline
+//
+// The token variables take a NAME_FILE variant the same way, as with
+// Docker and Kubernetes secrets mounted as files.
+const (
+	secretEnvPrefix  = "env:"
+	secretFilePrefix = "file:"
+)
+
+// resolveSecret returns the value v references, or v itself when it is
+// not a reference
+func resolveSecret(v string) (string, error) {
+	switch {
+	case strings.HasPrefix(v, secretEnvPrefix):
+		name := strings.TrimPrefix(v, secretEnvPrefix)
+		s := os.Getenv(name)
+		if s == "" {
+			return "", fmt.Errorf("secret %s: environment variable %s is not set", v, name)
+		}
+		return s, nil
+	case strings.HasPrefix(v, secretFilePrefix):
+		data, err := os.ReadFile(strings.TrimPrefix(v, secretFilePrefix))
+		if err != nil {
+			return "", fmt.Errorf("secret %s: %w", v, err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+	return v, nil
+}
+
+// resolveSecrets resolves each of the fields in place, stopping at the
+// first reference that cannot be
+func resolveSecrets(fields ...*string) error {
+	for _, f := range fields {
+		v, err := resolveSecret(*f)
+		if err != nil {
+			return err
+		}
+		*f = v
+	}
+	return nil
+}
+
+// settingSecret returns the secret of a setting, read from the file of
+// its _FILE variant when that is set instead
+func settingSecret(value, file string) (string, error) {
+	if file != "" {
+		return resolveSecret(secretFilePrefix + file)
+	}
+	return resolveSecret(value)
+}
+
+// decryptKeys locate the keys of the encrypted config files, from the
+// environment until the settings are resolved
+var decryptKeys = struct {
+	ageIdentityFile string
+	sopsBinary      string
+	sopsAgeKeyFile  string
+}{os.Getenv("AGE_IDENTITY_FILE"), os.Getenv("SOPS_BINARY"), os.Getenv("SOPS_AGE_KEY_FILE")}
+
+// setDecryptKeys takes the decryption settings of cfg
+func setDecryptKeys(cfg Config) {
+	decryptKeys.ageIdentityFile = cfg.Secrets.AgeIdentityFile
+	decryptKeys.sopsBinary = cfg.Secrets.SOPSBinary
+	decryptKeys.sopsAgeKeyFile = cfg.Secrets.SOPSAgeKeyFile
+}
+
+// ageHeader starts the binary age format; armored files start with
+// armor.Header
+const ageHeader = "age-encryption.org/v1\n"
+
+// sopsMetadata matches the metadata block SOPS adds to the YAML and JSON
+// files it encrypts, whose values it replaces with ENC[AES256_GCM,...]
+var sopsMetadata = regexp.MustCompile(`(?m)^sops:|"sops"\s*:\s*\{`)
+
+// sopsTimeout bounds a sops --decrypt run
+const sopsTimeout = 30 * time.Second
+
+// readConfigFile reads a config file, decrypting it first when it was
+// encrypted with age, using the identities at AGE_IDENTITY_FILE, or with
+// SOPS, by running the sops command with its usual key settings. Plain
+// files are returned as they are, so encryption stays optional.
+func readConfigFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case bytes.HasPrefix(data, []byte(ageHeader)), bytes.HasPrefix(bytes.TrimSpace(data), []byte(armor.Header)):
+		return decryptAge(path, data)
+	case sopsMetadata.Match(data) && bytes.Contains(data, []byte("ENC[AES256_GCM,")):
+		return decryptSOPS(path)
+	}
+	return data, nil
+}
+
+// configExt is the extension giving the format of a config file, ignoring
+// the .age suffix of an encrypted one
+func configExt(path string) string {
+	return filepath.Ext(strings.TrimSuffix(path, ".age"))
+}
+
+// decryptAge decrypts the age file at path with the identities of
+// AGE_IDENTITY_FILE, a file of AGE-SECRET-KEY lines as age-keygen writes
+// them
+func decryptAge(path string, data []byte) ([]byte, error) {
+	keyFile := decryptKeys.ageIdentityFile
+	if keyFile == "" {
+		return nil, fmt.Errorf("%s is encrypted with age: set AGE_IDENTITY_FILE to the identity decrypting it", path)
+	}
+	f, err := os.Open(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("AGE_IDENTITY_FILE: %w", err)
+	}
+	defer f.Close()
+	identities, err := age.ParseIdentities(bufio.NewReader(f))
+	if err != nil {
+		return nil, fmt.Errorf("AGE_IDENTITY_FILE %s: %w", keyFile, err)
+	}
+
+	var in io.Reader = bytes.NewReader(data)
+	if !bytes.HasPrefix(data, []byte(ageHeader)) {
+		in = armor.NewReader(bytes.NewReader(bytes.TrimSpace(data)))
+	}
+	r, err := age.Decrypt(in, identities...)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt %s: %w", path, err)
+	}
+	return io.ReadAll(r)
+}
+
+// decryptSOPS decrypts the SOPS file at path with the sops command, or
+// SOPS_BINARY, which finds its keys in SOPS_AGE_KEY_FILE, the cloud KMS
+// credentials or the PGP keyring
+func decryptSOPS(path string) ([]byte, error) {
+	bin := decryptKeys.sopsBinary
+	if bin == "" {
+		bin = "sops"
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), sopsTimeout)
+	defer cancel()
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, bin, "--decrypt", path)
+	cmd.Stderr = &stderr
+	if decryptKeys.sopsAgeKeyFile != "" {
+		cmd.Env = append(os.Environ(), "SOPS_AGE_KEY_FILE="+decryptKeys.sopsAgeKeyFile)
+	}
+	out, err := cmd.Output()
+	if errors.Is(err, exec.ErrNotFound) {
+		return nil, fmt.Errorf("%s is encrypted with SOPS: install sops or set SOPS_BINARY", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("sops --decrypt %s: %v: %s", path, err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
@@ -0,0 +1,147 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+)
+
+func TestResolveSecret(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "token")
+	os.WriteFile(path, []byte("from-file\n"), 0600)
+	t.Setenv("TEST_SECRET", "from-env")
+
+	for _, tc := range []struct {
+		in, want string
+		err      bool
+	}{
+		{"plain", "plain", false},
+		{"env:TEST_SECRET", "from-env", false},
+		{"file:" + path, "from-file", false},
+		{"env:TEST_SECRET_UNSET", "", true},
+		{"file:" + filepath.Join(dir, "missing"), "", true},
+	} {
+		got, err := resolveSecret(tc.in)
+		if got != tc.want || (err != nil) != tc.err {
+			t.Errorf("resolveSecret(%q) = %q, %v", tc.in, got, err)
+		}
+	}
+
+	if v, _ := settingSecret("inline", ""); v != "inline" {
+		t.Errorf("expected the setting without _FILE, got %q", v)
+	}
+	if v, _ := settingSecret("inline", path); v != "from-file" {
+		t.Errorf("expected _FILE to win, got %q", v)
+	}
+}
+
+// encryptAge encrypts data to id, armored or not
+func encryptAge(t *testing.T, id *age.X25519Identity, data []byte, armored bool) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var out io.WriteCloser = nopWriteCloser{&buf}
+	if armored {
+		out = armor.NewWriter(&buf)
+	}
+	w, err := age.Encrypt(out, id.Recipient())
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(data)
+	w.Close()
+	out.Close()
+	return buf.Bytes()
+}
+
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
+
+// useDecryptKeys restores the decryption settings after the test
+func useDecryptKeys(t *testing.T) {
+	t.Helper()
+	old := decryptKeys
+	t.Cleanup(func() { decryptKeys = old })
+}
+
+func TestReadConfigFileAge(t *testing.T) {
+	useDecryptKeys(t)
+	dir := t.TempDir()
+	id, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(dir, "key.txt")
+	os.WriteFile(keyFile, []byte("# created by the test\n"+id.String()+"\n"), 0600)
+
+	plain := []byte(`[{"name": "ci", "key": "env:TEST_ADMIN_KEY", "roles": ["admin"]}]`)
+	for _, armored := range []bool{false, true} {
+		path := filepath.Join(dir, "keys.json.age")
+		os.WriteFile(path, encryptAge(t, id, plain, armored), 0600)
+
+		decryptKeys.ageIdentityFile = ""
+		if _, err := readConfigFile(path); err == nil || !strings.Contains(err.Error(), "AGE_IDENTITY_FILE") {
+			t.Errorf("armored %v: expected to need AGE_IDENTITY_FILE, got %v", armored, err)
+		}
+		decryptKeys.ageIdentityFile = keyFile
+		data, err := readConfigFile(path)
+		if err != nil || !bytes.Equal(data, plain) {
+			t.Errorf("armored %v: got %q, %v", armored, data, err)
+		}
+	}
+
+	t.Setenv("TEST_ADMIN_KEY", "s3cret")
+	keys, err := loadAPIKeys(filepath.Join(dir, "keys.json.age"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k, ok := keys[sha256.Sum256([]byte("s3cret"))]; !ok || k.Name != "ci" {
+		t.Errorf("expected the key referenced from the environment, got %+v", keys)
+	}
+
+	other, _ := age.GenerateX25519Identity()
+	os.WriteFile(filepath.Join(dir, "other.age"), encryptAge(t, other, plain, false), 0600)
+	if _, err := readConfigFile(filepath.Join(dir, "other.age")); err == nil {
+		t.Error("expected a file encrypted to another identity to fail")
+	}
+
+	os.WriteFile(filepath.Join(dir, "plain.json"), plain, 0600)
+	if data, err := readConfigFile(filepath.Join(dir, "plain.json")); err != nil || !bytes.Equal(data, plain) {
+		t.Errorf("expected a plain file as it is, got %q, %v", data, err)
+	}
+	if ext := configExt("rating.yaml.age"); ext != ".yaml" {
+		t.Errorf("expected .yaml, got %q", ext)
+	}
+}
+
+func TestReadConfigFileSOPS(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake sops is a shell script")
+	}
+	useDecryptKeys(t)
+	dir := t.TempDir()
+	fake := filepath.Join(dir, "sops")
+	os.WriteFile(fake, []byte("#!/bin/sh\n"+`echo "{\"decrypted\": \"$SOPS_AGE_KEY_FILE\"}"`+"\n"), 0755)
+	path := filepath.Join(dir, "notifiers.json")
+	os.WriteFile(path, []byte(`{"data": "ENC[AES256_GCM,data:abc]", "sops": {"mac": "ENC[...]"}}`), 0600)
+
+	decryptKeys.sopsBinary = fake
+	decryptKeys.sopsAgeKeyFile = "/keys/age.txt"
+	data, err := readConfigFile(path)
+	if err != nil || strings.TrimSpace(string(data)) != `{"decrypted": "/keys/age.txt"}` {
+		t.Errorf("expected the output of sops with the age key file, got %q, %v", data, err)
+	}
+	decryptKeys.sopsBinary = filepath.Join(dir, "missing")
+	if _, err := readConfigFile(path); err == nil {
+		t.Error("expected an error without sops")
+	}
+}
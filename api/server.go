@@ -0,0 +1,146 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jjway/rewatchableGamesApi-go/ratings"
+	"github.com/jjway/rewatchableGamesApi-go/store"
+)
+
+// Server timeouts. WriteTimeout bounds the slowest response, a full season
+// encoded for a slow client.
+const (
+	readHeaderTimeout = 5 * time.Second
+	writeTimeout      = 30 * time.Second
+	idleTimeout       = 120 * time.Second
+
+	// defaultShutdownTimeout fits within the 30s Kubernetes grace period
+	defaultShutdownTimeout = 25 * time.Second
+)
+
+// listenPort returns the port of PORT, 8000 by default or 443 when the
+// server speaks HTTPS
+func listenPort(cfg Config) string {
+	if p := cfg.Server.Port; p != 0 {
+		return strconv.Itoa(p)
+	}
+	if tlsEnabled(cfg) {
+		return defaultTLSPort
+	}
+	return "8000"
+}
+
+// shutdownTimeout returns how long SHUTDOWN_TIMEOUT lets in-flight
+// requests drain
+func shutdownTimeout(cfg Config) time.Duration {
+	if d := cfg.Server.ShutdownTimeout; d != 0 {
+		return d
+	}
+	return defaultShutdownTimeout
+}
+
+// newHTTPServer builds the HTTP server with its timeouts
+func newHTTPServer(addr string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: readHeaderTimeout,
+		WriteTimeout:      writeTimeout,
+		IdleTimeout:       idleTimeout,
+	}
+}
+
+// serve runs srv on ln until ctx is cancelled, then stops accepting
+// connections and waits up to drain for in-flight requests to finish
+func serve(ctx context.Context, srv *http.Server, ln net.Listener, drain time.Duration) error {
+	errc := make(chan error, 1)
+	go func() { errc <- srv.Serve(ln) }()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Printf("Shutting down, draining requests for up to %s", drain)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), drain)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// Server is the API over the week files of a store, for programs that
+// embed it rather than run the serve command. The handlers keep their
+// state in the package, so a process runs a single Server at a time.
+type Server struct {
+	handler http.Handler
+}
+
+// liveServer is the Server owning the state of the package, until closed
+var liveServer struct {
+	sync.Mutex
+	srv *Server
+}
+
+// errServerExists is returned by NewServer while another Server is open
+var errServerExists = errors.New("a Server is already open in this process, close it first")
+
+// NewServer returns the API over the week files of s, rated with cfg. It
+// fails while another Server is open.
+func NewServer(s store.Store, cfg ratings.Config) (*Server, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid rating config: %w", err)
+	}
+	liveServer.Lock()
+	defer liveServer.Unlock()
+	if liveServer.srv != nil {
+		return nil, errServerExists
+	}
+	if err := loadTeams(s); err != nil {
+		return nil, fmt.Errorf("load teams: %w", err)
+	}
+	dataStore, ratingConfig = s, cfg
+
+	// Nothing read from or rated for a previous store is served
+	cache = newWeekCache(0, 0, "")
+	responses = newResponseCache()
+	invalidateTopGames()
+	invalidateQuantiles()
+	liveServer.srv = &Server{handler: requestIDMiddleware(recoverMiddleware(compressMiddleware(deprecationMiddleware(newMux()))))}
+	return liveServer.srv, nil
+}
+
+// Close releases the state of the package, for another Server to be
+// created. The Server must not serve requests afterwards.
+func (srv *Server) Close() error {
+	liveServer.Lock()
+	defer liveServer.Unlock()
+	if liveServer.srv == srv {
+		liveServer.srv = nil
+	}
+	return nil
+}
+
+func (srv *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	srv.handler.ServeHTTP(w, r)
+}
+
+// Preload reads every week file of the store into the cache, which the
+// views across weeks, such as /seasons, /teams and /search, are built from
+func (srv *Server) Preload() {
+	preloadCache(dataStore)
+	readiness.markReady()
+}
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jjway/rewatchableGamesApi-go/ratings"
+	"github.com/jjway/rewatchableGamesApi-go/store"
+)
+
+func TestServeDrainsInFlightRequests(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	started := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		io.WriteString(w, "done")
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- serve(ctx, newHTTPServer("", handler), ln, 5*time.Second) }()
+
+	type result struct {
+		body string
+		err  error
+	}
+	got := make(chan result, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String() + "/")
+		if err != nil {
+			got <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		got <- result{string(body), err}
+	}()
+
+	<-started
+	cancel()
+
+	if res := <-got; res.err != nil || res.body != "done" {
+		t.Errorf("expected the in-flight request to complete, got %q, %v", res.body, res.err)
+	}
+	if err := <-served; err != nil {
+		t.Errorf("expected a clean shutdown, got %v", err)
+	}
+	if _, err := http.Get("http://" + ln.Addr().String() + "/"); err == nil {
+		t.Error("expected new connections to be refused after shutdown")
+	}
+}
+
+func TestNewHTTPServerTimeouts(t *testing.T) {
+	srv := newHTTPServer(":0", http.NotFoundHandler())
+	if srv.ReadHeaderTimeout == 0 || srv.WriteTimeout == 0 || srv.IdleTimeout == 0 {
+		t.Errorf("expected all timeouts to be set, got %+v", srv)
+	}
+}
+
+func TestNewServer(t *testing.T) {
+	useTestStore(t, t.TempDir())
+	old := ratingConfig
+	t.Cleanup(func() { ratingConfig = old })
+
+	if _, err := NewServer(store.NewDir(t.TempDir()), ratings.Config{}); err == nil {
+		t.Error("expected an invalid rating config to be rejected")
+	}
+
+	dir := setupTestData(t)
+	week := func(cfg ratings.Config) (*Server, ProcessedGameStats) {
+		t.Helper()
+		srv, err := NewServer(store.NewDir(dir), cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { srv.Close() })
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest("GET", "/v1/games/2024/1", nil))
+		var games []ProcessedGameStats
+		if err := json.Unmarshal(rec.Body.Bytes(), &games); err != nil || rec.Code != http.StatusOK || len(games) != 1 {
+			t.Fatalf("expected the week, got %d %s", rec.Code, rec.Body)
+		}
+		if rec.Header().Get(requestIDHeader) == "" {
+			t.Error("expected the request ID middleware")
+		}
+		return srv, games[0]
+	}
+	first, rated := week(ratings.DefaultConfig())
+	// A second Server would take over the state of the first
+	if _, err := NewServer(store.NewDir(dir), ratings.DefaultConfig()); !errors.Is(err, errServerExists) {
+		t.Fatalf("expected a second Server to be refused, got %v", err)
+	}
+	first.Close()
+	cfg := ratings.DefaultConfig()
+	cfg.TotalPoints = nil
+	srv, custom := week(cfg)
+	if custom.OffensiveRating != rated.OffensiveRating-1 {
+		t.Errorf("expected the rating config of the server, got %v then %v", rated.OffensiveRating, custom.OffensiveRating)
+	}
+
+	srv.Preload()
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest("GET", "/seasons", nil))
+	if !strings.Contains(rec.Body.String(), "2024") {
+		t.Errorf("expected the preloaded season, got %s", rec.Body)
+	}
+}
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// gameLocation identifies a game within the season/week layout
+type gameLocation struct {
+	Season string
+	Week   string
+	ID     string
+}
+
+// slugIndex resolves shareable slugs such as "2023-w5-buf-kc" to games
+var (
+	slugIndex   = make(map[string]gameLocation)
+	slugIndexMu sync.RWMutex
+)
+
+// gameSlug builds the slug of a game from its season, week and short name
+// ("BUF @ KC" in 2023 week 5 becomes "2023-w5-buf-kc", in the wild card
+// round "2023-wildcard-buf-kc"). Games whose short name cannot be parsed
+// fall back to their ID.
+func gameSlug(season, week, shortName, id string) string {
+	prefix := season + "-w" + week + "-"
+	if isPostseason(week) {
+		prefix = season + "-" + week + "-"
+	}
+	teams := splitMatchup(shortName)
+	if len(teams) != 2 {
+		return prefix + strings.ToLower(id)
+	}
+	return prefix + slugPart(teams[0]) + "-" + slugPart(teams[1])
+}
+
+// slugPart lowercases s and replaces anything but letters and digits with
+// dashes
+func slugPart(s string) string {
+	return strings.Trim(strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		}
+		return '-'
+	}, s), "-")
+}
+
+// setLocation tags a processed game with its season, week and slug
+func (p *ProcessedGameStats) setLocation(season, week string) {
+	p.Season, p.Week, p.WeekLabel = season, week, weekLabel(week)
+	p.Slug = gameSlug(season, week, p.ShortName, p.ID)
+}
+
+func indexSlugs(season, week string, games []GameStats) {
+	slugIndexMu.Lock()
+	defer slugIndexMu.Unlock()
+	for _, g := range games {
+		if g.ID == "" {
+			continue
+		}
+		slugIndex[gameSlug(season, week, g.ShortName, g.ID)] = gameLocation{Season: season, Week: week, ID: g.ID}
+	}
+}
+
+func unindexSlugs(season, week string) {
+	slugIndexMu.Lock()
+	defer slugIndexMu.Unlock()
+	for slug, loc := range slugIndex {
+		if loc.Season == season && loc.Week == week {
+			delete(slugIndex, slug)
+		}
+	}
+}
+
+// handleSlug redirects a shareable slug to the game detail endpoint
+func handleSlug(w http.ResponseWriter, r *http.Request) {
+	slug := strings.ToLower(r.PathValue("slug"))
+
+	slugIndexMu.RLock()
+	loc, ok := slugIndex[slug]
+	slugIndexMu.RUnlock()
+
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "unknown game "+slug)
+		return
+	}
+	http.Redirect(w, r, versionedPath(r, "/games/"+loc.Season+"/"+loc.Week+"/"+loc.ID), http.StatusFound)
+}
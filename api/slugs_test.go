@@ -0,0 +1,49 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGameSlug(t *testing.T) {
+	tests := []struct {
+		season, week, shortName, id string
+		want                        string
+	}{
+		{"2023", "5", "BUF @ KC", "401", "2023-w5-buf-kc"},
+		{"2024", "1", "GB VS PHI", "402", "2024-w1-gb-phi"},
+		{"2024", "2", "", "403", "2024-w2-403"},
+	}
+	for _, tt := range tests {
+		if got := gameSlug(tt.season, tt.week, tt.shortName, tt.id); got != tt.want {
+			t.Errorf("gameSlug(%q) = %q, want %q", tt.shortName, got, tt.want)
+		}
+	}
+}
+
+func TestHandleSlug(t *testing.T) {
+	useTestStore(t, setupTestData(t))
+	preloadCache(dataStore)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /g/{slug}", handleSlug)
+
+	req := httptest.NewRequest("GET", "/g/2024-w2-a-b", nil)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusFound {
+		t.Fatalf("expected status 302, got %d", rec.Code)
+	}
+	if loc := rec.Header().Get("Location"); loc != "/games/2024/2/game1" {
+		t.Errorf("unexpected redirect target %q", loc)
+	}
+
+	req = httptest.NewRequest("GET", "/g/1999-w1-x-y", nil)
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", rec.Code)
+	}
+}
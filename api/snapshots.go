@@ -0,0 +1,195 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/jjway/rewatchableGamesApi-go/store"
+)
+
+// snapshotStore keeps every version of the week files the API served, so
+// a response can be reproduced as of a past time. Contents are stored once
+// by hash under objects/, and each week has an append-only history of
+// which version was served from when:
+//
+//	objects/ab/ab12...
+//	history/2024/1.jsonl
+type snapshotStore struct {
+	root string
+	mu   sync.Mutex
+}
+
+// snapshotEntry is one line of a week history
+type snapshotEntry struct {
+	SHA256 string    `json:"sha256"`
+	At     time.Time `json:"at"`
+}
+
+// snapshots is set from SNAPSHOT_DIR; nil disables snapshots
+var snapshots *snapshotStore
+
+func newSnapshotStore(root string) *snapshotStore {
+	return &snapshotStore{root: root}
+}
+
+func (s *snapshotStore) objectPath(hash string) string {
+	return filepath.Join(s.root, "objects", hash[:2], hash)
+}
+
+func (s *snapshotStore) historyPath(name string) string {
+	return filepath.Join(s.root, "history", filepath.FromSlash(name)+"l")
+}
+
+// history returns the recorded versions of the week file name, oldest
+// first
+func (s *snapshotStore) history(name string) ([]snapshotEntry, error) {
+	data, err := os.ReadFile(s.historyPath(name))
+	if err != nil {
+		return nil, err
+	}
+	var entries []snapshotEntry
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		var e snapshotEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("%s: %w", s.historyPath(name), err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, sc.Err()
+}
+
+// record adds data as the version of name served from at, unless it is
+// already the latest version
+func (s *snapshotStore) record(name string, data []byte, at time.Time) error {
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := s.history(name)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if len(entries) > 0 && entries[len(entries)-1].SHA256 == hash {
+		return nil
+	}
+
+	obj := s.objectPath(hash)
+	if _, err := os.Stat(obj); errors.Is(err, fs.ErrNotExist) {
+		if err := os.MkdirAll(filepath.Dir(obj), 0755); err != nil {
+			return err
+		}
+		tmp := obj + ".tmp"
+		if err := os.WriteFile(tmp, data, 0644); err != nil {
+			return err
+		}
+		if err := os.Rename(tmp, obj); err != nil {
+			return err
+		}
+	}
+
+	line, err := json.Marshal(snapshotEntry{SHA256: hash, At: at.UTC()})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.historyPath(name)), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.historyPath(name), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// lookup returns the version of name served at asOf and when it started
+// being served. Times before the first recorded version report
+// fs.ErrNotExist.
+func (s *snapshotStore) lookup(name string, asOf time.Time) ([]byte, time.Time, error) {
+	if !fs.ValidPath(name) {
+		return nil, time.Time{}, fs.ErrNotExist
+	}
+	s.mu.Lock()
+	entries, err := s.history(name)
+	s.mu.Unlock()
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	var found *snapshotEntry
+	for i := range entries {
+		if entries[i].At.After(asOf) {
+			break
+		}
+		found = &entries[i]
+	}
+	if found == nil {
+		return nil, time.Time{}, fs.ErrNotExist
+	}
+	data, err := os.ReadFile(s.objectPath(found.SHA256))
+	return data, found.At, err
+}
+
+// recordSnapshot records data as the version of the week file name now
+// being served
+func recordSnapshot(name string, data []byte) {
+	if snapshots == nil || !store.IsWeekFile(name) {
+		return
+	}
+	if err := snapshots.record(name, data, clock.Now()); err != nil {
+		log.Printf("Warning: snapshot %s: %v", name, err)
+	}
+}
+
+// parseAsOf parses ?asOf=, an RFC 3339 timestamp or a date standing for
+// the end of that day in UTC. The zero time means the current data.
+func parseAsOf(r *http.Request) (time.Time, *QueryError) {
+	v := r.URL.Query().Get("asOf")
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if snapshots == nil {
+		return time.Time{}, &QueryError{Param: "asOf", Value: v, Message: "snapshots are not enabled on this server"}
+	}
+	return parseTime("asOf", v)
+}
+
+// parseTime parses the value v of the query parameter param, an RFC 3339
+// timestamp or a date standing for the end of that day in UTC
+func parseTime(param, v string) (time.Time, *QueryError) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	if d, err := time.Parse(time.DateOnly, v); err == nil {
+		return d.Add(24*time.Hour - time.Nanosecond), nil
+	}
+	return time.Time{}, &QueryError{Param: param, Value: v, Message: "must be an RFC 3339 timestamp or a YYYY-MM-DD date"}
+}
+
+// snapshotGames returns the games of the week file name as served at asOf
+// and when that version started being served
+func snapshotGames(name string, asOf time.Time) ([]GameStats, time.Time, error) {
+	data, at, err := snapshots.lookup(name, asOf)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	games, err := decodeWeekFile(name, data)
+	return games, at, err
+}
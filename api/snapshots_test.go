@@ -0,0 +1,133 @@
+package api
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSnapshotStore(t *testing.T) {
+	s := newSnapshotStore(t.TempDir())
+	t0 := time.Date(2024, 9, 8, 12, 0, 0, 0, time.UTC)
+
+	for i, step := range []struct {
+		data string
+		at   time.Time
+	}{{"v1", t0}, {"v1", t0.Add(time.Hour)}, {"v2", t0.Add(2 * time.Hour)}} {
+		if err := s.record("2024/1.json", []byte(step.data), step.at); err != nil {
+			t.Fatalf("record %d: %v", i, err)
+		}
+	}
+	if entries, _ := s.history("2024/1.json"); len(entries) != 2 {
+		t.Errorf("expected an unchanged version not to be recorded again, got %+v", entries)
+	}
+
+	for _, tc := range []struct {
+		asOf time.Time
+		want string
+		at   time.Time
+	}{
+		{t0, "v1", t0},
+		{t0.Add(90 * time.Minute), "v1", t0},
+		{t0.Add(3 * time.Hour), "v2", t0.Add(2 * time.Hour)},
+	} {
+		data, at, err := s.lookup("2024/1.json", tc.asOf)
+		if err != nil || string(data) != tc.want || !at.Equal(tc.at) {
+			t.Errorf("as of %s: got %q at %s, %v; want %q at %s", tc.asOf, data, at, err, tc.want, tc.at)
+		}
+	}
+	if _, _, err := s.lookup("2024/1.json", t0.Add(-time.Second)); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected ErrNotExist before the first version, got %v", err)
+	}
+	if _, _, err := s.lookup("2024/2.json", t0); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected ErrNotExist for a week without history, got %v", err)
+	}
+}
+
+func TestSnapshotAfterValidation(t *testing.T) {
+	dir := setupTestData(t)
+	useTestStore(t, dir)
+	snapshots = newSnapshotStore(t.TempDir())
+	t.Cleanup(func() { snapshots = nil })
+	t.Cleanup(func() { validations.setMode(validationFlag) })
+	os.WriteFile(filepath.Join(dir, "2024", "3.json"), []byte(badWeek), 0644)
+
+	validations.setMode(validationReject)
+	for _, name := range []string{"2024/1.json", "2024/3.json"} {
+		if _, err := loadGameStats(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if entries, _ := snapshots.history("2024/1.json"); len(entries) != 1 {
+		t.Errorf("expected the valid week to be recorded, got %+v", entries)
+	}
+	if entries, _ := snapshots.history("2024/3.json"); len(entries) != 0 {
+		t.Errorf("expected the week with rejected games not to be recorded, got %+v", entries)
+	}
+
+	// Flagged games are served, and recorded
+	validations.setMode(validationFlag)
+	cache.remove("2024/3.json")
+	if _, err := loadGameStats("2024/3.json"); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := snapshots.history("2024/3.json"); len(entries) != 1 {
+		t.Errorf("expected the flagged week to be recorded, got %+v", entries)
+	}
+}
+
+func TestGamesAsOf(t *testing.T) {
+	c := useFakeClock(t)
+	useTestStore(t, setupTestData(t))
+	snapshots = newSnapshotStore(t.TempDir())
+	t.Cleanup(func() { snapshots = nil })
+	t.Cleanup(func() { unindexFile("2024/1.json") })
+
+	if _, err := loadGameStats("2024/1.json"); err != nil {
+		t.Fatal(err)
+	}
+	c.Advance(time.Hour)
+	if rec := ingest(t, "/games/2024/1", `[{"id": "corrected", "shortName": "A @ B", "fullName": "Team A at Team B"}]`); rec.Code != http.StatusOK {
+		t.Fatalf("ingest: %d %s", rec.Code, rec.Body)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /games/{year}/{week}", handleGamesYearWeek)
+	get := func(url string) (*httptest.ResponseRecorder, []ProcessedGameStats) {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest("GET", url, nil))
+		var games []ProcessedGameStats
+		json.Unmarshal(rec.Body.Bytes(), &games)
+		return rec, games
+	}
+
+	if _, games := get("/games/2024/1"); len(games) != 1 || games[0].ID != "corrected" {
+		t.Errorf("expected the current version without asOf, got %+v", games)
+	}
+	rec, games := get("/games/2024/1?asOf=2024-09-08T12:30:00Z")
+	if rec.Code != http.StatusOK || len(games) != 1 || games[0].ID != "game1" {
+		t.Fatalf("expected the version served at 12:30, got %d %+v", rec.Code, games)
+	}
+	if lm := rec.Header().Get("Last-Modified"); lm != "Sun, 08 Sep 2024 12:00:00 GMT" {
+		t.Errorf("expected Last-Modified of the snapshot, got %q", lm)
+	}
+	if _, games := get("/games/2024/1?asOf=2024-09-08"); len(games) != 1 || games[0].ID != "corrected" {
+		t.Errorf("expected a date to cover the whole day, got %+v", games)
+	}
+	if rec, _ := get("/games/2024/1?asOf=2024-09-07"); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 before the first snapshot, got %d", rec.Code)
+	}
+	if rec, _ := get("/games/2024/1?asOf=yesterday"); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid asOf, got %d", rec.Code)
+	}
+
+	snapshots = nil
+	if rec, _ := get("/games/2024/1?asOf=2024-09-08"); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 with snapshots disabled, got %d", rec.Code)
+	}
+}
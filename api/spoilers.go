@@ -0,0 +1,104 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+)
+
+// SpoilerFreeGame is the ?spoilerFree=true representation of a processed
+// game. The component ratings are dropped since they hint at the score and
+// the defensive touchdowns; the composite rating says what to rewatch.
+type SpoilerFreeGame struct {
+	ID             string  `json:"id"`
+	Season         string  `json:"season,omitempty"`
+	Week           string  `json:"week,omitempty"`
+	WeekLabel      string  `json:"weekLabel,omitempty"`
+	Slug           string  `json:"slug,omitempty"`
+	FullName       string  `json:"fullName"`
+	ShortName      string  `json:"shortName"`
+	HomeTeam       *Team   `json:"homeTeam,omitempty"`
+	AwayTeam       *Team   `json:"awayTeam,omitempty"`
+	MatchupQuality string  `json:"matchupQuality"`
+	TotalRating    float64 `json:"totalRating"`
+	Algorithm      string  `json:"algorithm"`
+
+	NormalizedRating *float64 `json:"normalizedRating,omitempty"`
+	Normalization    string   `json:"normalization,omitempty"`
+
+	CommunityRating *float64 `json:"communityRating,omitempty"`
+	CommunityVotes  int      `json:"communityVotes,omitempty"`
+}
+
+// spoilerPaths are the GameStats fields revealing the outcome of a game,
+// removed from raw stats in spoiler-free mode
+var spoilerPaths = []string{
+	"scenario.marginOfVictory",
+	"scenario.fourthQuarterLeadershipChange",
+	"scenario.leadershipChange",
+	"scenario.scenarioData",
+	"offense.totalPoints",
+	"defense.defensiveTds",
+	"defense.specialTeamsTd",
+	"defense.safeties",
+}
+
+// isSpoilerFree reports whether the request asked for ?spoilerFree=true
+func isSpoilerFree(r *http.Request) bool {
+	return r.URL.Query().Get("spoilerFree") == "true"
+}
+
+// spoilerFreeGames reduces processed games to their spoiler-free
+// representation
+func spoilerFreeGames(processed []ProcessedGameStats) []SpoilerFreeGame {
+	games := make([]SpoilerFreeGame, 0, len(processed))
+	for _, p := range processed {
+		games = append(games, SpoilerFreeGame{
+			ID:             p.ID,
+			Season:         p.Season,
+			Week:           p.Week,
+			WeekLabel:      p.WeekLabel,
+			Slug:           p.Slug,
+			FullName:       p.FullName,
+			ShortName:      p.ShortName,
+			HomeTeam:       p.HomeTeam,
+			AwayTeam:       p.AwayTeam,
+			MatchupQuality: p.MatchupQuality,
+			TotalRating:    p.TotalRating,
+			Algorithm:      p.Algorithm,
+
+			NormalizedRating: p.NormalizedRating,
+			Normalization:    p.Normalization,
+			CommunityRating:  p.CommunityRating,
+			CommunityVotes:   p.CommunityVotes,
+		})
+	}
+	return games
+}
+
+// spoilerFreeStats returns the raw stats of g as a JSON object without the
+// spoilerPaths
+func spoilerFreeStats(g GameStats) (map[string]any, error) {
+	data, err := json.Marshal(g)
+	if err != nil {
+		return nil, err
+	}
+	var obj map[string]any
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return nil, err
+	}
+	for _, path := range spoilerPaths {
+		deletePath(obj, strings.Split(path, "."))
+	}
+	return obj, nil
+}
+
+// deletePath removes the key at a dotted path from a decoded JSON object
+func deletePath(obj map[string]any, path []string) {
+	if len(path) == 1 {
+		delete(obj, path[0])
+		return
+	}
+	if child, ok := obj[path[0]].(map[string]any); ok {
+		deletePath(child, path[1:])
+	}
+}
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSpoilerFreeWeek(t *testing.T) {
+	useTestStore(t, setupTestData(t))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /games/{year}/{week}", handleGamesYearWeek)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/games/2024/1?spoilerFree=true", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	var games []map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &games); err != nil {
+		t.Fatal(err)
+	}
+	if len(games) != 1 {
+		t.Fatalf("expected 1 game, got %d", len(games))
+	}
+	if _, ok := games[0]["totalRating"]; !ok {
+		t.Error("expected the composite rating to be kept")
+	}
+	for _, field := range []string{"offensiveRating", "defensiveBigPlays", "scenarioRating"} {
+		if _, ok := games[0][field]; ok {
+			t.Errorf("expected %s to be stripped", field)
+		}
+	}
+}
+
+func TestSpoilerFreeGameByID(t *testing.T) {
+	useTestStore(t, setupTestData(t))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /games/{year}/{week}/{id}", handleGameByID)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/games/2024/1/game1?spoilerFree=true", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	body := rec.Body.String()
+	for _, field := range []string{"marginOfVictory", "totalPoints", "leadershipChange", "scenarioData", "defensiveTds"} {
+		if strings.Contains(body, `"`+field+`"`) {
+			t.Errorf("expected %s to be stripped from %s", field, body)
+		}
+	}
+	for _, field := range []string{"scenarioRating", "totalYards", "interceptions"} {
+		if !strings.Contains(body, `"`+field+`"`) {
+			t.Errorf("expected %s to be kept", field)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"reflect"
+	"strings"
+)
+
+// statPaths maps the JSON path of every numeric GameStats field, e.g.
+// "offense.totalPoints", to its reflect field index
+var statPaths = buildStatPaths(reflect.TypeOf(GameStats{}), "", nil)
+
+// statLeaves maps the last segment of each stat path to the path, for the
+// names that are unambiguous, e.g. "totalPoints" to "offense.totalPoints"
+var statLeaves = buildStatLeaves(statPaths)
+
+func buildStatLeaves(paths map[string][]int) map[string]string {
+	leaves := make(map[string]string)
+	ambiguous := make(map[string]bool)
+	for path := range paths {
+		leaf := path[strings.LastIndex(path, ".")+1:]
+		if _, ok := leaves[leaf]; ok {
+			ambiguous[leaf] = true
+		}
+		leaves[leaf] = path
+	}
+	for leaf := range ambiguous {
+		delete(leaves, leaf)
+	}
+	return leaves
+}
+
+func buildStatPaths(t reflect.Type, prefix string, index []int) map[string][]int {
+	paths := make(map[string][]int)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		path := prefix + name
+		fieldIndex := append(append([]int(nil), index...), i)
+
+		switch f.Type.Kind() {
+		case reflect.Struct:
+			for p, idx := range buildStatPaths(f.Type, path+".", fieldIndex) {
+				paths[p] = idx
+			}
+		case reflect.Float64, reflect.Int:
+			paths[path] = fieldIndex
+		}
+	}
+	return paths
+}
+
+// stat returns the numeric field of g at a JSON path, or 0 if unknown
+func stat(g *GameStats, path string) float64 {
+	index, ok := statPaths[path]
+	if !ok {
+		return 0
+	}
+	v := reflect.ValueOf(g).Elem().FieldByIndex(index)
+	if v.Kind() == reflect.Int {
+		return float64(v.Int())
+	}
+	return v.Float()
+}
+
+// extensionGame adapts GameStats to extensions.Game
+type extensionGame struct {
+	g *GameStats
+}
+
+func (e extensionGame) ID() string { return e.g.ID }
+
+func (e extensionGame) Stat(path string) float64 { return stat(e.g, path) }
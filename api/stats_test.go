@@ -0,0 +1,22 @@
+package api
+
+import "testing"
+
+func TestStat(t *testing.T) {
+	var g GameStats
+	g.Week = 3
+	g.Offense.TotalPoints = 55
+	g.Scenario.ScenarioData.Max4th = 0.9
+
+	tests := map[string]float64{
+		"week":                          3,
+		"offense.totalPoints":           55,
+		"scenario.scenarioData.max_4th": 0.9,
+		"offense.nope":                  0,
+	}
+	for path, want := range tests {
+		if got := stat(&g, path); got != want {
+			t.Errorf("stat(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
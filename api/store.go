@@ -0,0 +1,60 @@
+package api
+
+import (
+	"fmt"
+	"io/fs"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/jjway/rewatchableGamesApi-go/store"
+)
+
+// dataStore is the backend used by the handlers, selected at startup
+var dataStore store.Store = store.NewDir("data")
+
+// EmbeddedData is the data directory compiled into the binary, set by the
+// main package in builds with the embeddata tag
+var EmbeddedData fs.FS
+
+// openStore builds the store selected by STORE_BACKEND (dir, embedded,
+// sqlite, s3 or gcs), defaulting to the local data directory, or to the
+// embedded data when the binary has it and DATA_DIR is unset
+func openStore(cfg Config) (store.Store, error) {
+	switch backend := cfg.Store.Backend; backend {
+	case "", "dir":
+		dir := cfg.Store.DataDir
+		if dir == "" && backend == "" && EmbeddedData != nil {
+			return store.NewFS(EmbeddedData), nil
+		}
+		if dir == "" {
+			dir = "data"
+		}
+		return store.NewDir(dir), nil
+	case "embedded":
+		if EmbeddedData == nil {
+			return nil, fmt.Errorf("this binary was built without embedded data")
+		}
+		return store.NewFS(EmbeddedData), nil
+	case "sqlite":
+		dsn := cfg.Store.SQLitePath
+		if dsn == "" {
+			return nil, fmt.Errorf("SQLITE_PATH is required for the sqlite backend")
+		}
+		return store.OpenSQLite(dsn)
+	case "s3", "gcs":
+		base := cfg.Store.ObjectStoreURL
+		if base == "" {
+			return nil, fmt.Errorf("OBJECT_STORE_URL is required for the %s backend", backend)
+		}
+		creds, err := objectCredentials("OBJECT_STORE", cfg.objectStoreAuth())
+		if err != nil {
+			return nil, err
+		}
+		if backend == "s3" && creds.Token != "" {
+			return nil, fmt.Errorf("S3 does not accept OBJECT_STORE_TOKEN; set OBJECT_STORE_ACCESS_KEY_ID and OBJECT_STORE_SECRET_ACCESS_KEY")
+		}
+		return store.NewObject(base, creds), nil
+	default:
+		return nil, fmt.Errorf("unknown STORE_BACKEND %q", backend)
+	}
+}
@@ -0,0 +1,37 @@
+package api
+
+import (
+	"os"
+	"testing"
+
+	"github.com/jjway/rewatchableGamesApi-go/store"
+)
+
+func TestOpenEmbeddedStore(t *testing.T) {
+	var cfg Config
+	cfg.Store.Backend = "embedded"
+	old := EmbeddedData
+	t.Cleanup(func() { EmbeddedData = old })
+
+	EmbeddedData = nil
+	if _, err := openStore(cfg); err == nil {
+		t.Error("expected an error without embedded data")
+	}
+
+	EmbeddedData = os.DirFS(setupTestData(t))
+	s, err := openStore(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.ReadFile("2024/1.json"); err != nil {
+		t.Errorf("expected to read the embedded data, got %v", err)
+	}
+
+	// Embedded builds serve their data by default
+	cfg.Store.Backend = ""
+	if s, _ := openStore(cfg); s == nil {
+		t.Fatal("expected a store")
+	} else if _, ok := s.(*store.FS); !ok {
+		t.Errorf("expected the embedded store by default, got %T", s)
+	}
+}
@@ -0,0 +1,137 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/jjway/rewatchableGamesApi-go/store"
+)
+
+// teamsFile is the curated team dataset at the root of the store
+const teamsFile = "teams.json"
+
+// Team describes a team of the curated dataset. The homeTeam and awayTeam
+// of a game carry its name as written in the game's fullName.
+type Team struct {
+	Name         string      `json:"name"`
+	Abbreviation string      `json:"abbreviation"`
+	Conference   string      `json:"conference,omitempty"`
+	Division     string      `json:"division,omitempty"`
+	Colors       *TeamColors `json:"colors,omitempty"`
+	LogoURL      string      `json:"logoUrl,omitempty"`
+}
+
+// TeamColors are the hex colors of a team
+type TeamColors struct {
+	Primary   string `json:"primary"`
+	Secondary string `json:"secondary,omitempty"`
+}
+
+// teamDirectory is the loaded team dataset, indexed by lowercase
+// abbreviation and full name
+type teamDirectory struct {
+	teams []Team
+	byKey map[string]Team
+}
+
+var (
+	teamMeta   = newTeamDirectory(nil)
+	teamMetaMu sync.RWMutex
+)
+
+func newTeamDirectory(teams []Team) *teamDirectory {
+	d := &teamDirectory{teams: teams, byKey: make(map[string]Team, 2*len(teams))}
+	for _, t := range teams {
+		d.byKey[strings.ToLower(t.Abbreviation)] = t
+		d.byKey[strings.ToLower(t.Name)] = t
+	}
+	return d
+}
+
+// loadTeams reads the team dataset of s. A store without one serves no
+// team metadata.
+func loadTeams(s store.Store) error {
+	data, err := s.ReadFile(teamsFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		setTeams(nil)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var teams []Team
+	if err := json.Unmarshal(data, &teams); err != nil {
+		return fmt.Errorf("parse %s: %w", teamsFile, err)
+	}
+	for i, t := range teams {
+		if t.Name == "" || t.Abbreviation == "" {
+			return fmt.Errorf("%s: team %d needs a name and an abbreviation", teamsFile, i)
+		}
+	}
+	setTeams(teams)
+	return nil
+}
+
+func setTeams(teams []Team) {
+	d := newTeamDirectory(teams)
+	teamMetaMu.Lock()
+	teamMeta = d
+	teamMetaMu.Unlock()
+}
+
+// lookupTeam returns the team of an abbreviation, or else of a full name
+func lookupTeam(abbreviation, name string) (Team, bool) {
+	teamMetaMu.RLock()
+	defer teamMetaMu.RUnlock()
+	if t, ok := teamMeta.byKey[strings.ToLower(abbreviation)]; ok && abbreviation != "" {
+		return t, true
+	}
+	t, ok := teamMeta.byKey[strings.ToLower(name)]
+	return t, ok && name != ""
+}
+
+// gameTeams returns the teams of a matchup from its short and full names,
+// with their metadata when the dataset knows them. The abbreviation goes
+// first since the full names change with rebrandings.
+func gameTeams(shortName, fullName string) (away, home *Team) {
+	short, full := splitNames(shortName), splitNames(fullName)
+	var teams [2]*Team
+	for i := range teams {
+		var t Team
+		if short != nil {
+			t.Abbreviation = short[i]
+		}
+		if full != nil {
+			t.Name = full[i]
+		}
+		if t.Abbreviation == "" && t.Name == "" {
+			continue
+		}
+		if known, ok := lookupTeam(t.Abbreviation, t.Name); ok {
+			if t.Name != "" {
+				known.Name = t.Name
+			}
+			t = known
+		}
+		teams[i] = &t
+	}
+	return teams[0], teams[1]
+}
+
+func handleTeams(w http.ResponseWriter, r *http.Request) {
+	teamMetaMu.RLock()
+	teams := teamMeta.teams
+	teamMetaMu.RUnlock()
+	if teams == nil {
+		teams = []Team{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	setCacheHeaders(w, "meta")
+	if err := json.NewEncoder(w).Encode(teams); err != nil {
+		writeError(w, r, http.StatusInternalServerError, "error encoding response")
+	}
+}
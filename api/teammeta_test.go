@@ -0,0 +1,66 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jjway/rewatchableGamesApi-go/store"
+)
+
+func TestLoadTeams(t *testing.T) {
+	t.Cleanup(func() { setTeams(nil) })
+	if err := loadTeams(store.NewDir(filepath.Join("..", "data"))); err != nil {
+		t.Fatal(err)
+	}
+	if len(teamMeta.teams) != 32 || len(teamMeta.byKey) != 64 {
+		t.Errorf("expected 32 teams with distinct names and abbreviations, got %d keyed %d", len(teamMeta.teams), len(teamMeta.byKey))
+	}
+
+	dir := t.TempDir()
+	if err := loadTeams(store.NewDir(dir)); err != nil || len(teamMeta.teams) != 0 {
+		t.Errorf("expected no team without a dataset, got %v %v", teamMeta.teams, err)
+	}
+	os.WriteFile(filepath.Join(dir, teamsFile), []byte(`[{"name": "Kansas City Chiefs"}]`), 0644)
+	if err := loadTeams(store.NewDir(dir)); err == nil {
+		t.Error("expected a team without an abbreviation to be rejected")
+	}
+}
+
+func TestGameTeams(t *testing.T) {
+	setTeams([]Team{
+		{Name: "Washington Commanders", Abbreviation: "WSH", Division: "NFC East", LogoURL: "wsh.png"},
+		{Name: "Dallas Cowboys", Abbreviation: "DAL", Colors: &TeamColors{Primary: "#003594"}},
+	})
+	t.Cleanup(func() { setTeams(nil) })
+
+	away, home := gameTeams("WSH @ DAL", "Washington at Dallas Cowboys")
+	if away.Name != "Washington" || away.Division != "NFC East" || away.LogoURL != "wsh.png" {
+		t.Errorf("expected the name of the game with the metadata of the abbreviation, got %+v", away)
+	}
+	if home.Abbreviation != "DAL" || home.Colors.Primary != "#003594" {
+		t.Errorf("expected the home team second, got %+v", home)
+	}
+
+	away, home = gameTeams("BUF VS KC", "Buffalo Bills vs Kansas City Chiefs")
+	if away.Name != "Buffalo Bills" || away.Abbreviation != "BUF" || home.Abbreviation != "KC" || home.LogoURL != "" {
+		t.Errorf("expected the parsed names of unknown teams, got %+v %+v", away, home)
+	}
+	if away, home := gameTeams("", "TBD"); away != nil || home != nil {
+		t.Errorf("expected no team without a matchup, got %+v %+v", away, home)
+	}
+
+	p := processGame(raters[defaultAlgorithm], GameStats{ID: "g", ShortName: "WSH @ DAL", FullName: "Washington Commanders at Dallas Cowboys"})
+	if p.AwayTeam == nil || p.AwayTeam.LogoURL != "wsh.png" || p.HomeTeam == nil || p.HomeTeam.Name != "Dallas Cowboys" {
+		t.Errorf("expected the processed game to carry its teams, got %+v %+v", p.AwayTeam, p.HomeTeam)
+	}
+
+	rec := httptest.NewRecorder()
+	handleTeams(rec, httptest.NewRequest("GET", "/teams", nil))
+	var teams []Team
+	if err := json.Unmarshal(rec.Body.Bytes(), &teams); err != nil || rec.Code != http.StatusOK || len(teams) != 2 {
+		t.Errorf("expected the dataset, got %d %s", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,326 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// teamIndex maps a normalized team key (abbreviation, full name or
+// nickname) to every game that team played, and matchupIndex maps a pair
+// of team keys to every game between the two teams, both built at preload
+// time
+var (
+	teamIndex    = make(map[string][]ProcessedGameStats)
+	matchupIndex = make(map[string][]ProcessedGameStats)
+	teamIndexMu  sync.RWMutex
+)
+
+// splitMatchup splits "A at B", "A @ B" or "A VS B" into its two sides,
+// lowercased
+func splitMatchup(name string) []string {
+	return splitNames(strings.ToLower(name))
+}
+
+// splitNames splits "A at B", "A @ B" or "A VS B" into its two sides as
+// written
+func splitNames(name string) []string {
+	lower := strings.ToLower(name)
+	if len(lower) != len(name) {
+		// The offsets in lower are those of name in ASCII only
+		name = lower
+	}
+	for _, sep := range []string{" @ ", " at ", " vs ", " vs. "} {
+		if i := strings.Index(lower, sep); i >= 0 {
+			return []string{strings.TrimSpace(name[:i]), strings.TrimSpace(name[i+len(sep):])}
+		}
+	}
+	return nil
+}
+
+// teamKeys returns the normalized keys under which g is indexed
+func teamKeys(g GameStats) []string {
+	return matchupKeys(g.ShortName, g.FullName)
+}
+
+// matchupKeys returns the normalized team keys of a matchup from its short
+// and full names
+func matchupKeys(shortName, fullName string) []string {
+	sides := matchupSides(shortName, fullName)
+	return append(sides[0], sides[1]...)
+}
+
+// matchupSides returns the normalized keys of each team of a matchup,
+// away team first
+func matchupSides(shortName, fullName string) [2][]string {
+	var sides [2][]string
+	if short := splitMatchup(shortName); short != nil {
+		sides[0] = append(sides[0], short[0])
+		sides[1] = append(sides[1], short[1])
+	}
+	for i, full := range splitMatchup(fullName) {
+		sides[i] = append(sides[i], full)
+		if j := strings.LastIndex(full, " "); j >= 0 {
+			// Nickname, e.g. "chiefs" for "kansas city chiefs"
+			sides[i] = append(sides[i], full[j+1:])
+		}
+	}
+	return sides
+}
+
+// pairKey is the matchupIndex key of two normalized team keys, the same
+// whichever team is listed first
+func pairKey(a, b string) string {
+	if a > b {
+		a, b = b, a
+	}
+	return a + "|" + b
+}
+
+// indexGames adds the games of one week file to the team index
+func indexGames(season, week string, games []GameStats) {
+	teamIndexMu.Lock()
+	defer teamIndexMu.Unlock()
+
+	for _, g := range games {
+		if g.ID == "" {
+			continue
+		}
+		tg := processGame(raters[defaultAlgorithm], g)
+		tg.setLocation(season, week)
+		seen := make(map[string]bool)
+		for _, key := range teamKeys(g) {
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			teamIndex[key] = append(teamIndex[key], tg)
+		}
+
+		sides := matchupSides(g.ShortName, g.FullName)
+		for _, a := range sides[0] {
+			for _, b := range sides[1] {
+				key := pairKey(a, b)
+				if a == b || seen[key] {
+					continue
+				}
+				seen[key] = true
+				matchupIndex[key] = append(matchupIndex[key], tg)
+			}
+		}
+	}
+}
+
+// indexFile adds a cached week file, named {year}/{week}.json, to the team,
+// slug, ID and search indexes
+func indexFile(name string, games []GameStats) {
+	season, file, _ := strings.Cut(name, "/")
+	week := strings.TrimSuffix(file, ".json")
+	indexGames(season, week, games)
+	indexSlugs(season, week, games)
+	indexIDs(season, week, games)
+	indexSearch(season, week, games)
+}
+
+// unindexFile removes the games of a week file from the team, slug, ID and
+// search indexes
+func unindexFile(name string) {
+	season, file, _ := strings.Cut(name, "/")
+	week := strings.TrimSuffix(file, ".json")
+	unindexSlugs(season, week)
+	unindexIDs(season, week)
+	unindexSearch(season, week)
+
+	teamIndexMu.Lock()
+	defer teamIndexMu.Unlock()
+
+	for _, index := range []map[string][]ProcessedGameStats{teamIndex, matchupIndex} {
+		for key, games := range index {
+			kept := games[:0]
+			for _, g := range games {
+				if g.Season != season || g.Week != week {
+					kept = append(kept, g)
+				}
+			}
+			if len(kept) == 0 {
+				delete(index, key)
+			} else {
+				index[key] = kept
+			}
+		}
+	}
+}
+
+// gamesForTeam returns the indexed games of team ordered by season and week
+func gamesForTeam(team string) []ProcessedGameStats {
+	teamIndexMu.RLock()
+	games := append([]ProcessedGameStats(nil), teamIndex[strings.ToLower(strings.TrimSpace(team))]...)
+	teamIndexMu.RUnlock()
+
+	sort.SliceStable(games, func(i, j int) bool {
+		if games[i].Season != games[j].Season {
+			return games[i].Season < games[j].Season
+		}
+		wi, _ := weekOrder(games[i].Week)
+		wj, _ := weekOrder(games[j].Week)
+		return wi < wj
+	})
+	return withCommunityRatings(games)
+}
+
+// rerateGames recomputes indexed games, rated with the default algorithm,
+// with rater from their cached week files
+func rerateGames(rater Rater, games []ProcessedGameStats) []ProcessedGameStats {
+	for i, tg := range games {
+		g, err := findGame(tg.Season, tg.Week, tg.ID)
+		if err != nil {
+			continue
+		}
+		rated := processGame(rater, g)
+		rated.setLocation(tg.Season, tg.Week)
+		games[i] = rated
+	}
+	return games
+}
+
+// handleTeamGames returns every cached game involving a team, matched
+// against abbreviation ("KC"), full name or nickname ("chiefs"), against
+// the opponents of ?teams= when set
+func handleTeamGames(w http.ResponseWriter, r *http.Request) {
+	team := r.PathValue("team")
+
+	rater, qerr := raterFor(r)
+	if qerr != nil {
+		writeQueryError(w, r, qerr)
+		return
+	}
+	opponents, filtered, qerr := parseTeams(r.URL.Query())
+	if qerr != nil {
+		writeQueryError(w, r, qerr)
+		return
+	}
+
+	format, qerr := responseFormat(r)
+	if qerr != nil {
+		writeQueryError(w, r, qerr)
+		return
+	}
+	w.Header().Add("Vary", "Accept")
+
+	games := gamesForTeam(team)
+	if len(games) == 0 {
+		writeError(w, r, http.StatusNotFound, "no games found for team "+team)
+		return
+	}
+	if filtered {
+		kept := make([]ProcessedGameStats, 0, len(games))
+		for _, p := range games {
+			if opponents.keep(p) {
+				kept = append(kept, p)
+			}
+		}
+		games = kept
+	}
+	if rater.Version() != defaultAlgorithm {
+		games = rerateGames(rater, games)
+	}
+	if format != formatJSON {
+		if err := writeGameRows(w, r, format, "team", games); err != nil {
+			log.Printf("Warning: streaming %s: %v", r.URL.RequestURI(), err)
+		}
+		return
+	}
+
+	var body any = games
+	if isSpoilerFree(r) {
+		body = spoilerFreeGames(games)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	setCacheHeaders(w, "team")
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		writeError(w, r, http.StatusInternalServerError, "error encoding response")
+	}
+}
+
+// gamesBetween returns the indexed games between two teams, each matched
+// like on /teams/{team}/games
+func gamesBetween(teamA, teamB string) []ProcessedGameStats {
+	key := pairKey(strings.ToLower(strings.TrimSpace(teamA)), strings.ToLower(strings.TrimSpace(teamB)))
+	teamIndexMu.RLock()
+	games := append([]ProcessedGameStats(nil), matchupIndex[key]...)
+	teamIndexMu.RUnlock()
+	return withCommunityRatings(games)
+}
+
+// withCommunityRatings updates the community ratings of indexed games,
+// which change with every vote
+func withCommunityRatings(games []ProcessedGameStats) []ProcessedGameStats {
+	for i := range games {
+		games[i].CommunityRating, games[i].CommunityVotes = community.rating(games[i].ID)
+	}
+	return games
+}
+
+// handleMatchup returns every cached game between two teams across
+// seasons, most rewatchable first
+func handleMatchup(w http.ResponseWriter, r *http.Request) {
+	teamA, teamB := r.PathValue("teamA"), r.PathValue("teamB")
+	if strings.EqualFold(strings.TrimSpace(teamA), strings.TrimSpace(teamB)) {
+		writeError(w, r, http.StatusBadRequest, "a matchup needs two different teams")
+		return
+	}
+
+	rater, qerr := raterFor(r)
+	if qerr != nil {
+		writeQueryError(w, r, qerr)
+		return
+	}
+
+	format, qerr := responseFormat(r)
+	if qerr != nil {
+		writeQueryError(w, r, qerr)
+		return
+	}
+	w.Header().Add("Vary", "Accept")
+
+	games := gamesBetween(teamA, teamB)
+	if len(games) == 0 {
+		writeError(w, r, http.StatusNotFound, "no games found between "+teamA+" and "+teamB)
+		return
+	}
+	if rater.Version() != defaultAlgorithm {
+		games = rerateGames(rater, games)
+	}
+	sort.SliceStable(games, func(i, j int) bool {
+		a, b := games[i], games[j]
+		if a.TotalRating != b.TotalRating {
+			return a.TotalRating > b.TotalRating
+		}
+		if a.Season != b.Season {
+			return a.Season < b.Season
+		}
+		wa, _ := weekOrder(a.Week)
+		wb, _ := weekOrder(b.Week)
+		return wa < wb
+	})
+	if format != formatJSON {
+		if err := writeGameRows(w, r, format, "team", games); err != nil {
+			log.Printf("Warning: streaming %s: %v", r.URL.RequestURI(), err)
+		}
+		return
+	}
+
+	var body any = games
+	if isSpoilerFree(r) {
+		body = spoilerFreeGames(games)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	setCacheHeaders(w, "team")
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		writeError(w, r, http.StatusInternalServerError, "error encoding response")
+	}
+}
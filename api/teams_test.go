@@ -0,0 +1,131 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHandleTeamGames(t *testing.T) {
+	useTestStore(t, setupTestData(t))
+	teamIndexMu.Lock()
+	teamIndex = make(map[string][]ProcessedGameStats)
+	teamIndexMu.Unlock()
+
+	preloadCache(dataStore)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /teams/{team}/games", handleTeamGames)
+
+	// Abbreviation, full name and nickname all resolve to the same games
+	for _, team := range []string{"A", "b", "Team%20B"} {
+		req := httptest.NewRequest("GET", "/teams/"+team+"/games", nil)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("team %s: expected status 200, got %d", team, rec.Code)
+		}
+		var games []ProcessedGameStats
+		if err := json.Unmarshal(rec.Body.Bytes(), &games); err != nil {
+			t.Fatalf("failed to parse response: %v", err)
+		}
+		if len(games) != 2 {
+			t.Fatalf("team %s: expected 2 games, got %d", team, len(games))
+		}
+		if games[0].Season != "2024" || games[0].Week != "1" || games[1].Week != "2" {
+			t.Errorf("team %s: unexpected season/week ordering: %+v", team, games)
+		}
+		if games[0].TotalRating == 0 {
+			t.Errorf("team %s: expected processed ratings", team)
+		}
+	}
+
+	// ?teams= filters the opponents
+	for query, want := range map[string]int{"teams=B": 2, "teams=Z,team%20b": 2, "teams=Z": 0} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest("GET", "/teams/A/games?"+query, nil))
+		var games []ProcessedGameStats
+		if err := json.Unmarshal(rec.Body.Bytes(), &games); rec.Code != http.StatusOK || err != nil || games == nil || len(games) != want {
+			t.Errorf("%s: expected %d games, got %d %s", query, want, rec.Code, rec.Body)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/teams/ZZZ/games", nil)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for unknown team, got %d", rec.Code)
+	}
+}
+
+func TestHandleMatchup(t *testing.T) {
+	dir := setupTestData(t)
+	useTestStore(t, dir)
+	if err := os.MkdirAll(filepath.Join(dir, "2023"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	classic := strings.NewReplacer(`"game1"`, `"classic"`, "A @ B", "B @ A", "Team A vs Team B", "Team B at Team A", "8.5", "20").Replace(testData)
+	other := `[{"id": "other", "shortName": "A @ C", "fullName": "Team A at Team C"}]`
+	for name, data := range map[string]string{"5.json": classic, "6.json": other} {
+		if err := os.WriteFile(filepath.Join(dir, "2023", name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	teamIndexMu.Lock()
+	teamIndex = make(map[string][]ProcessedGameStats)
+	matchupIndex = make(map[string][]ProcessedGameStats)
+	teamIndexMu.Unlock()
+
+	preloadCache(dataStore)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /matchups/{teamA}/{teamB}", handleMatchup)
+	get := func(url string) (int, []ProcessedGameStats) {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest("GET", url, nil))
+		var games []ProcessedGameStats
+		json.Unmarshal(rec.Body.Bytes(), &games)
+		return rec.Code, games
+	}
+
+	// Either order, abbreviations, full names and nicknames all match
+	for _, url := range []string{"/matchups/A/B", "/matchups/b/a", "/matchups/Team%20B/A", "/matchups/a/Team%20B"} {
+		code, games := get(url)
+		if code != http.StatusOK || len(games) != 3 {
+			t.Fatalf("%s: expected 3 games, got %d %d", url, code, len(games))
+		}
+		if games[0].ID != "classic" || games[0].Season != "2023" {
+			t.Errorf("%s: expected the best rated game first, got %s", url, games[0].ID)
+		}
+		if games[1].Week != "1" || games[2].Week != "2" {
+			t.Errorf("%s: expected ties in week order, got %s, %s", url, games[1].Week, games[2].Week)
+		}
+	}
+	if code, games := get("/matchups/C/A"); code != http.StatusOK || len(games) != 1 || games[0].ID != "other" {
+		t.Errorf("C vs A: got %d %+v", code, games)
+	}
+	if code, _ := get("/matchups/B/C"); code != http.StatusNotFound {
+		t.Errorf("expected 404 for teams that never met, got %d", code)
+	}
+	if code, _ := get("/matchups/A/a"); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for the same team twice, got %d", code)
+	}
+}
+
+func TestSplitMatchup(t *testing.T) {
+	tests := map[string][]string{
+		"BAL @ KC":                               {"bal", "kc"},
+		"GB VS PHI":                              {"gb", "phi"},
+		"Baltimore Ravens at Kansas City Chiefs": {"baltimore ravens", "kansas city chiefs"},
+	}
+	for in, want := range tests {
+		got := splitMatchup(in)
+		if len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+			t.Errorf("splitMatchup(%q) = %v, want %v", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// TeamSeasonSummary aggregates the games of one team in one season
+type TeamSeasonSummary struct {
+	Team                string  `json:"team"`
+	Season              string  `json:"season"`
+	Games               int     `json:"games"`
+	AverageTotalRating  float64 `json:"averageTotalRating"`
+	HighQualityMatchups int     `json:"highQualityMatchups"`
+
+	// Component averages, dropped in spoiler-free mode like the component
+	// ratings of each game
+	AverageOffensiveRating   *float64 `json:"averageOffensiveRating,omitempty"`
+	AverageDefensiveBigPlays *float64 `json:"averageDefensiveBigPlays,omitempty"`
+	AverageScenarioRating    *float64 `json:"averageScenarioRating,omitempty"`
+
+	// BestGame is the game with the highest TotalRating, a
+	// ProcessedGameStats or a SpoilerFreeGame
+	BestGame any `json:"bestGame"`
+}
+
+// summarizeTeamSeason aggregates games, which must not be empty, leaving
+// out what hints at the scores when spoilerFree is set
+func summarizeTeamSeason(team, season string, games []ProcessedGameStats, spoilerFree bool) TeamSeasonSummary {
+	s := TeamSeasonSummary{Team: team, Season: season, Games: len(games)}
+	var total, offense, defense, scenario float64
+	best := games[0]
+	for _, g := range games {
+		total += g.TotalRating
+		offense += g.OffensiveRating
+		defense += g.DefensiveBigPlays
+		scenario += g.ScenarioRating
+		if g.MatchupQuality == "high" {
+			s.HighQualityMatchups++
+		}
+		if g.TotalRating > best.TotalRating {
+			best = g
+		}
+	}
+	n := float64(len(games))
+	s.AverageTotalRating = round2(total / n)
+	if spoilerFree {
+		s.BestGame = spoilerFreeGames([]ProcessedGameStats{best})[0]
+		return s
+	}
+	offense, defense, scenario = round2(offense/n), round2(defense/n), round2(scenario/n)
+	s.AverageOffensiveRating = &offense
+	s.AverageDefensiveBigPlays = &defense
+	s.AverageScenarioRating = &scenario
+	s.BestGame = best
+	return s
+}
+
+// handleTeamSeasonSummary aggregates the games of a team in one season,
+// the team being matched like on /teams/{team}/games
+func handleTeamSeasonSummary(w http.ResponseWriter, r *http.Request) {
+	team := r.PathValue("team")
+	year := r.PathValue("year")
+	if _, err := strconv.Atoi(year); err != nil {
+		writeError(w, r, http.StatusNotFound, "unknown season "+year)
+		return
+	}
+
+	rater, qerr := raterFor(r)
+	if qerr != nil {
+		writeQueryError(w, r, qerr)
+		return
+	}
+
+	var games []ProcessedGameStats
+	for _, g := range gamesForTeam(team) {
+		if g.Season == year {
+			games = append(games, g)
+		}
+	}
+	if len(games) == 0 {
+		writeError(w, r, http.StatusNotFound, "no games found for team "+team+" in "+year)
+		return
+	}
+	if rater.Version() != defaultAlgorithm {
+		games = rerateGames(rater, games)
+	}
+
+	summary := summarizeTeamSeason(team, year, games, isSpoilerFree(r))
+	w.Header().Set("Content-Type", "application/json")
+	setCacheHeaders(w, "team")
+	if err := json.NewEncoder(w).Encode(summary); err != nil {
+		writeError(w, r, http.StatusInternalServerError, "error encoding response")
+	}
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSummarizeTeamSeason(t *testing.T) {
+	games := []ProcessedGameStats{
+		{ID: "a", MatchupQuality: "high", OffensiveRating: 3, DefensiveBigPlays: 1, ScenarioRating: 2, TotalRating: 6},
+		{ID: "b", MatchupQuality: "low", OffensiveRating: 5, DefensiveBigPlays: 2, ScenarioRating: 4, TotalRating: 11},
+		{ID: "c", MatchupQuality: "high", OffensiveRating: 1, DefensiveBigPlays: 0, ScenarioRating: 1, TotalRating: 2},
+	}
+
+	s := summarizeTeamSeason("KC", "2024", games, false)
+	if s.Games != 3 || s.HighQualityMatchups != 2 || s.AverageTotalRating != 6.33 {
+		t.Errorf("unexpected summary %+v", s)
+	}
+	if *s.AverageOffensiveRating != 3 || *s.AverageDefensiveBigPlays != 1 || *s.AverageScenarioRating != 2.33 {
+		t.Errorf("unexpected component averages %v %v %v", *s.AverageOffensiveRating, *s.AverageDefensiveBigPlays, *s.AverageScenarioRating)
+	}
+	if best, ok := s.BestGame.(ProcessedGameStats); !ok || best.ID != "b" {
+		t.Errorf("expected game b as the best game, got %+v", s.BestGame)
+	}
+
+	s = summarizeTeamSeason("KC", "2024", games, true)
+	if s.AverageOffensiveRating != nil || s.AverageDefensiveBigPlays != nil || s.AverageScenarioRating != nil {
+		t.Errorf("expected no component averages in spoiler-free mode, got %+v", s)
+	}
+	if best, ok := s.BestGame.(SpoilerFreeGame); !ok || best.ID != "b" {
+		t.Errorf("expected a spoiler-free best game, got %+v", s.BestGame)
+	}
+}
+
+func TestHandleTeamSeasonSummary(t *testing.T) {
+	useTestStore(t, setupTestData(t))
+	teamIndexMu.Lock()
+	teamIndex = make(map[string][]ProcessedGameStats)
+	teamIndexMu.Unlock()
+	preloadCache(dataStore)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /teams/{team}/summary/{year}", handleTeamSeasonSummary)
+	get := func(url string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest("GET", url, nil))
+		return rec
+	}
+
+	rec := get("/teams/B/summary/2024")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	var s struct {
+		TeamSeasonSummary
+		BestGame ProcessedGameStats `json:"bestGame"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Games != 2 || s.HighQualityMatchups != 2 || s.AverageTotalRating != 21.5 || s.BestGame.Week != "1" {
+		t.Errorf("unexpected summary %s", rec.Body)
+	}
+
+	if body := get("/teams/B/summary/2024?spoilerFree=true").Body.String(); strings.Contains(body, "averageOffensiveRating") || strings.Contains(body, "offensiveRating") {
+		t.Errorf("expected no component ratings in spoiler-free mode, got %s", body)
+	}
+	if code := get("/teams/B/summary/2023").Code; code != http.StatusNotFound {
+		t.Errorf("expected 404 for a season without games, got %d", code)
+	}
+	if code := get("/teams/ZZZ/summary/2024").Code; code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown team, got %d", code)
+	}
+}
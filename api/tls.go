@@ -0,0 +1,215 @@
+package api
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// The server speaks HTTPS itself when either of these is set:
+//
+//	TLS_CERT_FILE, TLS_KEY_FILE  a certificate and its key, PEM encoded,
+//	                             reloaded when they change on disk
+//	TLS_AUTOCERT_DOMAINS         comma-separated domains to get Let's
+//	                             Encrypt certificates for, cached in
+//	                             TLS_AUTOCERT_CACHE, with TLS_AUTOCERT_EMAIL
+//	                             as the account contact
+//
+// PORT then defaults to 443, and HTTP_PORT, 80 by default with autocert,
+// answers the HTTP-01 challenges and redirects everything else to HTTPS.
+// HTTP_PORT=off turns the HTTP listener off.
+const (
+	defaultTLSPort       = "443"
+	defaultHTTPPort      = "80"
+	defaultAutocertCache = "autocert-cache"
+
+	// certCheckInterval is how often a certificate file is checked for
+	// a renewed one
+	certCheckInterval = time.Minute
+)
+
+// tlsSetup is the HTTPS configuration of the server
+type tlsSetup struct {
+	config *tls.Config
+	// httpPort serves httpHandler, empty without an HTTP listener
+	httpPort    string
+	httpHandler http.Handler
+}
+
+// tlsEnabled reports whether the settings ask for HTTPS
+func tlsEnabled(cfg Config) bool {
+	return cfg.TLS.CertFile != "" || len(cfg.TLS.AutocertDomains) > 0
+}
+
+// checkTLS reports the conflicting or incomplete HTTPS settings
+func checkTLS(cfg Config) error {
+	t := cfg.TLS
+	switch {
+	case t.CertFile != "" && len(t.AutocertDomains) > 0:
+		return errors.New("set either TLS_CERT_FILE or TLS_AUTOCERT_DOMAINS, not both")
+	case (t.CertFile == "") != (t.KeyFile == "") && len(t.AutocertDomains) == 0:
+		return errors.New("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	return nil
+}
+
+// tlsFromConfig builds the HTTPS configuration of the settings, nil when
+// the server speaks plain HTTP
+func tlsFromConfig(cfg Config) (*tlsSetup, error) {
+	if err := checkTLS(cfg); err != nil {
+		return nil, err
+	}
+	t := cfg.TLS
+	if t.CertFile == "" && t.KeyFile == "" && len(t.AutocertDomains) == 0 {
+		return nil, nil
+	}
+
+	httpPort, port := cfg.Server.HTTPPort, listenPort(cfg)
+	redirect := redirectToHTTPS(port)
+	var setup *tlsSetup
+	if len(t.AutocertDomains) > 0 {
+		m := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(t.AutocertDomains...),
+			Email:      t.AutocertEmail,
+		}
+		if dir := t.AutocertCache; dir != "off" {
+			if dir == "" {
+				dir = defaultAutocertCache
+			}
+			m.Cache = autocert.DirCache(dir)
+		}
+		if u := t.AutocertDirectory; u != "" {
+			m.Client = &acme.Client{DirectoryURL: u}
+		}
+		if httpPort == "" {
+			httpPort = defaultHTTPPort
+		}
+		setup = &tlsSetup{config: m.TLSConfig(), httpHandler: m.HTTPHandler(redirect)}
+		log.Printf("Serving HTTPS with Let's Encrypt certificates for %s", strings.Join(t.AutocertDomains, ", "))
+	} else {
+		certs, err := newCertReloader(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		setup = &tlsSetup{
+			config:      &tls.Config{GetCertificate: certs.getCertificate, NextProtos: []string{"h2", "http/1.1"}},
+			httpHandler: redirect,
+		}
+		log.Printf("Serving HTTPS with the certificate of %s", t.CertFile)
+	}
+	setup.config.MinVersion = tls.VersionTLS12
+	if httpPort != "off" {
+		setup.httpPort = httpPort
+	}
+	return setup, nil
+}
+
+// listen listens on port, speaking TLS when the setup is not nil
+func (t *tlsSetup) listen(port string) (net.Listener, error) {
+	ln, err := net.Listen("tcp", ":"+port)
+	if err != nil || t == nil {
+		return ln, err
+	}
+	return tls.NewListener(ln, t.config), nil
+}
+
+// serveHTTP runs the HTTP listener of the challenges and redirects in the
+// background until ctx is cancelled
+func (t *tlsSetup) serveHTTP(ctx context.Context, drain time.Duration) error {
+	if t == nil || t.httpPort == "" {
+		return nil
+	}
+	ln, err := net.Listen("tcp", ":"+t.httpPort)
+	if err != nil {
+		return fmt.Errorf("HTTP_PORT: %w", err)
+	}
+	go func() {
+		if err := serve(ctx, newHTTPServer(":"+t.httpPort, t.httpHandler), ln, drain); err != nil {
+			log.Printf("Warning: HTTP listener: %v", err)
+		}
+	}()
+	log.Printf("Redirecting HTTP on :%s to HTTPS", t.httpPort)
+	return nil
+}
+
+// redirectToHTTPS redirects requests to the same URL over HTTPS on port
+func redirectToHTTPS(port string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if port != defaultTLSPort {
+			host = net.JoinHostPort(host, port)
+		}
+		status := http.StatusPermanentRedirect
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			status = http.StatusMovedPermanently
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), status)
+	})
+}
+
+// certReloader serves a certificate from files, loading them again when
+// they change, as when certbot renews them
+type certReloader struct {
+	certFile, keyFile string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+	checked time.Time
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	c := &certReloader{certFile: certFile, keyFile: keyFile}
+	if err := c.reload(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// reload loads the certificate and its key
+func (c *certReloader) reload() error {
+	info, err := os.Stat(c.certFile)
+	if err != nil {
+		return fmt.Errorf("TLS_CERT_FILE: %w", err)
+	}
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return fmt.Errorf("load TLS certificate: %w", err)
+	}
+	c.cert, c.modTime = &cert, info.ModTime()
+	return nil
+}
+
+// getCertificate returns the certificate, reloaded when the file changed
+// since the last check. A renewal that fails to load keeps the previous
+// certificate.
+func (c *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if now := time.Now(); now.Sub(c.checked) >= certCheckInterval {
+		c.checked = now
+		if info, err := os.Stat(c.certFile); err == nil && !info.ModTime().Equal(c.modTime) {
+			if err := c.reload(); err != nil {
+				log.Printf("Warning: keeping the previous certificate: %v", err)
+			} else {
+				log.Printf("Reloaded the certificate of %s", c.certFile)
+			}
+		}
+	}
+	return c.cert, nil
+}
@@ -0,0 +1,165 @@
+package api
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// writeTestCert writes a self-signed certificate for localhost and its key
+// to dir
+func writeTestCert(t *testing.T, dir, name string) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+	return certFile, keyFile
+}
+
+func TestTLSFromConfig(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCert(t, dir, "first")
+
+	if setup, err := tlsFromConfig(Config{}); setup != nil || err != nil {
+		t.Errorf("expected plain HTTP by default, got %+v, %v", setup, err)
+	}
+	for _, env := range []map[string]string{
+		{"TLS_CERT_FILE": certFile},
+		{"TLS_CERT_FILE": certFile, "TLS_KEY_FILE": keyFile, "TLS_AUTOCERT_DOMAINS": "example.com"},
+		{"TLS_CERT_FILE": filepath.Join(dir, "missing.pem"), "TLS_KEY_FILE": keyFile},
+	} {
+		if _, err := tlsFromConfig(configOf(t, env)); err == nil {
+			t.Errorf("%v: expected an error", env)
+		}
+	}
+
+	setup, err := tlsFromConfig(configOf(t, map[string]string{"TLS_CERT_FILE": certFile, "TLS_KEY_FILE": keyFile}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if setup.httpPort != "" {
+		t.Errorf("expected no HTTP listener without HTTP_PORT, got %q", setup.httpPort)
+	}
+
+	ln, err := setup.listen("0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go serve(ctx, newHTTPServer("", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.TLS.ServerName)
+	})), ln, time.Second)
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true, ServerName: "localhost"}}}
+	resp, err := client.Get("https://" + ln.Addr().String() + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "localhost" || resp.TLS.PeerCertificates[0].Subject.CommonName != "first" {
+		t.Errorf("expected the configured certificate, got %q %s", body, resp.TLS.PeerCertificates[0].Subject)
+	}
+}
+
+func TestAutocertFromConfig(t *testing.T) {
+	setup, err := tlsFromConfig(configOf(t, map[string]string{
+		"TLS_AUTOCERT_DOMAINS": "api.example.com, www.example.com",
+		"TLS_AUTOCERT_CACHE":   t.TempDir(),
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if setup.httpPort != defaultHTTPPort {
+		t.Errorf("expected the HTTP-01 challenges on port 80, got %q", setup.httpPort)
+	}
+	if !slices.Contains(setup.config.NextProtos, "acme-tls/1") {
+		t.Errorf("expected the TLS-ALPN-01 protocol, got %v", setup.config.NextProtos)
+	}
+
+	rec := httptest.NewRecorder()
+	setup.httpHandler.ServeHTTP(rec, httptest.NewRequest("GET", "http://api.example.com/seasons?algo=v2", nil))
+	if loc := rec.Header().Get("Location"); rec.Code != http.StatusMovedPermanently || loc != "https://api.example.com/seasons?algo=v2" {
+		t.Errorf("expected a redirect to HTTPS, got %d %q", rec.Code, loc)
+	}
+
+	off, _ := tlsFromConfig(configOf(t, map[string]string{"TLS_AUTOCERT_DOMAINS": "api.example.com", "TLS_AUTOCERT_CACHE": "off", "HTTP_PORT": "off"}))
+	if off.httpPort != "" {
+		t.Errorf("expected HTTP_PORT=off to turn the HTTP listener off, got %q", off.httpPort)
+	}
+}
+
+func TestRedirectToHTTPS(t *testing.T) {
+	for _, tc := range []struct {
+		port, method, url string
+		status            int
+		want              string
+	}{
+		{"443", "GET", "http://example.com:80/games/2024/1", http.StatusMovedPermanently, "https://example.com/games/2024/1"},
+		{"8443", "GET", "http://example.com/feed.rss", http.StatusMovedPermanently, "https://example.com:8443/feed.rss"},
+		{"443", "POST", "http://example.com/plan", http.StatusPermanentRedirect, "https://example.com/plan"},
+	} {
+		rec := httptest.NewRecorder()
+		redirectToHTTPS(tc.port).ServeHTTP(rec, httptest.NewRequest(tc.method, tc.url, nil))
+		if loc := rec.Header().Get("Location"); rec.Code != tc.status || loc != tc.want {
+			t.Errorf("%s %s: got %d %q, want %d %q", tc.method, tc.url, rec.Code, loc, tc.status, tc.want)
+		}
+	}
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCert(t, dir, "first")
+	c, err := newCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeTestCert(t, dir, "renewed")
+	os.Chtimes(certFile, time.Now(), time.Now().Add(time.Minute))
+	cert, _ := c.getCertificate(nil)
+	if leaf, _ := x509.ParseCertificate(cert.Certificate[0]); leaf.Subject.CommonName != "renewed" {
+		t.Errorf("expected the renewed certificate, got %s", leaf.Subject)
+	}
+
+	os.WriteFile(certFile, []byte("garbage"), 0600)
+	os.Chtimes(certFile, time.Now(), time.Now().Add(2*time.Minute))
+	c.checked = time.Time{}
+	if cert, _ := c.getCertificate(nil); cert == nil {
+		t.Error("expected to keep the previous certificate when the renewal is invalid")
+	}
+}
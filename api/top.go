@@ -0,0 +1,157 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/jjway/rewatchableGamesApi-go/store"
+)
+
+// Bounds of ?n= on the top endpoints
+const (
+	defaultTopN = 10
+	maxTopN     = 100
+)
+
+// topIndex holds the cached games sorted by TotalRating, keyed like the
+// rating quantiles by algorithm and season ("" for all time). Lists are
+// built on first use, warmed at preload, and dropped whenever a week file
+// is reloaded or evicted.
+var (
+	topIndex   = make(map[string][]ProcessedGameStats)
+	topIndexMu sync.Mutex
+)
+
+// topGames returns every cached game of season, or of all seasons when
+// season is empty, rated with rater, best first. Ties keep chronological
+// order.
+func topGames(rater Rater, season string) []ProcessedGameStats {
+	key := rater.Version() + "/" + season
+	topIndexMu.Lock()
+	defer topIndexMu.Unlock()
+	if games, ok := topIndex[key]; ok {
+		return games
+	}
+
+	var games []ProcessedGameStats
+	for name := range cache.weekCounts() {
+		s, file, _ := strings.Cut(name, "/")
+		week := strings.TrimSuffix(file, ".json")
+		if (season != "" && s != season) || !store.IsWeekFile(name) || !isValidWeek(week) {
+			continue
+		}
+		weekGames, ok := catalogGames(name)
+		if !ok {
+			continue
+		}
+		for _, p := range processGames(rater, weekGames) {
+			p.setLocation(s, week)
+			games = append(games, p)
+		}
+	}
+
+	sort.Slice(games, func(i, j int) bool {
+		a, b := games[i], games[j]
+		if a.TotalRating != b.TotalRating {
+			return a.TotalRating > b.TotalRating
+		}
+		if a.Season != b.Season {
+			return a.Season < b.Season
+		}
+		wa, _ := weekOrder(a.Week)
+		wb, _ := weekOrder(b.Week)
+		if wa != wb {
+			return wa < wb
+		}
+		return a.ID < b.ID
+	})
+	topIndex[key] = games
+	return games
+}
+
+// invalidateTopGames drops the sorted lists after a data change
+func invalidateTopGames() {
+	topIndexMu.Lock()
+	clear(topIndex)
+	topIndexMu.Unlock()
+}
+
+// handleTopGames serves the ?n= highest rated games of a season, or of all
+// time on /games/top, of ?teams= when set, with ?normalize= placing each
+// game in its season
+func handleTopGames(w http.ResponseWriter, r *http.Request) {
+	year := r.PathValue("year")
+
+	n := defaultTopN
+	if v := r.URL.Query().Get("n"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed < 1 || parsed > maxTopN {
+			writeQueryError(w, r, &QueryError{Param: "n", Value: v, Message: "must be an integer between 1 and " + strconv.Itoa(maxTopN)})
+			return
+		}
+		n = parsed
+	}
+	rater, qerr := raterFor(r)
+	if qerr != nil {
+		writeQueryError(w, r, qerr)
+		return
+	}
+	normalize, qerr := parseNormalize(r.URL.Query())
+	if qerr != nil {
+		writeQueryError(w, r, qerr)
+		return
+	}
+	teams, filtered, qerr := parseTeams(r.URL.Query())
+	if qerr != nil {
+		writeQueryError(w, r, qerr)
+		return
+	}
+	format, qerr := responseFormat(r)
+	if qerr != nil {
+		writeQueryError(w, r, qerr)
+		return
+	}
+	w.Header().Add("Vary", "Accept")
+
+	games := topGames(rater, year)
+	if len(games) == 0 && year != "" {
+		writeError(w, r, http.StatusNotFound, "no data for season "+year)
+		return
+	}
+	// The list is shared with other requests, filter and normalize a copy
+	if filtered {
+		kept := make([]ProcessedGameStats, 0, n)
+		for _, p := range games {
+			if len(kept) == n {
+				break
+			}
+			if teams.keep(p) {
+				kept = append(kept, p)
+			}
+		}
+		games = kept
+	} else {
+		games = append([]ProcessedGameStats(nil), games[:min(n, len(games))]...)
+	}
+	normalizeGames(games, normalize)
+	if format != formatJSON {
+		if err := writeGameRows(w, r, format, "season", games); err != nil {
+			log.Printf("Warning: streaming %s: %v", r.URL.RequestURI(), err)
+		}
+		return
+	}
+
+	var body any = games
+	if isSpoilerFree(r) {
+		body = spoilerFreeGames(games)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	setCacheHeaders(w, "season")
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		writeError(w, r, http.StatusInternalServerError, "error encoding response")
+	}
+}
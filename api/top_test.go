@@ -0,0 +1,94 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const topTestWeek = `[
+	{"id": "low", "shortName": "A @ B"},
+	{"id": "high", "shortName": "C @ D", "scenario": {"scenarioRating": 5}},
+	{"id": "mid", "shortName": "E @ F", "scenario": {"scenarioRating": 3}}
+]`
+
+func TestHandleTopGames(t *testing.T) {
+	dir := t.TempDir()
+	useTestStore(t, dir)
+	for _, name := range []string{"2023/1.json", "2024/1.json"} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(topTestWeek), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	preloadCache(dataStore)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /games/top", handleTopGames)
+	mux.HandleFunc("GET /games/{year}/top", handleTopGames)
+	get := func(url string) (int, []ProcessedGameStats) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest("GET", url, nil))
+		var games []ProcessedGameStats
+		if rec.Code == http.StatusOK {
+			if err := json.Unmarshal(rec.Body.Bytes(), &games); err != nil {
+				t.Fatalf("%s: failed to parse response: %v", url, err)
+			}
+		}
+		return rec.Code, games
+	}
+
+	code, games := get("/games/top?n=3")
+	if code != http.StatusOK || len(games) != 3 {
+		t.Fatalf("expected 3 games, got %d %v", code, games)
+	}
+	// Ties are broken chronologically
+	if games[0].ID != "high" || games[0].Season != "2023" || games[1].ID != "high" || games[1].Season != "2024" || games[2].ID != "mid" {
+		t.Errorf("unexpected order %+v", games)
+	}
+	for i := 1; i < len(games); i++ {
+		if games[i].TotalRating > games[i-1].TotalRating {
+			t.Errorf("expected descending TotalRating, got %+v", games)
+		}
+	}
+
+	if _, games := get("/games/2024/top"); len(games) != 3 || games[0].Season != "2024" {
+		t.Errorf("expected the 2024 games only, got %+v", games)
+	}
+
+	// ?teams= filters before ?n= cuts the list
+	if _, games := get("/games/top?n=3&teams=A,e"); len(games) != 3 || games[0].ID != "mid" || games[1].ID != "mid" || games[2].ID != "low" {
+		t.Errorf("expected the games of A and E, got %+v", games)
+	}
+	if code, games := get("/games/2024/top?teams=Z"); code != http.StatusOK || games == nil || len(games) != 0 {
+		t.Errorf("expected no games of an absent team, got %d %+v", code, games)
+	}
+	if code, _ := get("/games/top?teams=,"); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an empty teams list, got %d", code)
+	}
+
+	// New data replaces the index
+	var added []GameStats
+	if err := json.Unmarshal([]byte(`[{"id": "new", "shortName": "G @ H", "scenario": {"scenarioRating": 6}}]`), &added); err != nil {
+		t.Fatal(err)
+	}
+	setCached("2024/2.json", added, 0, "")
+	if _, games := get("/games/top?n=1"); len(games) != 1 || games[0].ID != "new" {
+		t.Errorf("expected the new game on top, got %+v", games)
+	}
+
+	for _, url := range []string{"/games/top?n=0", "/games/top?n=101", "/games/top?n=ten"} {
+		if code, _ := get(url); code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", url, code)
+		}
+	}
+	if code, _ := get("/games/1999/top"); code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown season, got %d", code)
+	}
+}
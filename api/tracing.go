@@ -0,0 +1,191 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// serviceName is the service.name of the spans unless OTEL_SERVICE_NAME or
+// OTEL_RESOURCE_ATTRIBUTES says otherwise
+const serviceName = "rewatchable-games-api"
+
+// tracer returns the tracer of the server spans from the current global
+// provider, which records nothing until startTracing installs one
+func tracer() trace.Tracer {
+	return otel.Tracer("github.com/jjway/rewatchableGamesApi-go")
+}
+
+// startTracing exports spans over OTLP/HTTP when OTEL_EXPORTER_OTLP_ENDPOINT
+// or OTEL_EXPORTER_OTLP_TRACES_ENDPOINT is set, sampled and described as
+// the rest of the tracing settings say. The returned function flushes the
+// spans left at shutdown.
+func startTracing(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+	noop := func(context.Context) error { return nil }
+	t := cfg.Tracing
+	endpoint := t.TracesEndpoint
+	if endpoint == "" && t.Endpoint != "" {
+		endpoint = strings.TrimSuffix(t.Endpoint, "/") + "/v1/traces"
+	}
+	if endpoint == "" || t.Disabled {
+		return noop, nil
+	}
+
+	sampler, err := tracingSampler(t.Sampler, t.SamplerArg)
+	if err != nil {
+		return nil, err
+	}
+	attrs, err := resourceAttributes(t.ResourceAttributes)
+	if err != nil {
+		return nil, err
+	}
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(endpoint))
+	if err != nil {
+		return nil, fmt.Errorf("OTLP exporter: %w", err)
+	}
+	// The service name of the settings wins over the one of the attributes
+	attrs = append([]attribute.KeyValue{
+		attribute.String("service.name", serviceName),
+		attribute.String("service.version", versionInfo().Version),
+	}, attrs...)
+	if t.ServiceName != "" {
+		attrs = append(attrs, attribute.String("service.name", t.ServiceName))
+	}
+	opts := []sdktrace.TracerProviderOption{sdktrace.WithBatcher(exporter), sdktrace.WithResource(resource.NewSchemaless(attrs...))}
+	if sampler != nil {
+		opts = append(opts, sdktrace.WithSampler(sampler))
+	}
+	provider := sdktrace.NewTracerProvider(opts...)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	log.Printf("Exporting traces over OTLP")
+	return provider.Shutdown, nil
+}
+
+// tracingSampler builds the sampler of OTEL_TRACES_SAMPLER, with the ratio
+// of OTEL_TRACES_SAMPLER_ARG, nil for the default of the SDK
+func tracingSampler(name, arg string) (sdktrace.Sampler, error) {
+	ratio := 1.0
+	if arg != "" && strings.HasSuffix(name, "traceidratio") {
+		r, err := strconv.ParseFloat(arg, 64)
+		if err != nil || r < 0 || r > 1 {
+			return nil, fmt.Errorf("invalid OTEL_TRACES_SAMPLER_ARG %q: must be a ratio from 0 to 1", arg)
+		}
+		ratio = r
+	}
+	switch name {
+	case "":
+		return nil, nil
+	case "always_on":
+		return sdktrace.AlwaysSample(), nil
+	case "always_off":
+		return sdktrace.NeverSample(), nil
+	case "traceidratio":
+		return sdktrace.TraceIDRatioBased(ratio), nil
+	case "parentbased_always_on":
+		return sdktrace.ParentBased(sdktrace.AlwaysSample()), nil
+	case "parentbased_always_off":
+		return sdktrace.ParentBased(sdktrace.NeverSample()), nil
+	case "parentbased_traceidratio":
+		return sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio)), nil
+	}
+	return nil, fmt.Errorf("invalid OTEL_TRACES_SAMPLER %q", name)
+}
+
+// resourceAttributes parses OTEL_RESOURCE_ATTRIBUTES, comma-separated
+// key=value pairs with percent-encoded values
+func resourceAttributes(v string) ([]attribute.KeyValue, error) {
+	var attrs []attribute.KeyValue
+	for _, pair := range strings.Split(v, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid OTEL_RESOURCE_ATTRIBUTES: %q is not key=value", pair)
+		}
+		value, err := url.PathUnescape(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid OTEL_RESOURCE_ATTRIBUTES: %q: %v", pair, err)
+		}
+		attrs = append(attrs, attribute.String(key, value))
+	}
+	return attrs, nil
+}
+
+// startChildSpan starts a span under the span of ctx. Without one, as in
+// the preload and the background jobs, it records nothing rather than
+// start a trace per week file.
+func startChildSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	parent := trace.SpanFromContext(ctx)
+	if !parent.SpanContext().IsValid() {
+		return ctx, parent
+	}
+	return tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// endSpan records err, if any, on span and ends it
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// tracingMiddleware starts a server span per request, continuing the trace
+// of the caller's traceparent header, named after the mux pattern the
+// request matches
+func tracingMiddleware(mux *http.ServeMux, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		_, route := mux.Handler(r)
+		name := r.Method
+		if route != "" {
+			name = route
+		}
+		ctx, span := tracer().Start(ctx, name,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", r.Method),
+				attribute.String("http.route", route),
+				attribute.String("url.path", r.URL.Path),
+				attribute.String("client.address", clientIP(r)),
+				attribute.String("http.request.id", requestID(r.Context())),
+			))
+		defer span.End()
+
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r.WithContext(ctx))
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		span.SetAttributes(attribute.Int("http.response.status_code", rec.status), attribute.Int64("http.response.body.size", rec.bytes))
+		if rec.status >= 500 {
+			span.SetStatus(codes.Error, http.StatusText(rec.status))
+		}
+	})
+}
+
+// rateGames is processGames traced as the rating computation of a request
+func rateGames(ctx context.Context, rater Rater, games []GameStats) []ProcessedGameStats {
+	_, span := startChildSpan(ctx, "rate",
+		attribute.String("rating.algorithm", rater.Version()),
+		attribute.Int("rating.games", len(games)))
+	defer span.End()
+	return processGames(rater, games)
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func useSpanRecorder(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	rec := tracetest.NewSpanRecorder()
+	oldProvider, oldPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(oldProvider)
+		otel.SetTextMapPropagator(oldPropagator)
+	})
+	return rec
+}
+
+func TestTracingMiddleware(t *testing.T) {
+	useTestStore(t, setupTestData(t))
+	spans := useSpanRecorder(t)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /games/{year}/{week}", handleGamesYearWeek)
+	handler := tracingMiddleware(mux, mux)
+
+	req := httptest.NewRequest("GET", "/games/2024/1", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+
+	var names []string
+	for _, s := range spans.Ended() {
+		names = append(names, s.Name())
+		if s.SpanContext().TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+			t.Errorf("expected %s to continue the caller's trace", s.Name())
+		}
+	}
+	for _, want := range []string{"GET /games/{year}/{week}", "cache.lookup", "store.read", "rate"} {
+		if !slices.Contains(names, want) {
+			t.Errorf("expected a %q span, got %v", want, names)
+		}
+	}
+
+	// Without a request span nothing is recorded
+	spans.Reset()
+	loadGameStats("2024/2.json")
+	if n := len(spans.Ended()); n != 0 {
+		t.Errorf("expected no span outside of a request, got %d", n)
+	}
+}
@@ -0,0 +1,206 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// VALIDATION_MODE sets what happens to the games of a week file that fail
+// validation when the file is loaded:
+//
+//	flag    the default: they are served, and logged and listed by
+//	        /admin/validate
+//	reject  they are left out of the week as well
+//	off     the files are not validated
+const (
+	validationFlag   = "flag"
+	validationReject = "reject"
+	validationOff    = "off"
+)
+
+// maxLoggedIssues bounds the issues logged per file, the report keeps
+// them all
+const maxLoggedIssues = 5
+
+// unitBounds are the plausible values of the stats by their unit tag, no
+// upper bound when negative. A passer rating tops out at 158.3.
+var unitBounds = map[string][2]float64{
+	"count":       {0, -1},
+	"points":      {0, -1},
+	"yards":       {0, -1},
+	"ratio":       {0, -1},
+	"score":       {0, -1},
+	"percent":     {0, 100},
+	"probability": {0, 1},
+	"rating":      {0, 158.3},
+}
+
+// ValidationIssue is one problem of a game of a week file
+type ValidationIssue struct {
+	Index   int    `json:"index"`
+	GameID  string `json:"gameId,omitempty"`
+	Field   string `json:"field,omitempty"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// validateGame checks the game at index i of a week file: the fields every
+// game needs, the bounds of each stat by its unit, and stats without the
+// plays they come from
+func validateGame(i int, g GameStats) []ValidationIssue {
+	var issues []ValidationIssue
+	add := func(field, rule, format string, args ...any) {
+		issues = append(issues, ValidationIssue{Index: i, GameID: g.ID, Field: field, Rule: rule, Message: fmt.Sprintf(format, args...)})
+	}
+
+	for field, v := range map[string]string{"id": g.ID, "shortName": g.ShortName, "fullName": g.FullName} {
+		if v == "" {
+			add(field, "required", "%s is missing", field)
+		}
+	}
+	for _, m := range fieldsMeta.Stats {
+		bounds, ok := unitBounds[m.Unit]
+		if !ok {
+			continue
+		}
+		v := stat(&g, m.Field)
+		switch {
+		case v < bounds[0]:
+			add(m.Field, "range", "%s is %v, below %v", m.Field, v, bounds[0])
+		case bounds[1] >= 0 && v > bounds[1]:
+			add(m.Field, "range", "%s is %v, above %v", m.Field, v, bounds[1])
+		}
+	}
+	if g.Offense.TotalPlays == 0 && (g.Offense.TotalPoints != 0 || g.Offense.TotalYards != 0) {
+		add("offense.totalPlays", "no-plays", "offense.totalPlays is 0 with %v points and %v yards", g.Offense.TotalPoints, g.Offense.TotalYards)
+	}
+	sort.Slice(issues, func(a, b int) bool { return issues[a].Field < issues[b].Field })
+	return issues
+}
+
+// validateGames checks every game of a week file
+func validateGames(games []GameStats) []ValidationIssue {
+	var issues []ValidationIssue
+	for i, g := range games {
+		issues = append(issues, validateGame(i, g)...)
+	}
+	return issues
+}
+
+// FileValidation is the validation of a loaded week file
+type FileValidation struct {
+	File     string            `json:"file"`
+	Games    int               `json:"games"`
+	Rejected int               `json:"rejected,omitempty"`
+	Issues   []ValidationIssue `json:"issues"`
+}
+
+// ValidationReport is the response of GET /admin/validate, listing the
+// files with issues
+type ValidationReport struct {
+	Mode     string           `json:"mode"`
+	Files    int              `json:"files"`
+	Issues   int              `json:"issues"`
+	Rejected int              `json:"rejected"`
+	Results  []FileValidation `json:"results"`
+}
+
+// validationLog keeps the validation of the latest load of each week file
+type validationLog struct {
+	mu    sync.Mutex
+	mode  string
+	files map[string]FileValidation
+}
+
+var validations = &validationLog{mode: validationFlag, files: make(map[string]FileValidation)}
+
+// parseValidationMode parses a VALIDATION_MODE value
+func parseValidationMode(v string) (string, error) {
+	switch v {
+	case "":
+		return validationFlag, nil
+	case validationFlag, validationReject, validationOff:
+		return v, nil
+	}
+	return "", fmt.Errorf("invalid VALIDATION_MODE %q: must be flag, reject or off", v)
+}
+
+func (l *validationLog) setMode(mode string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.mode = mode
+	l.files = make(map[string]FileValidation)
+}
+
+// check validates the games of the week file name as it is loaded, logs
+// its issues and returns the games to serve
+func (l *validationLog) check(name string, games []GameStats) []GameStats {
+	l.mu.Lock()
+	mode := l.mode
+	l.mu.Unlock()
+	if mode == validationOff {
+		return games
+	}
+
+	issues := validateGames(games)
+	result := FileValidation{File: name, Games: len(games), Issues: issues}
+	if mode == validationReject && len(issues) > 0 {
+		bad := make(map[int]bool)
+		for _, issue := range issues {
+			bad[issue.Index] = true
+		}
+		kept := make([]GameStats, 0, len(games)-len(bad))
+		for i, g := range games {
+			if !bad[i] {
+				kept = append(kept, g)
+			}
+		}
+		result.Rejected = len(bad)
+		games = kept
+	}
+
+	for i, issue := range issues {
+		if i == maxLoggedIssues {
+			log.Printf("Validation: %s: %d more issues, see /admin/validate", name, len(issues)-i)
+			break
+		}
+		log.Printf("Validation: %s game %d (%s) [%s] %s", name, issue.Index, issue.GameID, issue.Rule, issue.Message)
+	}
+	if result.Rejected > 0 {
+		log.Printf("Validation: %s: rejected %d of %d games", name, result.Rejected, result.Games)
+	}
+
+	l.mu.Lock()
+	l.files[name] = result
+	l.mu.Unlock()
+	return games
+}
+
+// report lists the files with issues in name order
+func (l *validationLog) report() ValidationReport {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	report := ValidationReport{Mode: l.mode, Files: len(l.files), Results: []FileValidation{}}
+	for _, result := range l.files {
+		if len(result.Issues) == 0 {
+			continue
+		}
+		report.Issues += len(result.Issues)
+		report.Rejected += result.Rejected
+		report.Results = append(report.Results, result)
+	}
+	sort.Slice(report.Results, func(i, j int) bool { return report.Results[i].File < report.Results[j].File })
+	return report
+}
+
+// handleValidationReport serves the validation of the loaded week files
+func handleValidationReport(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(validations.report()); err != nil {
+		writeError(w, r, http.StatusInternalServerError, "error encoding response")
+	}
+}
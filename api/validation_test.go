@@ -0,0 +1,85 @@
+package api
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// badWeek has a valid game, one with negative yards and one with points
+// and no plays
+const badWeek = `[
+	{"id": "1", "shortName": "A @ B", "fullName": "A at B", "offense": {"totalPlays": 120, "totalPoints": 40, "totalYards": 700}},
+	{"id": "2", "shortName": "C @ D", "fullName": "C at D", "offense": {"totalPlays": 110, "totalPoints": 30, "totalYards": -5}},
+	{"id": "3", "shortName": "E @ F", "fullName": "E at F", "offense": {"totalPoints": 21}, "scenario": {"scenarioData": {"maxWinProbability": 1.5}}}
+]`
+
+func TestValidateGame(t *testing.T) {
+	var games []GameStats
+	if err := json.Unmarshal([]byte(badWeek), &games); err != nil {
+		t.Fatal(err)
+	}
+	if issues := validateGame(0, games[0]); len(issues) != 0 {
+		t.Errorf("expected a valid game, got %+v", issues)
+	}
+	if issues := validateGame(1, games[1]); len(issues) != 1 || issues[0].Field != "offense.totalYards" || issues[0].Rule != "range" {
+		t.Errorf("expected the negative yards, got %+v", issues)
+	}
+	rules := make(map[string]string)
+	for _, issue := range validateGame(2, games[2]) {
+		rules[issue.Field] = issue.Rule
+	}
+	if rules["offense.totalPlays"] != "no-plays" || rules["scenario.scenarioData.maxWinProbability"] != "range" {
+		t.Errorf("expected the missing plays and the probability, got %v", rules)
+	}
+	if issues := validateGame(0, GameStats{}); len(issues) != 3 || issues[0].Rule != "required" {
+		t.Errorf("expected the missing id and names, got %+v", issues)
+	}
+}
+
+func TestValidationModes(t *testing.T) {
+	t.Cleanup(func() { validations.setMode(validationFlag) })
+	for _, tt := range []struct {
+		mode     string
+		games    int
+		rejected int
+		results  int
+	}{
+		{validationFlag, 3, 0, 1},
+		{validationReject, 1, 2, 1},
+		{validationOff, 3, 0, 0},
+	} {
+		t.Run(tt.mode, func(t *testing.T) {
+			dir := setupTestData(t)
+			useTestStore(t, dir)
+			os.WriteFile(filepath.Join(dir, "2024", "3.json"), []byte(badWeek), 0644)
+			validations.setMode(tt.mode)
+
+			games, err := loadGameStats("2024/3.json")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(games) != tt.games {
+				t.Errorf("expected %d games served, got %d", tt.games, len(games))
+			}
+
+			rec := httptest.NewRecorder()
+			handleValidationReport(rec, httptest.NewRequest("GET", "/admin/validate", nil))
+			var report ValidationReport
+			if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+				t.Fatal(err)
+			}
+			if report.Mode != tt.mode || len(report.Results) != tt.results || report.Rejected != tt.rejected {
+				t.Errorf("unexpected report %+v", report)
+			}
+			if tt.results > 0 && (report.Results[0].File != "2024/3.json" || report.Issues < 3) {
+				t.Errorf("expected the issues of 2024/3.json, got %+v", report.Results)
+			}
+		})
+	}
+
+	if _, err := parseValidationMode("strict"); err == nil {
+		t.Error("expected an unknown mode to be refused")
+	}
+}
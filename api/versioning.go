@@ -0,0 +1,87 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// currentAPIVersion is the version of the API served under its /{version}/
+// prefix. The unprefixed routes are its deprecated aliases until
+// unversionedSunset.
+const currentAPIVersion = "v1"
+
+// unversionedDeprecated is when the unprefixed aliases were deprecated, in
+// favor of the /v1/ routes
+var unversionedDeprecated = time.Date(2026, time.October, 17, 0, 0, 0, 0, time.UTC)
+
+// unversionedSunset is when the unprefixed aliases stop being served
+var unversionedSunset = time.Date(2027, time.June, 30, 0, 0, 0, 0, time.UTC)
+
+// unversionedPaths stay unprefixed: probes, crawlers and the index do not
+// follow the versions of the API
+var unversionedPaths = map[string]bool{"/": true, "/robots.txt": true, "/readyz": true}
+
+// apiVersionKey is the context key set by the API version path prefix
+type apiVersionKey struct{}
+
+// withAPIVersion serves next as the API version, stripping its /{version}
+// prefix. A version or algorithm prefix nested under another is not a
+// route. A version changing the response shapes mounts a mux of its own
+// routes; v1 serves those of newMux.
+func withAPIVersion(version string, next http.Handler) http.Handler {
+	return http.StripPrefix("/"+version, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if pathPrefix(r) != "" {
+			writeError(w, r, http.StatusNotFound, "no route for "+versionedPath(r, r.URL.Path))
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), apiVersionKey{}, version)))
+	}))
+}
+
+// versionedPath prefixes path, a route of the API, with the version the
+// request was served under, so links and redirects keep to it
+func versionedPath(r *http.Request, path string) string {
+	if version, ok := r.Context().Value(apiVersionKey{}).(string); ok {
+		return "/" + version + path
+	}
+	return path
+}
+
+// deprecationDate formats t as the structured date of a Deprecation header
+func deprecationDate(t time.Time) string {
+	return "@" + strconv.FormatInt(t.Unix(), 10)
+}
+
+// deprecationMiddleware marks the responses of the unprefixed aliases as
+// deprecated since unversionedDeprecated (RFC 9745), with the date they go
+// away and the route of the current
+// version replacing them. An /{algorithm}/ prefix becomes ?algo= there.
+func deprecationMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path
+		if strings.HasPrefix(path, "/"+currentAPIVersion+"/") || unversionedPaths[path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		query := r.URL.Query()
+		for _, version := range algorithmNames() {
+			if rest, ok := strings.CutPrefix(path, "/"+version+"/"); ok {
+				path = "/" + rest
+				query.Set("algo", version)
+				break
+			}
+		}
+		successor := (&url.URL{Path: "/" + currentAPIVersion + path, RawQuery: query.Encode()}).String()
+
+		h := w.Header()
+		h.Set("Deprecation", deprecationDate(unversionedDeprecated))
+		h.Set("Sunset", unversionedSunset.Format(http.TimeFormat))
+		h.Add("Link", "<"+successor+`>; rel="successor-version"`)
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAPIVersions(t *testing.T) {
+	useTestStore(t, setupTestData(t))
+	week, err := loadGameStats("2024/1.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	indexFile("2024/1.json", week)
+	t.Cleanup(func() { unindexFile("2024/1.json") })
+	mux := newMux()
+	handler := deprecationMiddleware(mux)
+
+	get := func(target string) (*httptest.ResponseRecorder, []ProcessedGameStats) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", target, nil))
+		var games []ProcessedGameStats
+		if rec.Code == http.StatusOK && strings.HasPrefix(target, "/v") && !strings.Contains(target, "links=") {
+			if err := json.Unmarshal(rec.Body.Bytes(), &games); err != nil {
+				t.Fatalf("%s: %v", target, err)
+			}
+		}
+		return rec, games
+	}
+
+	get("/games/2024/1?links=true")
+	rec, rated := get("/v1/games/2024/1?algo=v2")
+	if rec.Code != http.StatusOK || rec.Header().Get("Deprecation") != "" || rated[0].Algorithm != "v2" {
+		t.Errorf("expected the current version, got %d %v", rec.Code, rec.Header())
+	}
+	if link := rec.Header().Get("Link"); !strings.Contains(link, `</v1/seasons/2024/games>; rel="up"`) {
+		t.Errorf("expected the links to keep the version, got %s", link)
+	}
+
+	for target, successor := range map[string]string{
+		"/games/2024/1?sort=totalRating": "/v1/games/2024/1?sort=totalRating",
+		"/v2/games/2024/1":               "/v1/games/2024/1?algo=v2",
+		"/seasons":                       "/v1/seasons",
+	} {
+		rec, _ := get(target)
+		h := rec.Header()
+		if rec.Code != http.StatusOK || h.Get("Deprecation") != "@1792195200" || h.Get("Sunset") != "Wed, 30 Jun 2027 00:00:00 GMT" {
+			t.Errorf("%s: expected a deprecated alias, got %d %v", target, rec.Code, h)
+		}
+		if link := strings.Join(h.Values("Link"), ", "); !strings.Contains(link, "<"+successor+`>; rel="successor-version"`) {
+			t.Errorf("%s: expected the successor %s, got %s", target, successor, link)
+		}
+	}
+	if _, games := get("/v2/games/2024/1"); games[0].Algorithm != "v2" {
+		t.Errorf("expected the algorithm prefix to keep working, got %s", games[0].Algorithm)
+	}
+
+	if rec, _ := get("/readyz"); rec.Header().Get("Deprecation") != "" {
+		t.Error("expected the probes to stay unversioned")
+	}
+	if rec, _ := get("/v1/g/2024-w1-a-b"); rec.Code != http.StatusFound || rec.Header().Get("Location") != "/v1/games/2024/1/game1" {
+		t.Errorf("expected a redirect within the version, got %d %s", rec.Code, rec.Header().Get("Location"))
+	}
+	for _, target := range []string{"/v1/v1/games/2024/1", "/v1/v2/games/2024/1", "/v2/v1/games/2024/1", "/v2/v2/games/2024/1"} {
+		if rec, _ := get(target); rec.Code != http.StatusNotFound {
+			t.Errorf("%s: expected 404 for a nested prefix, got %d", target, rec.Code)
+		}
+	}
+	rec, _ = get("/v1/games/2024/1?links=true")
+	if !strings.Contains(rec.Body.String(), `"self":"/v1/games/2024/1?links=true"`) {
+		t.Errorf("expected the links of the version, not those of a cached response, got %s", rec.Body)
+	}
+	if rec, _ := get("/v1/nope"); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown route, got %d", rec.Code)
+	}
+	if class := routeClass(mux, httptest.NewRequest("GET", "/v1/bulk/2024", nil)); class != "export" {
+		t.Errorf("expected the class of the unprefixed route, got %s", class)
+	}
+}
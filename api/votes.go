@@ -0,0 +1,421 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// maxVoteBytes caps the body of the voting requests
+const maxVoteBytes = 16 << 10
+
+// maxVoterID bounds the length of an X-Voter-ID header
+const maxVoterID = 128
+
+// voteCategoryPattern is the form of a category name, e.g. "gameOfTheYear"
+var voteCategoryPattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_-]{0,63}$`)
+
+// VotingWindow is the period and categories of the votes of a season,
+// opened by an admin
+type VotingWindow struct {
+	Categories []string  `json:"categories"`
+	OpensAt    time.Time `json:"opensAt"`
+	ClosesAt   time.Time `json:"closesAt"`
+}
+
+// isOpen reports whether votes are accepted at t
+func (v VotingWindow) isOpen(t time.Time) bool {
+	return !t.Before(v.OpensAt) && t.Before(v.ClosesAt)
+}
+
+func (v VotingWindow) hasCategory(category string) bool {
+	for _, c := range v.Categories {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
+
+// seasonVotes is the window of a season and its ballots: the game ID each
+// voter picked, by category then voter
+type seasonVotes struct {
+	Window  VotingWindow                 `json:"window"`
+	Ballots map[string]map[string]string `json:"ballots"`
+}
+
+// voteBook holds the votes of every season, saved to its repository, by
+// season, after each change
+type voteBook struct {
+	mu      sync.Mutex
+	repo    Repository
+	seasons map[string]*seasonVotes
+}
+
+// votes is the vote book of the server, persisted to the user data
+// repository
+var votes = newVoteBook(newFileRepository(nil))
+
+func newVoteBook(repo Repository) *voteBook {
+	return &voteBook{repo: repo, seasons: make(map[string]*seasonVotes)}
+}
+
+// loadVoteBook reads the votes saved in repo
+func loadVoteBook(repo Repository) (*voteBook, error) {
+	b := newVoteBook(repo)
+	docs, err := repo.Load(context.Background(), votesCollection)
+	if err != nil {
+		return nil, err
+	}
+	for season, doc := range docs {
+		var sv seasonVotes
+		if err := json.Unmarshal(doc, &sv); err != nil {
+			return nil, fmt.Errorf("votes of %s: %w", season, err)
+		}
+		b.seasons[season] = &sv
+	}
+	return b, nil
+}
+
+// save writes the votes of season to the repository. The caller holds
+// b.mu.
+func (b *voteBook) save(season string) error {
+	data, err := json.Marshal(b.seasons[season])
+	if err != nil {
+		return err
+	}
+	return b.repo.Put(context.Background(), votesCollection, season, data)
+}
+
+// open opens the votes of season with window. Ballots of the categories
+// kept from a previous window stay counted.
+func (b *voteBook) open(season string, window VotingWindow) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	sv, ok := b.seasons[season]
+	if !ok {
+		sv = &seasonVotes{Ballots: make(map[string]map[string]string)}
+		b.seasons[season] = sv
+	}
+	sv.Window = window
+	for category := range sv.Ballots {
+		if !window.hasCategory(category) {
+			delete(sv.Ballots, category)
+		}
+	}
+	return b.save(season)
+}
+
+// closeAt moves the end of the votes of season to t, reporting false
+// when the season has no votes
+func (b *voteBook) closeAt(season string, t time.Time) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	sv, ok := b.seasons[season]
+	if !ok {
+		return false, nil
+	}
+	if t.Before(sv.Window.ClosesAt) {
+		sv.Window.ClosesAt = t
+	}
+	return true, b.save(season)
+}
+
+// Errors of voteBook.cast
+var (
+	errVotingClosed    = errors.New("voting is closed")
+	errUnknownCategory = errors.New("unknown category")
+	errAlreadyVoted    = errors.New("already voted in this category")
+)
+
+// cast records the vote of voter for gameID in category
+func (b *voteBook) cast(season, category, voter, gameID string, now time.Time) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	sv, ok := b.seasons[season]
+	if !ok || !sv.Window.isOpen(now) {
+		return errVotingClosed
+	}
+	if !sv.Window.hasCategory(category) {
+		return errUnknownCategory
+	}
+	ballots := sv.Ballots[category]
+	if ballots == nil {
+		ballots = make(map[string]string)
+		sv.Ballots[category] = ballots
+	}
+	if _, ok := ballots[voter]; ok {
+		return errAlreadyVoted
+	}
+	ballots[voter] = gameID
+	if err := b.save(season); err != nil {
+		delete(ballots, voter)
+		return err
+	}
+	return nil
+}
+
+// tally counts the votes of season by category and game
+func (b *voteBook) tally(season string) (VotingWindow, map[string]map[string]int, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	sv, ok := b.seasons[season]
+	if !ok {
+		return VotingWindow{}, nil, false
+	}
+	counts := make(map[string]map[string]int, len(sv.Window.Categories))
+	for _, category := range sv.Window.Categories {
+		counts[category] = make(map[string]int)
+		for _, gameID := range sv.Ballots[category] {
+			counts[category][gameID]++
+		}
+	}
+	return sv.Window, counts, true
+}
+
+// VoteStandings is the GET /votes/{year} document
+type VoteStandings struct {
+	Season     string              `json:"season"`
+	Open       bool                `json:"open"`
+	OpensAt    time.Time           `json:"opensAt"`
+	ClosesAt   time.Time           `json:"closesAt"`
+	Categories []CategoryStandings `json:"categories"`
+}
+
+// CategoryStandings ranks the games voted for in a category
+type CategoryStandings struct {
+	Category  string         `json:"category"`
+	Votes     int            `json:"votes"`
+	Standings []GameStanding `json:"standings"`
+}
+
+// GameStanding is the vote count of one game
+type GameStanding struct {
+	ID        string `json:"id"`
+	Week      string `json:"week,omitempty"`
+	Slug      string `json:"slug,omitempty"`
+	ShortName string `json:"shortName,omitempty"`
+	FullName  string `json:"fullName,omitempty"`
+	Votes     int    `json:"votes"`
+}
+
+// seasonGameIndex maps the IDs of the games of a season to the games
+func seasonGameIndex(season string) map[string]ProcessedGameStats {
+	index := make(map[string]ProcessedGameStats)
+	for _, g := range seasonGames(context.Background(), raters[defaultAlgorithm], season) {
+		index[g.ID] = g
+	}
+	return index
+}
+
+// OpenVotingRequest is the body of POST /admin/votes/{year}
+type OpenVotingRequest struct {
+	Categories []string   `json:"categories"`
+	OpensAt    *time.Time `json:"opensAt,omitempty"`
+	ClosesAt   time.Time  `json:"closesAt"`
+}
+
+// handleOpenVoting opens, or reopens with new dates and categories, the
+// votes of a season
+func handleOpenVoting(w http.ResponseWriter, r *http.Request) {
+	year := r.PathValue("year")
+	if _, err := strconv.Atoi(year); err != nil {
+		writeError(w, r, http.StatusNotFound, "unknown season "+year)
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxVoteBytes))
+	if err != nil {
+		writeError(w, r, http.StatusRequestEntityTooLarge, "request body is too large")
+		return
+	}
+	var req OpenVotingRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		writeError(w, r, http.StatusBadRequest, "request body must be a JSON object with categories and closesAt")
+		return
+	}
+
+	window := VotingWindow{Categories: req.Categories, OpensAt: clock.Now().UTC(), ClosesAt: req.ClosesAt.UTC()}
+	if req.OpensAt != nil {
+		window.OpensAt = req.OpensAt.UTC()
+	}
+	if len(window.Categories) == 0 {
+		writeError(w, r, http.StatusUnprocessableEntity, "at least one category is required")
+		return
+	}
+	seen := make(map[string]bool)
+	for _, c := range window.Categories {
+		if !voteCategoryPattern.MatchString(c) || seen[c] {
+			writeError(w, r, http.StatusUnprocessableEntity, "invalid or duplicate category "+strconv.Quote(c))
+			return
+		}
+		seen[c] = true
+	}
+	if !window.ClosesAt.After(window.OpensAt) {
+		writeError(w, r, http.StatusUnprocessableEntity, "closesAt must be after opensAt")
+		return
+	}
+	if dryRun, qerr := dryRunRequested(r); qerr != nil {
+		writeQueryError(w, r, qerr)
+		return
+	} else if dryRun {
+		rep := newDryRunReport("open votes " + year)
+		rep.writeFile(votes.repo.Location(votesCollection))
+		writeDryRun(w, r, rep)
+		return
+	}
+
+	if err := votes.open(year, window); err != nil {
+		log.Printf("Error: save votes: %v", err)
+		writeError(w, r, http.StatusInternalServerError, "could not save the voting window")
+		return
+	}
+	log.Printf("Voting for %s open from %s to %s", year, window.OpensAt.Format(time.RFC3339), window.ClosesAt.Format(time.RFC3339))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(window); err != nil {
+		writeError(w, r, http.StatusInternalServerError, "error encoding response")
+	}
+}
+
+// handleCloseVoting ends the votes of a season now
+func handleCloseVoting(w http.ResponseWriter, r *http.Request) {
+	year := r.PathValue("year")
+	if dryRun, qerr := dryRunRequested(r); qerr != nil {
+		writeQueryError(w, r, qerr)
+		return
+	} else if dryRun {
+		if _, _, ok := votes.tally(year); !ok {
+			writeError(w, r, http.StatusNotFound, "no votes for season "+year)
+			return
+		}
+		rep := newDryRunReport("close votes " + year)
+		rep.writeFile(votes.repo.Location(votesCollection))
+		writeDryRun(w, r, rep)
+		return
+	}
+	ok, err := votes.closeAt(year, clock.Now().UTC())
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "no votes for season "+year)
+		return
+	}
+	if err != nil {
+		log.Printf("Error: save votes: %v", err)
+		writeError(w, r, http.StatusInternalServerError, "could not save the voting window")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Vote is the body of POST /votes/{year}
+type Vote struct {
+	Category string `json:"category"`
+	GameID   string `json:"gameId"`
+}
+
+// requestVoter names the voter of a request: its API key, or the user of
+// the key named by X-Voter-ID. A client voting on behalf of its users,
+// such as the frontend, names them so that each of them votes rather than
+// the key.
+func requestVoter(r *http.Request) (string, error) {
+	key, _ := requestAPIKey(r)
+	voter := key.Name
+	if id := r.Header.Get("X-Voter-ID"); id != "" {
+		if len(id) > maxVoterID {
+			return "", errors.New("X-Voter-ID exceeds " + strconv.Itoa(maxVoterID) + " bytes")
+		}
+		voter += "/" + id
+	}
+	return voter, nil
+}
+
+// handleVote casts the vote of the request's voter for a game of the
+// season, once per category
+func handleVote(w http.ResponseWriter, r *http.Request) {
+	year := r.PathValue("year")
+	voter, err := requestVoter(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxVoteBytes))
+	if err != nil {
+		writeError(w, r, http.StatusRequestEntityTooLarge, "request body is too large")
+		return
+	}
+	var vote Vote
+	if err := json.Unmarshal(data, &vote); err != nil || vote.Category == "" || vote.GameID == "" {
+		writeError(w, r, http.StatusBadRequest, "request body must be a JSON object with a category and a gameId")
+		return
+	}
+	if _, ok := seasonGameIndex(year)[vote.GameID]; !ok {
+		writeError(w, r, http.StatusUnprocessableEntity, "no game "+vote.GameID+" in season "+year)
+		return
+	}
+
+	switch err := votes.cast(year, vote.Category, voter, vote.GameID, clock.Now()); {
+	case errors.Is(err, errVotingClosed):
+		writeError(w, r, http.StatusForbidden, "voting for season "+year+" is not open")
+	case errors.Is(err, errUnknownCategory):
+		writeError(w, r, http.StatusUnprocessableEntity, "unknown category "+strconv.Quote(vote.Category))
+	case errors.Is(err, errAlreadyVoted):
+		writeError(w, r, http.StatusConflict, "already voted in "+vote.Category+" for season "+year)
+	case err != nil:
+		log.Printf("Error: save votes: %v", err)
+		writeError(w, r, http.StatusInternalServerError, "could not record the vote")
+	default:
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+// handleVoteStandings serves the live standings of the votes of a season
+func handleVoteStandings(w http.ResponseWriter, r *http.Request) {
+	year := r.PathValue("year")
+	window, counts, ok := votes.tally(year)
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "no votes for season "+year)
+		return
+	}
+
+	games := seasonGameIndex(year)
+	doc := VoteStandings{
+		Season:   year,
+		Open:     window.isOpen(clock.Now()),
+		OpensAt:  window.OpensAt,
+		ClosesAt: window.ClosesAt,
+	}
+	for _, category := range window.Categories {
+		cs := CategoryStandings{Category: category, Standings: []GameStanding{}}
+		for id, n := range counts[category] {
+			g := games[id]
+			cs.Votes += n
+			cs.Standings = append(cs.Standings, GameStanding{
+				ID: id, Week: g.Week, Slug: g.Slug, ShortName: g.ShortName, FullName: g.FullName, Votes: n,
+			})
+		}
+		sort.Slice(cs.Standings, func(i, j int) bool {
+			a, b := cs.Standings[i], cs.Standings[j]
+			if a.Votes != b.Votes {
+				return a.Votes > b.Votes
+			}
+			return a.ID < b.ID
+		})
+		doc.Categories = append(doc.Categories, cs)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	setCacheHeaders(w, "votes")
+	if err := json.NewEncoder(w).Encode(doc); err != nil {
+		writeError(w, r, http.StatusInternalServerError, "error encoding response")
+	}
+}
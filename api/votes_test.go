@@ -0,0 +1,119 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func useVoteBook(t *testing.T, path string) {
+	t.Helper()
+	old := votes
+	b, err := loadVoteBook(newFileRepository(map[string]string{votesCollection: path}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	votes = b
+	t.Cleanup(func() { votes = old })
+}
+
+func votesMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /votes/{year}", handleVoteStandings)
+	mux.Handle("POST /votes/{year}", requireRole(roleRead, http.HandlerFunc(handleVote)))
+	mux.Handle("POST /admin/votes/{year}", requireRole(roleAdmin, http.HandlerFunc(handleOpenVoting)))
+	mux.Handle("POST /admin/votes/{year}/close", requireRole(roleAdmin, http.HandlerFunc(handleCloseVoting)))
+	return mux
+}
+
+func TestVoting(t *testing.T) {
+	clk := useFakeClock(t)
+	useTestStore(t, setupTestData(t))
+	useAPIKeys(t,
+		APIKey{Name: "ops", Key: "admin-key", Roles: []string{roleAdmin}},
+		APIKey{Name: "frontend", Key: "read-key", Roles: []string{roleRead}},
+	)
+	path := filepath.Join(t.TempDir(), "votes.json")
+	useVoteBook(t, path)
+	mux := votesMux()
+
+	do := func(method, target, key, voter, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("X-API-Key", key)
+		if voter != "" {
+			req.Header.Set("X-Voter-ID", voter)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+	vote := `{"category": "gameOfTheYear", "gameId": "game1"}`
+
+	if rec := do("POST", "/votes/2024", "read-key", "u1", vote); rec.Code != http.StatusForbidden {
+		t.Errorf("expected status 403 before the window opens, got %d", rec.Code)
+	}
+	if rec := do("POST", "/admin/votes/2024", "read-key", "", `{}`); rec.Code != http.StatusForbidden {
+		t.Errorf("expected only admins to open votes, got %d", rec.Code)
+	}
+	if rec := do("POST", "/admin/votes/2024", "admin-key", "", `{"categories": ["gameOfTheYear"], "closesAt": "2024-09-01T00:00:00Z"}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected status 422 for a window closing in the past, got %d", rec.Code)
+	}
+	if rec := do("POST", "/admin/votes/2024", "admin-key", "", `{"categories": ["gameOfTheYear", "bestComeback"], "closesAt": "2024-09-10T00:00:00Z"}`); rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200 opening the votes, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	for _, tt := range []struct {
+		voter, body string
+		status      int
+	}{
+		{"u1", vote, http.StatusCreated},
+		{"u1", vote, http.StatusConflict},
+		{"u2", vote, http.StatusCreated},
+		{"u1", `{"category": "bestComeback", "gameId": "game1"}`, http.StatusCreated},
+		{"u3", `{"category": "bestDefense", "gameId": "game1"}`, http.StatusUnprocessableEntity},
+		{"u3", `{"category": "gameOfTheYear", "gameId": "nope"}`, http.StatusUnprocessableEntity},
+		{"u3", `not json`, http.StatusBadRequest},
+	} {
+		if rec := do("POST", "/votes/2024", "read-key", tt.voter, tt.body); rec.Code != tt.status {
+			t.Errorf("%s %s: expected status %d, got %d", tt.voter, tt.body, tt.status, rec.Code)
+		}
+	}
+
+	rec := do("GET", "/votes/2024", "", "", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	var standings VoteStandings
+	if err := json.Unmarshal(rec.Body.Bytes(), &standings); err != nil {
+		t.Fatal(err)
+	}
+	if !standings.Open || len(standings.Categories) != 2 {
+		t.Fatalf("unexpected standings %+v", standings)
+	}
+	goty := standings.Categories[0]
+	if goty.Category != "gameOfTheYear" || goty.Votes != 2 || len(goty.Standings) != 1 {
+		t.Fatalf("unexpected category %+v", goty)
+	}
+	if s := goty.Standings[0]; s.ID != "game1" || s.Votes != 2 || s.ShortName != "A @ B" {
+		t.Errorf("unexpected standing %+v", s)
+	}
+
+	// Votes survive a restart and stop at the close
+	useVoteBook(t, path)
+	if rec := do("POST", "/votes/2024", "read-key", "u2", vote); rec.Code != http.StatusConflict {
+		t.Errorf("expected the saved ballot to be kept, got %d", rec.Code)
+	}
+	if rec := do("POST", "/admin/votes/2024/close", "admin-key", "", ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("expected status 204 closing the votes, got %d", rec.Code)
+	}
+	clk.Advance(time.Second)
+	if rec := do("POST", "/votes/2024", "read-key", "u4", vote); rec.Code != http.StatusForbidden {
+		t.Errorf("expected status 403 after the close, got %d", rec.Code)
+	}
+	if rec := do("GET", "/votes/2023", "", "", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for a season without votes, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,118 @@
+package api
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jjway/rewatchableGamesApi-go/store"
+)
+
+const (
+	defaultWaitTimeout = 30 * time.Second
+	maxWaitTimeout     = 2 * time.Minute
+
+	// waitRecheck is how often a waiting request looks at the store, for
+	// weeks published without going through ingestion or the watcher
+	waitRecheck = 10 * time.Second
+)
+
+// weekWaiters holds a channel per awaited week file, closed when the week
+// is cached
+var (
+	weekWaiters   = make(map[string]chan struct{})
+	weekWaitersMu sync.Mutex
+)
+
+// publishedSignal returns a channel closed once name is next cached
+func publishedSignal(name string) <-chan struct{} {
+	weekWaitersMu.Lock()
+	defer weekWaitersMu.Unlock()
+	ch, ok := weekWaiters[name]
+	if !ok {
+		ch = make(chan struct{})
+		weekWaiters[name] = ch
+	}
+	return ch
+}
+
+// signalPublished wakes the requests waiting for name
+func signalPublished(name string) {
+	weekWaitersMu.Lock()
+	defer weekWaitersMu.Unlock()
+	if ch, ok := weekWaiters[name]; ok {
+		close(ch)
+		delete(weekWaiters, name)
+	}
+}
+
+// handleWeekWait long-polls until a week is published and then answers
+// like /games/{year}/{week}. After ?timeout= (default 30s, at most 2m)
+// without the week it answers 204 and the client polls again.
+func handleWeekWait(w http.ResponseWriter, r *http.Request) {
+	timeout := defaultWaitTimeout
+	if v := r.URL.Query().Get("timeout"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 || d > maxWaitTimeout {
+			writeQueryError(w, r, &QueryError{Param: "timeout", Value: v, Message: "must be a duration up to " + maxWaitTimeout.String()})
+			return
+		}
+		timeout = d
+	}
+
+	// Only real weeks can be awaited, so waiters stay bounded
+	year, week := r.PathValue("year"), r.PathValue("week")
+	if _, err := strconv.Atoi(year); err != nil {
+		writeError(w, r, http.StatusNotFound, "unknown season "+year)
+		return
+	}
+	if !isValidWeek(week) {
+		writeError(w, r, http.StatusNotFound, "unknown week "+week)
+		return
+	}
+
+	name := store.WeekFile(year, week)
+	published := func() (bool, error) {
+		_, err := loadGameStats(name)
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+
+	// Outlive the server write timeout for the duration of the wait
+	http.NewResponseController(w).SetWriteDeadline(time.Now().Add(timeout + writeTimeout))
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	recheck := time.NewTicker(waitRecheck)
+	defer recheck.Stop()
+
+	for {
+		// Subscribe before checking so a publication in between is not missed
+		signal := publishedSignal(name)
+		ok, err := published()
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, "error reading data")
+			return
+		}
+		if ok {
+			handleGamesYearWeek(w, r)
+			return
+		}
+
+		select {
+		case <-signal:
+		case <-recheck.C:
+		case <-deadline.C:
+			w.Header().Set("Cache-Control", "no-store")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
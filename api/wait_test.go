@@ -0,0 +1,70 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func waitMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /games/{year}/{week}/wait", handleWeekWait)
+	return mux
+}
+
+func TestWeekWaitReturnsPublishedWeek(t *testing.T) {
+	useTestStore(t, setupTestData(t))
+
+	rec := httptest.NewRecorder()
+	waitMux().ServeHTTP(rec, httptest.NewRequest("GET", "/games/2024/1/wait", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected an already published week right away, got %d", rec.Code)
+	}
+}
+
+func TestWeekWaitWakesOnPublication(t *testing.T) {
+	useTestStore(t, setupTestData(t))
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		rec := httptest.NewRecorder()
+		waitMux().ServeHTTP(rec, httptest.NewRequest("GET", "/games/2024/3/wait?timeout=5s", nil))
+		done <- rec
+	}()
+
+	// Let the request subscribe, then publish the week
+	time.Sleep(50 * time.Millisecond)
+	setCached("2024/3.json", []GameStats{{ID: "g3", ShortName: "A @ B"}}, 0, "")
+
+	select {
+	case rec := <-done:
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", rec.Code)
+		}
+		var games []ProcessedGameStats
+		if err := json.Unmarshal(rec.Body.Bytes(), &games); err != nil || len(games) != 1 || games[0].ID != "g3" {
+			t.Errorf("expected the published week, got %s", rec.Body)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the wait to end on publication")
+	}
+}
+
+func TestWeekWaitTimesOut(t *testing.T) {
+	useTestStore(t, setupTestData(t))
+
+	rec := httptest.NewRecorder()
+	waitMux().ServeHTTP(rec, httptest.NewRequest("GET", "/games/2024/3/wait?timeout=20ms", nil))
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("expected 204 after the timeout, got %d", rec.Code)
+	}
+
+	for _, url := range []string{"/games/2024/3/wait?timeout=1h", "/games/2024/3/wait?timeout=soon"} {
+		rec := httptest.NewRecorder()
+		waitMux().ServeHTTP(rec, httptest.NewRequest("GET", url, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", url, rec.Code)
+		}
+	}
+}
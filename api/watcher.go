@@ -0,0 +1,152 @@
+package api
+
+import (
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/jjway/rewatchableGamesApi-go/store"
+)
+
+// reloadFile re-reads a week file from the store and swaps it into the
+// cache and team index. A file that no longer exists is evicted; a file
+// that fails to parse keeps its previous cache entry and the error is
+// returned.
+func reloadFile(name string) error {
+	games, size, hash, err := readGameStats(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		evictFile(name)
+		return nil
+	}
+	if err != nil {
+		log.Printf("Warning: keeping cached %s, reload failed: %v", name, err)
+		return err
+	}
+
+	setCached(name, games, size, hash)
+
+	unindexFile(name)
+	indexFile(name, games)
+	log.Printf("Reloaded %s (%d games)", name, len(games))
+	publishWeekEvent("reloaded", name, games)
+	return nil
+}
+
+// evictFile drops a week file from the cache and team index
+func evictFile(name string) {
+	ok := cache.remove(name)
+
+	responses.invalidate(name)
+	invalidateQuantiles()
+	invalidateTopGames()
+	if ok {
+		invalidateSeasonResponses(name)
+	}
+
+	unindexFile(name)
+	if ok {
+		log.Printf("Evicted %s", name)
+	}
+}
+
+// dirWatcher is a running watchDataDir
+type dirWatcher struct {
+	w    *fsnotify.Watcher
+	done chan struct{}
+}
+
+// Close stops the watches and waits for the event being handled, if any,
+// so that nothing is reloaded once it returns
+func (d *dirWatcher) Close() error {
+	err := d.w.Close()
+	<-d.done
+	return err
+}
+
+// watchDataDir watches a store.Dir root and its year directories, reloading
+// or evicting cache entries as week files change. It returns once the
+// watches are installed; events are handled until the watcher is closed.
+func watchDataDir(root string) (*dirWatcher, error) {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err := w.Add(root); err != nil {
+		w.Close()
+		return nil, err
+	}
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		w.Close()
+		return nil, err
+	}
+	for _, e := range entries {
+		if e.IsDir() {
+			if err := w.Add(filepath.Join(root, e.Name())); err != nil {
+				log.Printf("Warning: could not watch %s: %v", e.Name(), err)
+			}
+		}
+	}
+
+	d := &dirWatcher{w: w, done: make(chan struct{})}
+	go func() {
+		defer close(d.done)
+		for {
+			select {
+			case ev, ok := <-w.Events:
+				if !ok {
+					return
+				}
+				handleWatchEvent(w, root, ev)
+			case err, ok := <-w.Errors:
+				if !ok {
+					return
+				}
+				log.Printf("Warning: file watcher error: %v", err)
+			}
+		}
+	}()
+	return d, nil
+}
+
+func handleWatchEvent(w *fsnotify.Watcher, root string, ev fsnotify.Event) {
+	rel, err := filepath.Rel(root, ev.Name)
+	if err != nil {
+		return
+	}
+	name := filepath.ToSlash(rel)
+
+	// A new year directory: watch it and load any files already inside
+	if !strings.Contains(name, "/") {
+		if ev.Has(fsnotify.Create) {
+			if info, err := os.Stat(ev.Name); err == nil && info.IsDir() {
+				if err := w.Add(ev.Name); err != nil {
+					log.Printf("Warning: could not watch %s: %v", name, err)
+					return
+				}
+				entries, _ := os.ReadDir(ev.Name)
+				for _, e := range entries {
+					if weekName := name + "/" + e.Name(); store.IsWeekFile(weekName) {
+						reloadFile(weekName)
+					}
+				}
+			}
+		}
+		return
+	}
+
+	if !store.IsWeekFile(name) {
+		return
+	}
+	switch {
+	case ev.Has(fsnotify.Remove), ev.Has(fsnotify.Rename):
+		evictFile(name)
+	case ev.Has(fsnotify.Create), ev.Has(fsnotify.Write):
+		reloadFile(name)
+	}
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// waitFor polls cond until it holds or the deadline passes
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func residentGames(name string) ([]GameStats, bool) {
+	entry, ok := cache.peek(name)
+	return entry.games, ok
+}
+
+func TestWatchDataDir(t *testing.T) {
+	tmpDir := setupTestData(t)
+	useTestStore(t, tmpDir)
+	preloadCache(dataStore)
+
+	w, err := watchDataDir(tmpDir)
+	if err != nil {
+		t.Fatalf("failed to start watcher: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := w.Close(); err != nil {
+			t.Errorf("close the watcher: %v", err)
+		}
+	})
+
+	// Modified file is reloaded
+	path := filepath.Join(tmpDir, "2024", "1.json")
+	updated := strings.Replace(testData, `"game1"`, `"game1-fixed"`, 1)
+	if err := os.WriteFile(path, []byte(updated), 0644); err != nil {
+		t.Fatalf("failed to update file: %v", err)
+	}
+	waitFor(t, "reload", func() bool {
+		games, ok := residentGames("2024/1.json")
+		return ok && len(games) == 1 && games[0].ID == "game1-fixed"
+	})
+
+	// Deleted file is evicted
+	if err := os.Remove(path); err != nil {
+		t.Fatalf("failed to remove file: %v", err)
+	}
+	waitFor(t, "eviction", func() bool {
+		_, ok := residentGames("2024/1.json")
+		return !ok
+	})
+
+	// New year directory is picked up
+	yearDir := filepath.Join(tmpDir, "2025")
+	if err := os.Mkdir(yearDir, 0755); err != nil {
+		t.Fatalf("failed to create year: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if err := os.WriteFile(filepath.Join(yearDir, "1.json"), []byte(testData), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	waitFor(t, "new season", func() bool {
+		_, ok := residentGames("2025/1.json")
+		return ok
+	})
+}
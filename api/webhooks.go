@@ -0,0 +1,257 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+)
+
+// maxWebhookBytes caps the body of POST /admin/webhooks
+const maxWebhookBytes = 16 << 10
+
+// defaultWebhookTop is how many games a registered webhook receives when
+// the registration does not say
+const defaultWebhookTop = 10
+
+// webhookRetry is the retry policy of the registered webhooks
+var webhookRetry = RetryPolicy{MaxAttempts: 3, BackoffMs: 1000}
+
+// Webhook is a webhook registered through /admin/webhooks. Secret is only
+// returned by the registration.
+type Webhook struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name,omitempty"`
+	URL       string    `json:"url"`
+	Top       int       `json:"top"`
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// RegisterWebhookRequest is the body of POST /admin/webhooks. A secret is
+// generated when none is given.
+type RegisterWebhookRequest struct {
+	Name   string `json:"name"`
+	URL    string `json:"url"`
+	Top    int    `json:"top"`
+	Secret string `json:"secret"`
+}
+
+// webhookRegistry holds the registered webhooks, saved to its repository,
+// by ID, after each change
+type webhookRegistry struct {
+	mu    sync.Mutex
+	repo  Repository
+	hooks map[string]Webhook
+}
+
+// webhooks is the registry of the server, persisted to the user data
+// repository
+var webhooks = newWebhookRegistry(newFileRepository(nil))
+
+func newWebhookRegistry(repo Repository) *webhookRegistry {
+	return &webhookRegistry{repo: repo, hooks: make(map[string]Webhook)}
+}
+
+// loadWebhookRegistry reads the webhooks saved in repo
+func loadWebhookRegistry(repo Repository) (*webhookRegistry, error) {
+	reg := newWebhookRegistry(repo)
+	docs, err := repo.Load(context.Background(), webhooksCollection)
+	if err != nil {
+		return nil, err
+	}
+	for id, doc := range docs {
+		var h Webhook
+		if err := json.Unmarshal(doc, &h); err != nil {
+			return nil, fmt.Errorf("webhook %s: %w", id, err)
+		}
+		reg.hooks[id] = h
+	}
+	return reg, nil
+}
+
+func (reg *webhookRegistry) add(h Webhook) error {
+	data, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	if err := reg.repo.Put(context.Background(), webhooksCollection, h.ID, data); err != nil {
+		return err
+	}
+	reg.hooks[h.ID] = h
+	return nil
+}
+
+// remove deletes the webhook id, reporting false when there is none
+func (reg *webhookRegistry) has(id string) bool {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	_, ok := reg.hooks[id]
+	return ok
+}
+
+func (reg *webhookRegistry) remove(id string) (bool, error) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	if _, ok := reg.hooks[id]; !ok {
+		return false, nil
+	}
+	if err := reg.repo.Delete(context.Background(), webhooksCollection, id); err != nil {
+		return true, err
+	}
+	delete(reg.hooks, id)
+	return true, nil
+}
+
+// list returns the webhooks oldest first, without their secrets
+func (reg *webhookRegistry) list() []Webhook {
+	reg.mu.Lock()
+	hooks := make([]Webhook, 0, len(reg.hooks))
+	for _, h := range reg.hooks {
+		h.Secret = ""
+		hooks = append(hooks, h)
+	}
+	reg.mu.Unlock()
+	sort.Slice(hooks, func(i, j int) bool {
+		if !hooks[i].CreatedAt.Equal(hooks[j].CreatedAt) {
+			return hooks[i].CreatedAt.Before(hooks[j].CreatedAt)
+		}
+		return hooks[i].ID < hooks[j].ID
+	})
+	return hooks
+}
+
+// notifiers returns a signed webhook notifier per registered webhook
+func (reg *webhookRegistry) notifiers() []Notifier {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	ns := make([]Notifier, 0, len(reg.hooks))
+	for _, h := range reg.hooks {
+		name := h.Name
+		if name == "" {
+			name = "webhook-" + h.ID
+		}
+		ns = append(ns, &retryNotifier{
+			Notifier: &webhookNotifier{name: name, url: h.URL, secret: h.Secret, top: h.Top},
+			policy:   webhookRetry,
+		})
+	}
+	sort.Slice(ns, func(i, j int) bool { return ns[i].Name() < ns[j].Name() })
+	return ns
+}
+
+// randomHex returns n random bytes as hex
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// handleListWebhooks serves the registered webhooks
+func handleListWebhooks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(webhooks.list()); err != nil {
+		writeError(w, r, http.StatusInternalServerError, "error encoding response")
+	}
+}
+
+// handleRegisterWebhook registers a webhook called with the signed
+// week-published payload. The response is the only one carrying the
+// secret.
+func handleRegisterWebhook(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBytes))
+	if err != nil {
+		writeError(w, r, http.StatusRequestEntityTooLarge, "request body is too large")
+		return
+	}
+	var req RegisterWebhookRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		writeError(w, r, http.StatusBadRequest, "request body must be a JSON object with a url")
+		return
+	}
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		writeError(w, r, http.StatusUnprocessableEntity, "url must be an absolute http or https URL")
+		return
+	}
+	if req.Top < 0 {
+		writeError(w, r, http.StatusUnprocessableEntity, "top must not be negative")
+		return
+	}
+	if dryRun, qerr := dryRunRequested(r); qerr != nil {
+		writeQueryError(w, r, qerr)
+		return
+	} else if dryRun {
+		rep := newDryRunReport("register webhook to " + u.Host)
+		rep.writeFile(webhooks.repo.Location(webhooksCollection))
+		writeDryRun(w, r, rep)
+		return
+	}
+
+	h := Webhook{
+		ID:        randomHex(8),
+		Name:      req.Name,
+		URL:       req.URL,
+		Top:       req.Top,
+		Secret:    req.Secret,
+		CreatedAt: clock.Now().UTC(),
+	}
+	if h.Top == 0 {
+		h.Top = defaultWebhookTop
+	}
+	if h.Secret == "" {
+		h.Secret = randomHex(32)
+	}
+	if err := webhooks.add(h); err != nil {
+		log.Printf("Error: save webhooks: %v", err)
+		writeError(w, r, http.StatusInternalServerError, "could not save the webhook")
+		return
+	}
+	log.Printf("Registered webhook %s to %s", h.ID, u.Host)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Location", "/admin/webhooks/"+h.ID)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(h)
+}
+
+// handleDeleteWebhook unregisters a webhook
+func handleDeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if dryRun, qerr := dryRunRequested(r); qerr != nil {
+		writeQueryError(w, r, qerr)
+		return
+	} else if dryRun {
+		if !webhooks.has(id) {
+			writeError(w, r, http.StatusNotFound, "unknown webhook "+id)
+			return
+		}
+		rep := newDryRunReport("delete webhook " + id)
+		rep.writeFile(webhooks.repo.Location(webhooksCollection))
+		writeDryRun(w, r, rep)
+		return
+	}
+	ok, err := webhooks.remove(id)
+	if err != nil {
+		log.Printf("Error: save webhooks: %v", err)
+		writeError(w, r, http.StatusInternalServerError, "could not save the webhooks")
+		return
+	}
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "unknown webhook "+id)
+		return
+	}
+	log.Printf("Deleted webhook %s", id)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,141 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func useWebhookRegistry(t *testing.T, path string) {
+	t.Helper()
+	old := webhooks
+	reg, err := loadWebhookRegistry(newFileRepository(map[string]string{webhooksCollection: path}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	webhooks = reg
+	t.Cleanup(func() { webhooks = old })
+}
+
+func TestWebhookNotifierSignsPayload(t *testing.T) {
+	clk := useFakeClock(t)
+	var body []byte
+	var header http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		header = r.Header
+	}))
+	defer srv.Close()
+
+	n, err := newNotifier(ChannelConfig{Type: "webhook", URL: srv.URL, Secret: "s3cret", Top: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ev := NotifyEvent{Kind: "week-published", Title: "Week 1 of 2024 is out", Games: []ProcessedGameStats{
+		{ID: "best", TotalRating: 12}, {ID: "other", TotalRating: 8},
+	}}
+	if err := n.Notify(context.Background(), ev); err != nil {
+		t.Fatal(err)
+	}
+
+	var got NotifyEvent
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Games) != 1 || got.Games[0].ID != "best" {
+		t.Errorf("expected only the best game, got %+v", got.Games)
+	}
+	ts := header.Get("X-Webhook-Timestamp")
+	if want := strconv.FormatInt(clk.Now().Unix(), 10); ts != want {
+		t.Errorf("expected timestamp %s, got %s", want, ts)
+	}
+	if sig := header.Get("X-Webhook-Signature"); sig != "sha256="+signWebhook("s3cret", ts, body) {
+		t.Errorf("unexpected signature %s", sig)
+	}
+	if signWebhook("s3cret", ts, body) == signWebhook("other", ts, body) {
+		t.Error("expected the signature to depend on the secret")
+	}
+}
+
+func TestWebhookRegistration(t *testing.T) {
+	useFakeClock(t)
+	useTestStore(t, setupTestData(t))
+	useAPIKeys(t,
+		APIKey{Name: "ops", Key: "admin-key", Roles: []string{roleAdmin}},
+		APIKey{Name: "frontend", Key: "read-key", Roles: []string{roleRead}},
+	)
+	path := filepath.Join(t.TempDir(), "webhooks.json")
+	useWebhookRegistry(t, path)
+
+	deliveries := make(chan *http.Request, 4)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		deliveries <- r
+	}))
+	defer srv.Close()
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /admin/webhooks", requireRole(roleAdmin, http.HandlerFunc(handleListWebhooks)))
+	mux.Handle("POST /admin/webhooks", requireRole(roleAdmin, http.HandlerFunc(handleRegisterWebhook)))
+	mux.Handle("DELETE /admin/webhooks/{id}", requireRole(roleAdmin, http.HandlerFunc(handleDeleteWebhook)))
+	do := func(method, target, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("X-API-Key", key)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := do("POST", "/admin/webhooks", "read-key", `{"url": "`+srv.URL+`"}`); rec.Code != http.StatusForbidden {
+		t.Errorf("expected only admins to register webhooks, got %d", rec.Code)
+	}
+	if rec := do("POST", "/admin/webhooks", "admin-key", `{"url": "ftp://example.com"}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected status 422 for a non-HTTP URL, got %d", rec.Code)
+	}
+	rec := do("POST", "/admin/webhooks", "admin-key", `{"name": "discord-bot", "url": "`+srv.URL+`"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var hook Webhook
+	if err := json.Unmarshal(rec.Body.Bytes(), &hook); err != nil {
+		t.Fatal(err)
+	}
+	if hook.ID == "" || len(hook.Secret) != 64 || hook.Top != defaultWebhookTop {
+		t.Fatalf("unexpected webhook %+v", hook)
+	}
+
+	// The registry survives a restart and never lists secrets
+	useWebhookRegistry(t, path)
+	rec = do("GET", "/admin/webhooks", "admin-key", "")
+	var hooks []Webhook
+	if err := json.Unmarshal(rec.Body.Bytes(), &hooks); err != nil {
+		t.Fatal(err)
+	}
+	if len(hooks) != 1 || hooks[0].ID != hook.ID || hooks[0].Secret != "" {
+		t.Fatalf("unexpected webhooks %+v", hooks)
+	}
+
+	games, err := loadGameStats("2024/1.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	notifyWeekPublished("2024", "1", games)
+	r := <-deliveries
+	if r.Header.Get("X-Webhook-Signature") == "" {
+		t.Error("expected the delivery to be signed")
+	}
+
+	if rec := do("DELETE", "/admin/webhooks/"+hook.ID, "admin-key", ""); rec.Code != http.StatusNoContent {
+		t.Errorf("expected status 204, got %d", rec.Code)
+	}
+	if rec := do("DELETE", "/admin/webhooks/"+hook.ID, "admin-key", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for a deleted webhook, got %d", rec.Code)
+	}
+	if n := len(webhooks.notifiers()); n != 0 {
+		t.Errorf("expected no webhooks left, got %d", n)
+	}
+}
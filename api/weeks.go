@@ -0,0 +1,66 @@
+package api
+
+import "strconv"
+
+// regularSeasonWeeks is the length of the longest regular season
+const regularSeasonWeeks = 18
+
+// postseasonWeeks are the playoff rounds in the order they are played.
+// Their week files are named after them, e.g. data/2023/wildcard.json.
+var postseasonWeeks = []string{"wildcard", "divisional", "conference", "superbowl"}
+
+// postseasonLabels are the display names of the playoff rounds
+var postseasonLabels = map[string]string{
+	"wildcard":   "Wild Card",
+	"divisional": "Divisional Round",
+	"conference": "Conference Championships",
+	"superbowl":  "Super Bowl",
+}
+
+// seasonWeekNames returns every week name of a season in canonical order:
+// the regular season weeks, then the playoff rounds
+func seasonWeekNames() []string {
+	names := make([]string, 0, regularSeasonWeeks+len(postseasonWeeks))
+	for week := 1; week <= regularSeasonWeeks; week++ {
+		names = append(names, strconv.Itoa(week))
+	}
+	return append(names, postseasonWeeks...)
+}
+
+// weekOrder returns the canonical position of a week in its season, the
+// playoff rounds following week 18. ok is false for unknown weeks.
+func weekOrder(week string) (order int, ok bool) {
+	if n, err := strconv.Atoi(week); err == nil {
+		return n, n >= 1 && n <= regularSeasonWeeks
+	}
+	for i, name := range postseasonWeeks {
+		if week == name {
+			return regularSeasonWeeks + 1 + i, true
+		}
+	}
+	return 0, false
+}
+
+// isValidWeek reports whether week names a regular season week or a
+// playoff round
+func isValidWeek(week string) bool {
+	_, ok := weekOrder(week)
+	return ok
+}
+
+// isPostseason reports whether week names a playoff round
+func isPostseason(week string) bool {
+	_, ok := postseasonLabels[week]
+	return ok
+}
+
+// weekLabel returns the display name of a week, "Week 5" or "Wild Card"
+func weekLabel(week string) string {
+	if label, ok := postseasonLabels[week]; ok {
+		return label
+	}
+	return "Week " + week
+}
+
+// invalidWeekMessage explains which weeks exist
+const invalidWeekMessage = "must be a week from 1 to 18 or wildcard, divisional, conference or superbowl"
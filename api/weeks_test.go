@@ -0,0 +1,49 @@
+package api
+
+import "testing"
+
+func TestWeekOrder(t *testing.T) {
+	tests := []struct {
+		week  string
+		order int
+		ok    bool
+	}{
+		{"1", 1, true},
+		{"18", 18, true},
+		{"wildcard", 19, true},
+		{"superbowl", 22, true},
+		{"0", 0, false},
+		{"19", 0, false},
+		{"probowl", 0, false},
+		{"Wildcard", 0, false},
+	}
+	for _, tt := range tests {
+		order, ok := weekOrder(tt.week)
+		if ok != tt.ok || (ok && order != tt.order) {
+			t.Errorf("%q: expected %d, %v, got %d, %v", tt.week, tt.order, tt.ok, order, ok)
+		}
+	}
+
+	names := seasonWeekNames()
+	for i := 1; i < len(names); i++ {
+		prev, _ := weekOrder(names[i-1])
+		cur, _ := weekOrder(names[i])
+		if cur <= prev {
+			t.Errorf("expected %s after %s", names[i], names[i-1])
+		}
+	}
+}
+
+func TestWeekLabel(t *testing.T) {
+	for week, want := range map[string]string{
+		"5":          "Week 5",
+		"wildcard":   "Wild Card",
+		"divisional": "Divisional Round",
+		"conference": "Conference Championships",
+		"superbowl":  "Super Bowl",
+	} {
+		if got := weekLabel(week); got != want {
+			t.Errorf("%q: expected %q, got %q", week, want, got)
+		}
+	}
+}
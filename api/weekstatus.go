@@ -0,0 +1,100 @@
+package api
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jjway/rewatchableGamesApi-go/store"
+)
+
+// Week publication states
+const (
+	weekPublished  = "published"
+	weekPending    = "pending"
+	weekInProgress = "in-progress"
+)
+
+// weekStates holds states set explicitly by ingestion, such as a week
+// being in progress while its games are still being written. Weeks without
+// an explicit state are derived from the store.
+var (
+	weekStates   = make(map[string]string)
+	weekStatesMu sync.RWMutex
+)
+
+// setWeekState records an explicit state for a week file; an empty state
+// clears it
+func setWeekState(name, state string) {
+	weekStatesMu.Lock()
+	defer weekStatesMu.Unlock()
+	if state == "" {
+		delete(weekStates, name)
+		return
+	}
+	weekStates[name] = state
+}
+
+// WeekStatus is the response of /games/{year}/{week}/status
+type WeekStatus struct {
+	Season    string     `json:"season"`
+	Week      string     `json:"week"`
+	Status    string     `json:"status"`
+	Games     int        `json:"games"`
+	UpdatedAt *time.Time `json:"updatedAt,omitempty"`
+
+	// SHA256 is the hash of the stored week file, the entity tag to send
+	// in If-Match when replacing the week
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+// handleWeekStatus reports whether a week is published, pending or in
+// progress, so frontends can show "coming soon" instead of a bare 404
+func handleWeekStatus(w http.ResponseWriter, r *http.Request) {
+	year := r.PathValue("year")
+	week := r.PathValue("week")
+
+	if _, err := strconv.Atoi(year); err != nil {
+		writeError(w, r, http.StatusNotFound, "unknown season "+year)
+		return
+	}
+	if !isValidWeek(week) {
+		writeError(w, r, http.StatusNotFound, "unknown week "+week)
+		return
+	}
+
+	name := store.WeekFile(year, week)
+	status := WeekStatus{Season: year, Week: week, Status: weekPending}
+
+	games, err := loadGameStats(name)
+	switch {
+	case err == nil:
+		status.Status = weekPublished
+		status.Games = len(games)
+		if hash := cacheHash(name); hash != "" {
+			status.SHA256 = hash
+			w.Header().Set("ETag", `"`+hash+`"`)
+		}
+		if loadedAt := cacheLoadedAt(name); !loadedAt.IsZero() {
+			status.UpdatedAt = &loadedAt
+		}
+	case !errors.Is(err, fs.ErrNotExist):
+		writeError(w, r, http.StatusInternalServerError, "error reading data")
+		return
+	}
+
+	weekStatesMu.RLock()
+	if state, ok := weekStates[name]; ok {
+		status.Status = state
+	}
+	weekStatesMu.RUnlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	setCacheHeaders(w, "missing")
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		writeError(w, r, http.StatusInternalServerError, "error encoding response")
+	}
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jjway/rewatchableGamesApi-go/store"
+)
+
+func TestHandleWeekStatus(t *testing.T) {
+	useTestStore(t, setupTestData(t))
+	t.Cleanup(func() { setWeekState("2024/3.json", "") })
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /games/{year}/{week}/status", handleWeekStatus)
+	mux.HandleFunc("GET /games/{year}/{week}/{id}", handleGameByID)
+
+	get := func(url string) (int, WeekStatus) {
+		req := httptest.NewRequest("GET", url, nil)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		var status WeekStatus
+		json.Unmarshal(rec.Body.Bytes(), &status)
+		return rec.Code, status
+	}
+
+	if code, s := get("/games/2024/1/status"); code != http.StatusOK || s.Status != weekPublished || s.Games != 1 {
+		t.Errorf("week 1: got %d %+v", code, s)
+	}
+	if _, s := get("/games/2024/1/status"); len(s.SHA256) != 64 {
+		t.Errorf("week 1: expected the file hash, got %q", s.SHA256)
+	}
+
+	// The hash is the one computed when the week was cached, the store is
+	// not read again
+	want, _, _ := weekHash("2024/1.json")
+	data := dataStore
+	dataStore = store.NewDir(t.TempDir())
+	if _, s := get("/games/2024/1/status"); s.SHA256 != want {
+		t.Errorf("week 1: expected the cached hash %s, got %q", want, s.SHA256)
+	}
+	dataStore = data
+	if code, s := get("/games/2024/3/status"); code != http.StatusOK || s.Status != weekPending {
+		t.Errorf("week 3: got %d %+v", code, s)
+	}
+
+	setWeekState("2024/3.json", weekInProgress)
+	if _, s := get("/games/2024/3/status"); s.Status != weekInProgress {
+		t.Errorf("week 3 with ingestion state: got %+v", s)
+	}
+
+	if code, _ := get("/games/2024/25/status"); code != http.StatusNotFound {
+		t.Errorf("week 25: expected 404, got %d", code)
+	}
+	if code, s := get("/games/abc/1/status"); code != http.StatusNotFound {
+		t.Errorf("season abc: expected 404, got %d %+v", code, s)
+	}
+}
@@ -0,0 +1,187 @@
+// Command release builds the server for every supported platform, each in
+// two flavors: one reading its data directory at runtime and one with the
+// data embedded. It writes the binaries, a SHA256SUMS file and a
+// manifest.json describing the release to the output directory.
+//
+// Run it from the module root:
+//
+//	go run ./cmd/release -version v1.4.0
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// binaryName is the base name of the release artifacts
+const binaryName = "rewatchableGamesApi-go"
+
+// defaultTargets are the GOOS/GOARCH pairs released by default
+var defaultTargets = []string{
+	"linux/amd64", "linux/arm64", "darwin/amd64", "darwin/arm64", "windows/amd64",
+}
+
+// Release flavors
+const (
+	flavorPlain    = "plain"
+	flavorEmbedded = "embedded"
+)
+
+// Target is one platform of the release
+type Target struct {
+	OS   string
+	Arch string
+}
+
+// Artifact is one binary of the release
+type Artifact struct {
+	File   string `json:"file"`
+	OS     string `json:"os"`
+	Arch   string `json:"arch"`
+	Flavor string `json:"flavor"`
+	Bytes  int64  `json:"bytes"`
+	SHA256 string `json:"sha256"`
+}
+
+// Manifest describes a release
+type Manifest struct {
+	Version   string     `json:"version"`
+	GoVersion string     `json:"goVersion"`
+	BuiltAt   time.Time  `json:"builtAt"`
+	Artifacts []Artifact `json:"artifacts"`
+}
+
+// buildFunc compiles the server for target and flavor into out
+type buildFunc func(ctx context.Context, version string, t Target, flavor, out string) error
+
+// goBuild compiles the server with the go tool. Builds are static and
+// reproducible: cgo is off, paths are trimmed and the version is stamped.
+func goBuild(ctx context.Context, version string, t Target, flavor, out string) error {
+	args := []string{"build", "-trimpath", "-ldflags", "-s -w -X main.version=" + version, "-o", out}
+	if flavor == flavorEmbedded {
+		args = append(args, "-tags", "embeddata")
+	}
+	cmd := exec.CommandContext(ctx, "go", append(args, ".")...)
+	cmd.Env = append(os.Environ(), "CGO_ENABLED=0", "GOOS="+t.OS, "GOARCH="+t.Arch)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// parseTargets parses a comma-separated list of GOOS/GOARCH pairs
+func parseTargets(list string) ([]Target, error) {
+	var targets []Target
+	for _, item := range strings.Split(list, ",") {
+		goos, goarch, ok := strings.Cut(strings.TrimSpace(item), "/")
+		if !ok || goos == "" || goarch == "" {
+			return nil, fmt.Errorf("invalid target %q, expected GOOS/GOARCH", item)
+		}
+		targets = append(targets, Target{OS: goos, Arch: goarch})
+	}
+	return targets, nil
+}
+
+// artifactName returns the file name of a binary, e.g.
+// rewatchableGamesApi-go_v1.4.0_linux_amd64_embedded
+func artifactName(version string, t Target, flavor string) string {
+	name := fmt.Sprintf("%s_%s_%s_%s", binaryName, version, t.OS, t.Arch)
+	if flavor == flavorEmbedded {
+		name += "_" + flavorEmbedded
+	}
+	if t.OS == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+// release builds every target in both flavors into dir and writes the
+// checksums and manifest
+func release(ctx context.Context, build buildFunc, version, dir string, targets []Target) (Manifest, error) {
+	m := Manifest{Version: version, GoVersion: runtime.Version(), BuiltAt: time.Now().UTC()}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return m, err
+	}
+
+	for _, t := range targets {
+		for _, flavor := range []string{flavorPlain, flavorEmbedded} {
+			name := artifactName(version, t, flavor)
+			log.Printf("Building %s", name)
+			if err := build(ctx, version, t, flavor, filepath.Join(dir, name)); err != nil {
+				return m, fmt.Errorf("%s: %w", name, err)
+			}
+			size, sum, err := checksum(filepath.Join(dir, name))
+			if err != nil {
+				return m, err
+			}
+			m.Artifacts = append(m.Artifacts, Artifact{
+				File: name, OS: t.OS, Arch: t.Arch, Flavor: flavor, Bytes: size, SHA256: sum,
+			})
+		}
+	}
+	sort.Slice(m.Artifacts, func(i, j int) bool { return m.Artifacts[i].File < m.Artifacts[j].File })
+
+	// SHA256SUMS uses the sha256sum format so releases verify with
+	// sha256sum -c
+	var sums strings.Builder
+	for _, a := range m.Artifacts {
+		fmt.Fprintf(&sums, "%s  %s\n", a.SHA256, a.File)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "SHA256SUMS"), []byte(sums.String()), 0o644); err != nil {
+		return m, err
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return m, err
+	}
+	return m, os.WriteFile(filepath.Join(dir, "manifest.json"), append(data, '\n'), 0o644)
+}
+
+// checksum returns the size and hex SHA-256 of a file
+func checksum(name string) (int64, string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return 0, "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return 0, "", err
+	}
+	return size, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func main() {
+	fs := flag.NewFlagSet("release", flag.ExitOnError)
+	ver := fs.String("version", "", "release version, e.g. v1.4.0 (required)")
+	dir := fs.String("o", "dist", "output directory")
+	list := fs.String("targets", strings.Join(defaultTargets, ","), "comma-separated GOOS/GOARCH pairs")
+	fs.Parse(os.Args[1:])
+
+	if *ver == "" {
+		log.Fatal("-version is required")
+	}
+	targets, err := parseTargets(*list)
+	if err != nil {
+		log.Fatal(err)
+	}
+	m, err := release(context.Background(), goBuild, *ver, *dir, targets)
+	if err != nil {
+		log.Fatalf("Release failed: %v", err)
+	}
+	log.Printf("Built %d artifacts into %s", len(m.Artifacts), *dir)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseTargets(t *testing.T) {
+	targets, err := parseTargets("linux/amd64, windows/arm64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(targets) != 2 || targets[1] != (Target{OS: "windows", Arch: "arm64"}) {
+		t.Errorf("unexpected targets %v", targets)
+	}
+	for _, bad := range []string{"linux", "linux/", "/amd64", ""} {
+		if _, err := parseTargets(bad); err == nil {
+			t.Errorf("expected an error for %q", bad)
+		}
+	}
+}
+
+func TestArtifactName(t *testing.T) {
+	tests := []struct {
+		target Target
+		flavor string
+		want   string
+	}{
+		{Target{"linux", "amd64"}, flavorPlain, "rewatchableGamesApi-go_v1.0.0_linux_amd64"},
+		{Target{"linux", "arm64"}, flavorEmbedded, "rewatchableGamesApi-go_v1.0.0_linux_arm64_embedded"},
+		{Target{"windows", "amd64"}, flavorEmbedded, "rewatchableGamesApi-go_v1.0.0_windows_amd64_embedded.exe"},
+	}
+	for _, tt := range tests {
+		if got := artifactName("v1.0.0", tt.target, tt.flavor); got != tt.want {
+			t.Errorf("expected %q, got %q", tt.want, got)
+		}
+	}
+}
+
+func TestRelease(t *testing.T) {
+	dir := t.TempDir()
+	fake := func(ctx context.Context, version string, target Target, flavor, out string) error {
+		return os.WriteFile(out, []byte(version+" "+target.OS+"/"+target.Arch+" "+flavor), 0o755)
+	}
+
+	targets := []Target{{"linux", "amd64"}, {"darwin", "arm64"}}
+	m, err := release(context.Background(), fake, "v1.0.0", dir, targets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Artifacts) != 4 {
+		t.Fatalf("expected both flavors of both targets, got %v", m.Artifacts)
+	}
+
+	sums, err := os.ReadFile(filepath.Join(dir, "SHA256SUMS"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range m.Artifacts {
+		if !strings.Contains(string(sums), a.SHA256+"  "+a.File+"\n") {
+			t.Errorf("expected %s in SHA256SUMS, got %s", a.File, sums)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "manifest.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Manifest
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Version != "v1.0.0" || len(got.Artifacts) != 4 || got.Artifacts[0].Bytes == 0 {
+		t.Errorf("unexpected manifest %+v", got)
+	}
+}
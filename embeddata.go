@@ -0,0 +1,24 @@
+//go:build embeddata
+
+package main
+
+import (
+	"embed"
+	"io/fs"
+
+	"github.com/jjway/rewatchableGamesApi-go/api"
+)
+
+// The embedded data directory of release binaries built with -tags
+// embeddata
+//
+//go:embed data
+var embeddedFiles embed.FS
+
+func init() {
+	sub, err := fs.Sub(embeddedFiles, "data")
+	if err != nil {
+		panic(err)
+	}
+	api.EmbeddedData = sub
+}
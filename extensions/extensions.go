@@ -0,0 +1,102 @@
+// Package extensions lets downstream forks add organization-specific
+// routes and raters without modifying the server itself. Drop a file in
+// this package that registers its additions from an init function:
+//
+//	func init() {
+//		extensions.HandleFunc("GET /org/hello", func(w http.ResponseWriter, r *http.Request) {
+//			w.Write([]byte("hello"))
+//		})
+//		extensions.RegisterRater("closeness", func(g extensions.Game) float64 {
+//			return 40 - g.Stat("scenario.marginOfVictory")
+//		})
+//	}
+//
+// Registered routes are mounted on the server mux at startup, and every
+// registered rater's score is reported under "extensions" in processed
+// game responses.
+package extensions
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// Game is the read-only view of a game handed to raters
+type Game interface {
+	// ID returns the game identifier
+	ID() string
+	// Stat returns a numeric field by its JSON path, e.g.
+	// "offense.totalPoints" or "scenario.scenarioData.max_4th".
+	// Unknown paths return 0.
+	Stat(path string) float64
+}
+
+// RaterFunc scores a game
+type RaterFunc func(Game) float64
+
+// Route is an extension HTTP route
+type Route struct {
+	Pattern string
+	Handler http.Handler
+}
+
+var (
+	mu     sync.RWMutex
+	routes []Route
+	raters = make(map[string]RaterFunc)
+)
+
+// Handle registers an extension route using http.ServeMux pattern syntax
+func Handle(pattern string, handler http.Handler) {
+	mu.Lock()
+	defer mu.Unlock()
+	routes = append(routes, Route{Pattern: pattern, Handler: handler})
+}
+
+// HandleFunc registers an extension route handler function
+func HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	Handle(pattern, http.HandlerFunc(handler))
+}
+
+// RegisterRater registers a named rater. Registering a name twice
+// replaces the previous rater.
+func RegisterRater(name string, rate RaterFunc) {
+	mu.Lock()
+	defer mu.Unlock()
+	raters[name] = rate
+}
+
+// Routes returns the registered routes in registration order
+func Routes() []Route {
+	mu.RLock()
+	defer mu.RUnlock()
+	return append([]Route(nil), routes...)
+}
+
+// RaterNames returns the registered rater names in sorted order
+func RaterNames() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, 0, len(raters))
+	for name := range raters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Rate runs every registered rater against g. It returns nil when no
+// raters are registered.
+func Rate(g Game) map[string]float64 {
+	mu.RLock()
+	defer mu.RUnlock()
+	if len(raters) == 0 {
+		return nil
+	}
+	scores := make(map[string]float64, len(raters))
+	for name, rate := range raters {
+		scores[name] = rate(g)
+	}
+	return scores
+}
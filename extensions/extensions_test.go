@@ -0,0 +1,42 @@
+package extensions
+
+import (
+	"net/http"
+	"testing"
+)
+
+type fakeGame map[string]float64
+
+func (f fakeGame) ID() string               { return "fake" }
+func (f fakeGame) Stat(path string) float64 { return f[path] }
+
+func TestRegisterRater(t *testing.T) {
+	RegisterRater("points", func(g Game) float64 { return g.Stat("offense.totalPoints") })
+	t.Cleanup(func() {
+		mu.Lock()
+		delete(raters, "points")
+		mu.Unlock()
+	})
+
+	scores := Rate(fakeGame{"offense.totalPoints": 42})
+	if scores["points"] != 42 {
+		t.Errorf("expected points rater to return 42, got %v", scores)
+	}
+	if names := RaterNames(); len(names) != 1 || names[0] != "points" {
+		t.Errorf("unexpected rater names %v", names)
+	}
+}
+
+func TestHandleFunc(t *testing.T) {
+	HandleFunc("GET /org/hello", func(w http.ResponseWriter, r *http.Request) {})
+	t.Cleanup(func() {
+		mu.Lock()
+		routes = nil
+		mu.Unlock()
+	})
+
+	got := Routes()
+	if len(got) != 1 || got[0].Pattern != "GET /org/hello" {
+		t.Errorf("unexpected routes %+v", got)
+	}
+}
@@ -0,0 +1,107 @@
+package ratings
+
+// OffenseBreakdown is the contribution of each offensive component to the
+// offensive rating
+type OffenseBreakdown struct {
+	ExplosiveRate   float64 `json:"explosiveRate"`
+	BigPlayRate     float64 `json:"bigPlayRate"`
+	TotalPoints     float64 `json:"totalPoints"`
+	TotalYards      float64 `json:"totalYards"`
+	YardsPerAttempt float64 `json:"yardsPerAttempt"`
+	HomeQBR         float64 `json:"homeQBR"`
+	AwayQBR         float64 `json:"awayQBR"`
+}
+
+// Total sums the offensive components
+func (o OffenseBreakdown) Total() float64 {
+	return o.ExplosiveRate + o.BigPlayRate + o.TotalPoints + o.TotalYards +
+		o.YardsPerAttempt + o.HomeQBR + o.AwayQBR
+}
+
+// DefenseBreakdown is the contribution of each defensive big play term
+type DefenseBreakdown struct {
+	DefensiveTds   float64 `json:"defensiveTds"`
+	FumbleRecs     float64 `json:"fumbleRecs"`
+	SpecialTeamsTd float64 `json:"specialTeamsTd"`
+	Interceptions  float64 `json:"interceptions"`
+	BlockedKicks   float64 `json:"blockedKicks"`
+	Safeties       float64 `json:"safeties"`
+	GoalLineStands float64 `json:"goalLineStands"`
+}
+
+// Total sums the defensive terms
+func (d DefenseBreakdown) Total() float64 {
+	return d.DefensiveTds + d.FumbleRecs + d.SpecialTeamsTd + d.Interceptions +
+		d.BlockedKicks + d.Safeties + d.GoalLineStands
+}
+
+// Breakdown explains how a game's TotalRating is built
+type Breakdown struct {
+	ID                string           `json:"id"`
+	ShortName         string           `json:"shortName"`
+	Offense           OffenseBreakdown `json:"offense"`
+	Defense           DefenseBreakdown `json:"defense"`
+	OffensiveRating   float64          `json:"offensiveRating"`
+	DefensiveBigPlays float64          `json:"defensiveBigPlays"`
+	ScenarioBonus     float64          `json:"scenarioBonus,omitempty"`
+	ScenarioRating    float64          `json:"scenarioRating"`
+	TotalRating       float64          `json:"totalRating"`
+	Algorithm         string           `json:"algorithm"`
+}
+
+// Offense scores each offensive component of g
+func (c Config) Offense(g GameStats) OffenseBreakdown {
+	// If TotalPlays is 0, we can't calculate rates and likely there's no meaningful stats
+	if g.Offense.TotalPlays == 0 {
+		return OffenseBreakdown{}
+	}
+
+	explosiveRate := g.Offense.OffensiveExplosivePlays / g.Offense.TotalPlays
+	bigPlayRate := g.Offense.OffensiveBigPlays / g.Offense.TotalPlays
+
+	return OffenseBreakdown{
+		ExplosiveRate:   Score(c.ExplosiveRate, explosiveRate),
+		BigPlayRate:     Score(c.BigPlayRate, bigPlayRate),
+		TotalPoints:     Score(c.TotalPoints, g.Offense.TotalPoints),
+		TotalYards:      Score(c.TotalYards, g.Offense.TotalYards),
+		YardsPerAttempt: Score(c.YardsPerAttempt, g.Offense.TotalYardsPerAttempt),
+		HomeQBR:         Score(c.QBR, g.Offense.HomeQBR),
+		AwayQBR:         Score(c.QBR, g.Offense.AwayQBR),
+	}
+}
+
+// Defense weighs each defensive big play of g
+func (c Config) Defense(g GameStats) DefenseBreakdown {
+	return DefenseBreakdown{
+		DefensiveTds:   g.Defense.DefensiveTds * c.DefensiveTd,
+		FumbleRecs:     g.Defense.FumbleRecs * c.FumbleRec,
+		SpecialTeamsTd: g.Defense.SpecialTeamsTd * c.SpecialTeamsTd,
+		Interceptions:  g.Defense.Interceptions * c.Interception,
+		BlockedKicks:   g.Defense.BlockedKicks * c.BlockedKick,
+		Safeties:       g.Defense.Safeties * c.Safety,
+		GoalLineStands: g.Defense.GoalLineStands * c.GoalLineStand,
+	}
+}
+
+// Breakdown computes the full rating breakdown of g, without an algorithm
+// and its scenario bonus
+func (c Config) Breakdown(g GameStats) Breakdown {
+	off := c.Offense(g)
+	def := c.Defense(g)
+	b := Breakdown{
+		ID:                g.ID,
+		ShortName:         g.ShortName,
+		Offense:           off,
+		Defense:           def,
+		OffensiveRating:   off.Total(),
+		DefensiveBigPlays: def.Total(),
+		ScenarioRating:    g.Scenario.ScenarioRating,
+	}
+	b.TotalRating = b.OffensiveRating + b.DefensiveBigPlays + b.ScenarioRating
+	return b
+}
+
+// IsBlowout reports whether g was decided by at least the blowout margin
+func (c Config) IsBlowout(g GameStats) bool {
+	return g.Scenario.MarginOfVictory >= c.BlowoutMargin
+}
@@ -0,0 +1,116 @@
+package ratings
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Tier awards Points when a stat is above Min, or at least Min when
+// Inclusive is set. Tier lists are checked from the highest Min down and
+// only the first matching tier counts.
+type Tier struct {
+	Min       float64 `json:"min" yaml:"min"`
+	Inclusive bool    `json:"inclusive,omitempty" yaml:"inclusive,omitempty"`
+	Points    float64 `json:"points" yaml:"points"`
+}
+
+// Config holds the thresholds and weights of the rating formula
+type Config struct {
+	ExplosiveRate   []Tier `json:"explosiveRate" yaml:"explosiveRate"`
+	BigPlayRate     []Tier `json:"bigPlayRate" yaml:"bigPlayRate"`
+	TotalPoints     []Tier `json:"totalPoints" yaml:"totalPoints"`
+	TotalYards      []Tier `json:"totalYards" yaml:"totalYards"`
+	YardsPerAttempt []Tier `json:"yardsPerAttempt" yaml:"yardsPerAttempt"`
+	QBR             []Tier `json:"qbr" yaml:"qbr"`
+
+	// Weights of each defensive big play
+	DefensiveTd    float64 `json:"defensiveTd" yaml:"defensiveTd"`
+	FumbleRec      float64 `json:"fumbleRec" yaml:"fumbleRec"`
+	SpecialTeamsTd float64 `json:"specialTeamsTd" yaml:"specialTeamsTd"`
+	Interception   float64 `json:"interception" yaml:"interception"`
+	BlockedKick    float64 `json:"blockedKick" yaml:"blockedKick"`
+	Safety         float64 `json:"safety" yaml:"safety"`
+	GoalLineStand  float64 `json:"goalLineStand" yaml:"goalLineStand"`
+
+	// Games won by at least BlowoutMargin points are flagged as blowouts
+	BlowoutMargin float64 `json:"blowoutMargin" yaml:"blowoutMargin"`
+}
+
+// DefaultConfig returns the scoring model of the API
+func DefaultConfig() Config {
+	return Config{
+		ExplosiveRate:   []Tier{{Min: 3, Points: 1}},
+		BigPlayRate:     []Tier{{Min: 10, Points: 1}},
+		TotalPoints:     []Tier{{Min: 75, Points: 3}, {Min: 60, Points: 2}, {Min: 50, Points: 1}},
+		TotalYards:      []Tier{{Min: 1000, Points: 2}, {Min: 800, Points: 1}},
+		YardsPerAttempt: []Tier{{Min: 6, Inclusive: true, Points: 3}, {Min: 5, Inclusive: true, Points: 1}},
+		QBR:             []Tier{{Min: 120, Points: 1}, {Min: 100, Points: 0.5}},
+
+		DefensiveTd:    3,
+		FumbleRec:      1,
+		SpecialTeamsTd: 3,
+		Interception:   1,
+		BlockedKick:    1,
+		Safety:         1,
+		GoalLineStand:  1,
+
+		BlowoutMargin: 21,
+	}
+}
+
+// Score returns the points of the first tier v reaches
+func Score(tiers []Tier, v float64) float64 {
+	for _, t := range tiers {
+		if v > t.Min || (t.Inclusive && v == t.Min) {
+			return t.Points
+		}
+	}
+	return 0
+}
+
+// Validate checks that every tier list is ordered from the highest
+// threshold down and that the blowout margin is positive
+func (c Config) Validate() error {
+	lists := map[string][]Tier{
+		"explosiveRate":   c.ExplosiveRate,
+		"bigPlayRate":     c.BigPlayRate,
+		"totalPoints":     c.TotalPoints,
+		"totalYards":      c.TotalYards,
+		"yardsPerAttempt": c.YardsPerAttempt,
+		"qbr":             c.QBR,
+	}
+	for name, tiers := range lists {
+		for i := 1; i < len(tiers); i++ {
+			if tiers[i].Min > tiers[i-1].Min {
+				return fmt.Errorf("%s: tiers must be ordered by descending min", name)
+			}
+		}
+	}
+	if c.BlowoutMargin <= 0 {
+		return fmt.Errorf("blowoutMargin must be positive")
+	}
+	return nil
+}
+
+// ParseConfig decodes a rating config as YAML when ext is .yaml or .yml
+// and as JSON otherwise. Fields missing from data keep their default
+// values.
+func ParseConfig(data []byte, ext string) (Config, error) {
+	cfg := DefaultConfig()
+	var err error
+	switch ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &cfg)
+	default:
+		err = json.Unmarshal(data, &cfg)
+	}
+	if err != nil {
+		return Config{}, fmt.Errorf("parse rating config: %w", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
@@ -0,0 +1,36 @@
+package ratings
+
+import "testing"
+
+func TestScore(t *testing.T) {
+	tiers := []Tier{{Min: 6, Inclusive: true, Points: 3}, {Min: 5, Points: 1}}
+	tests := map[float64]float64{7: 3, 6: 3, 5.5: 1, 5: 0, 1: 0}
+	for v, want := range tests {
+		if got := Score(tiers, v); got != want {
+			t.Errorf("Score(%v) = %v, want %v", v, got, want)
+		}
+	}
+}
+
+func TestParseConfig(t *testing.T) {
+	cfg, err := ParseConfig([]byte("totalPoints:\n  - {min: 40, points: 5}\ndefensiveTd: 6\n"), ".yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.TotalPoints) != 1 || cfg.TotalPoints[0].Points != 5 || cfg.DefensiveTd != 6 {
+		t.Errorf("overrides not applied: %+v", cfg)
+	}
+	if cfg.Interception != 1 || len(cfg.QBR) != 2 {
+		t.Errorf("expected unset fields to keep defaults: %+v", cfg)
+	}
+
+	for _, data := range []string{
+		`{"qbr": [{"min": 100}, {"min": 120}]}`,
+		`{"blowoutMargin": 0}`,
+		`{"qbr": 3}`,
+	} {
+		if _, err := ParseConfig([]byte(data), ".json"); err == nil {
+			t.Errorf("%s: expected an error", data)
+		}
+	}
+}
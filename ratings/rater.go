@@ -0,0 +1,70 @@
+package ratings
+
+// Rater is one version of the rating algorithm
+type Rater interface {
+	Version() string
+	Rate(g GameStats) Breakdown
+}
+
+// V1 is the original formula: offense tiers, weighted defensive big plays
+// and the precomputed scenario rating
+type V1 struct {
+	Config Config
+}
+
+// NewV1 returns the v1 rater of cfg
+func NewV1(cfg Config) V1 {
+	return V1{Config: cfg}
+}
+
+func (V1) Version() string { return "v1" }
+
+func (r V1) Rate(g GameStats) Breakdown {
+	b := r.Config.Breakdown(g)
+	b.Algorithm = "v1"
+	return b
+}
+
+// V2 rewards late drama on top of v1: every fourth quarter lead change and
+// a one-score finish add to the scenario rating
+type V2 struct {
+	Config Config
+}
+
+// NewV2 returns the v2 rater of cfg
+func NewV2(cfg Config) V2 {
+	return V2{Config: cfg}
+}
+
+const (
+	v2LeadChangePoints = 1.5
+	v2MaxLeadChanges   = 4
+	v2OneScoreMargin   = 8
+	v2OneScorePoints   = 1
+	v2FieldGoalMargin  = 3
+	v2FieldGoalPoints  = 2
+)
+
+func (V2) Version() string { return "v2" }
+
+func (r V2) Rate(g GameStats) Breakdown {
+	b := r.Config.Breakdown(g)
+	b.Algorithm = "v2"
+
+	changes := g.Scenario.FourthQuarterLeadershipChange
+	if changes > v2MaxLeadChanges {
+		changes = v2MaxLeadChanges
+	}
+	bonus := changes * v2LeadChangePoints
+	switch margin := g.Scenario.MarginOfVictory; {
+	case margin <= v2FieldGoalMargin:
+		bonus += v2FieldGoalPoints
+	case margin <= v2OneScoreMargin:
+		bonus += v2OneScorePoints
+	}
+
+	b.ScenarioBonus = bonus
+	b.ScenarioRating += bonus
+	b.TotalRating += bonus
+	return b
+}
@@ -0,0 +1,51 @@
+package ratings
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestRaters(t *testing.T) {
+	var g GameStats
+	g.Offense.TotalPlays = 100
+	g.Offense.TotalPoints = 55
+	g.Defense.DefensiveTds = 1
+	g.Scenario.ScenarioRating = 5
+	g.Scenario.MarginOfVictory = 3
+	g.Scenario.FourthQuarterLeadershipChange = 6
+
+	cfg := DefaultConfig()
+	v1 := NewV1(cfg).Rate(g)
+	if v1.OffensiveRating != 1 || v1.DefensiveBigPlays != 3 || v1.TotalRating != 9 || v1.Algorithm != "v1" {
+		t.Errorf("unexpected v1 breakdown %+v", v1)
+	}
+	// Lead changes are capped at 4: 4*1.5 + 2 for a field goal finish
+	v2 := NewV2(cfg).Rate(g)
+	if v2.ScenarioBonus != 8 || v2.TotalRating != 17 || v2.Algorithm != "v2" {
+		t.Errorf("unexpected v2 breakdown %+v", v2)
+	}
+
+	cfg.DefensiveTd = 5
+	if b := NewV1(cfg).Rate(g); b.DefensiveBigPlays != 5 {
+		t.Errorf("expected the config to weigh defensive touchdowns, got %+v", b)
+	}
+	if g.Scenario.MarginOfVictory = 21; !cfg.IsBlowout(g) {
+		t.Error("expected a 21 point game to be a blowout")
+	}
+}
+
+func Example() {
+	data := []byte(`[{"id": "401547665", "shortName": "BUF @ KC",
+		"scenario": {"marginOfVictory": 3, "fourthQuarterLeadershipChange": 2, "scenarioRating": 4},
+		"offense": {"totalPlays": 120, "totalPoints": 61, "totalYards": 850}}]`)
+	var games []GameStats
+	if err := json.Unmarshal(data, &games); err != nil {
+		panic(err)
+	}
+	rater := NewV2(DefaultConfig())
+	for _, g := range games {
+		fmt.Println(g.ShortName, rater.Rate(g).TotalRating)
+	}
+	// Output: BUF @ KC 12
+}
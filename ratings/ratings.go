@@ -0,0 +1,94 @@
+// Package ratings rates how rewatchable an NFL game is from its stats,
+// the way the API does. It has no dependency on the server, so other
+// services can decode the week files and rate their games:
+//
+//	var games []ratings.GameStats
+//	if err := json.Unmarshal(data, &games); err != nil {
+//		return err
+//	}
+//	rater := ratings.NewV2(ratings.DefaultConfig())
+//	for _, g := range games {
+//		fmt.Println(g.ShortName, rater.Rate(g).TotalRating)
+//	}
+package ratings
+
+import "time"
+
+// GameStats is a game of a week file. It mirrors the structure in types.ts
+// of the frontend.
+type GameStats struct {
+	ID             string `json:"id"`
+	Week           int    `json:"week,omitempty" unit:"week" range:"1-18" better:"neutral" desc:"Week of the season"`
+	FullName       string `json:"fullName"`
+	ShortName      string `json:"shortName"`
+	MatchupQuality string `json:"matchupQuality"`
+	Efficiency     struct {
+		HomeTeamEfficiency          float64 `json:"homeTeamEfficiency" unit:"percent" range:"8-96" better:"higher" desc:"Overall efficiency of the home team"`
+		AwayTeamEfficiency          float64 `json:"awayTeamEfficiency" unit:"percent" range:"4-92" better:"higher" desc:"Overall efficiency of the away team"`
+		HomeTeamOffensiveEfficiency float64 `json:"homeTeamOffensiveEfficiency" unit:"percent" range:"6-92" better:"higher" desc:"Offensive efficiency of the home team"`
+		HomeTeamDefensiveEfficiency float64 `json:"homeTeamDefensiveEfficiency" unit:"percent" range:"10-96" better:"higher" desc:"Defensive efficiency of the home team"`
+		AwayTeamOffensiveEfficiency float64 `json:"awayTeamOffensiveEfficiency" unit:"percent" range:"4-90" better:"higher" desc:"Offensive efficiency of the away team"`
+		AwayTeamDefensiveEfficiency float64 `json:"awayTeamDefensiveEfficiency" unit:"percent" range:"8-94" better:"higher" desc:"Defensive efficiency of the away team"`
+		HomeTeamPerformance         float64 `json:"homeTeamPerformance" unit:"percent" range:"7-96" better:"higher" desc:"Game performance of the home team"`
+		AwayTeamPerformance         float64 `json:"awayTeamPerformance" unit:"percent" range:"5-95" better:"higher" desc:"Game performance of the away team"`
+	} `json:"efficiency"`
+	Scenario struct {
+		MarginOfVictory               float64 `json:"marginOfVictory" unit:"points" range:"1-29" better:"lower" desc:"Final score difference"`
+		FourthQuarterLeadershipChange float64 `json:"fourthQuarterLeadershipChange" unit:"count" range:"0-2" better:"higher" desc:"Lead changes in the fourth quarter"`
+		LeadershipChange              float64 `json:"leadershipChange" unit:"count" range:"1-5" better:"higher" desc:"Lead changes over the game"`
+		ScenarioRating                float64 `json:"scenarioRating" unit:"score" range:"0-6" better:"higher" desc:"Precomputed rating of how dramatic the game script was"`
+		ScenarioData                  struct {
+			MaxWinProbability float64 `json:"maxWinProbability" unit:"probability" range:"0.43-1" better:"neutral" desc:"Highest win probability reached during the game"`
+			MinWinProbability float64 `json:"minWinProbability" unit:"probability" range:"0-0.7" better:"neutral" desc:"Lowest win probability reached during the game"`
+			InversionOfLead   float64 `json:"inversionOfLead" unit:"count" range:"0-20" better:"higher" desc:"Win probability lead inversions"`
+			ShareOfLead       float64 `json:"shareOfLead" unit:"ratio" range:"0-1" better:"neutral" desc:"Share of the game with a lead"`
+			Max4th            float64 `json:"max_4th" unit:"probability" range:"0.02-1" better:"neutral" desc:"Highest win probability reached in the fourth quarter"`
+			Min4th            float64 `json:"min_4th" unit:"probability" range:"0-1" better:"neutral" desc:"Lowest win probability reached in the fourth quarter"`
+			Inv4th            float64 `json:"inv_4th" unit:"count" range:"0-8" better:"higher" desc:"Win probability lead inversions in the fourth quarter"`
+			Share4th          float64 `json:"share_4th" unit:"ratio" range:"0-0.25" better:"neutral" desc:"Share of the fourth quarter with a lead"`
+		} `json:"scenarioData"`
+	} `json:"scenario"`
+	Offense struct {
+		OffensiveBigPlays        float64 `json:"offensiveBigPlays" unit:"count" range:"4-14" better:"higher" desc:"Big plays by both offenses"`
+		OffensiveExplosivePlays  float64 `json:"offensiveExplosivePlays" unit:"count" range:"0-4" better:"higher" desc:"Explosive plays by both offenses, rarer than big plays"`
+		ExplosiveRate            float64 `json:"explosiveRate" unit:"ratio" range:"0-0.04" better:"higher" desc:"Explosive plays per play"`
+		TotalPlays               float64 `json:"totalPlays" unit:"count" range:"107-132" better:"neutral" desc:"Offensive plays by both teams"`
+		TotalPoints              float64 `json:"totalPoints" unit:"points" range:"24-69" better:"higher" desc:"Combined points scored by both teams"`
+		TotalYards               float64 `json:"totalYards" unit:"yards" range:"484-842" better:"higher" desc:"Combined yards gained by both teams"`
+		TotalYardsPerAttempt     float64 `json:"totalYardsPerAttempt" unit:"yards" range:"4.1-6.9" better:"higher" desc:"Combined yards per play"`
+		TotalPassYards           float64 `json:"totalPassYards" unit:"yards" range:"272-567" better:"higher" desc:"Combined passing yards"`
+		TotalPassYardsPerAttempt float64 `json:"totalPassYardsPerAttempt" unit:"yards" range:"8.3-13.2" better:"higher" desc:"Combined passing yards per attempt"`
+		TotalRushYards           float64 `json:"totalRushYards" unit:"yards" range:"129-312" better:"higher" desc:"Combined rushing yards"`
+		TotalRushYardsPerAttempt float64 `json:"totalRushYardsPerAttempt" unit:"yards" range:"2.8-5.7" better:"higher" desc:"Combined rushing yards per attempt"`
+		HomeQBR                  float64 `json:"homeQBR" unit:"rating" range:"54-135" better:"higher" desc:"Passer rating of the home quarterback"`
+		AwayQBR                  float64 `json:"awayQBR" unit:"rating" range:"47-133" better:"higher" desc:"Passer rating of the away quarterback"`
+	} `json:"offense"`
+	Defense struct {
+		Punts          float64 `json:"punts" unit:"count" range:"3-13" better:"lower" desc:"Punts by both teams"`
+		Sacks          float64 `json:"sacks" unit:"count" range:"1-8" better:"higher" desc:"Sacks by both defenses"`
+		Interceptions  float64 `json:"interceptions" unit:"count" range:"0-4" better:"higher" desc:"Interceptions by both defenses"`
+		DefensiveTds   float64 `json:"defensiveTds" unit:"count" range:"0-1" better:"higher" desc:"Defensive touchdowns"`
+		FumbleRecs     float64 `json:"fumbleRecs" unit:"count" range:"0-2" better:"higher" desc:"Fumbles recovered by the defense"`
+		BlockedKicks   float64 `json:"blockedKicks" unit:"count" range:"0-1" better:"higher" desc:"Blocked punts and kicks"`
+		Safeties       float64 `json:"safeties" unit:"count" range:"0-0" better:"higher" desc:"Safeties"`
+		SpecialTeamsTd float64 `json:"specialTeamsTd" unit:"count" range:"0-1" better:"higher" desc:"Special teams touchdowns"`
+		GoalLineStands float64 `json:"goalLineStands" unit:"count" range:"0-1" better:"higher" desc:"Goal line stands"`
+	} `json:"defense"`
+	Conditions *GameConditions `json:"conditions,omitempty"`
+}
+
+// GameConditions is the kickoff time, venue and weather of a game, added
+// to existing seasons by the server's backfill job
+type GameConditions struct {
+	Kickoff time.Time    `json:"kickoff"`
+	Venue   string       `json:"venue,omitempty"`
+	Indoor  bool         `json:"indoor,omitempty"`
+	Weather *GameWeather `json:"weather,omitempty"`
+}
+
+// GameWeather is the weather at kickoff of an outdoor game
+type GameWeather struct {
+	Condition    string  `json:"condition"`
+	TemperatureF float64 `json:"temperatureF"`
+	WindMph      float64 `json:"windMph,omitempty"`
+}
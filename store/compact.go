@@ -0,0 +1,157 @@
+package store
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"strings"
+	"time"
+)
+
+// A compacted season is stored next to the year directories as two files:
+//
+//	{year}.season      the week files, each gzipped as its own member
+//	{year}.season.idx  a JSON index locating each week in the season file
+//
+// FS reads both this layout and plain {year}/{week}.json files.
+const (
+	SeasonFileExt  = ".season"
+	SeasonIndexExt = ".season.idx"
+)
+
+// SeasonIndex is the content of a {year}.season.idx file
+type SeasonIndex struct {
+	Weeks []SeasonIndexEntry `json:"weeks"`
+}
+
+// SeasonIndexEntry locates one gzipped week inside the season file
+type SeasonIndexEntry struct {
+	Week   string `json:"week"`
+	Offset int64  `json:"offset"`
+	Length int64  `json:"length"`
+	Games  int    `json:"games"`
+}
+
+// ReadSeasonIndex reads the index of a compacted season
+func ReadSeasonIndex(fsys fs.FS, year string) (SeasonIndex, error) {
+	var idx SeasonIndex
+	data, err := fs.ReadFile(fsys, year+SeasonIndexExt)
+	if err != nil {
+		return idx, err
+	}
+	if err := json.Unmarshal(data, &idx); err != nil {
+		return idx, fmt.Errorf("parse %s%s: %w", year, SeasonIndexExt, err)
+	}
+	return idx, nil
+}
+
+// compactedSeason is the parsed index of a compacted season, kept by FS
+// along with the size and modification time of the index file it was
+// read from, so a recompacted season is read again
+type compactedSeason struct {
+	size    int64
+	modTime time.Time
+	weeks   map[string]SeasonIndexEntry
+	order   []string
+}
+
+// compactedSeason returns the parsed index of a compacted season, reading
+// it only when the index file changed since it was last parsed
+func (s *FS) compactedSeason(year string) (*compactedSeason, error) {
+	info, err := fs.Stat(s.fsys, year+SeasonIndexExt)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	cs, ok := s.seasons[year]
+	s.mu.Unlock()
+	if ok && cs.size == info.Size() && cs.modTime.Equal(info.ModTime()) {
+		return cs, nil
+	}
+
+	idx, err := ReadSeasonIndex(s.fsys, year)
+	if err != nil {
+		return nil, err
+	}
+	cs = &compactedSeason{size: info.Size(), modTime: info.ModTime(), weeks: make(map[string]SeasonIndexEntry, len(idx.Weeks))}
+	for _, e := range idx.Weeks {
+		cs.weeks[e.Week] = e
+		cs.order = append(cs.order, e.Week)
+	}
+	s.mu.Lock()
+	if s.seasons == nil {
+		s.seasons = make(map[string]*compactedSeason)
+	}
+	s.seasons[year] = cs
+	s.mu.Unlock()
+	return cs, nil
+}
+
+// readCompactedWeek reads a week file from a compacted season, only the
+// bytes of its gzip member
+func (s *FS) readCompactedWeek(name string) ([]byte, error) {
+	year, file, _ := strings.Cut(name, "/")
+	week := strings.TrimSuffix(file, ".json")
+
+	cs, err := s.compactedSeason(year)
+	if err != nil {
+		return nil, err
+	}
+	e, ok := cs.weeks[week]
+	if !ok {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrNotExist}
+	}
+
+	f, err := s.fsys.Open(year + SeasonFileExt)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if e.Offset < 0 || e.Length < 0 || e.Offset+e.Length > info.Size() {
+		return nil, fmt.Errorf("%s: index entry out of range", name)
+	}
+	var member io.Reader
+	if ra, ok := f.(io.ReaderAt); ok {
+		member = io.NewSectionReader(ra, e.Offset, e.Length)
+	} else {
+		// Files without random access are read up to the member
+		if _, err := io.CopyN(io.Discard, f, e.Offset); err != nil {
+			return nil, err
+		}
+		member = io.LimitReader(f, e.Length)
+	}
+	zr, err := gzip.NewReader(member)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
+
+// listCompactedWeeks returns the week file names of every compacted season
+func (s *FS) listCompactedWeeks() ([]string, error) {
+	matches, err := fs.Glob(s.fsys, "*"+SeasonIndexExt)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, m := range matches {
+		year := strings.TrimSuffix(m, SeasonIndexExt)
+		cs, err := s.compactedSeason(year)
+		if err != nil {
+			log.Printf("Warning: skipping compacted season %s: %v", year, err)
+			continue
+		}
+		for _, week := range cs.order {
+			names = append(names, WeekFile(year, week))
+		}
+	}
+	return names, nil
+}
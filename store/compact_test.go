@@ -0,0 +1,86 @@
+package store
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// compactTestSeason writes the compacted layout of the weeks of dir/year,
+// leaving the week files in place
+func compactTestSeason(t *testing.T, dir, year string, weeks ...string) {
+	t.Helper()
+	var season bytes.Buffer
+	var idx SeasonIndex
+	for _, week := range weeks {
+		data, err := os.ReadFile(filepath.Join(dir, year, week+".json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		offset := int64(season.Len())
+		zw := gzip.NewWriter(&season)
+		zw.Write(data)
+		zw.Close()
+		idx.Weeks = append(idx.Weeks, SeasonIndexEntry{Week: week, Offset: offset, Length: int64(season.Len()) - offset, Games: 1})
+	}
+	indexData, _ := json.Marshal(idx)
+	if err := os.WriteFile(filepath.Join(dir, year+SeasonFileExt), season.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, year+SeasonIndexExt), indexData, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// indexReadsFS counts the reads of the season indexes of the wrapped
+// filesystem
+type indexReadsFS struct {
+	fs.FS
+	reads int
+}
+
+func (f *indexReadsFS) ReadFile(name string) ([]byte, error) {
+	if strings.HasSuffix(name, SeasonIndexExt) {
+		f.reads++
+	}
+	return fs.ReadFile(f.FS, name)
+}
+
+func TestCompactedSeasonIndexIsCached(t *testing.T) {
+	dir := setupTestData(t)
+	compactTestSeason(t, dir, "2024", "1", "2")
+	fsys := &indexReadsFS{FS: os.DirFS(dir)}
+	s := NewFS(fsys)
+
+	// -keep leaves both layouts, each week is listed once
+	names, err := s.ListFiles()
+	if err != nil || len(names) != 2 {
+		t.Fatalf("expected each week once, got %v %v", names, err)
+	}
+
+	os.RemoveAll(filepath.Join(dir, "2024"))
+	for i := 0; i < 3; i++ {
+		for _, name := range []string{"2024/1.json", "2024/2.json"} {
+			if data, err := s.ReadFile(name); err != nil || string(data) != testData {
+				t.Fatalf("%s: unexpected read %v", name, err)
+			}
+		}
+	}
+	if fsys.reads != 1 {
+		t.Errorf("expected the index to be parsed once, got %d reads", fsys.reads)
+	}
+
+	// A recompacted season is read again
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(filepath.Join(dir, "2024"+SeasonIndexExt), later, later)
+	s.ReadFile("2024/1.json")
+	if fsys.reads != 2 {
+		t.Errorf("expected the changed index to be parsed again, got %d reads", fsys.reads)
+	}
+}
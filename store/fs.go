@@ -0,0 +1,128 @@
+package store
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// FS reads week files from an fs.FS laid out as {year}/{week}.json, or as
+// compacted {year}.season files
+type FS struct {
+	fsys fs.FS
+
+	mu      sync.Mutex
+	seasons map[string]*compactedSeason // parsed season indexes, by year
+}
+
+// NewFS returns the store of the week files of fsys
+func NewFS(fsys fs.FS) *FS {
+	return &FS{fsys: fsys}
+}
+
+func (s *FS) ReadFile(name string) ([]byte, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrNotExist}
+	}
+	data, err := fs.ReadFile(s.fsys, name)
+	if errors.Is(err, fs.ErrNotExist) && IsWeekFile(name) {
+		// The week may live in a compacted season file
+		if compacted, cerr := s.readCompactedWeek(name); cerr == nil || !errors.Is(cerr, fs.ErrNotExist) {
+			return compacted, cerr
+		}
+	}
+	return data, err
+}
+
+func (s *FS) ListFiles() ([]string, error) {
+	years, err := fs.ReadDir(s.fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, year := range years {
+		if !year.IsDir() {
+			continue
+		}
+		weeks, err := fs.ReadDir(s.fsys, year.Name())
+		if err != nil {
+			continue
+		}
+		for _, week := range weeks {
+			if week.IsDir() || !strings.HasSuffix(week.Name(), ".json") {
+				continue
+			}
+			names = append(names, year.Name()+"/"+week.Name())
+		}
+	}
+
+	compacted, err := s.listCompactedWeeks()
+	if err != nil {
+		return nil, err
+	}
+	// A season compacted with -keep is in both layouts
+	listed := make(map[string]bool, len(names))
+	for _, name := range names {
+		listed[name] = true
+	}
+	for _, name := range compacted {
+		if !listed[name] {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+func (s *FS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return fs.Stat(s.fsys, name)
+}
+
+// Dir is an FS over a local directory. The root is kept so the directory
+// can be watched for changes and written to.
+type Dir struct {
+	*FS
+	root string
+}
+
+// NewDir returns the store of the week files under root
+func NewDir(root string) *Dir {
+	return &Dir{FS: NewFS(os.DirFS(root)), root: root}
+}
+
+// Root returns the directory of the store
+func (s *Dir) Root() string {
+	return s.root
+}
+
+// WriteFile writes a week file atomically under the directory
+func (s *Dir) WriteFile(name string, data []byte) error {
+	path := filepath.Join(s.root, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return WriteFileAtomic(path, data)
+}
+
+// WriteFileAtomic writes data to a temporary file and renames it into place
+func WriteFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,178 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Object reads week files from an S3-compatible bucket over HTTP.
+// GCS buckets work through their XML API. The URL of NewObject is the
+// bucket URL with an optional key prefix, e.g. https://bucket.s3.amazonaws.com/data or
+// https://storage.googleapis.com/bucket/data. Private buckets need creds:
+// an access key for S3, an HMAC key or a bearer token for GCS.
+type Object struct {
+	bucketURL string
+	prefix    string
+	creds     Credentials
+	client    *http.Client
+}
+
+// objectTimeout bounds each request to the bucket, body included
+const objectTimeout = 30 * time.Second
+
+// NewObject returns the store of the bucket at baseURL
+func NewObject(baseURL string, creds Credentials) *Object {
+	if creds.Region == "" {
+		creds.Region = DefaultRegion
+	}
+	s := &Object{creds: creds, client: &http.Client{Timeout: objectTimeout}}
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		s.bucketURL = strings.TrimSuffix(baseURL, "/")
+		return s
+	}
+
+	// Path-style GCS URLs carry the bucket in the first path segment
+	p := strings.Trim(u.Path, "/")
+	if u.Host == "storage.googleapis.com" {
+		bucket, rest, _ := strings.Cut(p, "/")
+		u.Path = "/" + bucket
+		p = rest
+	} else {
+		u.Path = ""
+	}
+	s.bucketURL = strings.TrimSuffix(u.String(), "/")
+	if p != "" {
+		s.prefix = p + "/"
+	}
+	return s
+}
+
+// emptySHA256 is the SHA-256 of an empty body, the payload hash of a GET
+var emptySHA256 = sha256Hex(nil)
+
+func (s *Object) get(ctx context.Context, rawURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	s.creds.authorize(req, emptySHA256)
+	return s.client.Do(req)
+}
+
+// Put uploads data under the key name, below the prefix
+func (s *Object) Put(ctx context.Context, name string, data []byte, contentType, contentEncoding string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.bucketURL+"/"+path.Join(s.prefix, name), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if contentEncoding != "" {
+		req.Header.Set("Content-Encoding", contentEncoding)
+	}
+	s.creds.authorize(req, sha256Hex(data))
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("PUT %s: unexpected status %d", name, resp.StatusCode)
+	}
+	return nil
+}
+
+func (s *Object) ReadFile(name string) ([]byte, error) {
+	return s.ReadFileContext(context.Background(), name)
+}
+
+// ReadFileContext reads name, giving up when ctx is done
+func (s *Object) ReadFileContext(ctx context.Context, name string) ([]byte, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrNotExist}
+	}
+	resp, err := s.get(ctx, s.bucketURL+"/"+path.Join(s.prefix, name))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return io.ReadAll(resp.Body)
+	case http.StatusNotFound:
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrNotExist}
+	case http.StatusForbidden:
+		// Public buckets answer 403 for missing keys when listing is
+		// denied. With credentials it is the key that is refused, which
+		// must not pass for missing weeks.
+		if s.creds.AccessKeyID == "" && s.creds.Token == "" {
+			return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrNotExist}
+		}
+		return nil, fmt.Errorf("GET %s: access denied, status %d: check the credentials", name, resp.StatusCode)
+	default:
+		return nil, fmt.Errorf("GET %s: unexpected status %d", name, resp.StatusCode)
+	}
+}
+
+// listBucketResult is the subset of a ListObjectsV2 response we need
+type listBucketResult struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+func (s *Object) ListFiles() ([]string, error) {
+	var names []string
+	token := ""
+	for {
+		q := url.Values{"list-type": {"2"}, "prefix": {s.prefix}}
+		if token != "" {
+			q.Set("continuation-token", token)
+		}
+		resp, err := s.get(context.Background(), s.bucketURL+"/?"+q.Encode())
+		if err != nil {
+			return nil, err
+		}
+		var result listBucketResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("list bucket: unexpected status %d", resp.StatusCode)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("list bucket: %w", err)
+		}
+
+		for _, obj := range result.Contents {
+			name := strings.TrimPrefix(obj.Key, s.prefix)
+			if IsWeekFile(name) {
+				names = append(names, name)
+			}
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		token = result.NextContinuationToken
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// WriteFile uploads a week file with a PUT, which S3 and the GCS XML API
+// both accept
+func (s *Object) WriteFile(name string, data []byte) error {
+	return s.Put(context.Background(), name, data, "application/json", "")
+}
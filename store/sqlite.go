@@ -0,0 +1,68 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"io/fs"
+
+	_ "modernc.org/sqlite"
+)
+
+// SQLite keeps week files as rows of a single table:
+//
+//	CREATE TABLE week_files (name TEXT PRIMARY KEY, data BLOB NOT NULL)
+type SQLite struct {
+	db *sql.DB
+}
+
+// OpenSQLite opens the database of dsn, creating the table if needed
+func OpenSQLite(dsn string) (*SQLite, error) {
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS week_files (
+		name TEXT PRIMARY KEY,
+		data BLOB NOT NULL
+	)`); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("create week_files table: %w", err)
+	}
+	return &SQLite{db: db}, nil
+}
+
+func (s *SQLite) ReadFile(name string) ([]byte, error) {
+	var data []byte
+	err := s.db.QueryRow(`SELECT data FROM week_files WHERE name = ?`, name).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrNotExist}
+	}
+	return data, err
+}
+
+func (s *SQLite) ListFiles() ([]string, error) {
+	rows, err := s.db.Query(`SELECT name FROM week_files ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		if IsWeekFile(name) {
+			names = append(names, name)
+		}
+	}
+	return names, rows.Err()
+}
+
+// WriteFile replaces the row of a week file
+func (s *SQLite) WriteFile(name string, data []byte) error {
+	_, err := s.db.Exec(`INSERT INTO week_files (name, data) VALUES (?, ?)
+		ON CONFLICT(name) DO UPDATE SET data = excluded.data`, name, data)
+	return err
+}
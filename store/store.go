@@ -0,0 +1,56 @@
+// Package store reads and writes the week files the API serves: one JSON
+// array of games per week, named {year}/{week}.json. The files live in a
+// local directory or any fs.FS, a SQLite table or an S3-compatible bucket.
+package store
+
+import (
+	"context"
+	"io/fs"
+	"strings"
+)
+
+// Store is the source of the week files. Names are slash-separated and
+// relative to the store root, e.g. "2024/1.json". Missing files are
+// reported with an error matching fs.ErrNotExist.
+type Store interface {
+	ReadFile(name string) ([]byte, error)
+	ListFiles() ([]string, error)
+}
+
+// StatStore is a Store that can report the size and modification time of
+// a week file without reading it
+type StatStore interface {
+	Store
+	Stat(name string) (fs.FileInfo, error)
+}
+
+// ContextStore is a Store whose reads can be cancelled, such as one
+// reading over the network
+type ContextStore interface {
+	Store
+	ReadFileContext(ctx context.Context, name string) ([]byte, error)
+}
+
+// WritableStore is a Store that ingestion can write week files to
+type WritableStore interface {
+	Store
+	WriteFile(name string, data []byte) error
+}
+
+// WeekFile returns the store name of a week file
+func WeekFile(year, week string) string {
+	return year + "/" + week + ".json"
+}
+
+// IsWeekFile reports whether name has the {year}/{week}.json layout
+func IsWeekFile(name string) bool {
+	return strings.Count(name, "/") == 1 && strings.HasSuffix(name, ".json")
+}
+
+// ReadFile reads name from s, bound to ctx when s supports it
+func ReadFile(ctx context.Context, s Store, name string) ([]byte, error) {
+	if cs, ok := s.(ContextStore); ok {
+		return cs.ReadFileContext(ctx, name)
+	}
+	return s.ReadFile(name)
+}
@@ -0,0 +1,167 @@
+package store
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testData is a week file of one game
+const testData = `[{"id": "game1", "shortName": "A @ B"}]`
+
+// setupTestData writes weeks 1 and 2 of 2024 to a temporary directory
+func setupTestData(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "2024"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, week := range []string{"1", "2"} {
+		if err := os.WriteFile(filepath.Join(dir, "2024", week+".json"), []byte(testData), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestDirStore(t *testing.T) {
+	s := NewDir(setupTestData(t))
+
+	names, err := s.ListFiles()
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	if len(names) != 2 || names[0] != "2024/1.json" {
+		t.Errorf("unexpected names %v", names)
+	}
+
+	if _, err := s.ReadFile("2024/3.json"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected ErrNotExist for missing week, got %v", err)
+	}
+	if _, err := s.ReadFile("../2024/1.json"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected ErrNotExist for path escaping the root, got %v", err)
+	}
+}
+
+func TestSQLiteStore(t *testing.T) {
+	s, err := OpenSQLite(filepath.Join(t.TempDir(), "games.db"))
+	if err != nil {
+		t.Fatalf("open failed: %v", err)
+	}
+	defer s.db.Close()
+
+	if _, err := s.db.Exec(`INSERT INTO week_files (name, data) VALUES (?, ?)`, "2024/1.json", []byte(testData)); err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+
+	data, err := s.ReadFile("2024/1.json")
+	if err != nil || string(data) != testData {
+		t.Errorf("unexpected read result: %v", err)
+	}
+	if _, err := s.ReadFile("2024/2.json"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected ErrNotExist, got %v", err)
+	}
+
+	names, err := s.ListFiles()
+	if err != nil || len(names) != 1 {
+		t.Errorf("unexpected list result %v: %v", names, err)
+	}
+}
+
+func TestSQLiteStoreWriteFile(t *testing.T) {
+	s, err := OpenSQLite(filepath.Join(t.TempDir(), "weeks.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, body := range []string{"[1]", "[2]"} {
+		if err := s.WriteFile("2024/1.json", []byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if data, err := s.ReadFile("2024/1.json"); err != nil || string(data) != "[2]" {
+		t.Errorf("expected the latest write, got %q, %v", data, err)
+	}
+}
+
+func TestObjectStore(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Query().Get("list-type") == "2":
+			if r.URL.Query().Get("prefix") != "data/" {
+				t.Errorf("expected prefix data/, got %q", r.URL.Query().Get("prefix"))
+			}
+			w.Write([]byte(`<ListBucketResult>
+				<Contents><Key>data/2024/1.json</Key></Contents>
+				<Contents><Key>data/README.md</Key></Contents>
+				<IsTruncated>false</IsTruncated>
+			</ListBucketResult>`))
+		case r.URL.Path == "/data/2024/1.json":
+			w.Write([]byte(testData))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	s := NewObject(srv.URL+"/data", Credentials{})
+
+	names, err := s.ListFiles()
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	if len(names) != 1 || names[0] != "2024/1.json" {
+		t.Errorf("unexpected names %v", names)
+	}
+
+	if data, err := s.ReadFile("2024/1.json"); err != nil || string(data) != testData {
+		t.Errorf("unexpected read result: %v", err)
+	}
+	if _, err := s.ReadFile("2024/2.json"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected ErrNotExist, got %v", err)
+	}
+}
+
+func TestObjectStoreForbidden(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	// A public bucket denying the listing answers 403 for missing keys
+	if _, err := NewObject(srv.URL, Credentials{}).ReadFile("2024/1.json"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected ErrNotExist from an anonymous bucket, got %v", err)
+	}
+	for _, creds := range []Credentials{{AccessKeyID: "id", SecretAccessKey: "secret"}, {Token: "tok"}} {
+		_, err := NewObject(srv.URL, creds).ReadFile("2024/1.json")
+		if err == nil || errors.Is(err, fs.ErrNotExist) || !strings.Contains(err.Error(), "403") {
+			t.Errorf("expected refused credentials to be an error with the status, got %v", err)
+		}
+	}
+}
+
+func TestObjectStoreWriteFile(t *testing.T) {
+	var gotPath, gotBody, gotAuth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		gotPath, gotBody, gotAuth = r.URL.Path, string(body), r.Header.Get("Authorization")
+	}))
+	defer srv.Close()
+
+	s := NewObject(srv.URL+"/data", Credentials{Token: "tok"})
+	if err := s.WriteFile("2024/1.json", []byte("[]")); err != nil {
+		t.Fatal(err)
+	}
+	if gotPath != "/data/2024/1.json" || gotBody != "[]" || gotAuth != "Bearer tok" {
+		t.Errorf("unexpected PUT %s %q %q", gotPath, gotBody, gotAuth)
+	}
+}